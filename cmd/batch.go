@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// fileSkipDetectors flags skipped chunks separately for every file of a batch,
+// the chunk indices of different files are unrelated
+type fileSkipDetectors struct {
+	// dir is the directory decoded chunks are stored in
+	dir string
+	// detectors maps file IDs to their detector, "" for a single file
+	detectors map[string]*qrfiletransfer.SkipDetector
+}
+
+// newFileSkipDetectors returns skip detectors for the chunks stored in dir
+func newFileSkipDetectors(dir string) *fileSkipDetectors {
+	return &fileSkipDetectors{dir: dir, detectors: make(map[string]*qrfiletransfer.SkipDetector)}
+}
+
+// observe records a decoded chunk of a file, see qrfiletransfer.SkipDetector.Observe.
+// Chunks decoded by a previous run into the same directory are not flagged.
+func (d *fileSkipDetectors) observe(file string, index int, next []int) []int {
+	detector, ok := d.detectors[file]
+	if !ok {
+		var known []int
+		if report, err := newQRFileTransfer().VerifyChunks(filepath.Join(d.dir, file)); err == nil {
+			known = report.Present
+		}
+
+		detector = qrfiletransfer.NewSkipDetector(known...)
+		d.detectors[file] = detector
+	}
+
+	return detector.Observe(index, next)
+}
+
+// files returns the IDs of the files observed so far in order
+func (d *fileSkipDetectors) files() []string {
+	files := make([]string, 0, len(d.detectors))
+	for file := range d.detectors {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
+
+// fileLabel names the file of a batch a chunk belongs to in messages
+func fileLabel(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	return " of file " + file
+}
+
+// splitBatch encodes several files into one batch of QR codes in outDir and lists
+// the session directory of every file
+func splitBatch(qrft *qrfiletransfer.QRFileTransfer, files []string, outDir string) error {
+	stopEncode := timePhase("encode")
+	batch, err := qrft.FilesToQRCodesCtx(runCtx, files, outDir)
+	stopEncode()
+
+	if err != nil {
+		return err
+	}
+
+	sessions := make([]sessionResult, 0, len(batch.Files))
+
+	for _, f := range batch.Files {
+		if session, err := qrfiletransfer.OpenSession(filepath.Join(outDir, f.ID)); err == nil {
+			sessions = append(sessions, newSessionResult(session))
+		}
+
+		fmt.Printf("File %s: %s (%d bytes) in '%s'\n", f.ID, f.Name, f.Size, filepath.Join(outDir, f.ID))
+	}
+
+	setResult("sessions", sessions)
+
+	fmt.Printf("Successfully split %d files into QR codes in '%s'\n", len(batch.Files), outDir)
+
+	return nil
+}
+
+// reconstructBatch reconstructs every complete file of the batch in dir into outDir
+// and reports the files that are still incomplete, failing unless every file was
+// reconstructed
+func reconstructBatch(qrft *qrfiletransfer.QRFileTransfer, dir, outDir string) error {
+	fmt.Printf("Reconstructing the files of the batch into directory '%s'...\n", outDir)
+
+	// Files are reconstructed concurrently, report each as it completes
+	qrft.SetBatchProgress(func(p qrfiletransfer.BatchProgress) {
+		if !p.Done {
+			return
+		}
+
+		if p.Err != nil {
+			fmt.Printf("File %s: failed [%d/%d]: %v\n", p.File.ID, p.Completed, p.Total, p.Err)
+
+			return
+		}
+
+		kind := "file"
+		if p.File.Dir {
+			kind = "directory"
+		}
+
+		fmt.Printf("File %s: reconstructed %s %s [%d/%d]\n", p.File.ID, kind, p.File.Path, p.Completed, p.Total)
+	})
+
+	stopReconstruct := timePhase("reconstruct")
+	batchResult, err := qrft.BatchToFilesCtx(runCtx, dir, outDir)
+	stopReconstruct()
+
+	if err != nil {
+		return fmt.Errorf("failed to reconstruct files: %w", err)
+	}
+
+	outputs := make([]outputResult, 0, len(batchResult.Files))
+	for _, f := range batchResult.Files {
+		outputs = append(outputs, newOutputResult(f.Path))
+	}
+
+	setResult("outputs", outputs)
+
+	incomplete := make(map[string]chunksResult)
+
+	ids, _ := qrfiletransfer.BatchFileIDs(dir)
+	for _, id := range ids {
+		if report, ok := batchResult.Incomplete[id]; ok {
+			fmt.Printf("File %s: incomplete, chunks: %s\n", id, report)
+			incomplete[id] = newChunksResult(report)
+		}
+	}
+
+	if len(incomplete) > 0 {
+		setResult("incomplete", incomplete)
+	}
+
+	if len(batchResult.Incomplete) > 0 {
+		return exitErrorf(exitIncomplete, "%d files of the batch are incomplete", len(batchResult.Incomplete))
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/cobra"
+)
+
+// benchSampleName is the name of the sample file of the bench command
+const benchSampleName = "sample.bin"
+
+var (
+	benchSize      int
+	benchVersions  []int
+	benchRecovery  []string
+	benchFPS       []int
+	benchRoundTrip bool
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Report the throughput of QR code versions, recovery levels, and frame rates",
+	Long: `Split a random sample file with every combination of the highest QR code
+version, recovery level, and frame rate given, and report the bytes per second a
+video of its QR codes transfers, to pick the parameters of a camera and screen.
+
+Example:
+  qrfiletransfer bench --qr-versions 10,20,40 --recovery low,medium --fps 5,10
+
+The QR codes are counted as by the estimate command, without drawing them. With
+--round-trip, they are also written and decoded back into the file, and the
+throughput is limited by the frames decoded per second, or 0 if the file cannot
+be reconstructed. Dense QR codes that a camera cannot read at a distance still
+need a real transfer to be ruled out.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if benchSize <= 0 {
+			return exitErrorf(exitUsage, "--size must be positive")
+		}
+
+		for _, v := range benchVersions {
+			if v < 1 || v > 40 {
+				return exitErrorf(exitUsage, "invalid QR code version %d (expected 1-40)", v)
+			}
+		}
+
+		for _, fps := range benchFPS {
+			if fps <= 0 {
+				return exitErrorf(exitUsage, "frames per second must be positive")
+			}
+		}
+
+		levels := make([]qrcode.RecoveryLevel, len(benchRecovery))
+		for i, name := range benchRecovery {
+			level := slices.Index(recoveryLevels, name)
+			if level < 0 {
+				return exitErrorf(exitUsage, "unknown recovery level '%s' (expected low, medium, high, or highest)", name)
+			}
+
+			levels[i] = qrcode.RecoveryLevel(level)
+		}
+
+		dir, err := os.MkdirTemp("", "qrcode_bench_*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+
+		releaseTemp := trackTemp(dir)
+
+		defer func() {
+			_ = releaseTemp()
+		}()
+
+		samplePath := filepath.Join(dir, benchSampleName)
+
+		sample := make([]byte, benchSize)
+		if _, err := rand.Read(sample); err != nil {
+			return fmt.Errorf("failed to generate sample data: %w", err)
+		}
+
+		if err := os.WriteFile(samplePath, sample, 0644); err != nil {
+			return fmt.Errorf("failed to write sample file: %w", err)
+		}
+
+		var results []benchResult
+
+		for _, version := range benchVersions {
+			for i, level := range levels {
+				qrft := newQRFileTransfer()
+				qrft.SetTargetQRVersion(version)
+				qrft.SetRecoveryLevel(level)
+
+				estimate, err := qrft.EstimateFile(samplePath)
+				if err != nil {
+					return fmt.Errorf("failed to estimate QR codes: %w", err)
+				}
+
+				codes := len(estimate.Codes)
+
+				// Without a round trip, every frame shown is taken to be read
+				decodedFPS, complete := 0.0, true
+
+				if benchRoundTrip {
+					fmt.Printf("Decoding the %d QR codes of version %d and %s recovery\n", codes, version, benchRecovery[i])
+
+					sessionDir := filepath.Join(dir, fmt.Sprintf("v%d-%s", version, benchRecovery[i]))
+					if decodedFPS, complete, err = runBenchRoundTrip(qrft, samplePath, sessionDir); err != nil {
+						return err
+					}
+				}
+
+				for _, fps := range benchFPS {
+					r := benchResult{QRVersion: version, Recovery: benchRecovery[i], FPS: fps, QRCodes: codes}
+
+					if complete && codes > 0 {
+						rate := float64(fps)
+						if benchRoundTrip {
+							rate = min(rate, decodedFPS)
+						}
+
+						r.BytesPerSecond = float64(benchSize) * rate / float64(codes)
+					}
+
+					if benchRoundTrip {
+						r.DecodedFPS = decodedFPS
+						r.Complete = &complete
+					}
+
+					results = append(results, r)
+				}
+			}
+		}
+
+		setResult("size", benchSize)
+		setResult("bench", results)
+
+		printBenchResults(results)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+
+	// Add flags
+	benchCmd.Flags().IntVar(&benchSize, "size", 16384, "Size in bytes of the sample file")
+	benchCmd.Flags().IntSliceVar(&benchVersions, "qr-versions", []int{10, 20, 30, 40},
+		"Highest QR code versions to compare, 1 to 40")
+	benchCmd.Flags().StringSliceVarP(&benchRecovery, "recovery", "r", []string{"low", "medium", "high"},
+		"QR code recovery levels to compare (low, medium, high, highest)")
+	benchCmd.Flags().IntSliceVar(&benchFPS, "fps", []int{5, 10, 15, 30}, "Frames per second to compare")
+	benchCmd.Flags().BoolVar(&benchRoundTrip, "round-trip", false,
+		"Write and decode the QR codes of every combination, limiting the throughput to the frames decoded per second")
+
+	_ = benchCmd.RegisterFlagCompletionFunc("recovery", completeValues(recoveryLevels...))
+}
+
+// printBenchResults prints the results of the bench command as a table, followed
+// by the fastest combination
+func printBenchResults(results []benchResult) {
+	fmt.Printf("%-10s %-9s %8s %5s %12s", "QR version", "Recovery", "QR codes", "FPS", "Bytes/s")
+	if benchRoundTrip {
+		fmt.Printf(" %11s", "Decoded FPS")
+	}
+	fmt.Println()
+
+	var best *benchResult
+
+	for i, r := range results {
+		fmt.Printf("%-10d %-9s %8d %5d %12.0f", r.QRVersion, r.Recovery, r.QRCodes, r.FPS, r.BytesPerSecond)
+		if r.Complete != nil {
+			if *r.Complete {
+				fmt.Printf(" %11.1f", r.DecodedFPS)
+			} else {
+				fmt.Printf(" %11s", "incomplete")
+			}
+		}
+		fmt.Println()
+
+		if r.BytesPerSecond > 0 && (best == nil || r.BytesPerSecond > best.BytesPerSecond) {
+			best = &results[i]
+		}
+	}
+
+	if best != nil {
+		fmt.Printf("Fastest: --qr-version %d --recovery %s at %d fps, %.0f bytes per second\n",
+			best.QRVersion, best.Recovery, best.FPS, best.BytesPerSecond)
+	}
+}
@@ -0,0 +1,105 @@
+//go:build !nodecode
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/video"
+)
+
+// runBenchRoundTrip splits the sample file at samplePath into the QR codes of qrft
+// in sessionDir, decodes them back, and returns the frames decoded per second and
+// whether the reconstructed file matches the sample. The images are loaded before
+// the decoding is timed, as a camera hands over frames already decoded.
+func runBenchRoundTrip(qrft *qrfiletransfer.QRFileTransfer, samplePath, sessionDir string) (float64, bool, error) {
+	if err := qrft.FileToQRCodesCtx(runCtx, samplePath, sessionDir); err != nil {
+		return 0, false, fmt.Errorf("failed to split file: %w", err)
+	}
+
+	paths, _, err := playbackFrames(sessionDir)
+	if err != nil {
+		return 0, false, err
+	}
+
+	images := make([]image.Image, 0, len(paths))
+
+	for _, path := range paths {
+		img, err := loadBenchFrame(path)
+		if err != nil {
+			return 0, false, err
+		}
+
+		images = append(images, img)
+	}
+
+	stream := func(fn func(img image.Image) error) (*video.Info, error) {
+		for _, img := range images {
+			if err := fn(img); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, nil
+	}
+
+	decodedDir := sessionDir + "-decoded"
+	start := time.Now()
+
+	if err := readQRCodesFromStream(stream, decodedDir, 0, nil); err != nil {
+		return 0, false, fmt.Errorf("failed to read QR codes: %w", err)
+	}
+
+	decodedFPS := float64(len(images)) / max(time.Since(start).Seconds(), 1e-9)
+
+	report, err := qrft.VerifyChunks(decodedDir)
+	if err != nil {
+		return decodedFPS, false, fmt.Errorf("failed to verify chunks: %w", err)
+	}
+
+	if !report.Complete() {
+		return decodedFPS, false, nil
+	}
+
+	receivedPath := filepath.Join(decodedDir, filepath.Base(samplePath))
+	if err := qrft.QRCodesToFileCtx(runCtx, decodedDir, receivedPath); err != nil {
+		return decodedFPS, false, fmt.Errorf("failed to reconstruct file: %w", err)
+	}
+
+	sample, err := os.ReadFile(samplePath)
+	if err != nil {
+		return decodedFPS, false, fmt.Errorf("failed to read sample file: %w", err)
+	}
+
+	received, err := os.ReadFile(receivedPath)
+	if err != nil {
+		return decodedFPS, false, fmt.Errorf("failed to read reconstructed file: %w", err)
+	}
+
+	return decodedFPS, bytes.Equal(sample, received), nil
+}
+
+// loadBenchFrame decodes the image at path
+func loadBenchFrame(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frame: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame %s: %w", path, err)
+	}
+
+	return img, nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/cleanup"
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
+	"github.com/spf13/afero"
+)
+
+var (
+	// tempFiles tracks the temporary files and directories of the running command,
+	// which are removed when it ends, fails, is interrupted, or panics
+	tempFiles = cleanup.NewManager()
+
+	// work creates the intermediate directories of the running command and keeps
+	// those selected by --keep, tracking the others in tempFiles
+	work = workspace.New(tempFiles, workspace.KeepNone)
+
+	// keepRetention selects the intermediate files kept when the command ends, set
+	// by the --keep flag
+	keepRetention string
+
+	// keepTemp keeps every intermediate file, set by the deprecated --keep-temp flag
+	keepTemp bool
+
+	// interruptHandled is set by the commands that stop cleanly on the first
+	// interrupt themselves, such as scan and serve
+	interruptHandled atomic.Bool
+
+	// runCtx is the context of the running command, canceled when it is interrupted
+	// or terminated: the operations given it stop, and the subprocesses started with
+	// it, such as ffmpeg, are killed rather than left running
+	runCtx, cancelRun = context.WithCancel(context.Background())
+
+	// tempFilesRemoved makes sure the temporary files are removed once, the command
+	// may end while it is being interrupted
+	tempFilesRemoved sync.Once
+)
+
+// osFs is the operating system's file system the temporary paths are created on
+var osFs = afero.NewOsFs()
+
+// trackTemp registers path, a temporary file or directory, to be removed when the
+// command ends unless --keep all, and returns the function removing it once it is
+// no longer needed
+func trackTemp(path string) func() error {
+	return work.Track(osFs, workspace.Scratch, path)
+}
+
+// setRetention sets the retention of the workspace from --keep, or to keep
+// everything with --keep-temp
+func setRetention() error {
+	retention, err := workspace.ParseRetention(keepRetention)
+	if err != nil {
+		return err
+	}
+
+	if keepTemp {
+		retention = workspace.KeepAll
+	}
+
+	work.SetRetention(retention)
+
+	return nil
+}
+
+// interruptGrace is how long an interrupted command is given to stop its
+// operations and subprocesses before it exits anyway
+const interruptGrace = 3 * time.Second
+
+// watchInterrupts cancels runCtx when the command is interrupted or terminated, and
+// exits once the command stopped or after interruptGrace, removing the temporary
+// files. Another interrupt exits right away. A command that handles the interrupt
+// itself is only stopped by a second one.
+func watchInterrupts() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		handled := false
+
+		for sig := range signals {
+			if sig == os.Interrupt && interruptHandled.Load() && !handled {
+				handled = true
+
+				fmt.Println("\nStopping, press Ctrl+C again to exit immediately")
+
+				continue
+			}
+
+			if runCtx.Err() != nil {
+				exit(exitInterrupted)
+			}
+
+			fmt.Println("\nInterrupted")
+			cancelRun()
+
+			time.AfterFunc(interruptGrace, func() {
+				exit(exitInterrupted)
+			})
+		}
+	}()
+}
+
+// removeTempFiles removes the temporary files still tracked, and lists those kept
+// by --keep
+func removeTempFiles() {
+	tempFilesRemoved.Do(func() {
+		if err := tempFiles.Cleanup(); err != nil {
+			diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary files: %v", err)
+		}
+
+		if kept := work.Kept(); len(kept) > 0 {
+			fmt.Printf("Kept intermediate files (--keep %s): %s\n", work.Retention(), strings.Join(kept, ", "))
+		}
+	})
+}
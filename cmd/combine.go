@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	combineInputDirs []string
+	combineOutputDir string
+	combineManifest  string
+)
+
+var combineCmd = &cobra.Command{
+	Use:   "combine",
+	Short: "Combine the chunks captured by several receivers",
+	Long: `Combine the chunks captured independently by several devices or people into
+one directory, and report whether the union is complete.
+
+Example:
+  qrfiletransfer combine -i capA -i capB -o merged
+
+The inputs are directories written by read or scan, such as their --state
+directories, or session directories. Chunks are validated against the manifest
+given with --manifest, or found in the output or input directories, so a chunk
+misread by one receiver does not spoil the union. Once complete, the file can be
+reconstructed with:
+  qrfiletransfer join -i merged -o reconstructed_file`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if len(combineInputDirs) == 0 || combineOutputDir == "" {
+			return usageErrorf("input and output directories are required")
+		}
+
+		for _, dir := range combineInputDirs {
+			if _, err := os.Stat(dir); os.IsNotExist(err) {
+				return exitErrorf(exitUsage, "input directory '%s' does not exist", dir)
+			}
+		}
+
+		var manifest *qrfiletransfer.Manifest
+		if combineManifest != "" {
+			var err error
+			if manifest, err = qrfiletransfer.LoadManifest(combineManifest); err != nil {
+				return err
+			}
+		}
+
+		qrft := newQRFileTransfer()
+
+		result, err := qrft.CombineChunks(combineOutputDir, manifest, combineInputDirs...)
+		if err != nil {
+			return fmt.Errorf("failed to combine chunks: %w", err)
+		}
+
+		for i, dir := range combineInputDirs {
+			fmt.Printf("%s: %d new chunks\n", dir, result.Added[i])
+		}
+
+		if len(result.Rejected) > 0 {
+			diag.Warnf(diagnostics.CodeQuarantinedChunk, qrfiletransfer.FormatIndexRanges(result.Rejected),
+				"chunks did not match the manifest, kept in '%s' for analysis", filepath.Join(combineOutputDir, qrfiletransfer.QuarantineDirName))
+		}
+
+		if len(result.Conflicts) > 0 {
+			diag.Warnf(diagnostics.CodeChunkConflict, qrfiletransfer.FormatIndexRanges(result.Conflicts),
+				"chunks differ between inputs, kept the copy of the first input")
+		}
+
+		fmt.Printf("Chunks: %s\n", result.Report)
+
+		if !result.Report.Complete() {
+			return exitErrorf(exitIncomplete, "the combined chunks in '%s' are incomplete", combineOutputDir)
+		}
+
+		fmt.Printf("The combined chunks in '%s' are complete, reconstruct the file with: qrfiletransfer join -i %s\n", combineOutputDir, combineOutputDir)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(combineCmd)
+
+	// Add flags
+	combineCmd.Flags().StringArrayVarP(&combineInputDirs, "input", "i", nil,
+		"Input directory with captured chunks, repeat for every receiver (required)")
+	combineCmd.Flags().StringVarP(&combineOutputDir, "output", "o", "",
+		"Output directory receiving the union of the chunks (required)")
+	combineCmd.Flags().StringVarP(&combineManifest, "manifest", "m", "",
+		"Directory holding the manifest.json of the sender to validate chunks against")
+
+	markDirFlags(combineCmd, "input", "output", "manifest")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+// The completion command generated by cobra writes the completion scripts of
+// bash, zsh, fish, and powershell. The flags taking a path complete file names by
+// default, those taking a directory or one of a few names register their
+// completion where they are defined.
+
+// recoveryLevels are the names of the --recovery levels
+var recoveryLevels = []string{"low", "medium", "high", "highest"}
+
+// enhancementSteps are the --enhance steps of read and scan
+var enhancementSteps = []string{
+	imaging.EnhanceGrayscale, imaging.EnhancePerspective, imaging.EnhanceSharpen,
+	imaging.EnhanceAdaptive, imaging.EnhanceMultiScale, "all",
+}
+
+// completeValues completes a flag taking one of values, without file names
+func completeValues(values ...string) cobra.CompletionFunc {
+	return cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)
+}
+
+// completeList completes a flag taking a comma-separated list of values, the
+// value after the last comma
+func completeList(values ...string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		prefix := toComplete[:strings.LastIndex(toComplete, ",")+1]
+
+		completions := make([]cobra.Completion, 0, len(values))
+		for _, value := range values {
+			completions = append(completions, prefix+value)
+		}
+
+		return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+}
+
+// completeProfiles completes a flag taking a profile:NAME of the built-in profiles
+func completeProfiles() cobra.CompletionFunc {
+	names := qrfiletransfer.ProfileNames()
+	for i, name := range names {
+		names[i] = "profile:" + name
+	}
+
+	return completeValues(names...)
+}
+
+// markDirFlags makes the flags of cmd named names complete directory names only
+func markDirFlags(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		_ = cmd.MarkFlagDirname(name)
+	}
+}
@@ -0,0 +1,261 @@
+//go:build !nodecode
+
+package cmd
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrdecode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+var (
+	// lensProfile corrects frames before decoding, set by the --lens flag
+	lensProfile *imaging.LensProfile
+
+	// enhancement is applied to frames before decoding, set by the --enhance flag
+	enhancement imaging.Enhancement
+
+	// sweepBudget bounds the time spent retrying a frame that failed to decode
+	// through the preprocessing variants, set by the --frame-budget flag
+	sweepBudget time.Duration
+
+	// orientationVariants turn and mirror a frame that failed to decode upright
+	orientationVariants = imaging.OrientationVariants()
+
+	// frameOrientation is 1 + the index of the orientation variant that decoded the
+	// last frame, 0 for upright frames. The frames of a recording stored sideways
+	// are all turned the same way, so that variant is tried first.
+	frameOrientation atomic.Int32
+
+	// qrDecoder reads the QR codes of the frames
+	qrDecoder qrdecode.Decoder = qrdecode.NewGozxingDecoder()
+)
+
+// loadLensProfile loads the lens profile used by preprocessFrame, if path is set
+func loadLensProfile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	profile, err := imaging.LoadLensProfile(path)
+	if err != nil {
+		return err
+	}
+
+	lensProfile = profile
+
+	return nil
+}
+
+// loadEnhancement sets the enhancement used by preprocessFrame and decodeFrame
+// from a list of steps, see imaging.ParseEnhancement
+func loadEnhancement(spec string) error {
+	e, err := imaging.ParseEnhancement(spec)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	enhancement = e
+
+	return nil
+}
+
+// preprocessFrame applies the corrections requested on the command line to a frame
+func preprocessFrame(img image.Image) image.Image {
+	if lensProfile != nil {
+		img = lensProfile.Apply(img)
+	}
+
+	if !enhancement.IsZero() {
+		img = enhancement.Apply(img)
+	}
+
+	return img
+}
+
+// readPayloadsFromGroup decodes the chunk payloads of the first frame of a group
+// that holds a readable QR code, every code of a frame tiling several, and returns
+// them with the path of that frame. QR codes of the frame that do not hold a chunk
+// payload are reported as diagnostics.
+func readPayloadsFromGroup(group []string) ([]*qrfiletransfer.ChunkPayload, string, error) {
+	return firstPayloads(group, readQRCodesFromImage)
+}
+
+// firstPayloads is readPayloadsFromGroup for the frames named in group, whose QR
+// codes read returns
+func firstPayloads(group []string, read func(name string) ([][]byte, error)) ([]*qrfiletransfer.ChunkPayload, string, error) {
+	var lastErr error
+
+	for _, framePath := range group {
+		// Read the QR codes from the frame
+		contents, err := read(framePath)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to read QR code from frame %s: %w", framePath, err)
+
+			continue
+		}
+
+		payloads := parsePayloads(contents)
+		if len(payloads) == 0 {
+			lastErr = fmt.Errorf("failed to parse QR code payload from frame %s", framePath)
+
+			continue
+		}
+
+		if len(payloads) < len(contents) {
+			diag.Warnf(diagnostics.CodeInvalidChunk, framePath, "%d of %d QR codes hold no chunk payload", len(contents)-len(payloads), len(contents))
+		}
+
+		return payloads, framePath, nil
+	}
+
+	return nil, "", lastErr
+}
+
+// parsePayloads returns the chunk payloads of the QR code contents of a frame,
+// either binary or legacy text format, ordered by file and chunk index so that the
+// codes tiled in a frame are seen in playback order. Contents that hold no chunk
+// payload are left out.
+func parsePayloads(contents [][]byte) []*qrfiletransfer.ChunkPayload {
+	var payloads []*qrfiletransfer.ChunkPayload
+
+	for _, content := range contents {
+		if payload, err := qrfiletransfer.DecodePayload(content); err == nil {
+			payloads = append(payloads, payload)
+		}
+	}
+
+	sort.SliceStable(payloads, func(i, j int) bool {
+		if payloads[i].File != payloads[j].File {
+			return payloads[i].File < payloads[j].File
+		}
+
+		a, _ := payloads[i].Index()
+		b, _ := payloads[j].Index()
+
+		return a < b
+	})
+
+	return payloads
+}
+
+// readQRCodeFromImage reads a QR code from an image file and returns its raw
+// content, the first one found if it shows several
+func readQRCodeFromImage(imagePath string) ([]byte, error) {
+	contents, err := readQRCodesFromImage(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return contents[0], nil
+}
+
+// readQRCodesFromImage reads the QR codes of an image file and returns their raw
+// contents
+func readQRCodesFromImage(imagePath string) ([][]byte, error) {
+	// Open the image file
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer func(file *os.File) {
+		if err := file.Close(); err != nil {
+			diag.Warnf(diagnostics.CodeCleanupFailed, imagePath, "failed to close image file: %v", err)
+		}
+	}(file)
+
+	// Decode the image
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return decodeFrame(img)
+}
+
+// decodeFrame preprocesses a frame and reads its QR codes, a frame may tile
+// several. A frame that fails to decode is retried at the scales of the
+// enhancement, then rotated and mirrored, then through the preprocessing variants
+// of imaging.SweepVariants until one decodes or sweepBudget is used up.
+func decodeFrame(img image.Image) ([][]byte, error) {
+	img = preprocessFrame(img)
+
+	last := int(frameOrientation.Load())
+	if last > 0 {
+		if contents, err := decodeQRCodes(orientationVariants[last-1].Apply(img)); err == nil {
+			return contents, nil
+		}
+	}
+
+	contents, err := decodeQRCodes(img)
+	if err == nil {
+		frameOrientation.Store(0)
+
+		return contents, nil
+	}
+
+	for _, factor := range enhancement.Scales() {
+		if contents, scaledErr := decodeQRCodes(imaging.Scale(img, factor)); scaledErr == nil {
+			return contents, nil
+		}
+	}
+
+	for i, variant := range orientationVariants {
+		if i+1 == last {
+			continue
+		}
+
+		if contents, turnedErr := decodeQRCodes(variant.Apply(img)); turnedErr == nil {
+			frameOrientation.Store(int32(i + 1))
+
+			return contents, nil
+		}
+	}
+
+	if sweepBudget <= 0 {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(sweepBudget)
+
+	for _, variant := range imaging.SweepVariants() {
+		if time.Now().After(deadline) {
+			break
+		}
+
+		if contents, variantErr := decodeQRCodes(variant.Apply(img)); variantErr == nil {
+			return contents, nil
+		}
+	}
+
+	return nil, err
+}
+
+// decodeQRCodes reads the QR codes of an image and returns their raw contents, the
+// frame of ProtocolStructuredAppend for those with a structured append header
+func decodeQRCodes(img image.Image) ([][]byte, error) {
+	symbols, err := qrdecode.DecodeSymbols(qrDecoder, img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode QR code: %w", err)
+	}
+
+	contents := make([][]byte, len(symbols))
+	for i, s := range symbols {
+		contents[i] = s.Content
+		if s.StructuredAppend != nil {
+			contents[i] = qrfiletransfer.StructuredAppendFrame(*s.StructuredAppend, s.Content)
+		}
+	}
+
+	return contents, nil
+}
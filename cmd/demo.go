@@ -0,0 +1,260 @@
+//go:build !nodecode
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/video"
+	"github.com/spf13/cobra"
+)
+
+// Names of the files of a demo directory
+const (
+	demoSampleName   = "sample.bin"
+	demoSessionName  = "session"
+	demoVideoName    = "demo.mp4"
+	demoFramesName   = "frames"
+	demoReceivedName = "received.bin"
+)
+
+// demoFPS is the frame rate of the demo video
+const demoFPS = 2
+
+var (
+	demoDir  string
+	demoSize int
+)
+
+var demoCmd = &cobra.Command{
+	Use:     "demo",
+	Aliases: []string{"examples"},
+	Short:   "Run an encode, video, and decode round trip of a sample file",
+	Long: `Generate a small sample file, split it into QR codes, turn them into a video,
+read the video back, and verify the reconstructed file, narrating every step.
+This checks that the environment can run a whole transfer.
+
+Example:
+  qrfiletransfer demo
+
+The round trip runs in a temporary directory, or in --dir which is kept. The two
+halves also run on their own, e.g. to play the video on one machine and record
+it on another:
+  qrfiletransfer demo send --dir demo
+  qrfiletransfer demo receive --dir demo
+
+Without ffmpeg, the QR codes are written as an image sequence instead of an MP4
+video, which read takes as a video too.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := demoDir
+		if dir == "" {
+			tempDir, err := os.MkdirTemp("", "qrcode_demo_*")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary directory: %w", err)
+			}
+
+			trackTemp(tempDir)
+			dir = tempDir
+		}
+
+		if err := runDemoSend(dir); err != nil {
+			return err
+		}
+
+		fmt.Println()
+
+		return runDemoReceive(dir)
+	},
+}
+
+var demoSendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Generate a sample file and a video of its QR codes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDemoSend(demoDirOrDefault())
+	},
+}
+
+var demoReceiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "Read the demo video back and verify the sample file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDemoReceive(demoDirOrDefault())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(demoCmd)
+	demoCmd.AddCommand(demoSendCmd, demoReceiveCmd)
+
+	// Add flags
+	demoCmd.PersistentFlags().StringVar(&demoDir, "dir", "",
+		"Directory of the demo files (default: a temporary directory, qrfiletransfer-demo for send and receive)")
+	demoSendCmd.Flags().IntVar(&demoSize, "size", 4096, "Size in bytes of the sample file")
+	demoCmd.Flags().IntVar(&demoSize, "size", 4096, "Size in bytes of the sample file")
+
+	_ = demoCmd.MarkPersistentFlagDirname("dir")
+}
+
+// demoDirOrDefault returns the --dir directory, shared by send and receive
+func demoDirOrDefault() string {
+	if demoDir == "" {
+		return "qrfiletransfer-demo"
+	}
+
+	return demoDir
+}
+
+// runDemoSend writes a sample file into dir, splits it into QR codes, and plays
+// them into a video, or an image sequence without ffmpeg
+func runDemoSend(dir string) error {
+	if demoSize <= 0 {
+		return exitErrorf(exitUsage, "--size must be positive")
+	}
+
+	// Start over, the files of a previous demo would be mixed with the new ones
+	for _, name := range []string{demoSessionName, demoVideoName, demoFramesName, demoReceivedName} {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to clear the previous demo: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create demo directory: %w", err)
+	}
+
+	samplePath := filepath.Join(dir, demoSampleName)
+	fmt.Printf("[send 1/3] Writing a sample file of %d random bytes: %s\n", demoSize, samplePath)
+
+	sample := make([]byte, demoSize)
+	if _, err := rand.Read(sample); err != nil {
+		return fmt.Errorf("failed to generate sample data: %w", err)
+	}
+
+	if err := os.WriteFile(samplePath, sample, 0644); err != nil {
+		return fmt.Errorf("failed to write sample file: %w", err)
+	}
+
+	sessionDir := filepath.Join(dir, demoSessionName)
+	fmt.Printf("[send 2/3] Splitting it into QR codes: %s\n", sessionDir)
+
+	if err := newQRFileTransfer().FileToQRCodesCtx(runCtx, samplePath, sessionDir); err != nil {
+		return fmt.Errorf("failed to split file: %w", err)
+	}
+
+	frames, _, err := playbackFrames(sessionDir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("           The sample fits in %d QR codes\n", len(frames))
+
+	if ffmpegErr := checkFFmpegInstalled(); ffmpegErr != nil {
+		framesDir := filepath.Join(dir, demoFramesName)
+		fmt.Printf("[send 3/3] %v\n", ffmpegErr)
+		fmt.Printf("           Writing the QR codes as an image sequence instead, which read takes as a video: %s\n", framesDir)
+
+		if err := qrfiletransfer.WriteFrameSequence(frames, framesDir); err != nil {
+			return fmt.Errorf("failed to write frame sequence: %w", err)
+		}
+
+		return nil
+	}
+
+	videoPath := filepath.Join(dir, demoVideoName)
+	fmt.Printf("[send 3/3] Generating a %dfps video of the QR codes: %s\n", demoFPS, videoPath)
+
+	if err := generateQRCodeVideo(frames, videoPath, videoOptions{FPS: demoFPS, CRF: -1}); err != nil {
+		return fmt.Errorf("failed to generate video: %w", err)
+	}
+
+	return nil
+}
+
+// runDemoReceive reads the video written by runDemoSend into dir back, and checks
+// that the reconstructed file matches the sample
+func runDemoReceive(dir string) error {
+	input := filepath.Join(dir, demoVideoName)
+	if _, err := os.Stat(input); err != nil {
+		input = filepath.Join(dir, demoFramesName)
+	}
+
+	if _, err := os.Stat(input); err != nil {
+		return exitErrorf(exitUsage, "%s holds no demo video, run 'qrfiletransfer demo send --dir %s' first", dir, dir)
+	}
+
+	workDir, err := os.MkdirTemp("", "qrcode_demo_receive_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	releaseTemp := trackTemp(workDir)
+
+	defer func() {
+		_ = releaseTemp()
+	}()
+
+	backend, err := frameBackend(input)
+	if err != nil {
+		return err
+	}
+
+	sessionDir := filepath.Join(workDir, "session")
+	fmt.Printf("[receive 1/2] Decoding the QR codes of the frames of %s\n", input)
+
+	stream := func(fn func(img image.Image) error) (*video.Info, error) {
+		return streamFrames(input, backend, video.Sampling{}, fn)
+	}
+
+	if err := readQRCodesFromStream(stream, sessionDir, 0, nil); err != nil {
+		return fmt.Errorf("failed to read QR codes: %w", err)
+	}
+
+	qrft := newQRFileTransfer()
+
+	report, err := qrft.VerifyChunks(sessionDir)
+	if err != nil {
+		return fmt.Errorf("failed to verify chunks: %w", err)
+	}
+
+	fmt.Printf("              Chunks: %s\n", report)
+
+	if !report.Complete() {
+		return exitErrorf(exitIncomplete, "demo failed: not every QR code of the video could be read")
+	}
+
+	receivedPath := filepath.Join(dir, demoReceivedName)
+	fmt.Printf("[receive 2/2] Reconstructing the file and comparing it with the sample: %s\n", receivedPath)
+
+	if err := qrft.QRCodesToFileCtx(runCtx, sessionDir, receivedPath); err != nil {
+		return fmt.Errorf("failed to reconstruct file: %w", err)
+	}
+
+	sample, err := os.ReadFile(filepath.Join(dir, demoSampleName))
+	if err != nil {
+		return fmt.Errorf("failed to read sample file: %w", err)
+	}
+
+	received, err := os.ReadFile(receivedPath)
+	if err != nil {
+		return fmt.Errorf("failed to read reconstructed file: %w", err)
+	}
+
+	if !bytes.Equal(sample, received) {
+		return exitErrorf(exitHashMismatch, "demo failed: %s differs from %s", demoReceivedName, demoSampleName)
+	}
+
+	fmt.Printf("Demo passed: %s matches %s (%d bytes, sha256 %x)\n", demoReceivedName, demoSampleName, len(received), sha256.Sum256(received))
+
+	return nil
+}
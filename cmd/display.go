@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/serve"
+	"github.com/spf13/cobra"
+)
+
+// appBrowsers are the browsers display opens a window without browser controls
+// with, in order of preference
+var appBrowsers = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "microsoft-edge", "brave-browser",
+	"chrome", "msedge",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+}
+
+var (
+	displayInput     string
+	displayOutputDir string
+	displayFPS       float64
+	displayLoop      bool
+)
+
+var displayCmd = &cobra.Command{
+	Use:   "display",
+	Short: "Show QR codes as a fullscreen slideshow in a browser window",
+	Long: `Open a window showing the QR codes of a file as an auto-cycling fullscreen
+slideshow, to point the camera of the receiving device at the screen without
+generating a video file.
+
+Example:
+  qrfiletransfer display -i myfile.txt
+
+The input is either a file, which is encoded into QR codes first, or a session
+directory created by split.
+
+The window is not a native one: toolkits such as gio or fyne need cgo and the
+graphics libraries of every platform, which the single static binary avoids.
+Instead, the slideshow is the page of serve, served on a local port only, and
+opened as a fullscreen app window of Chromium, Chrome, Edge, or Brave, without
+tabs or an address bar, under a temporary profile removed afterwards. With none
+of these browsers it is opened in a tab of the default browser, and the page
+address is printed. Closing the window stops the command, as does Ctrl+C. Keys:
+  left, right  step through the QR codes
+  space        pause
+  + -          double or halve the frame rate
+  r            restart from the first QR code
+  s            toggle the status QR code
+  f            toggle fullscreen`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if displayInput == "" {
+			return usageErrorf("input file or session directory is required")
+		}
+
+		show, err := newSlideshow(displayInput, displayOutputDir, displayFPS, displayLoop)
+		if err != nil {
+			return err
+		}
+
+		defer show.release()
+
+		return displaySlideshow(show, nil)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(displayCmd)
+
+	// Add flags
+	displayCmd.Flags().StringVarP(&displayInput, "input", "i", "",
+		"Input file or session directory (required)")
+	displayCmd.Flags().StringVarP(&displayOutputDir, "output", "o", "",
+		"Output directory for the QR codes of an input file (default: temporary directory)")
+	displayCmd.Flags().Float64Var(&displayFPS, "fps", serve.DefaultFPS,
+		"Frames per second of the slideshow")
+	displayCmd.Flags().BoolVar(&displayLoop, "loop", true,
+		"Restart the slideshow after the last frame")
+
+	markDirFlags(displayCmd, "output")
+}
+
+// displaySlideshow presents show in a window until the window is closed or Ctrl+C
+// is pressed. If watch is set, it runs alongside until it returns, which stops
+// the slideshow too, returning its error.
+func displaySlideshow(show *slideshow, watch func(ctx context.Context) error) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+
+	httpServer := &http.Server{
+		Handler:           show.server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shut down on Ctrl+C, when the window is closed, or when watch returns
+	ctx, stop := signal.NotifyContext(runCtx, os.Interrupt)
+	defer stop()
+
+	interruptHandled.Store(true)
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	url := "http://" + listener.Addr().String() + "/"
+
+	waitWindow, err := openWindow(ctx, url)
+	if err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, "", "failed to open a window: %v", err)
+	}
+
+	windowClosed := make(chan struct{})
+
+	if waitWindow != nil {
+		go func() {
+			defer close(windowClosed)
+
+			waitWindow()
+			stop()
+		}()
+
+		fmt.Printf("Showing %d QR codes on %s (close the window or press Ctrl+C to stop)\n", len(show.frames), url)
+	} else {
+		close(windowClosed)
+		fmt.Printf("Showing %d QR codes on %s, open it in a browser (press Ctrl+C to stop)\n", len(show.frames), url)
+	}
+
+	var watchErr error
+
+	watched := make(chan struct{})
+
+	if watch != nil {
+		go func() {
+			defer close(watched)
+
+			watchErr = watch(ctx)
+			stop()
+		}()
+	} else {
+		close(watched)
+	}
+
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve QR codes: %w", err)
+	}
+
+	// Wait for the window to close and its profile to be removed
+	<-windowClosed
+	<-watched
+
+	return watchErr
+}
+
+// openWindow opens url in a fullscreen window without browser controls, and
+// returns a function waiting for the window to be closed, which is closed when
+// ctx is done. If no such browser is found, url is opened in the default browser
+// and nil is returned.
+func openWindow(ctx context.Context, url string) (func(), error) {
+	for _, name := range appBrowsers {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+
+		// A profile of its own starts a new browser process rather than opening
+		// the window in a running one, so the process lives as long as the window
+		profile, err := os.MkdirTemp("", "qrcode_display_*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create browser profile: %w", err)
+		}
+
+		releaseProfile := trackTemp(profile)
+
+		browser := exec.CommandContext(ctx, path, "--app="+url, "--start-fullscreen", "--user-data-dir="+profile,
+			"--no-first-run", "--no-default-browser-check")
+		if err := browser.Start(); err != nil {
+			_ = releaseProfile()
+
+			return nil, fmt.Errorf("failed to start %s: %w", name, err)
+		}
+
+		return func() {
+			_ = browser.Wait()
+
+			if err := releaseProfile(); err != nil {
+				diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove browser profile: %v", err)
+			}
+		}, nil
+	}
+
+	var opener *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		opener = exec.Command("open", url)
+	case "windows":
+		opener = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		opener = exec.Command("xdg-open", url)
+	}
+
+	if err := opener.Run(); err != nil {
+		return nil, fmt.Errorf("failed to open the default browser: %w", err)
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/features"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var docsManDir string
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate the documentation of the commands",
+	Long: `Generate the documentation of the commands from their help texts, for package
+managers to install along with the binary.`,
+}
+
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages of the commands",
+	Long: `Generate a man page in section 1 for every command, such as
+qrfiletransfer.1 and qrfiletransfer-split.1, into the output directory.
+
+The pages are dated with $SOURCE_DATE_EPOCH if set, so that release builds
+generate the same pages every time, and today otherwise.
+
+Example:
+  qrfiletransfer docs man -o share/man/man1`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.MkdirAll(docsManDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		header := &doc.GenManHeader{
+			Title:   "QRFILETRANSFER",
+			Section: "1",
+			Source:  "qrfiletransfer " + features.Capabilities().Version,
+			Manual:  "qrfiletransfer manual",
+		}
+
+		// Leave out the footer dating the pages again
+		rootCmd.DisableAutoGenTag = true
+
+		if err := doc.GenManTree(rootCmd, header, docsManDir); err != nil {
+			return fmt.Errorf("failed to generate man pages: %w", err)
+		}
+
+		fmt.Printf("Man pages written to '%s'\n", docsManDir)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(docsCmd)
+	docsCmd.AddCommand(docsManCmd)
+
+	// Add flags
+	docsManCmd.Flags().StringVarP(&docsManDir, "output", "o", "man", "Output directory of the man pages")
+	markDirFlags(docsManCmd, "output")
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	qrForeground string
+	qrBackground string
+	qrLogo       string
+	qrLogoSize   float64
+	quietZone    int
+	fastMask     bool
+	pngDPI       int
+	pngLevel     string
+)
+
+// namedColors maps the color names accepted by --fg and --bg to their color
+var namedColors = map[string]color.Color{
+	"black": color.Black,
+	"white": color.White,
+	"red":   color.RGBA{R: 0xff, A: 0xff},
+	"green": color.RGBA{G: 0x80, A: 0xff},
+	"blue":  color.RGBA{B: 0xff, A: 0xff},
+	"navy":  color.RGBA{B: 0x80, A: 0xff},
+	"gray":  color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+}
+
+// colorNames lists the names of namedColors for completion
+var colorNames = []string{"black", "white", "red", "green", "blue", "navy", "gray"}
+
+// pngCompressionLevels maps the names accepted by --png-compression to their
+// compression level
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"best":    png.BestCompression,
+	"default": png.DefaultCompression,
+	"speed":   png.BestSpeed,
+	"none":    png.NoCompression,
+}
+
+// addDrawingFlags adds the flags setting the colors, quiet zone, logo, and data
+// mask of the QR code images, and the compression and resolution of their PNG
+// files, to cmd
+func addDrawingFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&qrForeground, "fg", "black",
+		"Color of the QR code modules, a name or hex RGB such as #1a2b3c")
+	cmd.Flags().StringVar(&qrBackground, "bg", "white",
+		"Color of the QR code background, e.g. --fg white --bg black for dark screens, which not every scanner reads")
+	cmd.Flags().StringVar(&qrLogo, "logo", "",
+		"PNG or JPEG image drawn over the center of every QR code, raising the recovery level to recover the modules it hides")
+	cmd.Flags().Float64Var(&qrLogoSize, "logo-size", qrcode.DefaultLogoSize,
+		fmt.Sprintf("Width of the logo as a fraction of the width of the QR code, up to %g", qrcode.MaxLogoSize))
+	cmd.Flags().IntVar(&quietZone, "quiet-zone", 0,
+		"Width of the border around every QR code in modules (default: 4)")
+	cmd.Flags().BoolVar(&fastMask, "fast-mask", false,
+		"Apply a fixed data mask to every QR code rather than the best of eight, encoding large files several times faster")
+	cmd.Flags().IntVar(&pngDPI, "dpi", 0,
+		"Resolution recorded in the PNG images, so that a QR code of --size pixels prints size/dpi inches wide (default: none)")
+	cmd.Flags().StringVar(&pngLevel, "png-compression", "best",
+		"Compression of the PNG images (best, default, speed, none), speed for faster bulk generation of larger files")
+
+	_ = cmd.RegisterFlagCompletionFunc("fg", completeValues(colorNames...))
+	_ = cmd.RegisterFlagCompletionFunc("bg", completeValues(colorNames...))
+	_ = cmd.RegisterFlagCompletionFunc("png-compression", completeValues("best", "default", "speed", "none"))
+	_ = cmd.MarkFlagFilename("logo", "png", "jpg", "jpeg")
+}
+
+// configureDrawing applies the flags of addDrawingFlags to qrft
+func configureDrawing(qrft *qrfiletransfer.QRFileTransfer) error {
+	fg, err := parseColor(qrForeground)
+	if err != nil {
+		return exitErrorf(exitUsage, "invalid --fg: %v", err)
+	}
+
+	bg, err := parseColor(qrBackground)
+	if err != nil {
+		return exitErrorf(exitUsage, "invalid --bg: %v", err)
+	}
+
+	options := qrcode.Options{
+		ForegroundColor: fg,
+		BackgroundColor: bg,
+		QuietZone:       quietZone,
+		LogoSize:        qrLogoSize,
+		FastMask:        fastMask,
+	}
+
+	if qrLogo != "" {
+		if options.Logo, err = loadLogo(qrLogo); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+	}
+
+	if err := qrft.SetQROptions(options); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	level, ok := pngCompressionLevels[pngLevel]
+	if !ok {
+		return exitErrorf(exitUsage, "unknown PNG compression '%s' (expected best, default, speed, or none)", pngLevel)
+	}
+
+	if err := qrft.SetPNGOptions(qrcode.WriteFileOptions{CompressionLevel: level, DPI: pngDPI}); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	return nil
+}
+
+// parseColor returns the color named s, or written as hex RGB, #rrggbb or #rgb
+func parseColor(s string) (color.Color, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("unknown color '%s' (expected a name or hex RGB such as #1a2b3c)", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// loadLogo reads the logo image at path
+func loadLogo(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open logo: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode logo %s: %w", path, err)
+	}
+
+	return img, nil
+}
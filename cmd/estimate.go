@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/cobra"
+)
+
+var (
+	estimateInputFile string
+	estimateVideoFPS  int
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate",
+	Short: "Estimate the QR codes a file is split into, without writing them",
+	Long: `Report the QR codes the split command would generate for a file with the same
+settings, without writing anything.
+
+Example:
+  qrfiletransfer estimate -i myfile.txt -r high
+
+This will report the number of QR codes, the QR code versions used, the total
+pixel count of the images, and how long a video showing every QR code once plays
+at --fps frames per second. The file is split in memory and no image is drawn,
+so the recovery level, payload format, and sizes can be tuned quickly before
+encoding a large file.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input file
+		if estimateInputFile == "" {
+			return usageErrorf("input file is required")
+		}
+
+		stat, err := os.Stat(estimateInputFile)
+		if os.IsNotExist(err) {
+			return exitErrorf(exitUsage, "input file '%s' does not exist", estimateInputFile)
+		}
+
+		if err == nil && stat.IsDir() {
+			return exitErrorf(exitUsage, "input '%s' is a directory", estimateInputFile)
+		}
+
+		if estimateVideoFPS <= 0 {
+			return exitErrorf(exitUsage, "frames per second must be positive")
+		}
+
+		qrft := newQRFileTransfer()
+		if err := configureEncoder(qrft); err != nil {
+			return err
+		}
+
+		estimate, err := qrft.EstimateFile(estimateInputFile)
+		if err != nil {
+			return fmt.Errorf("failed to estimate QR codes: %w", err)
+		}
+
+		setResult("qr_codes", len(estimate.Codes))
+		setResult("parity", estimate.ParityCodes())
+		setResult("versions", estimate.Versions())
+
+		fmt.Printf("File:     %s (%d bytes)\n", estimate.Name, estimate.Size)
+		fmt.Printf("QR codes: %d\n", len(estimate.Codes))
+
+		if n := estimate.ParityCodes(); n > 0 {
+			fmt.Printf("Parity:   %d of the QR codes\n", n)
+		}
+
+		if len(estimate.Codes) > 0 {
+			versions := estimate.Versions()
+
+			keys := make([]int, 0, len(versions))
+			for v := range versions {
+				keys = append(keys, v)
+			}
+
+			sort.Ints(keys)
+
+			fmt.Printf("Versions:")
+			for _, v := range keys {
+				fmt.Printf(" %d (x%d)", v, versions[v])
+			}
+			fmt.Println()
+
+			// Count the recovery levels picked for the chunks
+			if autoRecovery {
+				levels := make(map[qrcode.RecoveryLevel]int)
+				for _, c := range estimate.Codes {
+					levels[c.RecoveryLevel]++
+				}
+
+				fmt.Printf("Recovery:")
+				for level, name := range []string{"low", "medium", "high", "highest"} {
+					if n := levels[qrcode.RecoveryLevel(level)]; n > 0 {
+						fmt.Printf(" %s (x%d)", name, n)
+					}
+				}
+				fmt.Println()
+			}
+
+			minChunk, maxChunk := estimate.Codes[0].ChunkSize, estimate.Codes[0].ChunkSize
+			minPixels, maxPixels := estimate.Codes[0].Pixels, estimate.Codes[0].Pixels
+
+			for _, c := range estimate.Codes[1:] {
+				minChunk, maxChunk = min(minChunk, c.ChunkSize), max(maxChunk, c.ChunkSize)
+				minPixels, maxPixels = min(minPixels, c.Pixels), max(maxPixels, c.Pixels)
+			}
+
+			fmt.Printf("Chunks:   %d-%d bytes\n", minChunk, maxChunk)
+			fmt.Printf("Images:   %d-%d pixels wide\n", minPixels, maxPixels)
+		}
+
+		fmt.Printf("Pixels:   %.1f megapixels\n", float64(estimate.PixelCount())/1e6)
+
+		for _, r := range estimate.ChunkSizeReductions {
+			fmt.Printf("Reduced:  %d to %d chunks, %d to %d bytes, to fit in a QR code\n",
+				r.FromChunks, r.ToChunks, r.FromChunkSize, r.ToChunkSize)
+		}
+
+		fmt.Printf("Video:    %v at %d frames per second\n", estimate.Duration(float64(estimateVideoFPS)), estimateVideoFPS)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(estimateCmd)
+
+	// Add flags
+	estimateCmd.Flags().StringVarP(&estimateInputFile, "input", "i", "", "Input file to estimate (required)")
+	estimateCmd.Flags().IntVar(&estimateVideoFPS, "fps", 5, "Frames per second of the video the duration is estimated for")
+	addEncoderFlags(estimateCmd)
+}
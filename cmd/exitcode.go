@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+// The exit codes of the commands, distinct for the failures wrappers react to
+const (
+	// exitFailure is the exit code of any other failure
+	exitFailure = 1
+	// exitUsage is the exit code of a required input that is not given or does not
+	// exist, or of an invalid flag
+	exitUsage = 2
+	// exitIncomplete is the exit code of a decoding that is missing chunks, which a
+	// later run can complete
+	exitIncomplete = 3
+	// exitHashMismatch is the exit code of a file that does not match its SHA-256
+	exitHashMismatch = 4
+	// exitFFmpegMissing is the exit code of a command that needs ffmpeg, which is
+	// not installed or not compiled in
+	exitFFmpegMissing = 5
+	// exitCapacityExceeded is the exit code of data that does not fit in a QR code
+	exitCapacityExceeded = 6
+	// exitInterrupted is the exit code of a command interrupted by a signal
+	exitInterrupted = 130
+)
+
+// errFFmpegMissing is returned by the commands that need ffmpeg if it is missing,
+// or wrapped if it is not compiled in
+var errFFmpegMissing = errors.New("ffmpeg is not installed or not in PATH. Please install ffmpeg to use the video generation feature")
+
+// exitError is an error a command fails with, exiting with code
+type exitError struct {
+	code int
+	err  error
+	// help is set to show the help of the command after the error
+	help bool
+}
+
+// Error implements error
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err exiting with code, nil if err is nil
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &exitError{code: code, err: err}
+}
+
+// exitErrorf returns an error formatted like fmt.Errorf, exiting with code
+func exitErrorf(code int, format string, args ...any) error {
+	return &exitError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// usageErrorf returns an error formatted like fmt.Errorf for a required input
+// that is not given, showing the help of the command
+func usageErrorf(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...), help: true}
+}
+
+// errFailed is returned by commands that already reported why they failed, such
+// as verify listing the chunks that failed. Execute exits without printing it.
+var errFailed = errors.New("failed")
+
+// exitCode returns the exit code of a command failing with err: the code given
+// with withExitCode, or otherwise the code of the library error it wraps
+func exitCode(err error) int {
+	var exitErr *exitError
+	var missing *qrfiletransfer.ErrMissingChunk
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.code
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.Is(err, qrfiletransfer.ErrHashMismatch):
+		return exitHashMismatch
+	case errors.As(err, &missing):
+		return exitIncomplete
+	case errors.Is(err, errFFmpegMissing):
+		return exitFFmpegMissing
+	case errors.Is(err, qrcode.ErrContentTooLong), errors.Is(err, qrfiletransfer.ErrSnippetTooLong):
+		return exitCapacityExceeded
+	case errors.Is(err, os.ErrNotExist):
+		return exitUsage
+	case strings.HasPrefix(err.Error(), "unknown command "):
+		// cobra has no error type for an unknown command
+		return exitUsage
+	}
+
+	return exitFailure
+}
+
+// usageArgs makes the arguments validation of cmd and its subcommands fail with
+// exitUsage
+func usageArgs(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, a []string) error {
+			return withExitCode(exitUsage, args(cmd, a))
+		}
+	}
+
+	for _, c := range cmd.Commands() {
+		usageArgs(c)
+	}
+}
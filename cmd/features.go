@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/dyammarcano/qrfiletransfer/pkg/features"
+	"github.com/spf13/cobra"
+)
+
+var featuresCmd = &cobra.Command{
+	Use:   "features",
+	Short: "List the features compiled into this binary",
+	Long: `List the optional features compiled into this binary and the build tag that
+leaves each of them out.
+
+Example:
+  go build -tags novideo .
+  qrfiletransfer features`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		enabled := make(map[string]bool)
+
+		for _, f := range features.List() {
+			enabled[f.Name] = f.Enabled
+
+			state := "enabled"
+			if !f.Enabled {
+				state = "disabled"
+			}
+
+			cmd.Printf("%-8s %-8s %s (build tag %s)\n", f.Name, state, f.Description, f.Tag)
+		}
+
+		setResult("features", enabled)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(featuresCmd)
+}
@@ -3,69 +3,364 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"image"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/dyammarcano/qrfiletransfer/pkg/animation"
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
 var (
 	generateInputDir string
 	generateVideoFPS int
+	generateSequence string
+	generateFormat   string
+	generateTiles    string
+	generateProtocol string
+	generateSchedule string
+	generateSize     string
+	generateCodec    string
+	generateCRF      int
+	generateBitrate  string
+	generateHold     int
+	generateLossless bool
+	generateInfo     bool
 )
 
+// videoCodecs maps the names accepted by --codec to their ffmpeg encoder
+var videoCodecs = map[string]string{
+	"libx264":    "libx264",
+	"libx265":    "libx265",
+	"vp9":        "libvpx-vp9",
+	"libvpx-vp9": "libvpx-vp9",
+	"ffv1":       "ffv1",
+}
+
+// streamInfoTitle is the title of the subtitle track holding the stream info
+const streamInfoTitle = "qrfiletransfer stream info"
+
+// defaultCRF is the constant rate factor of the lossy encoders when none is given
+var defaultCRF = map[string]int{
+	"libx264":    23,
+	"libx265":    28,
+	"libvpx-vp9": 32,
+}
+
+// videoOptions control the video encoded by generateQRCodeVideo
+type videoOptions struct {
+	// FPS is the number of QR codes shown per second
+	FPS int
+	// HoldFrames is the number of video frames every QR code is shown for, at a
+	// constant frame rate of FPS*HoldFrames. 0 or 1 writes one frame per QR code.
+	HoldFrames int
+	// Width and Height scale the QR codes to fit frames of that size, 0 keeps
+	// their size
+	Width, Height int
+	// Codec is the video codec, libx264, libx265, vp9, or ffv1, libx264 if empty
+	Codec string
+	// CRF is the constant rate factor, lower for a higher quality, -1 for the
+	// default of the codec
+	CRF int
+	// Bitrate is the target bitrate, e.g. "4M", empty for the default of the codec
+	Bitrate string
+	// Lossless encodes the QR codes without any loss, ffv1 always does
+	Lossless bool
+	// Info is written into a subtitle track of the video, nil for none
+	Info *qrfiletransfer.StreamInfo
+}
+
+// videoEncoder returns the --codec name of codec, libx264 if empty, and its ffmpeg
+// encoder
+func videoEncoder(codec string) (string, string, error) {
+	codec = strings.ToLower(codec)
+	if codec == "" {
+		codec = "libx264"
+	}
+
+	encoder, ok := videoCodecs[codec]
+	if !ok {
+		return "", "", fmt.Errorf("unknown codec '%s' (expected libx264, libx265, vp9 or ffv1)", codec)
+	}
+
+	return codec, encoder, nil
+}
+
+// videoFileName returns the name of the video generate writes with opts: ffv1
+// videos are stored in Matroska, the others in MP4
+func videoFileName(opts videoOptions) string {
+	if _, encoder, err := videoEncoder(opts.Codec); err == nil && encoder == "ffv1" {
+		return "qrcodes_video.mkv"
+	}
+
+	return "qrcodes_video.mp4"
+}
+
+// warnVideoDensity warns if lossy compression with opts risks blurring the modules
+// of the QR codes of the given version in frames, tiled by columns x rows, beyond
+// recognition. The rule of thumb is that a module needs 2 pixels, plus one for
+// every 8 of the CRF.
+func warnVideoDensity(frames []string, version int, opts videoOptions, columns, rows int) {
+	_, encoder, err := videoEncoder(opts.Codec)
+	if err != nil || opts.Lossless || encoder == "ffv1" || opts.Bitrate != "" || len(frames) == 0 || version == 0 {
+		return
+	}
+
+	file, err := os.Open(frames[0])
+	if err != nil {
+		return
+	}
+
+	config, _, err := image.DecodeConfig(file)
+	_ = file.Close()
+
+	if err != nil || config.Width == 0 {
+		return
+	}
+
+	// QR codes scaled to a resolution get bigger or smaller modules
+	pixels := float64(config.Width) / float64(21+(version-1)*4+8)
+	if opts.Width > 0 {
+		pixels *= min(float64(opts.Width)/float64(columns*config.Width), float64(opts.Height)/float64(rows*config.Height))
+	}
+
+	crf := opts.CRF
+	if crf < 0 {
+		crf = defaultCRF[encoder]
+	}
+
+	if pixels < 2+float64(crf)/8 {
+		diag.Warnf(diagnostics.CodeLowDensity, "",
+			"QR codes of version %d have %.1f pixels per module, which %s at CRF %d may blur beyond recognition, use --lossless, a lower --crf, or a larger --resolution",
+			version, pixels, encoder, crf)
+	}
+}
+
+// inputStreamInfo returns the stream info of the session in dir, or in its parent
+// for the QR codes directory of a session, nil if there is none
+func inputStreamInfo(dir string) *qrfiletransfer.StreamInfo {
+	manifest, err := qrfiletransfer.LoadManifest(dir)
+	if err != nil {
+		if manifest, err = qrfiletransfer.LoadManifest(filepath.Dir(dir)); err != nil {
+			return nil
+		}
+	}
+
+	return qrfiletransfer.NewStreamInfo(manifest)
+}
+
+// sessionQRVersion returns the highest QR code version of the chunks of the
+// session in dir, or in its parent for the QR codes directory of a session, 0 if
+// unknown
+func sessionQRVersion(dir string) int {
+	session, err := qrfiletransfer.OpenSession(dir)
+	if err != nil {
+		if session, err = qrfiletransfer.OpenSession(filepath.Dir(dir)); err != nil {
+			return 0
+		}
+	}
+
+	version := 0
+	for _, chunk := range session.Chunks {
+		version = max(version, chunk.QRVersion)
+	}
+
+	return version
+}
+
 var generateCmd = &cobra.Command{
 	Use:   "generate",
-	Short: "Generate a video from QR code images",
-	Long: `Generate a video from QR code images using ffmpeg.
+	Short: "Generate a video or animation from QR code images",
+	Long: `Generate a video from QR code images using ffmpeg, or an animated GIF or APNG
+without it.
 
 Example:
   qrfiletransfer generate -i qrcodes_directory
 
 This will generate a video from all QR code images in the specified directory.
-The video will be saved in the same directory as "qrcodes_video.mp4".`,
-	Run: func(cmd *cobra.Command, args []string) {
+--codec, --crf or --bitrate, and --resolution control its encoding, and with
+--hold-frames N every QR code stays on screen for N frames of a video at N
+times --fps, for cameras that miss short frames. --lossless, or --codec ffv1,
+encodes the QR codes without compression artifacts at the cost of a larger
+file; a warning suggests it when lossy compression risks blurring the modules of
+dense QR codes.
+If the directory is a session created by split, the QR codes listed by its
+session file are used. For a batch of several files, the QR codes are played in
+the global order listed in its frames.json, which is the same on every machine.
+The video will be saved in the same directory as "qrcodes_video.mp4", or
+"qrcodes_video.mkv" for ffv1. The video of a session has a subtitle track
+describing the file by name, size, SHA-256, and chunk count, which read checks
+before decoding the frames; --stream-info=false leaves it out.
+
+With --sequence, the QR codes are copied in playback order into a directory of
+images named by their zero-padded frame number instead, e.g. 0001.png, and no
+video is generated:
+  qrfiletransfer generate -i batch_qrcodes --sequence frames
+
+With --format gif or --format apng, a looping animation of the QR codes is
+written natively as "qrcodes_animation.gif" or "qrcodes_animation.png" instead,
+for display on any screen or in a browser, and ffmpeg is not needed:
+  qrfiletransfer generate -i qrcodes_directory --format gif --fps 4
+
+With --tiles, every frame lays out a grid of columns by rows QR codes instead of
+one, multiplying the chunks carried per frame. Split with a smaller --size so that
+the tiled codes stay readable. read and scan find every code of a frame without
+any option:
+  qrfiletransfer generate -i qrcodes_directory --tiles 2x2
+
+With --protocol, the file of a session split with the native protocol is
+framed again in another protocol, e.g. ur for the parts of a BC-UR, so that
+the apps speaking it receive the file from the video or animation. A session
+split with the protocol is played as it is:
+  qrfiletransfer generate -i qrcodes_directory --format gif --protocol ur
+
+With --schedule, the QR codes are repeated and interleaved, so that a camera
+dropping frames still catches every chunk: repeat:N plays the whole sequence N
+times, shuffle:N plays them in a shuffled order in blocks of N codes that are
+each played twice, and weighted:N plays N passes over ever shorter prefixes of
+the sequence, so the first chunks are played N times and the last ones once:
+  qrfiletransfer generate -i qrcodes_directory --schedule shuffle:8`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Validate input directory
 		if generateInputDir == "" {
-			cmd.Println("Error: input directory is required")
-			if err := cmd.Help(); err != nil {
-				fmt.Printf("Error displaying help: %v\n", err)
-			}
-			os.Exit(1)
+			return usageErrorf("input directory is required")
 		}
 
 		// Check if the input directory exists
 		if _, err := os.Stat(generateInputDir); os.IsNotExist(err) {
-			cmd.Printf("Error: input directory '%s' does not exist\n", generateInputDir)
-			os.Exit(1)
+			return exitErrorf(exitUsage, "input directory '%s' does not exist", generateInputDir)
+		}
+
+		// Find the QR codes in playback order
+		frames, videoDir, err := playbackFrames(generateInputDir)
+		if err == nil && generateProtocol != qrfiletransfer.ProtocolNative {
+			frames, videoDir, err = protocolPlaybackFrames(generateInputDir, generateProtocol)
+		}
+		if err != nil {
+			return err
+		}
+
+		schedule, err := qrfiletransfer.ParseSchedule(generateSchedule)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		frames = scheduleFrames(frames, schedule)
+
+		columns, rows, err := parseTiles(generateTiles)
+		if err != nil {
+			return err
+		}
+
+		codes := frames
+		setResult("qr_codes", len(codes))
+
+		if columns*rows > 1 {
+			tiled, err := tileFrames(frames, videoDir, columns, rows)
+			if err != nil {
+				return fmt.Errorf("failed to tile QR codes: %w", err)
+			}
+
+			if generateSequence != "" {
+				setResult("frames", len(tiled))
+				setResult("output", newOutputResult(generateSequence))
+				cmd.Printf("Successfully wrote %d QR codes as %d tiled frames: %s\n", len(frames), len(tiled), generateSequence)
+
+				return nil
+			}
+
+			frames = tiled
+		}
+
+		if generateSequence != "" {
+			cmd.Printf("Writing %d QR codes as a frame sequence to '%s'...\n", len(frames), generateSequence)
+			if err := qrfiletransfer.WriteFrameSequence(frames, generateSequence); err != nil {
+				return fmt.Errorf("failed to write frame sequence: %w", err)
+			}
+
+			setResult("frames", len(frames))
+			setResult("output", newOutputResult(generateSequence))
+			cmd.Printf("Successfully wrote frame sequence: %s\n", generateSequence)
+
+			return nil
+		}
+
+		if generateFormat == "gif" || generateFormat == "apng" {
+			animationPath, err := writeAnimation(frames, videoDir)
+			if err != nil {
+				return fmt.Errorf("failed to generate animation: %w", err)
+			}
+
+			setResult("frames", len(frames))
+			setResult("output", newOutputResult(animationPath))
+			cmd.Printf("Successfully generated animation: %s\n", animationPath)
+
+			return nil
 		}
 
-		// Find the QR codes directory
-		qrDir := generateInputDir
-		// If the input is the parent directory, look for the qrcodes subdirectory
-		qrcodesSubdir := filepath.Join(generateInputDir, "qrcodes")
-		if _, err := os.Stat(qrcodesSubdir); err == nil {
-			qrDir = qrcodesSubdir
+		if generateFormat != "mp4" {
+			return exitErrorf(exitUsage, "unknown format '%s' (expected mp4, gif or apng)", generateFormat)
 		}
 
 		cmd.Println("Generating video from QR codes...")
 
 		// Check if ffmpeg is installed
 		if err := checkFFmpegInstalled(); err != nil {
-			cmd.Printf("Error: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+
+		opts := videoOptions{
+			FPS:        generateVideoFPS,
+			HoldFrames: generateHold,
+			Codec:      generateCodec,
+			CRF:        -1,
+			Bitrate:    generateBitrate,
+			Lossless:   generateLossless,
+		}
+
+		// Without --crf the codec picks its own, 0 being a valid CRF
+		if cmd.Flags().Changed("crf") {
+			if generateCRF < 0 {
+				return exitErrorf(exitUsage, "invalid CRF %d (expected 0 or more)", generateCRF)
+			}
+
+			opts.CRF = generateCRF
+		}
+
+		if generateSize != "" {
+			if opts.Width, opts.Height, err = parseResolution(generateSize); err != nil {
+				return err
+			}
+		}
+
+		// The QR codes of other protocols are not those of the chunks
+		if generateProtocol == qrfiletransfer.ProtocolNative {
+			warnVideoDensity(codes, sessionQRVersion(generateInputDir), opts, columns, rows)
+
+			if generateInfo {
+				opts.Info = inputStreamInfo(generateInputDir)
+			}
 		}
 
 		// Generate video from QR codes
-		videoPath := filepath.Join(filepath.Dir(qrDir), "qrcodes_video.mp4")
-		if err := generateQRCodeVideo(qrDir, videoPath, generateVideoFPS); err != nil {
-			cmd.Printf("Error generating video: %v\n", err)
-			os.Exit(1)
+		videoPath := filepath.Join(videoDir, videoFileName(opts))
+		stopRender := timePhase("render")
+		if err := generateQRCodeVideo(frames, videoPath, opts); err != nil {
+			return fmt.Errorf("failed to generate video: %w", err)
 		}
+		stopRender()
 
+		setResult("frames", len(frames))
+		setResult("output", newOutputResult(videoPath))
 		cmd.Printf("Successfully generated video: %s\n", videoPath)
+
+		return nil
 	},
 }
 
@@ -75,88 +370,227 @@ func init() {
 	// Add flags
 	generateCmd.Flags().StringVarP(&generateInputDir, "input", "i", "", "Input directory containing QR codes (required)")
 	generateCmd.Flags().IntVar(&generateVideoFPS, "fps", 5, "Frames per second for the generated video (default: 2)")
+	generateCmd.Flags().StringVar(&generateSequence, "sequence", "",
+		"Write the QR codes in playback order as zero-padded image files into this directory instead of a video")
+	generateCmd.Flags().StringVar(&generateFormat, "format", "mp4",
+		"Output format: mp4 video with ffmpeg, or a looping gif or apng animation without it")
+	generateCmd.Flags().StringVar(&generateTiles, "tiles", "1x1",
+		"Grid of QR codes laid out in every frame, as columns x rows, e.g. 2x2 or 3x3")
+	generateCmd.Flags().StringVar(&generateProtocol, "protocol", qrfiletransfer.ProtocolNative,
+		"Protocol of the frames: native to play the QR codes as split, or ur, txqr, or structured-append to frame the file again for the apps speaking it")
+	generateCmd.Flags().StringVar(&generateSize, "resolution", "",
+		"Resolution of the video, e.g. 1080x1080, the QR codes are scaled to fit without smoothing (default: size of the QR codes)")
+	generateCmd.Flags().StringVar(&generateCodec, "codec", "libx264",
+		"Video codec: libx264, libx265, or vp9, or ffv1 for a lossless Matroska video")
+	generateCmd.Flags().BoolVar(&generateInfo, "stream-info", true,
+		"Describe the file in a subtitle track of the video, for read to check the QR codes against")
+	generateCmd.Flags().BoolVar(&generateLossless, "lossless", false,
+		"Encode the video without loss, so compression never degrades the QR codes (libx264 at qp 0 unless --codec is given)")
+	generateCmd.Flags().IntVar(&generateCRF, "crf", 0,
+		"Constant rate factor of the video, lower for fewer compression artifacts, e.g. 18 (default: codec default)")
+	generateCmd.Flags().StringVar(&generateBitrate, "bitrate", "",
+		"Target bitrate of the video, e.g. 4M, instead of --crf (default: codec default)")
+	generateCmd.Flags().IntVar(&generateHold, "hold-frames", 1,
+		"Number of video frames each QR code stays on screen for, at a frame rate of --fps times this number")
+	generateCmd.Flags().StringVar(&generateSchedule, "schedule", qrfiletransfer.ScheduleSequential,
+		"Order of the QR codes: sequential, repeat:N, shuffle:N, or weighted:N, repeating them for cameras that drop frames")
+
+	_ = generateCmd.RegisterFlagCompletionFunc("format", completeValues("mp4", "gif", "apng"))
+	_ = generateCmd.RegisterFlagCompletionFunc("protocol", completeValues(qrfiletransfer.ProtocolNames()...))
+	_ = generateCmd.RegisterFlagCompletionFunc("codec", completeValues("libx264", "libx265", "vp9", "ffv1"))
+	_ = generateCmd.RegisterFlagCompletionFunc("schedule", completeValues(qrfiletransfer.ScheduleSequential,
+		qrfiletransfer.ScheduleRepeat, qrfiletransfer.ScheduleShuffle, qrfiletransfer.ScheduleWeighted))
+	markDirFlags(generateCmd, "input", "sequence")
 }
 
-// checkFFmpegInstalled checks if ffmpeg is installed on the system.
-func checkFFmpegInstalled() error {
-	cmd := exec.Command("ffmpeg", "-version")
-	if err := cmd.Run(); err != nil {
-		return errors.New("ffmpeg is not installed or not in PATH. Please install ffmpeg to use the video generation feature")
+// scheduleFrames returns frames in the order of schedule
+func scheduleFrames(frames []string, schedule qrfiletransfer.Schedule) []string {
+	order := schedule.Order(len(frames))
+
+	scheduled := make([]string, 0, len(order))
+	for _, i := range order {
+		scheduled = append(scheduled, frames[i])
 	}
 
-	return nil
+	return scheduled
 }
 
-// generateQRCodeVideo generates a video from QR code images using ffmpeg.
-func generateQRCodeVideo(qrDir, videoPath string, fps int) (err error) {
-	// Get all PNG files in the QR codes directory
-	files, err := filepath.Glob(filepath.Join(qrDir, "*.png"))
+// protocolPlaybackFrames returns the QR codes of the file of the session in dir
+// framed in protocol, in playback order, into a temporary <protocol>_frames
+// directory of dir removed when the command ends, and dir as the video directory.
+// A session already split with the protocol is played as it is.
+func protocolPlaybackFrames(dir, protocol string) ([]string, string, error) {
+	qrft := newQRFileTransfer()
+	if err := qrft.SetProtocol(protocol); err != nil {
+		return nil, "", withExitCode(exitUsage, err)
+	}
+
+	if session, err := qrfiletransfer.OpenSession(dir); err == nil && session.Settings.Protocol == protocol {
+		return playbackFrames(dir)
+	}
+
+	data, name, err := qrft.QRCodesToBytesCtx(runCtx, dir)
 	if err != nil {
-		return fmt.Errorf("failed to list QR code files: %w", err)
+		return nil, "", fmt.Errorf("failed to reassemble the file of %s: %w", dir, err)
 	}
 
-	if len(files) == 0 {
-		return fmt.Errorf("no QR code images found in %s", qrDir)
+	outDir := filepath.Join(dir, protocol+"_frames")
+	if err := os.RemoveAll(outDir); err != nil {
+		return nil, "", fmt.Errorf("failed to clear %s frames: %w", protocol, err)
 	}
 
-	// Sort files to ensure they are processed in the correct order
-	sort.Strings(files)
+	work.Track(osFs, workspace.Frames, outDir)
 
-	// Create a temporary file with the list of images
-	tempFile, err := os.CreateTemp("", "qrcodes_list_*.txt")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
+	fmt.Printf("Framing %s in the %s protocol...\n", name, protocol)
+
+	if err := qrft.BytesToQRCodesCtx(runCtx, data, name, outDir); err != nil {
+		return nil, "", fmt.Errorf("failed to encode the %s QR codes: %w", protocol, err)
 	}
 
-	defer func() {
-		removeErr := os.Remove(tempFile.Name())
-		if removeErr != nil && err == nil {
-			err = fmt.Errorf("failed to remove temporary file: %w", removeErr)
+	frames, _, err := playbackFrames(outDir)
+
+	return frames, dir, err
+}
+
+// parseResolution parses a --resolution such as "1280x720" into its width and height
+func parseResolution(value string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	if ok {
+		width, err = strconv.Atoi(w)
+		if err == nil {
+			height, err = strconv.Atoi(h)
 		}
-	}()
+	}
 
-	// Write the list of files to the temporary file
-	for _, file := range files {
-		// Use the file's absolute path
-		absPath, err := filepath.Abs(file)
-		if err != nil {
-			return fmt.Errorf("failed to get absolute path for %s: %w", file, err)
+	if !ok || err != nil || width < 1 || height < 1 {
+		return 0, 0, exitErrorf(exitUsage, "invalid resolution '%s' (expected width x height, e.g. 1080x1080)", value)
+	}
+
+	return width, height, nil
+}
+
+// parseTiles parses a --tiles grid such as "2x2" into its columns and rows
+func parseTiles(value string) (columns, rows int, err error) {
+	c, r, ok := strings.Cut(strings.ToLower(value), "x")
+	if ok {
+		columns, err = strconv.Atoi(c)
+		if err == nil {
+			rows, err = strconv.Atoi(r)
 		}
-		// ffmpeg requires the file list to use the 'file' protocol
-		_, err = fmt.Fprintf(tempFile, "file '%s'\n", absPath)
-		if err != nil {
-			return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	if !ok || err != nil || columns < 1 || rows < 1 {
+		return 0, 0, exitErrorf(exitUsage, "invalid tile grid '%s' (expected columns x rows, e.g. 2x2)", value)
+	}
+
+	return columns, rows, nil
+}
+
+// tileFrames lays out frames in grids of columns by rows QR codes, into the
+// --sequence directory or else a temporary tiled_frames directory of dir removed
+// when the command ends, and returns the tiled frames
+func tileFrames(frames []string, dir string, columns, rows int) ([]string, error) {
+	for _, file := range frames {
+		if filepath.Ext(file) != qrfiletransfer.ImageFormatPNG.Ext() {
+			return nil, errors.New("only PNG QR codes can be tiled, split with --format png")
 		}
-		// Set the duration for each image (in seconds)
-		_, err = fmt.Fprintf(tempFile, "duration %f\n", 1.0/float64(fps))
-		if err != nil {
-			return fmt.Errorf("failed to write to temporary file: %w", err)
+	}
+
+	outDir := generateSequence
+	if outDir == "" {
+		// Tiled frames of a previous run would be mixed with the new ones
+		outDir = filepath.Join(dir, "tiled_frames")
+		if err := os.RemoveAll(outDir); err != nil {
+			return nil, fmt.Errorf("failed to clear tiled frames: %w", err)
+		}
+
+		work.Track(osFs, workspace.Frames, outDir)
+	}
+
+	fmt.Printf("Tiling %d QR codes %dx%d per frame...\n", len(frames), columns, rows)
+
+	return qrfiletransfer.TileFrames(frames, outDir, columns, rows)
+}
+
+// writeAnimation writes frames as an animation in the --format format into dir and
+// returns its path. A failed animation is removed.
+func writeAnimation(frames []string, dir string) (path string, err error) {
+	// Only PNG and JPEG images are decoded
+	for _, file := range frames {
+		if ext := filepath.Ext(file); ext != qrfiletransfer.ImageFormatPNG.Ext() && ext != qrfiletransfer.ImageFormatJPEG.Ext() {
+			return "", fmt.Errorf("%s QR codes cannot be animated, split with --format png or use --sequence", strings.ToUpper(strings.TrimPrefix(ext, ".")))
 		}
 	}
 
-	// Close the temporary file
-	if err := tempFile.Close(); err != nil {
-		return fmt.Errorf("failed to close temporary file: %w", err)
+	write, ext := animation.WriteGIF, ".gif"
+	if generateFormat == "apng" {
+		write, ext = animation.WriteAPNG, ".png"
+	}
+
+	path = filepath.Join(dir, "qrcodes_animation"+ext)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create animation: %w", err)
 	}
 
-	// Build the ffmpeg command
-	cmd := exec.Command(
-		"ffmpeg",
-		"-y",           // Overwrite an output file if it exists
-		"-f", "concat", // Use concat demuxer
-		"-safe", "0", // Don't require safe filenames
-		"-i", tempFile.Name(), // Input file list
-		"-vsync", "vfr", // Variable frame rate
-		"-pix_fmt", "yuv420p", // Pixel format for compatibility
-		"-c:v", "libx264", // Video codec
-		videoPath, // Output file
-	)
+	fmt.Printf("Writing %d QR codes as an animation...\n", len(frames))
+
+	err = write(out, frames, generateVideoFPS)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
 
-	// Capture command output
-	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("ffmpeg command failed: %w\nOutput: %s", err, string(output))
+		_ = os.Remove(path)
+
+		return "", err
 	}
 
-	return nil
+	return path, nil
+}
+
+// playbackFrames returns the QR code images found in dir in playback order, and the
+// directory the video of dir is saved in. A batch lists its frames in its frame
+// order file and a session in its session file, any other directory is expected to
+// contain the QR code images itself, played in name order. The chunks of a
+// content-addressed session or of a session split into volumes are played in the
+// order of its session file.
+func playbackFrames(dir string) ([]string, string, error) {
+	if order, err := qrfiletransfer.LoadFrameOrder(dir); err == nil {
+		return order.FramePaths(dir), dir, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, "", err
+	}
+
+	if qrfiletransfer.IsBatch(dir) {
+		return nil, "", fmt.Errorf("batch in %s has no %s, re-run split to write it", dir, qrfiletransfer.FrameOrderFileName)
+	}
+
+	qrDir, ext := dir, ".png"
+	if session, err := qrfiletransfer.OpenSession(dir); err == nil && session.QRCodesDir() != "" {
+		qrDir = session.QRCodesDir()
+		ext = qrfiletransfer.ImageFormat(session.Settings.ImageFormat).Ext()
+
+		// Content-addressed images are named after their hash, not in order, and
+		// volumes hold the images in directories of their own
+		if session.Settings.ContentAddressed || session.VolumeCount() > 0 {
+			return session.QRCodeFiles(), filepath.Dir(qrDir), nil
+		}
+	}
+
+	// Get all QR code images in the QR codes directory
+	files, err := filepath.Glob(filepath.Join(qrDir, "*"+ext))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list QR code files: %w", err)
+	}
+
+	if len(files) == 0 {
+		return nil, "", fmt.Errorf("no QR code images found in %s", qrDir)
+	}
+
+	// Sort files to ensure they are processed in the correct order
+	sort.Strings(files)
+
+	return files, filepath.Dir(qrDir), nil
 }
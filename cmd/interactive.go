@@ -0,0 +1,335 @@
+//go:build !novideo && !nodecode
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/serve"
+	"github.com/spf13/cobra"
+)
+
+// ackHoldTime is how long receive --interactive shows the final acknowledgement,
+// for the sender to see that every chunk was read
+const ackHoldTime = 10 * time.Second
+
+var (
+	sendInput       string
+	sendOutputDir   string
+	sendFPS         float64
+	sendInteractive bool
+
+	receiveInteractive bool
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send",
+	Short: "Send a file as a slideshow of QR codes, repeating the chunks a receiver misses",
+	Long: `Show the QR codes of a file as a fullscreen slideshow in a window, like
+display. With --interactive, the camera of the sender watches the screen of the
+receiver, which runs receive --interactive: the receiver shows a status QR code
+listing the chunks it is missing, and the slideshow then only cycles through
+those chunks, until the receiver reports that it has every chunk.
+
+Example:
+  qrfiletransfer send -i myfile.txt --interactive
+  qrfiletransfer receive --interactive
+
+Place the devices so that the camera of each one sees the screen of the other.
+The camera options are those of scan. Interactive transfers need a single file
+of native chunks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if sendInput == "" {
+			return usageErrorf("input file or session directory is required")
+		}
+
+		var (
+			inputFmt, device string
+			err              error
+		)
+
+		if sendInteractive {
+			if err := checkFFmpegInstalled(); err != nil {
+				return err
+			}
+
+			if err := loadLensProfile(scanLens); err != nil {
+				return err
+			}
+
+			if err := loadEnhancement(scanEnhance); err != nil {
+				return err
+			}
+
+			sweepBudget = scanBudget
+
+			if inputFmt, device, err = captureInput(scanInputFmt, scanDevice); err != nil {
+				return err
+			}
+		}
+
+		show, err := newSlideshow(sendInput, sendOutputDir, sendFPS, true)
+		if err != nil {
+			return err
+		}
+
+		defer show.release()
+
+		if !sendInteractive {
+			return displaySlideshow(show, nil)
+		}
+
+		session, err := qrfiletransfer.OpenSession(show.dir)
+		if err != nil || session.Settings.Protocol != "" {
+			return exitErrorf(exitUsage, "interactive transfers need a session of a single file with native chunks")
+		}
+
+		fmt.Printf("Watching for acknowledgements from %s (%s)...\n", device, inputFmt)
+
+		return displaySlideshow(show, func(ctx context.Context) error {
+			return watchAcks(ctx, show, session.File.Hash, captureArgs(inputFmt, device, scanFPS, scanVideoSize))
+		})
+	},
+}
+
+var receiveCmd = &cobra.Command{
+	Use:   "receive",
+	Short: "Receive a file from QR codes, reporting the missing chunks to the sender",
+	Long: `Scan QR codes from a camera and reconstruct the file, like scan. With
+--interactive, the chunks still missing are shown in the terminal as a status QR
+code for the camera of the sender, which runs send --interactive and repeats
+only those chunks. Once every chunk has been read, the final status QR code is
+shown for a few seconds so the sender stops too.
+
+Example:
+  qrfiletransfer receive --interactive -o reconstructed_file.txt
+
+The options are those of scan. Interactive transfers need a single file of
+native chunks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runScan(receiveInteractive)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sendCmd)
+	rootCmd.AddCommand(receiveCmd)
+
+	// Add flags
+	sendCmd.Flags().StringVarP(&sendInput, "input", "i", "",
+		"Input file or session directory (required)")
+	sendCmd.Flags().StringVarP(&sendOutputDir, "output", "o", "",
+		"Output directory for the QR codes of an input file (default: temporary directory)")
+	sendCmd.Flags().Float64Var(&sendFPS, "fps", serve.DefaultFPS,
+		"Frames per second of the slideshow")
+	sendCmd.Flags().BoolVar(&sendInteractive, "interactive", false,
+		"Watch the status QR code of the receiver with the camera and only repeat the chunks it is missing")
+	addCameraFlags(sendCmd, "capture-fps")
+
+	addScanFlags(receiveCmd)
+	receiveCmd.Flags().BoolVar(&receiveInteractive, "interactive", false,
+		"Show the chunks still missing as a status QR code for the camera of the sender")
+
+	markDirFlags(sendCmd, "output")
+}
+
+// watchAcks decodes the frames captured by ffmpeg until the receiver acknowledges
+// every chunk of the file with the given hash or ctx is done, and directs show to
+// the chunks the receiver is missing
+func watchAcks(ctx context.Context, show *slideshow, hash string, args []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	capture := exec.CommandContext(ctx, "ffmpeg", args...)
+	capture.Stderr = os.Stderr
+
+	stdout, err := capture.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to ffmpeg: %w", err)
+	}
+
+	if err := capture.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// The frames showing each data chunk, by chunk index
+	frames := make(map[int]int)
+	for i, frame := range show.frames {
+		if index, ok := (&qrfiletransfer.ChunkPayload{Name: frame.Name}).Index(); ok {
+			frames[index] = i
+		}
+	}
+
+	stream := bufio.NewReader(stdout)
+
+	var (
+		missing  []int
+		complete bool
+		warned   bool
+	)
+
+	for !complete {
+		img, err := png.Decode(stream)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || ctx.Err() != nil {
+				break
+			}
+
+			return fmt.Errorf("failed to decode captured frame: %w", err)
+		}
+
+		contents, err := decodeFrame(img)
+		if err != nil {
+			continue
+		}
+
+		for _, content := range contents {
+			ack, err := qrfiletransfer.ParseAck(content)
+			if err != nil {
+				continue
+			}
+
+			if ack.Hash != "" && ack.Hash != hash {
+				if !warned {
+					diag.Warnf(diagnostics.CodeOptionalOutput, "", "ignoring the acknowledgement of another file, SHA-256 %s", ack.Hash)
+					warned = true
+				}
+
+				continue
+			}
+
+			// The receiver only knows the missing chunks once it knows how many
+			// there are, until then every chunk is shown
+			if complete = ack.Complete(); complete || ack.Total == 0 || slices.Equal(ack.Missing, missing) {
+				continue
+			}
+
+			missing = ack.Missing
+
+			var queue []int
+			for _, index := range missing {
+				if i, ok := frames[index]; ok {
+					queue = append(queue, i)
+				}
+			}
+
+			if err := show.server.Queue(queue); err != nil {
+				return fmt.Errorf("failed to replay the missing chunks: %w", err)
+			}
+
+			fmt.Printf("Receiver is missing chunks %s, showing only those\n", qrfiletransfer.FormatIndexRanges(missing))
+		}
+	}
+
+	// ffmpeg is killed when watching stopped on purpose, any other exit is a failure
+	stopped := ctx.Err() != nil || complete
+
+	cancel()
+
+	if err := capture.Wait(); err != nil && !stopped {
+		return fmt.Errorf("ffmpeg command failed: %w", err)
+	}
+
+	if complete {
+		fmt.Println("Receiver has every chunk")
+	}
+
+	return nil
+}
+
+// ackScreen shows the acknowledgement of the chunks scanned into a directory as a
+// status QR code in the terminal, redrawn whenever it changes
+type ackScreen struct {
+	dir  string
+	last string
+	// batch is set once a chunk of a batch was read, which is not acknowledged
+	batch bool
+}
+
+// newAckScreen clears the terminal and shows the acknowledgement of the chunks
+// already in dir
+func newAckScreen(dir string) *ackScreen {
+	a := &ackScreen{dir: dir}
+
+	// Clear the screen and hide the cursor, shown again by close
+	fmt.Print("\x1b[2J\x1b[?25l")
+	a.update("", &qrfiletransfer.ChunkReport{})
+
+	return a
+}
+
+// update shows the acknowledgement of report, see scanCamera. The chunks of a
+// batch are reported as scan does.
+func (a *ackScreen) update(file string, report *qrfiletransfer.ChunkReport) {
+	if file != "" || a.batch {
+		if !a.batch {
+			a.batch = true
+			fmt.Print("\x1b[2J\x1b[H")
+			diag.Warnf(diagnostics.CodeOptionalOutput, "", "chunks of a batch are not acknowledged, scanning without acknowledgements")
+		}
+
+		fmt.Printf("\rChunks%s: %s", fileLabel(file), report)
+
+		return
+	}
+
+	var hash string
+	if session, err := qrfiletransfer.OpenSession(a.dir); err == nil {
+		hash = session.File.Hash
+	}
+
+	content := string(qrfiletransfer.EncodeAck(qrfiletransfer.NewAck(report, hash)))
+	if content == a.last {
+		return
+	}
+
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, "", "failed to create the status QR code: %v", err)
+
+		return
+	}
+
+	a.last = content
+
+	// Draw over the previous status QR code from the top, and clear what is left of it
+	fmt.Print("\x1b[H")
+
+	if err := code.WriteTerminal(os.Stdout); err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, "", "failed to show the status QR code: %v", err)
+	}
+
+	fmt.Printf("Chunks: %s\x1b[J", report)
+}
+
+// hold keeps the acknowledgement up for ackHoldTime, or until ctx is done
+func (a *ackScreen) hold(ctx context.Context) {
+	if a.batch {
+		return
+	}
+
+	fmt.Printf("\nShowing the final status QR code to the sender for %s...", ackHoldTime)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(ackHoldTime):
+	}
+}
+
+// close shows the cursor again
+func (a *ackScreen) close() {
+	fmt.Print("\x1b[?25h")
+}
@@ -7,52 +7,98 @@ import (
 	"strings"
 
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
 var (
-	joinInputDir   string
-	joinOutputFile string
+	joinInputDir    string
+	joinOutputFile  string
+	joinConcurrency int
 )
 
 var joinCmd = &cobra.Command{
-	Use:   "join",
-	Short: "Join QR code images into a file",
+	Use:     "join",
+	Aliases: []string{"decode"},
+	Short:   "Join QR code images into a file",
 	Long: `Join QR code images from an input directory back into the original file.
 
 Example:
   qrfiletransfer join -i input_directory -o output_file.txt
 
 This will join the QR code images in input_directory back into the original file
-and save it as output_file.txt.`,
-	Run: func(cmd *cobra.Command, args []string) {
+and save it as output_file.txt. QR codes of a directory tree, created with split
+--recursive, are restored into the output path as a directory. Chunks that are
+missing or damaged are restored from the parity QR codes of split --parity, if
+there are enough of them.
+
+The QR codes of a batch, created with split on several inputs, are joined into
+one file per input, written into the output path as a directory:
+  qrfiletransfer join -i batch_qrcodes -o received_files`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Validate input directory
 		if joinInputDir == "" {
-			cmd.Println("Error: input directory is required")
-			if err := cmd.Help(); err != nil {
-				fmt.Printf("Error displaying help: %v\n", err)
-			}
-			os.Exit(1)
+			return usageErrorf("input directory is required")
 		}
 
 		// Check if the input directory exists
 		if _, err := os.Stat(joinInputDir); os.IsNotExist(err) {
-			cmd.Printf("Error: input directory '%s' does not exist\n", joinInputDir)
-			os.Exit(1)
+			return exitErrorf(exitUsage, "input directory '%s' does not exist", joinInputDir)
+		}
+
+		// Sessions split with --no-data are joined from their decoded QR codes
+		inputDir := joinInputDir
+
+		decoded := splitWithoutData(joinInputDir)
+		if decoded {
+			var err error
+			if inputDir, err = decodeJoinInput(cmd, joinInputDir); err != nil {
+				return err
+			}
+		}
+
+		// Join every file of a batch into the output directory
+		if qrfiletransfer.IsBatch(inputDir) {
+			if joinOutputFile == "" {
+				joinOutputFile = filepath.Base(joinInputDir) + "_reconstructed"
+			}
+
+			var err error
+			if joinOutputFile, err = safeOutputPath(joinOutputFile); err != nil {
+				return err
+			}
+
+			qrft := newQRFileTransfer()
+			if joinConcurrency > 0 {
+				qrft.SetConcurrency(joinConcurrency)
+			}
+
+			return reconstructBatch(qrft, inputDir, joinOutputFile)
 		}
 
-		// Check if the qrcodes directory exists inside the input directory
-		qrcodesDir := filepath.Join(joinInputDir, "qrcodes")
-		if _, err := os.Stat(qrcodesDir); os.IsNotExist(err) {
-			cmd.Printf("Error: QR codes directory '%s' does not exist\n", qrcodesDir)
-			os.Exit(1)
+		// Load the session describing the layout of the input directory
+		// Archives created before session files existed are converted on the fly
+		session, err := qrfiletransfer.OpenSession(inputDir)
+		if err != nil {
+			return err
 		}
 
-		// Check if the data directory exists inside the input directory
-		dataDir := filepath.Join(joinInputDir, "data")
-		if _, err := os.Stat(dataDir); os.IsNotExist(err) {
-			cmd.Printf("Error: data directory '%s' does not exist\n", dataDir)
-			os.Exit(1)
+		// The chunks of QR codes that could not be decoded are restored from the parity
+		// chunks, if there are, or reported missing when the file is joined
+		if !session.Complete && decoded && !hasParity(joinInputDir) {
+			return exitErrorf(exitIncomplete, "not all QR codes in '%s' could be decoded", joinInputDir)
+		} else if !session.Complete && !decoded {
+			return exitErrorf(exitIncomplete, "session in '%s' is incomplete, re-run split to finish it", joinInputDir)
+		}
+
+		// Check if the data directory of the session exists, volumes hold their own
+		dataDir := session.DataDir()
+		if _, err := os.Stat(dataDir); dataDir == "" || (os.IsNotExist(err) && session.VolumeCount() == 0) {
+			return exitErrorf(exitUsage, "data directory '%s' does not exist", dataDir)
+		}
+
+		if session.Legacy && !decoded {
+			cmd.Printf("Reading '%s' as a legacy archive without a session file\n", joinInputDir)
 		}
 
 		// If an output file is not specified, use a default
@@ -64,26 +110,47 @@ and save it as output_file.txt.`,
 			joinOutputFile = baseName + "_reconstructed"
 		}
 
+		if joinOutputFile, err = safeOutputPath(joinOutputFile); err != nil {
+			return err
+		}
+
 		// Create an output directory if it doesn't exist
 		outputDir := filepath.Dir(joinOutputFile)
 		if outputDir != "." {
 			if err := os.MkdirAll(outputDir, 0755); err != nil {
-				cmd.Printf("Error creating output directory: %v\n", err)
-				os.Exit(1)
+				return fmt.Errorf("failed to create output directory: %w", err)
 			}
 		}
 
+		setResult("session", newSessionResult(session))
+
 		// Create QRFileTransfer instance
-		qrft := qrfiletransfer.NewQRFileTransfer()
+		qrft := newQRFileTransfer()
+		defer timePhase("reconstruct")()
+
+		// Restore a directory tree archived by split --recursive
+		if info, err := qrft.ReadFileInfo(inputDir); err == nil && info.Mode.IsDir() {
+			cmd.Printf("Joining QR codes from directory '%s' into directory '%s'...\n", joinInputDir, joinOutputFile)
+			if err := qrft.QRCodesToDirCtx(runCtx, inputDir, joinOutputFile); err != nil {
+				return fmt.Errorf("failed to join QR codes: %w", err)
+			}
+
+			setResult("output", newOutputResult(joinOutputFile))
+			cmd.Printf("Successfully joined QR codes into directory '%s'\n", joinOutputFile)
+
+			return nil
+		}
 
 		// Join the QR codes into a file
 		cmd.Printf("Joining QR codes from directory '%s' into file '%s'...\n", joinInputDir, joinOutputFile)
-		if err := qrft.QRCodesToFile(joinInputDir, joinOutputFile); err != nil {
-			cmd.Printf("Error joining QR codes: %v\n", err)
-			os.Exit(1)
+		if err := qrft.QRCodesToFileCtx(runCtx, inputDir, joinOutputFile); err != nil {
+			return fmt.Errorf("failed to join QR codes: %w", err)
 		}
 
+		setResult("output", newOutputResult(joinOutputFile))
 		cmd.Printf("Successfully joined QR codes into file '%s'\n", joinOutputFile)
+
+		return nil
 	},
 }
 
@@ -92,5 +159,82 @@ func init() {
 
 	// Add flags
 	joinCmd.Flags().StringVarP(&joinInputDir, "input", "i", "", "Input directory containing QR codes (required)")
-	joinCmd.Flags().StringVarP(&joinOutputFile, "output", "o", "", "Output file path (default: <dirname>_reconstructed)")
+	joinCmd.Flags().StringVarP(&joinOutputFile, "output", "o", "", "Output file path, or directory for a directory tree or batch (default: <dirname>_reconstructed)")
+	joinCmd.Flags().IntVarP(&joinConcurrency, "concurrency", "j", 0,
+		"Number of files of a batch reconstructed in parallel (default: number of CPUs)")
+
+	markDirFlags(joinCmd, "input")
+}
+
+// splitWithoutData reports whether the session in dir, or the sessions of the batch
+// in dir, were split with --no-data, leaving their file only in the QR code images
+func splitWithoutData(dir string) bool {
+	if qrfiletransfer.IsBatch(dir) {
+		ids, err := qrfiletransfer.BatchFileIDs(dir)
+		if err != nil || len(ids) == 0 {
+			return false
+		}
+
+		dir = filepath.Join(dir, ids[0])
+	}
+
+	session, err := qrfiletransfer.OpenSession(dir)
+
+	return err == nil && session.Settings.NoRawData
+}
+
+// hasParity reports whether the session in dir has parity chunks, see split --parity
+func hasParity(dir string) bool {
+	session, err := qrfiletransfer.OpenSession(dir)
+
+	return err == nil && len(session.Parity) > 0
+}
+
+// decodeJoinInput decodes the QR code images of the sessions in dir into a
+// temporary directory, the chunks of every file of a batch into the directory of
+// its ID, and returns it. The progress is printed to the output of cmd.
+func decodeJoinInput(cmd *cobra.Command, dir string) (string, error) {
+	sessionDirs := []string{dir}
+
+	if qrfiletransfer.IsBatch(dir) {
+		ids, err := qrfiletransfer.BatchFileIDs(dir)
+		if err != nil {
+			return "", err
+		}
+
+		sessionDirs = sessionDirs[:0]
+		for _, id := range ids {
+			sessionDirs = append(sessionDirs, filepath.Join(dir, id))
+		}
+	}
+
+	decodedDir, _, err := work.Create(osFs, workspace.Data, "qrcode_join_*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	for _, sessionDir := range sessionDirs {
+		session, err := qrfiletransfer.OpenSession(sessionDir)
+		if err != nil {
+			return "", err
+		}
+
+		for _, qrDir := range session.QRCodesDirs() {
+			// The chunks of the missing volumes of a session are restored from its
+			// parity when the file is joined
+			if _, err := os.Stat(qrDir); err != nil && session.VolumeCount() > 0 {
+				cmd.Printf("Skipping the missing volume %s\n", qrDir)
+
+				continue
+			}
+
+			cmd.Printf("Decoding the QR codes in %s, split without data files...\n", qrDir)
+
+			if err := readQRCodesFromFrames(qrDir, decodedDir, 0); err != nil {
+				return "", fmt.Errorf("failed to decode QR codes: %w", err)
+			}
+		}
+	}
+
+	return decodedDir, nil
 }
@@ -0,0 +1,98 @@
+//go:build !nodecode
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// execute runs the command line args with the flags of its command at their
+// defaults, as flag values persist between runs
+func execute(args ...string) error {
+	cmd, _, err := rootCmd.Find(args)
+	if err != nil {
+		return err
+	}
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			_ = slice.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+
+		f.Changed = false
+	})
+
+	rootCmd.SetArgs(args)
+
+	return rootCmd.Execute()
+}
+
+// splitAndJoin splits a file of size random bytes with the split flags, lets damage
+// remove some of the QR codes in the session directory, joins them back, and checks
+// that the file is restored
+func splitAndJoin(t *testing.T, size int, flags []string, damage func(t *testing.T, sessionDir string)) {
+	t.Helper()
+
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.bin")
+	sessionDir := filepath.Join(dir, "session")
+	outPath := filepath.Join(dir, "output.bin")
+
+	content := make([]byte, size)
+	if _, err := rand.Read(content); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(inPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The decoded chunks go into a directory of the test
+	work.SetBase(t.TempDir())
+
+	if err := execute(append([]string{"split", "-i", inPath, "-o", sessionDir}, flags...)...); err != nil {
+		t.Fatalf("split failed: %v", err)
+	}
+
+	damage(t, sessionDir)
+
+	if err := execute("join", "-i", sessionDir, "-o", outPath); err != nil {
+		t.Fatalf("join failed: %v (exit code %d)", err, exitCode(err))
+	}
+
+	if got, err := os.ReadFile(outPath); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("join restored %d bytes (%v), want %d", len(got), err, len(content))
+	}
+}
+
+func TestJoinNoDataMissingImage(t *testing.T) {
+	splitAndJoin(t, 6000, []string{"--no-data", "--parity", "20%"}, func(t *testing.T, sessionDir string) {
+		if err := os.Remove(filepath.Join(sessionDir, "qrcodes", "input_0001.png")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestJoinVolumeSubset(t *testing.T) {
+	splitAndJoin(t, 6000, []string{"--no-data", "--parity", "40%", "--volume-size", "2"}, func(t *testing.T, sessionDir string) {
+		if err := os.RemoveAll(filepath.Join(sessionDir, "vol001")); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestJoinNoDataRasterFormats(t *testing.T) {
+	for _, format := range []string{"jpeg", "bmp"} {
+		t.Run(format, func(t *testing.T) {
+			splitAndJoin(t, 3000, []string{"--no-data", "--format", format}, func(*testing.T, string) {})
+		})
+	}
+}
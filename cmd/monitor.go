@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/monitor"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// liveRedrawInterval bounds how often the live monitor is redrawn for new frames
+const liveRedrawInterval = 100 * time.Millisecond
+
+// liveView is the live decode monitor shown with --tui, nil without it. Its
+// methods do nothing on nil.
+var liveView *liveMonitor
+
+// liveMonitor draws a monitor.Monitor over the whole terminal, redrawn as frames
+// and chunks come in
+type liveMonitor struct {
+	mu     sync.Mutex
+	m      *monitor.Monitor
+	drawn  time.Time
+	color  bool
+	closed bool
+}
+
+// startLiveMonitor clears the terminal and shows the live monitor in liveView if
+// enabled. It falls back to the progress line if the output is not a terminal.
+func startLiveMonitor(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	if !isTerminal(os.Stdout) {
+		diag.Warnf(diagnostics.CodeOptionalOutput, "", "the output is not a terminal, --tui is ignored")
+
+		return
+	}
+
+	liveView = &liveMonitor{m: monitor.New(), color: os.Getenv("NO_COLOR") == ""}
+
+	// Clear the screen and hide the cursor, shown again by close
+	fmt.Print("\x1b[2J\x1b[?25l")
+	liveView.draw(true)
+}
+
+// frame counts a decoded frame
+func (l *liveMonitor) frame() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.m.Frame()
+	l.draw(false)
+}
+
+// duplicate counts a QR code of a chunk already received
+func (l *liveMonitor) duplicate() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.m.Duplicate()
+}
+
+// update shows the chunks of file in report, see scanCamera
+func (l *liveMonitor) update(file string, report *qrfiletransfer.ChunkReport) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.m.Update(file, report)
+	l.draw(true)
+}
+
+// refresh shows the chunks of file saved in the session directory dir, none
+// before the directory holds any
+func (l *liveMonitor) refresh(dir, file string) {
+	if l == nil {
+		return
+	}
+
+	report, err := newQRFileTransfer().VerifyChunks(filepath.Join(dir, file))
+	if err != nil {
+		report = &qrfiletransfer.ChunkReport{}
+	}
+
+	l.update(file, report)
+}
+
+// close draws the monitor a last time and shows the cursor again
+func (l *liveMonitor) close() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return
+	}
+
+	l.draw(true)
+	l.closed = true
+
+	fmt.Print("\x1b[?25h")
+}
+
+// draw redraws the monitor from the top of the terminal, at most every
+// liveRedrawInterval unless force is set. l.mu must be held.
+func (l *liveMonitor) draw(force bool) {
+	if l.closed || (!force && time.Since(l.drawn) < liveRedrawInterval) {
+		return
+	}
+
+	l.drawn = time.Now()
+
+	// Clear the rest of every line and what is left below of the previous view
+	view := strings.ReplaceAll(l.m.Render(terminalWidth(), l.color), "\n", "\x1b[K\n")
+	fmt.Print("\x1b[H" + view + "\x1b[J")
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// terminalWidth returns the width of the terminal from $COLUMNS, 80 if it is not
+// exported
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	return 80
+}
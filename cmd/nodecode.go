@@ -0,0 +1,28 @@
+//go:build nodecode
+
+package cmd
+
+import (
+	"errors"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// errNoDecode is returned by the decoding features of a binary built with the
+// nodecode tag
+var errNoDecode = errors.New("this binary is built without QR code decoding (nodecode build tag)")
+
+// readQRCodeFromImage always fails, QR codes are not decoded by this build
+func readQRCodeFromImage(string) ([]byte, error) {
+	return nil, errNoDecode
+}
+
+// readQRCodesFromFrames always fails, QR codes are not decoded by this build
+func readQRCodesFromFrames(string, string, float64) error {
+	return errNoDecode
+}
+
+// runBenchRoundTrip always fails, QR codes are not decoded by this build
+func runBenchRoundTrip(*qrfiletransfer.QRFileTransfer, string, string) (float64, bool, error) {
+	return 0, false, errNoDecode
+}
@@ -0,0 +1,38 @@
+//go:build novideo
+
+package cmd
+
+import (
+	"errors"
+	"image"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/video"
+)
+
+// errNoVideo is returned by the video features of a binary built with the novideo tag
+var errNoVideo = withExitCode(exitFFmpegMissing, errors.New("this binary is built without video support (novideo build tag), use --format gif or --sequence to generate, or --backend native to read, instead"))
+
+// checkFFmpegInstalled always fails, ffmpeg is not used by this build
+func checkFFmpegInstalled() error {
+	return errNoVideo
+}
+
+// generateQRCodeVideo always fails, ffmpeg is not used by this build
+func generateQRCodeVideo([]string, string, videoOptions) error {
+	return errNoVideo
+}
+
+// streamFramesFromVideo always fails, ffmpeg is not used by this build
+func streamFramesFromVideo(string, video.Sampling, func(image.Image) error) error {
+	return errNoVideo
+}
+
+// probeFrameRate always fails, ffprobe is not used by this build
+func probeFrameRate(string) (float64, error) {
+	return 0, errNoVideo
+}
+
+// extractStreamInfo always fails, ffmpeg is not used by this build
+func extractStreamInfo(string) ([]byte, error) {
+	return nil, errNoVideo
+}
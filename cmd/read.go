@@ -1,28 +1,59 @@
+//go:build !nodecode
+
 package cmd
 
 import (
-	"encoding/base64"
-	"encoding/hex"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/jpeg"
-	_ "image/png"
+	"image/png"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/frames"
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
-	"github.com/makiuchi-d/gozxing"
-	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/dyammarcano/qrfiletransfer/pkg/video"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
 var (
-	readInputVideo string
-	readOutputFile string
-	readTempDir    string
-	readKeepFrames bool
+	readInputVideo  string
+	readOutputFile  string
+	readTempDir     string
+	readStateDir    string
+	readBackend     string
+	readConcurrency int
+
+	readCluster          bool
+	readClusterThreshold float64
+	readLensProfile      string
+	readFrameBudget      time.Duration
+	readEnhance          string
+	readReport           string
+	readTUI              bool
+
+	readSampleFPS float64
+	readStart     string
+	readEnd       string
+	readEveryNth  int
+)
+
+const (
+	// slowMotionFrameRate is the frame rate from which a recording is treated as a
+	// slow-motion capture and its frames are clustered automatically
+	slowMotionFrameRate = 100
+
+	// clusterAttempts is the number of frames of a cluster tried, sharpest first,
+	// before the cluster is given up
+	clusterAttempts = 3
 )
 
 var readCmd = &cobra.Command{
@@ -33,22 +64,43 @@ var readCmd = &cobra.Command{
 Example:
   qrfiletransfer read -i qrcodes_video.mp4 -o reconstructed_file.txt
 
-This will extract frames from the video, read QR codes from the frames,
-and reconstruct the original file.`,
-	Run: func(cmd *cobra.Command, args []string) {
+This will decode the frames of the video, read QR codes from the frames,
+and reconstruct the original file. The frames are decoded in memory, --keep all
+also writes them to disk and keeps them.
+
+Frames are extracted with ffmpeg if it is installed. Without it, a built-in
+decoder reads a directory of images, a raw YUV4MPEG2 (.y4m) stream, and Motion
+JPEG, raw or in an AVI or MOV container. --backend selects one explicitly:
+  qrfiletransfer read -i capture.y4m --backend native -o file.txt
+
+--start and --end read only part of a long recording, and --every-nth N and
+--fps-sample downsample it, e.g. 60fps phone footage of a 5fps slideshow:
+  qrfiletransfer read -i phone.mp4 --start 0:12 --end 2:40 --fps-sample 10 -o file.txt
+
+With --state, decoded chunks are kept in the given directory across runs. If
+some chunks could not be read, the missing chunk indices are reported and a
+later run with the same --state (e.g. on a re-recording of only the missing
+QR codes) completes the file:
+  qrfiletransfer read -i take1.mp4 -s decode_state -o file.txt
+  qrfiletransfer read -i take2.mp4 -s decode_state -o file.txt
+
+QR codes of several files, created with split on more than one input, are
+sorted out by the file ID in each chunk and every complete file is written into
+the output path, which is used as a directory.
+
+The frames of other protocols, written by split --protocol or the apps and
+tools speaking them, such as the parts of a BC-UR, txqr frames, or QR codes
+linked by structured append, are recognized by themselves and reassembled as a
+whole, in any order.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Validate input video
 		if readInputVideo == "" {
-			fmt.Println("Error: input video is required")
-			if err := cmd.Help(); err != nil {
-				fmt.Printf("Error displaying help: %v\n", err)
-			}
-			os.Exit(1)
+			return usageErrorf("input video is required")
 		}
 
 		// Check if the input video exists
 		if _, err := os.Stat(readInputVideo); os.IsNotExist(err) {
-			fmt.Printf("Error: input video '%s' does not exist\n", readInputVideo)
-			os.Exit(1)
+			return exitErrorf(exitUsage, "input video '%s' does not exist", readInputVideo)
 		}
 
 		// If an output file is not specified, use a default
@@ -60,117 +112,204 @@ and reconstruct the original file.`,
 			readOutputFile = baseName + "_reconstructed"
 		}
 
+		var err error
+		if readOutputFile, err = safeOutputPath(readOutputFile); err != nil {
+			return err
+		}
+
 		// Create an output directory if it doesn't exist
 		outputDir := filepath.Dir(readOutputFile)
 		if outputDir != "." {
 			if err := os.MkdirAll(outputDir, 0755); err != nil {
-				fmt.Printf("Error creating output directory: %v\n", err)
-				os.Exit(1)
+				return fmt.Errorf("failed to create output directory: %w", err)
 			}
 		}
 
-		// If a temp directory is not specified, create a temporary one
-		if readTempDir == "" {
-			var err error
-			readTempDir, err = os.MkdirTemp("", "qrcode_frames_*")
+		// The intermediate directories are created in the temp directory, if given
+		work.SetBase(readTempDir)
+
+		if err := loadLensProfile(readLensProfile); err != nil {
+			return err
+		}
+
+		if err := loadEnhancement(readEnhance); err != nil {
+			return err
+		}
+
+		sweepBudget = readFrameBudget
+
+		fmt.Printf("Reading QR codes from video '%s'...\n", readInputVideo)
+
+		// Videos made by generate describe their file in a subtitle track
+		streamInfo := readStreamInfo(readInputVideo)
+		if streamInfo != nil {
+			fmt.Printf("Stream info: %s, SHA-256 %s\n", streamInfo, streamInfo.Hash)
+		}
+
+		// Frames are decoded in memory, and only written to disk to be kept
+		keepFrames := work.Keeps(workspace.Frames)
+
+		var framesDir string
+		if keepFrames {
+			dir, _, err := work.Create(osFs, workspace.Frames, "qrcode_frames_*")
 			if err != nil {
-				fmt.Printf("Error creating temporary directory: %v\n", err)
-				os.Exit(1)
-			}
-			// Clean up the temporary directory if not keeping frames
-			if !readKeepFrames {
-				defer func() {
-					if err := os.RemoveAll(readTempDir); err != nil {
-						fmt.Printf("Warning: failed to remove temporary directory: %v\n", err)
-					}
-				}()
-			}
-		} else {
-			// Create the specified temp directory if it doesn't exist
-			if err := os.MkdirAll(readTempDir, 0755); err != nil {
-				fmt.Printf("Error creating temporary directory: %v\n", err)
-				os.Exit(1)
+				return fmt.Errorf("failed to create frames directory: %w", err)
 			}
+
+			framesDir = dir
 		}
 
-		// Check if ffmpeg is installed
-		if err := checkFFmpegInstalled(); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		sampling, err := readSampling()
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("Reading QR codes from video '%s'...\n", readInputVideo)
+		backend, err := frameBackend(readInputVideo)
+		if err != nil {
+			return err
+		}
+
+		frameRate := inputFrameRate(readInputVideo, backend, sampling)
 
-		// Extract frames from the video
-		framesDir := filepath.Join(readTempDir, "frames")
-		if err := os.MkdirAll(framesDir, 0755); err != nil {
-			fmt.Printf("Error creating frames directory: %v\n", err)
-			os.Exit(1)
+		// Decoded chunks go into the state directory if one is given, so they
+		// survive this run and only the missing ones need to be scanned again.
+		// Otherwise they are kept by --keep data.
+		sessionDir := readStateDir
+		if sessionDir == "" {
+			var releaseSession func() error
+
+			sessionDir, releaseSession, err = work.Create(osFs, workspace.Data, "qrcode_chunks_*")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary directory: %w", err)
+			}
+
+			defer func() {
+				if err := releaseSession(); err != nil {
+					diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary directory: %v", err)
+				}
+			}()
 		}
 
-		if err := extractFramesFromVideo(readInputVideo, framesDir); err != nil {
-			fmt.Printf("Error extracting frames: %v\n", err)
-			os.Exit(1)
+		// Create QRFileTransfer instance
+		qrft := newQRFileTransfer()
+		if readConcurrency > 0 {
+			qrft.SetConcurrency(readConcurrency)
 		}
 
-		// Create directories for QR code data
-		qrcodesDir := filepath.Join(readTempDir, "qrcodes")
-		if err := os.MkdirAll(qrcodesDir, 0755); err != nil {
-			fmt.Printf("Error creating QR codes directory: %v\n", err)
-			os.Exit(1)
+		// Chunks decoded by a previous run into the state directory must be those of
+		// the stream, checked before any frame is decoded
+		if streamInfo != nil {
+			if err := checkStreamChunks(qrft, streamInfo, sessionDir); err != nil {
+				return err
+			}
 		}
 
-		dataDir := filepath.Join(readTempDir, "data")
-		if err := os.MkdirAll(dataDir, 0755); err != nil {
-			fmt.Printf("Error creating data directory: %v\n", err)
-			os.Exit(1)
+		// Slow-motion recordings show every QR code in a burst of near-identical
+		// frames, so only the sharpest frames of each burst are decoded
+		cluster := readCluster
+		if !cmd.Flags().Changed("cluster") {
+			if frameRate >= slowMotionFrameRate {
+				fmt.Printf("Detected a %.0ffps recording, clustering near-duplicate frames\n", frameRate)
+				cluster = true
+			}
 		}
 
-		// Read QR codes from frames
-		if err := readQRCodesFromFrames(framesDir, dataDir); err != nil {
-			fmt.Printf("Error reading QR codes: %v\n", err)
-			os.Exit(1)
+		threshold := 0.0
+		if cluster {
+			threshold = readClusterThreshold
 		}
 
-		// Copy the extracted frames to the qrcodes directory for reference
-		// This is not strictly necessary for reconstruction but helps with debugging
-		if readKeepFrames {
-			fmt.Println("Copying extracted frames to qrcodes directory for reference...")
-			frames, err := filepath.Glob(filepath.Join(framesDir, "*.png"))
-			if err != nil {
-				fmt.Printf("Warning: failed to list frames for copying: %v\n", err)
-			} else if len(frames) > 0 {
-				copiedFrames := 0
-				for i, frame := range frames {
-					// Only copy a reasonable number of frames to avoid excessive disk usage
-					if i >= 10 {
-						break
-					}
-					destPath := filepath.Join(qrcodesDir, filepath.Base(frame))
-					if err := copyFile(frame, destPath); err != nil {
-						fmt.Printf("Warning: failed to copy frame %s: %v\n", frame, err)
-					} else {
-						copiedFrames++
+		// Read QR codes from the frames as they are decoded
+		stream := func(fn func(img image.Image) error) (*video.Info, error) {
+			var count int
+
+			return streamFrames(readInputVideo, backend, sampling, func(img image.Image) error {
+				count++
+
+				if keepFrames {
+					if err := writeFrame(filepath.Join(framesDir, fmt.Sprintf(video.FramePattern, count)), img); err != nil {
+						return err
 					}
 				}
-				fmt.Printf("Copied %d frames to %s for reference\n", copiedFrames, qrcodesDir)
+
+				return fn(img)
+			})
+		}
+
+		// The report is written even if no chunk was found, to tell why
+		var frameReport *frames.Report
+		if readReport != "" {
+			frameReport = frames.NewReport()
+		}
+
+		startLiveMonitor(readTUI)
+		liveView.refresh(sessionDir, "")
+
+		stopDecode := timePhase("decode")
+		err = readQRCodesFromStream(stream, sessionDir, threshold, frameReport)
+		stopDecode()
+
+		if frameReport != nil {
+			if reportErr := frameReport.WriteFile(readReport); reportErr != nil {
+				return reportErr
 			}
+
+			fmt.Printf("Frame report (%s) written to %s\n", frameReport.Summary(), readReport)
 		}
 
-		// Create QRFileTransfer instance
-		qrft := qrfiletransfer.NewQRFileTransfer()
+		if err != nil {
+			return fmt.Errorf("failed to read QR codes: %w", err)
+		}
+
+		// QR codes of several files are reconstructed into the output directory
+		if qrfiletransfer.IsBatch(sessionDir) {
+			err := reconstructBatch(qrft, sessionDir, readOutputFile)
+			if exitCode(err) == exitIncomplete {
+				return partialReadError(sessionDir, "files")
+			}
+
+			return err
+		}
+
+		// Check that every chunk has been decoded before reconstructing
+		report, err := qrft.VerifyChunks(sessionDir)
+		if err != nil {
+			return fmt.Errorf("failed to verify chunks: %w", err)
+		}
+
+		fmt.Printf("Chunks: %s\n", report)
+		setResult("chunks", newChunksResult(report))
+
+		if streamInfo != nil {
+			if report.Total > 0 && report.Total != streamInfo.Chunks {
+				return fmt.Errorf("the QR codes hold %d chunks, the stream info describes %d", report.Total, streamInfo.Chunks)
+			}
+
+			if err := checkStreamChunks(qrft, streamInfo, sessionDir); err != nil {
+				return err
+			}
+		}
+
+		if !report.Complete() {
+			return partialReadError(sessionDir, "chunks")
+		}
 
 		// Reconstruct the file from QR codes
 		fmt.Printf("Reconstructing file from QR codes...\n")
-		if err := qrft.QRCodesToFile(readTempDir, readOutputFile); err != nil {
-			fmt.Printf("Error reconstructing file: %v\n", err)
-			os.Exit(1)
+		stopReconstruct := timePhase("reconstruct")
+		if err := qrft.QRCodesToFileCtx(runCtx, sessionDir, readOutputFile); err != nil {
+			return fmt.Errorf("failed to reconstruct file: %w", err)
 		}
+		stopReconstruct()
 
-		fmt.Printf("Successfully reconstructed file: %s\n", readOutputFile)
-		if readKeepFrames {
-			fmt.Printf("Extracted frames and intermediate files are kept in: %s\n", readTempDir)
+		if streamInfo != nil {
+			checkStreamInfo(streamInfo, readOutputFile)
 		}
+
+		setResult("output", newOutputResult(readOutputFile))
+		fmt.Printf("Successfully reconstructed file: %s\n", readOutputFile)
+
+		return nil
 	},
 }
 
@@ -179,165 +318,641 @@ func init() {
 
 	// Add flags
 	readCmd.Flags().StringVarP(&readInputVideo, "input", "i", "",
-		"Input video file containing QR codes (required)")
+		"Input video file, or directory of images, containing QR codes (required)")
 	readCmd.Flags().StringVarP(&readOutputFile, "output", "o", "",
 		"Output file path (default: <videoname>_reconstructed)")
 	readCmd.Flags().StringVarP(&readTempDir, "temp", "t", "",
-		"Temporary directory for extracted frames (default: system temp)")
-	readCmd.Flags().BoolVarP(&readKeepFrames, "keep", "k", false,
-		"Keep extracted frames and intermediate files")
+		"Directory the decoded chunks, and the frames kept by --keep all, are written into (default: system temp)")
+	readCmd.Flags().StringVarP(&readStateDir, "state", "s", "",
+		"Directory keeping decoded chunks across runs to resume a partial decode")
+	readCmd.Flags().BoolVar(&readCluster, "cluster", false,
+		"Decode only the sharpest frames of each burst of near-duplicate frames (default: on for recordings of 100fps and more)")
+	readCmd.Flags().Float64Var(&readClusterThreshold, "cluster-threshold", frames.DefaultClusterThreshold,
+		"Mean grey level difference (0-255) up to which consecutive frames belong to the same burst")
+	readCmd.Flags().StringVar(&readLensProfile, "lens", "",
+		"Camera calibration profile (JSON with k1/k2 distortion and crop) applied to frames before decoding")
+	readCmd.Flags().StringVar(&readBackend, "backend", "auto",
+		"Frame extraction: ffmpeg, native for the built-in y4m, Motion JPEG, and image directory decoder, or auto for ffmpeg if installed")
+	readCmd.Flags().IntVarP(&readConcurrency, "concurrency", "j", 0,
+		"Number of files of a batch reconstructed in parallel (default: number of CPUs)")
+	readCmd.Flags().Float64Var(&readSampleFPS, "fps-sample", 0,
+		"Extract at most this many frames per second, e.g. 10 for 60fps footage of a slow slideshow (default: every frame)")
+	readCmd.Flags().StringVar(&readStart, "start", "",
+		"Skip the recording before this time, in seconds, [hh:]mm:ss, or a duration such as 1m30s")
+	readCmd.Flags().StringVar(&readEnd, "end", "",
+		"Stop reading the recording at this time, in seconds, [hh:]mm:ss, or a duration such as 1m30s")
+	readCmd.Flags().IntVar(&readEveryNth, "every-nth", 0,
+		"Extract one frame out of every N (default: every frame)")
+	readCmd.Flags().DurationVar(&readFrameBudget, "frame-budget", 500*time.Millisecond,
+		"Time spent per failed frame retrying it with contrast, threshold, sharpening, and scale variants (0 disables retries)")
+	readCmd.Flags().StringVar(&readReport, "report", "",
+		"Write the outcome of decoding every frame to this file, as CSV if it ends in .csv and JSON otherwise")
+	readCmd.Flags().BoolVar(&readTUI, "tui", false,
+		"Show a live monitor of the chunks received, with the percentage, estimated time remaining, and duplicates")
+	readCmd.Flags().StringVar(&readEnhance, "enhance", "",
+		"Preprocess every frame for difficult captures: grayscale, perspective, sharpen, adaptive, multiscale, or all, comma-separated")
+
+	_ = readCmd.RegisterFlagCompletionFunc("backend", completeValues("auto", "native", "ffmpeg"))
+	_ = readCmd.RegisterFlagCompletionFunc("enhance", completeList(enhancementSteps...))
+	_ = readCmd.MarkFlagFilename("lens", "json")
+	markDirFlags(readCmd, "temp", "state")
 }
 
-// extractFramesFromVideo extracts frames from a video using ffmpeg.
-func extractFramesFromVideo(videoPath, outputDir string) error {
-	// Build the ffmpeg command to extract frames
-	cmd := exec.Command(
-		"ffmpeg",
-		"-i", videoPath,
-		"-vsync", "0",
-		"-q:v", "2", // High quality
-		filepath.Join(outputDir, "frame_%04d.png"),
-	)
+// readSampling returns the frames of the recording to extract given by --start,
+// --end, --every-nth, and --fps-sample
+func readSampling() (video.Sampling, error) {
+	sampling := video.Sampling{FPS: readSampleFPS, EveryNth: readEveryNth}
 
-	// Capture command output
-	output, err := cmd.CombinedOutput()
+	var err error
+
+	if readStart != "" {
+		if sampling.Start, err = video.ParseTimestamp(readStart); err != nil {
+			return sampling, exitErrorf(exitUsage, "invalid --start: %w", err)
+		}
+	}
+
+	if readEnd != "" {
+		if sampling.End, err = video.ParseTimestamp(readEnd); err != nil {
+			return sampling, exitErrorf(exitUsage, "invalid --end: %w", err)
+		}
+	}
+
+	return sampling, withExitCode(exitUsage, sampling.Validate())
+}
+
+// frameBackend returns the frame extraction backend chosen by --backend for input.
+// The auto backend uses ffmpeg when it is installed, except for a directory of
+// images.
+func frameBackend(input string) (string, error) {
+	switch readBackend {
+	case "auto":
+		if info, err := os.Stat(input); (err == nil && info.IsDir()) || checkFFmpegInstalled() != nil {
+			return "native", nil
+		}
+
+		return "ffmpeg", nil
+	case "ffmpeg":
+		return readBackend, checkFFmpegInstalled()
+	case "native":
+		return readBackend, nil
+	default:
+		return "", exitErrorf(exitUsage, "unknown backend %q (expected auto, ffmpeg or native)", readBackend)
+	}
+}
+
+// inputFrameRate returns the rate of the frames of input selected by sampling, 0
+// if it is unknown
+func inputFrameRate(input, backend string, sampling video.Sampling) float64 {
+	var frameRate float64
+
+	if backend == "ffmpeg" {
+		frameRate, _ = probeFrameRate(input)
+	} else if info, err := video.Probe(input); err == nil {
+		frameRate = info.FrameRate
+	}
+
+	return sampling.FrameRate(frameRate)
+}
+
+// streamFrames decodes the frames of input selected by sampling with backend, see
+// frameBackend, and passes them to fn in order without writing them to disk. It
+// returns the frames decoded by the built-in decoder, nil for ffmpeg.
+func streamFrames(input, backend string, sampling video.Sampling, fn func(img image.Image) error) (*video.Info, error) {
+	if backend == "ffmpeg" {
+		return nil, streamFramesFromVideo(input, sampling, fn)
+	}
+
+	info, err := video.StreamFrames(input, sampling, fn)
+	if errors.Is(err, video.ErrUnsupported) && readBackend == "auto" {
+		return nil, fmt.Errorf("%w, install ffmpeg to read other video formats", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if info.Skipped > 0 {
+		diag.Warnf(diagnostics.CodeSkippedFrame, input, "%d frames could not be decoded and were skipped", info.Skipped)
+	}
+
+	return info, nil
+}
+
+// writeFrame writes a decoded frame as a PNG file at path
+func writeFrame(path string, img image.Image) error {
+	file, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("ffmpeg command failed: %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to create frame: %w", err)
+	}
+
+	err = png.Encode(file, img)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write frame %s: %w", path, err)
 	}
 
 	return nil
 }
 
-// readQRCodesFromFrames reads QR codes from image frames and saves the data.
-func readQRCodesFromFrames(framesDir, dataDir string) error {
-	// Get all PNG files in the frames directory
-	frames, err := filepath.Glob(filepath.Join(framesDir, "*.png"))
+// readStreamInfo returns the stream info of the subtitle track of input, nil if it
+// has none or is not a video read with ffmpeg
+func readStreamInfo(input string) *qrfiletransfer.StreamInfo {
+	if info, err := os.Stat(input); err != nil || info.IsDir() || readBackend == "native" || checkFFmpegInstalled() != nil {
+		return nil
+	}
+
+	content, err := extractStreamInfo(input)
+	if err != nil {
+		return nil
+	}
+
+	streamInfo, err := qrfiletransfer.ParseStreamInfo(content)
+	if errors.Is(err, qrfiletransfer.ErrUnsupportedVersion) {
+		diag.Warnf(diagnostics.CodeOptionalOutput, input, "ignoring the stream info: %v", err)
+	}
+
+	return streamInfo
+}
+
+// checkStreamChunks fails if the chunks decoded into sessionDir describe another
+// file than streamInfo, by name, number of chunks, or SHA-256. Chunks without the
+// first one, which holds the metadata, cannot be checked yet.
+func checkStreamChunks(qrft *qrfiletransfer.QRFileTransfer, streamInfo *qrfiletransfer.StreamInfo, sessionDir string) error {
+	info, err := qrft.ReadFileInfo(sessionDir)
+	if err != nil {
+		return nil
+	}
+
+	if info.Name != streamInfo.Name {
+		return fmt.Errorf("the chunks in %s are of file %s, the stream info describes %s", sessionDir, info.Name, streamInfo.Name)
+	}
+
+	if info.Total != streamInfo.Chunks {
+		return fmt.Errorf("the chunks in %s are of a file of %d chunks, the stream info describes %d", sessionDir, info.Total, streamInfo.Chunks)
+	}
+
+	if hash := fmt.Sprintf("%x", info.Sum()); info.HashAlgorithm == split.HashSHA256 && hash != streamInfo.Hash {
+		return fmt.Errorf("%w: the chunks in %s are of a file with SHA-256 %s, the stream info describes %s", qrfiletransfer.ErrHashMismatch, sessionDir, hash, streamInfo.Hash)
+	}
+
+	return nil
+}
+
+// checkStreamInfo warns if the file reconstructed at path is not the one described
+// by streamInfo
+func checkStreamInfo(streamInfo *qrfiletransfer.StreamInfo, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, path, "failed to check the file against the stream info: %v", err)
+
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, path, "failed to check the file against the stream info: %v", err)
+
+		return
+	}
+
+	if hash := fmt.Sprintf("%x", h.Sum(nil)); hash != streamInfo.Hash {
+		diag.Warnf(diagnostics.CodeStreamMismatch, path, "the file has SHA-256 %s, the stream info %s", hash, streamInfo.Hash)
+	}
+}
+
+// groupFrames returns the frames to decode grouped by the QR code they show.
+// Without clustering (threshold 0) every frame is a group of its own. Otherwise
+// bursts of consecutive near-duplicate frames form a group whose sharpest frames
+// are tried first.
+func groupFrames(framePaths []string, threshold float64) ([][]string, error) {
+	if threshold <= 0 {
+		groups := make([][]string, len(framePaths))
+		for i, path := range framePaths {
+			groups[i] = []string{path}
+		}
+
+		return groups, nil
+	}
+
+	analyzed := make([]frames.Frame, 0, len(framePaths))
+
+	for _, path := range framePaths {
+		frame, err := frames.Analyze(path)
+		if err != nil {
+			diag.Warnf(diagnostics.CodeSkippedFrame, path, "failed to analyze frame: %v", err)
+
+			continue
+		}
+
+		analyzed = append(analyzed, frame)
+	}
+
+	clusters := frames.ClusterFrames(analyzed, threshold)
+	groups := make([][]string, 0, len(clusters))
+
+	for _, c := range clusters {
+		var group []string
+		for _, frame := range c.Candidates(clusterAttempts) {
+			group = append(group, frame.Path)
+		}
+
+		groups = append(groups, group)
+	}
+
+	fmt.Printf("Clustered %d frames into %d bursts\n", len(framePaths), len(groups))
+
+	return groups, nil
+}
+
+// frameImageExts are the extensions of the images read as frames, those of every
+// raster format split writes QR codes in
+var frameImageExts = []string{".png", ".jpg", ".jpeg", ".bmp", ".gif"}
+
+// globImages returns the paths of the images in dir in name order, see
+// frameImageExts
+func globImages(dir string) ([]string, error) {
+	var paths []string
+
+	for _, ext := range frameImageExts {
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// readQRCodesFromFrames reads QR codes from image frames and saves the data of every
+// chunk into sessionDir, see qrfiletransfer.ChunkDataPath.
+// A clusterThreshold above 0 enables clustering of near-duplicate frames, see groupFrames.
+func readQRCodesFromFrames(framesDir, sessionDir string, clusterThreshold float64) error {
+	// Get all image files in the frames directory
+	framePaths, err := globImages(framesDir)
 	if err != nil {
 		return fmt.Errorf("failed to list frame files: %w", err)
 	}
 
-	if len(frames) == 0 {
+	if len(framePaths) == 0 {
 		return fmt.Errorf("no frames found in %s", framesDir)
 	}
 
-	fmt.Printf("Processing %d frames...\n", len(frames))
+	fmt.Printf("Processing %d frames...\n", len(framePaths))
+
+	groups, err := groupFrames(framePaths, clusterThreshold)
+	if err != nil {
+		return err
+	}
 
-	// Track successfully processed frames and unique chunks
-	processedFrames := 0
-	processedChunks := make(map[string]bool)
+	c := newChunkCollector(sessionDir)
 
-	// Process each frame
-	for i, framePath := range frames {
-		// Read QR code from the frame
-		data, err := readQRCodeFromImage(framePath)
+	// Process each group, stopping at the first frame of a group that decodes
+	for i, group := range groups {
+		if err := runCtx.Err(); err != nil {
+			return err
+		}
+
+		payloads, framePath, err := readPayloadsFromGroup(group)
 		if err != nil {
-			// Just log the error and continue with the next frame
-			fmt.Printf("Warning: failed to read QR code from frame %s: %v\n", framePath, err)
+			// Just record the error and continue with the next group
+			diag.Warnf(diagnostics.CodeSkippedFrame, "", "%v", err)
 
 			continue
 		}
 
-		// Generate a simple hash of the data to detect duplicates
-		// This is a simple approach - in a production system, you might want to use a more robust method
-		dataHash := hex.EncodeToString(data[:minV(len(data), 20)])
+		if err := c.collect(framePath, payloads); err != nil {
+			return err
+		}
+
+		fmt.Printf("Processed frame %d/%d (found %d unique QR codes)\r", i+1, len(groups), c.processed)
+	}
+	fmt.Println() // Print a newline after the progress indicator
 
-		// Skip if we've already processed this chunk (duplicate frame)
-		if processedChunks[dataHash] {
-			fmt.Printf("Info: skipping duplicate QR code in frame %s\n", framePath)
+	return c.finish(len(framePaths))
+}
 
-			continue
+// readQRCodesFromStream is readQRCodesFromFrames for the frames that stream passes
+// to its function as they are decoded, which are named "frame N" by their number
+// from 1, see streamFrames. Only the bursts of near-duplicate frames being
+// clustered are held in memory. The outcome of every frame is recorded in report,
+// which may be nil.
+func readQRCodesFromStream(stream func(fn func(img image.Image) error) (*video.Info, error), sessionDir string, clusterThreshold float64, report *frames.Report) error {
+	c := newChunkCollector(sessionDir)
+	c.report = report
+
+	var (
+		count, groups int
+		clusterer     *frames.Clusterer
+	)
+
+	if clusterThreshold > 0 {
+		clusterer = frames.NewClusterer(clusterThreshold, clusterAttempts)
+	}
+
+	// decode collects the chunks of the first frame of a group that decodes
+	decode := func(group []frames.Frame) error {
+		groups++
+
+		images := make(map[string]image.Image, len(group))
+		names := make([]string, 0, len(group))
+
+		for _, frame := range group {
+			images[frame.Path] = frame.Image
+			names = append(names, frame.Path)
 		}
 
-		// Mark this chunk as processed
-		processedChunks[dataHash] = true
+		payloads, name, err := firstPayloads(names, func(name string) ([][]byte, error) {
+			start := time.Now()
+			contents, err := decodeFrame(images[name])
+			report.Attempted(name, time.Since(start), err)
 
-		// Save the data to a file
-		dataFilePath := filepath.Join(dataDir, fmt.Sprintf("chunk_%04d.dat", processedFrames))
-		if err := os.WriteFile(dataFilePath, data, 0644); err != nil {
-			return fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+			return contents, err
+		})
+		if err != nil {
+			diag.Warnf(diagnostics.CodeSkippedFrame, "", "%v", err)
+
+			return nil
 		}
 
-		processedFrames++
-		fmt.Printf("Processed frame %d/%d (found %d unique QR codes)\r", i+1, len(frames), processedFrames)
+		if err := c.collect(name, payloads); err != nil {
+			return err
+		}
+
+		if liveView == nil {
+			fmt.Printf("Processed frame %d (found %d unique QR codes)\r", count, c.processed)
+		}
+
+		return nil
+	}
+
+	info, err := stream(func(img image.Image) error {
+		if err := runCtx.Err(); err != nil {
+			return err
+		}
+
+		count++
+		name := fmt.Sprintf("frame %d", count)
+		report.Add(name)
+		liveView.frame()
+
+		if clusterer == nil {
+			return decode([]frames.Frame{{Path: name, Image: img}})
+		}
+
+		if cluster := clusterer.Add(frames.AnalyzeImage(name, img)); cluster != nil {
+			return decode(cluster.Candidates(clusterAttempts))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to decode frames: %w", err)
+	}
+
+	if clusterer != nil {
+		if cluster := clusterer.Flush(); cluster != nil {
+			if err := decode(cluster.Candidates(clusterAttempts)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Leave the last view of the live monitor above the summary
+	liveView.close()
+
+	if clusterer != nil {
+		fmt.Printf("\nClustered %d frames into %d bursts", count, groups)
 	}
 	fmt.Println() // Print a newline after the progress indicator
 
-	if processedFrames == 0 {
-		return fmt.Errorf("no valid QR codes found in any frames")
+	if info != nil {
+		fmt.Printf("Decoded %d frames (%s) with the built-in decoder\n", info.Frames, info.Format)
+		if info.Dropped > 0 {
+			fmt.Printf("Left out %d frames outside the sampling\n", info.Dropped)
+		}
 	}
 
-	fmt.Printf("Successfully extracted %d unique QR codes from %d frames\n", processedFrames, len(frames))
+	return c.finish(count)
+}
 
-	return nil
+// chunkCollector saves the chunks decoded from the frames of a recording into a
+// session directory
+type chunkCollector struct {
+	sessionDir string
+	// processed counts the chunks saved, known those decoded by a previous run
+	processed int
+	known     int
+	scans     chunkScans
+	skips     *fileSkipDetectors
+	// report records the chunks of every frame, if not nil
+	report *frames.Report
 }
 
-// minV returns the minimum of two integers.
-func minV(a, b int) int {
-	if a < b {
-		return a
+// newChunkCollector returns a chunkCollector saving chunks into sessionDir
+func newChunkCollector(sessionDir string) *chunkCollector {
+	return &chunkCollector{
+		sessionDir: sessionDir,
+		scans:      newChunkScans(),
+		// Flag chunks skipped between two decoded frames, if the sender embedded
+		// hints. Chunks decoded by a previous run into the same directory are not
+		// flagged.
+		skips: newFileSkipDetectors(sessionDir),
 	}
+}
 
-	return b
+// collect saves the chunk payloads decoded from a frame, a frame may tile several
+// QR codes
+func (c *chunkCollector) collect(framePath string, payloads []*qrfiletransfer.ChunkPayload) error {
+	var chunks []string
+
+	processed := c.processed
+
+	defer func() {
+		c.report.Decoded(framePath, chunks, c.processed-processed)
+	}()
+
+	for _, payload := range payloads {
+		dataFilePath, err := qrfiletransfer.ChunkDataPath(c.sessionDir, payload)
+		if err != nil {
+			diag.Warnf(diagnostics.CodeInvalidChunk, framePath, "%v", err)
+
+			continue
+		}
+
+		chunks = append(chunks, payload.Name)
+
+		if index, ok := payload.Index(); ok {
+			if skipped := c.skips.observe(payload.File, index, payload.Next); len(skipped) > 0 {
+				diag.Infof(diagnostics.CodeSkippedChunks, framePath, "chunks %s%s were skipped before this frame", qrfiletransfer.FormatIndexRanges(skipped), fileLabel(payload.File))
+			}
+		}
+
+		data := payload.Data
+
+		// Skip chunks already decoded from an earlier frame
+		if seen, conflict := c.scans.observe(payload); conflict {
+			diag.Warnf(diagnostics.CodeChunkConflict, framePath, "chunk %s%s decoded with different data than an earlier frame, the first is kept", payload.Name, fileLabel(payload.File))
+
+			continue
+		} else if seen {
+			diag.Infof(diagnostics.CodeDuplicateFrame, framePath, "duplicate QR code of chunk %s skipped", payload.Name)
+			liveView.duplicate()
+
+			continue
+		}
+
+		// Skip chunks decoded by a previous run
+		if _, err := os.Stat(dataFilePath); err == nil {
+			c.known++
+
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dataFilePath), 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+
+		// Save the data to a file named after the chunk
+		if err := qrfiletransfer.WriteFileAtomic(osFs, dataFilePath, data, 0644); err != nil {
+			return fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+		}
+
+		c.processed++
+		liveView.refresh(c.sessionDir, payload.File)
+	}
+
+	return nil
 }
 
-// copyFile copies a file from src to dst.
-func copyFile(src, dst string) error {
-	// Read the source file
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return fmt.Errorf("failed to read source file: %w", err)
+// finish reports the chunks collected from the given number of frames, and fails
+// if none were found
+func (c *chunkCollector) finish(frameCount int) error {
+	for _, file := range c.skips.files() {
+		if skipped := c.skips.detectors[file].Skipped(); len(skipped) > 0 {
+			diag.Warnf(diagnostics.CodeSkippedChunks, "", "chunks %s%s were skipped and not found in any later frame", qrfiletransfer.FormatIndexRanges(skipped), fileLabel(file))
+		}
 	}
 
-	// Write to the destination file
-	if err := os.WriteFile(dst, data, 0644); err != nil {
-		return fmt.Errorf("failed to write to destination file: %w", err)
+	if c.processed == 0 && c.known == 0 {
+		return fmt.Errorf("no valid QR codes found in any frames")
 	}
 
+	addResult("frames", frameCount)
+	addResult("qr_codes_decoded", c.processed)
+	addResult("qr_codes_known", c.known)
+
+	fmt.Printf("Successfully extracted %d unique QR codes from %d frames\n", c.processed, frameCount)
+	if c.known > 0 {
+		fmt.Printf("Skipped %d QR codes already decoded by a previous run\n", c.known)
+	}
+
+	c.scans.report()
+
 	return nil
 }
 
-// readQRCodeFromImage reads a QR code from an image file
-func readQRCodeFromImage(imagePath string) ([]byte, error) {
-	// Open the image file
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open image file: %w", err)
+// chunkKey identifies a chunk by the file ID and chunk name of its payload header
+type chunkKey struct {
+	file  string
+	chunk string
+}
+
+// chunkScan records the frames a chunk was decoded from
+type chunkScan struct {
+	count   int
+	sum     [sha256.Size]byte
+	index   int
+	indexed bool
+}
+
+// chunkScans counts the frames every chunk is decoded from
+type chunkScans map[chunkKey]*chunkScan
+
+// newChunkScans returns an empty chunkScans
+func newChunkScans() chunkScans {
+	return make(chunkScans)
+}
+
+// observe records a chunk decoded from a frame and reports whether it was decoded
+// before, and whether with different data
+func (s chunkScans) observe(payload *qrfiletransfer.ChunkPayload) (seen, conflict bool) {
+	key := chunkKey{file: payload.File, chunk: payload.Name}
+	sum := sha256.Sum256(payload.Data)
+
+	scan, ok := s[key]
+	if !ok {
+		index, indexed := payload.Index()
+		s[key] = &chunkScan{count: 1, sum: sum, index: index, indexed: indexed}
+
+		return false, false
 	}
-	defer func(file *os.File) {
-		if err := file.Close(); err != nil {
-			fmt.Printf("Warning: failed to close image file: %v\n", err)
+
+	scan.count++
+
+	return true, scan.sum != sum
+}
+
+// report prints the number of frames the chunks of every file were decoded from,
+// e.g. "Frames per chunk: 1 for chunks 3, 5-7; 2 for chunks 0-2, 4"
+func (s chunkScans) report() {
+	byFile := make(map[string]map[int][]int)
+
+	for key, scan := range s {
+		if !scan.indexed {
+			continue
 		}
-	}(file)
 
-	// Decode the image
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		if byFile[key.file] == nil {
+			byFile[key.file] = make(map[int][]int)
+		}
+
+		byFile[key.file][scan.count] = append(byFile[key.file][scan.count], scan.index)
 	}
 
-	// Create a binary bitmap from the image
-	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create binary bitmap: %w", err)
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
 	}
 
-	// Create a QR code reader
-	reader := qrcode.NewQRCodeReader()
+	sort.Strings(files)
 
-	// Try to decode the QR code
-	result, err := reader.Decode(bmp, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode QR code: %w", err)
-	}
+	for _, file := range files {
+		counts := make([]int, 0, len(byFile[file]))
+		for count := range byFile[file] {
+			counts = append(counts, count)
+		}
 
-	// Get the text from the result
-	text := result.GetText()
+		sort.Ints(counts)
 
-	// The QR code content is expected to be base64 encoded
-	data, err := base64.StdEncoding.DecodeString(text)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
+		groups := make([]string, 0, len(counts))
+
+		for _, count := range counts {
+			indices := byFile[file][count]
+			sort.Ints(indices)
+
+			groups = append(groups, fmt.Sprintf("%d for chunks %s", count, qrfiletransfer.FormatIndexRanges(indices)))
+		}
+
+		fmt.Printf("Frames per chunk%s: %s\n", fileLabel(file), strings.Join(groups, "; "))
+	}
+}
+
+// partialReadError returns the error of a read missing some of its files or
+// chunks, what, telling where the decoded chunks are kept to read the missing
+// ones, if they are
+func partialReadError(sessionDir, what string) error {
+	switch {
+	case readStateDir != "":
+		return exitErrorf(exitIncomplete, "not all %s could be read, decoded chunks are kept in %s, re-run with the same --state to read the missing ones", what, readStateDir)
+	case work.Keeps(workspace.Data):
+		return exitErrorf(exitIncomplete, "not all %s could be read, decoded chunks are kept in %s, re-run with --state %s to read the missing ones", what, sessionDir, sessionDir)
 	}
 
-	return data, nil
+	return exitErrorf(exitIncomplete, "not all %s could be read, re-run with --state or --keep data to keep the decoded chunks", what)
 }
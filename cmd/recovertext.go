@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recoverTextInputs []string
+	recoverTextOutput string
+)
+
+var recoverTextCmd = &cobra.Command{
+	Use:   "recover-text",
+	Short: "Recover a file from the text fallback of its chunks",
+	Long: `Recover a file from the Base45 text files written by split --text.
+
+Example:
+  qrfiletransfer recover-text -i output_directory -o myfile.txt
+
+The input is a session directory, its text directory, any directory of .txt
+files, or single text files given with repeated -i flags. The text files may
+have been restored by OCR or typed by hand: blank lines are ignored and letters
+may be in lower case. The data of every text file is checked against its SHA256
+line, so a mistyped chunk is reported by name rather than corrupting the file:
+  qrfiletransfer recover-text -i scans/page1.txt -i scans/page2.txt -o myfile.txt`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if len(recoverTextInputs) == 0 {
+			return usageErrorf("input is required")
+		}
+
+		if recoverTextOutput == "" {
+			return exitErrorf(exitUsage, "output file is required")
+		}
+
+		fmt.Println("Recovering file from text chunks...")
+
+		err := newQRFileTransfer().TextToFile(recoverTextInputs, recoverTextOutput)
+		var missing *qrfiletransfer.ErrMissingChunk
+		if errors.As(err, &missing) {
+			return fmt.Errorf("failed to recover file: %w, type or scan the text of chunk %d and run recover-text again", err, missing.Index+1)
+		} else if err != nil {
+			return fmt.Errorf("failed to recover file: %w", err)
+		}
+
+		setResult("output", newOutputResult(recoverTextOutput))
+		fmt.Printf("Successfully recovered file: %s\n", recoverTextOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(recoverTextCmd)
+
+	// Add flags
+	recoverTextCmd.Flags().StringArrayVarP(&recoverTextInputs, "input", "i", nil,
+		"Text file, or directory of text files, to recover from (required, repeatable)")
+	recoverTextCmd.Flags().StringVarP(&recoverTextOutput, "output", "o", "",
+		"Output file (required)")
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+// With --json, a command writes its messages to standard error instead, and a
+// single JSON object describing the run to standard output when it ends, see
+// runResult. Commands record what they did, such as the chunks encoded or the
+// frames decoded, with setResult and addResult, and time their phases with
+// timePhase.
+
+var (
+	jsonOutput bool
+
+	// result is the result of the running command, written with --json
+	result = &runResult{Timings: map[string]int64{}, Results: map[string]any{}}
+
+	// jsonStdout is the standard output the result is written to, nil until the
+	// messages are redirected
+	jsonStdout *os.File
+	// messages is the write end of the pipe the messages are written to, and
+	// messagesDone is closed once they are all copied to standard error
+	messages     *os.File
+	messagesDone chan struct{}
+	// firstError records the first error message of the command
+	firstError errorLine
+)
+
+// runResult is the JSON object written for a run with --json
+type runResult struct {
+	mu      sync.Mutex
+	started time.Time
+
+	// Command is the command run, such as "split"
+	Command string `json:"command"`
+	// OK is set if the command succeeded
+	OK bool `json:"ok"`
+	// ExitCode is the exit status of the command
+	ExitCode int `json:"exit_code"`
+	// Error is the first error message of a failed command
+	Error string `json:"error,omitempty"`
+	// DurationMS is the time the command ran in milliseconds
+	DurationMS int64 `json:"duration_ms"`
+	// Timings maps the phases of the command to their time in milliseconds
+	Timings map[string]int64 `json:"timings_ms,omitempty"`
+	// Results are the results of the command, such as "chunks" or "frames"
+	Results map[string]any `json:"results,omitempty"`
+	// Diagnostics are the non-fatal issues of the run, such as warnings
+	Diagnostics []diagnostics.Diagnostic `json:"diagnostics"`
+	// Kept lists the intermediate files kept by --keep
+	Kept []string `json:"kept,omitempty"`
+}
+
+// setResult records the result named key of the command
+func setResult(key string, value any) {
+	result.mu.Lock()
+	defer result.mu.Unlock()
+
+	result.Results[key] = value
+}
+
+// addResult adds n to the count named key of the command, for counts that several
+// steps contribute to, such as the frames of every session decoded
+func addResult(key string, n int) {
+	result.mu.Lock()
+	defer result.mu.Unlock()
+
+	count, _ := result.Results[key].(int)
+	result.Results[key] = count + n
+}
+
+// appendResult appends value to the list named key of the command, for results
+// of every session of a batch
+func appendResult(key string, value any) {
+	result.mu.Lock()
+	defer result.mu.Unlock()
+
+	list, _ := result.Results[key].([]any)
+	result.Results[key] = append(list, value)
+}
+
+// timePhase starts timing the phase name of the command and returns the function
+// ending it. The times of a phase run several times add up.
+func timePhase(name string) (stop func()) {
+	start := time.Now()
+
+	return func() {
+		result.mu.Lock()
+		defer result.mu.Unlock()
+
+		result.Timings[name] += time.Since(start).Milliseconds()
+	}
+}
+
+// startJSONOutput redirects the messages of cmd to standard error, keeping standard
+// output for the result
+func startJSONOutput(cmd *cobra.Command) error {
+	result.started = time.Now()
+	result.Command = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	jsonStdout, messages, messagesDone = os.Stdout, w, make(chan struct{})
+
+	os.Stdout = w
+	cmd.Root().SetOut(w)
+	cmd.Root().SetErr(w)
+
+	go func() {
+		defer close(messagesDone)
+
+		_, _ = io.Copy(io.MultiWriter(os.Stderr, &firstError), r)
+	}()
+
+	return nil
+}
+
+// writeResult writes the result of a command exiting with code to standard output
+// with --json. err is the error Execute returned, if any.
+func writeResult(code int, err error) {
+	if !jsonOutput {
+		return
+	}
+
+	out := os.Stdout
+
+	if jsonStdout != nil {
+		// Wait for the messages written so far
+		_ = messages.Close()
+		<-messagesDone
+
+		out = jsonStdout
+		os.Stdout = jsonStdout
+	}
+
+	result.mu.Lock()
+	defer result.mu.Unlock()
+
+	result.OK = code == 0
+	result.ExitCode = code
+	result.Diagnostics = diag.Diagnostics()
+	result.Kept = work.Kept()
+
+	if !result.started.IsZero() {
+		result.DurationMS = time.Since(result.started).Milliseconds()
+	}
+
+	if code != 0 {
+		result.Error = firstError.message
+		if result.Error == "" && err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	if result.Diagnostics == nil {
+		result.Diagnostics = []diagnostics.Diagnostic{}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	_ = encoder.Encode(result)
+}
+
+// errorLine records the first line written to it that starts with "Error", without
+// an "Error: " prefix
+type errorLine struct {
+	line    []byte
+	message string
+}
+
+// Write implements io.Writer
+func (e *errorLine) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != '\n' && b != '\r' {
+			e.line = append(e.line, b)
+
+			continue
+		}
+
+		if line := string(e.line); e.message == "" && strings.HasPrefix(line, "Error") {
+			e.message = strings.TrimPrefix(line, "Error: ")
+		}
+
+		e.line = e.line[:0]
+	}
+
+	return len(p), nil
+}
+
+// sessionResult describes a session in the result
+type sessionResult struct {
+	Dir      string                     `json:"dir"`
+	Complete bool                       `json:"complete"`
+	File     qrfiletransfer.SessionFile `json:"file"`
+	Chunks   int                        `json:"chunks"`
+	Parity   int                        `json:"parity,omitempty"`
+}
+
+// newSessionResult describes session in the result
+func newSessionResult(session *qrfiletransfer.Session) sessionResult {
+	return sessionResult{
+		Dir:      session.Dir(),
+		Complete: session.Complete,
+		File:     session.File,
+		Chunks:   len(session.Chunks),
+		Parity:   len(session.Parity),
+	}
+}
+
+// verifyResult describes the verification of a session in the result
+type verifyResult struct {
+	Dir string `json:"dir"`
+	// Source is what was checked, the QR codes or the data files
+	Source string `json:"source"`
+	Passed bool   `json:"passed"`
+	// Hash is the hex encoded SHA-256 recorded for the file
+	Hash string `json:"hash,omitempty"`
+	// FailedChunks lists the indices of the chunks that failed
+	FailedChunks []int  `json:"failed_chunks,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// chunksResult describes the chunks decoded of a file in the result
+type chunksResult struct {
+	Total      int   `json:"total"`
+	Present    int   `json:"present"`
+	Missing    []int `json:"missing,omitempty"`
+	Restorable []int `json:"restorable,omitempty"`
+}
+
+// newChunksResult describes the chunks of report in the result
+func newChunksResult(report *qrfiletransfer.ChunkReport) chunksResult {
+	return chunksResult{
+		Total:      report.Total,
+		Present:    len(report.Present),
+		Missing:    report.Missing,
+		Restorable: report.Restorable,
+	}
+}
+
+// benchResult describes the throughput of a combination of bench parameters in the
+// result
+type benchResult struct {
+	QRVersion int    `json:"qr_version"`
+	Recovery  string `json:"recovery"`
+	FPS       int    `json:"fps"`
+	QRCodes   int    `json:"qr_codes"`
+	// BytesPerSecond is the effective throughput of the file data
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	// DecodedFPS is the frames decoded per second by the round trip, and Complete
+	// whether it reconstructed the file
+	DecodedFPS float64 `json:"decoded_fps,omitempty"`
+	Complete   *bool   `json:"complete,omitempty"`
+}
+
+// outputResult describes a file or directory written by the command in the result
+type outputResult struct {
+	Path string `json:"path"`
+	Dir  bool   `json:"dir,omitempty"`
+	Size int64  `json:"size,omitempty"`
+	// Hash is the hex encoded SHA-256 of a file
+	Hash string `json:"hash,omitempty"`
+}
+
+// newOutputResult describes the file or directory at path in the result, hashing a
+// file only with --json
+func newOutputResult(path string) outputResult {
+	output := outputResult{Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return output
+	}
+
+	if info.IsDir() {
+		output.Dir = true
+
+		return output
+	}
+
+	output.Size = info.Size()
+
+	if !jsonOutput {
+		return output
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return output
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err == nil {
+		output.Hash = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return output
+}
@@ -1,11 +1,36 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
+	"sync"
 
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
 	"github.com/spf13/cobra"
 )
 
+// diagnosticsPerCode is the number of diagnostics of every code shown at the end
+// of a run, the others are counted
+const diagnosticsPerCode = 5
+
+var (
+	// diag collects the non-fatal issues of the running command, which are
+	// reported when it ends
+	diag = diagnostics.NewCollector()
+
+	diagnosticsJSON string
+	logLevel        string
+	logJSON         bool
+
+	// logger receives the log records of the library, configured by --log-level
+	// and --log-json
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "qrfiletransfer",
 	Short: "A tool to transfer files using QR codes",
@@ -16,11 +41,199 @@ back into the original file. This is useful for transferring files between devic
 that don't have a direct connection but can scan QR codes.
 
 Use the 'split' command to split a file into QR codes, and the 'join' command
-to join QR codes back into a file.`,
+to join QR codes back into a file.
+
+Non-fatal issues, such as frames that could not be decoded, are reported
+together when a command ends, with a severity and a code. --diagnostics-json
+also writes them as JSON for scripts.
+
+Log records, such as the progress of a merge, are written to standard error
+above --log-level, as text or as JSON lines with --log-json.
+
+With --json, every command writes its messages to standard error, and a JSON
+object describing the run to standard output when it ends, for scripts and GUIs:
+"command", "ok", "exit_code", the first "error" message of a failed command,
+"duration_ms", "timings_ms" mapping its phases to their time, "results" of the
+command such as the "chunks" encoded, the "frames" decoded, and the "output"
+written with its SHA-256 "hash", the "diagnostics" of the run, and the
+intermediate files "kept".
+
+Commands exit with 0 on success, 2 for a missing input or an invalid flag, 3 for
+chunks still missing, 4 for a hash mismatch, 5 without ffmpeg, 6 for data that
+does not fit in a QR code, 130 when interrupted, and 1 for any other failure.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if jsonOutput {
+			if err := startJSONOutput(cmd); err != nil {
+				return err
+			}
+		}
+
+		if err := setRetention(); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		return withExitCode(exitUsage, configureLogger())
+	},
+}
+
+func init() {
+	// Errors are reported by Execute, which exits with their exit code
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return withExitCode(exitUsage, err)
+	})
+
+	rootCmd.PersistentFlags().StringVar(&diagnosticsJSON, "diagnostics-json", "",
+		"Write the diagnostics of the run as JSON to this file, or - for standard output")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn",
+		"Lowest level of the log records written to standard error: debug, info, warn, or error")
+	rootCmd.PersistentFlags().BoolVar(&logJSON, "log-json", false,
+		"Write the log records as JSON lines")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false,
+		"Write the messages to standard error, and the result of the command as JSON to standard output")
+	rootCmd.PersistentFlags().StringVar(&keepRetention, "keep", "none",
+		"Intermediate files kept when the command ends and listed: none, data (the decoded chunks), or all (also frames and split chunks)")
+	rootCmd.PersistentFlags().BoolVar(&keepTemp, "keep-temp", false,
+		"Keep every intermediate file, same as --keep all")
+	_ = rootCmd.PersistentFlags().MarkDeprecated("keep-temp", "use --keep all instead")
+	_ = rootCmd.RegisterFlagCompletionFunc("keep", completeValues(
+		workspace.KeepNone.String(), workspace.KeepData.String(), workspace.KeepAll.String()))
+	_ = rootCmd.RegisterFlagCompletionFunc("log-level", completeValues("debug", "info", "warn", "error"))
+}
+
+// configureLogger sets up logger from --log-level and --log-json
+func configureLogger() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level '%s' (expected debug, info, warn, or error)", logLevel)
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+
+	if logJSON {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, options))
+	} else {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, options))
+	}
+
+	return nil
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	watchInterrupts()
+
+	// Remove the temporary files before a panic ends the program
+	defer func() {
+		if r := recover(); r != nil {
+			removeTempFiles()
+			panic(r)
+		}
+	}()
+
+	usageArgs(rootCmd)
+
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil {
+		executeErr = err
+		reportError(cmd, err)
+		exit(exitCode(err))
+	}
+
+	exit(0)
+}
+
+// reportError prints the error cmd failed with, followed by its help for a
+// required input that is not given
+func reportError(cmd *cobra.Command, err error) {
+	if !errors.Is(err, errFailed) {
+		fmt.Printf("Error: %v\n", err)
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) && exitErr.help {
+		if err := cmd.Help(); err != nil {
+			fmt.Printf("Error displaying help: %v\n", err)
+		}
+	}
+}
+
+// newQRFileTransfer creates a QRFileTransfer reporting its diagnostics to diag and
+// its log records to logger, and creating its intermediate directories in work
+func newQRFileTransfer() *qrfiletransfer.QRFileTransfer {
+	q := qrfiletransfer.NewQRFileTransfer()
+	q.SetDiagnostics(diag)
+	q.SetLogger(logger)
+	q.SetCleanup(tempFiles)
+	q.SetWorkspace(work)
+
+	return q
+}
+
+// safeOutputPath returns the output path of the command, renamed if the OS cannot
+// create it, see QRFileTransfer.SafeOutputPath. It fails if the directory of path
+// cannot be created, before any QR code is decoded.
+func safeOutputPath(path string) (string, error) {
+	return newQRFileTransfer().SafeOutputPath(path)
+}
+
+// exit closes the live monitor, removes the temporary files, reports the
+// diagnostics collected so far, writes the result with --json, and exits with
+// code, 130 for a command that failed once interrupted. Further calls, e.g. by
+// watchInterrupts while the command ends, block until it exits.
+func exit(code int) {
+	exiting.Do(func() {
+		if code != 0 && runCtx.Err() != nil {
+			code = exitInterrupted
+		}
+
+		liveView.close()
+		removeTempFiles()
+		reportDiagnostics()
+		writeResult(code, executeErr)
+		os.Exit(code)
+	})
+}
+
+var (
+	// exiting makes sure the command exits once
+	exiting sync.Once
+	// executeErr is the error the command failed with, if it returned one
+	executeErr error
+)
+
+// reportDiagnostics prints the diagnostics collected by the command, and writes
+// them as JSON with --diagnostics-json
+func reportDiagnostics() {
+	if err := diag.Report(os.Stdout, diagnosticsPerCode); err != nil {
+		fmt.Printf("Error reporting diagnostics: %v\n", err)
+	}
+
+	if diagnosticsJSON == "" {
+		return
+	}
+
+	if diagnosticsJSON == "-" {
+		if err := diag.WriteJSON(os.Stdout); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+
+		return
+	}
+
+	f, err := os.Create(diagnosticsJSON)
+	if err != nil {
+		fmt.Printf("Error creating diagnostics file: %v\n", err)
+
+		return
+	}
+
+	err = diag.WriteJSON(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 	}
 }
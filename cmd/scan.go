@@ -0,0 +1,434 @@
+//go:build !novideo && !nodecode
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanDevice     string
+	scanInputFmt   string
+	scanOutputFile string
+	scanStateDir   string
+	scanFPS        int
+	scanVideoSize  string
+	scanTimeout    time.Duration
+	scanLens       string
+	scanBudget     time.Duration
+	scanEnhance    string
+	scanTUI        bool
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scan QR codes from a camera and reconstruct the file",
+	Long: `Scan QR codes live from a camera and reconstruct the original file as soon
+as every chunk has been seen.
+
+Example:
+  qrfiletransfer scan -o reconstructed_file.txt
+
+Frames are captured with ffmpeg from the default camera of the platform
+(V4L2 on Linux, AVFoundation on macOS, DirectShow on Windows, where --device
+is required). The chunk indices that are still missing are shown while
+scanning. If scanning is interrupted, the decoded chunks are kept in the
+state directory and a later run with the same --state continues from them.
+
+QR codes of several files, created with split on more than one input, are
+sorted out by the file ID in each chunk. As the receiver cannot tell how many
+files a batch holds, scanning a batch continues until Ctrl+C or --timeout, and
+every complete file is then written into the output directory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runScan(false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scanCmd)
+
+	// Add flags
+	addScanFlags(scanCmd)
+	scanCmd.Flags().BoolVar(&scanTUI, "tui", false,
+		"Show a live monitor of the chunks received, with the percentage, estimated time remaining, and duplicates")
+}
+
+// addScanFlags adds the flags of scan, shared by receive, to cmd
+func addScanFlags(cmd *cobra.Command) {
+	addCameraFlags(cmd, "fps")
+	cmd.Flags().StringVarP(&scanOutputFile, "output", "o", "",
+		"Output file path, or directory for a batch of files (default: the name of the original file, scanned_files for a batch)")
+	cmd.Flags().StringVarP(&scanStateDir, "state", "s", "",
+		"Directory keeping decoded chunks across runs (default: temporary directory)")
+	cmd.Flags().DurationVar(&scanTimeout, "timeout", 0,
+		"Stop scanning after this duration, e.g. 2m (default: no timeout)")
+	markDirFlags(cmd, "state")
+}
+
+// addCameraFlags adds the flags selecting and capturing the camera to cmd, with
+// the number of frames decoded per second set by the flag named fpsFlag
+func addCameraFlags(cmd *cobra.Command, fpsFlag string) {
+	cmd.Flags().StringVarP(&scanDevice, "device", "d", "",
+		"Camera device (default: /dev/video0 on Linux, 0 on macOS)")
+	cmd.Flags().StringVarP(&scanInputFmt, "format", "f", "",
+		"ffmpeg input format of the camera (default: v4l2, avfoundation or dshow depending on the platform)")
+	cmd.Flags().IntVar(&scanFPS, fpsFlag, 10,
+		"Number of frames per second to decode")
+	cmd.Flags().StringVar(&scanVideoSize, "size", "",
+		"Capture resolution, e.g. 1280x720 (default: camera default)")
+	cmd.Flags().StringVar(&scanLens, "lens", "",
+		"Camera calibration profile (JSON with k1/k2 distortion and crop) applied to frames before decoding")
+	cmd.Flags().DurationVar(&scanBudget, "frame-budget", 50*time.Millisecond,
+		"Time spent per failed frame retrying it with contrast, threshold, sharpening, and scale variants (0 disables retries)")
+	cmd.Flags().StringVar(&scanEnhance, "enhance", "",
+		"Preprocess every frame for difficult captures: grayscale, perspective, sharpen, adaptive, multiscale, or all, comma-separated")
+
+	_ = cmd.RegisterFlagCompletionFunc("enhance", completeList(enhancementSteps...))
+	_ = cmd.MarkFlagFilename("lens", "json")
+}
+
+// runScan scans QR codes from the camera and reconstructs the file, showing the
+// acknowledgement of the chunks read in the terminal if interactive is set, see
+// receive
+func runScan(interactive bool) error {
+	// Check if ffmpeg is installed
+	if err := checkFFmpegInstalled(); err != nil {
+		return err
+	}
+
+	if err := loadLensProfile(scanLens); err != nil {
+		return err
+	}
+
+	if err := loadEnhancement(scanEnhance); err != nil {
+		return err
+	}
+
+	sweepBudget = scanBudget
+
+	var err error
+	if scanOutputFile != "" {
+		if scanOutputFile, err = safeOutputPath(scanOutputFile); err != nil {
+			return err
+		}
+	}
+
+	inputFmt, device, err := captureInput(scanInputFmt, scanDevice)
+	if err != nil {
+		return err
+	}
+
+	// Keep decoded chunks in the state directory, or in a temporary one that is
+	// removed once the file has been reconstructed unless --keep data, and kept
+	// if it cannot be
+	stateDir := scanStateDir
+	releaseState := func() error { return nil }
+
+	if stateDir == "" {
+		stateDir, releaseState, err = work.Create(osFs, workspace.Data, "qrcode_scan_*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Stop capturing on Ctrl+C or when the timeout expires
+	ctx, stop := signal.NotifyContext(runCtx, os.Interrupt)
+	defer stop()
+
+	interruptHandled.Store(true)
+
+	if scanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, scanTimeout)
+		defer cancel()
+	}
+
+	qrft := newQRFileTransfer()
+
+	fmt.Printf("Scanning QR codes from %s (%s), press Ctrl+C to stop...\n", device, inputFmt)
+
+	progress := func(file string, report *qrfiletransfer.ChunkReport) {
+		fmt.Printf("\rChunks%s: %s", fileLabel(file), report)
+	}
+
+	// Show the receiver's acknowledgement to the camera of the sender instead
+	var acks *ackScreen
+	if interactive {
+		acks = newAckScreen(stateDir)
+		progress = acks.update
+	} else {
+		startLiveMonitor(scanTUI)
+		if liveView != nil {
+			progress = liveView.update
+		}
+	}
+
+	report, err := scanCamera(ctx, qrft, captureArgs(inputFmt, device, scanFPS, scanVideoSize), stateDir, progress)
+	liveView.close()
+
+	if acks != nil {
+		// Leave the final acknowledgement up for the sender to stop
+		if err == nil && report != nil && report.Complete() {
+			acks.hold(ctx)
+		}
+
+		acks.close()
+	}
+
+	fmt.Println() // Print a newline after the progress indicator
+
+	if err != nil {
+		work.Keep(stateDir)
+		fmt.Printf("Decoded chunks are kept in %s\n", stateDir)
+
+		return fmt.Errorf("failed to scan QR codes: %w", err)
+	}
+
+	// QR codes of several files are reconstructed into the output directory
+	if qrfiletransfer.IsBatch(stateDir) {
+		outputDir := scanOutputFile
+		if outputDir == "" {
+			outputDir = "scanned_files"
+		}
+
+		if err := reconstructBatch(qrft, stateDir, outputDir); err != nil {
+			work.Keep(stateDir)
+			fmt.Printf("Decoded chunks are kept in %s, re-run with --state %s to continue\n", stateDir, stateDir)
+
+			return err
+		}
+
+		if err := releaseState(); err != nil {
+			diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary directory: %v", err)
+		}
+
+		return nil
+	}
+
+	if report == nil || !report.Complete() {
+		if report != nil {
+			fmt.Printf("Chunks: %s\n", report)
+		}
+
+		work.Keep(stateDir)
+
+		return exitErrorf(exitIncomplete, "scanning stopped before every chunk was read, re-run with --state %s to continue", stateDir)
+	}
+
+	// Name the output after the original file unless told otherwise
+	outputFile := scanOutputFile
+	if outputFile == "" {
+		session, err := qrfiletransfer.OpenSession(stateDir)
+		if err != nil || session.File.Name == "" {
+			outputFile = "scanned_reconstructed"
+		} else if outputFile, err = safeOutputPath(filepath.Base(session.File.Name)); err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(outputFile); err == nil {
+			work.Keep(stateDir)
+			fmt.Printf("Decoded chunks are kept in %s\n", stateDir)
+
+			return exitErrorf(exitUsage, "'%s' already exists, use -o to choose the output file", outputFile)
+		}
+	}
+
+	fmt.Printf("Reconstructing file from QR codes...\n")
+	defer timePhase("reconstruct")()
+	if err := qrft.QRCodesToFileCtx(runCtx, stateDir, outputFile); err != nil {
+		work.Keep(stateDir)
+		fmt.Printf("Decoded chunks are kept in %s\n", stateDir)
+
+		return fmt.Errorf("failed to reconstruct file: %w", err)
+	}
+
+	if err := releaseState(); err != nil {
+		diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary directory: %v", err)
+	}
+
+	setResult("output", newOutputResult(outputFile))
+	fmt.Printf("Successfully reconstructed file: %s\n", outputFile)
+
+	return nil
+}
+
+// captureInput returns the ffmpeg input format and device of the camera, filling in
+// the platform defaults for the ones not given
+func captureInput(inputFmt, device string) (string, string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		if inputFmt == "" {
+			inputFmt = "v4l2"
+		}
+
+		if device == "" {
+			device = "/dev/video0"
+		}
+	case "darwin":
+		if inputFmt == "" {
+			inputFmt = "avfoundation"
+		}
+
+		if device == "" {
+			device = "0"
+		}
+	case "windows":
+		if inputFmt == "" {
+			inputFmt = "dshow"
+		}
+	}
+
+	if inputFmt == "" || device == "" {
+		return "", "", errors.New("no default camera on this platform, use --format and --device")
+	}
+
+	// DirectShow devices are addressed by name
+	if inputFmt == "dshow" && !strings.HasPrefix(device, "video=") {
+		device = "video=" + device
+	}
+
+	return inputFmt, device, nil
+}
+
+// captureArgs returns the ffmpeg arguments capturing frames from a camera and
+// writing them as a stream of PNG images to stdout
+func captureArgs(inputFmt, device string, fps int, videoSize string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-f", inputFmt}
+
+	if videoSize != "" {
+		args = append(args, "-video_size", videoSize)
+	}
+
+	args = append(args, "-i", device)
+
+	if fps > 0 {
+		args = append(args, "-vf", "fps="+strconv.Itoa(fps))
+	}
+
+	return append(args, "-f", "image2pipe", "-vcodec", "png", "-")
+}
+
+// scanCamera decodes the frames captured by ffmpeg until every chunk has been read
+// or ctx is done, saving new chunks to stateDir, and passes every new chunk report
+// to progress with the ID of the file of the chunk. It returns the last chunk report,
+// or nil if no chunk was read. Once a chunk of a batch has been read, scanning only
+// stops when ctx is done, and the report is that of the last file read.
+func scanCamera(ctx context.Context, qrft *qrfiletransfer.QRFileTransfer, args []string, stateDir string,
+	progress func(file string, report *qrfiletransfer.ChunkReport)) (*qrfiletransfer.ChunkReport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	capture := exec.CommandContext(ctx, "ffmpeg", args...)
+	capture.Stderr = os.Stderr
+
+	stdout, err := capture.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ffmpeg: %w", err)
+	}
+
+	if err := capture.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	// A directory left by a previous run may already hold chunks
+	report, _ := qrft.VerifyChunks(stateDir)
+	if report != nil {
+		progress("", report)
+	}
+
+	stream := bufio.NewReader(stdout)
+
+	// Flag chunks that scrolled past undecoded, if the sender embedded hints
+	skips := newFileSkipDetectors(stateDir)
+	batch := qrfiletransfer.IsBatch(stateDir)
+
+	for batch || report == nil || !report.Complete() {
+		img, err := png.Decode(stream)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || ctx.Err() != nil {
+				break
+			}
+
+			return report, fmt.Errorf("failed to decode captured frame: %w", err)
+		}
+
+		liveView.frame()
+
+		contents, err := decodeFrame(img)
+		if err != nil {
+			// Most frames show no QR code or a blurred one
+			continue
+		}
+
+		// A frame may tile several QR codes
+		for _, payload := range parsePayloads(contents) {
+			dataFilePath, err := qrfiletransfer.ChunkDataPath(stateDir, payload)
+			if err != nil {
+				continue
+			}
+
+			if index, ok := payload.Index(); ok {
+				if skipped := skips.observe(payload.File, index, payload.Next); len(skipped) > 0 {
+					fmt.Printf("\nSkipped chunks %s%s, keep scanning until they come around again\n", qrfiletransfer.FormatIndexRanges(skipped), fileLabel(payload.File))
+				}
+			}
+
+			// Skip chunks already decoded
+			if _, err := os.Stat(dataFilePath); err == nil {
+				liveView.duplicate()
+
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(dataFilePath), 0755); err != nil {
+				return report, fmt.Errorf("failed to create data directory: %w", err)
+			}
+
+			if err := qrfiletransfer.WriteFileAtomic(osFs, dataFilePath, payload.Data, 0644); err != nil {
+				return report, fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+			}
+
+			batch = batch || payload.File != ""
+
+			if report, err = qrft.VerifyChunks(filepath.Join(stateDir, payload.File)); err != nil {
+				return nil, fmt.Errorf("failed to verify chunks: %w", err)
+			}
+
+			progress(payload.File, report)
+		}
+	}
+
+	// ffmpeg is killed when scanning stopped on purpose, any other exit is a failure
+	stopped := ctx.Err() != nil || (!batch && report != nil && report.Complete())
+
+	// Stop capturing once every chunk has been read
+	cancel()
+
+	if err := capture.Wait(); err != nil && !stopped {
+		return report, fmt.Errorf("ffmpeg command failed: %w", err)
+	}
+
+	return report, nil
+}
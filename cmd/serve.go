@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/serve"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveInput     string
+	serveOutputDir string
+	serveAddr      string
+	serveFPS       float64
+	serveLoop      bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve QR codes as an auto-cycling slideshow over HTTP",
+	Long: `Start a local HTTP server presenting the QR codes of a file as an
+auto-cycling slideshow, so a receiving phone can simply point its camera at
+the screen.
+
+Example:
+  qrfiletransfer serve -i myfile.txt
+
+The input is either a file, which is encoded into QR codes first, or a session
+directory created by split. The frames of a batch of several files are shown in
+the global order listed in its frames.json. Open the printed address in a browser to start the
+slideshow. The frame rate, looping, and pausing can be changed on the page or
+through the API, which also lets the receiving operator direct the slideshow:
+  GET  /api/frames     number and names of the frames
+  GET  /api/settings   current playback settings
+  POST /api/settings   e.g. {"fps": 5, "loop": false, "paused": true}
+  GET  /api/playback   current position and the frame shown by the page
+  POST /api/jump       e.g. {"index": 12} (frames start at 0)
+  POST /api/replay     e.g. {"start": 3, "end": 7}, or {} for all frames
+  POST /api/queue      e.g. {"frames": [3, 9, 12]}
+  POST /api/status     show the status QR code describing the transfer, or
+                       {"show": false} to hide it
+
+For example:
+  curl -d '{"start": 3, "end": 7}' http://localhost:8080/api/replay`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if serveInput == "" {
+			return usageErrorf("input file or session directory is required")
+		}
+
+		show, err := newSlideshow(serveInput, serveOutputDir, serveFPS, serveLoop)
+		if err != nil {
+			return err
+		}
+
+		defer show.release()
+
+		httpServer := &http.Server{
+			Addr:              serveAddr,
+			Handler:           show.server,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Shut down on Ctrl+C
+		ctx, stop := signal.NotifyContext(runCtx, os.Interrupt)
+		defer stop()
+
+		interruptHandled.Store(true)
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_ = httpServer.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("Serving %d QR codes on http://%s/ (press Ctrl+C to stop)\n", len(show.frames), displayAddr(serveAddr))
+
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("failed to serve QR codes: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	// Add flags
+	serveCmd.Flags().StringVarP(&serveInput, "input", "i", "",
+		"Input file or session directory (required)")
+	serveCmd.Flags().StringVarP(&serveOutputDir, "output", "o", "",
+		"Output directory for the QR codes of an input file (default: temporary directory)")
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "a", "localhost:8080",
+		"Address to listen on")
+	serveCmd.Flags().Float64Var(&serveFPS, "fps", serve.DefaultFPS,
+		"Frames per second of the slideshow")
+	serveCmd.Flags().BoolVar(&serveLoop, "loop", true,
+		"Restart the slideshow after the last frame")
+
+	markDirFlags(serveCmd, "output")
+}
+
+// slideshow is the slideshow of a session prepared by newSlideshow
+type slideshow struct {
+	// server presents the frames
+	server *serve.Server
+	// frames are the QR code images in playback order
+	frames []serve.Frame
+	// dir is the session or batch directory
+	dir string
+	// release removes the temporary directory of the session, if any
+	release func()
+}
+
+// newSlideshow prepares the slideshow of input, a file or a session directory, at
+// fps frames per second. A file is encoded into outputDir first, or into a
+// temporary directory unless outputDir is set.
+func newSlideshow(input, outputDir string, fps float64, loop bool) (*slideshow, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, exitErrorf(exitUsage, "input '%s' does not exist", input)
+	}
+
+	release := func() {}
+
+	// Encode a file into a session first, in a temporary directory unless an
+	// output directory is given
+	sessionDir := input
+	if !info.IsDir() {
+		sessionDir = outputDir
+		if sessionDir == "" {
+			tempDir, err := os.MkdirTemp("", "qrcode_serve_*")
+			if err != nil {
+				return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+			}
+
+			releaseTemp := trackTemp(tempDir)
+
+			release = func() {
+				if err := releaseTemp(); err != nil {
+					diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary directory: %v", err)
+				}
+			}
+
+			sessionDir = filepath.Join(tempDir, "session")
+		}
+
+		fmt.Printf("Encoding file '%s' into QR codes...\n", input)
+		if err := newQRFileTransfer().FileToQRCodesCtx(runCtx, input, sessionDir); err != nil {
+			release()
+
+			return nil, fmt.Errorf("failed to split file: %w", err)
+		}
+	}
+
+	// The temporary session is removed right away if it cannot be shown, rather
+	// than when the command exits
+	frames, statusQR, err := presentedFrames(sessionDir)
+	if err != nil {
+		release()
+
+		return nil, err
+	}
+
+	server := serve.NewServer(frames)
+
+	if status, err := statusQR(); err != nil {
+		diag.Warnf(diagnostics.CodeOptionalOutput, "", "failed to create the status QR code: %v", err)
+	} else {
+		server.SetStatusImage(status)
+	}
+	if err := server.SetSettings(serve.Settings{FPS: fps, Loop: loop}); err != nil {
+		release()
+
+		return nil, err
+	}
+
+	return &slideshow{server: server, frames: frames, dir: sessionDir, release: release}, nil
+}
+
+// presentedFrames returns the frames of the session or batch in dir in playback
+// order, and a function creating the status QR code describing the transfer
+func presentedFrames(dir string) ([]serve.Frame, func() ([]byte, error), error) {
+	if order, err := qrfiletransfer.LoadFrameOrder(dir); err == nil {
+		batch, err := qrfiletransfer.LoadBatch(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		frames, err := batchFrames(dir, order)
+
+		return frames, func() ([]byte, error) { return batchStatusQRCode(batch, order) }, err
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+
+	if qrfiletransfer.IsBatch(dir) {
+		return nil, nil, fmt.Errorf("batch in %s has no %s, re-run split to write it", dir, qrfiletransfer.FrameOrderFileName)
+	}
+
+	session, err := qrfiletransfer.OpenSession(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	frames, err := sessionFrames(session)
+
+	return frames, func() ([]byte, error) { return statusQRCode(session) }, err
+}
+
+// batchFrames returns the QR code images of a batch in its frame order
+func batchFrames(dir string, order *qrfiletransfer.FrameOrder) ([]serve.Frame, error) {
+	frames := make([]serve.Frame, 0, len(order.Frames))
+
+	for i, path := range order.FramePaths(dir) {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("QR code of frame %s is missing: %w", order.Frames[i].Name, err)
+		}
+
+		frames = append(frames, serve.Frame{Name: order.Frames[i].Name, Path: path})
+	}
+
+	return frames, nil
+}
+
+// sessionFrames returns the QR code images of a session in chunk order
+func sessionFrames(session *qrfiletransfer.Session) ([]serve.Frame, error) {
+	if session.QRCodesDir() == "" {
+		return nil, fmt.Errorf("%s contains no QR codes", session.Dir())
+	}
+
+	frames := make([]serve.Frame, 0, len(session.Chunks))
+
+	for _, chunk := range session.Chunks {
+		path := session.QRCodeFile(chunk.Name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("QR code of chunk %s is missing: %w", chunk.Name, err)
+		}
+
+		frames = append(frames, serve.Frame{Name: chunk.Name, Path: path})
+	}
+
+	return frames, nil
+}
+
+// statusQRCode returns a PNG image of a QR code describing the transfer, for the
+// receiving operator to check the file and chunk count they expect
+func statusQRCode(session *qrfiletransfer.Session) ([]byte, error) {
+	content := fmt.Sprintf("qrfiletransfer status\nfile: %s\nsize: %d bytes\nsha256: %s\nchunks: %d",
+		session.File.Name, session.File.Size, session.File.Hash, len(session.Chunks))
+
+	return qrcode.Encode(content, qrcode.Medium, 512)
+}
+
+// batchStatusQRCode returns a PNG image of a QR code describing the files of a
+// batch, see statusQRCode
+func batchStatusQRCode(batch *qrfiletransfer.Batch, order *qrfiletransfer.FrameOrder) ([]byte, error) {
+	content := fmt.Sprintf("qrfiletransfer status\nfiles: %d\nframes: %d", len(batch.Files), len(order.Frames))
+	for _, f := range batch.Files {
+		content += fmt.Sprintf("\n%s: %s (%d bytes)", f.ID, f.Name, f.Size)
+	}
+
+	return qrcode.Encode(content, qrcode.Medium, 512)
+}
+
+// displayAddr returns a listen address in a form that can be opened in a browser
+func displayAddr(addr string) string {
+	if len(addr) > 0 && addr[0] == ':' {
+		return "localhost" + addr
+	}
+
+	return addr
+}
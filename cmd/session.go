@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionExportInput  string
+	sessionExportOutput string
+	sessionImportInput  string
+	sessionImportOutput string
+)
+
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Hand a partial reception over to another machine",
+	Long: `Export the state of a partial reception into a single file, and import it on
+another machine or for another analyst to complete it.
+
+Example:
+  qrfiletransfer session export -i scan_state -o reception.tar.gz
+  qrfiletransfer session import -i reception.tar.gz -o scan_state
+
+The exported file is a gzip compressed tar archive of the received chunks, the
+manifest and session file if present, and the quarantined chunks. Continue the
+reception with read or scan using --state, or add more captures with combine.`,
+}
+
+var sessionExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Pack the state of a partial reception into a file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if sessionExportInput == "" || sessionExportOutput == "" {
+			return usageErrorf("input directory and output file are required")
+		}
+
+		if _, err := os.Stat(sessionExportInput); os.IsNotExist(err) {
+			return exitErrorf(exitUsage, "input directory '%s' does not exist", sessionExportInput)
+		}
+
+		report, err := newQRFileTransfer().VerifyChunks(sessionExportInput)
+		if err != nil {
+			return err
+		}
+
+		// Refuse to overwrite an existing file
+		out, err := os.OpenFile(sessionExportOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		if err := qrfiletransfer.ExportState(sessionExportInput, out); err != nil {
+			_ = out.Close()
+			_ = os.Remove(sessionExportOutput)
+
+			return fmt.Errorf("failed to export state: %w", err)
+		}
+
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+
+		setResult("chunks", newChunksResult(report))
+		setResult("output", newOutputResult(sessionExportOutput))
+
+		fmt.Printf("Chunks: %s\n", report)
+		fmt.Printf("Successfully exported '%s' to '%s'\n", sessionExportInput, sessionExportOutput)
+
+		return nil
+	},
+}
+
+var sessionImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Unpack an exported reception state into a directory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if sessionImportInput == "" || sessionImportOutput == "" {
+			return usageErrorf("input file and output directory are required")
+		}
+
+		in, err := os.Open(sessionImportInput)
+		if err != nil {
+			return exitErrorf(exitUsage, "input file '%s' cannot be opened: %w", sessionImportInput, err)
+		}
+
+		defer func() {
+			_ = in.Close()
+		}()
+
+		if err := qrfiletransfer.ImportState(in, sessionImportOutput); err != nil {
+			return fmt.Errorf("failed to import state: %w", err)
+		}
+
+		report, err := newQRFileTransfer().VerifyChunks(sessionImportOutput)
+		if err != nil {
+			return err
+		}
+
+		setResult("chunks", newChunksResult(report))
+		setResult("output", newOutputResult(sessionImportOutput))
+
+		fmt.Printf("Chunks: %s\n", report)
+		fmt.Printf("Successfully imported '%s' into '%s'\n", sessionImportInput, sessionImportOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionExportCmd, sessionImportCmd)
+
+	// Add flags
+	sessionExportCmd.Flags().StringVarP(&sessionExportInput, "input", "i", "",
+		"Directory holding the reception state, e.g. the --state directory of read or scan (required)")
+	sessionExportCmd.Flags().StringVarP(&sessionExportOutput, "output", "o", "",
+		"Output archive file (required)")
+	sessionImportCmd.Flags().StringVarP(&sessionImportInput, "input", "i", "",
+		"Archive file written by session export (required)")
+	sessionImportCmd.Flags().StringVarP(&sessionImportOutput, "output", "o", "",
+		"Directory to unpack the state into, which must not exist or be empty (required)")
+
+	markDirFlags(sessionExportCmd, "input")
+	markDirFlags(sessionImportCmd, "output")
+}
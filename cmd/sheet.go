@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/pdf"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sheetInput   string
+	sheetOutput  string
+	sheetProfile string
+	sheetPaper   string
+	sheetColumns int
+	sheetRows    int
+)
+
+var sheetCmd = &cobra.Command{
+	Use:   "sheet",
+	Short: "Print a file as a paper backup sheet of QR codes",
+	Long: `Encode a file into QR codes and lay them out as a printable PDF sheet.
+
+Example:
+  qrfiletransfer sheet -i secret.key -o backup.pdf
+
+Every QR code is printed in its own cell, headed with the file name, the chunk
+number as x/y, the date, and the first digits of the SHA-256 of the chunk, and
+separated from the other cells by cut marks, so that every code remains
+identifiable once cut out. The footer of every page carries the size and full
+SHA-256 of the file. Scan the printed codes with read or scan to restore the file.
+
+The input may also be a session or batch directory created by split, whose QR
+codes are laid out as they are. Otherwise the file is encoded with the --profile
+settings, by default the print-archive profile with the highest recovery level:
+  qrfiletransfer sheet -i notes.txt --profile profile:default,recovery=high
+
+Profiles: ` + strings.Join(qrfiletransfer.ProfileNames(), ", "),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if sheetInput == "" {
+			return usageErrorf("input file is required")
+		}
+
+		info, statErr := os.Stat(sheetInput)
+		if os.IsNotExist(statErr) {
+			return exitErrorf(exitUsage, "input '%s' does not exist", sheetInput)
+		}
+
+		// Set the page layout
+		layout := qrfiletransfer.DefaultSheetLayout()
+		layout.Columns = sheetColumns
+		layout.Rows = sheetRows
+
+		switch sheetPaper {
+		case "a4":
+			layout.PageWidth, layout.PageHeight = pdf.A4Width, pdf.A4Height
+		case "letter":
+			layout.PageWidth, layout.PageHeight = pdf.LetterWidth, pdf.LetterHeight
+		default:
+			return exitErrorf(exitUsage, "unknown paper size '%s' (expected a4 or letter)", sheetPaper)
+		}
+
+		// If the output file is not specified, use a default
+		if sheetOutput == "" {
+			baseName := filepath.Base(filepath.Clean(sheetInput))
+			sheetOutput = strings.TrimSuffix(baseName, filepath.Ext(baseName)) + "_sheet.pdf"
+		}
+
+		out, err := os.Create(sheetOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+
+		if statErr == nil && info.IsDir() {
+			fmt.Printf("Laying out the QR codes of '%s' as a sheet...\n", sheetInput)
+			err = qrfiletransfer.WriteSheet(out, sheetInput, layout)
+		} else {
+			err = sheetFromFile(out, layout)
+		}
+
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			_ = os.Remove(sheetOutput)
+
+			return fmt.Errorf("failed to write sheet: %w", err)
+		}
+
+		setResult("output", newOutputResult(sheetOutput))
+		fmt.Printf("Successfully wrote sheet: %s\n", sheetOutput)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sheetCmd)
+
+	// Add flags
+	sheetCmd.Flags().StringVarP(&sheetInput, "input", "i", "",
+		"Input file, or session or batch directory, to print (required)")
+	sheetCmd.Flags().StringVarP(&sheetOutput, "output", "o", "",
+		"Output PDF file (default: <filename>_sheet.pdf)")
+	sheetCmd.Flags().StringVar(&sheetProfile, "profile", "profile:print-archive",
+		"Encoder settings for a file, e.g. profile:default,recovery=high")
+	sheetCmd.Flags().StringVar(&sheetPaper, "paper", "a4", "Paper size (a4, letter)")
+	sheetCmd.Flags().IntVar(&sheetColumns, "columns", qrfiletransfer.DefaultSheetLayout().Columns,
+		"Number of QR code cells across a page")
+	sheetCmd.Flags().IntVar(&sheetRows, "rows", qrfiletransfer.DefaultSheetLayout().Rows,
+		"Number of QR code cells down a page")
+
+	_ = sheetCmd.RegisterFlagCompletionFunc("profile", completeProfiles())
+	_ = sheetCmd.RegisterFlagCompletionFunc("paper", completeValues("a4", "letter"))
+	_ = sheetCmd.MarkFlagFilename("output", "pdf")
+}
+
+// sheetFromFile encodes the input file with the --profile settings into a sheet
+func sheetFromFile(out *os.File, layout qrfiletransfer.SheetLayout) error {
+	base, _ := qrfiletransfer.LookupProfile("default")
+
+	profile, err := qrfiletransfer.ParseProfile(sheetProfile, base)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	qrft := newQRFileTransfer()
+	qrft.ApplyProfile(profile)
+
+	fmt.Printf("Encoding '%s' into a sheet of QR codes...\n", sheetInput)
+
+	return qrft.FileToSheet(sheetInput, out, layout)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// showQRVersion is the highest QR code version show generates unless --qr-version
+// is given, small enough for the QR codes to fit in a terminal of 80x40 characters
+const showQRVersion = 10
+
+var (
+	showInput    string
+	showFPS      float64
+	showLoop     bool
+	showProtocol string
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show QR codes in the terminal",
+	Long: `Encode a file into QR codes and cycle through them in the terminal, drawn
+with half block characters, so a file can be sent from a remote machine over an
+SSH session by pointing the camera of the receiving phone at the terminal.
+
+Example:
+  qrfiletransfer show -i myfile.txt
+
+The QR codes are drawn in black and white whatever the color scheme of the
+terminal, and are not written to disk. Unless --qr-version is given, they are
+limited to version 10, 65 columns and 33 lines of the terminal; enlarge the
+terminal or reduce its font size for higher versions. Press Ctrl+C to stop.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if showInput == "" {
+			return usageErrorf("input file is required")
+		}
+
+		if info, err := os.Stat(showInput); err != nil {
+			return exitErrorf(exitUsage, "input file '%s' does not exist", showInput)
+		} else if info.IsDir() {
+			return exitErrorf(exitUsage, "input '%s' is a directory, show takes a file", showInput)
+		}
+
+		if showFPS <= 0 {
+			return exitErrorf(exitUsage, "invalid frame rate %v (expected more than 0)", showFPS)
+		}
+
+		if !cmd.Flags().Changed("qr-version") {
+			targetQRVersion = showQRVersion
+		}
+
+		frames, err := terminalFrames(showInput)
+		if err != nil {
+			return fmt.Errorf("failed to encode file: %w", err)
+		}
+
+		// Stop on Ctrl+C
+		ctx, stop := signal.NotifyContext(runCtx, os.Interrupt)
+		defer stop()
+
+		interruptHandled.Store(true)
+
+		if err := playTerminalFrames(ctx, frames, showFPS, showLoop); err != nil {
+			return fmt.Errorf("failed to show QR codes: %w", err)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	// Add flags
+	showCmd.Flags().StringVarP(&showInput, "input", "i", "", "Input file (required)")
+	showCmd.Flags().Float64Var(&showFPS, "fps", 2, "Frames per second")
+	showCmd.Flags().BoolVar(&showLoop, "loop", true, "Restart from the first QR code after the last one")
+	showCmd.Flags().StringVar(&showProtocol, "protocol", qrfiletransfer.ProtocolNative,
+		"Protocol of the QR codes: native chunk payloads, ur for a BC-UR read by UR-capable apps, txqr for txqr frames, or structured-append for QR codes linked by structured append")
+	addEncoderFlags(showCmd)
+
+	_ = showCmd.RegisterFlagCompletionFunc("protocol", completeValues(qrfiletransfer.ProtocolNames()...))
+}
+
+// terminalFrame is a QR code shown by show
+type terminalFrame struct {
+	name string
+	code *qrcode.QRCode
+}
+
+// terminalFrames encodes the file at path into QR codes in memory and returns
+// them in chunk order
+func terminalFrames(path string) ([]terminalFrame, error) {
+	qrft := newQRFileTransfer()
+	if err := configureEncoder(qrft); err != nil {
+		return nil, err
+	}
+
+	if err := qrft.SetProtocol(showProtocol); err != nil {
+		return nil, withExitCode(exitUsage, err)
+	}
+
+	// Read the file from disk, and keep the session in memory
+	qrft.SetFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(afero.NewOsFs()), afero.NewMemMapFs()))
+
+	var (
+		mu     sync.Mutex
+		frames []terminalFrame
+	)
+
+	qrft.SetQRCodeObserver(func(name string, code *qrcode.QRCode) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		frames = append(frames, terminalFrame{name: name, code: code})
+	})
+
+	if err := qrft.FileToQRCodesCtx(runCtx, showInput, "/show"); err != nil {
+		return nil, err
+	}
+
+	// The data chunks sort before their parity chunks
+	sort.Slice(frames, func(i, j int) bool { return frames[i].name < frames[j].name })
+
+	return frames, nil
+}
+
+// playTerminalFrames draws frames in the terminal at fps frames per second until
+// ctx is done, or the last one was shown for a frame period unless loop is set
+func playTerminalFrames(ctx context.Context, frames []terminalFrame, fps float64, loop bool) error {
+	// Clear the screen and hide the cursor, which is shown again on return
+	fmt.Print("\x1b[2J\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\n")
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / fps))
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		if i == len(frames) {
+			if !loop {
+				return nil
+			}
+
+			i = 0
+		}
+
+		// Draw over the previous frame from the top, and clear what is left of it
+		fmt.Print("\x1b[H")
+
+		if err := frames[i].code.WriteTerminal(os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Printf("QR code %d of %d: %s (press Ctrl+C to stop)\x1b[J", i+1, len(frames), frames[i].name)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -11,43 +11,170 @@ import (
 )
 
 var (
-	splitInputFile string
-	splitOutputDir string
-	qrSize         int
-	minQRSize      int
-	maxQRSize      int
-	autoAdjustSize bool
-	recoveryLevel  string
+	splitInputFiles []string
+	splitOutputDir  string
+	qrSize          int
+	minQRSize       int
+	maxQRSize       int
+	autoAdjustSize  bool
+	recoveryLevel   string
+	concurrency     int
+	payloadFormat   string
+	chunkCodec      string
+	nextHints       int
+	maxChunkSize    int
+	targetQRVersion int
+	autoRecovery    bool
+	uniformVersion  bool
+	parity          string
+	dedup           bool
+	recursive       bool
+	caption         bool
+	imageFormat     string
+	codesPerPage    int
+	textFallback    bool
+	noRawData       bool
+	referenceDir    string
+	deterministic   bool
+	splitProtocol   string
+	contentAddress  bool
+	volumeSize      int
 )
 
 var splitCmd = &cobra.Command{
-	Use:   "split",
-	Short: "Split a file into QR code images",
+	Use:     "split",
+	Aliases: []string{"encode"},
+	Short:   "Split a file into QR code images",
 	Long: `Split a file into multiple QR code images stored in an output directory.
 
 Example:
   qrfiletransfer split -i myfile.txt -o output_directory
 
 This will split myfile.txt into multiple QR code images and store them in output_directory.
-The QR codes can later be joined back into the original file using the join command.`,
-	Run: func(cmd *cobra.Command, args []string) {
+The QR codes can later be joined back into the original file using the join command.
+
+With --recursive, the input is a directory whose whole tree is archived into the
+QR codes, and join restores the tree:
+  qrfiletransfer split -i my_directory --recursive
+
+Several inputs, given with repeated -i flags, are encoded into one batch of QR
+codes. Every file gets its own subdirectory named after its file ID, which is
+also written into each QR code, so that read, scan and join can reconstruct all
+files of the batch:
+  qrfiletransfer split -i notes.txt -i photo.jpg -o batch_qrcodes
+
+With --format svg, the QR codes are written as SVG vector images for crisp
+printing at any size. With --format pdf, a multi-page PDF paper backup with
+--per-page QR codes per page, each captioned with its chunk index, is written
+to backup.pdf next to the PNG QR codes:
+  qrfiletransfer split -i myfile.txt --format pdf --per-page 4
+
+For e-ink and embedded displays reading no PNG images, --format jpeg or bmp
+writes the QR codes as JPEG or uncompressed 24 bit BMP images, which read,
+verify and join decode like PNG images:
+  qrfiletransfer split -i firmware.bin --format bmp --size 400
+
+With --text, every chunk is also written to the text directory as a Base45 text
+file with a checksum line. If the QR codes are damaged, the file can still be
+recovered from the text files, e.g. after OCR or manual typing, with recover-text:
+  qrfiletransfer split -i secret.key --text
+
+With --codec base45, chunks are stored as Base45 text, which generic scanner
+apps display as text like --payload text, yet QR codes hold in their denser
+alphanumeric mode:
+  qrfiletransfer split -i notes.txt --codec base45
+
+With --parity, Reed-Solomon parity QR codes are added, a percentage of the
+data QR codes rounded up. Their data is kept in the parity directory, and join
+restores as many missing or damaged chunks as there are parity QR codes:
+  qrfiletransfer split -i myfile.txt --parity 10%
+
+With --dedup, the file is cut into chunks at boundaries depending on its
+content, so repeated data such as the zeros of a sparse file or the blocks a
+disk image repeats yields identical chunks, which are encoded as a single QR
+code. The manifest and the first QR code list the chunks read from another
+QR code, and join, read and scan expand them by themselves. Files without
+repeated data take more QR codes, and --parity cannot be combined with it:
+  qrfiletransfer split -i disk.img --dedup
+
+With --protocol, the QR codes hold the frames of another air-gap transfer
+protocol instead of chunk payloads, so the apps and tools speaking it receive
+the file: ur for a BC-UR ("UR:BYTES/..."), the animated QR code format of
+wallet and scanner apps, whose fountain coded parts are reassembled in any
+order, txqr for "<offset>/<total>|<data>" frames of txqr and the tools built on
+it, or structured-append for up to 16 QR codes linked by the structured append
+of the QR code standard, which scanner apps supporting it join into the file.
+The file name is not carried, and --parity adds redundant frames. join and read
+recognize the frames of every protocol by themselves:
+  qrfiletransfer split -i psbt.bin --protocol ur
+
+With --fg and --bg, the QR codes are drawn in other colors, e.g. light on dark
+for a dark screen, which not every scanner reads, and --quiet-zone sets the
+width of their border. With --logo, an image is drawn over the center of every
+QR code, and the recovery level raised until it recovers the modules the logo
+hides, which may take more QR codes:
+  qrfiletransfer split -i myfile.txt --logo brand.png --fg navy
+
+With --fast-mask, every QR code gets a fixed data mask rather than the best of
+the eight masks of the standard, which encodes large files several times faster:
+  qrfiletransfer split -i backup.tar.gz --fast-mask
+
+With --deterministic, no timestamps are recorded, so splitting the same input
+again writes byte-identical QR codes, manifest and session, which can be
+checksummed, signed, or diffed in CI:
+  qrfiletransfer split -i release.tar --deterministic
+
+With --content-addressed, the data files are named after the first 16 hex
+digits of the SHA-256 of their data, and the QR codes after that of their
+content, with the session and manifest mapping every chunk to its files. The
+qrcodes and data directories of archives of different files can then be merged,
+deduplicated, or synced with rsync without their files colliding:
+  qrfiletransfer split -i photo.jpg --content-addressed
+
+With --volume-size, or --max-per-dir, the QR codes are written into numbered
+volume directories vol001, vol002, ... of at most that many QR codes, each
+with its data files and a manifest of its own, to print or display a large set
+in batches. Parity QR codes start a volume of their own, so join restores the
+file from a subset of the volumes as long as the parity covers the missing ones:
+  qrfiletransfer split -i backup.tar --volume-size 100 --parity 20%
+
+With --emit-reference-samples, no file is split: a canonical QR code for every
+payload layout and profile is written into the given directory instead, with
+reference.json listing the content every code holds and index.html showing
+them. Scan them with common QR code apps to verify that a release stays
+readable in the field:
+  qrfiletransfer split --emit-reference-samples reference_samples`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if referenceDir != "" {
+			return emitReferenceSamples(referenceDir)
+		}
+
 		// Validate input file
-		if splitInputFile == "" {
-			fmt.Println("Error: input file is required")
-			if err := cmd.Help(); err != nil {
-				fmt.Printf("Error displaying help: %v\n", err)
-			}
-			os.Exit(1)
+		if len(splitInputFiles) == 0 {
+			return usageErrorf("input file is required")
 		}
 
-		// Check if an input file exists
-		if _, err := os.Stat(splitInputFile); os.IsNotExist(err) {
-			fmt.Printf("Error: input file '%s' does not exist\n", splitInputFile)
-			os.Exit(1)
+		// Check if the input files exist
+		var info os.FileInfo
+		for _, inputFile := range splitInputFiles {
+			var err error
+			info, err = os.Stat(inputFile)
+			if os.IsNotExist(err) {
+				return exitErrorf(exitUsage, "input file '%s' does not exist", inputFile)
+			}
+
+			if err == nil && info.IsDir() && !recursive {
+				return exitErrorf(exitUsage, "input '%s' is a directory, use --recursive to split a directory tree", inputFile)
+			}
 		}
 
+		splitInputFile := splitInputFiles[0]
+		batch := len(splitInputFiles) > 1
+
 		// If the output directory is not specified, use a default
-		if splitOutputDir == "" {
+		if splitOutputDir == "" && batch {
+			splitOutputDir = "batch_qrcodes"
+		} else if splitOutputDir == "" {
 			// Use the input file name as the output directory name
 			baseName := filepath.Base(splitInputFile)
 			baseNameWithoutExt := baseName[:len(baseName)-len(filepath.Ext(baseName))]
@@ -56,52 +183,112 @@ The QR codes can later be joined back into the original file using the join comm
 
 		// Create an output directory if it doesn't exist
 		if err := os.MkdirAll(splitOutputDir, 0755); err != nil {
-			fmt.Printf("Error creating output directory: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("failed to create output directory: %w", err)
 		}
 
 		// Create QRFileTransfer instance
-		qrft := qrfiletransfer.NewQRFileTransfer()
+		qrft := newQRFileTransfer()
+		if err := configureEncoder(qrft); err != nil {
+			return err
+		}
 
-		// Set QR code options
-		if qrSize > 0 {
-			qrft.SetQRSize(qrSize)
+		if err := configureDrawing(qrft); err != nil {
+			return err
 		}
 
-		if minQRSize > 0 {
-			qrft.SetMinQRSize(minQRSize)
+		if concurrency > 0 {
+			qrft.SetConcurrency(concurrency)
 		}
 
-		if maxQRSize > 0 {
-			qrft.SetMaxQRSize(maxQRSize)
+		qrft.SetChecksumCaption(caption)
+		qrft.SetTextFallback(textFallback)
+		qrft.SetEmitRawData(!noRawData)
+		qrft.SetDeterministic(deterministic)
+		qrft.SetContentAddressed(contentAddress)
+
+		if volumeSize < 0 {
+			return exitErrorf(exitUsage, "--volume-size must not be negative")
 		}
 
-		qrft.SetAutoAdjustQRSize(autoAdjustSize)
+		qrft.SetVolumeSize(volumeSize)
 
-		// Set a recovery level
-		var level qrcode.RecoveryLevel
-		switch recoveryLevel {
-		case "low":
-			level = qrcode.Low
-		case "medium":
-			level = qrcode.Medium
-		case "high":
-			level = qrcode.High
-		case "highest":
-			level = qrcode.Highest
+		if err := qrft.SetProtocol(splitProtocol); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		// Set the image format, a paper backup is written next to PNG images
+		switch imageFormat {
+		case "png", "pdf":
+			qrft.SetImageFormat(qrfiletransfer.ImageFormatPNG)
+		case "svg":
+			qrft.SetImageFormat(qrfiletransfer.ImageFormatSVG)
+		case "jpeg", "jpg":
+			qrft.SetImageFormat(qrfiletransfer.ImageFormatJPEG)
+		case "bmp":
+			qrft.SetImageFormat(qrfiletransfer.ImageFormatBMP)
 		default:
-			level = qrcode.Medium
+			return exitErrorf(exitUsage, "unknown image format '%s' (expected png, svg, jpeg, bmp or pdf)", imageFormat)
+		}
+
+		// join decodes the QR codes of a session without data files, which it cannot
+		// do for SVG images, and the paper backup is printed from the data
+		if noRawData && (imageFormat == "svg" || imageFormat == "pdf") {
+			return exitErrorf(exitUsage, "--no-data needs a raster format, join decodes the QR code images")
+		}
+
+		if imageFormat == "pdf" && codesPerPage < 1 {
+			return exitErrorf(exitUsage, "--per-page must be at least 1")
+		}
+
+		// Split several files into one batch of QR codes
+		if batch {
+			fmt.Printf("Splitting %d files into QR codes in directory '%s'...\n", len(splitInputFiles), splitOutputDir)
+			if err := splitBatch(qrft, splitInputFiles, splitOutputDir); err != nil {
+				return fmt.Errorf("failed to split files: %w", err)
+			}
+
+			return writeSplitPaperBackup(splitOutputDir)
+		}
+
+		// Split the file or directory tree into QR codes
+		stopEncode := timePhase("encode")
+
+		var err error
+		if recursive && info != nil && info.IsDir() {
+			fmt.Printf("Splitting directory '%s' into QR codes in directory '%s'...\n", splitInputFile, splitOutputDir)
+			err = qrft.DirToQRCodesCtx(runCtx, splitInputFile, splitOutputDir)
+		} else {
+			fmt.Printf("Splitting file '%s' into QR codes in directory '%s'...\n", splitInputFile, splitOutputDir)
+			err = qrft.FileToQRCodesCtx(runCtx, splitInputFile, splitOutputDir)
+		}
+
+		stopEncode()
+
+		if err != nil {
+			return fmt.Errorf("failed to split file: %w", err)
+		}
+
+		volumes := 0
+		if session, err := qrfiletransfer.OpenSession(splitOutputDir); err == nil {
+			setResult("session", newSessionResult(session))
+			volumes = session.VolumeCount()
 		}
-		qrft.SetRecoveryLevel(level)
 
-		// Split the file into QR codes
-		fmt.Printf("Splitting file '%s' into QR codes in directory '%s'...\n", splitInputFile, splitOutputDir)
-		if err := qrft.FileToQRCodes(splitInputFile, splitOutputDir); err != nil {
-			fmt.Printf("Error splitting file: %v\n", err)
-			os.Exit(1)
+		// Report chunk size reductions made because a chunk did not fit in a QR code
+		if manifest, err := qrfiletransfer.LoadManifest(splitOutputDir); err == nil {
+			for _, r := range manifest.ChunkSizeReductions {
+				fmt.Printf("Note: reduced the chunk size from %d to %d bytes (%d to %d chunks): %s\n",
+					r.FromChunkSize, r.ToChunkSize, r.FromChunks, r.ToChunks, r.Reason)
+			}
+		}
+
+		if volumes > 0 {
+			fmt.Printf("Successfully split file into QR codes. QR codes are stored in %d volumes, '%s/vol001/qrcodes' and on\n", volumes, splitOutputDir)
+		} else {
+			fmt.Printf("Successfully split file into QR codes. QR codes are stored in '%s/qrcodes'\n", splitOutputDir)
 		}
 
-		fmt.Printf("Successfully split file into QR codes. QR codes are stored in '%s/qrcodes'\n", splitOutputDir)
+		return writeSplitPaperBackup(splitOutputDir)
 	},
 }
 
@@ -109,15 +296,212 @@ func init() {
 	rootCmd.AddCommand(splitCmd)
 
 	// Add flags
-	splitCmd.Flags().StringVarP(&splitInputFile, "input", "i", "",
-		"Input file to split (required)")
+	splitCmd.Flags().StringArrayVarP(&splitInputFiles, "input", "i", nil,
+		"Input file, or directory with --recursive, to split (required, repeat to split several files into one batch)")
 	splitCmd.Flags().StringVarP(&splitOutputDir, "output", "o", "",
 		"Output directory for QR codes (default: <filename>_qrcodes)")
-	splitCmd.Flags().IntVarP(&qrSize, "size", "s", 0, "QR code size in pixels (default: 800)")
-	splitCmd.Flags().IntVar(&minQRSize, "min-size", 0, "Minimum QR code size in pixels (default: 400)")
-	splitCmd.Flags().IntVar(&maxQRSize, "max-size", 0, "Maximum QR code size in pixels (default: 1600)")
-	splitCmd.Flags().BoolVar(&autoAdjustSize, "auto-adjust", true,
+	addEncoderFlags(splitCmd)
+	addDrawingFlags(splitCmd)
+	splitCmd.Flags().IntVarP(&concurrency, "concurrency", "j", 0,
+		"Number of QR codes generated in parallel (default: number of CPUs)")
+	splitCmd.Flags().BoolVar(&recursive, "recursive", false,
+		"Split a directory tree, archived with its nested paths, instead of a single file")
+	splitCmd.Flags().BoolVar(&caption, "caption", false,
+		"Print the chunk index and the first 6 hex digits of its SHA-256 below each QR code for manual triage")
+	splitCmd.Flags().StringVar(&imageFormat, "format", "png",
+		"QR code image format (png, svg, jpeg, bmp), or pdf to also write a printable paper backup to backup.pdf")
+	splitCmd.Flags().StringVar(&imageFormat, "image-format", "png", "Same as --format")
+	splitCmd.Flags().IntVar(&codesPerPage, "per-page", qrfiletransfer.DefaultPaperLayout().CodesPerPage,
+		"Number of QR codes per page of the paper backup written with --format pdf")
+	splitCmd.Flags().BoolVar(&textFallback, "text", false,
+		"Also write every chunk as a Base45 text file that recover-text can read back after OCR or manual typing")
+	splitCmd.Flags().BoolVar(&noRawData, "no-data", false,
+		"Do not write the raw data of every chunk into data/, halving the disk space; join then decodes the QR code images")
+	splitCmd.Flags().BoolVar(&deterministic, "deterministic", false,
+		"Omit timestamps from the metadata, so the same input always yields byte-identical QR codes")
+	splitCmd.Flags().BoolVar(&contentAddress, "content-addressed", false,
+		"Name the data files and QR codes after the SHA-256 of their content, so archives can be merged or synced without collisions")
+	splitCmd.Flags().IntVar(&volumeSize, "volume-size", 0,
+		"Most QR codes of every volume directory, vol001, vol002, ..., each with its own manifest (default: a single qrcodes directory)")
+	splitCmd.Flags().IntVar(&volumeSize, "max-per-dir", 0, "Same as --volume-size")
+	splitCmd.Flags().StringVar(&splitProtocol, "protocol", qrfiletransfer.ProtocolNative,
+		"Protocol of the QR codes: native chunk payloads, ur for a BC-UR read by UR-capable apps, txqr for txqr frames, or structured-append for QR codes linked by structured append")
+	splitCmd.Flags().StringVar(&referenceDir, "emit-reference-samples", "",
+		"Write canonical QR codes of every payload layout and profile into this directory for interop tests with QR code apps, instead of splitting")
+
+	_ = splitCmd.RegisterFlagCompletionFunc("format", completeValues("png", "svg", "jpeg", "bmp", "pdf"))
+	_ = splitCmd.RegisterFlagCompletionFunc("image-format", completeValues("png", "svg", "jpeg", "bmp", "pdf"))
+	_ = splitCmd.RegisterFlagCompletionFunc("protocol", completeValues(qrfiletransfer.ProtocolNames()...))
+	markDirFlags(splitCmd, "output", "emit-reference-samples")
+}
+
+// addEncoderFlags adds the QR code flags shared by split and estimate to cmd
+func addEncoderFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&qrSize, "size", "s", 0, "QR code size in pixels (default: 800)")
+	cmd.Flags().IntVar(&minQRSize, "min-size", 0, "Minimum QR code size in pixels (default: 400)")
+	cmd.Flags().IntVar(&maxQRSize, "max-size", 0, "Maximum QR code size in pixels (default: 1600)")
+	cmd.Flags().BoolVar(&autoAdjustSize, "auto-adjust", true,
 		"Automatically adjust QR code size based on data size")
-	splitCmd.Flags().StringVarP(&recoveryLevel, "recovery", "r", "medium",
+	cmd.Flags().StringVarP(&recoveryLevel, "recovery", "r", "medium",
 		"QR code recovery level (low, medium, high, highest)")
+	cmd.Flags().BoolVar(&autoRecovery, "auto-recovery", false,
+		"Give every QR code the highest recovery level that fits in a QR code of --qr-version, --recovery being the lowest")
+	cmd.Flags().StringVar(&payloadFormat, "payload", "binary",
+		"QR code payload format (binary, text)")
+	cmd.Flags().StringVar(&chunkCodec, "codec", "",
+		"Codec of the chunk data, overriding --payload: raw for binary payloads, base64 or base45 for text payloads")
+	cmd.Flags().IntVar(&nextHints, "next-hints", 0,
+		"Number of following chunk indices embedded in each QR code, so receivers detect skipped chunks immediately")
+	cmd.Flags().IntVar(&maxChunkSize, "chunk-size", 0,
+		"Maximum number of file bytes per QR code (default: as many as fit in the QR code)")
+	cmd.Flags().IntVar(&targetQRVersion, "qr-version", 0,
+		"Highest QR code version generated, 1 to 40, for scanners that struggle with dense codes (default: no limit)")
+	cmd.Flags().BoolVar(&uniformVersion, "uniform-version", false,
+		"Draw every QR code at --qr-version, including the last one, so all frames of a video have the same size")
+	cmd.Flags().StringVar(&parity, "parity", "0%",
+		"Parity QR codes added in percent of the data QR codes, e.g. 10%, so the file survives losing as many QR codes")
+	cmd.Flags().BoolVar(&dedup, "dedup", false,
+		"Encode identical chunks of repetitive or sparse files as a single QR code, cutting chunks at content-defined boundaries")
+
+	_ = cmd.RegisterFlagCompletionFunc("recovery", completeValues(recoveryLevels...))
+	_ = cmd.RegisterFlagCompletionFunc("payload", completeValues("binary", "text"))
+	_ = cmd.RegisterFlagCompletionFunc("codec", completeValues(qrfiletransfer.CodecNames()...))
+}
+
+// configureEncoder applies the flags of addEncoderFlags to qrft
+func configureEncoder(qrft *qrfiletransfer.QRFileTransfer) error {
+	// Set QR code options
+	if qrSize > 0 {
+		qrft.SetQRSize(qrSize)
+	}
+
+	if minQRSize > 0 {
+		qrft.SetMinQRSize(minQRSize)
+	}
+
+	if maxQRSize > 0 {
+		qrft.SetMaxQRSize(maxQRSize)
+	}
+
+	qrft.SetAutoAdjustQRSize(autoAdjustSize)
+
+	// Set a recovery level
+	var level qrcode.RecoveryLevel
+	switch recoveryLevel {
+	case "low":
+		level = qrcode.Low
+	case "medium":
+		level = qrcode.Medium
+	case "high":
+		level = qrcode.High
+	case "highest":
+		level = qrcode.Highest
+	default:
+		level = qrcode.Medium
+	}
+	qrft.SetRecoveryLevel(level)
+	qrft.SetAutoRecoveryLevel(autoRecovery)
+
+	// Set the payload format
+	switch payloadFormat {
+	case "binary":
+		qrft.SetPayloadFormat(qrfiletransfer.PayloadFormatBinary)
+	case "text":
+		qrft.SetPayloadFormat(qrfiletransfer.PayloadFormatText)
+	default:
+		return exitErrorf(exitUsage, "unknown payload format '%s' (expected binary or text)", payloadFormat)
+	}
+
+	if chunkCodec != "" {
+		if err := qrft.SetCodec(chunkCodec); err != nil {
+			return withExitCode(exitUsage, err)
+		}
+	}
+
+	qrft.SetNextHints(nextHints)
+
+	if targetQRVersion < 0 || targetQRVersion > 40 {
+		return exitErrorf(exitUsage, "invalid QR code version %d (expected 1-40)", targetQRVersion)
+	}
+
+	qrft.SetMaxChunkSize(maxChunkSize)
+	qrft.SetTargetQRVersion(targetQRVersion)
+
+	if uniformVersion && targetQRVersion == 0 {
+		return exitErrorf(exitUsage, "--uniform-version requires --qr-version")
+	}
+
+	qrft.SetUniformQRVersion(uniformVersion)
+
+	percent, err := qrfiletransfer.ParseParity(parity)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+
+	qrft.SetParity(percent)
+	qrft.SetDedup(dedup)
+
+	if dedup && percent > 0 {
+		return exitErrorf(exitUsage, "--dedup cannot be combined with --parity")
+	}
+
+	return nil
+}
+
+// emitReferenceSamples writes the reference samples into dir
+func emitReferenceSamples(dir string) error {
+	set, err := qrfiletransfer.WriteReferenceSamples(dir)
+	if err != nil {
+		return fmt.Errorf("failed to write reference samples: %w", err)
+	}
+
+	for _, s := range set.Samples {
+		fmt.Printf("%-32s %-10s payload version %d, recovery %s, QR version %d\n",
+			s.Image, s.Protocol, s.PayloadFormatVersion, s.RecoveryLevel, s.QRVersion)
+	}
+
+	setResult("samples", len(set.Samples))
+	setResult("output", newOutputResult(dir))
+
+	fmt.Printf("Successfully wrote %d reference samples to '%s', open %s to scan them\n",
+		len(set.Samples), dir, filepath.Join(dir, "index.html"))
+
+	return nil
+}
+
+// writeSplitPaperBackup writes the paper backup of the QR codes in dir when
+// --format pdf is set
+func writeSplitPaperBackup(dir string) error {
+	if imageFormat != "pdf" {
+		return nil
+	}
+
+	path := filepath.Join(dir, qrfiletransfer.PaperBackupFileName)
+	if err := writePaperBackup(dir, path, codesPerPage); err != nil {
+		return fmt.Errorf("failed to write paper backup: %w", err)
+	}
+
+	setResult("paper_backup", newOutputResult(path))
+	fmt.Printf("Paper backup written to '%s'\n", path)
+
+	return nil
+}
+
+// writePaperBackup writes the PDF paper backup of the session or batch in dir to path
+func writePaperBackup(dir, path string, perPage int) (err error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create paper backup: %w", err)
+	}
+
+	defer func() {
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close paper backup: %w", closeErr)
+		}
+	}()
+
+	layout := qrfiletransfer.DefaultPaperLayout()
+	layout.CodesPerPage = perPage
+
+	return qrfiletransfer.WritePaperBackup(out, dir, layout)
 }
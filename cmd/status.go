@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var statusInputDir string
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of a session directory",
+	Long: `Show the state of a session directory created by the split command.
+
+Example:
+  qrfiletransfer status -i output_directory
+
+This will read the session file of output_directory and report the encoded file,
+the settings used, and how many QR codes and data files are present.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input directory
+		if statusInputDir == "" {
+			return usageErrorf("input directory is required")
+		}
+
+		// Load the session describing the input directory
+		session, err := qrfiletransfer.OpenSession(statusInputDir)
+		if err != nil {
+			return err
+		}
+
+		state := "complete"
+		if !session.Complete {
+			state = "incomplete"
+		}
+
+		if session.Legacy {
+			state += ", legacy archive without session file"
+		}
+
+		setResult("session", newSessionResult(session))
+
+		cmd.Printf("Session:  %s (%s, layout version %d)\n", session.Dir(), state, session.Version)
+		cmd.Printf("File:     %s (%d bytes)\n", session.File.Name, session.File.Size)
+		cmd.Printf("SHA-256:  %s\n", session.File.Hash)
+		cmd.Printf("Chunks:   %d\n", len(session.Chunks))
+
+		// The layout directories of a session split into volumes are in every volume
+		where := func(dir, name string) string {
+			if session.VolumeCount() == 0 {
+				return dir
+			}
+
+			return filepath.Join(session.Dir(), "vol*", name)
+		}
+
+		if volumes := session.VolumeCount(); volumes > 0 {
+			setResult("volumes", volumes)
+			cmd.Printf("Volumes:  %d of up to %d QR codes\n", volumes, session.Settings.VolumeSize)
+		}
+
+		// Count the artifacts that are present for every chunk
+		if qrDir := session.QRCodesDir(); qrDir != "" {
+			qrCodes := countArtifacts(session.Chunks, session.QRCodeFile)
+			setResult("qr_codes", qrCodes)
+			cmd.Printf("QR codes: %d/%d in %s\n", qrCodes, len(session.Chunks), where(qrDir, session.Layout.QRCodes))
+		}
+
+		if dataDir := session.DataDir(); dataDir != "" {
+			dataFiles := countArtifacts(session.Chunks, session.DataFile)
+			setResult("data_files", dataFiles)
+			cmd.Printf("Data:     %d/%d in %s\n", dataFiles, len(session.Chunks), where(dataDir, session.Layout.Data))
+		}
+
+		if parityDir := session.ParityDir(); parityDir != "" {
+			parityFiles := countArtifacts(session.Parity, session.ParityFile)
+			setResult("parity_files", parityFiles)
+			cmd.Printf("Parity:   %d/%d in %s\n", parityFiles, len(session.Parity), where(parityDir, session.Layout.Parity))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	// Add flags
+	statusCmd.Flags().StringVarP(&statusInputDir, "input", "i", "", "Session directory (required)")
+
+	markDirFlags(statusCmd, "input")
+}
+
+// countArtifacts counts the chunks of a session whose artifact, located by path, exists
+func countArtifacts(chunks []qrfiletransfer.SessionChunk, path func(name string) string) int {
+	count := 0
+
+	for _, chunk := range chunks {
+		if _, err := os.Stat(path(chunk.Name)); err == nil {
+			count++
+		}
+	}
+
+	return count
+}
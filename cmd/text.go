@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv is the environment variable the text command reads the passphrase
+// from without --passphrase-file
+const passphraseEnv = "QRFILETRANSFER_PASSPHRASE"
+
+// maxSnippetInput bounds the snippet read from standard input
+const maxSnippetInput = 1 << 20
+
+var (
+	textQRFile         string
+	textDecodeFile     string
+	textEncrypt        bool
+	textPassphraseFile string
+	textProfile        string
+)
+
+var textCmd = &cobra.Command{
+	Use:   "text [snippet]",
+	Short: "Encode a short text or secret into a single QR code",
+	Long: `Encode a literal string, or a snippet read from standard input, into a single
+QR code, and decode it back to standard output, for quick one-off transfers.
+
+Example:
+  qrfiletransfer text "some secret" --qr secret.png
+  qrfiletransfer text --decode secret.png
+
+Without --qr, the QR code is printed to the terminal. A snippet read from
+standard input loses a single trailing newline, and a decoded snippet is printed
+followed by one:
+  cat token.txt | qrfiletransfer text --qr token.png
+
+The QR code uses the same metadata and payload header as split, so read and join
+also restore it, as snippet.txt. Snippets of up to 500 bytes fit in a single
+QR code. The QR code of any file split into a single chunk decodes too.
+
+With --encrypt, the snippet is encrypted with AES-256-GCM under a key derived
+from a passphrase, read from --passphrase-file or the ` + passphraseEnv + `
+environment variable. Decoding an encrypted snippet needs the same passphrase:
+  ` + passphraseEnv + `=hunter2 qrfiletransfer text "api key" --encrypt --qr key.png
+  ` + passphraseEnv + `=hunter2 qrfiletransfer text --decode key.png`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if textDecodeFile != "" {
+			if len(args) > 0 {
+				return exitErrorf(exitUsage, "a snippet cannot be given with --decode")
+			}
+
+			return decodeSnippet()
+		}
+
+		snippet, err := readSnippet(args)
+		if err != nil {
+			return fmt.Errorf("failed to read snippet: %w", err)
+		}
+
+		var passphrase string
+		if textEncrypt {
+			if passphrase, err = readPassphrase(); err != nil {
+				return err
+			}
+		}
+
+		base, _ := qrfiletransfer.LookupProfile("default")
+
+		profile, err := qrfiletransfer.ParseProfile(textProfile, base)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		qrft := newQRFileTransfer()
+		qrft.ApplyProfile(profile)
+
+		code, err := qrft.SnippetToQRCode(snippet, passphrase)
+		if errors.Is(err, qrfiletransfer.ErrSnippetTooLong) {
+			return fmt.Errorf("failed to encode snippet: %w, use split for longer content", err)
+		} else if err != nil {
+			return fmt.Errorf("failed to encode snippet: %w", err)
+		}
+
+		if textQRFile == "" {
+			fmt.Print(code.ToSmallString(false))
+
+			return nil
+		}
+
+		if err := code.WriteFile(profile.QRSize, textQRFile); err != nil {
+			return fmt.Errorf("failed to write QR code: %w", err)
+		}
+
+		setResult("qr_version", code.VersionNumber)
+		setResult("output", newOutputResult(textQRFile))
+		fmt.Printf("Successfully wrote QR code version %d: %s\n", code.VersionNumber, textQRFile)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(textCmd)
+
+	// Add flags
+	textCmd.Flags().StringVar(&textQRFile, "qr", "",
+		"Write the QR code to this PNG file instead of printing it to the terminal")
+	textCmd.Flags().StringVarP(&textDecodeFile, "decode", "d", "",
+		"Decode the snippet of this QR code image and print it to standard output")
+	textCmd.Flags().BoolVar(&textEncrypt, "encrypt", false,
+		"Encrypt the snippet with a passphrase (from --passphrase-file or "+passphraseEnv+")")
+	textCmd.Flags().StringVar(&textPassphraseFile, "passphrase-file", "",
+		"File whose first line is the passphrase")
+	textCmd.Flags().StringVar(&textProfile, "profile", "profile:default",
+		"Encoder settings, e.g. profile:default,recovery=high")
+
+	_ = textCmd.RegisterFlagCompletionFunc("profile", completeProfiles())
+	_ = textCmd.MarkFlagFilename("qr", "png")
+}
+
+// decodeSnippet prints the snippet of the --decode QR code image
+func decodeSnippet() error {
+	content, err := readQRCodeFromImage(textDecodeFile)
+	if err != nil {
+		return fmt.Errorf("failed to read QR code: %w", err)
+	}
+
+	qrft := newQRFileTransfer()
+
+	// The passphrase is only needed for an encrypted snippet
+	passphrase, err := readPassphrase()
+	if err != nil && textPassphraseFile != "" {
+		return err
+	}
+
+	snippet, err := qrft.QRCodeToSnippet(content, passphrase)
+	if errors.Is(err, qrfiletransfer.ErrPassphraseRequired) {
+		return fmt.Errorf("failed to decode snippet: %w, give the passphrase with --passphrase-file or %s", err, passphraseEnv)
+	} else if err != nil {
+		return fmt.Errorf("failed to decode snippet: %w", err)
+	}
+
+	_, err = os.Stdout.Write(append(snippet, '\n'))
+
+	return err
+}
+
+// readSnippet returns the snippet given as argument, or read from standard input
+// without it or for "-"
+func readSnippet(args []string) ([]byte, error) {
+	if len(args) > 0 && args[0] != "-" {
+		return []byte(args[0]), nil
+	}
+
+	// Anything longer than a snippet is rejected by SnippetToQRCode
+	data, err := io.ReadAll(io.LimitReader(os.Stdin, maxSnippetInput))
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasSuffix(data, []byte("\r\n")) {
+		return data[:len(data)-2], nil
+	}
+
+	return bytes.TrimSuffix(data, []byte("\n")), nil
+}
+
+// readPassphrase returns the passphrase from --passphrase-file or the environment
+func readPassphrase() (string, error) {
+	if textPassphraseFile != "" {
+		data, err := os.ReadFile(textPassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+
+		passphrase, _, _ := strings.Cut(string(data), "\n")
+
+		if passphrase = strings.TrimSuffix(passphrase, "\r"); passphrase != "" {
+			return passphrase, nil
+		}
+
+		return "", fmt.Errorf("passphrase file %s is empty", textPassphraseFile)
+	}
+
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	return "", fmt.Errorf("a passphrase is required, give it with --passphrase-file or %s", passphraseEnv)
+}
@@ -0,0 +1,220 @@
+//go:build !nodecode
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	transcodeInput       string
+	transcodeOutputDir   string
+	transcodeTo          string
+	transcodeConcurrency int
+	transcodeDeterminism bool
+)
+
+var transcodeCmd = &cobra.Command{
+	Use:   "transcode",
+	Short: "Regenerate a QR code set with different settings",
+	Long: `Decode an existing set of QR codes and regenerate it with different size,
+recovery, or payload settings, without needing the original file.
+
+Example:
+  qrfiletransfer transcode -i myfile_qrcodes --to profile:print-archive
+
+The input is a session directory created by split, or a directory of QR code
+images. The --to spec is a comma separated list of "profile:<name>" items, which
+select a built-in profile, and "<key>=<value>" items, which override a single
+setting, applied in order on top of the settings of the input session:
+  profile:print-archive,payload=text
+  recovery=high,next-hints=2
+
+Profiles: ` + strings.Join(qrfiletransfer.ProfileNames(), ", ") + `
+Keys:     size, min-size, max-size, auto-adjust, recovery, auto-recovery, payload, codec, next-hints,
+          caption, text, parity`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input
+		if transcodeInput == "" || transcodeTo == "" {
+			return usageErrorf("input directory and --to are required")
+		}
+
+		if _, err := os.Stat(transcodeInput); os.IsNotExist(err) {
+			return exitErrorf(exitUsage, "input directory '%s' does not exist", transcodeInput)
+		}
+
+		images, base, err := transcodeSource(transcodeInput)
+		if err != nil {
+			return err
+		}
+
+		profile, err := qrfiletransfer.ParseProfile(transcodeTo, base)
+		if err != nil {
+			return withExitCode(exitUsage, err)
+		}
+
+		// If the output directory is not specified, use a default
+		if transcodeOutputDir == "" {
+			transcodeOutputDir = strings.TrimSuffix(filepath.Clean(transcodeInput), string(filepath.Separator)) + "_transcoded"
+		}
+
+		if filepath.Clean(transcodeOutputDir) == filepath.Clean(transcodeInput) {
+			return exitErrorf(exitUsage, "output directory must differ from the input directory")
+		}
+
+		// Decode the QR codes into a temporary directory
+		tempDir, err := os.MkdirTemp("", "qrcode_transcode_*")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+
+		releaseTemp := trackTemp(tempDir)
+
+		defer func() {
+			if err := releaseTemp(); err != nil {
+				diag.Warnf(diagnostics.CodeCleanupFailed, "", "failed to remove temporary directory: %v", err)
+			}
+		}()
+
+		stateDir := filepath.Join(tempDir, "decoded")
+
+		fmt.Printf("Decoding %d QR codes from '%s'...\n", len(images), transcodeInput)
+		if err := decodeQRCodeImages(images, filepath.Join(stateDir, "data")); err != nil {
+			return fmt.Errorf("failed to decode QR codes: %w", err)
+		}
+
+		// Rebuild the original file, whose hash is verified, under its own name so
+		// the new session records it
+		session, err := qrfiletransfer.OpenSession(stateDir)
+		if err != nil {
+			return err
+		}
+
+		fileName := filepath.Base(session.File.Name)
+		if session.File.Name == "" {
+			fileName = "transcoded"
+		}
+
+		filePath, err := safeOutputPath(filepath.Join(tempDir, fileName))
+		if err != nil {
+			return err
+		}
+
+		qrft := newQRFileTransfer()
+		if err := qrft.QRCodesToFileCtx(runCtx, stateDir, filePath); err != nil {
+			return fmt.Errorf("failed to reconstruct file: %w", err)
+		}
+
+		// Encode it again with the new settings
+		qrft.ApplyProfile(profile)
+
+		if transcodeConcurrency > 0 {
+			qrft.SetConcurrency(transcodeConcurrency)
+		}
+
+		qrft.SetDeterministic(transcodeDeterminism)
+
+		fmt.Printf("Encoding '%s' into QR codes in directory '%s'...\n", fileName, transcodeOutputDir)
+		if err := qrft.FileToQRCodesCtx(runCtx, filePath, transcodeOutputDir); err != nil {
+			return fmt.Errorf("failed to split file: %w", err)
+		}
+
+		if session, err := qrfiletransfer.OpenSession(transcodeOutputDir); err == nil {
+			setResult("session", newSessionResult(session))
+		}
+
+		fmt.Printf("Successfully transcoded QR codes. QR codes are stored in '%s/qrcodes'\n", transcodeOutputDir)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(transcodeCmd)
+
+	// Add flags
+	transcodeCmd.Flags().StringVarP(&transcodeInput, "input", "i", "",
+		"Session directory or directory of QR code images (required)")
+	transcodeCmd.Flags().StringVarP(&transcodeOutputDir, "output", "o", "",
+		"Output directory for the new QR codes (default: <input>_transcoded)")
+	transcodeCmd.Flags().StringVar(&transcodeTo, "to", "",
+		"Target settings, e.g. profile:print-archive or recovery=high,payload=text (required)")
+	transcodeCmd.Flags().BoolVar(&transcodeDeterminism, "deterministic", false,
+		"Omit timestamps from the metadata, so the same input always yields byte-identical QR codes")
+	transcodeCmd.Flags().IntVarP(&transcodeConcurrency, "concurrency", "j", 0,
+		"Number of QR codes generated in parallel (default: number of CPUs)")
+
+	_ = transcodeCmd.RegisterFlagCompletionFunc("to", completeProfiles())
+	markDirFlags(transcodeCmd, "input", "output")
+}
+
+// transcodeSource returns the QR code images of a transcode input and the settings
+// they were created with. Directories without a session yield every image they
+// contain and the default settings.
+func transcodeSource(dir string) ([]string, qrfiletransfer.Profile, error) {
+	base, _ := qrfiletransfer.LookupProfile("default")
+
+	session, err := qrfiletransfer.OpenSession(dir)
+	if err == nil && session.QRCodesDir() != "" {
+		if format := qrfiletransfer.ImageFormat(session.Settings.ImageFormat); !format.Decodable() {
+			return nil, base, fmt.Errorf("QR codes of %s are %s images and cannot be decoded", dir, format)
+		}
+
+		if !session.Legacy {
+			base = qrfiletransfer.ProfileFromSettings(session.Settings)
+		}
+
+		images := make([]string, 0, len(session.Chunks))
+
+		for _, chunk := range session.Chunks {
+			images = append(images, session.QRCodeFile(chunk.Name))
+		}
+
+		return images, base, nil
+	}
+
+	images, err := globImages(dir)
+	if err != nil {
+		return nil, base, fmt.Errorf("failed to list QR code images: %w", err)
+	}
+
+	if len(images) == 0 {
+		return nil, base, fmt.Errorf("no QR code images found in %s", dir)
+	}
+
+	return images, base, nil
+}
+
+// decodeQRCodeImages decodes the chunk payload of every image into a data file in
+// dataDir. Every image must decode since the original file is not available.
+func decodeQRCodeImages(images []string, dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	for i, imagePath := range images {
+		payloads, _, err := readPayloadsFromGroup([]string{imagePath})
+		if err != nil {
+			return err
+		}
+
+		for _, payload := range payloads {
+			dataFilePath := filepath.Join(dataDir, filepath.Base(payload.Name)+".dat")
+			if err := qrfiletransfer.WriteFileAtomic(osFs, dataFilePath, payload.Data, 0644); err != nil {
+				return fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+			}
+		}
+
+		fmt.Printf("Decoded QR code %d/%d\r", i+1, len(images))
+	}
+	fmt.Println() // Print a newline after the progress indicator
+
+	return nil
+}
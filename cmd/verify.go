@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/features"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyInputDir string
+	verifyDataOnly bool
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the QR codes of a session reconstruct the original file",
+	Long: `Decode every QR code of a session directory and check the chunks and the file
+they reconstruct, without writing the file.
+
+Example:
+  qrfiletransfer verify -i myfile_qrcodes
+
+Every chunk is checked against its CRC-32 and the manifest, and the file against
+the SHA-256 recorded in the metadata, the session, and the manifest. All damaged
+or unreadable chunks are reported in one pass, and the command exits with a
+non-zero status unless the whole file passes. Run it before deleting the source
+file.
+
+With --data, the data files of the session are checked instead of decoding the
+QR codes, which is faster but does not catch unreadable QR code images. PNG,
+JPEG and BMP images are decoded. A directory written by read or scan, a legacy
+archive, and every session in a build without QR code decoding are checked from
+their data files, as are sessions of SVG images, which cannot be decoded, with a
+warning. The files of a batch are verified one by one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate input directory
+		if verifyInputDir == "" {
+			return usageErrorf("input directory is required")
+		}
+
+		if _, err := os.Stat(verifyInputDir); os.IsNotExist(err) {
+			return exitErrorf(exitUsage, "input directory '%s' does not exist", verifyInputDir)
+		}
+
+		dirs := []string{verifyInputDir}
+
+		if qrfiletransfer.IsBatch(verifyInputDir) {
+			ids, err := qrfiletransfer.BatchFileIDs(verifyInputDir)
+			if err != nil {
+				return err
+			}
+
+			dirs = dirs[:0]
+			for _, id := range ids {
+				dirs = append(dirs, filepath.Join(verifyInputDir, id))
+			}
+		}
+
+		// Verify every file of a batch, failing as the first that failed
+		var failure error
+
+		for i, dir := range dirs {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			err := verifySession(dir)
+			if err != nil && !errors.Is(err, errFailed) {
+				return err
+			}
+
+			if failure == nil {
+				failure = err
+			}
+		}
+
+		return failure
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// Add flags
+	verifyCmd.Flags().StringVarP(&verifyInputDir, "input", "i", "", "Session directory, or directory written by read or scan (required)")
+	verifyCmd.Flags().BoolVar(&verifyDataOnly, "data", false,
+		"Check the data files of the session instead of decoding its QR codes")
+
+	markDirFlags(verifyCmd, "input")
+}
+
+// verifySession verifies the session in dir and prints its report. A file that
+// failed returns errFailed, exiting with the code of its first failure.
+func verifySession(dir string) error {
+	session, err := qrfiletransfer.OpenSession(dir)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := qrfiletransfer.LoadManifest(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	checkedDir := dir
+	source := "data files"
+
+	decode := !verifyDataOnly && features.Decode && !session.Legacy && session.QRCodesDir() != ""
+
+	// SVG images are checked from the data files, as they cannot be decoded
+	if format := qrfiletransfer.ImageFormat(session.Settings.ImageFormat); decode && !format.Decodable() {
+		diag.Warnf(diagnostics.CodeOptionalOutput, dir, "the QR codes are %s images and cannot be decoded, checking the data files", format)
+
+		decode = false
+	}
+
+	if decode {
+		decodedDir, releaseTemp, err := decodeSessionQRCodes(session)
+
+		defer func() {
+			_ = releaseTemp()
+		}()
+
+		if err != nil {
+			appendResult("sessions", verifyResult{Dir: dir, Source: "QR codes", Error: err.Error()})
+
+			fmt.Printf("Session:  %s\n", dir)
+			fmt.Printf("FAIL:     %v\n", err)
+
+			return withExitCode(max(exitCode(err), exitFailure), errFailed)
+		}
+
+		checkedDir = decodedDir
+		source = "QR codes"
+	}
+
+	report, err := newQRFileTransfer().VerifyIntegrity(checkedDir, manifest)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", dir, err)
+	}
+
+	verified := verifyResult{Dir: dir, Source: source, Passed: report.OK()}
+	if report.File != nil {
+		verified.Hash = fmt.Sprintf("%x", report.File.Hash)
+	}
+
+	for _, c := range report.Failed() {
+		verified.FailedChunks = append(verified.FailedChunks, c.Index)
+	}
+
+	if report.Err != nil {
+		verified.Error = report.Err.Error()
+	}
+
+	appendResult("sessions", verified)
+
+	fmt.Printf("Session:  %s (checked from its %s)\n", dir, source)
+
+	if report.File != nil {
+		fmt.Printf("File:     %s (%d bytes)\n", report.File.Name, report.File.Size)
+		fmt.Printf("SHA-256:  %x\n", report.File.Hash)
+	}
+
+	for _, c := range report.Failed() {
+		fmt.Printf("Chunk %d: %v\n", c.Index, c.Err)
+	}
+
+	if !report.OK() {
+		fmt.Printf("FAIL:     %s\n", report)
+
+		cause := report.Err
+		if failed := report.Failed(); cause == nil && len(failed) > 0 {
+			cause = failed[0].Err
+		}
+
+		return withExitCode(max(exitCode(cause), exitFailure), errFailed)
+	}
+
+	fmt.Printf("PASS:     %s\n", report)
+
+	return nil
+}
+
+// decodeSessionQRCodes decodes the QR code images of session, in the QR codes
+// directory or those of its volumes, into a temporary directory, and returns the
+// directory holding the decoded chunks with the function removing it. QR codes
+// that cannot be read leave their chunks missing, only a session none of which is
+// read fails.
+func decodeSessionQRCodes(session *qrfiletransfer.Session) (string, func() error, error) {
+	tempDir, err := os.MkdirTemp("", "qrcode_verify_*")
+	if err != nil {
+		return "", func() error { return nil }, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	releaseTemp := trackTemp(tempDir)
+
+	for _, qrDir := range session.QRCodesDirs() {
+		// A missing volume leaves its chunks missing
+		if _, err := os.Stat(qrDir); err != nil && session.VolumeCount() > 0 {
+			continue
+		}
+
+		fmt.Printf("Decoding the QR codes in %s...\n", qrDir)
+
+		if err := readQRCodesFromFrames(qrDir, tempDir, 0); err != nil {
+			return "", releaseTemp, err
+		}
+	}
+
+	// The chunks of a file of a batch are decoded into the directory of its ID
+	if id := session.Settings.FileID; id != "" {
+		return filepath.Join(tempDir, id), releaseTemp, nil
+	}
+
+	return tempDir, releaseTemp, nil
+}
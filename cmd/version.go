@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/features"
+	"github.com/spf13/cobra"
+)
+
+var versionJSON bool
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Show the version and capabilities of this binary",
+	Long: `Show the version of this binary, the file and payload format versions it
+reads and writes, the optional features compiled in, and the availability of the
+external tools they use.
+
+Example:
+  qrfiletransfer version --json
+
+With --json, the report itself is printed as a JSON object for orchestration
+scripts, the "capabilities" of the result of the global --json: "version",
+"go_version", "os", "arch", "protocols" with the "name" and "versions" of every
+format, "features" mapping cli, video, decode, webcam, pdf, and wasm to whether
+they are available, and "tools" mapping ffmpeg to its "available", "path", and
+"version".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		capabilities := features.Capabilities()
+		setResult("capabilities", capabilities)
+
+		if !versionJSON {
+			fmt.Print(capabilities)
+
+			return nil
+		}
+
+		data, err := json.MarshalIndent(capabilities, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode capabilities: %w", err)
+		}
+
+		fmt.Println(string(data))
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	// Add flags
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "Print the capabilities as JSON")
+}
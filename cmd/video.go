@@ -0,0 +1,390 @@
+//go:build !novideo
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+	"github.com/dyammarcano/qrfiletransfer/pkg/video"
+)
+
+// checkFFmpegInstalled checks if ffmpeg is installed on the system.
+func checkFFmpegInstalled() error {
+	cmd := exec.CommandContext(runCtx, "ffmpeg", "-version")
+	if err := cmd.Run(); err != nil {
+		return errFFmpegMissing
+	}
+
+	return nil
+}
+
+// generateQRCodeVideo generates a video from QR code images using ffmpeg, showing
+// files in the given order.
+func generateQRCodeVideo(files []string, videoPath string, opts videoOptions) (err error) {
+	for _, file := range files {
+		if filepath.Ext(file) == qrfiletransfer.ImageFormatSVG.Ext() {
+			return errors.New("SVG QR codes cannot be encoded into a video, split with --format png or use --sequence")
+		}
+	}
+
+	encodeArgs, err := videoEncodeArgs(opts)
+	if err != nil {
+		return err
+	}
+
+	// Create a temporary file with the list of images
+	tempFile, err := os.CreateTemp("", "qrcodes_list_*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	releaseTemp := trackTemp(tempFile.Name())
+
+	defer func() {
+		removeErr := releaseTemp()
+		if removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove temporary file: %w", removeErr)
+		}
+	}()
+
+	// Write the list of files to the temporary file
+	for _, file := range files {
+		// Use the file's absolute path
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", file, err)
+		}
+		// ffmpeg requires the file list to use the 'file' protocol
+		_, err = fmt.Fprintf(tempFile, "file '%s'\n", absPath)
+		if err != nil {
+			return fmt.Errorf("failed to write to temporary file: %w", err)
+		}
+		// Set the duration for each image (in seconds)
+		_, err = fmt.Fprintf(tempFile, "duration %f\n", 1.0/float64(opts.FPS))
+		if err != nil {
+			return fmt.Errorf("failed to write to temporary file: %w", err)
+		}
+	}
+
+	// Close the temporary file
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
+	}
+
+	// Build the ffmpeg command
+	args := []string{
+		"-y",           // Overwrite an output file if it exists
+		"-f", "concat", // Use concat demuxer
+		"-safe", "0", // Don't require safe filenames
+		"-i", tempFile.Name(), // Input file list
+	}
+
+	if opts.Info != nil {
+		var (
+			infoPath    string
+			releaseInfo func() error
+		)
+
+		infoPath, releaseInfo, err = writeStreamInfoSubtitle(opts.Info, float64(len(files))/float64(opts.FPS))
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			removeErr := releaseInfo()
+			if removeErr != nil && err == nil {
+				err = fmt.Errorf("failed to remove temporary file: %w", removeErr)
+			}
+		}()
+
+		// MP4 only holds text subtitles as mov_text
+		subtitleCodec := "mov_text"
+		if filepath.Ext(videoPath) == ".mkv" {
+			subtitleCodec = "subrip"
+		}
+
+		args = append(args,
+			"-i", infoPath, // Stream info subtitles
+			"-map", "0:v", "-map", "1:s",
+			"-c:s", subtitleCodec,
+			"-metadata:s:s:0", "title="+streamInfoTitle,
+		)
+	}
+
+	args = append(args, encodeArgs...)
+	args = append(args, videoPath) // Output file
+
+	cmd := exec.CommandContext(runCtx, "ffmpeg", args...)
+
+	// Capture command output
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg command failed: %w\nOutput: %s", err, string(output))
+	}
+
+	return nil
+}
+
+// writeStreamInfoSubtitle writes info as a SubRip subtitle shown for the given
+// number of seconds into a temporary file, and returns its path and the function
+// removing it
+func writeStreamInfoSubtitle(info *qrfiletransfer.StreamInfo, seconds float64) (string, func() error, error) {
+	file, err := os.CreateTemp("", "qrcodes_info_*.srt")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	release := trackTemp(file.Name())
+
+	end := time.Duration(seconds * float64(time.Second))
+	_, err = fmt.Fprintf(file, "1\n00:00:00,000 --> %02d:%02d:%02d,%03d\n%s\n",
+		int(end.Hours()), int(end.Minutes())%60, int(end.Seconds())%60, end.Milliseconds()%1000,
+		qrfiletransfer.EncodeStreamInfo(info))
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = release()
+
+		return "", nil, fmt.Errorf("failed to write stream info subtitle: %w", err)
+	}
+
+	return file.Name(), release, nil
+}
+
+// extractStreamInfo returns the text of the first subtitle track of a video using
+// ffmpeg, which holds the stream info of videos made by generate
+func extractStreamInfo(videoPath string) ([]byte, error) {
+	output, err := exec.CommandContext(runCtx,
+		"ffmpeg",
+		"-v", "error",
+		"-i", videoPath,
+		"-map", "0:s:0",
+		"-f", "srt",
+		"-",
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg command failed: %w", err)
+	}
+
+	return output, nil
+}
+
+// videoEncodeArgs returns the ffmpeg output arguments encoding a video according
+// to opts
+func videoEncodeArgs(opts videoOptions) ([]string, error) {
+	codec, encoder, err := videoEncoder(opts.Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.CRF >= 0 && opts.Bitrate != "" {
+		return nil, errors.New("use either a CRF or a bitrate, not both")
+	}
+
+	if opts.Lossless && (opts.CRF >= 0 || opts.Bitrate != "") {
+		return nil, errors.New("a lossless video takes no CRF or bitrate")
+	}
+
+	maxCRF := 51
+	switch encoder {
+	case "libvpx-vp9":
+		maxCRF = 63
+	case "ffv1":
+		maxCRF = -1
+	}
+
+	if opts.CRF > maxCRF {
+		return nil, fmt.Errorf("invalid CRF %d for %s (expected 0-%d)", opts.CRF, codec, maxCRF)
+	}
+
+	if opts.HoldFrames < 0 {
+		return nil, fmt.Errorf("invalid number of held frames %d", opts.HoldFrames)
+	}
+
+	// yuv420p subsamples the chroma in blocks of 2x2 pixels
+	if opts.Width%2 != 0 || opts.Height%2 != 0 {
+		return nil, fmt.Errorf("invalid resolution %dx%d, width and height must be even", opts.Width, opts.Height)
+	}
+
+	var args []string
+
+	// Scale without smoothing the module edges, and pad with white to the frame
+	if opts.Width > 0 {
+		args = append(args, "-vf", fmt.Sprintf(
+			"scale=%d:%d:force_original_aspect_ratio=decrease:flags=neighbor,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=white",
+			opts.Width, opts.Height, opts.Width, opts.Height))
+	}
+
+	// Held QR codes are repeated over several frames of a constant frame rate
+	if opts.HoldFrames > 1 {
+		args = append(args, "-vsync", "cfr", "-r", strconv.Itoa(opts.FPS*opts.HoldFrames))
+	} else {
+		args = append(args, "-vsync", "vfr") // Variable frame rate
+	}
+
+	args = append(args,
+		"-pix_fmt", "yuv420p", // Pixel format for compatibility
+		"-c:v", encoder, // Video codec
+	)
+
+	if opts.Lossless {
+		switch encoder {
+		case "libx264":
+			args = append(args, "-qp", "0")
+		case "libx265":
+			args = append(args, "-x265-params", "lossless=1")
+		case "libvpx-vp9":
+			args = append(args, "-lossless", "1")
+		}
+	}
+
+	if opts.CRF >= 0 {
+		args = append(args, "-crf", strconv.Itoa(opts.CRF))
+
+		// VP9 only uses the CRF as a constant quality without a bitrate
+		if encoder == "libvpx-vp9" {
+			args = append(args, "-b:v", "0")
+		}
+	}
+
+	if opts.Bitrate != "" {
+		args = append(args, "-b:v", opts.Bitrate)
+	}
+
+	// Players of Apple devices only recognize HEVC tagged as hvc1
+	if encoder == "libx265" {
+		args = append(args, "-tag:v", "hvc1")
+	}
+
+	return args, nil
+}
+
+// ffmpegSeconds formats d as seconds for ffmpeg
+func ffmpegSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// probeFrameRate returns the frame rate of the first video stream using ffprobe
+func probeFrameRate(videoPath string) (float64, error) {
+	output, err := exec.CommandContext(runCtx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		videoPath,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe command failed: %w", err)
+	}
+
+	return parseFrameRate(strings.TrimSpace(string(output)))
+}
+
+// parseFrameRate parses a frame rate reported by ffprobe, e.g. "240/1" or "30000/1001"
+func parseFrameRate(rate string) (float64, error) {
+	num, den, found := strings.Cut(rate, "/")
+	if !found {
+		den = "1"
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid frame rate %q: %w", rate, err)
+	}
+
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0, fmt.Errorf("invalid frame rate %q", rate)
+	}
+
+	return n / d, nil
+}
+
+// streamFramesFromVideo decodes the frames of a video selected by sampling using
+// ffmpeg, and passes them to fn as grey images while they are decoded, without
+// writing them to disk
+func streamFramesFromVideo(videoPath string, sampling video.Sampling, fn func(img image.Image) error) error {
+	// Seek to the start of the range rather than decoding the frames before it
+	var args []string
+
+	if sampling.Start > 0 {
+		args = append(args, "-ss", ffmpegSeconds(sampling.Start))
+	}
+
+	if sampling.End > 0 {
+		args = append(args, "-t", ffmpegSeconds(sampling.End-sampling.Start))
+	}
+
+	args = append(args, "-v", "error", "-i", videoPath)
+
+	var filters []string
+
+	if sampling.EveryNth > 1 {
+		filters = append(filters, fmt.Sprintf("select=not(mod(n\\,%d))", sampling.EveryNth))
+	}
+
+	if sampling.FPS > 0 {
+		filters = append(filters, "fps="+strconv.FormatFloat(sampling.FPS, 'f', -1, 64))
+	}
+
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+
+	// A QR code decoder only looks at the luma plane
+	args = append(args,
+		"-vsync", "0",
+		"-pix_fmt", "gray",
+		"-f", "yuv4mpegpipe",
+		"-",
+	)
+
+	ctx, cancel := context.WithCancel(runCtx)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to connect to ffmpeg: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	_, streamErr := video.StreamY4M(stdout, video.Sampling{}, fn)
+
+	// Stop ffmpeg if the frames are not read to the end
+	if streamErr != nil {
+		cancel()
+	}
+
+	if err := cmd.Wait(); err != nil && streamErr == nil {
+		return fmt.Errorf("ffmpeg command failed: %w\nOutput: %s", err, stderr.String())
+	}
+
+	if streamErr != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%w\nOutput: %s", streamErr, stderr.String())
+	}
+
+	return streamErr
+}
@@ -1,3 +1,5 @@
+//go:build !libonly
+
 package main
 
 import "github.com/dyammarcano/qrfiletransfer/cmd"
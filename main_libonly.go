@@ -0,0 +1,259 @@
+//go:build libonly
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/features"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// The libonly build replaces the cobra based command line with this minimal one,
+// built on the standard library only, for embedding in constrained appliances.
+// Its commands, aliases, and flags are those of the full command line.
+const usage = `Usage: qrfiletransfer <command> [flags]
+
+Commands:
+  split     Split a file into QR code images (alias: encode)
+  join      Join QR codes back into a file (alias: decode)
+  status    Show the state of a session directory
+  features  List the features compiled into this binary
+  version   Show the version and capabilities of this binary
+
+Run 'qrfiletransfer <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Print(usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func([]string) error{
+		"split":    runSplit,
+		"join":     runJoin,
+		"status":   runStatus,
+		"features": runFeatures,
+		"version":  runVersion,
+	}
+
+	// The aliases of the full command line
+	commands["encode"] = runSplit
+	commands["decode"] = runJoin
+
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Printf("Error: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[2:]); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// stringFlag defines a string flag of flags named name with the shorthand short,
+// as a flag of the full command line, e.g. -input or --input and -i
+func stringFlag(flags *flag.FlagSet, name, short, usage string) *string {
+	value := flags.String(name, "", usage)
+	flags.StringVar(value, short, "", "Shorthand for -"+name)
+
+	return value
+}
+
+// runSplit splits a file into QR codes
+func runSplit(args []string) error {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	input := stringFlag(flags, "input", "i", "Input file to split (required)")
+	output := stringFlag(flags, "output", "o", "Output directory for QR codes (default: <filename>_qrcodes)")
+	profile := flags.String("profile", "", "Encoder settings, e.g. profile:print-archive or recovery=high")
+	format := flags.String("format", "png", "QR code image format (png, svg), or pdf to also write backup.pdf")
+	_ = flags.Parse(args)
+
+	if *input == "" {
+		return errors.New("input file is required")
+	}
+
+	if *output == "" {
+		base := filepath.Base(*input)
+		*output = strings.TrimSuffix(base, filepath.Ext(base)) + "_qrcodes"
+	}
+
+	qrft := qrfiletransfer.NewQRFileTransfer()
+
+	if *profile != "" {
+		base, _ := qrfiletransfer.LookupProfile("default")
+
+		p, err := qrfiletransfer.ParseProfile(*profile, base)
+		if err != nil {
+			return err
+		}
+
+		qrft.ApplyProfile(p)
+	}
+
+	switch *format {
+	case "png", "pdf":
+	case "svg":
+		qrft.SetImageFormat(qrfiletransfer.ImageFormatSVG)
+	default:
+		return fmt.Errorf("unknown image format %q (expected png, svg or pdf)", *format)
+	}
+
+	if err := qrft.FileToQRCodes(*input, *output); err != nil {
+		return fmt.Errorf("failed to split file: %w", err)
+	}
+
+	fmt.Printf("Split '%s' into QR codes in '%s'\n", *input, *output)
+
+	if *format != "pdf" {
+		return nil
+	}
+
+	return writePaperBackup(*output)
+}
+
+// writePaperBackup writes the paper backup of the session in dir
+func writePaperBackup(dir string) (err error) {
+	path := filepath.Join(dir, qrfiletransfer.PaperBackupFileName)
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create paper backup: %w", err)
+	}
+
+	defer func() {
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close paper backup: %w", closeErr)
+		}
+	}()
+
+	if err := qrfiletransfer.WritePaperBackup(out, dir, qrfiletransfer.DefaultPaperLayout()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Paper backup written to '%s'\n", path)
+
+	return nil
+}
+
+// runJoin joins a session, directory archive, or batch back into its files
+func runJoin(args []string) error {
+	flags := flag.NewFlagSet("join", flag.ExitOnError)
+	input := stringFlag(flags, "input", "i", "Input directory containing QR codes (required)")
+	output := stringFlag(flags, "output", "o", "Output file path, or directory for a directory tree or batch (default: <dirname>_reconstructed)")
+	_ = flags.Parse(args)
+
+	if *input == "" {
+		return errors.New("input directory is required")
+	}
+
+	if *output == "" {
+		*output = filepath.Base(*input) + "_reconstructed"
+	}
+
+	qrft := qrfiletransfer.NewQRFileTransfer()
+
+	if qrfiletransfer.IsBatch(*input) {
+		result, err := qrft.BatchToFiles(*input, *output)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range result.Files {
+			fmt.Printf("File %s: reconstructed %s\n", f.ID, f.Path)
+		}
+
+		if len(result.Incomplete) > 0 {
+			return fmt.Errorf("%d files of the batch are incomplete", len(result.Incomplete))
+		}
+
+		return nil
+	}
+
+	if info, err := qrft.ReadFileInfo(*input); err == nil && info.Mode.IsDir() {
+		if err := qrft.QRCodesToDir(*input, *output); err != nil {
+			return fmt.Errorf("failed to join QR codes: %w", err)
+		}
+	} else if err := qrft.QRCodesToFile(*input, *output); err != nil {
+		return fmt.Errorf("failed to join QR codes: %w", err)
+	}
+
+	fmt.Printf("Joined QR codes into '%s'\n", *output)
+
+	return nil
+}
+
+// runStatus shows the state of a session directory
+func runStatus(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	input := stringFlag(flags, "input", "i", "Session directory (required)")
+	_ = flags.Parse(args)
+
+	if *input == "" {
+		return errors.New("input directory is required")
+	}
+
+	session, err := qrfiletransfer.OpenSession(*input)
+	if err != nil {
+		return err
+	}
+
+	state := "complete"
+	if !session.Complete {
+		state = "incomplete"
+	}
+
+	fmt.Printf("Session:  %s (%s)\n", session.Dir(), state)
+	fmt.Printf("File:     %s (%d bytes)\n", session.File.Name, session.File.Size)
+	fmt.Printf("SHA-256:  %s\n", session.File.Hash)
+	fmt.Printf("Chunks:   %d\n", len(session.Chunks))
+
+	return nil
+}
+
+// runFeatures lists the features compiled into the binary
+func runFeatures([]string) error {
+	for _, f := range features.List() {
+		state := "enabled"
+		if !f.Enabled {
+			state = "disabled"
+		}
+
+		fmt.Printf("%-8s %-8s %s (build tag %s)\n", f.Name, state, f.Description, f.Tag)
+	}
+
+	return nil
+}
+
+// runVersion prints the version and capabilities, as JSON with -json
+func runVersion(args []string) error {
+	flags := flag.NewFlagSet("version", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the capabilities as JSON")
+	_ = flags.Parse(args)
+
+	capabilities := features.Capabilities()
+
+	if !*asJSON {
+		fmt.Print(capabilities)
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(capabilities, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode capabilities: %w", err)
+	}
+
+	fmt.Println(string(data))
+
+	return nil
+}
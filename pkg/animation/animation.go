@@ -0,0 +1,261 @@
+// Package animation writes QR code images as a looping animated GIF or APNG with
+// the standard library only, for showing a transfer on a screen without ffmpeg.
+// QR codes are black and white, so every frame is reduced to a two color palette,
+// which keeps the animations small and lossless for the codes.
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+)
+
+// palette is the palette of every frame, white first so that the canvas around
+// smaller frames is blank
+var palette = color.Palette{color.White, color.Black}
+
+// ErrNoFrames is returned when an animation is written without frames
+var ErrNoFrames = errors.New("no frames")
+
+// WriteGIF writes the image files frames, in order, as an animated GIF to w that
+// shows fps frames per second and loops forever. GIF delays are counted in
+// hundredths of a second, so the frame rate is rounded to fit. The frames are
+// held in memory in their two color form, one byte per pixel, until the GIF is
+// encoded.
+func WriteGIF(w io.Writer, frames []string, fps int) error {
+	bounds, err := canvasBounds(frames)
+	if err != nil {
+		return err
+	}
+
+	anim := &gif.GIF{
+		Config: image.Config{ColorModel: palette, Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+
+	delay := max(1, (100+fps/2)/max(1, fps))
+
+	for _, path := range frames {
+		frame, err := loadFrame(path, bounds)
+		if err != nil {
+			return err
+		}
+
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	if err := gif.EncodeAll(w, anim); err != nil {
+		return fmt.Errorf("failed to encode GIF: %w", err)
+	}
+
+	return nil
+}
+
+// WriteAPNG writes the image files frames, in order, as an animated PNG to w that
+// shows fps frames per second and loops forever. Viewers without APNG support show
+// the first frame. Frames are encoded one at a time, so the memory used does not
+// grow with their number.
+func WriteAPNG(w io.Writer, frames []string, fps int) error {
+	bounds, err := canvasBounds(frames)
+	if err != nil {
+		return err
+	}
+
+	a := &apngWriter{w: w}
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return fmt.Errorf("failed to write APNG: %w", err)
+	}
+
+	for i, path := range frames {
+		frame, err := loadFrame(path, bounds)
+		if err != nil {
+			return err
+		}
+
+		chunks, err := encodePNGChunks(frame)
+		if err != nil {
+			return err
+		}
+
+		// The header and palette of the first frame describe the whole animation,
+		// every frame is encoded with the same ones
+		if i == 0 {
+			a.chunk("IHDR", chunks["IHDR"])
+
+			// acTL: number of frames, number of plays (0 loops forever)
+			actl := make([]byte, 8)
+			binary.BigEndian.PutUint32(actl, uint32(len(frames)))
+			a.chunk("acTL", actl)
+			a.chunk("PLTE", chunks["PLTE"])
+		}
+
+		// fcTL: sequence number, size, offset, delay fraction, dispose and blend ops
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], a.next())
+		binary.BigEndian.PutUint32(fctl[4:], uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(bounds.Dy()))
+		binary.BigEndian.PutUint16(fctl[20:], 1)
+		binary.BigEndian.PutUint16(fctl[22:], uint16(max(1, fps)))
+		a.chunk("fcTL", fctl)
+
+		// The first frame is the default image, the others are frame data chunks
+		if i == 0 {
+			a.chunk("IDAT", chunks["IDAT"])
+		} else {
+			fdat := make([]byte, 4, 4+len(chunks["IDAT"]))
+			binary.BigEndian.PutUint32(fdat, a.next())
+			a.chunk("fdAT", append(fdat, chunks["IDAT"]...))
+		}
+
+		if a.err != nil {
+			return fmt.Errorf("failed to write APNG: %w", a.err)
+		}
+	}
+
+	a.chunk("IEND", nil)
+
+	if a.err != nil {
+		return fmt.Errorf("failed to write APNG: %w", a.err)
+	}
+
+	return nil
+}
+
+// canvasBounds returns the bounds of a canvas that holds the largest of the image
+// files frames. QR codes of a session differ in size when their size is adjusted to
+// their content.
+func canvasBounds(frames []string) (image.Rectangle, error) {
+	if len(frames) == 0 {
+		return image.Rectangle{}, ErrNoFrames
+	}
+
+	var width, height int
+
+	for _, path := range frames {
+		f, err := os.Open(path)
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("failed to open frame: %w", err)
+		}
+
+		config, _, err := image.DecodeConfig(f)
+
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("failed to read frame %s: %w", path, err)
+		}
+
+		width = max(width, config.Width)
+		height = max(height, config.Height)
+	}
+
+	return image.Rect(0, 0, width, height), nil
+}
+
+// loadFrame decodes the image file at path and returns it reduced to the two color
+// palette, centered on a white canvas of bounds
+func loadFrame(path string, bounds image.Rectangle) (*image.Paletted, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frame: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode frame %s: %w", path, err)
+	}
+
+	frame := image.NewPaletted(bounds, palette)
+
+	src := img.Bounds()
+	offset := image.Pt((bounds.Dx()-src.Dx())/2, (bounds.Dy()-src.Dy())/2)
+
+	// Draw without dithering: every pixel takes the nearest of black and white
+	draw.Draw(frame, src.Sub(src.Min).Add(offset), img, src.Min, draw.Src)
+
+	return frame, nil
+}
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// encodePNGChunks encodes img as a PNG and returns the data of its chunks by type,
+// with the data of all IDAT chunks joined
+func encodePNGChunks(img image.Image) (map[string][]byte, error) {
+	var b bytes.Buffer
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&b, img); err != nil {
+		return nil, fmt.Errorf("failed to encode frame: %w", err)
+	}
+
+	data := b.Bytes()[len(pngSignature):]
+	chunks := make(map[string][]byte)
+
+	// Every chunk is a length, a type, the data, and a CRC
+	for len(data) >= 12 {
+		n := binary.BigEndian.Uint32(data)
+		if int64(n) > int64(len(data)-12) {
+			return nil, errors.New("failed to encode frame: truncated PNG chunk")
+		}
+
+		kind := string(data[4:8])
+		chunks[kind] = append(chunks[kind], data[8:8+n]...)
+		data = data[12+n:]
+	}
+
+	return chunks, nil
+}
+
+// apngWriter writes PNG chunks and numbers the animation chunks. The first error
+// is kept and stops all further writes.
+type apngWriter struct {
+	w   io.Writer
+	seq uint32
+	err error
+}
+
+// next returns the next sequence number of an fcTL or fdAT chunk
+func (a *apngWriter) next() uint32 {
+	seq := a.seq
+	a.seq++
+
+	return seq
+}
+
+// chunk writes a PNG chunk of the given type and data
+func (a *apngWriter) chunk(kind string, data []byte) {
+	if a.err != nil {
+		return
+	}
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], kind)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	for _, b := range [][]byte{header, data, binary.BigEndian.AppendUint32(nil, crc.Sum32())} {
+		if _, err := a.w.Write(b); err != nil {
+			a.err = err
+
+			return
+		}
+	}
+}
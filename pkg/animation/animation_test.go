@@ -0,0 +1,217 @@
+package animation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFrames writes square PNG frames of the given sizes, black with a white top
+// left pixel, and returns their paths
+func writeFrames(t *testing.T, sizes ...int) []string {
+	t.Helper()
+
+	var paths []string
+
+	for i, size := range sizes {
+		img := image.NewGray(image.Rect(0, 0, size, size))
+		img.SetGray(0, 0, color.Gray{Y: 0xff})
+
+		var b bytes.Buffer
+		if err := png.Encode(&b, img); err != nil {
+			t.Fatalf("Failed to encode frame: %v", err)
+		}
+
+		path := filepath.Join(t.TempDir(), string(rune('a'+i))+".png")
+		if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+func TestWriteGIF(t *testing.T) {
+	frames := writeFrames(t, 40, 40, 20)
+
+	var b bytes.Buffer
+	if err := WriteGIF(&b, frames, 5); err != nil {
+		t.Fatalf("WriteGIF failed: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	if len(anim.Image) != 3 || anim.LoopCount != 0 {
+		t.Fatalf("GIF has %d frames and loop count %d, want 3 looping forever", len(anim.Image), anim.LoopCount)
+	}
+
+	for i, delay := range anim.Delay {
+		if delay != 20 {
+			t.Errorf("Frame %d has delay %d, want 20", i, delay)
+		}
+	}
+
+	// The smaller frame is centered on a white canvas of the largest size
+	last := anim.Image[2]
+	if last.Bounds().Dx() != 40 || !isWhite(last.At(0, 0)) || !isWhite(last.At(10, 10)) || isWhite(last.At(15, 15)) {
+		t.Error("Smaller frame is not centered on a white canvas")
+	}
+}
+
+func TestWriteAPNG(t *testing.T) {
+	frames := writeFrames(t, 30, 30, 30, 24)
+
+	var b bytes.Buffer
+	if err := WriteAPNG(&b, frames, 4); err != nil {
+		t.Fatalf("WriteAPNG failed: %v", err)
+	}
+
+	// Viewers without APNG support show the first frame
+	first, err := png.Decode(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode APNG as PNG: %v", err)
+	}
+
+	if !isWhite(first.At(0, 0)) || isWhite(first.At(1, 1)) {
+		t.Error("Default image does not show the first frame")
+	}
+
+	chunks := readChunks(t, b.Bytes())
+
+	var (
+		kinds []string
+		seq   uint32
+		ihdr  []byte
+		plte  []byte
+	)
+
+	for _, c := range chunks {
+		kinds = append(kinds, c.kind)
+
+		switch c.kind {
+		case "IHDR":
+			ihdr = c.data
+		case "PLTE":
+			plte = c.data
+		case "acTL":
+			if n := binary.BigEndian.Uint32(c.data); n != 4 || binary.BigEndian.Uint32(c.data[4:]) != 0 {
+				t.Errorf("acTL announces %d frames and %d plays, want 4 looping forever", n, binary.BigEndian.Uint32(c.data[4:]))
+			}
+		case "fcTL", "fdAT":
+			if got := binary.BigEndian.Uint32(c.data); got != seq {
+				t.Errorf("%s has sequence number %d, want %d", c.kind, got, seq)
+			}
+
+			seq++
+
+			if c.kind == "fcTL" && (binary.BigEndian.Uint16(c.data[20:]) != 1 || binary.BigEndian.Uint16(c.data[22:]) != 4) {
+				t.Error("fcTL does not set a delay of 1/4 second")
+			}
+		}
+	}
+
+	want := []string{"IHDR", "acTL", "PLTE", "fcTL", "IDAT", "fcTL", "fdAT", "fcTL", "fdAT", "fcTL", "fdAT", "IEND"}
+	if len(kinds) != len(want) {
+		t.Fatalf("APNG chunks = %v, want %v", kinds, want)
+	}
+
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Fatalf("APNG chunks = %v, want %v", kinds, want)
+		}
+	}
+
+	// Every frame data chunk holds a complete image of the animation size
+	for _, c := range chunks {
+		if c.kind != "fdAT" {
+			continue
+		}
+
+		var frame bytes.Buffer
+
+		frame.Write(pngSignature)
+
+		for _, part := range []chunk{{"IHDR", ihdr}, {"PLTE", plte}, {"IDAT", c.data[4:]}, {"IEND", nil}} {
+			frame.Write(encodeChunk(part))
+		}
+
+		img, err := png.Decode(&frame)
+		if err != nil {
+			t.Fatalf("Failed to decode frame data: %v", err)
+		}
+
+		if img.Bounds().Dx() != 30 || !isWhite(img.At(0, 0)) {
+			t.Error("Frame data does not hold the frame on the animation canvas")
+		}
+	}
+}
+
+func TestWriteNoFrames(t *testing.T) {
+	if err := WriteGIF(&bytes.Buffer{}, nil, 5); !errors.Is(err, ErrNoFrames) {
+		t.Errorf("WriteGIF() error = %v, want ErrNoFrames", err)
+	}
+
+	if err := WriteAPNG(&bytes.Buffer{}, nil, 5); !errors.Is(err, ErrNoFrames) {
+		t.Errorf("WriteAPNG() error = %v, want ErrNoFrames", err)
+	}
+}
+
+// chunk is a PNG chunk
+type chunk struct {
+	kind string
+	data []byte
+}
+
+// readChunks splits a PNG file into its chunks and checks their CRCs
+func readChunks(t *testing.T, data []byte) []chunk {
+	t.Helper()
+
+	if !bytes.HasPrefix(data, pngSignature) {
+		t.Fatal("APNG does not start with the PNG signature")
+	}
+
+	var chunks []chunk
+
+	for data = data[len(pngSignature):]; len(data) >= 12; {
+		n := binary.BigEndian.Uint32(data)
+		c := chunk{string(data[4:8]), data[8 : 8+n]}
+
+		if !bytes.Equal(encodeChunk(c), data[:12+n]) {
+			t.Errorf("Chunk %s has a bad CRC", c.kind)
+		}
+
+		chunks = append(chunks, c)
+		data = data[12+n:]
+	}
+
+	return chunks
+}
+
+// encodeChunk returns the bytes of a PNG chunk
+func encodeChunk(c chunk) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(c.data)))
+	b = append(b, c.kind...)
+	b = append(b, c.data...)
+
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+// isWhite reports whether c is white
+func isWhite(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+
+	return r == 0xffff && g == 0xffff && b == 0xffff
+}
@@ -0,0 +1,79 @@
+// Package base45 implements the Base45 encoding of RFC 9285.
+// Base45 encodes every 2 bytes in 3 characters of a 45 character alphabet of digits,
+// upper case letters, and a few symbols. The alphabet has no lower case letters,
+// so encoded text survives OCR and manual typing better than Base64.
+package base45
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Alphabet is the Base45 alphabet, the index of a character is its value
+const Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// ErrInvalid is returned when decoding text that is not valid Base45
+var ErrInvalid = errors.New("invalid base45")
+
+// EncodedLen returns the length of the Base45 encoding of n bytes
+func EncodedLen(n int) int {
+	return n/2*3 + n%2*2
+}
+
+// Encode returns the Base45 encoding of data
+func Encode(data []byte) string {
+	var b strings.Builder
+	b.Grow(EncodedLen(len(data)))
+
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		b.WriteByte(Alphabet[n%45])
+		b.WriteByte(Alphabet[n/45%45])
+		b.WriteByte(Alphabet[n/(45*45)])
+	}
+
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		b.WriteByte(Alphabet[n%45])
+		b.WriteByte(Alphabet[n/45])
+	}
+
+	return b.String()
+}
+
+// Decode returns the data encoded in Base45 text. It returns an error wrapping
+// ErrInvalid for characters outside the alphabet, a length that no encoding has,
+// and character groups that encode values out of range.
+func Decode(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalid, len(s))
+	}
+
+	data := make([]byte, 0, len(s)/3*2+len(s)%3/2)
+
+	for i := 0; i < len(s); i += 3 {
+		group := s[i:min(i+3, len(s))]
+
+		n := 0
+		for j := len(group) - 1; j >= 0; j-- {
+			v := strings.IndexByte(Alphabet, group[j])
+			if v < 0 {
+				return nil, fmt.Errorf("%w: character %q at offset %d", ErrInvalid, group[j], i+j)
+			}
+
+			n = n*45 + v
+		}
+
+		switch {
+		case len(group) == 3 && n <= 0xffff:
+			data = append(data, byte(n>>8), byte(n))
+		case len(group) == 2 && n <= 0xff:
+			data = append(data, byte(n))
+		default:
+			return nil, fmt.Errorf("%w: group %q at offset %d is out of range", ErrInvalid, group, i)
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,69 @@
+package base45
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	// Examples of RFC 9285
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", ""},
+		{"AB", "BB8"},
+		{"Hello!!", "%69 VD92EX0"},
+		{"base-45", "UJCLQE7W581"},
+		{"ietf!", "QED8WEX0"},
+	}
+
+	for _, tt := range tests {
+		if got := Encode([]byte(tt.data)); got != tt.want {
+			t.Errorf("Encode(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+
+		got, err := Decode(tt.want)
+		if err != nil || string(got) != tt.data {
+			t.Errorf("Decode(%q) = %q, %v, want %q", tt.want, got, err, tt.data)
+		}
+
+		if n := EncodedLen(len(tt.data)); n != len(tt.want) {
+			t.Errorf("EncodedLen(%d) = %d, want %d", len(tt.data), n, len(tt.want))
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := make([]byte, 0, 513)
+	for i := range 513 {
+		data = append(data, byte(i*7))
+	}
+
+	data = append(data, 0xff, 0xff)
+
+	got, err := Decode(Encode(data))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if !bytes.Equal(got, data) {
+		t.Error("Decoded data does not match the encoded data")
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	for _, s := range []string{
+		"GGW",  // 65535 + 1
+		"GGX",  // above the largest value of 3 characters
+		"V5",   // 256, above the largest value of 2 characters
+		"BB8B", // a single trailing character
+		"bb8",  // lower case letters are not in the alphabet
+		"BB#",
+	} {
+		if _, err := Decode(s); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Decode(%q) error = %v, want ErrInvalid", s, err)
+		}
+	}
+}
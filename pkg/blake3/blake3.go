@@ -0,0 +1,410 @@
+// Package blake3 implements the BLAKE3 cryptographic hash in its default hashing
+// mode, with 32 byte digests. BLAKE3 splits its input into 1 KiB chunks hashed
+// into a binary tree, so it is faster than SHA-256 on several cores, or on CPUs
+// without SHA instructions.
+//
+// This is a portable implementation of the reference algorithm, without SIMD and
+// without the keyed hashing and key derivation modes. The chunks of large writes
+// are hashed on several goroutines.
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// Size is the size of a BLAKE3 digest in bytes
+const Size = 32
+
+// BlockSize is the size of the blocks BLAKE3 compresses in bytes
+const BlockSize = 64
+
+// chunkLen is the size of the chunks hashed into the leaves of the tree
+const chunkLen = 1024
+
+// parallelChunks is the number of chunks of a write every additional goroutine
+// hashing them needs
+const parallelChunks = 16
+
+// The domain separation flags of the compression function
+const (
+	flagChunkStart = 1 << iota
+	flagChunkEnd
+	flagParent
+	flagRoot
+)
+
+// iv is the initialization vector, the one of SHA-256
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+// g is the quarter-round mixing two message words into four state words
+func g(a, b, c, d, mx, my uint32) (uint32, uint32, uint32, uint32) {
+	a += b + mx
+	d = bits.RotateLeft32(d^a, -16)
+	c += d
+	b = bits.RotateLeft32(b^c, -12)
+	a += b + my
+	d = bits.RotateLeft32(d^a, -8)
+	c += d
+	b = bits.RotateLeft32(b^c, -7)
+
+	return a, b, c, d
+}
+
+// compress compresses a block of 16 message words into the chaining value cv and
+// returns the 16 words of the output
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s0, s1, s2, s3, s4, s5, s6, s7 := cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7]
+	s8, s9, s10, s11 := iv[0], iv[1], iv[2], iv[3]
+	s12, s13, s14, s15 := uint32(counter), uint32(counter>>32), blockLen, flags
+
+	// The rounds mix the columns and then the diagonals, with the message words
+	// permuted from one round to the next
+	// Round 1
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[0], m[1])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[2], m[3])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[4], m[5])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[6], m[7])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[8], m[9])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[10], m[11])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[12], m[13])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[14], m[15])
+
+	// Round 2
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[2], m[6])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[3], m[10])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[7], m[0])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[4], m[13])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[1], m[11])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[12], m[5])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[9], m[14])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[15], m[8])
+
+	// Round 3
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[3], m[4])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[10], m[12])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[13], m[2])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[7], m[14])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[6], m[5])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[9], m[0])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[11], m[15])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[8], m[1])
+
+	// Round 4
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[10], m[7])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[12], m[9])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[14], m[3])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[13], m[15])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[4], m[0])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[11], m[2])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[5], m[8])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[1], m[6])
+
+	// Round 5
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[12], m[13])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[9], m[11])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[15], m[10])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[14], m[8])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[7], m[2])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[5], m[3])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[0], m[1])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[6], m[4])
+
+	// Round 6
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[9], m[14])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[11], m[5])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[8], m[12])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[15], m[1])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[13], m[3])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[0], m[10])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[2], m[6])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[4], m[7])
+
+	// Round 7
+	s0, s4, s8, s12 = g(s0, s4, s8, s12, m[11], m[15])
+	s1, s5, s9, s13 = g(s1, s5, s9, s13, m[5], m[0])
+	s2, s6, s10, s14 = g(s2, s6, s10, s14, m[1], m[9])
+	s3, s7, s11, s15 = g(s3, s7, s11, s15, m[8], m[6])
+	s0, s5, s10, s15 = g(s0, s5, s10, s15, m[14], m[10])
+	s1, s6, s11, s12 = g(s1, s6, s11, s12, m[2], m[12])
+	s2, s7, s8, s13 = g(s2, s7, s8, s13, m[3], m[4])
+	s3, s4, s9, s14 = g(s3, s4, s9, s14, m[7], m[13])
+
+	return [16]uint32{
+		s0 ^ s8, s1 ^ s9, s2 ^ s10, s3 ^ s11, s4 ^ s12, s5 ^ s13, s6 ^ s14, s7 ^ s15,
+		s8 ^ cv[0], s9 ^ cv[1], s10 ^ cv[2], s11 ^ cv[3], s12 ^ cv[4], s13 ^ cv[5], s14 ^ cv[6], s15 ^ cv[7],
+	}
+}
+
+// words reads the little endian message words of a block
+func words(block *[BlockSize]byte) [16]uint32 {
+	var m [16]uint32
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(block[4*i:])
+	}
+
+	return m
+}
+
+// output is a node of the tree not compressed yet, as whether it is the root is
+// only known once the input ends
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+// chainingValue returns the chaining value of a node that is not the root
+func (o *output) chainingValue() [8]uint32 {
+	out := compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+
+	return [8]uint32(out[:8])
+}
+
+// rootBytes appends the first Size bytes of the output of the root node to b
+func (o *output) rootBytes(b []byte) []byte {
+	out := compress(&o.cv, &o.block, 0, o.blockLen, o.flags|flagRoot)
+
+	for _, w := range out[:Size/4] {
+		b = binary.LittleEndian.AppendUint32(b, w)
+	}
+
+	return b
+}
+
+// chunkState hashes the blocks of a chunk
+type chunkState struct {
+	cv [8]uint32
+	// counter is the index of the chunk
+	counter          uint64
+	block            [BlockSize]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+// len returns the number of bytes of the chunk written
+func (c *chunkState) len() int {
+	return BlockSize*c.blocksCompressed + c.blockLen
+}
+
+// startFlag returns flagChunkStart for the first block of the chunk
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return flagChunkStart
+	}
+
+	return 0
+}
+
+// update writes p, which does not exceed the chunk, to the chunk. A full block is
+// only compressed once more input follows, as the last block is flagged.
+func (c *chunkState) update(p []byte) {
+	for len(p) > 0 {
+		if c.blockLen == BlockSize {
+			m := words(&c.block)
+			out := compress(&c.cv, &m, c.counter, BlockSize, c.startFlag())
+			c.cv = [8]uint32(out[:8])
+			c.blocksCompressed++
+			c.block = [BlockSize]byte{}
+			c.blockLen = 0
+		}
+
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+// output returns the node of the chunk
+func (c *chunkState) output() output {
+	return output{
+		cv:       c.cv,
+		block:    words(&c.block),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | flagChunkEnd,
+	}
+}
+
+// parentOutput returns the parent node of two chaining values
+func parentOutput(left, right [8]uint32) output {
+	var block [16]uint32
+
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+
+	return output{cv: iv, block: block, blockLen: BlockSize, flags: flagParent}
+}
+
+// digest is the state of a BLAKE3 computation
+type digest struct {
+	chunk chunkState
+	// stack holds the chaining values of the complete subtrees on the left, at most
+	// one per level of a tree of 2^64 chunks
+	stack [54][8]uint32
+	depth int
+	// cvs holds the chaining values of the chunks of a write
+	cvs [][8]uint32
+}
+
+// New returns a hash.Hash computing the BLAKE3 digest
+func New() hash.Hash {
+	d := &digest{}
+	d.Reset()
+
+	return d
+}
+
+// Sum256 returns the BLAKE3 digest of data
+func Sum256(data []byte) [Size]byte {
+	d := New()
+	_, _ = d.Write(data)
+
+	return [Size]byte(d.Sum(nil))
+}
+
+// Reset implements hash.Hash
+func (d *digest) Reset() {
+	d.chunk = chunkState{cv: iv}
+	d.depth = 0
+}
+
+// Size implements hash.Hash
+func (d *digest) Size() int {
+	return Size
+}
+
+// BlockSize implements hash.Hash
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
+
+// Write implements io.Writer, it never fails
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		// A full chunk is only added to the tree once more input follows, as the
+		// last chunk may be the root
+		if d.chunk.len() == chunkLen {
+			out := d.chunk.output()
+			total := d.chunk.counter + 1
+			d.addChunk(out.chainingValue(), total)
+			d.chunk = chunkState{cv: iv, counter: total}
+		}
+
+		// The full chunks followed by more input are hashed straight from p
+		if n := (len(p) - 1) / chunkLen; d.chunk.len() == 0 && n > 0 {
+			d.addChunks(p[:n*chunkLen])
+			p = p[n*chunkLen:]
+
+			continue
+		}
+
+		n := min(chunkLen-d.chunk.len(), len(p))
+		d.chunk.update(p[:n])
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// addChunks adds the full chunks of p to the tree, hashing them on several
+// goroutines if there are enough of them
+func (d *digest) addChunks(p []byte) {
+	counter := d.chunk.counter
+	n := len(p) / chunkLen
+
+	if cap(d.cvs) < n {
+		d.cvs = make([][8]uint32, n)
+	}
+
+	cvs := d.cvs[:n]
+
+	workers := min(runtime.GOMAXPROCS(0), n/parallelChunks)
+	if workers <= 1 {
+		for i := range cvs {
+			cvs[i] = chunkChainingValue(p[i*chunkLen:(i+1)*chunkLen], counter+uint64(i))
+		}
+	} else {
+		var wg sync.WaitGroup
+
+		for w := range workers {
+			wg.Add(1)
+
+			go func(first, last int) {
+				defer wg.Done()
+
+				for i := first; i < last; i++ {
+					cvs[i] = chunkChainingValue(p[i*chunkLen:(i+1)*chunkLen], counter+uint64(i))
+				}
+			}(n*w/workers, n*(w+1)/workers)
+		}
+
+		wg.Wait()
+	}
+
+	for i, cv := range cvs {
+		d.addChunk(cv, counter+uint64(i)+1)
+	}
+
+	d.chunk = chunkState{cv: iv, counter: counter + uint64(n)}
+}
+
+// chunkChainingValue returns the chaining value of a full chunk that is not the
+// root, the chunk of index counter
+func chunkChainingValue(chunk []byte, counter uint64) [8]uint32 {
+	cv := iv
+
+	for b := range chunkLen / BlockSize {
+		var m [16]uint32
+		for i := range m {
+			m[i] = binary.LittleEndian.Uint32(chunk[b*BlockSize+4*i:])
+		}
+
+		var flags uint32
+		switch b {
+		case 0:
+			flags = flagChunkStart
+		case chunkLen/BlockSize - 1:
+			flags = flagChunkEnd
+		}
+
+		out := compress(&cv, &m, counter, BlockSize, flags)
+		cv = [8]uint32(out[:8])
+	}
+
+	return cv
+}
+
+// addChunk adds the chaining value of a chunk to the tree of total chunks, merging
+// the subtrees it completes
+func (d *digest) addChunk(cv [8]uint32, total uint64) {
+	for ; total&1 == 0; total >>= 1 {
+		d.depth--
+		parent := parentOutput(d.stack[d.depth], cv)
+		cv = parent.chainingValue()
+	}
+
+	d.stack[d.depth] = cv
+	d.depth++
+}
+
+// Sum implements hash.Hash
+func (d *digest) Sum(b []byte) []byte {
+	out := d.chunk.output()
+
+	for i := d.depth - 1; i >= 0; i-- {
+		out = parentOutput(d.stack[i], out.chainingValue())
+	}
+
+	return out.rootBytes(b)
+}
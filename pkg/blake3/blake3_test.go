@@ -0,0 +1,97 @@
+package blake3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"runtime"
+	"testing"
+)
+
+// testInput returns the input of the official test vectors of n bytes
+func testInput(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	return data
+}
+
+func TestSum256(t *testing.T) {
+	// The first 32 bytes of the hashes of the official test vectors, at the block,
+	// chunk, and tree boundaries
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{63, "e9bc37a594daad83be9470df7f7b3798297c3d834ce80ba85d6e207627b7db7b"},
+		{64, "4eed7141ea4a5cd4b788606bd23f46e212af9cacebacdc7d1f4c6dc7f2511b98"},
+		{65, "de1e5fa0be70df6d2be8fffd0e99ceaa8eb6e8c93a63f2d8d1c30ecb6b263dee"},
+		{1023, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+		{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+		{2049, "5f4d72f40d7a5f82b15ca2b2e44b1de3c2ef86c426c95c1af0b6879522563030"},
+		{3072, "b98cb0ff3623be03326b373de6b9095218513e64f1ee2edd2525c7ad1e5cffd2"},
+		{3073, "7124b49501012f81cc7f11ca069ec9226cecb8a2c850cfe644e327d22d3e1cd3"},
+		{8193, "bab6c09cb8ce8cf459261398d2e7aef35700bf488116ceb94a36d0f5f1b7bc3b"},
+		{31744, "62b6960e1a44bcc1eb1a611a8d6235b6b4b78f32e7abc4fb4c6cdcce94895c47"},
+		{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+
+	for _, tt := range tests {
+		data := testInput(tt.n)
+
+		if got := Sum256(data); hex.EncodeToString(got[:]) != tt.want {
+			t.Errorf("Sum256(%d bytes) = %x, want %s", tt.n, got, tt.want)
+		}
+
+		// Written in uneven pieces
+		h := New()
+		for p := data; len(p) > 0; {
+			n := min(len(p), 1+len(p)%700)
+			_, _ = h.Write(p[:n])
+			p = p[n:]
+		}
+
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.want {
+			t.Errorf("Write(%d bytes) in pieces = %s, want %s", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSumKeepsState(t *testing.T) {
+	h := New()
+	_, _ = h.Write(testInput(3000))
+
+	first := h.Sum([]byte("prefix"))
+	if !bytes.HasPrefix(first, []byte("prefix")) || len(first) != len("prefix")+Size {
+		t.Fatalf("Sum() = %x, want the digest appended", first)
+	}
+
+	_, _ = h.Write(testInput(100))
+
+	want := Sum256(append(testInput(3000), testInput(100)...))
+	if got := h.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("Sum() changed the state: %x, want %x", got, want)
+	}
+
+	h.Reset()
+
+	if got, want := h.Sum(nil), Sum256(nil); !bytes.Equal(got, want[:]) {
+		t.Errorf("Sum() after Reset() = %x, want %x", got, want)
+	}
+}
+
+func TestWriteParallel(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	// 99 full chunks followed by more input, hashed on 4 goroutines
+	const want = "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"
+
+	if got := Sum256(testInput(102400)); hex.EncodeToString(got[:]) != want {
+		t.Errorf("Sum256(102400 bytes) = %x, want %s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Package cleanup tracks the temporary files and directories of a run, such as
+// extracted frames or split chunks, so that they are removed even when the run
+// fails, is interrupted, or panics, instead of being left behind.
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// entry is a tracked path and the file system it was created on
+type entry struct {
+	id   int
+	fs   afero.Fs
+	path string
+}
+
+// Manager tracks temporary paths until they are released or cleaned up, safely for
+// concurrent use. A nil Manager tracks nothing, releasing a path removes it right
+// away, so that code creating temporary paths needs no checks.
+type Manager struct {
+	mu      sync.Mutex
+	entries []entry
+	nextID  int
+	keep    bool
+	kept    []string
+}
+
+// NewManager creates a Manager tracking no path
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+// SetKeep sets whether released and cleaned up paths are kept in place instead of
+// removed, e.g. to inspect them after the run. Kept paths are listed by Kept.
+func (m *Manager) SetKeep(keep bool) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.keep = keep
+}
+
+// Track registers path, a temporary file or directory on fs, to be removed by
+// Cleanup. The returned function removes it and stops tracking it, once the path is
+// no longer needed; it may be called more than once and after Cleanup.
+func (m *Manager) Track(fs afero.Fs, path string) (release func() error) {
+	if m == nil {
+		return func() error {
+			return fs.RemoveAll(path)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.entries = append(m.entries, entry{id: id, fs: fs, path: path})
+
+	return func() error {
+		return m.release(id)
+	}
+}
+
+// release removes the tracked entry id, if it is still tracked
+func (m *Manager) release(id int) error {
+	m.mu.Lock()
+
+	var (
+		e     entry
+		found bool
+	)
+
+	for i := range m.entries {
+		if m.entries[i].id == id {
+			e, found = m.entries[i], true
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+
+			break
+		}
+	}
+
+	keep := m.keep
+	if found && keep {
+		m.kept = append(m.kept, e.path)
+	}
+
+	m.mu.Unlock()
+
+	if !found || keep {
+		return nil
+	}
+
+	return e.fs.RemoveAll(e.path)
+}
+
+// Keep stops tracking path without removing it, for a temporary path that turned
+// out to be worth keeping, e.g. the state of an interrupted transfer
+func (m *Manager) Keep(path string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries := m.entries[:0]
+	for _, e := range m.entries {
+		if e.path != path {
+			entries = append(entries, e)
+		}
+	}
+
+	m.entries = entries
+}
+
+// Tracked returns the paths tracked, in the order they were registered
+func (m *Manager) Tracked() []string {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	paths := make([]string, 0, len(m.entries))
+	for _, e := range m.entries {
+		paths = append(paths, e.path)
+	}
+
+	return paths
+}
+
+// Kept returns the paths released or cleaned up while keeping paths, see SetKeep
+func (m *Manager) Kept() []string {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.kept...)
+}
+
+// Cleanup removes every tracked path, the most recently registered first so that
+// files go before the directories holding them, and stops tracking them. It returns
+// the errors of the paths that could not be removed.
+func (m *Manager) Cleanup() error {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+
+	entries := m.entries
+	m.entries = nil
+
+	keep := m.keep
+	if keep {
+		for _, e := range entries {
+			m.kept = append(m.kept, e.path)
+		}
+	}
+
+	m.mu.Unlock()
+
+	if keep {
+		return nil
+	}
+
+	var errs []error
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := entries[i].fs.RemoveAll(entries[i].path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", entries[i].path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package cleanup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func exists(t *testing.T, fs afero.Fs, path string) bool {
+	t.Helper()
+
+	ok, err := afero.Exists(fs, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ok
+}
+
+func TestManager(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	m := NewManager()
+
+	for _, path := range []string{"/tmp/frames/0001.png", "/tmp/chunks/a.part"} {
+		if err := afero.WriteFile(fs, path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	releaseFrames := m.Track(fs, "/tmp/frames")
+	m.Track(fs, "/tmp/chunks")
+
+	if err := releaseFrames(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+
+	if exists(t, fs, "/tmp/frames") {
+		t.Error("Released directory was not removed")
+	}
+
+	if got := m.Tracked(); !reflect.DeepEqual(got, []string{"/tmp/chunks"}) {
+		t.Errorf("Tracked() = %v", got)
+	}
+
+	// An interrupted run removes what is still tracked
+	if err := m.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+
+	if exists(t, fs, "/tmp/chunks") || len(m.Tracked()) != 0 {
+		t.Error("Cleanup left the tracked directory")
+	}
+
+	if err := releaseFrames(); err != nil {
+		t.Errorf("Second release failed: %v", err)
+	}
+}
+
+func TestManagerKeep(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	m := NewManager()
+	m.SetKeep(true)
+
+	for _, path := range []string{"/tmp/frames", "/tmp/state"} {
+		if err := fs.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release := m.Track(fs, "/tmp/frames")
+	m.Track(fs, "/tmp/state")
+
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists(t, fs, "/tmp/frames") || !exists(t, fs, "/tmp/state") {
+		t.Error("Kept paths were removed")
+	}
+
+	if got := m.Kept(); !reflect.DeepEqual(got, []string{"/tmp/frames", "/tmp/state"}) {
+		t.Errorf("Kept() = %v", got)
+	}
+
+	// A path kept explicitly is left in place without --keep-temp too
+	m.SetKeep(false)
+	m.Track(fs, "/tmp/state")
+	m.Keep("/tmp/state")
+
+	if err := m.Cleanup(); err != nil || !exists(t, fs, "/tmp/state") {
+		t.Errorf("Cleanup removed a kept path: %v", err)
+	}
+}
+
+func TestNilManager(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := fs.MkdirAll("/tmp/frames", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var m *Manager
+
+	release := m.Track(fs, "/tmp/frames")
+	if err := release(); err != nil || exists(t, fs, "/tmp/frames") {
+		t.Errorf("release of a nil Manager did not remove the path: %v", err)
+	}
+
+	m.SetKeep(true)
+	m.Keep("/tmp/frames")
+
+	if err := m.Cleanup(); err != nil || m.Tracked() != nil || m.Kept() != nil {
+		t.Error("A nil Manager tracked paths")
+	}
+}
@@ -0,0 +1,266 @@
+// Package diagnostics collects the non-fatal issues of a run, such as frames that
+// could not be decoded or outputs that had to be renamed, so that they are
+// reported together at the end with a severity and a machine-readable code
+// instead of interleaved with the progress output.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Severity tells how much attention a diagnostic needs
+type Severity int
+
+const (
+	// Info reports something expected that may still be of interest, e.g. a
+	// duplicate frame
+	Info Severity = iota
+	// Warning reports an issue worked around, whose outcome may not be the
+	// expected one
+	Warning
+	// Error reports an issue that made a part of the run fail, while the rest
+	// went on
+	Error
+)
+
+// severityNames are the names of the severities, as shown and written to JSON
+var severityNames = []string{"info", "warning", "error"}
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	if s < Info || s > Error {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity as its name
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity from its name
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if string(text) == name {
+			*s = Severity(i)
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Code identifies the kind of a diagnostic. Codes are stable, scripts may rely on
+// them.
+type Code string
+
+const (
+	// CodeSkippedFrame is a frame or image that could not be decoded
+	CodeSkippedFrame Code = "skipped-frame"
+	// CodeDuplicateFrame is a frame showing a QR code already decoded
+	CodeDuplicateFrame Code = "duplicate-frame"
+	// CodeInvalidChunk is a decoded QR code whose payload is not a valid chunk
+	CodeInvalidChunk Code = "invalid-chunk"
+	// CodeSkippedChunks are chunks missed between two decoded chunks
+	CodeSkippedChunks Code = "skipped-chunks"
+	// CodeRenamedOutput is an output written under another name than the original
+	// file, e.g. to avoid a name clash
+	CodeRenamedOutput Code = "renamed-output"
+	// CodeTruncatedName is a file name that may have been truncated by the format
+	// it was recorded in
+	CodeTruncatedName Code = "truncated-name"
+	// CodeLowDensity is a QR code drawn with too few pixels per module to be
+	// scanned reliably
+	CodeLowDensity Code = "low-density"
+	// CodeQuarantinedChunk is a chunk set aside because it does not match the
+	// manifest
+	CodeQuarantinedChunk Code = "quarantined-chunk"
+	// CodeChunkConflict is a chunk received with different data from several inputs
+	CodeChunkConflict Code = "chunk-conflict"
+	// CodeCleanupFailed is a temporary file or directory that could not be removed,
+	// or a file that could not be closed
+	CodeCleanupFailed Code = "cleanup-failed"
+	// CodeOptionalOutput is an optional output that could not be produced, e.g. a
+	// copy of the frames kept for reference
+	CodeOptionalOutput Code = "optional-output"
+	// CodeStreamMismatch is a file reconstructed from a stream that does not match
+	// the stream info of its side channel, e.g. the subtitle track of a video
+	CodeStreamMismatch Code = "stream-mismatch"
+)
+
+// Diagnostic is a non-fatal issue
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     Code     `json:"code"`
+	// Subject is the file, frame, or chunk the diagnostic is about, if any
+	Subject string `json:"subject,omitempty"`
+	Message string `json:"message"`
+}
+
+// String returns the diagnostic in one line
+func (d Diagnostic) String() string {
+	if d.Subject == "" {
+		return fmt.Sprintf("%s [%s] %s", d.Severity, d.Code, d.Message)
+	}
+
+	return fmt.Sprintf("%s [%s] %s: %s", d.Severity, d.Code, d.Subject, d.Message)
+}
+
+// Collector accumulates diagnostics, safely for concurrent use. A nil Collector
+// discards them, so that code reporting them needs no checks.
+type Collector struct {
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+}
+
+// NewCollector creates an empty Collector
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// Add records a diagnostic with a message formatted like fmt.Sprintf
+func (c *Collector) Add(severity Severity, code Code, subject, format string, args ...any) {
+	if c == nil {
+		return
+	}
+
+	d := Diagnostic{Severity: severity, Code: code, Subject: subject, Message: fmt.Sprintf(format, args...)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// Infof records an Info diagnostic
+func (c *Collector) Infof(code Code, subject, format string, args ...any) {
+	c.Add(Info, code, subject, format, args...)
+}
+
+// Warnf records a Warning diagnostic
+func (c *Collector) Warnf(code Code, subject, format string, args ...any) {
+	c.Add(Warning, code, subject, format, args...)
+}
+
+// Errorf records an Error diagnostic
+func (c *Collector) Errorf(code Code, subject, format string, args ...any) {
+	c.Add(Error, code, subject, format, args...)
+}
+
+// Diagnostics returns the recorded diagnostics in the order they were recorded
+func (c *Collector) Diagnostics() []Diagnostic {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Diagnostic(nil), c.diagnostics...)
+}
+
+// Count returns the number of recorded diagnostics of the given severity
+func (c *Collector) Count(severity Severity) int {
+	n := 0
+
+	for _, d := range c.Diagnostics() {
+		if d.Severity == severity {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Summary returns the number of diagnostics of every severity, most severe first,
+// e.g. "1 error, 3 warnings, 2 info", or "" if none were recorded
+func (c *Collector) Summary() string {
+	var parts []string
+
+	for s := Error; s >= Info; s-- {
+		if n := c.Count(s); n > 0 {
+			name := s.String()
+			if n > 1 && s != Info {
+				name += "s"
+			}
+
+			parts = append(parts, fmt.Sprintf("%d %s", n, name))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Report writes the recorded diagnostics to w, most severe first, showing at most
+// perCode diagnostics of each code followed by the number of the others. A perCode
+// of 0 or less shows them all. Nothing is written if none were recorded.
+func (c *Collector) Report(w io.Writer, perCode int) error {
+	diagnostics := c.Diagnostics()
+	if len(diagnostics) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Severity > diagnostics[j].Severity
+	})
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Diagnostics: %s\n", c.Summary())
+
+	shown := make(map[Code]int)
+	hidden := make(map[Code]int)
+
+	var codes []Code
+
+	for _, d := range diagnostics {
+		if perCode > 0 && shown[d.Code] >= perCode {
+			if hidden[d.Code] == 0 {
+				codes = append(codes, d.Code)
+			}
+
+			hidden[d.Code]++
+
+			continue
+		}
+
+		shown[d.Code]++
+		fmt.Fprintf(&b, "  %s\n", d)
+	}
+
+	for _, code := range codes {
+		fmt.Fprintf(&b, "  ... and %d more [%s]\n", hidden[code], code)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// WriteJSON writes the recorded diagnostics to w as a JSON array, in the order
+// they were recorded
+func (c *Collector) WriteJSON(w io.Writer) error {
+	diagnostics := c.Diagnostics()
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+
+	data, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+
+	c.Infof(CodeDuplicateFrame, "frame_0002.png", "duplicate QR code skipped")
+	c.Warnf(CodeSkippedFrame, "frame_0001.png", "failed to decode: %s", "not found")
+	c.Errorf(CodeCleanupFailed, "", "failed to remove temporary directory")
+	c.Infof(CodeDuplicateFrame, "frame_0003.png", "duplicate QR code skipped")
+
+	if got := c.Summary(); got != "1 error, 1 warning, 2 info" {
+		t.Fatalf("Summary() = %q", got)
+	}
+
+	diagnostics := c.Diagnostics()
+	if len(diagnostics) != 4 || diagnostics[1].Message != "failed to decode: not found" {
+		t.Fatalf("Diagnostics() = %+v", diagnostics)
+	}
+
+	var b bytes.Buffer
+	if err := c.Report(&b, 1); err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+
+	want := `Diagnostics: 1 error, 1 warning, 2 info
+  error [cleanup-failed] failed to remove temporary directory
+  warning [skipped-frame] frame_0001.png: failed to decode: not found
+  info [duplicate-frame] frame_0002.png: duplicate QR code skipped
+  ... and 1 more [duplicate-frame]
+`
+	if b.String() != want {
+		t.Fatalf("Report wrote:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestCollectorJSON(t *testing.T) {
+	c := NewCollector()
+	c.Warnf(CodeRenamedOutput, "2", "written as %s", "2_notes.txt")
+
+	var b bytes.Buffer
+	if err := c.WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	if !strings.Contains(b.String(), `"severity": "warning"`) || !strings.Contains(b.String(), `"code": "renamed-output"`) {
+		t.Fatalf("WriteJSON wrote %s", b.String())
+	}
+
+	var decoded []Diagnostic
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to decode diagnostics: %v", err)
+	}
+
+	if len(decoded) != 1 || decoded[0] != c.Diagnostics()[0] {
+		t.Fatalf("Decoded %+v, want %+v", decoded, c.Diagnostics())
+	}
+}
+
+func TestNilCollector(t *testing.T) {
+	var c *Collector
+
+	c.Warnf(CodeLowDensity, "", "ignored")
+
+	if c.Diagnostics() != nil || c.Summary() != "" {
+		t.Fatal("A nil Collector should discard diagnostics")
+	}
+
+	var b bytes.Buffer
+	if err := c.Report(&b, 0); err != nil || b.Len() != 0 {
+		t.Fatalf("Report of a nil Collector wrote %q, %v", b.String(), err)
+	}
+}
+
+func TestCollectorConcurrent(t *testing.T) {
+	c := NewCollector()
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range 100 {
+				c.Warnf(CodeLowDensity, "", "small modules")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := c.Count(Warning); n != 800 {
+		t.Fatalf("Count(Warning) = %d, want 800", n)
+	}
+}
@@ -0,0 +1,247 @@
+// Package erasure implements a systematic Reed-Solomon erasure code over GF(2^8).
+// Data is cut into equally sized data shards, and parity shards are computed from
+// them such that any data shards lost, up to the number of parity shards, can be
+// reconstructed from the shards that remain. The data shards are stored verbatim.
+//
+// Parity shards are computed with a Cauchy matrix, every square submatrix of which
+// is invertible, so any combination of surviving shards can be used.
+package erasure
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxShards is the maximum number of data and parity shards of an Encoder
+const MaxShards = 256
+
+var (
+	// ErrShardCount is returned when the number of shards does not match the Encoder
+	ErrShardCount = errors.New("wrong number of shards")
+	// ErrShardSize is returned when the shards are not all of the same size
+	ErrShardSize = errors.New("shards differ in size")
+	// ErrTooFewShards is returned when too many shards are missing to reconstruct the data
+	ErrTooFewShards = errors.New("too few shards to reconstruct the data")
+)
+
+// Encoder computes and uses the parity shards of a fixed number of data shards
+type Encoder struct {
+	dataShards   int
+	parityShards int
+	// matrix holds the rows of the encoding matrix, the identity for the data
+	// shards followed by the Cauchy rows of the parity shards
+	matrix [][]byte
+}
+
+// New returns an Encoder for dataShards data shards and parityShards parity shards.
+// Both must be positive and their sum at most MaxShards.
+func New(dataShards, parityShards int) (*Encoder, error) {
+	if dataShards < 1 || parityShards < 1 || dataShards+parityShards > MaxShards {
+		return nil, fmt.Errorf("%w: %d data and %d parity shards (at most %d in total)",
+			ErrShardCount, dataShards, parityShards, MaxShards)
+	}
+
+	total := dataShards + parityShards
+	matrix := make([][]byte, total)
+
+	for i := range dataShards {
+		matrix[i] = make([]byte, dataShards)
+		matrix[i][i] = 1
+	}
+
+	// The Cauchy matrix 1/(x_i + y_j) with x_i and y_j all distinct
+	for i := dataShards; i < total; i++ {
+		matrix[i] = make([]byte, dataShards)
+		for j := range dataShards {
+			matrix[i][j] = gfInverse(byte(i) ^ byte(j))
+		}
+	}
+
+	return &Encoder{dataShards: dataShards, parityShards: parityShards, matrix: matrix}, nil
+}
+
+// DataShards returns the number of data shards
+func (e *Encoder) DataShards() int {
+	return e.dataShards
+}
+
+// ParityShards returns the number of parity shards
+func (e *Encoder) ParityShards() int {
+	return e.parityShards
+}
+
+// Encode computes the parity shards of shards, which holds the data shards followed
+// by the parity shards. The data shards must all be of the same size, parity shards
+// of another size, e.g. nil, are allocated.
+func (e *Encoder) Encode(shards [][]byte) error {
+	if len(shards) != e.dataShards+e.parityShards {
+		return fmt.Errorf("%w: got %d, want %d", ErrShardCount, len(shards), e.dataShards+e.parityShards)
+	}
+
+	size, err := e.shardSize(shards[:e.dataShards])
+	if err != nil {
+		return err
+	}
+
+	for i := e.dataShards; i < len(shards); i++ {
+		if len(shards[i]) != size {
+			shards[i] = make([]byte, size)
+		}
+
+		e.encodeRow(e.matrix[i], shards[:e.dataShards], shards[i])
+	}
+
+	return nil
+}
+
+// Reconstruct restores the missing shards of shards, the data shards followed by the
+// parity shards, where a missing shard is nil. It returns an error wrapping
+// ErrTooFewShards if fewer than DataShards shards are present.
+func (e *Encoder) Reconstruct(shards [][]byte) error {
+	if len(shards) != e.dataShards+e.parityShards {
+		return fmt.Errorf("%w: got %d, want %d", ErrShardCount, len(shards), e.dataShards+e.parityShards)
+	}
+
+	// Use the first DataShards shards that are present
+	present := make([]int, 0, e.dataShards)
+	for i, shard := range shards {
+		if shard != nil && len(present) < e.dataShards {
+			present = append(present, i)
+		}
+	}
+
+	if len(present) < e.dataShards {
+		return fmt.Errorf("%w: %d of %d shards present, %d needed",
+			ErrTooFewShards, countPresent(shards), len(shards), e.dataShards)
+	}
+
+	available := make([][]byte, len(present))
+	for i, index := range present {
+		available[i] = shards[index]
+	}
+
+	size, err := e.shardSize(available)
+	if err != nil {
+		return err
+	}
+
+	// The present shards are the rows of the encoding matrix applied to the data;
+	// the inverse of those rows recovers the missing data shards
+	sub := make([][]byte, len(present))
+	for i, index := range present {
+		sub[i] = e.matrix[index]
+	}
+
+	inverse, err := invertMatrix(sub)
+	if err != nil {
+		return err
+	}
+
+	for i := range e.dataShards {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+			e.encodeRow(inverse[i], available, shards[i])
+		}
+	}
+
+	for i := e.dataShards; i < len(shards); i++ {
+		if shards[i] == nil {
+			shards[i] = make([]byte, size)
+			e.encodeRow(e.matrix[i], shards[:e.dataShards], shards[i])
+		}
+	}
+
+	return nil
+}
+
+// shardSize returns the size shared by all shards
+func (e *Encoder) shardSize(shards [][]byte) (int, error) {
+	size := len(shards[0])
+
+	for _, shard := range shards[1:] {
+		if len(shard) != size {
+			return 0, fmt.Errorf("%w: %d and %d bytes", ErrShardSize, size, len(shard))
+		}
+	}
+
+	return size, nil
+}
+
+// encodeRow sets out to the sum of the inputs multiplied by the coefficients of row
+func (e *Encoder) encodeRow(row []byte, inputs [][]byte, out []byte) {
+	clear(out)
+
+	for j, input := range inputs {
+		c := row[j]
+		if c == 0 {
+			continue
+		}
+
+		for k, b := range input {
+			out[k] ^= gfMul(c, b)
+		}
+	}
+}
+
+// countPresent returns the number of shards that are not nil
+func countPresent(shards [][]byte) int {
+	n := 0
+
+	for _, shard := range shards {
+		if shard != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// invertMatrix returns the inverse of the square matrix m by Gauss-Jordan elimination
+func invertMatrix(m [][]byte) ([][]byte, error) {
+	n := len(m)
+
+	// Work on m augmented with the identity
+	work := make([][]byte, n)
+	for i := range m {
+		work[i] = make([]byte, 2*n)
+		copy(work[i], m[i])
+		work[i][n+i] = 1
+	}
+
+	for col := range n {
+		pivot := col
+		for pivot < n && work[pivot][col] == 0 {
+			pivot++
+		}
+
+		if pivot == n {
+			return nil, errors.New("singular matrix")
+		}
+
+		work[col], work[pivot] = work[pivot], work[col]
+
+		if scale := gfInverse(work[col][col]); scale != 1 {
+			for k := range work[col] {
+				work[col][k] = gfMul(work[col][k], scale)
+			}
+		}
+
+		for row := range n {
+			if row == col || work[row][col] == 0 {
+				continue
+			}
+
+			factor := work[row][col]
+			for k := range work[row] {
+				work[row][k] ^= gfMul(factor, work[col][k])
+			}
+		}
+	}
+
+	inverse := make([][]byte, n)
+	for i := range work {
+		inverse[i] = work[i][n:]
+	}
+
+	return inverse, nil
+}
@@ -0,0 +1,110 @@
+package erasure
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestGaloisField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := gfMul(byte(a), gfInverse(byte(a))); got != 1 {
+			t.Fatalf("%d * inverse(%d) = %d, want 1", a, a, got)
+		}
+	}
+
+	if got := gfMul(0x53, 0xca); got != 0x8f {
+		t.Errorf("gfMul(0x53, 0xca) = %#x, want 0x8f", got)
+	}
+}
+
+func TestReconstruct(t *testing.T) {
+	tests := []struct {
+		data, parity int
+	}{
+		{1, 1},
+		{4, 2},
+		{10, 3},
+		{200, 56},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	for _, tt := range tests {
+		enc, err := New(tt.data, tt.parity)
+		if err != nil {
+			t.Fatalf("New(%d, %d) failed: %v", tt.data, tt.parity, err)
+		}
+
+		shards := make([][]byte, tt.data+tt.parity)
+		for i := range tt.data {
+			shards[i] = make([]byte, 37)
+			rng.Read(shards[i])
+		}
+
+		if err := enc.Encode(shards); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+
+		want := make([][]byte, len(shards))
+		for i := range shards {
+			want[i] = bytes.Clone(shards[i])
+		}
+
+		// Lose as many random shards as there are parity shards
+		for range 5 {
+			damaged := make([][]byte, len(shards))
+			copy(damaged, want)
+
+			for _, i := range rng.Perm(len(shards))[:tt.parity] {
+				damaged[i] = nil
+			}
+
+			if err := enc.Reconstruct(damaged); err != nil {
+				t.Fatalf("Reconstruct(%d+%d) failed: %v", tt.data, tt.parity, err)
+			}
+
+			for i := range damaged {
+				if !bytes.Equal(damaged[i], want[i]) {
+					t.Fatalf("Reconstruct(%d+%d) shard %d = %x, want %x", tt.data, tt.parity, i, damaged[i], want[i])
+				}
+			}
+		}
+
+		// One shard more cannot be reconstructed
+		damaged := make([][]byte, len(shards))
+		copy(damaged, want)
+
+		for i := range tt.parity + 1 {
+			damaged[i] = nil
+		}
+
+		if err := enc.Reconstruct(damaged); !errors.Is(err, ErrTooFewShards) {
+			t.Errorf("Reconstruct(%d+%d) with %d shards lost error = %v, want %v", tt.data, tt.parity, tt.parity+1, err, ErrTooFewShards)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, tt := range [][2]int{{0, 1}, {1, 0}, {200, 57}} {
+		if _, err := New(tt[0], tt[1]); !errors.Is(err, ErrShardCount) {
+			t.Errorf("New(%d, %d) error = %v, want %v", tt[0], tt[1], err, ErrShardCount)
+		}
+	}
+}
+
+func TestEncodeShardSize(t *testing.T) {
+	enc, err := New(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := enc.Encode([][]byte{{1, 2}, {3}, nil}); !errors.Is(err, ErrShardSize) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrShardSize)
+	}
+
+	if err := enc.Encode([][]byte{{1, 2}, {3, 4}}); !errors.Is(err, ErrShardCount) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrShardCount)
+	}
+}
@@ -0,0 +1,39 @@
+package erasure
+
+// The field GF(2^8) with the primitive polynomial x^8 + x^4 + x^3 + x^2 + 1
+const primitivePolynomial = 0x11d
+
+var (
+	// gfExp maps i to 2^i, twice over so products of logarithms need no modulo
+	gfExp [510]byte
+	// gfLog maps a non-zero element to its logarithm
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := range 255 {
+		gfExp[i] = byte(x)
+		gfExp[i+255] = byte(x)
+		gfLog[x] = i
+
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= primitivePolynomial
+		}
+	}
+}
+
+// gfMul returns the product of a and b
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+// gfInverse returns the multiplicative inverse of a, which must not be zero
+func gfInverse(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
@@ -0,0 +1,165 @@
+package features
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// Version is the version of the binary. Release builds set it with
+//
+//	go build -ldflags "-X github.com/dyammarcano/qrfiletransfer/pkg/features.Version=v1.2.0"
+//
+// Otherwise the module version recorded by the go command is reported.
+var Version string
+
+// CapabilityReport describes what the binary supports, so that orchestration scripts
+// can adapt to it instead of parsing help texts
+type CapabilityReport struct {
+	// Version is the version of the binary, see Version
+	Version string `json:"version"`
+	// GoVersion is the version of Go the binary was built with
+	GoVersion string `json:"go_version"`
+	// OS and Arch are the platform the binary was built for
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Protocols lists the file and payload formats read and written
+	Protocols []qrfiletransfer.Protocol `json:"protocols"`
+	// Features maps the optional features to whether they are compiled in: the
+	// features of List, webcam scanning, reading videos without ffmpeg, PDF output,
+	// and the WebAssembly build
+	Features map[string]bool `json:"features"`
+	// Tools maps the external tools used by optional features to their availability
+	Tools map[string]Tool `json:"tools"`
+}
+
+// Tool describes the availability of an external tool
+type Tool struct {
+	// Available is set when the tool is found in the PATH
+	Available bool `json:"available"`
+	// Path is the location of the tool, if available
+	Path string `json:"path,omitempty"`
+	// Version is the version the tool reports, if available
+	Version string `json:"version,omitempty"`
+}
+
+// Capabilities reports the capabilities of the running binary. Looking up the
+// external tools runs them once to query their version.
+func Capabilities() CapabilityReport {
+	c := CapabilityReport{
+		Version:   binaryVersion(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Protocols: qrfiletransfer.Protocols(),
+		Features:  make(map[string]bool),
+		Tools:     map[string]Tool{"ffmpeg": lookupTool("ffmpeg", "-version")},
+	}
+
+	for _, f := range List() {
+		c.Features[f.Name] = f.Enabled
+	}
+
+	// scan captures the webcam with ffmpeg and decodes the frames
+	c.Features["webcam"] = CLI && Video && Decode
+	// read decodes y4m, Motion JPEG, and image directories without ffmpeg
+	c.Features["native-video"] = CLI && Decode
+	c.Features["pdf"] = true
+	c.Features["wasm"] = runtime.GOARCH == "wasm"
+
+	return c
+}
+
+// String returns the report in lines for humans, e.g.
+//
+//	qrfiletransfer v1.2.0
+//	Go:        go1.23.0 linux/amd64
+//	Protocols: session 1, manifest 1, ..., payload-binary 1-3, text-chunk 1, parity-chunk 1, ack 1, stream-info 1
+//	Features:  cli, decode, pdf, video, webcam
+//	Tools:     ffmpeg 6.1.1 (/usr/bin/ffmpeg)
+func (c CapabilityReport) String() string {
+	protocols := make([]string, 0, len(c.Protocols))
+	for _, p := range c.Protocols {
+		versions := fmt.Sprint(p.Versions[0])
+		if len(p.Versions) > 1 {
+			versions += fmt.Sprintf("-%d", p.Versions[len(p.Versions)-1])
+		}
+
+		protocols = append(protocols, p.Name+" "+versions)
+	}
+
+	var enabled []string
+
+	for name, ok := range c.Features {
+		if ok {
+			enabled = append(enabled, name)
+		}
+	}
+
+	sort.Strings(enabled)
+
+	tools := make([]string, 0, len(c.Tools))
+	for name, t := range c.Tools {
+		switch {
+		case !t.Available:
+			tools = append(tools, name+" not found")
+		case t.Version != "":
+			tools = append(tools, fmt.Sprintf("%s %s (%s)", name, t.Version, t.Path))
+		default:
+			tools = append(tools, fmt.Sprintf("%s (%s)", name, t.Path))
+		}
+	}
+
+	sort.Strings(tools)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "qrfiletransfer %s\n", c.Version)
+	fmt.Fprintf(&b, "Go:        %s %s/%s\n", c.GoVersion, c.OS, c.Arch)
+	fmt.Fprintf(&b, "Protocols: %s\n", strings.Join(protocols, ", "))
+	fmt.Fprintf(&b, "Features:  %s\n", strings.Join(enabled, ", "))
+	fmt.Fprintf(&b, "Tools:     %s\n", strings.Join(tools, ", "))
+
+	return b.String()
+}
+
+// binaryVersion returns Version, or the module version if it is not set
+func binaryVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "(devel)"
+}
+
+// lookupTool finds the named tool in the PATH and reads its version from the third
+// word of the first line it prints for versionFlag, e.g. "ffmpeg version 6.1.1 ..."
+func lookupTool(name, versionFlag string) Tool {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return Tool{}
+	}
+
+	t := Tool{Available: true, Path: path}
+
+	out, err := exec.Command(path, versionFlag).Output()
+	if err != nil {
+		return t
+	}
+
+	line, _, _ := strings.Cut(string(out), "\n")
+	if fields := strings.Fields(line); len(fields) >= 3 && fields[1] == "version" {
+		t.Version = fields[2]
+	}
+
+	return t
+}
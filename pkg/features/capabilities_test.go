@@ -0,0 +1,62 @@
+package features
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	c := Capabilities()
+
+	if c.Version == "" || c.GoVersion == "" || c.OS == "" || c.Arch == "" {
+		t.Errorf("Capabilities() does not describe the binary: %+v", c)
+	}
+
+	for _, f := range List() {
+		if enabled, ok := c.Features[f.Name]; !ok || enabled != f.Enabled {
+			t.Errorf("Feature %s enabled = %v, want %v", f.Name, enabled, f.Enabled)
+		}
+	}
+
+	for _, name := range []string{"webcam", "native-video", "pdf", "wasm"} {
+		if _, ok := c.Features[name]; !ok {
+			t.Errorf("Capabilities() does not report feature %s", name)
+		}
+	}
+
+	if ffmpeg, ok := c.Tools["ffmpeg"]; !ok || ffmpeg.Available != (ffmpeg.Path != "") {
+		t.Errorf("Capabilities() reports ffmpeg as %+v", ffmpeg)
+	}
+
+	var payloads []int
+
+	for _, p := range c.Protocols {
+		if len(p.Versions) == 0 {
+			t.Errorf("Protocol %s has no versions", p.Name)
+		}
+
+		if p.Name == "payload-binary" {
+			payloads = p.Versions
+		}
+	}
+
+	if len(payloads) != 3 || payloads[0] != 1 || payloads[2] != 3 {
+		t.Errorf("Binary payload versions = %v, want [1 2 3]", payloads)
+	}
+}
+
+func TestCapabilitiesVersion(t *testing.T) {
+	defer func(v string) { Version = v }(Version)
+
+	Version = "v1.2.3"
+
+	data, err := json.Marshal(Capabilities())
+	if err != nil {
+		t.Fatalf("Failed to encode capabilities: %v", err)
+	}
+
+	if !strings.Contains(string(data), `"version":"v1.2.3"`) {
+		t.Errorf("Capabilities do not carry the version set at build time: %s", data)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build libonly
+
+package features
+
+// CLI is set when the full command line is compiled in
+const CLI = false
@@ -0,0 +1,6 @@
+//go:build nodecode || libonly
+
+package features
+
+// Decode is set when the QR code decoder is compiled in
+const Decode = false
@@ -0,0 +1,47 @@
+// Package features reports the optional features compiled into the qrfiletransfer
+// binary. Features are selected at compile time with build tags, so that a binary
+// for a constrained appliance leaves out the dependencies it does not need:
+//
+//	novideo   without ffmpeg integration: no video generation or scan, read only
+//	          takes the formats of its built-in decoder
+//	nodecode  without the gozxing QR code decoder: no read, scan, or transcode
+//	libonly   a minimal command line with split, join, and status instead of the
+//	          cobra based one, without ffmpeg and gozxing
+//
+// The library packages under pkg never depend on ffmpeg, gozxing, or cobra, except
+// qrdecode whose default decoder is gozxing.
+// Capabilities extends the list with the version of the binary, the protocol
+// versions it supports, and the availability of external tools.
+package features
+
+// Feature describes an optional feature of the binary
+type Feature struct {
+	// Name is the feature name
+	Name string
+	// Tag is the build tag that leaves the feature out
+	Tag string
+	// Enabled is set when the feature is compiled in
+	Enabled bool
+	// Description tells what the feature provides
+	Description string
+}
+
+// List returns the optional features in a fixed order
+func List() []Feature {
+	return []Feature{
+		{Name: "cli", Tag: "libonly", Enabled: CLI, Description: "full command line with every command"},
+		{Name: "video", Tag: "novideo", Enabled: Video, Description: "ffmpeg video generation and capture"},
+		{Name: "decode", Tag: "nodecode", Enabled: Decode, Description: "QR code decoding from images"},
+	}
+}
+
+// Enabled reports whether the named feature is compiled in
+func Enabled(name string) bool {
+	for _, f := range List() {
+		if f.Name == name {
+			return f.Enabled
+		}
+	}
+
+	return false
+}
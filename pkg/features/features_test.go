@@ -0,0 +1,26 @@
+package features
+
+import "testing"
+
+func TestList(t *testing.T) {
+	want := map[string]bool{"cli": CLI, "video": Video, "decode": Decode}
+
+	list := List()
+	if len(list) != len(want) {
+		t.Fatalf("List() returned %d features, want %d", len(list), len(want))
+	}
+
+	for _, f := range list {
+		if enabled, ok := want[f.Name]; !ok || f.Enabled != enabled {
+			t.Errorf("Feature %s enabled = %v, want %v", f.Name, f.Enabled, enabled)
+		}
+
+		if Enabled(f.Name) != f.Enabled {
+			t.Errorf("Enabled(%q) = %v, want %v", f.Name, Enabled(f.Name), f.Enabled)
+		}
+	}
+
+	if Enabled("unknown") {
+		t.Error("Enabled() reports an unknown feature")
+	}
+}
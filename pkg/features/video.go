@@ -0,0 +1,6 @@
+//go:build !novideo && !libonly
+
+package features
+
+// Video is set when the ffmpeg integration is compiled in
+const Video = true
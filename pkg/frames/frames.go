@@ -0,0 +1,268 @@
+// Package frames provides analysis of video frames before QR code decoding.
+// High frame rate recordings, e.g. 120 or 240fps slow-motion captures, contain long
+// bursts of near-identical frames for every QR code shown. Grouping those bursts
+// and decoding only their sharpest frames keeps the decoding time proportional to
+// the number of codes instead of the number of frames. A Report records the outcome
+// of decoding every frame.
+package frames
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"sort"
+
+	// Register the decoders of the formats frames are extracted in
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+const (
+	// signatureSize is the width and height of a frame signature
+	signatureSize = 32
+
+	// DefaultClusterThreshold is the default mean grey level difference (0-255)
+	// below which consecutive frames are considered to show the same QR code
+	DefaultClusterThreshold = 10.0
+)
+
+// Signature is a downscaled grayscale thumbnail used to compare frames cheaply
+type Signature [signatureSize * signatureSize]uint8
+
+// Frame holds the analysis of a single frame
+type Frame struct {
+	// Path is the path of the frame file, or the name of a frame in memory
+	Path string
+	// Image is the frame in memory, nil for a frame file
+	Image image.Image
+	// Signature is the thumbnail of the frame
+	Signature Signature
+	// Sharpness is the variance of the Laplacian of the frame, higher is sharper
+	Sharpness float64
+}
+
+// Cluster is a run of consecutive frames showing the same content
+type Cluster struct {
+	// Frames lists the frames of the cluster in temporal order
+	Frames []Frame
+}
+
+// Analyze decodes the image at path and computes its signature and sharpness
+func Analyze(path string) (Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to open frame: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to decode frame: %w", err)
+	}
+
+	frame := AnalyzeImage(path, img)
+	frame.Image = nil
+
+	return frame, nil
+}
+
+// AnalyzeImage computes the signature and sharpness of a frame in memory, which
+// is kept in the returned Frame under the given name
+func AnalyzeImage(name string, img image.Image) Frame {
+	return Frame{
+		Path:      name,
+		Image:     img,
+		Signature: NewSignature(img),
+		Sharpness: Sharpness(img),
+	}
+}
+
+// NewSignature computes the signature of an image by averaging the grey levels of
+// a signatureSize x signatureSize grid of cells
+func NewSignature(img image.Image) Signature {
+	var sig Signature
+
+	b := img.Bounds()
+	if b.Empty() {
+		return sig
+	}
+
+	for cy := range signatureSize {
+		y0 := b.Min.Y + cy*b.Dy()/signatureSize
+		y1 := max(b.Min.Y+(cy+1)*b.Dy()/signatureSize, y0+1)
+
+		for cx := range signatureSize {
+			x0 := b.Min.X + cx*b.Dx()/signatureSize
+			x1 := max(b.Min.X+(cx+1)*b.Dx()/signatureSize, x0+1)
+
+			var sum, n int
+
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += int(grey(img, x, y))
+					n++
+				}
+			}
+
+			sig[cy*signatureSize+cx] = uint8(sum / n)
+		}
+	}
+
+	return sig
+}
+
+// Distance returns the mean absolute grey level difference between two signatures
+func (s *Signature) Distance(other *Signature) float64 {
+	var sum int
+
+	for i := range s {
+		d := int(s[i]) - int(other[i])
+		if d < 0 {
+			d = -d
+		}
+
+		sum += d
+	}
+
+	return float64(sum) / float64(len(s))
+}
+
+// Sharpness returns the variance of the Laplacian of the grey levels of an image.
+// Motion blur and defocus remove edges, which lowers the variance.
+func Sharpness(img image.Image) float64 {
+	b := img.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return 0
+	}
+
+	w, h := b.Dx(), b.Dy()
+
+	grays := make([]float64, w*h)
+	for y := range h {
+		for x := range w {
+			grays[y*w+x] = float64(grey(img, b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	var sum, sumSq float64
+
+	n := 0
+
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			l := grays[i-w] + grays[i+w] + grays[i-1] + grays[i+1] - 4*grays[i]
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+
+	mean := sum / float64(n)
+
+	return sumSq/float64(n) - mean*mean
+}
+
+// ClusterFrames groups consecutive frames whose signatures differ from the previous
+// frame by at most threshold. Frames must be given in temporal order.
+func ClusterFrames(frames []Frame, threshold float64) []Cluster {
+	var clusters []Cluster
+
+	for i, frame := range frames {
+		if i > 0 && frames[i-1].Signature.Distance(&frame.Signature) <= threshold {
+			last := &clusters[len(clusters)-1]
+			last.Frames = append(last.Frames, frame)
+
+			continue
+		}
+
+		clusters = append(clusters, Cluster{Frames: []Frame{frame}})
+	}
+
+	return clusters
+}
+
+// Clusterer groups frames into clusters as they arrive, like ClusterFrames, so
+// that a stream of frames is clustered without holding all of them in memory
+type Clusterer struct {
+	threshold float64
+	keep      int
+	last      Signature
+	current   *Cluster
+}
+
+// NewClusterer returns a Clusterer grouping frames that differ from the previous
+// frame by at most threshold, which keeps only the keep sharpest frames of every
+// cluster, all of them if keep is 0
+func NewClusterer(threshold float64, keep int) *Clusterer {
+	return &Clusterer{threshold: threshold, keep: keep}
+}
+
+// Add adds the next frame in temporal order, and returns the cluster it ends, or
+// nil if it belongs to the current cluster
+func (c *Clusterer) Add(frame Frame) *Cluster {
+	var done *Cluster
+
+	if c.current != nil && c.last.Distance(&frame.Signature) > c.threshold {
+		done = c.current
+		c.current = nil
+	}
+
+	c.last = frame.Signature
+
+	if c.current == nil {
+		c.current = &Cluster{}
+	}
+
+	c.current.Frames = append(c.current.Frames, frame)
+
+	// Drop the least sharp frame once more than keep are held
+	if c.keep > 0 && len(c.current.Frames) > c.keep {
+		blurriest := 0
+		for i, f := range c.current.Frames {
+			if f.Sharpness < c.current.Frames[blurriest].Sharpness {
+				blurriest = i
+			}
+		}
+
+		c.current.Frames = append(c.current.Frames[:blurriest], c.current.Frames[blurriest+1:]...)
+	}
+
+	return done
+}
+
+// Flush returns the current cluster, nil if there is none, and starts a new one
+func (c *Clusterer) Flush() *Cluster {
+	done := c.current
+	c.current = nil
+
+	return done
+}
+
+// Candidates returns up to n frames of the cluster, sharpest first
+func (c *Cluster) Candidates(n int) []Frame {
+	candidates := append([]Frame(nil), c.Frames...)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Sharpness > candidates[j].Sharpness
+	})
+
+	if n > 0 && len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	return candidates
+}
+
+// grey returns the grey level of the pixel at x, y
+func grey(img image.Image, x, y int) uint8 {
+	if g, ok := img.(*image.Gray); ok {
+		return g.GrayAt(x, y).Y
+	}
+
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
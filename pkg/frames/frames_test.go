@@ -0,0 +1,178 @@
+package frames
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// qrImage renders a QR code for content as a grayscale image
+func qrImage(t *testing.T, content string) *image.Gray {
+	t.Helper()
+
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	src := q.Image(256)
+	img := image.NewGray(src.Bounds())
+
+	for y := src.Bounds().Min.Y; y < src.Bounds().Max.Y; y++ {
+		for x := src.Bounds().Min.X; x < src.Bounds().Max.X; x++ {
+			img.Set(x, y, src.At(x, y))
+		}
+	}
+
+	return img
+}
+
+// boxBlur returns img blurred with a box of the given radius
+func boxBlur(img *image.Gray, radius int) *image.Gray {
+	b := img.Bounds()
+	out := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var sum, n int
+
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					p := image.Pt(x+dx, y+dy)
+					if p.In(b) {
+						sum += int(img.GrayAt(p.X, p.Y).Y)
+						n++
+					}
+				}
+			}
+
+			out.SetGray(x, y, color.Gray{Y: uint8(sum / n)})
+		}
+	}
+
+	return out
+}
+
+func TestClusterFramesPicksSharpestFrame(t *testing.T) {
+	dir := t.TempDir()
+
+	var paths []string
+
+	// Two codes, each shown in a burst of frames with varying blur; the
+	// unblurred frame sits in the middle of the burst
+	for code, content := range []string{"first chunk", "second chunk"} {
+		sharp := qrImage(t, content)
+
+		for i, radius := range []int{3, 2, 0, 1, 2} {
+			img := sharp
+			if radius > 0 {
+				img = boxBlur(sharp, radius)
+			}
+
+			path := filepath.Join(dir, fmt.Sprintf("frame_%04d.png", code*5+i))
+
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatalf("Failed to create frame: %v", err)
+			}
+
+			if err := png.Encode(file, img); err != nil {
+				t.Fatalf("Failed to encode frame: %v", err)
+			}
+
+			if err := file.Close(); err != nil {
+				t.Fatalf("Failed to close frame: %v", err)
+			}
+
+			paths = append(paths, path)
+		}
+	}
+
+	analyzed := make([]Frame, 0, len(paths))
+
+	for _, path := range paths {
+		frame, err := Analyze(path)
+		if err != nil {
+			t.Fatalf("Analyze failed: %v", err)
+		}
+
+		analyzed = append(analyzed, frame)
+	}
+
+	clusters := ClusterFrames(analyzed, DefaultClusterThreshold)
+	if len(clusters) != 2 {
+		t.Fatalf("Expected 2 clusters, got %d", len(clusters))
+	}
+
+	for code, c := range clusters {
+		if len(c.Frames) != 5 {
+			t.Errorf("Cluster %d: expected 5 frames, got %d", code, len(c.Frames))
+		}
+
+		candidates := c.Candidates(2)
+		if len(candidates) != 2 {
+			t.Fatalf("Cluster %d: expected 2 candidates, got %d", code, len(candidates))
+		}
+
+		if want := paths[code*5+2]; candidates[0].Path != want {
+			t.Errorf("Cluster %d: expected sharpest frame %s, got %s", code, want, candidates[0].Path)
+		}
+	}
+}
+
+func TestClustererKeepsSharpestFrames(t *testing.T) {
+	c := NewClusterer(DefaultClusterThreshold, 2)
+
+	var clusters []*Cluster
+
+	for code, content := range []string{"first chunk", "second chunk"} {
+		sharp := qrImage(t, content)
+
+		for i, radius := range []int{3, 2, 0, 1, 2} {
+			img := sharp
+			if radius > 0 {
+				img = boxBlur(sharp, radius)
+			}
+
+			if done := c.Add(AnalyzeImage(fmt.Sprintf("frame %d", code*5+i), img)); done != nil {
+				clusters = append(clusters, done)
+			}
+		}
+	}
+
+	clusters = append(clusters, c.Flush())
+	if len(clusters) != 2 || c.Flush() != nil {
+		t.Fatalf("Expected 2 clusters, got %d", len(clusters))
+	}
+
+	for code, cluster := range clusters {
+		candidates := cluster.Candidates(0)
+		if len(candidates) != 2 || candidates[0].Image == nil {
+			t.Fatalf("Cluster %d: expected the 2 sharpest frames in memory, got %+v", code, candidates)
+		}
+
+		if want := fmt.Sprintf("frame %d", code*5+2); candidates[0].Path != want {
+			t.Errorf("Cluster %d: expected sharpest frame %s, got %s", code, want, candidates[0].Path)
+		}
+	}
+}
+
+func TestSignatureDistance(t *testing.T) {
+	a := NewSignature(qrImage(t, "same"))
+	b := NewSignature(qrImage(t, "same"))
+
+	if d := a.Distance(&b); d != 0 {
+		t.Errorf("Expected identical images to have distance 0, got %f", d)
+	}
+
+	c := NewSignature(qrImage(t, "different content"))
+	if d := a.Distance(&c); d <= DefaultClusterThreshold {
+		t.Errorf("Expected different codes to be farther apart than %f, got %f", DefaultClusterThreshold, d)
+	}
+}
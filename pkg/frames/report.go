@@ -0,0 +1,238 @@
+package frames
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statuses of a Record
+const (
+	// StatusDecoded is a frame holding at least one chunk not decoded before
+	StatusDecoded = "decoded"
+	// StatusDuplicate is a frame holding only chunks already decoded, from an
+	// earlier frame or by a previous run
+	StatusDuplicate = "duplicate"
+	// StatusInvalid is a frame whose QR codes hold no valid chunk payload
+	StatusInvalid = "invalid"
+	// StatusFailed is a frame in which no QR code could be read
+	StatusFailed = "failed"
+	// StatusSkipped is a frame that was not decoded, as another frame of its burst
+	// of near-duplicate frames was
+	StatusSkipped = "skipped"
+)
+
+// Record is the outcome of decoding a frame
+type Record struct {
+	// Frame is the number of the frame, from 1
+	Frame int
+	// Name is the name of the frame, e.g. "frame 12" or the path of an image
+	Name string
+	// Status is one of the Status constants
+	Status string
+	// Chunks lists the names of the chunks read from the frame
+	Chunks []string
+	// Latency is the time spent decoding the frame, 0 if it was skipped
+	Latency time.Duration
+	// Error tells why the frame failed to decode
+	Error string
+}
+
+// jsonRecord is a Record as written to JSON, with its latency in milliseconds
+type jsonRecord struct {
+	Frame     int      `json:"frame"`
+	Name      string   `json:"name"`
+	Status    string   `json:"status"`
+	Chunks    []string `json:"chunks,omitempty"`
+	LatencyMS float64  `json:"latency_ms"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Report lists the outcome of decoding every frame of a recording, for a user to
+// tell why a transfer is incomplete, e.g. frames failing in a row while the camera
+// was refocusing. A nil *Report records nothing.
+type Report struct {
+	records []*Record
+	byName  map[string]*Record
+}
+
+// NewReport returns an empty Report
+func NewReport() *Report {
+	return &Report{byName: make(map[string]*Record)}
+}
+
+// Add records a frame, skipped until its outcome is set
+func (r *Report) Add(name string) {
+	if r == nil {
+		return
+	}
+
+	record := &Record{Frame: len(r.records) + 1, Name: name, Status: StatusSkipped}
+	r.records = append(r.records, record)
+	r.byName[name] = record
+}
+
+// Attempted sets the outcome of decoding the frame named name in latency, failed
+// with err if it is not nil, and invalid until chunks are set by Decoded
+func (r *Report) Attempted(name string, latency time.Duration, err error) {
+	record := r.record(name)
+	if record == nil {
+		return
+	}
+
+	record.Latency = latency
+	record.Status, record.Error = StatusInvalid, "no chunk payload in the QR codes"
+
+	if err != nil {
+		record.Status, record.Error = StatusFailed, err.Error()
+	}
+}
+
+// Decoded sets the chunks read from the frame named name, status StatusDecoded if
+// fresh counts the chunks not decoded before, StatusDuplicate otherwise
+func (r *Report) Decoded(name string, chunks []string, fresh int) {
+	record := r.record(name)
+	if record == nil {
+		return
+	}
+
+	record.Chunks = chunks
+	record.Status, record.Error = StatusDecoded, ""
+
+	switch {
+	case len(chunks) == 0:
+		record.Status, record.Error = StatusInvalid, "no valid chunk in the QR codes"
+	case fresh == 0:
+		record.Status = StatusDuplicate
+	}
+}
+
+// record returns the record of the frame named name, nil if there is none
+func (r *Report) record(name string) *Record {
+	if r == nil {
+		return nil
+	}
+
+	return r.byName[name]
+}
+
+// Records returns the records of the frames, in order
+func (r *Report) Records() []Record {
+	if r == nil {
+		return nil
+	}
+
+	records := make([]Record, len(r.records))
+	for i, record := range r.records {
+		records[i] = *record
+	}
+
+	return records
+}
+
+// Summary counts the frames of every status, e.g. "40 decoded, 3 duplicate, 5
+// failed", leaving out statuses without frames
+func (r *Report) Summary() string {
+	counts := make(map[string]int)
+	for _, record := range r.Records() {
+		counts[record.Status]++
+	}
+
+	var parts []string
+
+	for _, status := range []string{StatusDecoded, StatusDuplicate, StatusInvalid, StatusFailed, StatusSkipped} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+
+	if len(parts) == 0 {
+		return "no frames"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// WriteJSON writes the report as a JSON object with the records in "frames"
+func (r *Report) WriteJSON(w io.Writer) error {
+	records := make([]jsonRecord, 0, len(r.Records()))
+	for _, record := range r.Records() {
+		records = append(records, jsonRecord{
+			Frame:     record.Frame,
+			Name:      record.Name,
+			Status:    record.Status,
+			Chunks:    record.Chunks,
+			LatencyMS: latencyMS(record.Latency),
+			Error:     record.Error,
+		})
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Frames []jsonRecord `json:"frames"`
+	}{records}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode frame report: %w", err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write frame report: %w", err)
+	}
+
+	return nil
+}
+
+// WriteCSV writes the report as CSV with a header row: frame, name, status,
+// chunks separated by semicolons, latency_ms, and error
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	rows := [][]string{{"frame", "name", "status", "chunks", "latency_ms", "error"}}
+	for _, record := range r.Records() {
+		rows = append(rows, []string{
+			strconv.Itoa(record.Frame),
+			record.Name,
+			record.Status,
+			strings.Join(record.Chunks, ";"),
+			strconv.FormatFloat(latencyMS(record.Latency), 'f', -1, 64),
+			record.Error,
+		})
+	}
+
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write frame report: %w", err)
+	}
+
+	return nil
+}
+
+// WriteFile writes the report to path, as CSV if its extension is .csv and as
+// JSON otherwise
+func (r *Report) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create frame report: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = r.WriteCSV(f)
+	} else {
+		err = r.WriteJSON(f)
+	}
+
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close frame report: %w", closeErr)
+	}
+
+	return err
+}
+
+// latencyMS returns d in milliseconds, rounded to the microsecond
+func latencyMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
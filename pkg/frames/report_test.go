@@ -0,0 +1,105 @@
+package frames
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testReport returns a report of four frames, one of each status but invalid
+func testReport() *Report {
+	r := NewReport()
+	for _, name := range []string{"frame 1", "frame 2", "frame 3", "frame 4"} {
+		r.Add(name)
+	}
+
+	r.Attempted("frame 1", 1500*time.Microsecond, nil)
+	r.Decoded("frame 1", []string{"notes_0000", "notes_0001"}, 2)
+	r.Attempted("frame 2", 2*time.Millisecond, errors.New("no QR code found"))
+	r.Attempted("frame 3", time.Millisecond, nil)
+	r.Decoded("frame 3", []string{"notes_0001"}, 0)
+
+	return r
+}
+
+func TestReportRecords(t *testing.T) {
+	records := testReport().Records()
+
+	want := []struct {
+		status string
+		err    string
+	}{
+		{StatusDecoded, ""},
+		{StatusFailed, "no QR code found"},
+		{StatusDuplicate, ""},
+		{StatusSkipped, ""},
+	}
+
+	if len(records) != len(want) {
+		t.Fatalf("Got %d records, want %d", len(records), len(want))
+	}
+
+	for i, record := range records {
+		if record.Frame != i+1 || record.Status != want[i].status || record.Error != want[i].err {
+			t.Errorf("Record %d = %+v, want status %s and error %q", i, record, want[i].status, want[i].err)
+		}
+	}
+
+	if got := testReport().Summary(); got != "1 decoded, 1 duplicate, 1 failed, 1 skipped" {
+		t.Errorf("Summary() = %q", got)
+	}
+
+	// A nil report records nothing
+	var r *Report
+	r.Add("frame 1")
+	r.Attempted("frame 1", time.Second, nil)
+
+	if r.Records() != nil || r.Summary() != "no frames" {
+		t.Error("Expected a nil report to hold no records")
+	}
+}
+
+func TestReportWriteJSONAndCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := testReport().WriteJSON(&b); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	var decoded struct {
+		Frames []struct {
+			Frame     int      `json:"frame"`
+			Status    string   `json:"status"`
+			Chunks    []string `json:"chunks"`
+			LatencyMS float64  `json:"latency_ms"`
+		} `json:"frames"`
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+
+	if len(decoded.Frames) != 4 || decoded.Frames[0].LatencyMS != 1.5 || len(decoded.Frames[0].Chunks) != 2 {
+		t.Errorf("Got %+v", decoded.Frames)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := testReport().WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 5 || lines[0] != "frame,name,status,chunks,latency_ms,error" ||
+		lines[1] != "1,frame 1,decoded,notes_0000;notes_0001,1.5," || lines[2] != "2,frame 2,failed,,2,no QR code found" {
+		t.Errorf("Got CSV report:\n%s", data)
+	}
+}
@@ -0,0 +1,82 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	// glyphWidth and glyphHeight are the size of a caption glyph in font pixels
+	glyphWidth  = 5
+	glyphHeight = 7
+
+	// captionPadding is the margin around the caption text in font pixels
+	captionPadding = 2
+)
+
+// captionFont holds 5x7 glyphs for the characters of captions, one row per byte
+// with the leftmost pixel in bit 4
+var captionFont = map[rune][glyphHeight]uint8{
+	'0': {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1': {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2': {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3': {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4': {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5': {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6': {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7': {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9': {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'a': {0x00, 0x00, 0x0e, 0x01, 0x0f, 0x11, 0x0f},
+	'b': {0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1e},
+	'c': {0x00, 0x00, 0x0e, 0x10, 0x10, 0x11, 0x0e},
+	'd': {0x01, 0x01, 0x0d, 0x13, 0x11, 0x11, 0x0f},
+	'e': {0x00, 0x00, 0x0e, 0x11, 0x1f, 0x10, 0x0e},
+	'f': {0x06, 0x09, 0x08, 0x1c, 0x08, 0x08, 0x08},
+	'#': {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	' ': {},
+}
+
+// Caption returns img with a white strip added below it showing text in black.
+// The text is drawn with a built-in pixel font as large as the width of img
+// allows, up to a twentieth of it per glyph. The font covers decimal digits,
+// lowercase hexadecimal digits, '#', '/', '-' and space, other characters are
+// left blank.
+func Caption(img image.Image, text string) *image.Gray {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	runes := []rune(text)
+
+	// Every glyph is followed by one blank column
+	textColumns := len(runes)*(glyphWidth+1) - 1
+	scale := max(1, min(width/20/glyphWidth, width/(textColumns+2*captionPadding+1)))
+	stripHeight := (glyphHeight + 2*captionPadding) * scale
+
+	out := image.NewGray(image.Rect(0, 0, width, bounds.Dy()+stripHeight))
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(out, image.Rect(0, 0, width, bounds.Dy()), img, bounds.Min, draw.Src)
+
+	left := (width - textColumns*scale) / 2
+	top := bounds.Dy() + captionPadding*scale
+
+	for i, r := range runes {
+		glyph := captionFont[r]
+		x0 := left + i*(glyphWidth+1)*scale
+
+		for row, bits := range glyph {
+			for col := range glyphWidth {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+
+				rect := image.Rect(x0+col*scale, top+row*scale, x0+(col+1)*scale, top+(row+1)*scale)
+				draw.Draw(out, rect, &image.Uniform{C: color.Black}, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// darkPixels counts the pixels of img within r darker than mid grey
+func darkPixels(img *image.Gray, r image.Rectangle) int {
+	n := 0
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.GrayAt(x, y).Y < 128 {
+				n++
+			}
+		}
+	}
+
+	return n
+}
+
+func TestCaption(t *testing.T) {
+	q, err := qrcode.New("chunk with a caption", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	img := q.Image(400)
+	captioned := Caption(img, "0003 9f86d0")
+
+	if captioned.Bounds().Dx() != 400 || captioned.Bounds().Dy() <= 400 {
+		t.Fatalf("Unexpected caption size %v", captioned.Bounds())
+	}
+
+	strip := image.Rect(0, 400, 400, captioned.Bounds().Dy())
+	if darkPixels(captioned, strip) == 0 {
+		t.Error("Caption strip shows no text")
+	}
+
+	// A blank caption leaves the strip white
+	if blank := Caption(img, "   "); darkPixels(blank, image.Rect(0, 400, 400, blank.Bounds().Dy())) != 0 {
+		t.Error("Blank caption strip is not white")
+	}
+
+	// The QR code above the strip still decodes
+	if !decodes(captioned) {
+		t.Error("Captioned QR code does not decode")
+	}
+
+	// Long captions shrink down to one pixel per font pixel
+	if long := Caption(img, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"); long.Bounds().Dy() != 400+glyphHeight+2*captionPadding {
+		t.Errorf("Long caption strip is %d pixels high", long.Bounds().Dy()-400)
+	}
+}
@@ -0,0 +1,336 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// Steps of an Enhancement, as named by ParseEnhancement
+const (
+	EnhanceGrayscale   = "grayscale"
+	EnhanceAdaptive    = "adaptive"
+	EnhancePerspective = "perspective"
+	EnhanceSharpen     = "sharpen"
+	EnhanceMultiScale  = "multiscale"
+)
+
+// multiScaleFactors are the scales a frame is retried at by EnhanceMultiScale
+var multiScaleFactors = []float64{0.5, 2, 0.75, 1.5}
+
+// Enhancement is the preprocessing applied to every frame before decoding, to
+// rescue frames captured at an angle, in poor light, or with motion blur. Unlike
+// the variants of SweepVariants, which are only tried on frames that failed to
+// decode, it is applied to every frame. The zero value leaves frames untouched.
+type Enhancement struct {
+	// Grayscale converts the frame to grayscale
+	Grayscale bool
+	// Perspective maps a code filmed at an angle back to a square, see Rectify
+	Perspective bool
+	// Sharpen restores edges softened by defocus or motion blur
+	Sharpen bool
+	// Adaptive binarizes the frame against the mean of each neighbourhood, which
+	// copes with uneven lighting, see AdaptiveThreshold
+	Adaptive bool
+	// MultiScale retries a frame that failed to decode at several scales
+	MultiScale bool
+}
+
+// ParseEnhancement parses a comma-separated list of the steps of an Enhancement,
+// e.g. "adaptive,perspective", "all" for every step, or "" or "none" for none
+func ParseEnhancement(spec string) (Enhancement, error) {
+	var e Enhancement
+
+	for _, step := range strings.Split(strings.ToLower(spec), ",") {
+		switch strings.TrimSpace(step) {
+		case "", "none":
+		case "all":
+			e = Enhancement{Grayscale: true, Perspective: true, Sharpen: true, Adaptive: true, MultiScale: true}
+		case EnhanceGrayscale:
+			e.Grayscale = true
+		case EnhancePerspective:
+			e.Perspective = true
+		case EnhanceSharpen:
+			e.Sharpen = true
+		case EnhanceAdaptive:
+			e.Adaptive = true
+		case EnhanceMultiScale:
+			e.MultiScale = true
+		default:
+			return Enhancement{}, fmt.Errorf("unknown enhancement %q, expected grayscale, adaptive, perspective, sharpen, multiscale, or all", step)
+		}
+	}
+
+	return e, nil
+}
+
+// IsZero reports whether the enhancement leaves frames untouched
+func (e Enhancement) IsZero() bool {
+	return e == Enhancement{}
+}
+
+// String returns the enhancement as ParseEnhancement reads it
+func (e Enhancement) String() string {
+	var steps []string
+
+	for _, step := range []struct {
+		name string
+		on   bool
+	}{
+		{EnhanceGrayscale, e.Grayscale},
+		{EnhancePerspective, e.Perspective},
+		{EnhanceSharpen, e.Sharpen},
+		{EnhanceAdaptive, e.Adaptive},
+		{EnhanceMultiScale, e.MultiScale},
+	} {
+		if step.on {
+			steps = append(steps, step.name)
+		}
+	}
+
+	if len(steps) == 0 {
+		return "none"
+	}
+
+	return strings.Join(steps, ",")
+}
+
+// Apply returns img with the steps of the enhancement applied, in the order
+// grayscale, perspective, sharpen, and adaptive thresholding. Binarizing comes
+// last so that it works on the corrected and sharpened grey levels.
+func (e Enhancement) Apply(img image.Image) image.Image {
+	if e.Grayscale {
+		img = Grayscale(img)
+	}
+
+	if e.Perspective {
+		img = Rectify(img)
+	}
+
+	if e.Sharpen {
+		img = Sharpen(img)
+	}
+
+	if e.Adaptive {
+		img = AdaptiveThreshold(img)
+	}
+
+	return img
+}
+
+// Scales returns the factors a frame that failed to decode is retried at, none
+// without MultiScale
+func (e Enhancement) Scales() []float64 {
+	if !e.MultiScale {
+		return nil
+	}
+
+	return multiScaleFactors
+}
+
+// AdaptiveThreshold binarizes img against the mean grey level of the square window
+// around each pixel, an eighth of the smaller side of the image: a pixel more than
+// 15% darker than its neighbourhood becomes black, the others white. Unlike a
+// global threshold it keeps the modules of a code lit unevenly, such as a screen
+// filmed with a reflection or a vignette.
+func AdaptiveThreshold(img image.Image) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	// integral[y*(w+1)+x] sums the pixels above and left of (x, y)
+	integral := make([]uint64, (w+1)*(h+1))
+	for y := range h {
+		var row uint64
+
+		for x := range w {
+			row += uint64(g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)])
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	radius := max(min(w, h)/16, 7)
+
+	for y := range h {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, h)
+
+		for x := range w {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, w)
+
+			sum := integral[y1*(w+1)+x1] - integral[y0*(w+1)+x1] - integral[y1*(w+1)+x0] + integral[y0*(w+1)+x0]
+			count := uint64((x1 - x0) * (y1 - y0))
+
+			level := uint8(255)
+			if uint64(g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)])*count*100 < sum*85 {
+				level = 0
+			}
+
+			out.Pix[out.PixOffset(x, y)] = level
+		}
+	}
+
+	return out
+}
+
+// point is a position in an image, in pixels
+type point struct {
+	x, y float64
+}
+
+// Rectify finds the quadrilateral holding the dark modules of img, a code filmed at
+// an angle, and maps it back to a square with a white quiet zone around it. The
+// corners are the extreme dark pixels along the diagonals, or along the axes for a
+// code turned by about 45 degrees. An image without a quadrilateral covering a
+// sixty-fourth of it is returned in grayscale without correction.
+func Rectify(img image.Image) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	bin := AdaptiveThreshold(g)
+
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && bin.Pix[bin.PixOffset(x, y)] == 0
+	}
+
+	var (
+		diagonal, axes [4]point
+		found          bool
+	)
+
+	for y := range h {
+		for x := range w {
+			// Skip specks of noise: a module covers more of its neighbourhood
+			if !dark(x, y) {
+				continue
+			}
+
+			neighbours := 0
+
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				if dark(x+d[0], y+d[1]) {
+					neighbours++
+				}
+			}
+
+			if neighbours < 2 {
+				continue
+			}
+
+			fx, fy := float64(x), float64(y)
+			if !found {
+				diagonal = [4]point{{fx, fy}, {fx + 1, fy}, {fx + 1, fy + 1}, {fx, fy + 1}}
+				axes = [4]point{{fx + 0.5, fy}, {fx + 1, fy + 0.5}, {fx + 0.5, fy + 1}, {fx, fy + 0.5}}
+				found = true
+
+				continue
+			}
+
+			// Top-left, top-right, bottom-right, and bottom-left
+			if fx+fy < diagonal[0].x+diagonal[0].y {
+				diagonal[0] = point{fx, fy}
+			}
+
+			if fx-fy+1 > diagonal[1].x-diagonal[1].y {
+				diagonal[1] = point{fx + 1, fy}
+			}
+
+			if fx+fy+2 > diagonal[2].x+diagonal[2].y {
+				diagonal[2] = point{fx + 1, fy + 1}
+			}
+
+			if fx-fy-1 < diagonal[3].x-diagonal[3].y {
+				diagonal[3] = point{fx, fy + 1}
+			}
+
+			// Top, right, bottom, and left
+			if fy < axes[0].y {
+				axes[0] = point{fx + 0.5, fy}
+			}
+
+			if fx+1 > axes[1].x {
+				axes[1] = point{fx + 1, fy + 0.5}
+			}
+
+			if fy+1 > axes[2].y {
+				axes[2] = point{fx + 0.5, fy + 1}
+			}
+
+			if fx < axes[3].x {
+				axes[3] = point{fx, fy + 0.5}
+			}
+		}
+	}
+
+	quad := diagonal
+	if quadArea(axes) > quadArea(diagonal) {
+		quad = axes
+	}
+
+	if !found || quadArea(quad) < float64(w*h)/64 {
+		return g
+	}
+
+	var side float64
+	for i := range quad {
+		next := quad[(i+1)%4]
+		side = max(side, math.Hypot(next.x-quad[i].x, next.y-quad[i].y))
+	}
+
+	margin := math.Ceil(side / 8)
+	size := int(math.Ceil(side + 2*margin))
+	out := image.NewGray(image.Rect(0, 0, size, size))
+	project := squareToQuad(quad)
+
+	for y := range size {
+		v := (float64(y) + 0.5 - margin) / side
+
+		for x := range size {
+			u := (float64(x) + 0.5 - margin) / side
+
+			level := uint8(255)
+			if u >= 0 && u <= 1 && v >= 0 && v <= 1 {
+				p := project(u, v)
+				level = clampUint8(bilinearGray(g, p.x-0.5, p.y-0.5))
+			}
+
+			out.Pix[out.PixOffset(x, y)] = level
+		}
+	}
+
+	return out
+}
+
+// quadArea returns the area of the quadrilateral q with the shoelace formula
+func quadArea(q [4]point) float64 {
+	var area float64
+	for i := range q {
+		next := q[(i+1)%4]
+		area += q[i].x*next.y - next.x*q[i].y
+	}
+
+	return math.Abs(area) / 2
+}
+
+// squareToQuad returns the projective transform mapping the corners (0, 0),
+// (1, 0), (1, 1), and (0, 1) of the unit square to the corners of q, in order
+func squareToQuad(q [4]point) func(u, v float64) point {
+	dx1, dx2, dx3 := q[1].x-q[2].x, q[3].x-q[2].x, q[0].x-q[1].x+q[2].x-q[3].x
+	dy1, dy2, dy3 := q[1].y-q[2].y, q[3].y-q[2].y, q[0].y-q[1].y+q[2].y-q[3].y
+
+	var g, h float64
+	if det := dx1*dy2 - dx2*dy1; det != 0 && (dx3 != 0 || dy3 != 0) {
+		g = (dx3*dy2 - dx2*dy3) / det
+		h = (dx1*dy3 - dx3*dy1) / det
+	}
+
+	a, b, c := q[1].x-q[0].x+g*q[1].x, q[3].x-q[0].x+h*q[3].x, q[0].x
+	d, e, f := q[1].y-q[0].y+g*q[1].y, q[3].y-q[0].y+h*q[3].y, q[0].y
+
+	return func(u, v float64) point {
+		z := g*u + h*v + 1
+
+		return point{(a*u + b*v + c) / z, (d*u + e*v + f) / z}
+	}
+}
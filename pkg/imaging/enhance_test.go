@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestParseEnhancement(t *testing.T) {
+	e, err := ParseEnhancement("Adaptive, perspective")
+	if err != nil {
+		t.Fatalf("ParseEnhancement failed: %v", err)
+	}
+
+	if want := (Enhancement{Adaptive: true, Perspective: true}); e != want {
+		t.Errorf("ParseEnhancement() = %+v, want %+v", e, want)
+	}
+
+	if got := e.String(); got != "perspective,adaptive" {
+		t.Errorf("String() = %q, want perspective,adaptive", got)
+	}
+
+	all, err := ParseEnhancement("all")
+	if err != nil || all.String() != "grayscale,perspective,sharpen,adaptive,multiscale" || len(all.Scales()) == 0 {
+		t.Errorf("ParseEnhancement(all) = %v, %v, want every step", all, err)
+	}
+
+	if none, err := ParseEnhancement(""); err != nil || !none.IsZero() || none.Scales() != nil {
+		t.Errorf("ParseEnhancement(\"\") = %v, %v, want no step", none, err)
+	}
+
+	if _, err := ParseEnhancement("adaptive,blur"); err == nil {
+		t.Error("ParseEnhancement accepted an unknown step")
+	}
+}
+
+func TestAdaptiveThresholdUnevenLighting(t *testing.T) {
+	q, err := qrcode.New("chunk filmed next to a window", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	code := Grayscale(q.Image(300))
+
+	// Light falls off from 255 on the left to 60 on the right, and dark modules
+	// reflect 40% of it, so a dark module on the left is brighter than a light one
+	// on the right
+	frame := image.NewGray(code.Bounds())
+	for y := range 300 {
+		for x := range 300 {
+			light := 255 - 195*float64(x)/299
+			if code.Pix[code.PixOffset(x, y)] < 128 {
+				light *= 0.4
+			}
+
+			frame.Pix[frame.PixOffset(x, y)] = clampUint8(light)
+		}
+	}
+
+	binarized := AdaptiveThreshold(frame)
+
+	wrong := 0
+
+	for i, v := range code.Pix {
+		if (v < 128) != (binarized.Pix[i] == 0) {
+			wrong++
+		}
+	}
+
+	if wrong > len(code.Pix)/100 {
+		t.Errorf("%d of %d pixels binarized wrongly", wrong, len(code.Pix))
+	}
+
+	if !decodes(binarized) {
+		t.Error("Expected the binarized frame to decode")
+	}
+}
+
+func TestRectifyFilmedAtAngle(t *testing.T) {
+	q, err := qrcode.New("chunk filmed at an angle, with a payload long enough for a larger version", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	code := Grayscale(q.Image(240))
+
+	// Draw the code onto a trapezoid, as seen by a camera looking at the screen from
+	// its left, with its far edge shrunk to half the height
+	frame := image.NewGray(image.Rect(0, 0, 480, 400))
+	for i := range frame.Pix {
+		frame.Pix[i] = 255
+	}
+
+	project := squareToQuad([4]point{{40, 40}, {440, 120}, {440, 280}, {40, 360}})
+
+	const steps = 1600
+	for j := range steps {
+		for i := range steps {
+			u, v := (float64(i)+0.5)/steps, (float64(j)+0.5)/steps
+
+			p := project(u, v)
+			frame.Pix[frame.PixOffset(int(p.x), int(p.y))] = code.Pix[code.PixOffset(int(u*240), int(v*240))]
+		}
+	}
+
+	rectified := Rectify(frame)
+
+	if !decodes(rectified) {
+		t.Error("Expected the rectified frame to decode")
+	}
+
+	// The code takes up most of the square, within its quiet zone
+	if size := rectified.Bounds().Dx(); size != rectified.Bounds().Dy() || math.Abs(float64(size)-400) > 100 {
+		t.Errorf("Rectified frame is %v, want a square of about 400 pixels", rectified.Bounds())
+	}
+
+	// A blank frame has nothing to correct
+	blank := image.NewGray(image.Rect(0, 0, 64, 48))
+	if got := Rectify(blank).Bounds(); got != blank.Bounds() {
+		t.Errorf("Rectify(blank) is %v, want %v", got, blank.Bounds())
+	}
+}
@@ -0,0 +1,187 @@
+// Package imaging provides corrections applied to camera frames before QR code
+// decoding, and captions drawn below generated QR codes.
+package imaging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"os"
+)
+
+// LensProfile is a simple camera calibration profile.
+//
+// Radial distortion follows the Brown model with two coefficients: a point at
+// normalized distance r from the distortion center is imaged at distance
+// r * (1 + K1*r^2 + K2*r^4). Distances are normalized by half the image diagonal.
+// Wide-angle phone lenses show barrel distortion, which has a negative K1.
+//
+// A profile is stored as JSON, e.g.
+//
+//	{"k1": -0.22, "k2": 0.04, "crop": {"left": 0.1, "right": 0.1}}
+type LensProfile struct {
+	// K1 and K2 are the radial distortion coefficients
+	K1 float64 `json:"k1"`
+	K2 float64 `json:"k2"`
+	// CenterX and CenterY locate the distortion center as a fraction of the
+	// image size. Zero values mean the image center.
+	CenterX float64 `json:"center_x,omitempty"`
+	CenterY float64 `json:"center_y,omitempty"`
+	// Crop is removed from the corrected frame
+	Crop Crop `json:"crop"`
+}
+
+// Crop holds the fraction of the image removed from every edge
+type Crop struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+}
+
+// LoadLensProfile reads a lens profile from a JSON file
+func LoadLensProfile(path string) (*LensProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lens profile: %w", err)
+	}
+
+	var p LensProfile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse lens profile: %w", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid lens profile %s: %w", path, err)
+	}
+
+	return &p, nil
+}
+
+// Validate checks that the profile describes a usable correction
+func (p *LensProfile) Validate() error {
+	for _, v := range []float64{p.Crop.Left, p.Crop.Top, p.Crop.Right, p.Crop.Bottom} {
+		if v < 0 || v >= 1 {
+			return errors.New("crop fractions must be in [0, 1)")
+		}
+	}
+
+	if p.Crop.Left+p.Crop.Right >= 1 || p.Crop.Top+p.Crop.Bottom >= 1 {
+		return errors.New("crop removes the whole frame")
+	}
+
+	if p.CenterX < 0 || p.CenterX > 1 || p.CenterY < 0 || p.CenterY > 1 {
+		return errors.New("distortion center must lie within the frame")
+	}
+
+	return nil
+}
+
+// Apply returns img with the lens distortion removed and the crop applied
+func (p *LensProfile) Apply(img image.Image) image.Image {
+	src := toRGBA(img)
+
+	out := src
+	if p.K1 != 0 || p.K2 != 0 {
+		out = p.undistort(src)
+	}
+
+	return p.crop(out)
+}
+
+// undistort maps every pixel of the corrected image to its distorted position in
+// src and samples it with bilinear interpolation
+func (p *LensProfile) undistort(src *image.RGBA) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	cx, cy := float64(w)/2, float64(h)/2
+	if p.CenterX != 0 || p.CenterY != 0 {
+		cx, cy = p.CenterX*float64(w), p.CenterY*float64(h)
+	}
+
+	norm := math.Hypot(float64(w), float64(h)) / 2
+
+	for y := range h {
+		for x := range w {
+			dx := (float64(x) + 0.5 - cx) / norm
+			dy := (float64(y) + 0.5 - cy) / norm
+			r2 := dx*dx + dy*dy
+			scale := 1 + p.K1*r2 + p.K2*r2*r2
+
+			sx := cx + dx*scale*norm - 0.5
+			sy := cy + dy*scale*norm - 0.5
+
+			i := out.PixOffset(x, y)
+			bilinear(src, sx, sy, out.Pix[i:i+4])
+		}
+	}
+
+	return out
+}
+
+// crop returns the part of img left by the crop fractions
+func (p *LensProfile) crop(img *image.RGBA) image.Image {
+	if p.Crop == (Crop{}) {
+		return img
+	}
+
+	b := img.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(p.Crop.Left*float64(b.Dx())),
+		b.Min.Y+int(p.Crop.Top*float64(b.Dy())),
+		b.Max.X-int(p.Crop.Right*float64(b.Dx())),
+		b.Max.Y-int(p.Crop.Bottom*float64(b.Dy())),
+	)
+
+	return img.SubImage(rect)
+}
+
+// bilinear samples src at the fractional position x, y into dst. Positions outside
+// src are white, like the quiet zone around a QR code.
+func bilinear(src *image.RGBA, x, y float64, dst []uint8) {
+	b := src.Bounds()
+
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	for c := range 4 {
+		var v float64
+
+		for _, s := range [4]struct {
+			x, y int
+			w    float64
+		}{
+			{x0, y0, (1 - fx) * (1 - fy)},
+			{x0 + 1, y0, fx * (1 - fy)},
+			{x0, y0 + 1, (1 - fx) * fy},
+			{x0 + 1, y0 + 1, fx * fy},
+		} {
+			px := uint8(255)
+			if image.Pt(s.x+b.Min.X, s.y+b.Min.Y).In(b) {
+				px = src.Pix[src.PixOffset(s.x+b.Min.X, s.y+b.Min.Y)+c]
+			}
+
+			v += s.w * float64(px)
+		}
+
+		dst[c] = uint8(math.Round(v))
+	}
+}
+
+// toRGBA returns img as an *image.RGBA, converting it if needed
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+
+	return rgba
+}
@@ -0,0 +1,129 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkerboard returns a grayscale checkerboard with square cells
+func checkerboard(size, cell int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+
+	for y := range size {
+		for x := range size {
+			c := color.RGBA{255, 255, 255, 255}
+			if (x/cell+y/cell)%2 == 0 {
+				c = color.RGBA{0, 0, 0, 255}
+			}
+
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	return img
+}
+
+// distort applies the radial distortion of p to img, as a lens would
+func distort(img *image.RGBA, p *LensProfile) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(b)
+
+	cx, cy := float64(w)/2, float64(h)/2
+	norm := math.Hypot(float64(w), float64(h)) / 2
+
+	for y := range h {
+		for x := range w {
+			dx := (float64(x) + 0.5 - cx) / norm
+			dy := (float64(y) + 0.5 - cy) / norm
+			rd := math.Hypot(dx, dy)
+
+			// Invert r_d = r_u * (1 + K1*r_u^2 + K2*r_u^4) by fixed-point iteration
+			ru := rd
+			for range 20 {
+				ru = rd / (1 + p.K1*ru*ru + p.K2*ru*ru*ru*ru)
+			}
+
+			scale := 1.0
+			if rd > 0 {
+				scale = ru / rd
+			}
+
+			i := out.PixOffset(x, y)
+			bilinear(img, cx+dx*scale*norm-0.5, cy+dy*scale*norm-0.5, out.Pix[i:i+4])
+		}
+	}
+
+	return out
+}
+
+// meanDifference returns the mean absolute difference of the red channels of two images
+func meanDifference(a, b image.Image) float64 {
+	var sum float64
+
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ra, _, _, _ := a.At(x, y).RGBA()
+			rb, _, _, _ := b.At(x, y).RGBA()
+			sum += math.Abs(float64(ra>>8) - float64(rb>>8))
+		}
+	}
+
+	return sum / float64(bounds.Dx()*bounds.Dy())
+}
+
+func TestLensProfileUndistort(t *testing.T) {
+	profile := &LensProfile{K1: -0.2, K2: 0.03}
+
+	original := checkerboard(200, 20)
+	distorted := distort(original, profile)
+	corrected := profile.Apply(distorted)
+
+	before := meanDifference(original, distorted)
+	after := meanDifference(original, corrected)
+
+	if after >= before/3 {
+		t.Errorf("Expected the correction to remove most of the distortion, difference %.1f before and %.1f after", before, after)
+	}
+}
+
+func TestLensProfileCrop(t *testing.T) {
+	profile := &LensProfile{Crop: Crop{Left: 0.1, Top: 0.2, Right: 0.3, Bottom: 0.1}}
+
+	got := profile.Apply(checkerboard(100, 10)).Bounds()
+	if got != image.Rect(10, 20, 70, 90) {
+		t.Errorf("Unexpected crop bounds %v", got)
+	}
+}
+
+func TestLoadLensProfile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "phone.json")
+	if err := os.WriteFile(path, []byte(`{"k1": -0.22, "k2": 0.04, "crop": {"left": 0.1, "right": 0.1}}`), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	profile, err := LoadLensProfile(path)
+	if err != nil {
+		t.Fatalf("LoadLensProfile failed: %v", err)
+	}
+
+	if profile.K1 != -0.22 || profile.K2 != 0.04 || profile.Crop.Left != 0.1 {
+		t.Errorf("Unexpected profile: %+v", profile)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"crop": {"left": 0.6, "right": 0.5}}`), 0600); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+
+	if _, err := LoadLensProfile(invalid); err == nil {
+		t.Error("Expected a crop removing the whole frame to be rejected")
+	}
+}
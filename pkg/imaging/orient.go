@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+)
+
+// OrientationVariants returns the rotations by 90, 180, and 270 degrees of a frame,
+// then its horizontal mirror image and the rotations of it. Phone recordings are
+// often stored sideways with a rotation in their metadata that is lost on the way,
+// and front cameras mirror the image, while a QR code only reads the right way
+// round and, as a pure barcode, upright.
+func OrientationVariants() []Variant {
+	var variants []Variant
+
+	for _, mirror := range []bool{false, true} {
+		for turns := range 4 {
+			if !mirror && turns == 0 {
+				continue
+			}
+
+			name := fmt.Sprintf("rotate %d", turns*90)
+			if mirror {
+				name = "mirror"
+				if turns > 0 {
+					name += fmt.Sprintf(", rotate %d", turns*90)
+				}
+			}
+
+			variants = append(variants, Variant{Name: name, Apply: func(img image.Image) image.Image {
+				if mirror {
+					img = Mirror(img)
+				}
+
+				return Rotate(img, turns)
+			}})
+		}
+	}
+
+	return variants
+}
+
+// Rotate returns img in grayscale, turned clockwise by the given number of quarter
+// turns
+func Rotate(img image.Image, turns int) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	turns = (turns%4 + 4) % 4
+	if turns == 0 {
+		return g
+	}
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	if turns != 2 {
+		out = image.NewGray(image.Rect(0, 0, h, w))
+	}
+
+	for y := range h {
+		for x := range w {
+			var ox, oy int
+
+			switch turns {
+			case 1:
+				ox, oy = h-1-y, x
+			case 2:
+				ox, oy = w-1-x, h-1-y
+			case 3:
+				ox, oy = y, w-1-x
+			}
+
+			out.Pix[out.PixOffset(ox, oy)] = g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)]
+		}
+	}
+
+	return out
+}
+
+// Mirror returns img in grayscale, flipped horizontally
+func Mirror(img image.Image) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	for y := range h {
+		for x := range w {
+			out.Pix[out.PixOffset(w-1-x, y)] = g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)]
+		}
+	}
+
+	return out
+}
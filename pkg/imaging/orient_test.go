@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestRotateAndMirror(t *testing.T) {
+	// 1 2 3
+	// 4 5 6
+	img := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(img.Pix, []uint8{1, 2, 3, 4, 5, 6})
+
+	for _, tc := range []struct {
+		name string
+		got  *image.Gray
+		want []uint8
+		w    int
+	}{
+		{"rotate 90", Rotate(img, 1), []uint8{4, 1, 5, 2, 6, 3}, 2},
+		{"rotate 180", Rotate(img, 2), []uint8{6, 5, 4, 3, 2, 1}, 3},
+		{"rotate 270", Rotate(img, -1), []uint8{3, 6, 2, 5, 1, 4}, 2},
+		{"mirror", Mirror(img), []uint8{3, 2, 1, 6, 5, 4}, 3},
+	} {
+		if tc.got.Bounds().Dx() != tc.w || !bytes.Equal(tc.got.Pix, tc.want) {
+			t.Errorf("%s = %v (%v), want %v", tc.name, tc.got.Pix, tc.got.Bounds(), tc.want)
+		}
+	}
+}
+
+func TestOrientationVariantsUndoEveryOrientation(t *testing.T) {
+	q, err := qrcode.New("chunk recorded sideways", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	code := Grayscale(q.Image(100))
+	variants := OrientationVariants()
+
+	if len(variants) != 7 {
+		t.Fatalf("Got %d variants, want 7", len(variants))
+	}
+
+	for _, mirror := range []bool{false, true} {
+		for turns := range 4 {
+			if !mirror && turns == 0 {
+				continue
+			}
+
+			var frame image.Image = code
+			if mirror {
+				frame = Mirror(frame)
+			}
+
+			frame = Rotate(frame, turns)
+
+			undone := false
+
+			for _, variant := range variants {
+				if bytes.Equal(Grayscale(variant.Apply(frame)).Pix, code.Pix) {
+					undone = true
+				}
+			}
+
+			if !undone {
+				t.Errorf("No variant undoes mirror %v and %d quarter turns", mirror, turns)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// Variant is a preprocessing step tried on a frame that failed to decode
+type Variant struct {
+	// Name describes the variant, e.g. "threshold 128"
+	Name string
+	// Apply returns the preprocessed frame
+	Apply func(image.Image) image.Image
+}
+
+// SweepVariants returns the preprocessing variants tried, in order, on a frame
+// that failed to decode. Cheap variants that fix the most common problems of
+// borderline captures come first: low contrast, uneven exposure, blur, and codes
+// whose modules are too small or too large for the detector.
+func SweepVariants() []Variant {
+	variants := []Variant{
+		{Name: "contrast stretch", Apply: func(img image.Image) image.Image { return StretchContrast(img) }},
+	}
+
+	for _, level := range []uint8{96, 128, 160} {
+		variants = append(variants, Variant{
+			Name:  "threshold " + strconv.Itoa(int(level)),
+			Apply: func(img image.Image) image.Image { return Threshold(StretchContrast(img), level) },
+		})
+	}
+
+	return append(variants,
+		Variant{Name: "sharpen", Apply: func(img image.Image) image.Image { return Sharpen(StretchContrast(img)) }},
+		Variant{Name: "scale 0.5", Apply: func(img image.Image) image.Image { return Scale(img, 0.5) }},
+		Variant{Name: "scale 2", Apply: func(img image.Image) image.Image { return Scale(img, 2) }},
+		Variant{Name: "sharpen + threshold 128", Apply: func(img image.Image) image.Image {
+			return Threshold(Sharpen(StretchContrast(img)), 128)
+		}},
+	)
+}
+
+// Grayscale returns img converted to grayscale
+func Grayscale(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok {
+		return g
+	}
+
+	b := img.Bounds()
+	g := image.NewGray(b)
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g.Set(x, y, color.GrayModel.Convert(img.At(x, y)))
+		}
+	}
+
+	return g
+}
+
+// StretchContrast maps the darkest and brightest grey levels of img, ignoring the
+// extreme 1% of pixels, to black and white
+func StretchContrast(img image.Image) *image.Gray {
+	g := Grayscale(img)
+
+	var histogram [256]int
+	for _, v := range g.Pix {
+		histogram[v]++
+	}
+
+	clip := len(g.Pix) / 100
+	low, high := 0, 255
+
+	for count := 0; low < 255 && count+histogram[low] <= clip; low++ {
+		count += histogram[low]
+	}
+
+	for count := 0; high > 0 && count+histogram[high] <= clip; high-- {
+		count += histogram[high]
+	}
+
+	out := image.NewGray(g.Bounds())
+	if high <= low {
+		copy(out.Pix, g.Pix)
+
+		return out
+	}
+
+	var table [256]uint8
+	for v := range table {
+		table[v] = clampUint8(float64(v-low) * 255 / float64(high-low))
+	}
+
+	for i, v := range g.Pix {
+		out.Pix[i] = table[v]
+	}
+
+	return out
+}
+
+// Threshold returns img binarized at level: darker pixels become black, the others white
+func Threshold(img image.Image, level uint8) *image.Gray {
+	g := Grayscale(img)
+	out := image.NewGray(g.Bounds())
+
+	for i, v := range g.Pix {
+		if v >= level {
+			out.Pix[i] = 255
+		}
+	}
+
+	return out
+}
+
+// Sharpen returns img with an unsharp mask applied, which restores edges softened
+// by slight defocus or motion blur
+func Sharpen(img image.Image) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+	out := image.NewGray(b)
+
+	at := func(x, y int) float64 {
+		x = min(max(x, b.Min.X), b.Max.X-1)
+		y = min(max(y, b.Min.Y), b.Max.Y-1)
+
+		return float64(g.GrayAt(x, y).Y)
+	}
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			center := at(x, y)
+			blur := (at(x-1, y) + at(x+1, y) + at(x, y-1) + at(x, y+1) + center) / 5
+			out.SetGray(x, y, color.Gray{Y: clampUint8(center + 1.5*(center-blur))})
+		}
+	}
+
+	return out
+}
+
+// Scale returns img resized by factor with bilinear interpolation
+func Scale(img image.Image, factor float64) *image.Gray {
+	g := Grayscale(img)
+	b := g.Bounds()
+
+	w := max(int(float64(b.Dx())*factor), 1)
+	h := max(int(float64(b.Dy())*factor), 1)
+	out := image.NewGray(image.Rect(0, 0, w, h))
+
+	for y := range h {
+		sy := (float64(y)+0.5)/factor - 0.5
+
+		for x := range w {
+			sx := (float64(x)+0.5)/factor - 0.5
+			out.Pix[out.PixOffset(x, y)] = clampUint8(bilinearGray(g, sx, sy))
+		}
+	}
+
+	return out
+}
+
+// bilinearGray returns the grey level of g at (x, y), relative to its bounds,
+// interpolated between the four nearest pixels. Points outside g take the level of
+// the nearest edge pixel.
+func bilinearGray(g *image.Gray, x, y float64) float64 {
+	b := g.Bounds()
+
+	at := func(x, y int) float64 {
+		x = min(max(x, 0), b.Dx()-1)
+		y = min(max(y, 0), b.Dy()-1)
+
+		return float64(g.Pix[g.PixOffset(b.Min.X+x, b.Min.Y+y)])
+	}
+
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+
+	return at(x0, y0)*(1-fx)*(1-fy) + at(x0+1, y0)*fx*(1-fy) +
+		at(x0, y0+1)*(1-fx)*fy + at(x0+1, y0+1)*fx*fy
+}
+
+// clampUint8 rounds v to the nearest grey level
+func clampUint8(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 255)))
+}
@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/makiuchi-d/gozxing"
+	zxqrcode "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// decodes reports whether gozxing reads a QR code from img
+func decodes(img image.Image) bool {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return false
+	}
+
+	_, err = zxqrcode.NewQRCodeReader().Decode(bmp, nil)
+
+	return err == nil
+}
+
+func TestSweepVariantsRecoverWashedOutFrame(t *testing.T) {
+	q, err := qrcode.New("borderline capture of a chunk payload", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	// Squeeze the grey levels into 120-135, like an overexposed capture of a screen
+	frame := Grayscale(q.Image(300))
+	for i, v := range frame.Pix {
+		frame.Pix[i] = 120 + v/16
+	}
+
+	if decodes(frame) {
+		t.Skip("The washed-out frame decodes without preprocessing")
+	}
+
+	recovered := 0
+
+	for _, variant := range SweepVariants() {
+		if decodes(variant.Apply(frame)) {
+			recovered++
+		}
+	}
+
+	if recovered == 0 {
+		t.Error("Expected at least one variant to recover the washed-out frame")
+	}
+
+	if !decodes(SweepVariants()[0].Apply(frame)) {
+		t.Error("Expected the first variant, a contrast stretch, to recover the frame")
+	}
+}
+
+func TestThresholdAndScale(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	copy(img.Pix, []uint8{0, 100, 150, 255, 10, 127, 128, 200})
+
+	got := Threshold(img, 128).Pix
+	want := []uint8{0, 0, 255, 255, 0, 0, 255, 255}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Unexpected threshold result %v, want %v", got, want)
+		}
+	}
+
+	if b := Scale(img, 2).Bounds(); b != image.Rect(0, 0, 8, 4) {
+		t.Errorf("Unexpected scaled bounds %v", b)
+	}
+}
@@ -0,0 +1,33 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Tile lays out images in a grid of columns by rows cells on a white background,
+// filling the rows from left to right and top to bottom, e.g. to show several QR
+// codes in one video frame. Every cell is as large as the largest image, smaller
+// images are centered in their cell, and cells without an image are left blank.
+// Images beyond columns*rows are ignored.
+func Tile(images []image.Image, columns, rows int) *image.Gray {
+	var cellWidth, cellHeight int
+
+	for _, img := range images {
+		cellWidth = max(cellWidth, img.Bounds().Dx())
+		cellHeight = max(cellHeight, img.Bounds().Dy())
+	}
+
+	out := image.NewGray(image.Rect(0, 0, columns*cellWidth, rows*cellHeight))
+	draw.Draw(out, out.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for i, img := range images[:min(len(images), columns*rows)] {
+		bounds := img.Bounds()
+		x := (i%columns)*cellWidth + (cellWidth-bounds.Dx())/2
+		y := (i/columns)*cellHeight + (cellHeight-bounds.Dy())/2
+
+		draw.Draw(out, image.Rect(x, y, x+bounds.Dx(), y+bounds.Dy()), img, bounds.Min, draw.Src)
+	}
+
+	return out
+}
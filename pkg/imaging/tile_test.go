@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestTile(t *testing.T) {
+	var images []image.Image
+
+	for i := range 3 {
+		q, err := qrcode.New(fmt.Sprintf("tiled chunk %d", i), qrcode.Medium)
+		if err != nil {
+			t.Fatalf("Failed to create QR code: %v", err)
+		}
+
+		images = append(images, q.Image(200))
+	}
+
+	// A smaller code is centered in its cell
+	small, err := qrcode.New("small", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	images[2] = small.Image(120)
+
+	tiled := Tile(images, 2, 2)
+	if got := tiled.Bounds(); got.Dx() != 400 || got.Dy() != 400 {
+		t.Fatalf("Tiled frame is %v, want 400x400", got)
+	}
+
+	for i := range 3 {
+		cell := image.Rect((i%2)*200, (i/2)*200, (i%2+1)*200, (i/2+1)*200)
+		if !decodes(tiled.SubImage(cell)) {
+			t.Errorf("Cell %d does not decode", i)
+		}
+	}
+
+	// The fourth cell is blank
+	for _, v := range tiled.SubImage(image.Rect(200, 200, 400, 400)).(*image.Gray).Pix[:200] {
+		if v != 0xff {
+			t.Fatal("Empty cell is not blank")
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package monitor renders a live view of a capture for the terminal: a grid of the
+// chunk indices, lit as they are received, with the overall percentage, the
+// estimated time remaining, and the number of duplicate QR codes. Long captures
+// otherwise only show a line of counts that says little about what is missing.
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// ANSI styles of the grid cells
+const (
+	styleReceived   = "\x1b[30;42m"
+	styleRestorable = "\x1b[30;43m"
+	styleMissing    = "\x1b[2m"
+	styleReset      = "\x1b[0m"
+)
+
+// plainMarks stand for the styles of the grid cells without color
+var plainMarks = map[string]string{styleReceived: "#", styleRestorable: "+", styleMissing: "."}
+
+// maxGridRows is the number of rows from which the grid shows one character per
+// chunk instead of its index
+const maxGridRows = 16
+
+// Monitor tracks the progress of a capture, see Render
+type Monitor struct {
+	start   time.Time
+	reports map[string]*qrfiletransfer.ChunkReport
+	// received counts the chunks received since start
+	received int
+	frames   int
+	dupes    int
+	now      func() time.Time
+}
+
+// New returns a Monitor of a capture starting now
+func New() *Monitor {
+	m := &Monitor{reports: make(map[string]*qrfiletransfer.ChunkReport), now: time.Now}
+	m.start = m.now()
+
+	return m
+}
+
+// Frame counts a captured frame
+func (m *Monitor) Frame() {
+	m.frames++
+}
+
+// Duplicate counts a QR code of a chunk already received
+func (m *Monitor) Duplicate() {
+	m.dupes++
+}
+
+// Update sets the chunks of file, empty for a single file, to report. Chunks
+// present before the first report of a file, decoded by a previous run, do not
+// count towards the rate behind the estimated time remaining.
+func (m *Monitor) Update(file string, report *qrfiletransfer.ChunkReport) {
+	if previous, ok := m.reports[file]; ok && len(report.Present) > len(previous.Present) {
+		m.received += len(report.Present) - len(previous.Present)
+	}
+
+	m.reports[file] = report
+}
+
+// Progress returns the number of chunks available or restorable and the total of
+// every file, unknown while a file does not know its number of chunks
+func (m *Monitor) Progress() (done, total int, known bool) {
+	known = len(m.reports) > 0
+
+	for _, report := range m.reports {
+		done += len(report.Present) + len(report.Restorable)
+		total += report.Total
+
+		if report.Total == 0 {
+			known = false
+		}
+	}
+
+	return done, total, known
+}
+
+// Remaining estimates the time left to receive the missing chunks from the rate
+// they have been received at since the start, false if it cannot tell yet
+func (m *Monitor) Remaining() (time.Duration, bool) {
+	done, total, known := m.Progress()
+	if !known || m.received == 0 {
+		return 0, false
+	}
+
+	perChunk := m.now().Sub(m.start) / time.Duration(m.received)
+
+	return perChunk * time.Duration(total-done), true
+}
+
+// Render returns the view of the capture for a terminal width columns wide, with
+// ANSI colors if color is set
+func (m *Monitor) Render(width int, color bool) string {
+	var b strings.Builder
+
+	done, total, known := m.Progress()
+
+	if known {
+		percent := 100 * done / total
+		barWidth := max(min(width-40, 40), 10)
+		filled := barWidth * done / total
+
+		fmt.Fprintf(&b, "Chunks: %d of %d (%d%%) [%s%s]", done, total, percent, strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled))
+	} else {
+		fmt.Fprintf(&b, "Chunks: %d of unknown", done)
+	}
+
+	switch remaining, ok := m.Remaining(); {
+	case known && done >= total:
+		b.WriteString("  complete")
+	case ok:
+		fmt.Fprintf(&b, "  ETA %s", remaining.Round(time.Second))
+	default:
+		b.WriteString("  ETA unknown")
+	}
+
+	fmt.Fprintf(&b, "\nFrames: %d  Duplicate QR codes: %d  Elapsed: %s\n", m.frames, m.dupes, m.now().Sub(m.start).Round(time.Second))
+
+	files := make([]string, 0, len(m.reports))
+	for file := range m.reports {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		if file != "" {
+			fmt.Fprintf(&b, "\nFile %s:", file)
+		}
+
+		b.WriteString("\n")
+		b.WriteString(renderGrid(m.reports[file], width, color))
+	}
+
+	return b.String()
+}
+
+// renderGrid draws a cell for every chunk of report, its index if the grid fits in
+// maxGridRows and a single character otherwise. Without color, received chunks
+// show their index or #, restorable ones +, and missing ones dots.
+func renderGrid(report *qrfiletransfer.ChunkReport, width int, color bool) string {
+	states := make(map[int]string)
+
+	count := report.Total
+	for _, index := range report.Present {
+		states[index] = styleReceived
+		count = max(count, index+1)
+	}
+
+	for _, index := range report.Restorable {
+		states[index] = styleRestorable
+	}
+
+	digits := len(fmt.Sprint(max(count-1, 0)))
+	perRow := max(width/(digits+1), 1)
+	compact := (count+perRow-1)/perRow > maxGridRows
+
+	if compact {
+		perRow = max(width, 1)
+	}
+
+	var b strings.Builder
+
+	for index := range count {
+		switch {
+		case index > 0 && index%perRow == 0:
+			b.WriteString("\n")
+		case index > 0 && !compact:
+			b.WriteString(" ")
+		}
+
+		style, ok := states[index]
+		if !ok {
+			style = styleMissing
+		}
+
+		cell := fmt.Sprintf("%*d", digits, index)
+
+		switch {
+		case color && compact:
+			cell = style + " " + styleReset
+		case color:
+			cell = style + cell + styleReset
+		case compact:
+			cell = plainMarks[style]
+		case style != styleReceived:
+			cell = strings.Repeat(plainMarks[style], digits)
+		}
+
+		b.WriteString(cell)
+	}
+
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrfiletransfer"
+)
+
+// testMonitor returns a Monitor whose clock is advanced by the returned function
+func testMonitor() (*Monitor, func(time.Duration)) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	m := &Monitor{reports: make(map[string]*qrfiletransfer.ChunkReport), now: func() time.Time { return now }}
+	m.start = now
+
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMonitorProgressAndRemaining(t *testing.T) {
+	m, advance := testMonitor()
+
+	// A chunk decoded by a previous run does not count towards the rate
+	m.Update("", &qrfiletransfer.ChunkReport{Present: []int{0}, Total: 10})
+
+	if _, ok := m.Remaining(); ok {
+		t.Error("Expected no estimate before a chunk was received")
+	}
+
+	advance(4 * time.Second)
+	m.Update("", &qrfiletransfer.ChunkReport{Present: []int{0, 1, 2}, Total: 10})
+
+	if done, total, known := m.Progress(); done != 3 || total != 10 || !known {
+		t.Errorf("Progress() = %d, %d, %v, want 3 of 10", done, total, known)
+	}
+
+	// 2 chunks in 4s, 7 to go
+	if remaining, ok := m.Remaining(); !ok || remaining != 14*time.Second {
+		t.Errorf("Remaining() = %v, %v, want 14s", remaining, ok)
+	}
+
+	m.Update("f2", &qrfiletransfer.ChunkReport{Present: []int{0}})
+
+	if _, _, known := m.Progress(); known {
+		t.Error("Expected an unknown total while a file does not know its chunks")
+	}
+}
+
+func TestMonitorRender(t *testing.T) {
+	m, advance := testMonitor()
+
+	m.Update("", &qrfiletransfer.ChunkReport{})
+	advance(2 * time.Second)
+	m.Update("", &qrfiletransfer.ChunkReport{Present: []int{0, 1, 3}, Restorable: []int{2}, Missing: []int{2, 4}, Total: 12})
+	m.Frame()
+	m.Duplicate()
+
+	got := m.Render(20, false)
+	want := "Chunks: 4 of 12 (33%) [###-------]  ETA 5s\n" +
+		"Frames: 1  Duplicate QR codes: 1  Elapsed: 2s\n" +
+		"\n" +
+		" 0  1 ++  3 .. ..\n" +
+		".. .. .. .. .. ..\n"
+
+	if got != want {
+		t.Errorf("Render() =\n%q\nwant\n%q", got, want)
+	}
+
+	if colored := m.Render(20, true); !strings.Contains(colored, styleReceived+" 0"+styleReset) {
+		t.Errorf("Expected the received chunks in color, got %q", colored)
+	}
+
+	// A grid of more than maxGridRows rows shows a character per chunk
+	m.Update("", &qrfiletransfer.ChunkReport{Present: []int{1}, Total: 400})
+
+	if rows := strings.Split(strings.TrimSpace(m.Render(40, false)), "\n")[3:]; len(rows) != 10 || rows[0] != ".#"+strings.Repeat(".", 38) {
+		t.Errorf("Got compact grid %q", rows)
+	}
+}
@@ -0,0 +1,218 @@
+// Package pdf writes simple vector PDF documents: pages of filled rectangles,
+// lines, and text in the standard Helvetica font, which is enough to lay out
+// printable sheets of QR codes without external tools.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// A4Width and A4Height are the size of an A4 page in points
+	A4Width  = 595.28
+	A4Height = 841.89
+
+	// LetterWidth and LetterHeight are the size of a US Letter page in points
+	LetterWidth  = 612
+	LetterHeight = 792
+
+	// PointsPerMM is the number of points in a millimeter
+	PointsPerMM = 72 / 25.4
+)
+
+// Document is a PDF document whose pages all have the same size.
+// Coordinates are in points with the origin at the bottom left corner of a page.
+type Document struct {
+	width  float64
+	height float64
+	pages  []*Page
+}
+
+// Page is a page of a Document
+type Page struct {
+	content bytes.Buffer
+}
+
+// New creates an empty document with pages of the given size in points
+func New(width, height float64) *Document {
+	return &Document{width: width, height: height}
+}
+
+// Size returns the page size of the document in points
+func (d *Document) Size() (float64, float64) {
+	return d.width, d.height
+}
+
+// AddPage appends an empty page to the document
+func (d *Document) AddPage() *Page {
+	p := &Page{}
+	d.pages = append(d.pages, p)
+
+	return p
+}
+
+// PageCount returns the number of pages of the document
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// FillRect draws a black rectangle with its bottom left corner at (x, y)
+func (p *Page) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%s %s %s %s re f\n", num(x), num(y), num(w), num(h))
+}
+
+// FillGrid draws black rectangles as a single path. The rectangles are given as
+// x, y, width and height in units of cell points on a grid whose origin is at
+// (x, y), which keeps the page content small for shapes like QR codes.
+func (p *Page) FillGrid(x, y, cell float64, rects [][4]int) {
+	if len(rects) == 0 {
+		return
+	}
+
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm\n", num(cell), num(cell), num(x), num(y))
+
+	for _, r := range rects {
+		fmt.Fprintf(&p.content, "%d %d %d %d re\n", r[0], r[1], r[2], r[3])
+	}
+
+	p.content.WriteString("f Q\n")
+}
+
+// Line draws a black line of the given width from (x1, y1) to (x2, y2)
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(y1), num(x2), num(y2))
+}
+
+// Text draws text in Helvetica of the given size with the start of its baseline at
+// (x, y). Characters outside printable ASCII are drawn as '?'.
+func (p *Page) Text(x, y, size float64, text string) {
+	fmt.Fprintf(&p.content, "BT /F1 %s Tf %s %s Td (%s) Tj ET\n", num(size), num(x), num(y), escape(text))
+}
+
+// TextWidth returns the approximate width in points of text drawn at size, using
+// the average width of Helvetica glyphs
+func TextWidth(text string, size float64) float64 {
+	return float64(utf8.RuneCountInString(text)) * size * 0.55
+}
+
+// WriteTo writes the document to w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	// Objects 1 to 3 are the catalog, the page tree and the font, followed by a
+	// page object and a compressed content stream for every page
+	var offsets []int64
+
+	object := func(body string) {
+		offsets = append(offsets, cw.n)
+		fmt.Fprintf(cw, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	cw.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, 0, len(d.pages))
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			num(d.width), num(d.height), 5+2*i))
+		var stream bytes.Buffer
+
+		zw := zlib.NewWriter(&stream)
+		if _, err := zw.Write(p.content.Bytes()); err != nil {
+			return cw.n, fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+
+		if err := zw.Close(); err != nil {
+			return cw.n, fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+	}
+
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+
+	for _, off := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", off)
+	}
+
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	if cw.err != nil {
+		return cw.n, fmt.Errorf("failed to write PDF: %w", cw.err)
+	}
+
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, fmt.Errorf("failed to write PDF: %w", err)
+	}
+
+	return cw.n, nil
+}
+
+// countingWriter counts the bytes written and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+
+	return n, err
+}
+
+func (c *countingWriter) WriteString(s string) {
+	_, _ = c.Write([]byte(s))
+}
+
+// num formats a coordinate with at most 3 decimals
+func num(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+
+	if s == "" || s == "-0" {
+		return "0"
+	}
+
+	return s
+}
+
+// escape returns text as the body of a PDF string literal
+func escape(text string) string {
+	var b strings.Builder
+
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	doc := New(A4Width, A4Height)
+
+	first := doc.AddPage()
+	first.Text(10, 20, 12, "Chunk (1) of 2")
+	first.FillGrid(100, 200, 2.5, [][4]int{{0, 0, 3, 1}, {1, 1, 1, 1}})
+
+	second := doc.AddPage()
+	second.Line(0, 0, 50, 50, 0.5)
+
+	if doc.PageCount() != 2 {
+		t.Fatalf("PageCount() = %d, want 2", doc.PageCount())
+	}
+
+	var b bytes.Buffer
+
+	n, err := doc.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo returned %d bytes, wrote %d", n, b.Len())
+	}
+
+	out := b.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("Document is missing the PDF header or trailer")
+	}
+
+	if !strings.Contains(out, "/Count 2") {
+		t.Error("Page tree does not count 2 pages")
+	}
+
+	// Every cross-reference entry points at the start of its object
+	xref, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(out)[1])
+	if err != nil || !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Fatalf("startxref does not point at the cross-reference table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xref:], -1)
+	if len(entries) != 7 {
+		t.Fatalf("Expected 7 objects, cross-reference table lists %d", len(entries))
+	}
+
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[offset:], want) {
+			t.Errorf("Object %d is not at offset %d", i+1, offset)
+		}
+	}
+
+	// The content streams hold the drawing operators
+	streams := regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllStringSubmatch(out, -1)
+	if len(streams) != 2 {
+		t.Fatalf("Expected 2 content streams, found %d", len(streams))
+	}
+
+	var contents []string
+
+	for _, stream := range streams {
+		r, err := zlib.NewReader(strings.NewReader(stream[1]))
+		if err != nil {
+			t.Fatalf("Failed to open content stream: %v", err)
+		}
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Failed to decompress content stream: %v", err)
+		}
+
+		contents = append(contents, string(content))
+	}
+
+	for _, want := range []string{
+		`BT /F1 12 Tf 10 20 Td (Chunk \(1\) of 2) Tj ET`,
+		"q 2.5 0 0 2.5 100 200 cm\n0 0 3 1 re\n1 1 1 1 re\nf Q",
+	} {
+		if !strings.Contains(contents[0], want) {
+			t.Errorf("First page does not contain %q:\n%s", want, contents[0])
+		}
+	}
+
+	if want := "0.5 w 0 0 m 50 50 l S"; !strings.Contains(contents[1], want) {
+		t.Errorf("Second page does not contain %q:\n%s", want, contents[1])
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if got, want := escape(`a\b (c) é`), `a\\b \(c\) ?`; got != want {
+		t.Errorf("escape() = %q, want %q", got, want)
+	}
+}
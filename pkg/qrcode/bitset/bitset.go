@@ -49,8 +49,11 @@ func New(v ...bool) *Bitset {
 }
 
 // Clone returns a copy.
+//
+// The copy does not share its underlying array with from, so appending to either
+// leaves the other unchanged.
 func Clone(from *Bitset) *Bitset {
-	return &Bitset{numBits: from.numBits, bits: from.bits}
+	return &Bitset{numBits: from.numBits, bits: append([]byte(nil), from.bits...)}
 }
 
 // Substr returns a substring, consisting of the bits from indexes start to end.
@@ -62,6 +65,18 @@ func (b *Bitset) Substr(start int, end int) *Bitset {
 	result := New()
 	result.ensureCapacity(end - start)
 
+	// Whole bytes are copied from a byte boundary, and the bits after end cleared.
+	if start%8 == 0 {
+		copy(result.bits, b.bits[start/8:(end+7)/8])
+		result.numBits = end - start
+
+		if n := result.numBits % 8; n != 0 {
+			result.bits[result.numBits/8] &= 0xff << uint(8-n)
+		}
+
+		return result
+	}
+
 	for i := start; i < end; i++ {
 		if b.At(i) {
 			result.bits[result.numBits/8] |= 0x80 >> uint(result.numBits%8)
@@ -99,9 +114,7 @@ func NewFromBase2String(b2string string) *Bitset {
 
 // AppendBytes appends a list of whole bytes.
 func (b *Bitset) AppendBytes(data []byte) {
-	for _, d := range data {
-		b.AppendByte(d, 8)
-	}
+	b.appendBits(data, 8*len(data))
 }
 
 // AppendByte appends the numBits least significant bits from value.
@@ -161,15 +174,38 @@ func (b *Bitset) ensureCapacity(numBits int) {
 //
 // The new length is b.Len() + other.Len().
 func (b *Bitset) Append(other *Bitset) {
-	b.ensureCapacity(other.numBits)
+	b.appendBits(other.bits, other.numBits)
+}
 
-	for i := 0; i < other.numBits; i++ {
-		if other.At(i) {
-			b.bits[b.numBits/8] |= 0x80 >> uint(b.numBits%8)
+// appendBits appends the first numBits bits of bits, whose bits after numBits are
+// clear like those after the length of a Bitset.
+//
+// The bytes are shifted into place whole rather than bit by bit, for the long
+// data sequences of large QR Codes.
+func (b *Bitset) appendBits(bits []byte, numBits int) {
+	b.ensureCapacity(numBits)
+
+	shift := uint(b.numBits % 8)
+	i := b.numBits / 8
+
+	if shift == 0 {
+		copy(b.bits[i:], bits[:(numBits+7)/8])
+		b.numBits += numBits
+
+		return
+	}
+
+	for _, d := range bits[:(numBits+7)/8] {
+		b.bits[i] |= d >> shift
+
+		if i+1 < len(b.bits) {
+			b.bits[i+1] |= d << (8 - shift)
 		}
 
-		b.numBits++
+		i++
 	}
+
+	b.numBits += numBits
 }
 
 // AppendBools appends bits to the Bitset.
@@ -187,8 +223,17 @@ func (b *Bitset) AppendBools(bits ...bool) {
 
 // AppendNumBools appends num bits of value value.
 func (b *Bitset) AppendNumBools(num int, value bool) {
+	b.ensureCapacity(num)
+
+	if !value {
+		b.numBits += num
+
+		return
+	}
+
 	for i := 0; i < num; i++ {
-		b.AppendBools(value)
+		b.bits[b.numBits/8] |= 0x80 >> uint(b.numBits%8)
+		b.numBits++
 	}
 }
 
@@ -264,6 +309,10 @@ func (b *Bitset) ByteAt(index int) byte {
 		log.Panicf("Index %d out of range", index)
 	}
 
+	if index%8 == 0 && index+8 <= b.numBits {
+		return b.bits[index/8]
+	}
+
 	var result byte
 
 	for i := index; i < index+8 && i < b.numBits; i++ {
@@ -4,6 +4,7 @@
 package bitset
 
 import (
+	"fmt"
 	"math/rand"
 	"testing"
 )
@@ -321,3 +322,101 @@ func TestSubstr(t *testing.T) {
 		}
 	}
 }
+
+func TestClone(t *testing.T) {
+	b := New(b1, b0, b1)
+
+	clone := Clone(b)
+	clone.AppendBools(b1, b1)
+	b.AppendBools(b0, b0)
+
+	if expected := New(b1, b0, b1, b0, b0); !b.Equals(expected) {
+		t.Errorf("Got %s, expected %s", b.String(), expected.String())
+	}
+
+	if expected := New(b1, b0, b1, b1, b1); !clone.Equals(expected) {
+		t.Errorf("Got %s, expected %s", clone.String(), expected.String())
+	}
+}
+
+func TestSubstrAppend(t *testing.T) {
+	randomBools := make([]bool, 64)
+
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < len(randomBools); i++ {
+		randomBools[i] = rng.Intn(2) == 1
+	}
+
+	b := New(randomBools...)
+	ones := []bool{b1, b1, b1, b1, b1, b1, b1, b1, b1}
+
+	// Substrings from a byte boundary or not, followed by appended bits, which
+	// must not pick up the bits after the end of the substring.
+	for start := 0; start < 24; start++ {
+		for end := start; end < len(randomBools); end += 3 {
+			result := b.Substr(start, end)
+			result.Append(New(ones...))
+			result.AppendNumBools(3, b1)
+			result.AppendBytes([]byte{0xa5})
+
+			expected := append(append([]bool{}, randomBools[start:end]...), ones...)
+			expected = append(expected, b1, b1, b1, b1, b0, b1, b0, b0, b1, b0, b1)
+
+			if !equal(result.Bits(), expected) {
+				t.Errorf("Substr(%d, %d) + appended bits got %v, want %v", start, end, result.Bits(), expected)
+			}
+		}
+	}
+}
+
+func TestAppendNumBools(t *testing.T) {
+	for _, prefix := range [][]bool{{}, {b1}, {b1, b0, b1, b1, b0, b1, b1, b1}} {
+		for _, value := range []bool{b0, b1} {
+			b := New(prefix...)
+			b.AppendNumBools(11, value)
+			b.AppendBools(b1)
+
+			expected := append([]bool{}, prefix...)
+			for i := 0; i < 11; i++ {
+				expected = append(expected, value)
+			}
+
+			expected = append(expected, b1)
+
+			if !equal(b.Bits(), expected) {
+				t.Errorf("AppendNumBools(11, %t) after %v got %v, want %v", value, prefix, b.Bits(), expected)
+			}
+		}
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	data := New()
+	for i := 0; i < 2956; i++ {
+		data.AppendByte(byte(i), 8)
+	}
+
+	for _, offset := range []int{0, 3} {
+		b.Run(fmt.Sprintf("offset %d", offset), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				result := New()
+				result.AppendNumBools(offset, b0)
+				result.Append(data)
+			}
+		})
+	}
+}
+
+func BenchmarkSubstr(b *testing.B) {
+	data := New()
+	for i := 0; i < 2956; i++ {
+		data.AppendByte(byte(i), 8)
+	}
+
+	for i := 0; i < b.N; i++ {
+		for start := 0; start < data.Len(); start += 8 * 118 {
+			data.Substr(start, min(start+8*118, data.Len()))
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package qrcode
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", DecodeBMP, DecodeBMPConfig)
+}
+
+// BMP file and info header sizes, of a BITMAPINFOHEADER.
+const (
+	bmpFileHeaderSize = 14
+	bmpInfoHeaderSize = 40
+)
+
+// EncodeBMP writes img as an uncompressed 24 bit BMP image to out, the format
+// read by the most e-ink and embedded displays. A resolution of dpi dots per inch
+// is recorded in the header, none if 0.
+//
+// The image has no alpha channel: transparent pixels are written as their color
+// over black.
+func EncodeBMP(out io.Writer, img image.Image, dpi int) error {
+	if dpi < 0 || dpi > maxDPI {
+		return fmt.Errorf("invalid resolution of %d DPI (expected 0-%d inclusive)", dpi, maxDPI)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Every row is padded to a multiple of 4 bytes
+	stride := (3*width + 3) &^ 3
+	imageSize := stride * height
+
+	if int64(imageSize)+bmpFileHeaderSize+bmpInfoHeaderSize > math.MaxUint32 {
+		return fmt.Errorf("image of %dx%d pixels is too large for BMP", width, height)
+	}
+
+	ppm := uint32(math.Round(float64(dpi) / 0.0254))
+
+	header := make([]byte, bmpFileHeaderSize+bmpInfoHeaderSize)
+	copy(header, "BM")
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(header)+imageSize))
+	binary.LittleEndian.PutUint32(header[10:], uint32(len(header)))
+
+	info := header[bmpFileHeaderSize:]
+	binary.LittleEndian.PutUint32(info[0:], bmpInfoHeaderSize)
+	binary.LittleEndian.PutUint32(info[4:], uint32(width))
+	binary.LittleEndian.PutUint32(info[8:], uint32(height)) // Bottom-up rows
+	binary.LittleEndian.PutUint16(info[12:], 1)             // Planes
+	binary.LittleEndian.PutUint16(info[14:], 24)            // Bits per pixel
+	binary.LittleEndian.PutUint32(info[20:], uint32(imageSize))
+	binary.LittleEndian.PutUint32(info[24:], ppm)
+	binary.LittleEndian.PutUint32(info[28:], ppm)
+
+	w := bufio.NewWriter(out)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write BMP header: %w", err)
+	}
+
+	row := make([]byte, stride)
+
+	for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+		for x := 0; x < width; x++ {
+			c := color.RGBAModel.Convert(img.At(bounds.Min.X+x, y)).(color.RGBA)
+			row[3*x], row[3*x+1], row[3*x+2] = c.B, c.G, c.R
+		}
+
+		if _, err := w.Write(row); err != nil {
+			return fmt.Errorf("failed to write BMP image: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write BMP image: %w", err)
+	}
+
+	return nil
+}
+
+// bmpHeader is the part of the BMP file and info headers DecodeBMP reads.
+type bmpHeader struct {
+	offset        uint32
+	width, height int
+	topDown       bool
+	bitsPerPixel  uint16
+}
+
+// readBMPHeader reads the headers of a BMP image from r, up to the pixel data of
+// an uncompressed 24 or 32 bit image.
+func readBMPHeader(r io.Reader) (bmpHeader, error) {
+	header := make([]byte, bmpFileHeaderSize+bmpInfoHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return bmpHeader{}, fmt.Errorf("failed to read BMP header: %w", err)
+	}
+
+	info := header[bmpFileHeaderSize:]
+	if string(header[:2]) != "BM" || binary.LittleEndian.Uint32(info[0:]) < bmpInfoHeaderSize {
+		return bmpHeader{}, errors.New("not a BMP image with a BITMAPINFOHEADER")
+	}
+
+	h := bmpHeader{
+		offset:       binary.LittleEndian.Uint32(header[10:]),
+		width:        int(int32(binary.LittleEndian.Uint32(info[4:]))),
+		height:       int(int32(binary.LittleEndian.Uint32(info[8:]))),
+		bitsPerPixel: binary.LittleEndian.Uint16(info[14:]),
+	}
+
+	// A negative height stores the rows top-down
+	if h.height < 0 {
+		h.height, h.topDown = -h.height, true
+	}
+
+	if compression := binary.LittleEndian.Uint32(info[16:]); compression != 0 || (h.bitsPerPixel != 24 && h.bitsPerPixel != 32) {
+		return bmpHeader{}, fmt.Errorf("unsupported BMP image of %d bits with compression %d (expected uncompressed 24 or 32 bits)", h.bitsPerPixel, compression)
+	}
+
+	if h.width <= 0 || h.offset < uint32(len(header)) {
+		return bmpHeader{}, errors.New("invalid BMP header")
+	}
+
+	return h, nil
+}
+
+// DecodeBMPConfig returns the dimensions of the BMP image read from r, see
+// DecodeBMP.
+func DecodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{ColorModel: color.RGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+// DecodeBMP reads an uncompressed 24 or 32 bit BMP image from r, as written by
+// EncodeBMP. The format is registered with the image package, so that
+// image.Decode reads the BMP images of a session.
+func DecodeBMP(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readBMPHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := br.Discard(int(h.offset) - bmpFileHeaderSize - bmpInfoHeaderSize); err != nil {
+		return nil, fmt.Errorf("failed to read BMP image: %w", err)
+	}
+
+	bytesPerPixel := int(h.bitsPerPixel) / 8
+	stride := (bytesPerPixel*h.width + 3) &^ 3
+
+	if int64(stride)*int64(h.height) > math.MaxInt32 {
+		return nil, fmt.Errorf("BMP image of %dx%d pixels is too large", h.width, h.height)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, h.width, h.height))
+	row := make([]byte, stride)
+
+	for i := 0; i < h.height; i++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, fmt.Errorf("failed to read BMP image: %w", err)
+		}
+
+		y := h.height - 1 - i
+		if h.topDown {
+			y = i
+		}
+
+		// The alpha byte of 32 bit pixels is ignored, as by most readers
+		pix := img.Pix[y*img.Stride:]
+		for x := 0; x < h.width; x++ {
+			p := row[bytesPerPixel*x:]
+			pix[4*x], pix[4*x+1], pix[4*x+2], pix[4*x+3] = p[2], p[1], p[0], 0xff
+		}
+	}
+
+	return img, nil
+}
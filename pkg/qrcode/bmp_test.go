@@ -0,0 +1,108 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeBMP(t *testing.T) {
+	q, err := New("https://example.org", Medium)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	q.ForegroundColor = color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}
+
+	// A width of 3 pixels pads every row
+	for _, size := range []int{-3, 99} {
+		img := q.Image(size)
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+		var b bytes.Buffer
+		if err := EncodeBMP(&b, img, 300); err != nil {
+			t.Fatalf("EncodeBMP got %s expected success", err.Error())
+		}
+
+		p := b.Bytes()
+		stride := (3*width + 3) &^ 3
+
+		if string(p[:2]) != "BM" || int(binary.LittleEndian.Uint32(p[2:])) != len(p) || len(p) != 54+stride*height {
+			t.Fatalf("BMP of %d bytes got header %q of size %d, expected BM of %d bytes", len(p), p[:2], binary.LittleEndian.Uint32(p[2:]), 54+stride*height)
+		}
+
+		if w, h, bpp := binary.LittleEndian.Uint32(p[18:]), binary.LittleEndian.Uint32(p[22:]), binary.LittleEndian.Uint16(p[28:]); int(w) != width || int(h) != height || bpp != 24 {
+			t.Errorf("BMP got %dx%d pixels of %d bits, expected %dx%d of 24 bits", w, h, bpp, width, height)
+		}
+
+		if ppm := binary.LittleEndian.Uint32(p[38:]); ppm != 11811 {
+			t.Errorf("BMP resolution got %d pixels per meter, expected 11811", ppm)
+		}
+
+		// Rows are stored bottom-up, pixels as blue, green, red
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				i := 54 + (height-1-y)*stride + 3*x
+				want := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+
+				if got := (color.RGBA{R: p[i+2], G: p[i+1], B: p[i], A: 0xff}); got != want {
+					t.Fatalf("pixel (%d, %d) got %v, expected %v", x, y, got, want)
+				}
+			}
+		}
+	}
+
+	if err := EncodeBMP(&bytes.Buffer{}, q.Image(16), -1); err == nil {
+		t.Error("EncodeBMP with an invalid resolution succeeded, expected error")
+	}
+}
+
+func TestDecodeBMP(t *testing.T) {
+	q, err := New("https://example.org", Medium)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	img := q.Image(-3)
+
+	var b bytes.Buffer
+	if err := EncodeBMP(&b, img, 0); err != nil {
+		t.Fatalf("EncodeBMP got %s expected success", err.Error())
+	}
+
+	p := b.Bytes()
+
+	// image.Decode finds the registered format
+	decoded, format, err := image.Decode(bytes.NewReader(p))
+	if err != nil {
+		t.Fatalf("image.Decode got %s expected success", err.Error())
+	}
+
+	if format != "bmp" || decoded.Bounds() != img.Bounds() {
+		t.Fatalf("image.Decode got a %s image of %v, expected a bmp image of %v", format, decoded.Bounds(), img.Bounds())
+	}
+
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		for x := 0; x < img.Bounds().Dx(); x++ {
+			want := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+
+			if got := decoded.At(x, y).(color.RGBA); got != want {
+				t.Fatalf("pixel (%d, %d) got %v, expected %v", x, y, got, want)
+			}
+		}
+	}
+
+	// Run-length encoded images are not supported
+	compressed := bytes.Clone(p)
+	binary.LittleEndian.PutUint32(compressed[30:], 1)
+
+	if _, err := DecodeBMP(bytes.NewReader(compressed)); err == nil {
+		t.Error("DecodeBMP of a compressed image succeeded, expected error")
+	}
+
+	if _, err := DecodeBMP(bytes.NewReader(p[:60])); err == nil {
+		t.Error("DecodeBMP of a truncated image succeeded, expected error")
+	}
+}
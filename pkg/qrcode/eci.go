@@ -0,0 +1,166 @@
+package qrcode
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Extended Channel Interpretation.
+//
+// Readers decode byte mode data in a default character set, ISO-8859-1 in the
+// standard, although many guess it from the data. An ECI header before the
+// segments designates the character set of the data instead:
+//
+// - The ECI mode indicator, 0111.
+// - The ECI designator, 0-127 in 8 bits starting with 0, up to 16383 in 16 bits
+// starting with 10, or up to 999999 in 24 bits starting with 110.
+//
+// Kanji mode data is always Shift JIS, and ASCII is read the same in every
+// character set, so neither needs an ECI header.
+
+// ECI designators of common character sets.
+const (
+	// ECILatin1 designates ISO-8859-1, the default character set.
+	ECILatin1 = 3
+
+	// ECIShiftJIS designates Shift JIS.
+	ECIShiftJIS = 20
+
+	// ECIUTF8 designates UTF-8.
+	ECIUTF8 = 26
+)
+
+// maxECI is the largest ECI designator.
+const maxECI = 999999
+
+// eciHeader returns the ECI header bits designating eci.
+func eciHeader(eci int) (*bitset.Bitset, error) {
+	header := bitset.New(b0, b1, b1, b1)
+
+	switch {
+	case eci < 0 || eci > maxECI:
+		return nil, fmt.Errorf("invalid ECI designator %d (expected 0-%d inclusive)", eci, maxECI)
+	case eci < 1<<7:
+		header.AppendUint32(uint32(eci), 8)
+	case eci < 1<<14:
+		header.AppendUint32(0x8000|uint32(eci), 16)
+	default:
+		header.AppendUint32(0xc00000|uint32(eci), 24)
+	}
+
+	return header, nil
+}
+
+// NewECI constructs a QRCode holding data, text in the character set designated
+// by eci, after an ECI header.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewECI([]byte("naïve café"), qrcode.Medium, qrcode.ECIUTF8)
+//
+// The double-byte characters of Shift JIS data in the Kanji ranges are encoded in
+// Kanji mode. The Content field holds the data converted to a string.
+//
+// An error wrapping ErrContentTooLong occurs if the data is too long, or an error
+// in case of an invalid designator.
+func NewECI(data []byte, level RecoveryLevel, eci int) (*QRCode, error) {
+	header, err := eciHeader(eci)
+	if err != nil {
+		return nil, err
+	}
+
+	return newQRCode(string(data), level, func(encoder *dataEncoder) (*bitset.Bitset, error) {
+		encoder.kanji = eci == ECIShiftJIS
+
+		encoded, err := encoder.encode(data)
+		if err != nil {
+			return nil, err
+		}
+
+		result := bitset.Clone(header)
+		result.Append(encoded)
+
+		return result, nil
+	})
+}
+
+// NewText constructs a QRCode holding the UTF-8 text content so that standard
+// readers decode it in its character set.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewText("ファイル.txt", qrcode.Medium)
+//
+// ASCII content is encoded like by New. Text whose other characters are all
+// kanji, kana, or other double-byte characters of Shift JIS is encoded in Kanji
+// mode where it fits a smaller or the same version that way, and other text after
+// an ECI header designating UTF-8. Content that is not valid UTF-8 is encoded
+// like by New.
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long.
+func NewText(content string, level RecoveryLevel) (*QRCode, error) {
+	if !utf8.ValidString(content) || isASCII(content) {
+		return New(content, level)
+	}
+
+	q, err := NewECI([]byte(content), level, ECIUTF8)
+
+	data, ok := kanjiText(content)
+	if !ok {
+		return q, err
+	}
+
+	kanji, kanjiErr := newQRCode(content, level, func(encoder *dataEncoder) (*bitset.Bitset, error) {
+		encoder.kanji = true
+
+		return encoder.encode(data)
+	})
+	if kanjiErr == nil && (err != nil || kanji.VersionNumber <= q.VersionNumber) {
+		return kanji, nil
+	}
+
+	return q, err
+}
+
+// isASCII returns true if s holds ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// kanjiText returns the UTF-8 text content in Shift JIS, and false unless all its
+// characters are ASCII or double-byte characters of Kanji mode that decode back
+// to themselves.
+func kanjiText(content string) ([]byte, bool) {
+	data := make([]byte, 0, len(content))
+
+	encoder := japanese.ShiftJIS.NewEncoder()
+	decoder := japanese.ShiftJIS.NewDecoder()
+
+	for _, r := range content {
+		if r < utf8.RuneSelf {
+			data = append(data, byte(r))
+
+			continue
+		}
+
+		c, err := encoder.Bytes([]byte(string(r)))
+		if err != nil || len(c) != 2 || !isKanjiCharacter(c[0], c[1]) {
+			return nil, false
+		}
+
+		if decoded, err := decoder.Bytes(c); err != nil || string(decoded) != string(r) {
+			return nil, false
+		}
+
+		data = append(data, c...)
+	}
+
+	return data, true
+}
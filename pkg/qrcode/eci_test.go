@@ -0,0 +1,105 @@
+package qrcode
+
+import (
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+)
+
+func TestECIHeader(t *testing.T) {
+	tests := []struct {
+		eci      int
+		expected string
+	}{
+		{ECIUTF8, "0111 00011010"},
+		{1000, "0111 10000011 11101000"},
+		{100000, "0111 11000001 10000110 10100000"},
+	}
+
+	for _, test := range tests {
+		header, err := eciHeader(test.eci)
+		if err != nil {
+			t.Fatalf("eciHeader(%d) got %s expected success", test.eci, err.Error())
+		}
+
+		if expected := bitset.NewFromBase2String(test.expected); !header.Equals(expected) {
+			t.Errorf("eciHeader(%d) got %s, expected %s", test.eci, header, expected)
+		}
+	}
+
+	for _, invalid := range []int{-1, maxECI + 1} {
+		if _, err := eciHeader(invalid); err == nil {
+			t.Errorf("eciHeader(%d) succeeded, expected error", invalid)
+		}
+	}
+}
+
+func TestNewECI(t *testing.T) {
+	q, err := NewECI([]byte("naïve"), Medium, ECIUTF8)
+	if err != nil {
+		t.Fatalf("NewECI got %s expected success", err.Error())
+	}
+
+	// ECI header, then a single byte mode segment of 6 bytes
+	expected := bitset.NewFromBase2String("0111 00011010 0100 00000110")
+	if !q.data.Substr(0, expected.Len()).Equals(expected) {
+		t.Errorf("NewECI got %s, expected to start with %s", q.data.Substr(0, expected.Len()), expected)
+	}
+
+	if _, err := NewECI([]byte("x"), Medium, -1); err == nil {
+		t.Error("NewECI with an invalid designator succeeded, expected error")
+	}
+}
+
+func TestNewText(t *testing.T) {
+	ascii, err := NewText("HELLO 42", Medium)
+	if err != nil {
+		t.Fatalf("NewText got %s expected success", err.Error())
+	}
+
+	if plain, _ := New("HELLO 42", Medium); !ascii.data.Equals(plain.data) {
+		t.Errorf("NewText of ASCII got %s, expected %s", ascii.data, plain.data)
+	}
+
+	utf8, err := NewText("café", Medium)
+	if err != nil {
+		t.Fatalf("NewText got %s expected success", err.Error())
+	}
+
+	if header, _ := eciHeader(ECIUTF8); !utf8.data.Substr(0, header.Len()).Equals(header) {
+		t.Errorf("NewText of Latin text got %s, expected an ECI header", utf8.data)
+	}
+
+	// 点 and 茗 are 0x935f and 0xe4aa in Shift JIS
+	kanji, err := NewText("点茗", Medium)
+	if err != nil {
+		t.Fatalf("NewText got %s expected success", err.Error())
+	}
+
+	expected := bitset.NewFromBase2String("1000 00000010 0110110011111 1101010101010")
+	if !kanji.data.Equals(expected) {
+		t.Errorf("NewText of kanji got %s, expected %s", kanji.data, expected)
+	}
+
+	if kanji.Content != "点茗" {
+		t.Errorf("NewText content got %q, expected %q", kanji.Content, "点茗")
+	}
+
+	binary := string([]byte{0xff, 0xfe, 0x00})
+	if q, err := NewText(binary, Medium); err != nil || q.Content != binary {
+		t.Errorf("NewText of invalid UTF-8 got %v, expected success", err)
+	}
+}
+
+func TestKanjiText(t *testing.T) {
+	if data, ok := kanjiText("A点"); !ok || string(data) != "A\x93\x5f" {
+		t.Errorf("kanjiText got %q, %v, expected \"A\\x93\\x5f\"", data, ok)
+	}
+
+	// é is not a Shift JIS character, ｱ a single byte one
+	for _, content := range []string{"café", "ｱ"} {
+		if _, ok := kanjiText(content); ok {
+			t.Errorf("kanjiText(%q) succeeded, expected failure", content)
+		}
+	}
+}
@@ -15,7 +15,7 @@ import (
 // The main data portion of a QR Code consists of one or more segments of data.
 // A segment consists of:
 //
-// - The segment Data Mode: numeric, alphanumeric, or byte.
+// - The segment Data Mode: numeric, alphanumeric, byte, or Kanji.
 // - The length of segment in bits.
 // - Encoded data.
 //
@@ -36,8 +36,9 @@ import (
 // size, an optimisation routine coalesces segment types where possible, to
 // reduce the encoded data length.
 //
-// There are several other data modes available (e.g. Kanji mode) which are not
-// implemented here.
+// Kanji mode encodes the double-byte characters of Shift JIS text in 13 bits
+// each, instead of 16 in byte mode. It is only used for data the caller states to
+// be Shift JIS, see dataEncoder.kanji, and never coalesces with other modes.
 
 // A segment encoding mode.
 type dataMode uint8
@@ -53,6 +54,10 @@ const (
 	dataModeNumeric
 	dataModeAlphanumeric
 	dataModeByte
+
+	// dataModeKanji holds Shift JIS double-byte characters only, and is not a
+	// superset of the other modes.
+	dataModeKanji
 )
 
 // dataModeString returns d as a short printable string.
@@ -66,6 +71,8 @@ func dataModeString(d dataMode) string {
 		return "alphanumeric"
 	case dataModeByte:
 		return "byte"
+	case dataModeKanji:
+		return "kanji"
 	}
 
 	return "unknown"
@@ -98,11 +105,17 @@ type dataEncoder struct {
 	numericModeIndicator      *bitset.Bitset
 	alphanumericModeIndicator *bitset.Bitset
 	byteModeIndicator         *bitset.Bitset
+	kanjiModeIndicator        *bitset.Bitset
 
 	// Character count lengths.
 	numNumericCharCountBits      int
 	numAlphanumericCharCountBits int
 	numByteCharCountBits         int
+	numKanjiCharCountBits        int
+
+	// The raw input data is Shift JIS, whose double-byte characters in the Kanji
+	// ranges are encoded in Kanji mode.
+	kanji bool
 
 	// The raw input data.
 	data []byte
@@ -126,9 +139,11 @@ func newDataEncoder(t dataEncoderType) *dataEncoder {
 			numericModeIndicator:         bitset.New(b0, b0, b0, b1),
 			alphanumericModeIndicator:    bitset.New(b0, b0, b1, b0),
 			byteModeIndicator:            bitset.New(b0, b1, b0, b0),
+			kanjiModeIndicator:           bitset.New(b1, b0, b0, b0),
 			numNumericCharCountBits:      10,
 			numAlphanumericCharCountBits: 9,
 			numByteCharCountBits:         8,
+			numKanjiCharCountBits:        8,
 		}
 	case dataEncoderType10To26:
 		d = &dataEncoder{
@@ -137,9 +152,11 @@ func newDataEncoder(t dataEncoderType) *dataEncoder {
 			numericModeIndicator:         bitset.New(b0, b0, b0, b1),
 			alphanumericModeIndicator:    bitset.New(b0, b0, b1, b0),
 			byteModeIndicator:            bitset.New(b0, b1, b0, b0),
+			kanjiModeIndicator:           bitset.New(b1, b0, b0, b0),
 			numNumericCharCountBits:      12,
 			numAlphanumericCharCountBits: 11,
 			numByteCharCountBits:         16,
+			numKanjiCharCountBits:        10,
 		}
 	case dataEncoderType27To40:
 		d = &dataEncoder{
@@ -148,9 +165,11 @@ func newDataEncoder(t dataEncoderType) *dataEncoder {
 			numericModeIndicator:         bitset.New(b0, b0, b0, b1),
 			alphanumericModeIndicator:    bitset.New(b0, b0, b1, b0),
 			byteModeIndicator:            bitset.New(b0, b1, b0, b0),
+			kanjiModeIndicator:           bitset.New(b1, b0, b0, b0),
 			numNumericCharCountBits:      14,
 			numAlphanumericCharCountBits: 13,
 			numByteCharCountBits:         16,
+			numKanjiCharCountBits:        12,
 		}
 	default:
 		log.Panic("Unknown dataEncoderType")
@@ -159,6 +178,18 @@ func newDataEncoder(t dataEncoderType) *dataEncoder {
 	return d
 }
 
+// newVersionDataEncoder constructs the dataEncoder of a QR Code version 1-40.
+func newVersionDataEncoder(version int) *dataEncoder {
+	switch {
+	case version >= 27:
+		return newDataEncoder(dataEncoderType27To40)
+	case version >= 10:
+		return newDataEncoder(dataEncoderType10To26)
+	default:
+		return newDataEncoder(dataEncoderType1To9)
+	}
+}
+
 // encode data as one or more segments and return the encoded data.
 //
 // The returned data does not include the terminator bit sequence.
@@ -192,13 +223,16 @@ func (d *dataEncoder) encode(data []byte) (*bitset.Bitset, error) {
 		optimizedLength += length
 	}
 
-	singleByteSegmentLength, err := d.encodedLength(highestRequiredMode, len(d.data))
-	if err != nil {
-		return nil, err
-	}
+	// Kanji mode segments cannot be merged into a single segment.
+	if !d.hasKanji() {
+		singleByteSegmentLength, err := d.encodedLength(highestRequiredMode, len(d.data))
+		if err != nil {
+			return nil, err
+		}
 
-	if singleByteSegmentLength <= optimizedLength {
-		d.optimised = []segment{segment{dataMode: highestRequiredMode, data: d.data}}
+		if singleByteSegmentLength <= optimizedLength {
+			d.optimised = []segment{segment{dataMode: highestRequiredMode, data: d.data}}
+		}
 	}
 
 	// Encode data.
@@ -210,6 +244,34 @@ func (d *dataEncoder) encode(data []byte) (*bitset.Bitset, error) {
 	return encoded, nil
 }
 
+// encodeBytes encodes data as a single byte mode segment and returns the encoded
+// data.
+//
+// Unlike encode, the data is neither classified nor optimised into numeric and
+// alphanumeric segments, so binary content is stored verbatim.
+//
+// The returned data does not include the terminator bit sequence.
+func (d *dataEncoder) encodeBytes(data []byte) (*bitset.Bitset, error) {
+	d.data = data
+	d.actual = nil
+	d.optimised = nil
+
+	if len(data) == 0 {
+		return nil, errors.New("no data to encode")
+	}
+
+	if _, err := d.encodedLength(dataModeByte, len(data)); err != nil {
+		return nil, err
+	}
+
+	d.optimised = []segment{{dataMode: dataModeByte, data: d.data}}
+
+	encoded := bitset.New()
+	d.encodeDataRaw(d.data, dataModeByte, encoded)
+
+	return encoded, nil
+}
+
 // classifyDataModes classifies the raw data into unoptimised segments.
 // e.g. "123ZZ#!#!" =>
 // [numeric, 3, "123"] [alphanumeric, 2, "ZZ"] [byte, 4, "#!#!"].
@@ -224,10 +286,18 @@ func (d *dataEncoder) classifyDataModes() dataMode {
 	mode := dataModeNone
 	highestRequiredMode := mode
 
-	for i, v := range d.data {
+	for i := 0; i < len(d.data); i++ {
+		v := d.data[i]
+
 		var newMode dataMode
 
 		switch {
+		case d.kanji && isShiftJISLeadByte(v) && i+1 < len(d.data):
+			// A double-byte character is classified as a whole.
+			newMode = dataModeByte
+			if isKanjiCharacter(v, d.data[i+1]) {
+				newMode = dataModeKanji
+			}
 		case v >= 0x30 && v <= 0x39:
 			newMode = dataModeNumeric
 		case v == 0x20 || v == 0x24 || v == 0x25 || v == 0x2a || v == 0x2b || v ==
@@ -247,9 +317,13 @@ func (d *dataEncoder) classifyDataModes() dataMode {
 			mode = newMode
 		}
 
-		if newMode > highestRequiredMode {
+		if newMode > highestRequiredMode && newMode != dataModeKanji {
 			highestRequiredMode = newMode
 		}
+
+		if d.kanji && isShiftJISLeadByte(v) && i+1 < len(d.data) {
+			i++
+		}
 	}
 
 	d.actual = append(d.actual, segment{dataMode: mode, data: d.data[start:len(d.data)]})
@@ -276,7 +350,7 @@ func (d *dataEncoder) optimiseDataModes() error {
 			nextNumChars := len(d.actual[j].data)
 			nextMode := d.actual[j].dataMode
 
-			if nextMode > mode {
+			if nextMode > mode || mode == dataModeKanji || nextMode == dataModeKanji {
 				break
 			}
 
@@ -330,8 +404,13 @@ func (d *dataEncoder) encodeDataRaw(data []byte, dataMode dataMode, encoded *bit
 	// Append mode indicator.
 	encoded.Append(modeIndicator)
 
-	// Append character count.
-	encoded.AppendUint32(uint32(len(data)), charCountBits)
+	// Append character count, of double-byte characters in Kanji mode.
+	numChars := len(data)
+	if dataMode == dataModeKanji {
+		numChars /= 2
+	}
+
+	encoded.AppendUint32(uint32(numChars), charCountBits)
 
 	// Append data.
 	switch dataMode {
@@ -372,6 +451,10 @@ func (d *dataEncoder) encodeDataRaw(data []byte, dataMode dataMode, encoded *bit
 		for _, b := range data {
 			encoded.AppendByte(b, 8)
 		}
+	case dataModeKanji:
+		for i := 0; i+1 < len(data); i += 2 {
+			encoded.AppendUint32(encodeKanjiCharacter(data[i], data[i+1]), 13)
+		}
 	}
 }
 
@@ -384,6 +467,8 @@ func (d *dataEncoder) modeIndicator(dataMode dataMode) *bitset.Bitset {
 		return d.alphanumericModeIndicator
 	case dataModeByte:
 		return d.byteModeIndicator
+	case dataModeKanji:
+		return d.kanjiModeIndicator
 	default:
 		log.Panic("Unknown data mode")
 	}
@@ -401,6 +486,8 @@ func (d *dataEncoder) charCountBits(dataMode dataMode) int {
 		return d.numAlphanumericCharCountBits
 	case dataModeByte:
 		return d.numByteCharCountBits
+	case dataModeKanji:
+		return d.numKanjiCharCountBits
 	default:
 		log.Panic("Unknown data mode")
 	}
@@ -409,7 +496,7 @@ func (d *dataEncoder) charCountBits(dataMode dataMode) int {
 }
 
 // encodedLength returns the number of bits required to encode n symbols in
-// dataMode.
+// dataMode. In Kanji mode, n counts the bytes of the double-byte characters.
 //
 // The number of bits required is affected by:
 //   - QR code type - Mode Indicator length.
@@ -427,6 +514,10 @@ func (d *dataEncoder) encodedLength(dataMode dataMode, n int) (int, error) {
 		return 0, errors.New("mode not supported")
 	}
 
+	if dataMode == dataModeKanji {
+		n /= 2
+	}
+
 	maxLength := (1 << uint8(charCountBits)) - 1
 
 	if n > maxLength {
@@ -447,11 +538,54 @@ func (d *dataEncoder) encodedLength(dataMode dataMode, n int) (int, error) {
 		length += 6 * (n % 2)
 	case dataModeByte:
 		length += 8 * n
+	case dataModeKanji:
+		length += 13 * n
 	}
 
 	return length, nil
 }
 
+// hasKanji returns true if the optimised segments include a Kanji mode segment.
+func (d *dataEncoder) hasKanji() bool {
+	for _, s := range d.optimised {
+		if s.dataMode == dataModeKanji {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isShiftJISLeadByte returns true if v starts a Shift JIS double-byte character.
+func isShiftJISLeadByte(v byte) bool {
+	return (v >= 0x81 && v <= 0x9f) || (v >= 0xe0 && v <= 0xfc)
+}
+
+// isKanjiCharacter returns true if the Shift JIS double-byte character hi, lo is
+// in the 0x8140-0x9ffc or 0xe040-0xebbf ranges of Kanji mode.
+func isKanjiCharacter(hi byte, lo byte) bool {
+	c := uint16(hi)<<8 | uint16(lo)
+	if lo < 0x40 || lo == 0x7f || lo > 0xfc {
+		return false
+	}
+
+	return (c >= 0x8140 && c <= 0x9ffc) || (c >= 0xe040 && c <= 0xebbf)
+}
+
+// encodeKanjiCharacter returns the QR Code encoded value of the Shift JIS
+// double-byte character hi, lo, which must be in the Kanji mode ranges.
+func encodeKanjiCharacter(hi byte, lo byte) uint32 {
+	c := uint32(hi)<<8 | uint32(lo)
+
+	if c <= 0x9ffc {
+		c -= 0x8140
+	} else {
+		c -= 0xc140
+	}
+
+	return (c>>8)*0xc0 + c&0xff
+}
+
 // encodeAlphanumericChar returns the QR Code encoded value of v.
 //
 // v must be a QR Code defined alphanumeric character: 0-9, A-Z, SP, $%*+-./ or
@@ -62,6 +62,37 @@ func TestClassifyDataMode(t *testing.T) {
 	}
 }
 
+func TestClassifyKanjiDataMode(t *testing.T) {
+	// 点 and 茗, a double-byte character outside the Kanji ranges, then ASCII
+	data := []byte{0x93, 0x5f, 0xe4, 0xaa, 0xf0, 0x40, 0x41, 0x31}
+
+	encoder := newDataEncoder(dataEncoderType1To9)
+	encoder.kanji = true
+
+	if _, err := encoder.encode(data); err != nil {
+		t.Fatalf("Failed to encode data: %v", err)
+	}
+
+	expected := []segment{
+		{dataModeKanji, []byte{0x93, 0x5f, 0xe4, 0xaa}},
+		{dataModeByte, []byte{0xf0, 0x40}},
+		{dataModeAlphanumeric, []byte{0x41}},
+		{dataModeNumeric, []byte{0x31}},
+	}
+
+	if !reflect.DeepEqual(expected, encoder.actual) {
+		t.Errorf("Got %v, expected %v", encoder.actual, expected)
+	}
+
+	if !encoder.hasKanji() || encoder.optimised[0].dataMode != dataModeKanji {
+		t.Errorf("Got optimised segments %v, expected a Kanji mode segment first", encoder.optimised)
+	}
+
+	if length, err := encoder.encodedLength(dataModeKanji, 4); err != nil || length != 4+8+2*13 {
+		t.Errorf("Got Kanji mode length %d, %v, expected %d", length, err, 4+8+2*13)
+	}
+}
+
 func TestByteModeLengthCalculations(t *testing.T) {
 	var tests []struct {
 		dataEncoderType dataEncoderType
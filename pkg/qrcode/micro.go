@@ -0,0 +1,277 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/reedsolomon"
+)
+
+// Micro QR Code.
+//
+// A Micro QR Code is a smaller variant of the QR Code with a single finder
+// pattern, for payloads of a few characters. There are four versions, M1 to M4,
+// of 11x11 to 17x17 modules with a quiet zone of 2 modules:
+//
+// - M1 holds up to 5 numeric digits, with error detection only.
+// - M2 adds the alphanumeric mode and the Low and Medium recovery levels.
+// - M3 adds the byte and Kanji modes.
+// - M4 adds the High recovery level, and holds up to 15 bytes at Low.
+//
+// Micro QR Codes carry a single segment of data or a few short ones, no
+// structured append header, and are read by fewer decoders than QR Codes.
+
+// microVersion describes the data length and error correction of a single Micro
+// QR Code version at a recovery level.
+type microVersion struct {
+	// Version number, 1-4 inclusive for M1-M4.
+	version int
+
+	// Error recovery level.
+	level RecoveryLevel
+
+	// Symbol number identifying the version and level in the format information.
+	symbolNumber int
+
+	// Number of data bits. The last data codeword of M1 and M3 is 4 bits long.
+	numDataBits int
+
+	// Number of error correction codewords, in a single block.
+	numECCodewords int
+}
+
+// microVersions lists the Micro QR Code versions in increasing size. M1 provides
+// error detection only, and is listed at the Low level.
+var microVersions = []microVersion{
+	{1, Low, 0, 20, 2},
+	{2, Low, 1, 40, 5},
+	{2, Medium, 2, 32, 6},
+	{3, Low, 3, 84, 6},
+	{3, Medium, 4, 68, 8},
+	{4, Low, 5, 128, 8},
+	{4, Medium, 6, 112, 10},
+	{4, High, 7, 80, 14},
+}
+
+// newMicroDataEncoder constructs the dataEncoder of a Micro QR Code version. The
+// modes a version does not support have no mode indicator.
+func newMicroDataEncoder(version int) *dataEncoder {
+	d := &dataEncoder{minVersion: version, maxVersion: version}
+
+	switch version {
+	case 1:
+		d.numericModeIndicator = bitset.New()
+		d.numNumericCharCountBits = 3
+	case 2:
+		d.numericModeIndicator = bitset.New(b0)
+		d.alphanumericModeIndicator = bitset.New(b1)
+		d.numNumericCharCountBits = 4
+		d.numAlphanumericCharCountBits = 3
+	case 3:
+		d.numericModeIndicator = bitset.New(b0, b0)
+		d.alphanumericModeIndicator = bitset.New(b0, b1)
+		d.byteModeIndicator = bitset.New(b1, b0)
+		d.kanjiModeIndicator = bitset.New(b1, b1)
+		d.numNumericCharCountBits = 5
+		d.numAlphanumericCharCountBits = 4
+		d.numByteCharCountBits = 4
+		d.numKanjiCharCountBits = 3
+	case 4:
+		d.numericModeIndicator = bitset.New(b0, b0, b0)
+		d.alphanumericModeIndicator = bitset.New(b0, b0, b1)
+		d.byteModeIndicator = bitset.New(b0, b1, b0)
+		d.kanjiModeIndicator = bitset.New(b0, b1, b1)
+		d.numNumericCharCountBits = 6
+		d.numAlphanumericCharCountBits = 5
+		d.numByteCharCountBits = 5
+		d.numKanjiCharCountBits = 4
+	default:
+		log.Panicf("Invalid Micro QR Code version %d", version)
+	}
+
+	return d
+}
+
+// symbolSize returns the size of the Micro QR Code symbol in number of modules,
+// not including the quiet zone.
+func (v microVersion) symbolSize() int {
+	return 9 + 2*v.version
+}
+
+// quietZoneSize returns the number of modules of border space on each side of
+// the Micro QR Code.
+func (v microVersion) quietZoneSize() int {
+	return 2
+}
+
+// numTerminatorBits returns the length of the terminator of the version, which
+// is shortened or omitted when the data leaves less room.
+func (v microVersion) numTerminatorBits() int {
+	return 1 + 2*v.version
+}
+
+// formatInfo returns the 15-bit Format Information value for a Micro QR Code.
+func (v microVersion) formatInfo(maskPattern int) *bitset.Bitset {
+	if maskPattern < 0 || maskPattern > 3 {
+		log.Panicf("Invalid maskPattern %d", maskPattern)
+	}
+
+	result := bitset.New()
+	result.AppendUint32(formatBitSequence[v.symbolNumber<<2|maskPattern].micro, formatInfoLengthBits)
+
+	return result
+}
+
+// NewMicro constructs a Micro QRCode of the smallest version able to hold the
+// content.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewMicro("ACK 42", qrcode.Low)
+//
+// The QRCode has Micro set and a VersionNumber of 1-4 for M1-M4. At the Low level
+// an M1 symbol, which only detects errors, holds numeric content of up to 5
+// digits.
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long, or an
+// error at the Highest level, which Micro QR Codes do not support.
+func NewMicro(content string, level RecoveryLevel) (*QRCode, error) {
+	if len(content) == 0 {
+		return nil, errors.New("no data to encode")
+	}
+
+	if level == Highest {
+		return nil, fmt.Errorf("invalid recovery level %d for a Micro QR Code (expected Low, Medium, or High)", level)
+	}
+
+	for _, v := range microVersions {
+		if v.level != level {
+			continue
+		}
+
+		encoder := newMicroDataEncoder(v.version)
+
+		// The modes of a version unsupported by the content fail to encode
+		encoded, err := encoder.encode([]byte(content))
+		if err != nil || encoded.Len() > v.numDataBits {
+			continue
+		}
+
+		q := &QRCode{
+			Content: content,
+
+			Level:         level,
+			VersionNumber: v.version,
+			Micro:         true,
+
+			ForegroundColor: color.Black,
+			BackgroundColor: color.White,
+
+			encoder: encoder,
+			data:    encoded,
+			micro:   &v,
+		}
+
+		return q, nil
+	}
+
+	return nil, fmt.Errorf("%w: no Micro QR Code holds %d bytes at level %d", ErrContentTooLong, len(content), level)
+}
+
+// NewSmallest constructs a Micro QRCode if the content fits in one at level, and
+// a QRCode of the smallest version able to hold it otherwise.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewSmallest("my content", qrcode.Medium)
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long.
+func NewSmallest(content string, level RecoveryLevel) (*QRCode, error) {
+	if q, err := NewMicro(content, level); err == nil {
+		return q, nil
+	}
+
+	return New(content, level)
+}
+
+// encodeMicro completes the steps required to encode a Micro QR Code: adding the
+// terminator bits and padding, applying the error correction, and selecting the
+// best data mask.
+func (q *QRCode) encodeMicro() {
+	v := q.micro
+
+	q.data.AppendNumBools(min(v.numTerminatorBits(), v.numDataBits-q.data.Len()), false)
+	q.addMicroPadding()
+
+	encoded := q.encodeMicroBlock()
+
+	const numMasks int = 4
+
+	score := 0
+
+	for mask := 0; mask < numMasks; mask++ {
+		if q.FastMask && mask != fastMicroMask {
+			continue
+		}
+
+		s := buildMicroSymbol(*v, mask, encoded, q.quietZoneSize())
+
+		numEmptyModules := s.numEmptyModules()
+		if numEmptyModules != 0 {
+			log.Panicf("bug: numEmptyModules is %d (expected 0) (version=M%d)", numEmptyModules, q.VersionNumber)
+		}
+
+		// Unlike QR Codes, the mask with the highest score is chosen.
+		p := microMaskScore(s)
+
+		if q.symbol == nil || p > score {
+			q.symbol = s
+			q.mask = mask
+			score = p
+		}
+	}
+}
+
+// addMicroPadding pads the encoded data of a Micro QR Code up to the full length
+// required.
+func (q *QRCode) addMicroPadding() {
+	numDataBits := q.micro.numDataBits
+
+	// Pad to the nearest codeword boundary, the last codeword of M1 and M3 being
+	// 4 bits long.
+	q.data.AppendNumBools(min((8-q.data.Len()%8)%8, numDataBits-q.data.Len()), false)
+
+	// Pad codewords 0b11101100 and 0b00010001.
+	padding := [2]*bitset.Bitset{
+		bitset.New(true, true, true, false, true, true, false, false),
+		bitset.New(false, false, false, true, false, false, false, true),
+	}
+
+	i := 0
+	for numDataBits-q.data.Len() >= 8 {
+		q.data.Append(padding[i])
+
+		i = 1 - i
+	}
+
+	// A 4-bit last codeword is padded with zeros.
+	q.data.AppendNumBools(numDataBits-q.data.Len(), false)
+}
+
+// encodeMicroBlock applies the error correction to the completed encoded data of
+// a Micro QR Code, and returns its final data sequence.
+//
+// The 4-bit last data codeword of M1 and M3 is extended with zeros to compute the
+// error correction codewords only.
+func (q *QRCode) encodeMicroBlock() *bitset.Bitset {
+	padded := bitset.Clone(q.data)
+	padded.AppendNumBools((8-padded.Len()%8)%8, false)
+
+	block := reedsolomon.Encode(padded, q.micro.numECCodewords)
+
+	result := bitset.Clone(q.data)
+	result.Append(block.Substr(padded.Len(), block.Len()))
+
+	return result
+}
@@ -0,0 +1,153 @@
+package qrcode
+
+import (
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+)
+
+type microSymbol struct {
+	version microVersion
+	mask    int
+
+	data *bitset.Bitset
+
+	symbol *symbol
+	size   int
+}
+
+// buildMicroSymbol builds a Micro QR Code symbol holding the final data sequence
+// data with the data mask mask, and a quiet zone of quietZoneSize modules.
+func buildMicroSymbol(version microVersion, mask int, data *bitset.Bitset, quietZoneSize int) *symbol {
+	m := &microSymbol{
+		version: version,
+		mask:    mask,
+		data:    data,
+
+		symbol: newSymbol(version.symbolSize(), quietZoneSize),
+		size:   version.symbolSize(),
+	}
+
+	m.addFinderPattern()
+	m.addTimingPatterns()
+	m.addFormatInfo()
+	m.addData()
+
+	return m.symbol
+}
+
+// addFinderPattern adds the single finder pattern in the top left corner, and
+// its separator below and to the right of it.
+func (m *microSymbol) addFinderPattern() {
+	m.symbol.set2dPattern(0, 0, finderPattern)
+	m.symbol.set2dPattern(0, finderPatternSize, finderPatternHorizontalBorder)
+	m.symbol.set2dPattern(finderPatternSize, 0, finderPatternVerticalBorder)
+}
+
+// addTimingPatterns adds the timing patterns along the top and left edges.
+func (m *microSymbol) addTimingPatterns() {
+	for i := finderPatternSize + 1; i < m.size; i++ {
+		m.symbol.set(i, 0, i%2 == 0)
+		m.symbol.set(0, i, i%2 == 0)
+	}
+}
+
+// addFormatInfo adds the format information along the separator.
+func (m *microSymbol) addFormatInfo() {
+	f := m.version.formatInfo(m.mask)
+
+	// Bits 14-7, right of the left edge, below the finder pattern.
+	for i := 1; i <= 8; i++ {
+		m.symbol.set(i, finderPatternSize+1, f.At(i-1))
+	}
+
+	// Bits 6-0, up the right of the finder pattern.
+	for i := 1; i <= 7; i++ {
+		m.symbol.set(finderPatternSize+1, i, f.At(formatInfoLengthBits-i))
+	}
+}
+
+// addData places the data in two module wide columns from the bottom right
+// corner, like in a QR Code but without a vertical timing pattern to skip.
+func (m *microSymbol) addData() {
+	xOffset := 1
+	dir := up
+
+	x := m.size - 2
+	y := m.size - 1
+
+	for i := 0; i < m.data.Len(); i++ {
+		m.symbol.set(x+xOffset, y, microMask(m.mask, x+xOffset, y) != m.data.At(i))
+
+		if i == m.data.Len()-1 {
+			break
+		}
+
+		// Find the next free bit in the symbol.
+		for {
+			if xOffset == 1 {
+				xOffset = 0
+			} else {
+				xOffset = 1
+
+				if dir == up {
+					if y > 0 {
+						y--
+					} else {
+						dir = down
+						x -= 2
+					}
+				} else {
+					if y < m.size-1 {
+						y++
+					} else {
+						dir = up
+						x -= 2
+					}
+				}
+			}
+
+			if m.symbol.empty(x+xOffset, y) {
+				break
+			}
+		}
+	}
+}
+
+// microMask returns whether the data mask mask inverts the module at (x, y). The
+// four masks of Micro QR Codes are the QR Code masks 1, 4, 6, and 7.
+func microMask(mask int, x int, y int) bool {
+	switch mask {
+	case 0:
+		return y%2 == 0
+	case 1:
+		return (y/2+x/3)%2 == 0
+	case 2:
+		return ((y*x)%2+(y*x)%3)%2 == 0
+	case 3:
+		return ((y+x)%2+(y*x)%3)%2 == 0
+	}
+
+	return false
+}
+
+// microMaskScore returns the score of a masked Micro QR Code symbol from the dark
+// modules along its right and bottom edges, the higher the better.
+func microMaskScore(m *symbol) int {
+	sum1 := 0
+	sum2 := 0
+
+	for i := 1; i < m.symbolSize; i++ {
+		if m.get(m.symbolSize-1, i) {
+			sum1++
+		}
+
+		if m.get(i, m.symbolSize-1) {
+			sum2++
+		}
+	}
+
+	if sum1 > sum2 {
+		sum1, sum2 = sum2, sum1
+	}
+
+	return sum1*16 + sum2
+}
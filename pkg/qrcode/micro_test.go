@@ -0,0 +1,190 @@
+package qrcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+)
+
+func TestNewMicroEncoding(t *testing.T) {
+	// The M2-L example of ISO/IEC 18004 annex I.
+	q, err := NewMicro("01234567", Low)
+	if err != nil {
+		t.Fatalf("NewMicro got %s expected success", err.Error())
+	}
+
+	if !q.Micro || q.VersionNumber != 2 {
+		t.Fatalf("NewMicro got version %d (micro %v), expected M2", q.VersionNumber, q.Micro)
+	}
+
+	q.Bitmap()
+
+	data := bitset.NewFromBase2String("01000000 00011000 10101100 11000011 00000000")
+	if !q.data.Equals(data) {
+		t.Errorf("NewMicro data got %s, expected %s", q.data, data)
+	}
+
+	ec := bitset.NewFromBase2String("10000110 00001101 00100010 10101110 00110000")
+	if encoded := q.encodeMicroBlock(); !encoded.Substr(data.Len(), encoded.Len()).Equals(ec) {
+		t.Errorf("NewMicro error correction got %s, expected %s", encoded.Substr(data.Len(), encoded.Len()), ec)
+	}
+}
+
+func TestNewMicroVersion(t *testing.T) {
+	tests := []struct {
+		content string
+		level   RecoveryLevel
+		version int
+	}{
+		{"12345", Low, 1},
+		{"123456", Low, 2},
+		{"ABC", Low, 2},
+		{"ABC", Medium, 2},
+		{"abc", Low, 3},
+		{"abcdefghij", Medium, 4},
+		{"abcdefghijklmno", Low, 4},
+		{"1", High, 4},
+	}
+
+	for _, test := range tests {
+		q, err := NewMicro(test.content, test.level)
+		if err != nil {
+			t.Errorf("NewMicro(%q, %d) got %s expected success", test.content, test.level, err.Error())
+
+			continue
+		}
+
+		if q.VersionNumber != test.version {
+			t.Errorf("NewMicro(%q, %d) got M%d, expected M%d", test.content, test.level, q.VersionNumber, test.version)
+		}
+	}
+
+	if _, err := NewMicro("abcdefghijklmnop", Low); !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("NewMicro of 16 bytes got %v, expected ErrContentTooLong", err)
+	}
+
+	if _, err := NewMicro("1", Highest); err == nil || errors.Is(err, ErrContentTooLong) {
+		t.Errorf("NewMicro at Highest got %v, expected invalid level", err)
+	}
+
+	if _, err := NewMicro("", Low); err == nil {
+		t.Error("NewMicro of no content succeeded, expected error")
+	}
+}
+
+func TestMicroSymbol(t *testing.T) {
+	contents := []string{"12345", "HELLO", "ack 7", "01234567", "(a*b)", "abcdefghij", "NO 42"}
+
+	for _, content := range contents {
+		for _, level := range []RecoveryLevel{Low, Medium, High} {
+			q, err := NewMicro(content, level)
+			if err != nil {
+				continue
+			}
+
+			q.DisableBorder = true
+			bitmap := q.Bitmap()
+
+			size := 9 + 2*q.VersionNumber
+			if len(bitmap) != size {
+				t.Fatalf("%q at level %d got %d modules, expected %d", content, level, len(bitmap), size)
+			}
+
+			// Format information, bits 14-7 along row 8 then 6-0 up column 8
+			format := bitset.New()
+			for x := 1; x <= 8; x++ {
+				format.AppendBools(bitmap[8][x])
+			}
+
+			for y := 7; y >= 1; y-- {
+				format.AppendBools(bitmap[y][8])
+			}
+
+			if expected := q.micro.formatInfo(q.mask); !format.Equals(expected) {
+				t.Errorf("%q at level %d got format %s, expected %s", content, level, format, expected)
+			}
+
+			data := readMicroData(bitmap, q.mask)
+			if expected := q.encodeMicroBlock(); !data.Equals(expected) {
+				t.Errorf("%q at level %d got data %s, expected %s", content, level, data, expected)
+			}
+		}
+	}
+}
+
+// readMicroData reads the unmasked modules of a Micro QR Code symbol in
+// placement order.
+func readMicroData(bitmap [][]bool, mask int) *bitset.Bitset {
+	size := len(bitmap)
+	data := bitset.New()
+
+	upward := true
+
+	for right := size - 1; right >= 1; right -= 2 {
+		for i := 0; i < size; i++ {
+			y := i
+			if upward {
+				y = size - 1 - i
+			}
+
+			for _, x := range []int{right, right - 1} {
+				// Timing patterns, finder pattern, separator, and format information
+				if x == 0 || y == 0 || (x <= 8 && y <= 8) {
+					continue
+				}
+
+				data.AppendBools(bitmap[y][x] != microMask(mask, x, y))
+			}
+		}
+
+		upward = !upward
+	}
+
+	return data
+}
+
+func TestMicroMaskScore(t *testing.T) {
+	q, err := NewMicro("HELLO", Low)
+	if err != nil {
+		t.Fatalf("NewMicro got %s expected success", err.Error())
+	}
+
+	q.Bitmap()
+	encoded := q.encodeMicroBlock()
+
+	best := microMaskScore(q.symbol)
+
+	for mask := 0; mask < 4; mask++ {
+		if score := microMaskScore(buildMicroSymbol(*q.micro, mask, encoded, q.micro.quietZoneSize())); score > best {
+			t.Errorf("mask %d scores %d, above the chosen mask %d scoring %d", mask, score, q.mask, best)
+		}
+	}
+}
+
+func TestNewSmallest(t *testing.T) {
+	q, err := NewSmallest("ACK 42", Medium)
+	if err != nil {
+		t.Fatalf("NewSmallest got %s expected success", err.Error())
+	}
+
+	if !q.Micro {
+		t.Errorf("NewSmallest of a short content got version %d, expected a Micro QR Code", q.VersionNumber)
+	}
+
+	long := strings.Repeat("content too long for a Micro QR Code ", 3)
+
+	q, err = NewSmallest(long, Medium)
+	if err != nil {
+		t.Fatalf("NewSmallest got %s expected success", err.Error())
+	}
+
+	if q.Micro {
+		t.Errorf("NewSmallest of %d bytes got M%d, expected a QR Code", len(long), q.VersionNumber)
+	}
+
+	if q, err = NewSmallest("1", Highest); err != nil || q.Micro {
+		t.Errorf("NewSmallest at Highest got %v, expected a QR Code", err)
+	}
+}
@@ -0,0 +1,210 @@
+package qrcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	// DefaultLogoSize is the width of a logo as a fraction of the width of the
+	// symbol, unless set otherwise.
+	DefaultLogoSize = 0.2
+
+	// MaxLogoSize is the widest logo, as a fraction of the width of the symbol. A
+	// logo hiding more modules leaves too few for the error correction to recover.
+	MaxLogoSize = 0.35
+)
+
+// Data masks applied with FastMask. Mask 4, of alternating blocks of 3x2
+// modules, scores the lowest penalty on average over random data, and its
+// pattern is mask 1 of Micro QR Codes.
+const (
+	fastMask      = 4
+	fastMicroMask = 1
+)
+
+// recoveryCapacity is the fraction of the codewords each recovery level recovers.
+var recoveryCapacity = map[RecoveryLevel]float64{
+	Low:     0.07,
+	Medium:  0.15,
+	High:    0.25,
+	Highest: 0.30,
+}
+
+// Options holds the drawing options of a QR Code, for branded QR Codes or
+// displays of their own, e.g. light modules on a dark screen.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewWithOptions("my content", qrcode.Medium, qrcode.Options{
+//		ForegroundColor: color.White,
+//		BackgroundColor: color.Black,
+//		Logo:            logo,
+//	})
+//
+// Readers look for dark modules on a light background: many, though not all,
+// also read inverted QR Codes.
+type Options struct {
+	// Colors of the modules and the background, black and white if nil.
+	ForegroundColor color.Color
+	BackgroundColor color.Color
+
+	// Width of the border in modules, the standard quiet zone if 0.
+	QuietZone int
+
+	// Image drawn over the center of the QR Code, nil for none. The image is
+	// scaled to fit a square of LogoSize, over the background color.
+	Logo image.Image
+
+	// Width of the logo as a fraction of the width of the symbol, DefaultLogoSize
+	// if 0, up to MaxLogoSize.
+	LogoSize float64
+
+	// Apply a fixed data mask to the symbol rather than the one of the lowest
+	// penalty. The symbol is built once rather than for each of the eight masks,
+	// in about a quarter of the encoding time, for bulk generation of QR Codes of
+	// compressed or random data, which every mask suits much the same. The fixed
+	// mask may leave runs or blocks of modules hard to read in structured content.
+	FastMask bool
+}
+
+// Validate returns an error if the options are invalid.
+func (o Options) Validate() error {
+	if o.QuietZone < 0 {
+		return fmt.Errorf("invalid quiet zone of %d modules (expected 0 or more)", o.QuietZone)
+	}
+
+	if o.LogoSize < 0 || o.LogoSize > MaxLogoSize {
+		return fmt.Errorf("invalid logo size %g (expected 0-%g inclusive)", o.LogoSize, MaxLogoSize)
+	}
+
+	return nil
+}
+
+// RecoveryLevel returns the lowest recovery level, level or higher, which
+// recovers the modules hidden by the logo. The level is unchanged without logo.
+//
+// A logo hides the modules of a square of LogoSize squared of the symbol, and
+// partly covers the codewords along its edges, so the level must recover twice as
+// many codewords.
+func (o Options) RecoveryLevel(level RecoveryLevel) RecoveryLevel {
+	if o.Logo == nil {
+		return level
+	}
+
+	size := logoSize(o.LogoSize)
+	hidden := 2 * size * size
+
+	for ; level < Highest; level++ {
+		if recoveryCapacity[level] >= hidden {
+			break
+		}
+	}
+
+	return level
+}
+
+// NewWithOptions constructs a QRCode drawn with the options o, at the recovery
+// level required by its logo if higher than level, see Options.RecoveryLevel.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewWithOptions("my content", qrcode.Medium, qrcode.Options{QuietZone: 2})
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long, or an
+// error in case of invalid options.
+func NewWithOptions(content string, level RecoveryLevel, o Options) (*QRCode, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	q, err := New(content, o.RecoveryLevel(level))
+	if err != nil {
+		return nil, err
+	}
+
+	q.SetOptions(o)
+
+	return q, nil
+}
+
+// SetOptions sets the drawing options of the QR Code. The recovery level of the
+// QR Code is unchanged: one with a logo should be constructed at the level
+// returned by Options.RecoveryLevel.
+func (q *QRCode) SetOptions(o Options) {
+	if o.ForegroundColor != nil {
+		q.ForegroundColor = o.ForegroundColor
+	}
+
+	if o.BackgroundColor != nil {
+		q.BackgroundColor = o.BackgroundColor
+	}
+
+	q.QuietZone = o.QuietZone
+	q.Logo = o.Logo
+	q.LogoSize = o.LogoSize
+	q.FastMask = o.FastMask
+}
+
+// logoSize returns the width of a logo of size as a fraction of the width of the
+// symbol.
+func logoSize(size float64) float64 {
+	if size == 0 {
+		return DefaultLogoSize
+	}
+
+	return size
+}
+
+// logoRect returns the square the logo is drawn in, in an image of the QR Code
+// of size pixels.
+func (q *QRCode) logoRect(size int) image.Rectangle {
+	width := int(logoSize(q.LogoSize) * float64(q.symbol.symbolSize) * float64(size) / float64(q.symbol.size))
+	start := (size - width) / 2
+
+	return image.Rect(start, start, start+width, start+width)
+}
+
+// drawLogo returns img, the image of the QR Code, with the logo drawn over its
+// center.
+func (q *QRCode) drawLogo(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	out := image.NewNRGBA(bounds)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	rect := q.logoRect(bounds.Dx())
+	draw.Draw(out, rect, &image.Uniform{C: q.BackgroundColor}, image.Point{}, draw.Src)
+
+	logo := scaleToFit(q.Logo, rect.Dx(), rect.Dy())
+	offset := image.Pt((rect.Dx()-logo.Bounds().Dx())/2, (rect.Dy()-logo.Bounds().Dy())/2)
+	draw.Draw(out, logo.Bounds().Add(rect.Min).Add(offset), logo, image.Point{}, draw.Over)
+
+	return out
+}
+
+// scaleToFit returns img scaled to fit in width by height pixels, keeping its
+// aspect ratio, with the nearest neighbor of every pixel.
+func scaleToFit(img image.Image, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	if bounds.Empty() || width <= 0 || height <= 0 {
+		return image.NewNRGBA(image.Rectangle{})
+	}
+
+	if bounds.Dx()*height > bounds.Dy()*width {
+		height = max(1, bounds.Dy()*width/bounds.Dx())
+	} else {
+		width = max(1, bounds.Dx()*height/bounds.Dy())
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y2 := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			out.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/width, y2))
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,191 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testLogo returns a red logo of width by height pixels.
+func testLogo(width, height int) image.Image {
+	logo := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			logo.Set(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+
+	return logo
+}
+
+func TestOptionsRecoveryLevel(t *testing.T) {
+	logo := testLogo(4, 4)
+
+	tests := []struct {
+		options Options
+		level   RecoveryLevel
+		want    RecoveryLevel
+	}{
+		{Options{}, Low, Low},
+		{Options{QuietZone: 2}, Medium, Medium},
+		{Options{Logo: logo}, Low, Medium},
+		{Options{Logo: logo}, High, High},
+		{Options{Logo: logo, LogoSize: 0.1}, Low, Low},
+		{Options{Logo: logo, LogoSize: 0.3}, Medium, High},
+		{Options{Logo: logo, LogoSize: MaxLogoSize}, Low, High},
+		{Options{Logo: logo, LogoSize: MaxLogoSize}, Highest, Highest},
+	}
+
+	for _, test := range tests {
+		if got := test.options.RecoveryLevel(test.level); got != test.want {
+			t.Errorf("RecoveryLevel(%d) with logo size %g got %d, expected %d", test.level, test.options.LogoSize, got, test.want)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, o := range []Options{{QuietZone: -1}, {LogoSize: -0.1}, {LogoSize: MaxLogoSize + 0.01}} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, expected error", o)
+		}
+
+		if _, err := NewWithOptions("my content", Medium, o); err == nil {
+			t.Errorf("NewWithOptions(%+v) succeeded, expected error", o)
+		}
+	}
+
+	if err := (Options{QuietZone: 1, LogoSize: MaxLogoSize}).Validate(); err != nil {
+		t.Errorf("Validate got %s expected success", err.Error())
+	}
+}
+
+func TestQuietZone(t *testing.T) {
+	q, err := New("1", Low)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	if size := len(q.Bitmap()); size != 21+2*4 {
+		t.Errorf("Bitmap got %d modules, expected the standard quiet zone", size)
+	}
+
+	q.SetOptions(Options{QuietZone: 1})
+
+	if size := len(q.Bitmap()); size != 21+2*1 {
+		t.Errorf("Bitmap with a quiet zone of 1 module got %d modules, expected %d", size, 23)
+	}
+
+	q.DisableBorder = true
+
+	if size := len(q.Bitmap()); size != 21 {
+		t.Errorf("Bitmap without border got %d modules, expected %d", size, 21)
+	}
+
+	m, err := NewMicro("1", Low)
+	if err != nil {
+		t.Fatalf("NewMicro got %s expected success", err.Error())
+	}
+
+	if size := len(m.Bitmap()); size != 11+2*2 {
+		t.Errorf("Micro Bitmap got %d modules, expected the quiet zone of 2 modules", size)
+	}
+}
+
+func TestLogo(t *testing.T) {
+	bg := color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}
+
+	q, err := NewWithOptions("https://example.com/branded", Low, Options{
+		ForegroundColor: color.White,
+		BackgroundColor: bg,
+		Logo:            testLogo(20, 10),
+	})
+	if err != nil {
+		t.Fatalf("NewWithOptions got %s expected success", err.Error())
+	}
+
+	if q.Level != Medium {
+		t.Errorf("NewWithOptions with a logo got level %d, expected %d", q.Level, Medium)
+	}
+
+	img, ok := q.Image(-4).(*image.NRGBA)
+	if !ok {
+		t.Fatalf("Image with a logo got %T, expected *image.NRGBA", q.Image(-4))
+	}
+
+	size := img.Bounds().Dx()
+
+	if got := img.NRGBAAt(size/2, size/2); got != (color.NRGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("center got %v, expected the logo", got)
+	}
+
+	// The wide logo leaves the background above and below it
+	rect := q.logoRect(size)
+	if got := color.RGBAModel.Convert(img.At(size/2, rect.Min.Y+1)); got != bg {
+		t.Errorf("top of the logo square got %v, expected the background %v", got, bg)
+	}
+
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != bg {
+		t.Errorf("corner got %v, expected the background %v", got, bg)
+	}
+
+	svg := q.SVG(-4)
+	if !bytes.Contains(svg, []byte(`<image `)) || !bytes.Contains(svg, []byte(`fill="#102030"`)) {
+		t.Error("SVG lacks the logo or the background color")
+	}
+}
+
+func TestScaleToFit(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          image.Point
+	}{
+		{20, 10, image.Pt(8, 4)},
+		{10, 20, image.Pt(4, 8)},
+		{8, 8, image.Pt(8, 8)},
+		{1, 100, image.Pt(1, 8)},
+	}
+
+	for _, test := range tests {
+		if got := scaleToFit(testLogo(test.width, test.height), 8, 8).Bounds().Size(); got != test.want {
+			t.Errorf("scaleToFit of %dx%d got %v, expected %v", test.width, test.height, got, test.want)
+		}
+	}
+}
+
+func TestFastMask(t *testing.T) {
+	q, err := NewWithOptions(strings.Repeat("my content ", 20), Medium, Options{FastMask: true})
+	if err != nil {
+		t.Fatalf("NewWithOptions got %s expected success", err.Error())
+	}
+
+	q.Bitmap()
+
+	if q.mask != fastMask {
+		t.Errorf("mask got %d, expected %d", q.mask, fastMask)
+	}
+
+	m, err := NewMicro("ACK 42", Low)
+	if err != nil {
+		t.Fatalf("NewMicro got %s expected success", err.Error())
+	}
+
+	m.SetOptions(Options{FastMask: true})
+	m.Bitmap()
+
+	if m.mask != fastMicroMask {
+		t.Errorf("Micro mask got %d, expected %d", m.mask, fastMicroMask)
+	}
+
+	// The symbol is the one evaluated among the others without FastMask
+	s, err := buildRegularSymbol(q.version, fastMask, q.encodeBlocks(), q.quietZoneSize())
+	if err != nil {
+		t.Fatalf("buildRegularSymbol got %s expected success", err.Error())
+	}
+
+	if !reflect.DeepEqual(s.bitmap(), q.Bitmap()) {
+		t.Error("Bitmap with FastMask differs from the symbol of the fixed mask")
+	}
+}
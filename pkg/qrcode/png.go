@@ -0,0 +1,154 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"math"
+	"os"
+)
+
+// WriteFileOptions holds the options of the PNG images written by
+// WriteWithOptions and WriteFileWithOptions.
+//
+//	var q *qrcode.QRCode
+//	o := qrcode.DefaultWriteFileOptions()
+//	o.DPI = 300
+//	err := q.WriteFileWithOptions(-10, "qr.png", o)
+//
+// A printed QR Code of size pixels is size/DPI inches wide.
+type WriteFileOptions struct {
+	// Compression of the image, see png.Encoder. Write uses png.BestCompression:
+	// png.BestSpeed encodes faster, for bulk generation, into larger files.
+	CompressionLevel png.CompressionLevel
+
+	// Resolution of the image in dots per inch, recorded in a pHYs chunk so that
+	// printing and layout programs size the image physically, none if 0.
+	DPI int
+}
+
+// DefaultWriteFileOptions returns the options Write and WriteFile use: the best
+// compression, and no resolution.
+func DefaultWriteFileOptions() WriteFileOptions {
+	return WriteFileOptions{CompressionLevel: png.BestCompression}
+}
+
+// Validate returns an error if the options are invalid.
+func (o WriteFileOptions) Validate() error {
+	if o.CompressionLevel < png.BestCompression || o.CompressionLevel > png.DefaultCompression {
+		return fmt.Errorf("invalid PNG compression level %d", o.CompressionLevel)
+	}
+
+	if o.DPI < 0 || o.DPI > maxDPI {
+		return fmt.Errorf("invalid resolution of %d DPI (expected 0-%d inclusive)", o.DPI, maxDPI)
+	}
+
+	return nil
+}
+
+// maxDPI is the highest resolution a pHYs chunk holds, in pixels per meter below
+// 2^31.
+const maxDPI = 1000000
+
+// EncodePNG writes img as a PNG image with the options o to out, e.g. an image of
+// a QR Code composited with a caption.
+func EncodePNG(out io.Writer, img image.Image, o WriteFileOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	encoder := png.Encoder{CompressionLevel: o.CompressionLevel}
+
+	if o.DPI > 0 {
+		out = &physWriter{w: out, chunk: physChunk(o.DPI)}
+	}
+
+	if err := encoder.Encode(out, img); err != nil {
+		return fmt.Errorf("png.Encode: %w", err)
+	}
+
+	return nil
+}
+
+// WriteWithOptions writes the QR Code as a PNG image with the options o to out,
+// like Write.
+func (q *QRCode) WriteWithOptions(size int, out io.Writer, o WriteFileOptions) error {
+	return EncodePNG(out, q.Image(size), o)
+}
+
+// WriteFileWithOptions writes the QR Code as a PNG image with the options o to
+// the specified file, like WriteFile.
+func (q *QRCode) WriteFileWithOptions(size int, filename string, o WriteFileOptions) error {
+	var b bytes.Buffer
+	if err := q.WriteWithOptions(size, &b, o); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filename, b.Bytes(), os.FileMode(0644)); err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
+
+// physChunk returns the pHYs chunk of a resolution of dpi dots per inch, in
+// pixels per meter in both directions.
+func physChunk(dpi int) []byte {
+	ppm := uint32(math.Round(float64(dpi) / 0.0254))
+
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:], 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1 // The unit is the meter
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	return chunk
+}
+
+// pngHeaderSize is the length of the PNG signature and the IHDR chunk, which
+// png.Encoder writes first.
+const pngHeaderSize = 8 + 4 + 4 + 13 + 4
+
+// physWriter inserts a pHYs chunk after the IHDR chunk of the PNG image written
+// through it, where the chunk goes before the image data.
+type physWriter struct {
+	w       io.Writer
+	chunk   []byte
+	written int
+}
+
+// Write writes p to the underlying writer, with the chunk once the header is
+// written.
+func (w *physWriter) Write(p []byte) (int, error) {
+	n := 0
+
+	if w.written < pngHeaderSize && w.written+len(p) >= pngHeaderSize {
+		head := pngHeaderSize - w.written
+
+		m, err := w.w.Write(p[:head])
+		n += m
+		w.written += m
+
+		if err != nil {
+			return n, err
+		}
+
+		if _, err := w.w.Write(w.chunk); err != nil {
+			return n, err
+		}
+
+		p = p[head:]
+	}
+
+	m, err := w.w.Write(p)
+	n += m
+	w.written += m
+
+	return n, err
+}
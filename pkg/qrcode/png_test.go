@@ -0,0 +1,115 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// pngChunks returns the types and data of the chunks of the PNG image p.
+func pngChunks(t *testing.T, p []byte) ([]string, map[string][]byte) {
+	t.Helper()
+
+	var types []string
+
+	data := make(map[string][]byte)
+
+	for p = p[8:]; len(p) >= 12; {
+		n := int(binary.BigEndian.Uint32(p))
+		if len(p) < 12+n {
+			t.Fatalf("truncated %s chunk", p[4:8])
+		}
+
+		types = append(types, string(p[4:8]))
+		data[string(p[4:8])] = p[8 : 8+n]
+		p = p[12+n:]
+	}
+
+	return types, data
+}
+
+func TestWriteWithOptions(t *testing.T) {
+	q, err := New("https://example.org", Medium)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	var b bytes.Buffer
+	if err := q.WriteWithOptions(256, &b, WriteFileOptions{CompressionLevel: png.BestSpeed, DPI: 300}); err != nil {
+		t.Fatalf("WriteWithOptions got %s expected success", err.Error())
+	}
+
+	types, data := pngChunks(t, b.Bytes())
+	if len(types) < 3 || types[0] != "IHDR" || types[1] != "pHYs" {
+		t.Fatalf("chunks got %v, expected IHDR then pHYs", types)
+	}
+
+	// 300 DPI is 11811 pixels per meter
+	phys := data["pHYs"]
+	if x, y := binary.BigEndian.Uint32(phys), binary.BigEndian.Uint32(phys[4:]); x != 11811 || y != 11811 || phys[8] != 1 {
+		t.Errorf("pHYs got %d x %d pixels per unit %d, expected 11811 pixels per meter", x, y, phys[8])
+	}
+
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("png.Decode got %s expected success", err.Error())
+	}
+
+	if img.Bounds().Dx() != 256 {
+		t.Errorf("image got %d pixels wide, expected 256", img.Bounds().Dx())
+	}
+
+	// Without a resolution, the image is the one Write encodes
+	p, err := q.PNG(256)
+	if err != nil {
+		t.Fatalf("PNG got %s expected success", err.Error())
+	}
+
+	if types, _ := pngChunks(t, p); len(types) > 1 && types[1] == "pHYs" {
+		t.Error("PNG recorded a resolution, expected none")
+	}
+
+	var uncompressed bytes.Buffer
+	if err := q.WriteWithOptions(256, &uncompressed, WriteFileOptions{CompressionLevel: png.NoCompression}); err != nil {
+		t.Fatalf("WriteWithOptions got %s expected success", err.Error())
+	}
+
+	if uncompressed.Len() <= len(p) {
+		t.Errorf("uncompressed image got %d bytes, expected more than the %d of the best compression", uncompressed.Len(), len(p))
+	}
+}
+
+func TestWriteFileOptionsValidate(t *testing.T) {
+	for _, o := range []WriteFileOptions{{DPI: -1}, {DPI: maxDPI + 1}, {CompressionLevel: png.BestCompression - 1}, {CompressionLevel: 1}} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, expected error", o)
+		}
+	}
+
+	q, err := New("my content", Medium)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	filename := filepath.Join(t.TempDir(), "qr.png")
+
+	if err := q.WriteFileWithOptions(-4, filename, WriteFileOptions{DPI: -1}); err == nil {
+		t.Error("WriteFileWithOptions with an invalid resolution succeeded, expected error")
+	}
+
+	if err := q.WriteFileWithOptions(-4, filename, WriteFileOptions{DPI: 600}); err != nil {
+		t.Fatalf("WriteFileWithOptions got %s expected success", err.Error())
+	}
+
+	p, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, data := pngChunks(t, p); binary.BigEndian.Uint32(data["pHYs"]) != 23622 {
+		t.Errorf("pHYs got %v, expected 23622 pixels per meter", data["pHYs"])
+	}
+}
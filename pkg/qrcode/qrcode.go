@@ -54,15 +54,17 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"io"
 	"log"
-	"os"
 
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/reedsolomon"
 )
 
+// ErrContentTooLong is returned when the content does not fit in a QR Code of any
+// version at the requested recovery level.
+var ErrContentTooLong = errors.New("content too long to encode")
+
 // Encode a QR Code and return a raw PNG image.
 //
 // size is both the image width and height in pixels. If the size is too small, then
@@ -136,6 +138,13 @@ type QRCode struct {
 	Level         RecoveryLevel
 	VersionNumber int
 
+	// Micro QR Code, whose VersionNumber 1-4 stands for M1-M4, see NewMicro.
+	Micro bool
+
+	// Structured append header, nil unless the QR Code holds part of a message
+	// split across several QR Codes.
+	StructuredAppend *StructuredAppend
+
 	// User settable drawing options.
 	ForegroundColor color.Color
 	BackgroundColor color.Color
@@ -143,8 +152,28 @@ type QRCode struct {
 	// Disable the QR Code border.
 	DisableBorder bool
 
+	// Width of the border in modules, the standard quiet zone of 4 modules, or 2
+	// for a Micro QR Code, if 0. DisableBorder overrides it.
+	QuietZone int
+
+	// Image drawn over the center of the QR Code, nil for none. The QR Code must
+	// have enough error correction to recover the modules it hides, see Options.
+	Logo image.Image
+
+	// Width of the logo as a fraction of the width of the symbol, DefaultLogoSize
+	// if 0.
+	LogoSize float64
+
+	// Apply a fixed data mask rather than evaluating the penalty of every mask,
+	// see Options.
+	FastMask bool
+
 	encoder *dataEncoder
 	version qrCodeVersion
+	micro   *microVersion
+
+	// Encodes the data with the encoder of a version, see SetVersion.
+	encodeData func(*dataEncoder) (*bitset.Bitset, error)
 
 	data   *bitset.Bitset
 	symbol *symbol
@@ -156,8 +185,32 @@ type QRCode struct {
 //	var q *qrcode.QRCode
 //	q, err := qrcode.New("my content", qrcode.Medium)
 //
-// An error occurs if the content is too long.
+// An error wrapping ErrContentTooLong occurs if the content is too long.
 func New(content string, level RecoveryLevel) (*QRCode, error) {
+	return newQRCode(content, level, func(encoder *dataEncoder) (*bitset.Bitset, error) {
+		return encoder.encode([]byte(content))
+	})
+}
+
+// NewBytes constructs a QRCode holding data in a single byte mode segment.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewBytes([]byte{0x00, 0xff, 0x10}, qrcode.Medium)
+//
+// Unlike New, the data is never split into numeric or alphanumeric segments, so
+// binary content is stored verbatim and decoders report it as a single byte
+// segment. The Content field holds the data converted to a string.
+//
+// An error wrapping ErrContentTooLong occurs if the data is too long.
+func NewBytes(data []byte, level RecoveryLevel) (*QRCode, error) {
+	return newQRCode(string(data), level, func(encoder *dataEncoder) (*bitset.Bitset, error) {
+		return encoder.encodeBytes(data)
+	})
+}
+
+// newQRCode constructs a QRCode of the smallest version able to hold the data
+// produced by encode.
+func newQRCode(content string, level RecoveryLevel, encode func(*dataEncoder) (*bitset.Bitset, error)) (*QRCode, error) {
 	encoders := []dataEncoderType{dataEncoderType1To9, dataEncoderType10To26,
 		dataEncoderType27To40}
 
@@ -170,7 +223,7 @@ func New(content string, level RecoveryLevel) (*QRCode, error) {
 
 	for _, t := range encoders {
 		encoder = newDataEncoder(t)
-		encoded, err = encoder.encode([]byte(content))
+		encoded, err = encode(encoder)
 
 		if err != nil {
 			continue
@@ -186,7 +239,7 @@ func New(content string, level RecoveryLevel) (*QRCode, error) {
 	if err != nil {
 		return nil, err
 	} else if chosenVersion == nil {
-		return nil, errors.New("content too long to encode")
+		return nil, ErrContentTooLong
 	}
 
 	q := &QRCode{
@@ -201,17 +254,82 @@ func New(content string, level RecoveryLevel) (*QRCode, error) {
 		encoder: encoder,
 		data:    encoded,
 		version: *chosenVersion,
+
+		encodeData: encode,
+	}
+
+	return q, nil
+}
+
+// NewWithVersion constructs a QRCode of a specific version, e.g. to draw a
+// sequence of QR Codes at the same size whatever the length of their content.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewWithVersion("my content", qrcode.Medium, 25)
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long for the
+// version, or an error in case of an invalid version.
+func NewWithVersion(content string, level RecoveryLevel, version int) (*QRCode, error) {
+	q, err := New(content, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.SetVersion(version); err != nil {
+		return nil, err
 	}
 
 	return q, nil
 }
 
+// SetVersion encodes the QR Code again as a QR Code of version, keeping its
+// content, recovery level, and drawing options. Versions above the smallest able
+// to hold the content are padded.
+//
+// An error wrapping ErrContentTooLong occurs if the content is too long for the
+// version, or an error in case of an invalid version or a Micro QR Code.
+func (q *QRCode) SetVersion(version int) error {
+	if q.micro != nil {
+		return errors.New("cannot set the version of a Micro QR Code")
+	}
+
+	if version < 1 || version > 40 {
+		return fmt.Errorf("invalid version %d (expected 1-40 inclusive)", version)
+	}
+
+	if q.encodeData == nil {
+		return errors.New("cannot set the version of a QR Code of unknown data")
+	}
+
+	encoder := newVersionDataEncoder(version)
+
+	encoded, err := q.encodeData(encoder)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrContentTooLong, err)
+	}
+
+	v := getQRCodeVersion(q.Level, version)
+	if encoded.Len() > v.numDataBits() {
+		return fmt.Errorf("%w: encoded length of %d bits exceeds the %d bits of version %d",
+			ErrContentTooLong, encoded.Len(), v.numDataBits(), version)
+	}
+
+	q.VersionNumber = version
+	q.version = *v
+	q.encoder = encoder
+	q.data = encoded
+	q.symbol = nil
+
+	return nil
+}
+
 // NewWithForcedVersion constructs a QRCode of a specific version.
 //
 //	var q *qrcode.QRCode
 //	q, err := qrcode.NewWithForcedVersion("my content", 25, qrcode.Medium)
 //
-// An error occurs in case of an invalid version.
+// An error occurs in case of an invalid version. Unlike NewWithVersion, the error
+// of a content too long for the version does not wrap ErrContentTooLong.
 func NewWithForcedVersion(content string, version int, level RecoveryLevel) (*QRCode, error) {
 	var encoder *dataEncoder
 
@@ -258,6 +376,10 @@ func NewWithForcedVersion(content string, version int, level RecoveryLevel) (*QR
 		encoder: encoder,
 		data:    encoded,
 		version: *chosenVersion,
+
+		encodeData: func(encoder *dataEncoder) (*bitset.Bitset, error) {
+			return encoder.encode([]byte(content))
+		},
 	}
 
 	return q, nil
@@ -289,6 +411,9 @@ func (q *QRCode) Bitmap() [][]bool {
 // returned is the minimum size required for the QR Code. Choose a larger
 // negative number to increase the scale of the image. e.g. a size of -5 causes
 // each module (QR Code "pixel") to be 5px in size.
+//
+// The image of a QR Code with a Logo is an *image.NRGBA, of other QR Codes an
+// *image.Paletted of the foreground and background colors.
 func (q *QRCode) Image(size int) image.Image {
 	// Build QR code.
 	q.encode()
@@ -334,6 +459,10 @@ func (q *QRCode) Image(size int) image.Image {
 		}
 	}
 
+	if q.Logo != nil {
+		return q.drawLogo(img)
+	}
+
 	return img
 }
 
@@ -344,13 +473,9 @@ func (q *QRCode) Image(size int) image.Image {
 //
 //	variable-sized image to be returned: See the documentation for Image().
 func (q *QRCode) PNG(size int) ([]byte, error) {
-	img := q.Image(size)
-
-	encoder := png.Encoder{CompressionLevel: png.BestCompression}
-
 	var b bytes.Buffer
-	if err := encoder.Encode(&b, img); err != nil {
-		return nil, fmt.Errorf("png.Encode: %w", err)
+	if err := q.Write(size, &b); err != nil {
+		return nil, err
 	}
 
 	return b.Bytes(), nil
@@ -362,20 +487,13 @@ func (q *QRCode) PNG(size int) ([]byte, error) {
 // a larger image is silently written. Negative values for size cause a
 //
 //	variable-sized image to be written: See the documentation for Image().
+//
+// The image is encoded straight into out, e.g. an HTTP response or an archive
+// entry, without buffering the PNG file. To composite the QR Code into a larger
+// image, such as a printed sheet or a video frame, draw the image returned by
+// Image instead. See WriteWithOptions for the compression and resolution.
 func (q *QRCode) Write(size int, out io.Writer) error {
-	var p []byte
-
-	p, err := q.PNG(size)
-
-	if err != nil {
-		return err
-	}
-
-	if _, err = out.Write(p); err != nil {
-		return fmt.Errorf("io.Writer.Write: %w", err)
-	}
-
-	return nil
+	return q.WriteWithOptions(size, out, DefaultWriteFileOptions())
 }
 
 // WriteFile writes the QR Code as a PNG image to the specified file.
@@ -384,25 +502,22 @@ func (q *QRCode) Write(size int, out io.Writer) error {
 // a larger image is silently written. Negative values for size cause a
 // variable sized image to be written: See the documentation for Image().
 func (q *QRCode) WriteFile(size int, filename string) error {
-	var p []byte
-
-	p, err := q.PNG(size)
-
-	if err != nil {
-		return err
-	}
-
-	if err := os.WriteFile(filename, p, os.FileMode(0644)); err != nil {
-		return fmt.Errorf("os.WriteFile: %w", err)
-	}
-
-	return nil
+	return q.WriteFileWithOptions(size, filename, DefaultWriteFileOptions())
 }
 
 // encode completes the steps required to encode the QR Code. These include
 // adding the terminator bits and padding, splitting the data into blocks and
 // applying the error correction, and selecting the best data mask.
 func (q *QRCode) encode() {
+	// The symbol is built again, with the border currently set.
+	q.symbol = nil
+
+	if q.micro != nil {
+		q.encodeMicro()
+
+		return
+	}
+
 	numTerminatorBits := q.version.numTerminatorBitsRequired(q.data.Len())
 
 	q.addTerminatorBits(numTerminatorBits)
@@ -415,12 +530,16 @@ func (q *QRCode) encode() {
 	penalty := 0
 
 	for mask := 0; mask < numMasks; mask++ {
+		if q.FastMask && mask != fastMask {
+			continue
+		}
+
 		var (
 			s   *symbol
 			err error
 		)
 
-		s, err = buildRegularSymbol(q.version, mask, encoded, !q.DisableBorder)
+		s, err = buildRegularSymbol(q.version, mask, encoded, q.quietZoneSize())
 
 		if err != nil {
 			log.Panic(err.Error())
@@ -444,6 +563,20 @@ func (q *QRCode) encode() {
 	}
 }
 
+// quietZoneSize returns the width of the border of the QR Code in modules.
+func (q *QRCode) quietZoneSize() int {
+	switch {
+	case q.DisableBorder:
+		return 0
+	case q.QuietZone > 0:
+		return q.QuietZone
+	case q.micro != nil:
+		return q.micro.quietZoneSize()
+	default:
+		return q.version.quietZoneSize()
+	}
+}
+
 // addTerminatorBits adds final terminator bits to the encoded data.
 //
 // The number of terminator bits required is determined when the QR Code version
@@ -4,8 +4,14 @@
 package qrcode
 
 import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
 	"strings"
 	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
 )
 
 func TestQRCodeMaxCapacity(t *testing.T) {
@@ -144,6 +150,163 @@ func TestQRCodeVersionCapacity(t *testing.T) {
 	}
 }
 
+func TestNewBytes(t *testing.T) {
+	// Mixed digits and binary data would be split into several segments by New
+	data := make([]byte, 2953)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	copy(data, "0123456789")
+
+	q, err := NewBytes(data, Low)
+	if err != nil {
+		t.Fatalf("NewBytes(%d bytes) got %s expected success", len(data), err.Error())
+	}
+
+	if q.VersionNumber != 40 {
+		t.Errorf("NewBytes has version #%d, expected #40", q.VersionNumber)
+	}
+
+	// The data must be a single byte mode segment holding every byte verbatim
+	byteMode := bitset.New(b0, b1, b0, b0)
+	if !q.data.Substr(0, 4).Equals(byteMode) {
+		t.Errorf("NewBytes mode indicator got %s, expected %s", q.data.Substr(0, 4), byteMode)
+	}
+
+	if q.data.Len() != 4+16+8*len(data) {
+		t.Errorf("NewBytes encoded length got %d bits, expected %d", q.data.Len(), 4+16+8*len(data))
+	}
+
+	if _, err := NewBytes(append(data, 0), Low); err == nil {
+		t.Errorf("NewBytes(%d bytes) encodable, expected not encodable", len(data)+1)
+	}
+
+	if _, err := NewBytes(nil, Low); err == nil {
+		t.Error("NewBytes(nil) encodable, expected error")
+	}
+}
+
+func TestNewWithVersion(t *testing.T) {
+	q, err := NewWithVersion("my content", Medium, 25)
+	if err != nil {
+		t.Fatalf("NewWithVersion got %s expected success", err.Error())
+	}
+
+	if q.VersionNumber != 25 || len(q.Bitmap()) != 17+4*25+2*4 {
+		t.Errorf("NewWithVersion got version %d of %d modules, expected version 25", q.VersionNumber, len(q.Bitmap()))
+	}
+
+	if _, err := NewWithVersion(strings.Repeat("a", 100), Low, 1); !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("NewWithVersion of 100 bytes at version 1 got %v, expected ErrContentTooLong", err)
+	}
+
+	for _, version := range []int{0, 41} {
+		if _, err := NewWithVersion("my content", Low, version); err == nil || errors.Is(err, ErrContentTooLong) {
+			t.Errorf("NewWithVersion at version %d got %v, expected invalid version", version, err)
+		}
+	}
+}
+
+func TestSetVersion(t *testing.T) {
+	data := make([]byte, 300)
+	data[0] = 0xff
+
+	q, err := NewStructuredAppend(data, Medium, StructuredAppend{Index: 1, Total: 2, Parity: 0x42})
+	if err != nil {
+		t.Fatalf("NewStructuredAppend got %s expected success", err.Error())
+	}
+
+	// Drawing the QR Code first pads its data, which is encoded again
+	smallest := q.VersionNumber
+	q.Bitmap()
+
+	if err := q.SetVersion(smallest - 1); !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("SetVersion(%d) below the smallest version got %v, expected ErrContentTooLong", smallest-1, err)
+	}
+
+	// The length of the byte mode segment takes 16 bits from version 10 on
+	if err := q.SetVersion(30); err != nil {
+		t.Fatalf("SetVersion(30) got %s expected success", err.Error())
+	}
+
+	header := q.StructuredAppend.header()
+	if q.VersionNumber != 30 || !q.data.Substr(0, header.Len()).Equals(header) {
+		t.Errorf("SetVersion(30) got version %d, data %s, expected the structured append header", q.VersionNumber, q.data.Substr(0, header.Len()))
+	}
+
+	if q.data.Len() != header.Len()+4+16+8*len(data) {
+		t.Errorf("SetVersion(30) encoded length got %d bits, expected %d", q.data.Len(), header.Len()+4+16+8*len(data))
+	}
+
+	if size := len(q.Bitmap()); size != 17+4*30+2*4 {
+		t.Errorf("Bitmap at version 30 got %d modules, expected %d", size, 17+4*30+2*4)
+	}
+
+	m, err := NewMicro("1", Low)
+	if err != nil {
+		t.Fatalf("NewMicro got %s expected success", err.Error())
+	}
+
+	if err := m.SetVersion(2); err == nil {
+		t.Error("SetVersion of a Micro QR Code succeeded, expected error")
+	}
+}
+
+func TestQRCodeImageAndWrite(t *testing.T) {
+	q, err := New("https://example.org", Medium)
+	if err != nil {
+		t.Fatalf("New got %s expected success", err.Error())
+	}
+
+	bitmap := q.Bitmap()
+
+	img := q.Image(-3)
+	if size := img.Bounds().Dx(); size != 3*len(bitmap) {
+		t.Errorf("Image(-3) got %dpx, expected %dpx", size, 3*len(bitmap))
+	}
+
+	// The quiet zone is light, the top left finder pattern starts dark
+	quietZone := q.version.quietZoneSize()
+	if img.At(0, 0) != color.Color(color.White) || img.At(3*quietZone, 3*quietZone) != color.Color(color.Black) {
+		t.Errorf("Image(-3) got colors %v and %v, expected white and black", img.At(0, 0), img.At(3*quietZone, 3*quietZone))
+	}
+
+	if size := q.Image(10).Bounds().Dx(); size != len(bitmap) {
+		t.Errorf("Image(10) got %dpx, expected the minimum of %dpx", size, len(bitmap))
+	}
+
+	var b bytes.Buffer
+	if err := q.Write(256, &b); err != nil {
+		t.Fatalf("Write got %s expected success", err.Error())
+	}
+
+	p, err := q.PNG(256)
+	if err != nil || !bytes.Equal(p, b.Bytes()) {
+		t.Errorf("Write differs from PNG (%v)", err)
+	}
+
+	decoded, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("Write got an invalid PNG image: %s", err.Error())
+	}
+
+	if size := decoded.Bounds().Dx(); size != 256 {
+		t.Errorf("Write got an image of %dpx, expected 256px", size)
+	}
+
+	if err := q.Write(256, failingWriter{}); err == nil {
+		t.Error("Write to a failing writer succeeded, expected error")
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
 func TestQRCodeISOAnnexIExample(t *testing.T) {
 	var q *QRCode
 	q, err := New("01234567", Medium)
@@ -181,3 +344,31 @@ func BenchmarkQRCodeMaximumSize(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkQRCodeEncode(b *testing.B) {
+	data := bytes.Repeat([]byte{0x5a, 0xc3, 0x0f, 0x96, 0x00}, 400)
+
+	tests := []struct {
+		name     string
+		size     int
+		fastMask bool
+	}{
+		{"version 10", 200, false},
+		{"version 40", len(data), false},
+		{"version 40 fast mask", len(data), true},
+	}
+
+	for _, test := range tests {
+		b.Run(test.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				q, err := NewBytes(data[:test.size], Low)
+				if err != nil {
+					b.Fatalf("Failed to create QR code: %v", err)
+				}
+
+				q.FastMask = test.fastMask
+				q.Bitmap()
+			}
+		})
+	}
+}
@@ -72,6 +72,9 @@ func (e gfPoly) numTerms() int {
 }
 
 // gfPolyMultiply returns a * b.
+//
+// The products of the terms are accumulated in place, as allocating a monomial
+// for each of them made the polynomials of large QR Codes slow to multiply.
 func gfPolyMultiply(a, b gfPoly) gfPoly {
 	numATerms := a.numTerms()
 	numBTerms := b.numTerms()
@@ -79,12 +82,13 @@ func gfPolyMultiply(a, b gfPoly) gfPoly {
 	result := gfPoly{term: make([]gfElement, numATerms+numBTerms)}
 
 	for i := 0; i < numATerms; i++ {
-		for j := 0; j < numBTerms; j++ {
-			if a.term[i] != 0 && b.term[j] != 0 {
-				monomial := gfPoly{term: make([]gfElement, i+j+1)}
-				monomial.term[i+j] = gfMultiply(a.term[i], b.term[j])
+		if a.term[i] == 0 {
+			continue
+		}
 
-				result = gfPolyAdd(result, monomial)
+		for j := 0; j < numBTerms; j++ {
+			if b.term[j] != 0 {
+				result.term[i+j] = gfAdd(result.term[i+j], gfMultiply(a.term[i], b.term[j]))
 			}
 		}
 	}
@@ -93,22 +97,34 @@ func gfPolyMultiply(a, b gfPoly) gfPoly {
 }
 
 // gfPolyRemainder return the remainder of numerator / denominator.
+//
+// The remainder is computed by long division in a copy of the numerator, each
+// step cancelling its highest term.
 func gfPolyRemainder(numerator, denominator gfPoly) gfPoly {
 	if denominator.equals(gfPoly{}) {
 		log.Panicln("Remainder by zero")
 	}
 
-	remainder := numerator
+	remainder := gfPoly{term: append([]gfElement(nil), numerator.normalised().term...)}
 
-	for remainder.numTerms() >= denominator.numTerms() {
-		degree := remainder.numTerms() - denominator.numTerms()
-		coefficient := gfDivide(remainder.term[remainder.numTerms()-1],
-			denominator.term[denominator.numTerms()-1])
+	numDenominatorTerms := denominator.numTerms()
+	lead := denominator.term[numDenominatorTerms-1]
 
-		divisor := gfPolyMultiply(denominator,
-			newGFPolyMonomial(coefficient, degree))
+	for n := remainder.numTerms(); n >= numDenominatorTerms; n-- {
+		coefficient := gfDivide(remainder.term[n-1], lead)
+		if coefficient == gfZero {
+			continue
+		}
+
+		degree := n - numDenominatorTerms
+
+		for i, term := range denominator.term {
+			remainder.term[degree+i] = gfAdd(remainder.term[degree+i], gfMultiply(term, coefficient))
+		}
+	}
 
-		remainder = gfPolyAdd(remainder, divisor)
+	if len(remainder.term) >= numDenominatorTerms {
+		remainder.term = remainder.term[:numDenominatorTerms-1]
 	}
 
 	return remainder.normalised()
@@ -90,3 +90,42 @@ func TestEncode(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeLongBlock(t *testing.T) {
+	// The longest blocks, of version 40 at the Low level
+	const (
+		numDataBytes = 118
+		numECBytes   = 30
+	)
+
+	data := bitset.New()
+	for i := 0; i < numDataBytes; i++ {
+		data.AppendByte(byte(i*37+11), 8)
+	}
+
+	codeword := newGFPolyFromData(Encode(data, numECBytes))
+
+	// A codeword is a multiple of the generator polynomial, zero at its roots
+	for i := 0; i < numECBytes; i++ {
+		var value gfElement
+
+		for j := codeword.numTerms() - 1; j >= 0; j-- {
+			value = gfAdd(gfMultiply(value, gfExpTable[i]), codeword.term[j])
+		}
+
+		if value != gfZero {
+			t.Fatalf("codeword at a^%d = %d, want 0", i, value)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	data := bitset.New()
+	for i := 0; i < 118; i++ {
+		data.AppendByte(byte(i), 8)
+	}
+
+	for i := 0; i < b.N; i++ {
+		Encode(data, 30)
+	}
+}
@@ -104,12 +104,7 @@ var (
 	}
 )
 
-func buildRegularSymbol(version qrCodeVersion, mask int, data *bitset.Bitset, includeQuietZone bool) (*symbol, error) {
-	quietZoneSize := 0
-	if includeQuietZone {
-		quietZoneSize = version.quietZoneSize()
-	}
-
+func buildRegularSymbol(version qrCodeVersion, mask int, data *bitset.Bitset, quietZoneSize int) (*symbol, error) {
 	m := &regularSymbol{
 		version: version,
 		mask:    mask,
@@ -19,7 +19,7 @@ func TestBuildRegularSymbol(t *testing.T) {
 			data.AppendNumBools(8, false)
 		}
 
-		_, err := buildRegularSymbol(*v, k, data, false)
+		_, err := buildRegularSymbol(*v, k, data, 0)
 
 		if err != nil {
 			fmt.Println(err.Error())
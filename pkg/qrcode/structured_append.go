@@ -0,0 +1,153 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+)
+
+// Structured append.
+//
+// A message too long for one QR Code may be split across up to 16 QR Codes
+// linked by structured append, which scanners that support it reassemble into
+// the message whatever the order the QR Codes are read in. Each QR Code starts
+// with a structured append header:
+//
+// - The structured append mode indicator, 0011.
+// - The position of the QR Code in the message, from 0, in 4 bits.
+// - The number of QR Codes of the message minus one, in 4 bits.
+// - The parity of the message, the XOR of all its bytes, in 8 bits.
+//
+// followed by the segments of its part of the message.
+
+// MaxStructuredAppendSymbols is the largest number of QR Codes a structured
+// append message is split across.
+const MaxStructuredAppendSymbols = 16
+
+// structuredAppendHeaderBits is the length of the structured append header.
+const structuredAppendHeaderBits = 20
+
+// StructuredAppend is the structured append header of a QR Code holding part of a
+// message split across several QR Codes.
+type StructuredAppend struct {
+	// Index is the position of the QR Code in the message, from 0.
+	Index int
+
+	// Total is the number of QR Codes of the message, 1 to 16.
+	Total int
+
+	// Parity is the parity of the whole message, see StructuredAppendParity.
+	Parity byte
+}
+
+// validate returns an error if sa cannot be encoded in a structured append header.
+func (sa StructuredAppend) validate() error {
+	if sa.Total < 1 || sa.Total > MaxStructuredAppendSymbols {
+		return fmt.Errorf("invalid structured append total %d (expected 1-%d inclusive)", sa.Total, MaxStructuredAppendSymbols)
+	}
+
+	if sa.Index < 0 || sa.Index >= sa.Total {
+		return fmt.Errorf("invalid structured append index %d (expected 0-%d inclusive)", sa.Index, sa.Total-1)
+	}
+
+	return nil
+}
+
+// header returns the structured append header bits of sa.
+func (sa StructuredAppend) header() *bitset.Bitset {
+	header := bitset.New(b0, b0, b1, b1)
+	header.AppendUint32(uint32(sa.Index), 4)
+	header.AppendUint32(uint32(sa.Total-1), 4)
+	header.AppendByte(sa.Parity, 8)
+
+	return header
+}
+
+// StructuredAppendParity returns the parity of a message for the structured append
+// header of its QR Codes, the XOR of all its bytes.
+func StructuredAppendParity(message []byte) byte {
+	var parity byte
+	for _, b := range message {
+		parity ^= b
+	}
+
+	return parity
+}
+
+// NewStructuredAppend constructs a QRCode holding data, part of a message, in a
+// single byte mode segment following the structured append header sa.
+//
+//	var q *qrcode.QRCode
+//	q, err := qrcode.NewStructuredAppend([]byte("part"), qrcode.Medium, qrcode.StructuredAppend{Index: 0, Total: 2, Parity: p})
+//
+// An error wrapping ErrContentTooLong occurs if the data is too long, or an error
+// if the header is invalid.
+func NewStructuredAppend(data []byte, level RecoveryLevel, sa StructuredAppend) (*QRCode, error) {
+	if err := sa.validate(); err != nil {
+		return nil, err
+	}
+
+	q, err := newQRCode(string(data), level, func(encoder *dataEncoder) (*bitset.Bitset, error) {
+		encoded, err := encoder.encodeBytes(data)
+		if err != nil {
+			return nil, err
+		}
+
+		header := sa.header()
+		header.Append(encoded)
+
+		return header, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.StructuredAppend = &sa
+
+	return q, nil
+}
+
+// SplitStructuredAppend splits message across the fewest QR Codes of at most
+// version linked by structured append, each holding as many bytes of it as fits.
+//
+// An error wrapping ErrContentTooLong occurs if the message needs more than 16 QR
+// Codes, or an error in case of an invalid version.
+func SplitStructuredAppend(message []byte, level RecoveryLevel, version int) ([]*QRCode, error) {
+	if len(message) == 0 {
+		return nil, errors.New("no data to encode")
+	}
+
+	partLen := MaxStructuredAppendBytes(version, level)
+	if partLen == 0 {
+		return nil, fmt.Errorf("invalid version %d (expected 1-40 inclusive)", version)
+	}
+
+	total := (len(message) + partLen - 1) / partLen
+	if total > MaxStructuredAppendSymbols {
+		return nil, fmt.Errorf("%w: %d bytes need %d QR Codes of version %d, structured append links at most %d",
+			ErrContentTooLong, len(message), total, version, MaxStructuredAppendSymbols)
+	}
+
+	parity := StructuredAppendParity(message)
+	codes := make([]*QRCode, 0, total)
+
+	for i := 0; i < total; i++ {
+		part := message[i*partLen : min((i+1)*partLen, len(message))]
+
+		q, err := NewStructuredAppend(part, level, StructuredAppend{Index: i, Total: total, Parity: parity})
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, q)
+	}
+
+	return codes, nil
+}
+
+// MaxStructuredAppendBytes returns the number of bytes NewStructuredAppend fits in
+// a QR Code of version at level, or 0 if the version is not 1-40 inclusive.
+func MaxStructuredAppendBytes(version int, level RecoveryLevel) int {
+	return maxBytes(version, level, structuredAppendHeaderBits)
+}
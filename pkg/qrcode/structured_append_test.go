@@ -0,0 +1,120 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
+)
+
+func TestNewStructuredAppend(t *testing.T) {
+	data := []byte("0123456789 part of a message")
+	sa := StructuredAppend{Index: 2, Total: 5, Parity: 0xa5}
+
+	q, err := NewStructuredAppend(data, Medium, sa)
+	if err != nil {
+		t.Fatalf("NewStructuredAppend got %s expected success", err.Error())
+	}
+
+	if q.StructuredAppend == nil || *q.StructuredAppend != sa {
+		t.Errorf("NewStructuredAppend header got %v, expected %v", q.StructuredAppend, sa)
+	}
+
+	// Mode 0011, index 2, total 5 - 1, parity 0xa5, then a single byte mode segment
+	header := bitset.NewFromBase2String("0011" + "0010" + "0100" + "10100101")
+	if !q.data.Substr(0, 20).Equals(header) {
+		t.Errorf("NewStructuredAppend header got %s, expected %s", q.data.Substr(0, 20), header)
+	}
+
+	byteMode := bitset.New(b0, b1, b0, b0)
+	if !q.data.Substr(20, 24).Equals(byteMode) {
+		t.Errorf("NewStructuredAppend mode indicator got %s, expected %s", q.data.Substr(20, 24), byteMode)
+	}
+
+	if q.data.Len() != 20+4+8+8*len(data) {
+		t.Errorf("NewStructuredAppend encoded length got %d bits, expected %d", q.data.Len(), 20+4+8+8*len(data))
+	}
+
+	for _, invalid := range []StructuredAppend{{Index: 0, Total: 0}, {Index: 0, Total: 17}, {Index: 3, Total: 3}, {Index: -1, Total: 2}} {
+		if _, err := NewStructuredAppend(data, Medium, invalid); err == nil {
+			t.Errorf("NewStructuredAppend(%v) encodable, expected error", invalid)
+		}
+	}
+}
+
+func TestMaxStructuredAppendBytes(t *testing.T) {
+	for _, level := range []RecoveryLevel{Low, Medium, High, Highest} {
+		for version := 1; version <= 40; version++ {
+			n := MaxStructuredAppendBytes(version, level)
+			sa := StructuredAppend{Total: 1}
+
+			q, err := NewStructuredAppend(bytes.Repeat([]byte{0xff}, n), level, sa)
+			if err != nil || q.VersionNumber > version {
+				t.Errorf("version %d level %d: %d bytes do not fit (%v)", version, level, n, err)
+			}
+
+			if q, err := NewStructuredAppend(bytes.Repeat([]byte{0xff}, n+1), level, sa); err == nil && q.VersionNumber <= version {
+				t.Errorf("version %d level %d: %d bytes fit, expected at most %d", version, level, n+1, n)
+			}
+		}
+	}
+
+	if n := MaxStructuredAppendBytes(41, Low); n != 0 {
+		t.Errorf("MaxStructuredAppendBytes(41) got %d, expected 0", n)
+	}
+}
+
+func TestSplitStructuredAppend(t *testing.T) {
+	message := make([]byte, 1000)
+	for i := range message {
+		message[i] = byte(i * 7)
+	}
+
+	codes, err := SplitStructuredAppend(message, Low, 10)
+	if err != nil {
+		t.Fatalf("SplitStructuredAppend got %s expected success", err.Error())
+	}
+
+	partLen := MaxStructuredAppendBytes(10, Low)
+	if want := (len(message) + partLen - 1) / partLen; len(codes) != want {
+		t.Fatalf("SplitStructuredAppend got %d QR codes, expected %d", len(codes), want)
+	}
+
+	var joined []byte
+
+	for i, q := range codes {
+		expected := StructuredAppend{Index: i, Total: len(codes), Parity: StructuredAppendParity(message)}
+		if *q.StructuredAppend != expected {
+			t.Errorf("QR code %d header got %v, expected %v", i, *q.StructuredAppend, expected)
+		}
+
+		if q.VersionNumber > 10 {
+			t.Errorf("QR code %d has version %d, expected at most 10", i, q.VersionNumber)
+		}
+
+		joined = append(joined, q.Content...)
+	}
+
+	if !bytes.Equal(joined, message) {
+		t.Error("joined QR code contents differ from the message")
+	}
+
+	if _, err := SplitStructuredAppend(message, Low, 1); !errors.Is(err, ErrContentTooLong) {
+		t.Errorf("SplitStructuredAppend at version 1 got %v, expected ErrContentTooLong", err)
+	}
+
+	if _, err := SplitStructuredAppend(message, Low, 0); err == nil {
+		t.Error("SplitStructuredAppend at version 0 succeeded, expected error")
+	}
+}
+
+func TestStructuredAppendParity(t *testing.T) {
+	if p := StructuredAppendParity([]byte{0x0f, 0xf0, 0x01}); p != 0xfe {
+		t.Errorf("StructuredAppendParity got %#x, expected 0xfe", p)
+	}
+
+	if p := StructuredAppendParity(nil); p != 0 {
+		t.Errorf("StructuredAppendParity(nil) got %#x, expected 0", p)
+	}
+}
@@ -0,0 +1,99 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// SVG returns the QR Code as an SVG image.
+//
+// The QR Code is drawn as vector shapes, one module per unit of the view box, so
+// the image stays crisp at any print or display size. size sets the width and
+// height of the image in pixels like it does for Image: a negative size sets the
+// size of a module instead. A Logo is embedded as a PNG image.
+func (q *QRCode) SVG(size int) []byte {
+	bitmap := q.Bitmap()
+	realSize := len(bitmap)
+
+	// Variable size support.
+	if size < 0 {
+		size = size * -1 * realSize
+	}
+
+	if size < realSize {
+		size = realSize
+	}
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		size, size, realSize, realSize)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", realSize, realSize, svgColor(q.BackgroundColor))
+	fmt.Fprintf(&b, `<path fill="%s" d="`, svgColor(q.ForegroundColor))
+
+	// One horizontal run of set modules per subpath
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+
+			fmt.Fprintf(&b, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	b.WriteString(`"/>` + "\n")
+
+	if q.Logo != nil {
+		q.writeSVGLogo(&b)
+	}
+
+	b.WriteString("</svg>\n")
+
+	return b.Bytes()
+}
+
+// writeSVGLogo writes the logo over the center of the QR Code, embedded as a PNG
+// image scaled by the SVG renderer.
+func (q *QRCode) writeSVGLogo(b *bytes.Buffer) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, q.Logo); err != nil {
+		return
+	}
+
+	width := logoSize(q.LogoSize) * float64(q.symbol.symbolSize)
+	start := (float64(q.symbol.size) - width) / 2
+
+	fmt.Fprintf(b, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s"/>`+"\n",
+		start, start, width, width, svgColor(q.BackgroundColor))
+	fmt.Fprintf(b, `<image x="%g" y="%g" width="%g" height="%g" href="data:image/png;base64,%s"/>`+"\n",
+		start, start, width, width, base64.StdEncoding.EncodeToString(logo.Bytes()))
+}
+
+// WriteSVG writes the QR Code as an SVG image to io.Writer.
+//
+// size is both the image width and height in pixels, see SVG.
+func (q *QRCode) WriteSVG(size int, out io.Writer) error {
+	if _, err := out.Write(q.SVG(size)); err != nil {
+		return fmt.Errorf("io.Writer.Write: %w", err)
+	}
+
+	return nil
+}
+
+// svgColor returns the SVG notation of an opaque color
+func svgColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
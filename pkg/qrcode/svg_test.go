@@ -0,0 +1,65 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	q, err := New("https://example.org/vector", Medium)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := q.WriteSVG(-4, &b); err != nil {
+		t.Fatalf("WriteSVG failed: %v", err)
+	}
+
+	var doc struct {
+		Width   int    `xml:"width,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("SVG is not valid XML: %v", err)
+	}
+
+	bitmap := q.Bitmap()
+	n := len(bitmap)
+
+	if doc.Width != 4*n || doc.ViewBox != fmt.Sprintf("0 0 %d %d", n, n) {
+		t.Fatalf("Unexpected SVG size: width %d, viewBox %q", doc.Width, doc.ViewBox)
+	}
+
+	// Rebuild the modules from the runs of the path
+	drawn := make([][]bool, n)
+	for y := range drawn {
+		drawn[y] = make([]bool, n)
+	}
+
+	for _, run := range strings.Split(strings.TrimSuffix(doc.Path.D, "z"), "z") {
+		var x, y, w int
+		if _, err := fmt.Sscanf(run, "M%d %dh%dv1h", &x, &y, &w); err != nil {
+			t.Fatalf("Unexpected path segment %q: %v", run, err)
+		}
+
+		for i := x; i < x+w; i++ {
+			drawn[y][i] = true
+		}
+	}
+
+	for y := range bitmap {
+		for x := range bitmap[y] {
+			if drawn[y][x] != bitmap[y][x] {
+				t.Fatalf("Module (%d, %d) drawn %v, want %v", x, y, drawn[y][x], bitmap[y][x])
+			}
+		}
+	}
+}
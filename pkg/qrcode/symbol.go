@@ -149,7 +149,45 @@ const (
 // penaltyScore returns the penalty score of the symbol. The penalty score
 // consists of the sum of the four individual penalty types.
 func (m *symbol) penaltyScore() int {
-	return m.penalty1() + m.penalty2() + m.penalty3() + m.penalty4()
+	rows, columns := m.rows(), m.columns()
+
+	return linesPenalty1(rows) + linesPenalty1(columns) + m.penalty2() +
+		linesPenalty3(rows) + linesPenalty3(columns) + m.penalty4()
+}
+
+// rows returns the rows of the symbol, not including the quiet zone.
+//
+// The penalties read the modules through the rows and columns rather than get,
+// which offsets both coordinates by the quiet zone on every call: the penalties
+// of the eight masks are evaluated for every QR Code, and dominated the encoding
+// time of large ones.
+func (m *symbol) rows() [][]bool {
+	rows := make([][]bool, m.symbolSize)
+
+	for y := range rows {
+		rows[y] = m.module[y+m.quietZoneSize][m.quietZoneSize : m.quietZoneSize+m.symbolSize]
+	}
+
+	return rows
+}
+
+// columns returns the columns of the symbol, not including the quiet zone, so
+// that the penalties scan them like rows.
+func (m *symbol) columns() [][]bool {
+	modules := make([]bool, m.symbolSize*m.symbolSize)
+	columns := make([][]bool, m.symbolSize)
+
+	for x := range columns {
+		columns[x] = modules[x*m.symbolSize : (x+1)*m.symbolSize]
+	}
+
+	for y, row := range m.rows() {
+		for x, v := range row {
+			columns[x][y] = v
+		}
+	}
+
+	return columns
 }
 
 // penalty1 returns the penalty score for "adjacent modules in row/column with
@@ -159,47 +197,22 @@ func (m *symbol) penaltyScore() int {
 // 0-5: score = 0
 // 6+ : score = penaltyWeight1 + (numAdjacentModules - 5)
 func (m *symbol) penalty1() int {
-	penalty := 0
-
-	for x := 0; x < m.symbolSize; x++ {
-		lastValue := m.get(x, 0)
-		count := 1
-
-		for y := 1; y < m.symbolSize; y++ {
-			v := m.get(x, y)
+	return linesPenalty1(m.columns()) + linesPenalty1(m.rows())
+}
 
-			if v != lastValue {
-				count = 1
-				lastValue = v
-			} else {
-				count++
-				if count == 6 {
-					penalty += penaltyWeight1 + 1
-				} else if count > 6 {
-					penalty++
-				}
-			}
-		}
-	}
+// linesPenalty1 returns penalty1 of the rows or columns lines.
+func linesPenalty1(lines [][]bool) int {
+	penalty := 0
 
-	for y := 0; y < m.symbolSize; y++ {
-		lastValue := m.get(0, y)
+	for _, line := range lines {
+		lastValue := line[0]
 		count := 1
 
-		for x := 1; x < m.symbolSize; x++ {
-			v := m.get(x, y)
+		for _, v := range line[1:] {
+			count = count*boolToInt(v == lastValue) + 1
+			lastValue = v
 
-			if v != lastValue {
-				count = 1
-				lastValue = v
-			} else {
-				count++
-				if count == 6 {
-					penalty += penaltyWeight1 + 1
-				} else if count > 6 {
-					penalty++
-				}
-			}
+			penalty += boolToInt(count == 6)*(penaltyWeight1+1) + boolToInt(count > 6)
 		}
 	}
 
@@ -210,18 +223,16 @@ func (m *symbol) penalty1() int {
 //
 // m*n: score = penaltyWeight2 * (m-1) * (n-1).
 func (m *symbol) penalty2() int {
+	rows := m.rows()
 	penalty := 0
 
-	for y := 1; y < m.symbolSize; y++ {
-		for x := 1; x < m.symbolSize; x++ {
-			topLeft := m.get(x-1, y-1)
-			above := m.get(x, y-1)
-			left := m.get(x-1, y)
-			current := m.get(x, y)
+	for y := 1; y < len(rows); y++ {
+		above, row := rows[y-1], rows[y]
 
-			if current == left && current == above && current == topLeft {
-				penalty++
-			}
+		for x := 1; x < len(row); x++ {
+			current := row[x]
+
+			penalty += boolToInt(current == row[x-1]) & boolToInt(current == above[x]) & boolToInt(current == above[x-1])
 		}
 	}
 
@@ -234,40 +245,18 @@ func (m *symbol) penalty2() int {
 //
 // Existence of the pattern scores penaltyWeight3.
 func (m *symbol) penalty3() int {
-	penalty := 0
-
-	for y := 0; y < m.symbolSize; y++ {
-		var bitBuffer int16 = 0x00
-
-		for x := 0; x < m.symbolSize; x++ {
-			bitBuffer <<= 1
-			if v := m.get(x, y); v {
-				bitBuffer |= 1
-			}
+	return linesPenalty3(m.rows()) + linesPenalty3(m.columns())
+}
 
-			switch bitBuffer & 0x7ff {
-			// 0b000 0101 1101 or 0b10111010000
-			// 0x05d           or 0x5d0
-			case 0x05d, 0x5d0:
-				penalty += penaltyWeight3
-				bitBuffer = 0xFF
-			default:
-				if x == m.symbolSize-1 && (bitBuffer&0x7f) == 0x5d {
-					penalty += penaltyWeight3
-					bitBuffer = 0xFF
-				}
-			}
-		}
-	}
+// linesPenalty3 returns penalty3 of the rows or columns lines.
+func linesPenalty3(lines [][]bool) int {
+	penalty := 0
 
-	for x := 0; x < m.symbolSize; x++ {
+	for _, line := range lines {
 		var bitBuffer int16 = 0x00
 
-		for y := 0; y < m.symbolSize; y++ {
-			bitBuffer <<= 1
-			if v := m.get(x, y); v {
-				bitBuffer |= 1
-			}
+		for i, v := range line {
+			bitBuffer = bitBuffer<<1 | int16(boolToInt(v))
 
 			switch bitBuffer & 0x7ff {
 			// 0b000 0101 1101 or 0b10111010000
@@ -276,7 +265,7 @@ func (m *symbol) penalty3() int {
 				penalty += penaltyWeight3
 				bitBuffer = 0xFF
 			default:
-				if y == m.symbolSize-1 && (bitBuffer&0x7f) == 0x5d {
+				if i == len(line)-1 && (bitBuffer&0x7f) == 0x5d {
 					penalty += penaltyWeight3
 					bitBuffer = 0xFF
 				}
@@ -292,9 +281,9 @@ func (m *symbol) penalty4() int {
 	numModules := m.symbolSize * m.symbolSize
 	numDarkModules := 0
 
-	for x := 0; x < m.symbolSize; x++ {
-		for y := 0; y < m.symbolSize; y++ {
-			if v := m.get(x, y); v {
+	for _, row := range m.rows() {
+		for _, v := range row {
+			if v {
 				numDarkModules++
 			}
 		}
@@ -307,3 +296,16 @@ func (m *symbol) penalty4() int {
 
 	return penaltyWeight4 * (numDarkModuleDeviation / (numModules / 20))
 }
+
+// boolToInt returns 1 if b is true, 0 otherwise.
+//
+// The penalties add up comparisons of modules with boolToInt, which compiles to
+// no branch, rather than with if statements: the modules of the data are as good
+// as random, so the branches were mispredicted half the time.
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -3,7 +3,10 @@
 
 package qrcode
 
-import "testing"
+import (
+	"bytes"
+	"testing"
+)
 
 func TestSymbolBasic(t *testing.T) {
 	size := 10
@@ -336,3 +339,16 @@ func TestSymbolPenalties(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkPenaltyScore(b *testing.B) {
+	q, err := NewBytes(bytes.Repeat([]byte{0x5a, 0xc3, 0x0f, 0x96, 0x00}, 400), Low)
+	if err != nil {
+		b.Fatalf("Failed to create QR code: %v", err)
+	}
+
+	q.Bitmap()
+
+	for n := 0; n < b.N; n++ {
+		q.symbol.penaltyScore()
+	}
+}
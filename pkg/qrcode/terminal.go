@@ -0,0 +1,66 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Colors of the halves of a character cell drawn by WriteTerminal, as ANSI SGR
+// codes: black and bright white foreground and background
+const (
+	terminalDarkFg  = 30
+	terminalLightFg = 97
+	terminalDarkBg  = 40
+	terminalLightBg = 107
+)
+
+// WriteTerminal renders the QR code to out for display in a terminal, e.g. over
+// SSH. Every line of text draws two rows of modules with upper half block
+// characters, the upper module in the foreground and the lower one in the
+// background color, set to black and white by ANSI escape sequences, so the QR
+// code scans whatever the color scheme of the terminal. Every line ends with a
+// color reset. The QR code takes len(Bitmap()) columns and half as many lines,
+// rounded up.
+func (q *QRCode) WriteTerminal(out io.Writer) error {
+	bits := q.Bitmap()
+
+	var buf bytes.Buffer
+
+	for y := 0; y < len(bits); y += 2 {
+		last := ""
+
+		for x := range bits[y] {
+			// Below an odd last row is the light background
+			lower := y+1 < len(bits) && bits[y+1][x]
+
+			if code := terminalColors(bits[y][x], lower); code != last {
+				buf.WriteString(code)
+				last = code
+			}
+
+			buf.WriteString("▀")
+		}
+
+		buf.WriteString("\x1b[0m\n")
+	}
+
+	_, err := out.Write(buf.Bytes())
+
+	return err
+}
+
+// terminalColors returns the escape sequence setting the colors of a character
+// cell whose upper and lower modules are dark or light
+func terminalColors(upper, lower bool) string {
+	fg, bg := terminalLightFg, terminalLightBg
+	if upper {
+		fg = terminalDarkFg
+	}
+
+	if lower {
+		bg = terminalDarkBg
+	}
+
+	return fmt.Sprintf("\x1b[%d;%dm", fg, bg)
+}
@@ -0,0 +1,56 @@
+package qrcode
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteTerminal(t *testing.T) {
+	q, err := New("https://example.org/terminal", Medium)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := q.WriteTerminal(&b); err != nil {
+		t.Fatalf("WriteTerminal failed: %v", err)
+	}
+
+	bits := q.Bitmap()
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != (len(bits)+1)/2 {
+		t.Fatalf("WriteTerminal wrote %d lines, want %d", len(lines), (len(bits)+1)/2)
+	}
+
+	// Read the modules back from the colors of every character cell
+	cell := regexp.MustCompile(`(?:\x1b\[(\d+);(\d+)m)?▀`)
+
+	for i, line := range lines {
+		if !strings.HasSuffix(line, "\x1b[0m") {
+			t.Fatalf("Line %d does not reset the colors: %q", i, line)
+		}
+
+		cells := cell.FindAllStringSubmatch(line, -1)
+		if len(cells) != len(bits[0]) {
+			t.Fatalf("Line %d has %d cells, want %d", i, len(cells), len(bits[0]))
+		}
+
+		var fg, bg string
+
+		for x, c := range cells {
+			if c[1] != "" {
+				fg, bg = c[1], c[2]
+			}
+
+			upper := bits[2*i][x]
+			lower := 2*i+1 < len(bits) && bits[2*i+1][x]
+
+			if (fg == "30") != upper || (bg == "40") != lower {
+				t.Fatalf("Cell %d of line %d is %s;%s, want modules %v and %v", x, i, fg, bg, upper, lower)
+			}
+		}
+	}
+}
@@ -3053,3 +3053,99 @@ func getQRCodeVersion(level RecoveryLevel, version int) *qrCodeVersion {
 
 	return nil
 }
+
+// MaxBytes returns the number of bytes NewBytes fits in a QR Code of version at
+// level, or 0 if the version is not 1-40 inclusive.
+func MaxBytes(version int, level RecoveryLevel) int {
+	return maxBytes(version, level, 0)
+}
+
+// maxBytes returns the number of bytes fitting in a single byte mode segment of a
+// QR Code of version at level after headerBits bits, or 0 if the version is not
+// 1-40 inclusive.
+func maxBytes(version int, level RecoveryLevel, headerBits int) int {
+	return maxCapacity(version, level, ModeByte, headerBits)
+}
+
+// Mode is the encoding mode of the data of a QR Code segment.
+type Mode int
+
+const (
+	// ModeNumeric holds the digits 0-9, 3 digits in 10 bits.
+	ModeNumeric Mode = iota
+
+	// ModeAlphanumeric holds the digits, the upper case letters A-Z, and the
+	// characters " $%*+-./:", 2 characters in 11 bits.
+	ModeAlphanumeric
+
+	// ModeByte holds any bytes, 8 bits each.
+	ModeByte
+
+	// ModeKanji holds the double-byte characters of Shift JIS in the Kanji
+	// ranges, 13 bits each.
+	ModeKanji
+)
+
+// dataMode returns the segment encoding mode of the mode.
+func (m Mode) dataMode() dataMode {
+	switch m {
+	case ModeNumeric:
+		return dataModeNumeric
+	case ModeAlphanumeric:
+		return dataModeAlphanumeric
+	case ModeByte:
+		return dataModeByte
+	case ModeKanji:
+		return dataModeKanji
+	}
+
+	return dataModeNone
+}
+
+// MaxCapacity returns the number of characters of mode fitting in a single
+// segment of a QR Code of version at level: digits in numeric mode, characters
+// in alphanumeric mode, bytes in byte mode, and double-byte characters in Kanji
+// mode. It returns 0 if the version is not 1-40 inclusive or the mode is
+// unknown.
+//
+// Data split into several segments, as encoded by New, may fit more characters
+// of a denser mode.
+func MaxCapacity(version int, level RecoveryLevel, mode Mode) int {
+	return maxCapacity(version, level, mode, 0)
+}
+
+// maxCapacity returns the number of characters of mode fitting in a single
+// segment of a QR Code of version at level after headerBits bits.
+func maxCapacity(version int, level RecoveryLevel, mode Mode, headerBits int) int {
+	v := getQRCodeVersion(level, version)
+	if v == nil || mode.dataMode() == dataModeNone {
+		return 0
+	}
+
+	encoder := newVersionDataEncoder(version)
+	charCountBits := encoder.charCountBits(mode.dataMode())
+
+	numBits := v.numDataBits() - headerBits - encoder.modeIndicator(mode.dataMode()).Len() - charCountBits
+
+	var n int
+
+	switch mode {
+	case ModeNumeric:
+		// A final 1 or 2 digits take 4 or 7 bits.
+		n = numBits / 10 * 3
+		if r := numBits % 10; r >= 7 {
+			n += 2
+		} else if r >= 4 {
+			n++
+		}
+	case ModeAlphanumeric:
+		// A final character takes 6 bits.
+		n = numBits/11*2 + numBits%11/6
+	case ModeByte:
+		n = numBits / 8
+	case ModeKanji:
+		n = numBits / 13
+	}
+
+	return min(max(n, 0), 1<<charCountBits-1)
+}
@@ -4,6 +4,8 @@
 package qrcode
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode/bitset"
@@ -150,3 +152,88 @@ func TestNumBitsToPadToCodeoword(t *testing.T) {
 		}
 	}
 }
+
+func TestMaxBytes(t *testing.T) {
+	tests := []struct {
+		level    RecoveryLevel
+		version  int
+		expected int
+	}{
+		{Low, 1, 17},
+		{Medium, 10, 213},
+		{High, 20, 482},
+		{Low, 40, 2953},
+		{Highest, 40, 1273},
+		{Low, 0, 0},
+		{Low, 41, 0},
+	}
+
+	for _, test := range tests {
+		maxBytes := MaxBytes(test.version, test.level)
+		if maxBytes != test.expected {
+			t.Errorf("MaxBytes(%d, %d) = %d, want %d", test.version, test.level, maxBytes, test.expected)
+		}
+
+		if maxBytes == 0 {
+			continue
+		}
+
+		// The capacity fits in the version, one byte more does not
+		q, err := NewBytes(make([]byte, maxBytes), test.level)
+		if err != nil || q.VersionNumber != test.version {
+			t.Errorf("NewBytes of %d bytes at level %d = %v, %v, want version %d", maxBytes, test.level, q, err, test.version)
+		}
+
+		if q, err = NewBytes(make([]byte, maxBytes+1), test.level); err == nil && q.VersionNumber <= test.version {
+			t.Errorf("NewBytes of %d bytes at level %d = version %d, want above %d", maxBytes+1, test.level, q.VersionNumber, test.version)
+		}
+	}
+}
+
+func TestMaxCapacity(t *testing.T) {
+	tests := []struct {
+		level   RecoveryLevel
+		version int
+		mode    Mode
+		want    int
+	}{
+		{Low, 1, ModeNumeric, 41},
+		{Low, 1, ModeAlphanumeric, 25},
+		{Low, 1, ModeByte, 17},
+		{Low, 1, ModeKanji, 10},
+		{Medium, 10, ModeNumeric, 513},
+		{Medium, 10, ModeAlphanumeric, 311},
+		{Medium, 10, ModeKanji, 131},
+		{Highest, 2, ModeNumeric, 34},
+		{Low, 40, ModeNumeric, 7089},
+		{Low, 40, ModeAlphanumeric, 4296},
+		{Low, 40, ModeByte, 2953},
+		{Low, 40, ModeKanji, 1817},
+		{Highest, 40, ModeKanji, 784},
+		{Low, 0, ModeByte, 0},
+		{Low, 1, Mode(-1), 0},
+	}
+
+	for _, test := range tests {
+		if got := MaxCapacity(test.version, test.level, test.mode); got != test.want {
+			t.Errorf("MaxCapacity(%d, %d, %d) = %d, want %d", test.version, test.level, test.mode, got, test.want)
+		}
+	}
+
+	// The capacity fits in the version, one character more does not
+	for _, mode := range []Mode{ModeNumeric, ModeAlphanumeric, ModeByte} {
+		char := map[Mode]string{ModeNumeric: "7", ModeAlphanumeric: "Z", ModeByte: "z"}[mode]
+
+		for _, version := range []int{3, 9, 10, 26, 27} {
+			n := MaxCapacity(version, Medium, mode)
+
+			if _, err := NewWithVersion(strings.Repeat(char, n), Medium, version); err != nil {
+				t.Errorf("NewWithVersion of %d characters of mode %d at version %d got %s expected success", n, mode, version, err.Error())
+			}
+
+			if _, err := NewWithVersion(strings.Repeat(char, n+1), Medium, version); !errors.Is(err, ErrContentTooLong) {
+				t.Errorf("NewWithVersion of %d characters of mode %d at version %d got %v, expected ErrContentTooLong", n+1, mode, version, err)
+			}
+		}
+	}
+}
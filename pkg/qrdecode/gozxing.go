@@ -0,0 +1,202 @@
+package qrdecode
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/multi/qrcode/detector"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/makiuchi-d/gozxing/qrcode/decoder"
+)
+
+// GozxingDecoder is the default Decoder, based on the pure Go gozxing library. It
+// reads frames of a screen held up to a camera, but misses some of the densest
+// codes, as well as strongly blurred or skewed frames.
+type GozxingDecoder struct {
+	// TryHarder spends more time searching for the QR code in every image
+	TryHarder bool
+}
+
+// NewGozxingDecoder creates a GozxingDecoder
+func NewGozxingDecoder() *GozxingDecoder {
+	return &GozxingDecoder{}
+}
+
+// Decode reads the QR code of img. The finder pattern detection of gozxing
+// occasionally rejects clean, unskewed codes, such as frames of a generated video,
+// so a failed image is decoded again as a pure barcode, then with its colors
+// inverted for light codes on a dark background.
+func (d *GozxingDecoder) Decode(img image.Image) ([]byte, error) {
+	result, err := d.decodeSource(gozxing.NewLuminanceSourceFromImage(img))
+	if err != nil {
+		return nil, err
+	}
+
+	return resultContent(result), nil
+}
+
+// decodeSource reads the QR code of src, again with its colors inverted if it
+// fails, since gozxing only finds dark codes on a light background
+func (d *GozxingDecoder) decodeSource(src gozxing.LuminanceSource) (*gozxing.Result, error) {
+	bmp, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create binary bitmap: %w", err)
+	}
+
+	result, err := d.decode(bmp)
+	if err == nil {
+		return result, nil
+	}
+
+	if inverted, invErr := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(src.Invert())); invErr == nil {
+		if result, invErr := d.decode(inverted); invErr == nil {
+			return result, nil
+		}
+	}
+
+	return nil, err
+}
+
+// decode reads the QR code of bmp, again as a pure barcode if it fails
+func (d *GozxingDecoder) decode(bmp *gozxing.BinaryBitmap) (*gozxing.Result, error) {
+	reader := zxingqrcode.NewQRCodeReader()
+	hints := d.hints()
+
+	result, err := reader.Decode(bmp, hints)
+	if err != nil {
+		hints[gozxing.DecodeHintType_PURE_BARCODE] = true
+
+		var pureErr error
+		if result, pureErr = reader.Decode(bmp, hints); pureErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotFound, err)
+		}
+	}
+
+	return result, nil
+}
+
+// DecodeAll reads every QR code of img, without joining those linked by
+// structured append. An image in which the multiple QR code detector finds none
+// is decoded like by Decode, so a single code is read as reliably.
+func (d *GozxingDecoder) DecodeAll(img image.Image) ([][]byte, error) {
+	symbols, err := d.DecodeSymbols(img)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([][]byte, 0, len(symbols))
+	for _, symbol := range symbols {
+		contents = append(contents, symbol.Content)
+	}
+
+	return contents, nil
+}
+
+// DecodeSymbols reads every QR code of img with its structured append header, or
+// the single QR code found by Decode if the multiple QR code detector finds none,
+// e.g. in an inverted image.
+func (d *GozxingDecoder) DecodeSymbols(img image.Image) ([]Symbol, error) {
+	src := gozxing.NewLuminanceSourceFromImage(img)
+
+	bmp, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create binary bitmap: %w", err)
+	}
+
+	var symbols []Symbol
+
+	hints := d.hints()
+	if matrix, err := bmp.GetBlackMatrix(); err == nil {
+		if results, err := detector.NewMultiDetector(matrix).DetectMulti(hints); err == nil {
+			for _, r := range results {
+				result, err := decoder.NewDecoder().Decode(r.GetBits(), hints)
+				if err != nil {
+					continue
+				}
+
+				// The symbology modifiers of QR codes with an ECI header are even
+				content := rawContent(result.GetText(), result.GetSymbologyModifier()%2 == 0)
+
+				symbols = append(symbols, newSymbol(content, result.HasStructuredAppend(),
+					result.GetStructuredAppendSequenceNumber(), result.GetStructuredAppendParity()))
+			}
+		}
+	}
+
+	if len(symbols) > 0 {
+		return symbols, nil
+	}
+
+	result, err := d.decodeSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	sequence, hasSequence := result.GetResultMetadata()[gozxing.ResultMetadataType_STRUCTURED_APPEND_SEQUENCE].(int)
+	parity, _ := result.GetResultMetadata()[gozxing.ResultMetadataType_STRUCTURED_APPEND_PARITY].(int)
+
+	return []Symbol{newSymbol(resultContent(result), hasSequence, sequence, parity)}, nil
+}
+
+// newSymbol returns the symbol of a QR code of content, with the structured
+// append header of the sequence and parity read by gozxing if hasHeader is set
+func newSymbol(content []byte, hasHeader bool, sequence, parity int) Symbol {
+	symbol := Symbol{Content: content}
+
+	// The sequence holds the index in the high 4 bits, the total minus one in the low
+	if hasHeader {
+		symbol.StructuredAppend = &qrcode.StructuredAppend{
+			Index:  sequence >> 4,
+			Total:  sequence&0xf + 1,
+			Parity: byte(parity),
+		}
+	}
+
+	return symbol
+}
+
+// hints returns the decoding hints of d. Byte mode data is decoded as ISO-8859-1,
+// whose characters map one to one to the bytes, unless the QR code states another
+// character set.
+func (d *GozxingDecoder) hints() map[gozxing.DecodeHintType]interface{} {
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_CHARACTER_SET: "ISO-8859-1"}
+	if d.TryHarder {
+		hints[gozxing.DecodeHintType_TRY_HARDER] = true
+	}
+
+	return hints
+}
+
+// resultContent returns the content of the QR code of result, see rawContent.
+// The symbology identifier, "]Q2" for instance, tells a QR code with an ECI
+// header by its even modifier.
+func resultContent(result *gozxing.Result) []byte {
+	identifier, _ := result.GetResultMetadata()[gozxing.ResultMetadataType_SYMBOLOGY_IDENTIFIER].(string)
+	eci := strings.HasPrefix(identifier, "]Q") && strings.ContainsAny(identifier[2:], "246")
+
+	return rawContent(result.GetText(), eci)
+}
+
+// rawContent returns the bytes of text decoded as ISO-8859-1, or text as UTF-8 if
+// it holds other characters, from Kanji mode or another character set, or if
+// the QR code designates its character set with an ECI header
+func rawContent(text string, eci bool) []byte {
+	if eci {
+		return []byte(text)
+	}
+
+	content := make([]byte, 0, len(text))
+
+	for _, r := range text {
+		if r > 0xFF {
+			return []byte(text)
+		}
+
+		content = append(content, byte(r))
+	}
+
+	return content
+}
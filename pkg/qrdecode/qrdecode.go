@@ -0,0 +1,122 @@
+// Package qrdecode reads QR codes from images behind the Decoder interface, so
+// that the gozxing based default can be swapped for another decoder such as zbar,
+// quirc, or a decoding service, e.g. one that copes better with blurred frames.
+package qrdecode
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// ErrNotFound is returned, possibly wrapped, when an image holds no readable QR
+// code. Other errors tell that the decoder itself failed, e.g. a decoding service
+// that cannot be reached.
+var ErrNotFound = errors.New("no QR code found")
+
+// Decoder reads the QR code of an image
+type Decoder interface {
+	// Decode returns the raw content of the QR code shown in img. Byte mode data is
+	// returned verbatim, without any character set conversion, since chunk payloads
+	// are binary.
+	Decode(img image.Image) ([]byte, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface
+type DecoderFunc func(img image.Image) ([]byte, error)
+
+// Decode calls f(img)
+func (f DecoderFunc) Decode(img image.Image) ([]byte, error) {
+	return f(img)
+}
+
+// Fallback returns a Decoder that tries the decoders in turn until one reads the
+// QR code, e.g. a local decoder first and a slower service for the images it
+// misses. It fails with the error of the first decoder.
+func Fallback(decoders ...Decoder) Decoder {
+	return DecoderFunc(func(img image.Image) ([]byte, error) {
+		var firstErr error
+
+		for _, d := range decoders {
+			content, err := d.Decode(img)
+			if err == nil {
+				return content, nil
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if firstErr == nil {
+			return nil, fmt.Errorf("%w: no decoder", ErrNotFound)
+		}
+
+		return nil, firstErr
+	})
+}
+
+// MultiDecoder is a Decoder that also reads every QR code of an image, e.g. a
+// video frame tiling several codes
+type MultiDecoder interface {
+	Decoder
+
+	// DecodeAll returns the raw content of every QR code shown in img, in no
+	// particular order, and fails if it holds none
+	DecodeAll(img image.Image) ([][]byte, error)
+}
+
+// DecodeAll returns the content of every QR code of img read by d, or the content
+// of the only QR code read by d.Decode if d is not a MultiDecoder
+func DecodeAll(d Decoder, img image.Image) ([][]byte, error) {
+	if m, ok := d.(MultiDecoder); ok {
+		return m.DecodeAll(img)
+	}
+
+	content, err := d.Decode(img)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{content}, nil
+}
+
+// Symbol is the content of a QR code with its structured append header
+type Symbol struct {
+	Content []byte
+	// StructuredAppend links the QR code to the others of a message split across
+	// several QR codes, nil for a QR code holding a whole message
+	StructuredAppend *qrcode.StructuredAppend
+}
+
+// SymbolDecoder is a MultiDecoder that also reads the structured append header of
+// every QR code, whose messages DecodeAll may join from the QR codes of an image
+type SymbolDecoder interface {
+	MultiDecoder
+
+	// DecodeSymbols returns every QR code shown in img with its structured append
+	// header, in no particular order, and fails if it holds none
+	DecodeSymbols(img image.Image) ([]Symbol, error)
+}
+
+// DecodeSymbols returns every QR code of img read by d with its structured append
+// header, or their content alone read by DecodeAll if d is not a SymbolDecoder
+func DecodeSymbols(d Decoder, img image.Image) ([]Symbol, error) {
+	if s, ok := d.(SymbolDecoder); ok {
+		return s.DecodeSymbols(img)
+	}
+
+	contents, err := DecodeAll(d, img)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make([]Symbol, len(contents))
+	for i, content := range contents {
+		symbols[i] = Symbol{Content: content}
+	}
+
+	return symbols, nil
+}
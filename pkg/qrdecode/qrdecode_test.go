@@ -0,0 +1,253 @@
+package qrdecode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestGozxingDecoderRawBytes(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	q, err := qrcode.NewBytes(data, qrcode.Medium)
+	if err != nil {
+		t.Fatalf("NewBytes failed: %v", err)
+	}
+
+	content, err := NewGozxingDecoder().Decode(q.Image(-4))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if !bytes.Equal(content, data) {
+		t.Fatal("Decoded content differs from the encoded bytes")
+	}
+}
+
+func TestGozxingDecoderText(t *testing.T) {
+	for _, text := range []string{"QRFT:0123456789", "HELLO WORLD 42", "héllo, wörld"} {
+		q, err := qrcode.New(text, qrcode.Medium)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+
+		content, err := NewGozxingDecoder().Decode(q.Image(-4))
+		if err != nil {
+			t.Fatalf("Decode(%q) failed: %v", text, err)
+		}
+
+		if string(content) != text {
+			t.Fatalf("Decode(%q) = %q", text, content)
+		}
+	}
+}
+
+func TestGozxingDecoderInternationalText(t *testing.T) {
+	for _, text := range []string{"café.txt", "ファイル名.txt", "名前 日本", "Ünïcödé ✓ 文件", "ASCII only"} {
+		q, err := qrcode.NewText(text, qrcode.Medium)
+		if err != nil {
+			t.Fatalf("NewText failed: %v", err)
+		}
+
+		symbols, err := NewGozxingDecoder().DecodeSymbols(q.Image(-4))
+		if err != nil || len(symbols) != 1 {
+			t.Fatalf("DecodeSymbols(%q) = %d symbols, %v", text, len(symbols), err)
+		}
+
+		content, err := NewGozxingDecoder().Decode(q.Image(-4))
+		if err != nil {
+			t.Fatalf("Decode(%q) failed: %v", text, err)
+		}
+
+		if string(content) != text || string(symbols[0].Content) != text {
+			t.Fatalf("Decode(%q) = %q, DecodeSymbols = %q", text, content, symbols[0].Content)
+		}
+	}
+}
+
+func TestGozxingDecoderInverted(t *testing.T) {
+	logo := image.NewGray(image.Rect(0, 0, 10, 10))
+
+	for _, options := range []qrcode.Options{
+		{ForegroundColor: color.White, BackgroundColor: color.Black},
+		{ForegroundColor: color.RGBA{R: 0xe0, G: 0xe0, B: 0x40, A: 0xff}, BackgroundColor: color.RGBA{B: 0x30, A: 0xff}, Logo: logo},
+		{ForegroundColor: color.RGBA{B: 0x80, A: 0xff}, BackgroundColor: color.White, QuietZone: 1, Logo: logo},
+	} {
+		q, err := qrcode.NewWithOptions("QRFT:inverted colors", qrcode.Medium, options)
+		if err != nil {
+			t.Fatalf("NewWithOptions failed: %v", err)
+		}
+
+		content, err := NewGozxingDecoder().Decode(q.Image(-4))
+		if err != nil || string(content) != q.Content {
+			t.Errorf("Decode of colors %v on %v = %q, %v", options.ForegroundColor, options.BackgroundColor, content, err)
+		}
+
+		if symbols, err := NewGozxingDecoder().DecodeSymbols(q.Image(-4)); err != nil || len(symbols) != 1 || string(symbols[0].Content) != q.Content {
+			t.Errorf("DecodeSymbols of colors %v on %v = %v, %v", options.ForegroundColor, options.BackgroundColor, symbols, err)
+		}
+	}
+}
+
+func TestGozxingDecoderFastMask(t *testing.T) {
+	for _, size := range []int{10, 256, 2000} {
+		data := bytes.Repeat([]byte{0x5a, 0xc3, 0x0f, 0x96, 0x00}, size/5)
+
+		q, err := qrcode.NewWithOptions(string(data), qrcode.Low, qrcode.Options{FastMask: true})
+		if err != nil {
+			t.Fatalf("NewWithOptions failed: %v", err)
+		}
+
+		content, err := NewGozxingDecoder().Decode(q.Image(-4))
+		if err != nil || !bytes.Equal(content, data) {
+			t.Errorf("Decode of %d bytes with a fixed mask failed: %v", len(data), err)
+		}
+	}
+}
+
+func TestGozxingDecoderNotFound(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 64, 64))
+
+	if _, err := NewGozxingDecoder().Decode(blank); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Decode of a blank image = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	failing := DecoderFunc(func(image.Image) ([]byte, error) {
+		return nil, ErrNotFound
+	})
+	decoding := DecoderFunc(func(image.Image) ([]byte, error) {
+		return []byte("content"), nil
+	})
+
+	if content, err := Fallback(failing, decoding).Decode(nil); err != nil || string(content) != "content" {
+		t.Fatalf("Fallback decoded %q, %v", content, err)
+	}
+
+	if _, err := Fallback(failing, failing).Decode(nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fallback of failing decoders = %v, want ErrNotFound", err)
+	}
+
+	if _, err := Fallback().Decode(nil); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fallback without decoders = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGozxingDecoderDecodeAll(t *testing.T) {
+	for _, grid := range [][2]int{{2, 2}, {3, 3}} {
+		var (
+			images []image.Image
+			want   = make(map[string]bool)
+		)
+
+		for i := range grid[0] * grid[1] {
+			data := bytes.Repeat([]byte{byte(i), 0xFF}, 60)
+
+			q, err := qrcode.NewBytes(data, qrcode.Medium)
+			if err != nil {
+				t.Fatalf("NewBytes failed: %v", err)
+			}
+
+			images = append(images, q.Image(-4))
+			want[string(data)] = true
+		}
+
+		contents, err := NewGozxingDecoder().DecodeAll(imaging.Tile(images, grid[0], grid[1]))
+		if err != nil {
+			t.Fatalf("DecodeAll of %dx%d codes failed: %v", grid[0], grid[1], err)
+		}
+
+		for _, content := range contents {
+			delete(want, string(content))
+		}
+
+		if len(want) > 0 {
+			t.Fatalf("DecodeAll of %dx%d codes missed %d codes", grid[0], grid[1], len(want))
+		}
+	}
+}
+
+func TestDecodeAllSingle(t *testing.T) {
+	single := DecoderFunc(func(image.Image) ([]byte, error) {
+		return []byte("content"), nil
+	})
+
+	contents, err := DecodeAll(single, nil)
+	if err != nil || len(contents) != 1 || string(contents[0]) != "content" {
+		t.Fatalf("DecodeAll = %q, %v", contents, err)
+	}
+}
+
+func TestGozxingDecoderDecodeSymbols(t *testing.T) {
+	message := make([]byte, 600)
+	for i := range message {
+		message[i] = byte(i * 13)
+	}
+
+	codes, err := qrcode.SplitStructuredAppend(message, qrcode.Medium, 8)
+	if err != nil {
+		t.Fatalf("SplitStructuredAppend failed: %v", err)
+	}
+
+	images := make([]image.Image, len(codes))
+	for i, q := range codes {
+		images[i] = q.Image(-4)
+
+		// A single QR code is read with its header
+		symbols, err := NewGozxingDecoder().DecodeSymbols(images[i])
+		if err != nil || len(symbols) != 1 {
+			t.Fatalf("DecodeSymbols of QR code %d = %d symbols, %v", i, len(symbols), err)
+		}
+
+		if sa := symbols[0].StructuredAppend; sa == nil || *sa != *q.StructuredAppend {
+			t.Errorf("DecodeSymbols of QR code %d header = %v, want %v", i, sa, *q.StructuredAppend)
+		}
+
+		if !bytes.Equal(symbols[0].Content, []byte(q.Content)) {
+			t.Errorf("DecodeSymbols of QR code %d content differs", i)
+		}
+	}
+
+	// The QR codes of an image are not joined
+	symbols, err := NewGozxingDecoder().DecodeSymbols(imaging.Tile(images[:2], 2, 1))
+	if err != nil || len(symbols) != 2 {
+		t.Fatalf("DecodeSymbols of 2 tiled codes = %d symbols, %v", len(symbols), err)
+	}
+
+	for _, s := range symbols {
+		if s.StructuredAppend == nil {
+			t.Error("DecodeSymbols of tiled codes lost a structured append header")
+		}
+	}
+
+	// A QR code without header has none
+	q, err := qrcode.NewBytes([]byte("whole"), qrcode.Medium)
+	if err != nil {
+		t.Fatalf("NewBytes failed: %v", err)
+	}
+
+	symbols, err = DecodeSymbols(NewGozxingDecoder(), q.Image(-4))
+	if err != nil || len(symbols) != 1 || symbols[0].StructuredAppend != nil || string(symbols[0].Content) != "whole" {
+		t.Fatalf("DecodeSymbols of a plain QR code = %v, %v", symbols, err)
+	}
+}
+
+func TestDecodeSymbolsFallback(t *testing.T) {
+	single := DecoderFunc(func(image.Image) ([]byte, error) {
+		return []byte("content"), nil
+	})
+
+	symbols, err := DecodeSymbols(single, nil)
+	if err != nil || len(symbols) != 1 || string(symbols[0].Content) != "content" || symbols[0].StructuredAppend != nil {
+		t.Fatalf("DecodeSymbols = %v, %v", symbols, err)
+	}
+}
@@ -0,0 +1,181 @@
+package qrfiletransfer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ackMagic and ackVersion start the first line of an acknowledgement
+	ackMagic   = "QRFT-ACK"
+	ackVersion = 1
+
+	// MaxAckMissing is the largest number of missing chunks an acknowledgement
+	// lists, the lowest ones, so that it fits in a QR code that is easy to scan.
+	// The others are listed by later acknowledgements once those are received.
+	MaxAckMissing = 256
+)
+
+// ErrBadAck is returned when a QR code is not a valid acknowledgement
+var ErrBadAck = errors.New("bad acknowledgement")
+
+// Ack is the acknowledgement a receiver shows in a status QR code for the camera of
+// the sender, so that the sender only repeats the chunks still missing
+type Ack struct {
+	// Hash is the SHA-256 of the file, empty until the receiver has read it
+	Hash string
+	// Total is the number of chunks of the file, 0 while the receiver does not know it
+	Total int
+	// Missing lists the indices of the chunks the receiver still needs, in order.
+	// While Total is unknown it only covers the gaps below the highest chunk read.
+	Missing []int
+}
+
+// NewAck returns the acknowledgement of the chunks of a file with the given hash
+// in report. The missing chunks that the parity chunks restore are not needed.
+func NewAck(report *ChunkReport, hash string) *Ack {
+	restorable := make(map[int]bool, len(report.Restorable))
+	for _, index := range report.Restorable {
+		restorable[index] = true
+	}
+
+	ack := &Ack{Hash: hash, Total: report.Total}
+
+	for _, index := range report.Missing {
+		if !restorable[index] && len(ack.Missing) < MaxAckMissing {
+			ack.Missing = append(ack.Missing, index)
+		}
+	}
+
+	return ack
+}
+
+// Complete reports whether the receiver has every chunk it needs
+func (a *Ack) Complete() bool {
+	return a.Total > 0 && len(a.Missing) == 0
+}
+
+// EncodeAck returns the content of the status QR code of an acknowledgement:
+//
+//	QRFT-ACK 1
+//	SHA256 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	CHUNKS 120
+//	MISSING 3-5,17,80-84
+//
+// The SHA256 line is left out while the hash is unknown, and CHUNKS is 0 while the
+// number of chunks is.
+func EncodeAck(a *Ack) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%s %d\n", ackMagic, ackVersion)
+
+	if a.Hash != "" {
+		fmt.Fprintf(&b, "SHA256 %s\n", a.Hash)
+	}
+
+	fmt.Fprintf(&b, "CHUNKS %d\n", a.Total)
+	fmt.Fprintf(&b, "MISSING %s\n", strings.ReplaceAll(FormatIndexRanges(a.Missing), " ", ""))
+
+	return b.Bytes()
+}
+
+// ParseAck parses the content of a status QR code written by EncodeAck. It returns
+// an error wrapping ErrBadAck for content that is not an acknowledgement.
+func ParseAck(content []byte) (*Ack, error) {
+	if !bytes.HasPrefix(content, []byte(ackMagic+" ")) {
+		return nil, fmt.Errorf("%w: missing %s line", ErrBadAck, ackMagic)
+	}
+
+	var (
+		a     Ack
+		total bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		keyword, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+
+		switch keyword {
+		case ackMagic:
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid version %q", ErrBadAck, value)
+			}
+
+			if v > ackVersion {
+				return nil, fmt.Errorf("%w: acknowledgement version %d (newest supported is %d)", ErrUnsupportedVersion, v, ackVersion)
+			}
+		case "SHA256":
+			a.Hash = strings.ToLower(value)
+		case "CHUNKS":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: invalid chunk count %q", ErrBadAck, value)
+			}
+
+			a.Total, total = n, true
+		case "MISSING":
+			missing, err := parseIndexRanges(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrBadAck, err)
+			}
+
+			a.Missing = missing
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadAck, err)
+	}
+
+	if !total {
+		return nil, fmt.Errorf("%w: missing CHUNKS line", ErrBadAck)
+	}
+
+	for _, index := range a.Missing {
+		if a.Total > 0 && index >= a.Total {
+			return nil, fmt.Errorf("%w: missing chunk %d of %d", ErrBadAck, index, a.Total)
+		}
+	}
+
+	return &a, nil
+}
+
+// parseIndexRanges parses the ranges of increasing chunk indices written by
+// FormatIndexRanges, with or without spaces
+func parseIndexRanges(s string) ([]int, error) {
+	var indices []int
+
+	for _, part := range strings.Split(strings.ReplaceAll(s, " ", ""), ",") {
+		if part == "" {
+			continue
+		}
+
+		first, last, isRange := strings.Cut(part, "-")
+
+		start, err := strconv.Atoi(first)
+		end := start
+
+		if err == nil && isRange {
+			end, err = strconv.Atoi(last)
+		}
+
+		if err != nil || start < 0 || end < start || (len(indices) > 0 && start <= indices[len(indices)-1]) {
+			return nil, fmt.Errorf("invalid index range %q", part)
+		}
+
+		if len(indices)+end-start >= MaxAckMissing {
+			return nil, fmt.Errorf("more than %d indices", MaxAckMissing)
+		}
+
+		for index := start; index <= end; index++ {
+			indices = append(indices, index)
+		}
+	}
+
+	return indices, nil
+}
@@ -0,0 +1,80 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAckRoundTrip(t *testing.T) {
+	report := &ChunkReport{Total: 90, Missing: []int{3, 4, 5, 17, 80, 81}, Restorable: []int{17}}
+
+	ack := NewAck(report, "9F86D081")
+	if ack.Complete() || !reflect.DeepEqual(ack.Missing, []int{3, 4, 5, 80, 81}) {
+		t.Fatalf("NewAck() = %+v, want the chunks missing and not restorable", ack)
+	}
+
+	content := EncodeAck(ack)
+	if !strings.Contains(string(content), "MISSING 3-5,80-81\n") {
+		t.Errorf("EncodeAck() = %q, want compact ranges", content)
+	}
+
+	got, err := ParseAck(content)
+	if err != nil {
+		t.Fatalf("ParseAck failed: %v", err)
+	}
+
+	if got.Hash != "9f86d081" || got.Total != 90 || !reflect.DeepEqual(got.Missing, ack.Missing) {
+		t.Errorf("ParseAck() = %+v, want %+v", got, ack)
+	}
+
+	// Every chunk read, or restorable
+	done := NewAck(&ChunkReport{Total: 90, Missing: []int{17}, Restorable: []int{17}}, "")
+	if got, err := ParseAck(EncodeAck(done)); err != nil || !got.Complete() || got.Hash != "" {
+		t.Errorf("ParseAck() = %+v, %v, want a complete acknowledgement", got, err)
+	}
+
+	// The number of chunks is not known yet
+	if got, err := ParseAck(EncodeAck(&Ack{Missing: []int{0}})); err != nil || got.Complete() || got.Total != 0 {
+		t.Errorf("ParseAck() = %+v, %v, want an incomplete acknowledgement", got, err)
+	}
+}
+
+func TestNewAckLimit(t *testing.T) {
+	report := &ChunkReport{Total: 1000}
+	for i := 0; i < 1000; i += 2 {
+		report.Missing = append(report.Missing, i)
+	}
+
+	ack := NewAck(report, "")
+	if len(ack.Missing) != MaxAckMissing || ack.Missing[MaxAckMissing-1] != 2*(MaxAckMissing-1) {
+		t.Fatalf("NewAck() lists %d chunks, want the lowest %d", len(ack.Missing), MaxAckMissing)
+	}
+
+	if _, err := ParseAck(EncodeAck(ack)); err != nil {
+		t.Errorf("ParseAck failed: %v", err)
+	}
+}
+
+func TestParseAckInvalid(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"QRFT-TEXT 1\nCHUNKS 3\n",
+		"QRFT-ACK x\nCHUNKS 3\n",
+		"QRFT-ACK 1\nMISSING 1\n",
+		"QRFT-ACK 1\nCHUNKS -1\n",
+		"QRFT-ACK 1\nCHUNKS 3\nMISSING 5\n",
+		"QRFT-ACK 1\nCHUNKS 9\nMISSING 4,2\n",
+		"QRFT-ACK 1\nCHUNKS 9\nMISSING 4-2\n",
+		"QRFT-ACK 1\nCHUNKS 0\nMISSING 0-1000\n",
+	} {
+		if _, err := ParseAck([]byte(content)); !errors.Is(err, ErrBadAck) {
+			t.Errorf("ParseAck(%q) error = %v, want %v", content, err, ErrBadAck)
+		}
+	}
+
+	if _, err := ParseAck([]byte("QRFT-ACK 2\nCHUNKS 3\n")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("ParseAck() of a newer version error = %v, want %v", err, ErrUnsupportedVersion)
+	}
+}
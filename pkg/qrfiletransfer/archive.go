@@ -0,0 +1,430 @@
+package qrfiletransfer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// FileInfo describes a transferred file as recorded in the split metadata of its
+// first chunk
+type FileInfo = split.FileInfo
+
+// dirArchiveExt is appended to the name of a directory to name its archive
+const dirArchiveExt = ".tar.gz"
+
+// DirToQRCodes converts a directory tree to a series of QR codes.
+// The tree is packed into a gzip compressed tar archive named after the directory,
+// which is encoded like a file by FileToQRCodes. The split metadata marks the file
+// as a directory archive, so a receiver can restore the tree with QRCodesToDir.
+// Regular files and directories are archived with their permission bits and
+// modification times; other file types, such as symbolic links, are rejected.
+// Parameters:
+//   - dirPath: Path to the directory to convert
+//   - outDir: Directory to store the QR codes
+//
+// Returns an error if any part of the process fails.
+func (q *QRFileTransfer) DirToQRCodes(dirPath string, outDir string) error {
+	info, err := q.fs.Stat(dirPath)
+	if err != nil {
+		return fmt.Errorf("failed to get directory info: %w", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dirPath)
+	}
+
+	tempDir, releaseTemp, err := q.makeTempDir("qrcode_archive_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		_ = releaseTemp()
+	}()
+
+	archivePath := filepath.Join(tempDir, dirArchiveName(dirPath))
+	if err := writeDirArchive(q.fs, dirPath, archivePath, q.deterministic); err != nil {
+		return err
+	}
+
+	return q.fileToQRCodes(archivePath, outDir, info)
+}
+
+// QRCodesToDir reconstructs a directory tree encoded by DirToQRCodes into outDir,
+// which must not exist or be empty. Like QRCodesToFile, every chunk is verified
+// before the archive is unpacked. Entries that would be written outside outDir are
+// rejected.
+func (q *QRFileTransfer) QRCodesToDir(inDir string, outDir string) error {
+	info, err := q.ReadFileInfo(inDir)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode.IsDir() {
+		return fmt.Errorf("%s holds the file %s, not a directory archive", inDir, info.Name)
+	}
+
+	if entries, err := afero.ReadDir(q.fs, outDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", outDir)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	tempDir, releaseTemp, err := q.makeTempDir("qrcode_archive_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		_ = releaseTemp()
+	}()
+
+	archivePath := filepath.Join(tempDir, split.SafeFileName(info.Name))
+	if err := q.QRCodesToFile(inDir, archivePath); err != nil {
+		return err
+	}
+
+	if err := extractDirArchive(q.fs, archivePath, outDir); err != nil {
+		return err
+	}
+
+	// Restore the root of the tree last, extracting its entries changed its mtime
+	if err := q.fs.Chmod(outDir, info.Mode.Perm()); err != nil {
+		return fmt.Errorf("failed to restore directory mode: %w", err)
+	}
+
+	if !info.ModTime.IsZero() {
+		if err := q.fs.Chtimes(outDir, time.Time{}, info.ModTime); err != nil {
+			return fmt.Errorf("failed to restore directory modification time: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ReadFileInfo reads the description of the transferred file recorded in the split
+// metadata of the first chunk of inDir, a session directory or a directory written
+// by read or scan. FileInfo.Mode has os.ModeDir set for a directory archive.
+func (q *QRFileTransfer) ReadFileInfo(inDir string) (*FileInfo, error) {
+	session, err := openSession(q.fs, inDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(session.Chunks) == 0 || session.DataFile(session.Chunks[0].Name) == "" {
+		return nil, fmt.Errorf("session in %s has no data files", inDir)
+	}
+
+	if session.isFramed() {
+		return nil, fmt.Errorf("session in %s holds %s frames, which carry no file metadata", inDir, session.Settings.Protocol)
+	}
+
+	// A legacy session of received chunks starts with the first chunk received
+	first := session.DataFile(session.Chunks[0].Name)
+	if index, ok := chunkIndex(first); !fileExists(q.fs, first) || (ok && index != 0) {
+		return q.restoredFileInfo(session)
+	}
+
+	info, err := q.splitter.ReadFileInfo(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	return info, nil
+}
+
+// restoredFileInfo returns the description of the file of session recorded in its
+// first chunk, which is missing, restored from the parity chunks
+func (q *QRFileTransfer) restoredFileInfo(session *Session) (*FileInfo, error) {
+	repaired, err := q.repairChunks(session, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	data, ok := repaired[session.Chunks[0].Name]
+	if !ok {
+		return nil, fmt.Errorf("failed to read file metadata: %w", &ErrMissingChunk{Index: 0})
+	}
+
+	// The restored chunk is read from memory
+	mem := afero.NewMemMapFs()
+	if err := afero.WriteFile(mem, "chunk.part", data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	splitter := split.NewSplit()
+	splitter.SetFs(mem)
+
+	info, err := splitter.ReadFileInfo("chunk.part")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	return info, nil
+}
+
+// dirArchiveName returns the name of the archive of the directory at dirPath
+func dirArchiveName(dirPath string) string {
+	name := filepath.Base(filepath.Clean(dirPath))
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		if abs, err := filepath.Abs(dirPath); err == nil && filepath.Base(abs) != string(filepath.Separator) {
+			name = filepath.Base(abs)
+		} else {
+			name = "archive"
+		}
+	}
+
+	return name + dirArchiveExt
+}
+
+// writeDirArchive packs the tree rooted at dir into a gzip compressed tar archive at
+// archivePath. Entries are named relative to dir and written in lexical order, so
+// the same tree always yields the same archive and interrupted runs can be resumed.
+// With omitTimes, the modification times of the entries are recorded as the Unix
+// epoch, which extractDirArchive does not restore, so the archive only depends on
+// the names, modes and contents of the tree.
+func writeDirArchive(fsys afero.Fs, dir, archivePath string, omitTimes bool) (err error) {
+	out, err := fsys.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	defer func() {
+		closeErr := out.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close archive: %w", closeErr)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	err = afero.Walk(fsys, dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return fmt.Errorf("%s is not a regular file or directory", p)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		// Owners do not carry over to the receiving machine
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		if omitTimes {
+			header.ModTime = time.Unix(0, 0)
+		}
+
+		header.Format = tar.FormatPAX
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = f.Close()
+		}()
+
+		if _, err := io.CopyN(tw, f, info.Size()); err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return nil
+}
+
+// extractDirArchive unpacks an archive written by writeDirArchive into dir,
+// restoring permission bits and modification times. Times at the Unix epoch were
+// omitted and are not restored.
+func extractDirArchive(fsys afero.Fs, archivePath, dir string) error {
+	f, err := fsys.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Directory modes and times are restored last, creating their entries needs
+	// write permission and changes their times
+	type dirAttrs struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+
+	var dirs []dirAttrs
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name, ok := archiveEntryPath(header.Name)
+		if !ok {
+			return fmt.Errorf("invalid archive entry %q", header.Name)
+		}
+
+		target := filepath.Join(dir, name)
+		mode := header.FileInfo().Mode().Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := fsys.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+
+			dirs = append(dirs, dirAttrs{target, mode, header.ModTime})
+		case tar.TypeReg:
+			if err := fsys.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+
+			if err := extractArchiveFile(fsys, tr, target, mode); err != nil {
+				return err
+			}
+
+			if err := restoreModTime(fsys, target, header.ModTime); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported archive entry %q", header.Name)
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := restoreModTime(fsys, dirs[i].path, dirs[i].modTime); err != nil {
+			return err
+		}
+
+		if err := fsys.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return fmt.Errorf("failed to restore directory mode: %w", err)
+		}
+	}
+
+	return gz.Close()
+}
+
+// extractArchiveFile writes the current entry of tr to path
+func extractArchiveFile(fsys afero.Fs, tr *tar.Reader, path string, mode os.FileMode) (err error) {
+	out, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	defer func() {
+		closeErr := out.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close %s: %w", path, closeErr)
+		}
+	}()
+
+	if _, err := io.Copy(out, tr); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := fsys.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to restore file mode: %w", err)
+	}
+
+	return nil
+}
+
+// archiveEntryPath returns the local relative path of an archive entry, or false if
+// the entry is absolute or would escape the directory it is extracted into
+func archiveEntryPath(name string) (string, bool) {
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) {
+		return "", false
+	}
+
+	clean := path.Clean(name)
+	if clean != name || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+
+	return filepath.FromSlash(clean), true
+}
+
+// restoreModTime sets the modification time of path to modTime from an archive
+// entry, unless it was omitted
+func restoreModTime(fsys afero.Fs, path string, modTime time.Time) error {
+	if modTime.Unix() == 0 {
+		return nil
+	}
+
+	if err := fsys.Chtimes(path, time.Time{}, modTime); err != nil {
+		return fmt.Errorf("failed to restore modification time: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package qrfiletransfer
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDirToQRCodes(t *testing.T) {
+	testDir := t.TempDir()
+
+	// A tree with nested paths, an empty directory, and an executable script
+	srcDir := filepath.Join(testDir, "project")
+	files := map[string]string{
+		"README.md":               "A project sent as QR codes.\n",
+		"src/main.go":             "package main\n\nfunc main() {}\n",
+		"src/internal/util/u.go":  "package util\n",
+		"scripts/build.sh":        "#!/bin/sh\ngo build ./...\n",
+		"docs/notes/2024/day.txt": "nested deeply\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(srcDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(srcDir, "empty"), 0750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if err := os.Chmod(filepath.Join(srcDir, "scripts", "build.sh"), 0755); err != nil {
+		t.Fatalf("Failed to change file mode: %v", err)
+	}
+
+	modTime := time.Date(2022, 2, 2, 22, 2, 2, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(srcDir, "README.md"), modTime, modTime); err != nil {
+		t.Fatalf("Failed to change file time: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	outDir := filepath.Join(testDir, "session")
+
+	if err := qrft.DirToQRCodes(srcDir, outDir); err != nil {
+		t.Fatalf("DirToQRCodes failed: %v", err)
+	}
+
+	info, err := qrft.ReadFileInfo(outDir)
+	if err != nil {
+		t.Fatalf("ReadFileInfo failed: %v", err)
+	}
+
+	if !info.Mode.IsDir() || info.Name != "project.tar.gz" {
+		t.Errorf("Unexpected file info: %+v", info)
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil || !manifest.File.Dir {
+		t.Errorf("Expected the manifest to describe a directory archive: %+v (%v)", manifest, err)
+	}
+
+	restored := filepath.Join(testDir, "restored")
+	if err := qrft.QRCodesToDir(outDir, restored); err != nil {
+		t.Fatalf("QRCodesToDir failed: %v", err)
+	}
+
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(restored, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("Restored %s differs: %q (%v)", name, data, err)
+		}
+	}
+
+	if stat, err := os.Stat(filepath.Join(restored, "empty")); err != nil || !stat.IsDir() {
+		t.Errorf("Expected the empty directory to be restored (%v)", err)
+	}
+
+	if stat, err := os.Stat(filepath.Join(restored, "README.md")); err != nil || !stat.ModTime().Equal(modTime) {
+		t.Errorf("Expected the modification time to be restored (%v)", err)
+	}
+
+	if runtime.GOOS != "windows" {
+		if stat, err := os.Stat(filepath.Join(restored, "scripts", "build.sh")); err != nil || stat.Mode().Perm() != 0755 {
+			t.Errorf("Expected the script to stay executable (%v)", err)
+		}
+	}
+
+	// A non-empty output directory is not overwritten
+	if err := qrft.QRCodesToDir(outDir, restored); err == nil {
+		t.Error("Expected QRCodesToDir to refuse a non-empty directory")
+	}
+
+	// QRCodesToFile yields the archive itself
+	if err := qrft.QRCodesToFile(outDir, filepath.Join(testDir, "project.tar.gz")); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+}
+
+func TestArchiveEntryPath(t *testing.T) {
+	for name, want := range map[string]bool{
+		"file.txt":        true,
+		"dir/":            true,
+		"dir/sub/file":    true,
+		"":                false,
+		"/etc/passwd":     false,
+		"../escape":       false,
+		"dir/../../x":     false,
+		"./file":          false,
+		`dir\..\..\x`:     false,
+		"dir//file":       false,
+		"..":              false,
+		"dir/../file.txt": false,
+	} {
+		if _, ok := archiveEntryPath(name); ok != want {
+			t.Errorf("archiveEntryPath(%q) = %v, want %v", name, ok, want)
+		}
+	}
+}
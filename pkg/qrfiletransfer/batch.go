@@ -0,0 +1,472 @@
+package qrfiletransfer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// BatchFileName is the name of the file that describes a batch directory
+const BatchFileName = "batch.json"
+
+// BatchVersion is the version of the batch file written by this package
+const BatchVersion = 1
+
+// maxFileIDLength bounds the length of a file ID read from a payload
+const maxFileIDLength = 32
+
+// errBatchStopped is the error of the files of a batch not reconstructed after
+// another file failed
+var errBatchStopped = errors.New("stopped after another file failed")
+
+// Batch describes a batch directory produced by FilesToQRCodes.
+// Every file of a batch is encoded into its own session directory, named after the
+// file ID, inside the batch directory. The file ID is also written into the header
+// of every chunk payload, so the QR codes of all files can be shown in one sequence
+// and sorted out again by the receiver.
+type Batch struct {
+	// Version is the batch file version
+	Version int `json:"version"`
+	// Files lists every file of the batch in input order
+	Files []BatchFile `json:"files"`
+}
+
+// BatchFile describes a single file of a batch
+type BatchFile struct {
+	// ID is the file ID, which names the session directory of the file
+	ID string `json:"id"`
+	// Name is the base name of the input file
+	Name string `json:"name"`
+	// Size is the size of the encoded file in bytes
+	Size int64 `json:"size"`
+	// Dir is set when the file is an archive of a directory, see DirToQRCodes
+	Dir bool `json:"dir,omitempty"`
+}
+
+// BatchResult describes the reconstruction of a batch by BatchToFiles
+type BatchResult struct {
+	// Files lists the files written, in file ID order
+	Files []BatchOutput
+	// Incomplete maps the IDs of the files that could not be reconstructed yet to
+	// the report of their chunks
+	Incomplete map[string]*ChunkReport
+}
+
+// BatchOutput describes a file reconstructed from a batch
+type BatchOutput struct {
+	// ID is the file ID
+	ID string
+	// Path is the path the file, or directory tree, was written to
+	Path string
+	// Dir is set when a directory tree was restored
+	Dir bool
+}
+
+// FilesToQRCodes converts several files to one set of QR codes.
+// Each file is encoded like FileToQRCodes does into outDir/<id>, where id is the
+// position of the file in filePaths starting at 1, and carries its ID in every
+// chunk payload. Directories are archived as by DirToQRCodes. The batch is
+// described in outDir/batch.json, and the QR codes of all files are listed in
+// playback order in outDir/frames.json, see FrameOrder. Like FileToQRCodes, an
+// interrupted run resumes from the artifacts already written.
+// Parameters:
+//   - filePaths: Paths to the files to convert
+//   - outDir: Directory to store the QR codes
+//
+// Returns the batch description, or an error if any part of the process fails.
+func (q *QRFileTransfer) FilesToQRCodes(filePaths []string, outDir string) (*Batch, error) {
+	if len(filePaths) == 0 {
+		return nil, errors.New("no files to convert")
+	}
+
+	batch := &Batch{Version: BatchVersion}
+
+	for i, filePath := range filePaths {
+		info, err := q.fs.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file info: %w", err)
+		}
+
+		// Each file gets its own ID on a copy of the encoder settings
+		fq := *q
+		fq.fileID = strconv.Itoa(i + 1)
+		fileDir := filepath.Join(outDir, fq.fileID)
+
+		if info.IsDir() {
+			err = fq.DirToQRCodes(filePath, fileDir)
+		} else {
+			err = fq.FileToQRCodes(filePath, fileDir)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s: %w", filePath, err)
+		}
+
+		session, err := loadSession(q.fs, fileDir)
+		if err != nil {
+			return nil, err
+		}
+
+		batch.Files = append(batch.Files, BatchFile{
+			ID:   fq.fileID,
+			Name: session.File.Name,
+			Size: session.File.Size,
+			Dir:  session.File.Dir,
+		})
+	}
+
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch file: %w", err)
+	}
+
+	if err := WriteFileAtomic(q.fs, filepath.Join(outDir, BatchFileName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write batch file: %w", err)
+	}
+
+	order, err := buildFrameOrder(q.fs, outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := order.save(q.fs, outDir); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// LoadBatch reads the batch file from a batch directory.
+// It returns an error wrapping os.ErrNotExist if the directory has no batch file.
+func LoadBatch(dir string) (*Batch, error) {
+	return loadBatch(osFs, dir)
+}
+
+// loadBatch is LoadBatch on the file system fsys
+func loadBatch(fsys afero.Fs, dir string) (*Batch, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(dir, BatchFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file: %w", err)
+	}
+
+	var b Batch
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to decode batch file: %w", err)
+	}
+
+	if b.Version > BatchVersion {
+		return nil, fmt.Errorf("%w: batch version %d (newest supported is %d)", ErrUnsupportedVersion, b.Version, BatchVersion)
+	}
+
+	for _, f := range b.Files {
+		if !validFileID(f.ID) {
+			return nil, fmt.Errorf("invalid file ID %q in batch file", f.ID)
+		}
+	}
+
+	return &b, nil
+}
+
+// ChunkDataPath returns the path of the data file a decoded chunk is stored in
+// below dir, a directory written by read or scan. Chunks of a single file go into
+// dir/data, chunks of a file in a batch into the data directory of its file ID,
+// dir/<id>/data. The chunk name and file ID come from the scanned payload and are
+// checked not to escape dir.
+func ChunkDataPath(dir string, payload *ChunkPayload) (string, error) {
+	name := payload.Name
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid chunk name %q", name)
+	}
+
+	dataDir := defaultSessionLayout().Data
+	if payload.File != "" {
+		if !validFileID(payload.File) {
+			return "", fmt.Errorf("invalid file ID %q", payload.File)
+		}
+
+		dataDir = filepath.Join(payload.File, dataDir)
+	}
+
+	return filepath.Join(dir, dataDir, name+".dat"), nil
+}
+
+// BatchFileIDs returns the IDs of the files of a batch found in dir, in order.
+// The IDs are taken from the batch file if present, otherwise from the
+// subdirectories holding the chunks of a file, as written by read or scan. It
+// returns no IDs if dir is not a batch directory.
+func BatchFileIDs(dir string) ([]string, error) {
+	return batchFileIDs(osFs, dir)
+}
+
+// batchFileIDs is BatchFileIDs on the file system fsys
+func batchFileIDs(fsys afero.Fs, dir string) ([]string, error) {
+	if batch, err := loadBatch(fsys, dir); err == nil {
+		ids := make([]string, 0, len(batch.Files))
+		for _, f := range batch.Files {
+			ids = append(ids, f.ID)
+		}
+
+		return ids, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	entries, err := afero.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var ids []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !validFileID(entry.Name()) {
+			continue
+		}
+
+		dataDir, err := fsys.Stat(filepath.Join(dir, entry.Name(), defaultSessionLayout().Data))
+		if fileExists(fsys, filepath.Join(dir, entry.Name(), SessionFileName)) || (err == nil && dataDir.IsDir()) {
+			ids = append(ids, entry.Name())
+		}
+	}
+
+	// IDs are numbers, shorter ones sort first
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	return ids, nil
+}
+
+// IsBatch reports whether dir holds the files of a batch, see BatchFileIDs
+func IsBatch(dir string) bool {
+	return isBatch(osFs, dir)
+}
+
+// isBatch is IsBatch on the file system fsys
+func isBatch(fsys afero.Fs, dir string) bool {
+	ids, err := batchFileIDs(fsys, dir)
+
+	return err == nil && len(ids) > 0
+}
+
+// BatchProgress reports the reconstruction of a file of a batch by BatchToFiles
+type BatchProgress struct {
+	// File is the file being reconstructed
+	File BatchOutput
+	// Done is set once the file is written, or failed with Err
+	Done bool
+	Err  error
+	// Completed is the number of files done so far out of Total, the complete files
+	// of the batch
+	Completed int
+	Total     int
+}
+
+// BatchToFiles reconstructs every complete file of the batch in inDir into outDir,
+// naming each after the original file. Files whose chunks have not all been
+// received are reported in BatchResult.Incomplete and skipped; the other files are
+// still written. Directory archives are restored as directory trees, see
+// QRCodesToDir. When two files of a batch have the same name, the later ones are
+// prefixed with their file ID and reported as renamed outputs.
+//
+// The files are reconstructed concurrently by a pool of workers bounded by
+// SetConcurrency, reporting to the function set by SetBatchProgress. Once a file
+// fails, no other file is started, and the error of the first failed file in ID
+// order is returned along with the files written.
+func (q *QRFileTransfer) BatchToFiles(inDir string, outDir string) (*BatchResult, error) {
+	ids, err := batchFileIDs(q.fs, inDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s is not a batch directory", inDir)
+	}
+
+	if err := q.fs.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	result := &BatchResult{Incomplete: make(map[string]*ChunkReport)}
+	names := make(map[string]bool)
+
+	// Name the outputs in ID order, so that renames do not depend on scheduling
+	var outputs []BatchOutput
+
+	for _, id := range ids {
+		fileDir := filepath.Join(inDir, id)
+
+		report, err := q.VerifyChunks(fileDir)
+		if err != nil {
+			return result, fmt.Errorf("failed to verify chunks of file %s: %w", id, err)
+		}
+
+		if !report.Complete() {
+			result.Incomplete[id] = report
+
+			continue
+		}
+
+		info, err := q.ReadFileInfo(fileDir)
+		if err != nil {
+			return result, fmt.Errorf("failed to read file %s: %w", id, err)
+		}
+
+		name := info.Name
+		if info.Mode.IsDir() {
+			name = strings.TrimSuffix(name, dirArchiveExt)
+		}
+
+		// Names recorded by version 1 metadata were cut to a fixed length
+		if info.Version == 1 && len(info.Name) == split.MaxFilenameLength {
+			q.diagnostics.Warnf(diagnostics.CodeTruncatedName, id,
+				"the name %q may have been truncated to %d bytes by the version that split the file", info.Name, split.MaxFilenameLength)
+		}
+
+		// The name recorded by the sender may not be valid on this system
+		safePath, err := q.SafeOutputPath(filepath.Join(outDir, name))
+		if err != nil {
+			return result, fmt.Errorf("failed to reconstruct file %s: %w", id, err)
+		}
+
+		name = filepath.Base(safePath)
+
+		if names[name] {
+			q.diagnostics.Warnf(diagnostics.CodeRenamedOutput, id,
+				"another file of the batch is named %q, written as %q", name, id+"_"+name)
+
+			name = id + "_" + name
+		}
+
+		names[name] = true
+		outputs = append(outputs, BatchOutput{ID: id, Path: filepath.Join(outDir, name), Dir: info.Mode.IsDir()})
+	}
+
+	errs := q.reconstructBatchFiles(inDir, outputs)
+
+	for i, out := range outputs {
+		if errs[i] == nil {
+			result.Files = append(result.Files, out)
+		}
+	}
+
+	for i, out := range outputs {
+		if errs[i] != nil {
+			return result, fmt.Errorf("failed to reconstruct file %s: %w", out.ID, errs[i])
+		}
+	}
+
+	return result, nil
+}
+
+// reconstructBatchFiles writes the files of the batch in inDir to their outputs
+// using a pool of q.concurrency workers, and returns the error of every output, nil
+// for the files written. Outputs not started after a failure are reported as
+// errBatchStopped.
+func (q *QRFileTransfer) reconstructBatchFiles(inDir string, outputs []BatchOutput) []error {
+	errs := make([]error, len(outputs))
+	for i := range errs {
+		errs[i] = errBatchStopped
+	}
+
+	workers := min(max(q.concurrency, 1), len(outputs))
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		once      sync.Once
+		completed int
+		failed    = make(chan struct{})
+		queue     = make(chan int)
+	)
+
+	// progress reports to the progress function one call at a time
+	progress := func(p BatchProgress) {
+		if q.batchProgress == nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if p.Done {
+			completed++
+		}
+
+		p.Completed, p.Total = completed, len(outputs)
+		q.batchProgress(p)
+	}
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				out := outputs[i]
+				progress(BatchProgress{File: out})
+
+				fileDir := filepath.Join(inDir, out.ID)
+
+				var err error
+				if out.Dir {
+					err = q.QRCodesToDir(fileDir, out.Path)
+				} else {
+					err = q.QRCodesToFile(fileDir, out.Path)
+				}
+
+				errs[i] = err
+				if err != nil {
+					once.Do(func() { close(failed) })
+				}
+
+				progress(BatchProgress{File: out, Done: true, Err: err})
+			}
+		}()
+	}
+
+feed:
+	for i := range outputs {
+		select {
+		case queue <- i:
+		case <-failed:
+			break feed
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return errs
+}
+
+// validFileID reports whether id is a file ID as written by FilesToQRCodes: a
+// decimal number, which is safe to use as a directory name
+func validFileID(id string) bool {
+	if id == "" || len(id) > maxFileIDLength || id[0] == '0' {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
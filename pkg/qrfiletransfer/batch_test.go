@@ -0,0 +1,224 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+)
+
+func TestFilesToQRCodes(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Two inputs with the same base name and a directory tree
+	inputs := map[string]string{
+		"a/report.txt":  strings.Repeat("First report. ", 120),
+		"b/report.txt":  strings.Repeat("Second report. ", 90),
+		"tree/nested/x": "in a tree\n",
+	}
+
+	for name, content := range inputs {
+		path := filepath.Join(testDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(500)
+	batchDir := filepath.Join(testDir, "batch")
+	paths := []string{
+		filepath.Join(testDir, "a", "report.txt"),
+		filepath.Join(testDir, "b", "report.txt"),
+		filepath.Join(testDir, "tree"),
+	}
+
+	batch, err := qrft.FilesToQRCodes(paths, batchDir)
+	if err != nil {
+		t.Fatalf("FilesToQRCodes failed: %v", err)
+	}
+
+	if len(batch.Files) != 3 || batch.Files[1].ID != "2" || !batch.Files[2].Dir {
+		t.Fatalf("Unexpected batch: %+v", batch)
+	}
+
+	// Every data file is found where a receiver stores the chunk of its payload
+	receiveDir := filepath.Join(testDir, "received")
+
+	for _, f := range batch.Files {
+		session, err := LoadSession(filepath.Join(batchDir, f.ID))
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		if session.Settings.FileID != f.ID {
+			t.Errorf("Session of file %s has file ID %q", f.ID, session.Settings.FileID)
+		}
+
+		for _, chunk := range session.Chunks {
+			data, err := os.ReadFile(session.DataFile(chunk.Name))
+			if err != nil {
+				t.Fatalf("Failed to read data file: %v", err)
+			}
+
+			path, err := ChunkDataPath(receiveDir, &ChunkPayload{File: f.ID, Name: chunk.Name, Data: data})
+			if err != nil {
+				t.Fatalf("ChunkDataPath failed: %v", err)
+			}
+
+			// Leave the last chunk of the second file out
+			if f.ID == "2" && chunk.Name == session.Chunks[len(session.Chunks)-1].Name {
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				t.Fatalf("Failed to write data file: %v", err)
+			}
+		}
+	}
+
+	ids, err := BatchFileIDs(receiveDir)
+	if err != nil || !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Fatalf("BatchFileIDs() = %v, %v", ids, err)
+	}
+
+	outDir := filepath.Join(testDir, "out")
+
+	result, err := qrft.BatchToFiles(receiveDir, outDir)
+	if err != nil {
+		t.Fatalf("BatchToFiles failed: %v", err)
+	}
+
+	if len(result.Files) != 2 || result.Incomplete["2"] == nil || result.Incomplete["2"].Complete() {
+		t.Fatalf("Unexpected result: %+v", result)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "report.txt"))
+	if err != nil || string(data) != inputs["a/report.txt"] {
+		t.Errorf("Unexpected first file: %v", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(outDir, "tree", "nested", "x"))
+	if err != nil || string(data) != inputs["tree/nested/x"] {
+		t.Errorf("Unexpected directory tree: %v", err)
+	}
+
+	// The sender's batch directory is complete, the second file gets a prefix
+	collector := diagnostics.NewCollector()
+	qrft.SetDiagnostics(collector)
+
+	result, err = qrft.BatchToFiles(batchDir, filepath.Join(testDir, "all"))
+	if err != nil || len(result.Files) != 3 || len(result.Incomplete) != 0 {
+		t.Fatalf("BatchToFiles() = %+v, %v", result, err)
+	}
+
+	if d := collector.Diagnostics(); len(d) != 1 || d[0].Code != diagnostics.CodeRenamedOutput || d[0].Subject != "2" {
+		t.Errorf("Diagnostics() = %+v, want the renamed second file", d)
+	}
+
+	data, err = os.ReadFile(filepath.Join(testDir, "all", "2_report.txt"))
+	if err != nil || string(data) != inputs["b/report.txt"] {
+		t.Errorf("Unexpected second file: %v", err)
+	}
+}
+
+func TestChunkDataPath(t *testing.T) {
+	if path, err := ChunkDataPath("in", &ChunkPayload{Name: "file_0001"}); err != nil || path != filepath.Join("in", "data", "file_0001.dat") {
+		t.Errorf("ChunkDataPath() = %q, %v", path, err)
+	}
+
+	for _, payload := range []*ChunkPayload{
+		{Name: "../file_0001"},
+		{Name: ".."},
+		{File: "..", Name: "file_0001"},
+		{File: "1/2", Name: "file_0001"},
+		{File: "01", Name: "file_0001"},
+	} {
+		if _, err := ChunkDataPath("in", payload); err == nil {
+			t.Errorf("ChunkDataPath(%+v) accepted an unsafe path", payload)
+		}
+	}
+}
+
+func TestBatchToFilesConcurrent(t *testing.T) {
+	testDir := t.TempDir()
+
+	var paths []string
+
+	for i := range 6 {
+		path := filepath.Join(testDir, "input", fmt.Sprintf("file%d.txt", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(strings.Repeat(fmt.Sprintf("file %d ", i), 100*(i+1))), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	qrft := NewQRFileTransfer()
+	batchDir := filepath.Join(testDir, "batch")
+
+	if _, err := qrft.FilesToQRCodes(paths, batchDir); err != nil {
+		t.Fatalf("FilesToQRCodes failed: %v", err)
+	}
+
+	var started, done []string
+
+	qrft.SetConcurrency(3)
+	qrft.SetBatchProgress(func(p BatchProgress) {
+		if p.Total != len(paths) {
+			t.Errorf("Progress total is %d, want %d", p.Total, len(paths))
+		}
+
+		if p.Done {
+			done = append(done, p.File.ID)
+
+			if p.Err != nil || p.Completed != len(done) {
+				t.Errorf("Unexpected progress: %+v", p)
+			}
+		} else {
+			started = append(started, p.File.ID)
+		}
+	})
+
+	outDir := filepath.Join(testDir, "out")
+
+	result, err := qrft.BatchToFiles(batchDir, outDir)
+	if err != nil {
+		t.Fatalf("BatchToFiles failed: %v", err)
+	}
+
+	if len(started) != len(paths) || len(done) != len(paths) {
+		t.Errorf("Progress reported %d started and %d done files, want %d", len(started), len(done), len(paths))
+	}
+
+	// The result lists the files in ID order whatever order they completed in
+	for i, out := range result.Files {
+		if out.ID != strconv.Itoa(i+1) {
+			t.Errorf("File %d of the result is %s", i, out.ID)
+		}
+
+		want, _ := os.ReadFile(paths[i])
+
+		got, err := os.ReadFile(out.Path)
+		if err != nil || string(got) != string(want) {
+			t.Errorf("File %s was not reconstructed: %v", out.ID, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// ErrInvalidName is returned by BytesToQRCodes for a name that is not a file name
+var ErrInvalidName = errors.New("invalid name")
+
+// BytesToQRCodes converts data to a series of QR codes in outDir like FileToQRCodes
+// converts a file named name, without writing data to a file first. This suits
+// small payloads such as keys, configuration files, or secrets. The receiver
+// restores it as a file named name, readable by its owner only.
+func (q *QRFileTransfer) BytesToQRCodes(data []byte, name string, outDir string) error {
+	if name != filepath.Base(name) {
+		return fmt.Errorf("%w: %q must be a file name without directory", ErrInvalidName, name)
+	}
+
+	if err := split.CheckFileName(name); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidName, err)
+	}
+
+	// An in-memory file carries the name, size, and time the session records
+	mem := afero.NewMemMapFs()
+	if err := afero.WriteFile(mem, name, data, 0600); err != nil {
+		return fmt.Errorf("failed to buffer data: %w", err)
+	}
+
+	file, err := mem.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open buffered data: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return q.encodeFile(file, outDir, nil)
+}
+
+// QRCodesToBytes reconstructs the file of the session in inDir like QRCodesToFile,
+// and returns its content and original name instead of writing it. Nothing is
+// written to inDir, the chunks are merged in memory.
+func (q *QRFileTransfer) QRCodesToBytes(inDir string) ([]byte, string, error) {
+	session, err := openSession(q.fs, inDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if session.File.Dir {
+		return nil, "", fmt.Errorf("session in %s holds a directory, use QRCodesToDir", inDir)
+	}
+
+	// Reads go to the file system of q, writes stay in memory
+	mem := q.withFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(q.fs), afero.NewMemMapFs()))
+
+	outFilePath := filepath.Join(inDir, "reconstructed")
+	if err := mem.QRCodesToFile(inDir, outFilePath); err != nil {
+		return nil, "", err
+	}
+
+	data, err := afero.ReadFile(mem.fs, outFilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read reconstructed file: %w", err)
+	}
+
+	return data, session.File.Name, nil
+}
+
+// withFs returns a copy of q with the same settings, reading from and writing to fs
+func (q *QRFileTransfer) withFs(fs afero.Fs) *QRFileTransfer {
+	c := *q
+	c.splitter = split.NewSplit()
+	c.SetLogger(q.logger)
+	c.SetFs(fs)
+	c.SetDeterministic(q.deterministic)
+
+	// The intermediate directories on fs are not kept, they may not be on disk
+	c.work = nil
+
+	return &c
+}
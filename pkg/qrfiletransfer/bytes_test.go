@@ -0,0 +1,70 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestBytesToQRCodes(t *testing.T) {
+	dir := t.TempDir()
+	outDir := filepath.Join(dir, "session")
+	secret := bytes.Repeat([]byte("-----BEGIN KEY-----\n"), 80)
+
+	qrft := NewQRFileTransfer()
+	if err := qrft.BytesToQRCodes(secret, "id_ed25519", outDir); err != nil {
+		t.Fatalf("BytesToQRCodes failed: %v", err)
+	}
+
+	// Nothing but the session is written
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Output directory holds %v (%v), want the session only", entries, err)
+	}
+
+	before, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, name, err := qrft.QRCodesToBytes(outDir)
+	if err != nil {
+		t.Fatalf("QRCodesToBytes failed: %v", err)
+	}
+
+	if !bytes.Equal(data, secret) || name != "id_ed25519" {
+		t.Errorf("QRCodesToBytes returned %d bytes named %q, want %d bytes named id_ed25519", len(data), name, len(secret))
+	}
+
+	after, err := os.ReadDir(outDir)
+	if err != nil || len(after) != len(before) {
+		t.Errorf("QRCodesToBytes wrote into the session: %v, was %v", after, before)
+	}
+
+	// The session restores as an ordinary file, readable by its owner only
+	outputFile := filepath.Join(dir, "restored")
+	if err := qrft.QRCodesToFile(outDir, outputFile); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	stat, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if runtime.GOOS != "windows" && stat.Mode().Perm() != 0600 {
+		t.Errorf("Restored file mode %v, want %v", stat.Mode().Perm(), os.FileMode(0600))
+	}
+}
+
+func TestBytesToQRCodesInvalidName(t *testing.T) {
+	for _, name := range []string{"", "keys/id_ed25519", "."} {
+		err := NewQRFileTransfer().BytesToQRCodes([]byte("key"), name, filepath.Join(t.TempDir(), "session"))
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("BytesToQRCodes(%q) error = %v, want ErrInvalidName", name, err)
+		}
+	}
+}
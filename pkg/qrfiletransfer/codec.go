@@ -0,0 +1,186 @@
+package qrfiletransfer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/base45"
+)
+
+// ChunkCodec converts the data of a chunk to and from the text stored after the
+// header of a text payload. Downstream projects register their own codecs with
+// QRFileTransfer.RegisterCodec to frame chunks differently, e.g. as CBOR or UR,
+// without changing the package.
+type ChunkCodec interface {
+	// Name identifies the codec in the "Codec: " line of text payloads, it must
+	// not be empty nor contain white space
+	Name() string
+	// Encode returns the text holding data
+	Encode(data []byte) string
+	// Decode returns the data held by text produced by Encode
+	Decode(text string) ([]byte, error)
+}
+
+// Names of the built-in chunk codecs
+const (
+	// CodecBase64 stores chunk data base64 encoded, the default of text payloads
+	CodecBase64 = "base64"
+	// CodecBase45 stores chunk data Base45 encoded, which QR codes hold in the
+	// denser alphanumeric mode
+	CodecBase45 = "base45"
+	// CodecRaw stores chunk data verbatim in a byte mode QR code, see PayloadFormatBinary
+	CodecRaw = "raw"
+)
+
+// ErrUnknownCodec is returned for a chunk codec that is neither built in nor registered
+var ErrUnknownCodec = errors.New("unknown chunk codec")
+
+// builtinCodecs lists the built-in chunk codecs by name
+var builtinCodecs = map[string]ChunkCodec{
+	CodecBase64: base64Codec{},
+	CodecBase45: base45Codec{},
+	CodecRaw:    rawCodec{},
+}
+
+// base64Codec is the standard base64 encoding with padding
+type base64Codec struct{}
+
+func (base64Codec) Name() string { return CodecBase64 }
+
+func (base64Codec) Encode(data []byte) string { return base64.StdEncoding.EncodeToString(data) }
+
+func (base64Codec) Decode(text string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
+	}
+
+	return data, nil
+}
+
+// base45Codec is the Base45 encoding of RFC 9285
+type base45Codec struct{}
+
+func (base45Codec) Name() string { return CodecBase45 }
+
+func (base45Codec) Encode(data []byte) string { return base45.Encode(data) }
+
+func (base45Codec) Decode(text string) ([]byte, error) {
+	data, err := base45.Decode(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base45 content: %w", err)
+	}
+
+	return data, nil
+}
+
+// rawCodec stores the data unchanged
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return CodecRaw }
+
+func (rawCodec) Encode(data []byte) string { return string(data) }
+
+func (rawCodec) Decode(text string) ([]byte, error) { return []byte(text), nil }
+
+// CodecNames returns the names of the built-in chunk codecs in alphabetical order
+func CodecNames() []string {
+	names := make([]string, 0, len(builtinCodecs))
+	for name := range builtinCodecs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupCodec returns the codec named name among the built-in codecs and
+// registered, base64 for an empty name
+func lookupCodec(registered map[string]ChunkCodec, name string) (ChunkCodec, error) {
+	if name == "" {
+		name = CodecBase64
+	}
+
+	if c, ok := builtinCodecs[name]; ok {
+		return c, nil
+	}
+
+	if c, ok := registered[name]; ok {
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("%w %q (expected one of %s, or a registered codec)", ErrUnknownCodec, name, strings.Join(CodecNames(), ", "))
+}
+
+// RegisterCodec makes a chunk codec available to SetCodec and DecodePayload.
+// Its name must not be empty, contain white space, or be taken by a built-in or
+// previously registered codec.
+func (q *QRFileTransfer) RegisterCodec(c ChunkCodec) error {
+	name := c.Name()
+	if name == "" || strings.ContainsFunc(name, unicode.IsSpace) {
+		return fmt.Errorf("invalid chunk codec name %q", name)
+	}
+
+	if _, err := lookupCodec(q.codecs, name); err == nil {
+		return fmt.Errorf("chunk codec %q is already registered", name)
+	}
+
+	if q.codecs == nil {
+		q.codecs = make(map[string]ChunkCodec)
+	}
+
+	q.codecs[name] = c
+
+	return nil
+}
+
+// SetCodec sets the codec chunk data is stored with by name, see CodecNames and
+// RegisterCodec. CodecRaw selects PayloadFormatBinary, every other codec
+// PayloadFormatText with a "Codec: " line naming it, so readers pick the same
+// codec; base64 payloads carry no such line and stay readable by versions that
+// predate codecs. An error wrapping ErrUnknownCodec is returned for an unknown name.
+func (q *QRFileTransfer) SetCodec(name string) error {
+	if _, err := lookupCodec(q.codecs, name); err != nil {
+		return err
+	}
+
+	switch name {
+	case CodecRaw:
+		q.SetPayloadFormat(PayloadFormatBinary)
+	default:
+		q.SetPayloadFormat(PayloadFormatText)
+		q.codec = textCodecName(name)
+	}
+
+	return nil
+}
+
+// textCodecName returns the codec name recorded for text payloads, empty for base64
+func textCodecName(name string) string {
+	if name == CodecBase64 {
+		return ""
+	}
+
+	return name
+}
+
+// encodePayload returns the QR code content for a chunk payload in the payload
+// format and codec of q
+func (q *QRFileTransfer) encodePayload(p *ChunkPayload) ([]byte, error) {
+	payload := *p
+	payload.Codec = q.codec
+
+	return encodeChunkPayload(q.payloadFormat, &payload, q.codecs)
+}
+
+// DecodePayload parses the content of a QR code like the package level
+// DecodePayload, also decoding text payloads of the codecs registered with
+// RegisterCodec and the frames of the protocols registered with RegisterFramer
+func (q *QRFileTransfer) DecodePayload(content []byte) (*ChunkPayload, error) {
+	return decodePayload(content, q.codecs, q.framers)
+}
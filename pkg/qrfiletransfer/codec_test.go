@@ -0,0 +1,148 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// hexCodec is a codec a downstream project might register
+type hexCodec struct{}
+
+func (hexCodec) Name() string { return "hex" }
+
+func (hexCodec) Encode(data []byte) string { return hex.EncodeToString(data) }
+
+func (hexCodec) Decode(text string) ([]byte, error) { return hex.DecodeString(text) }
+
+func TestCodecPayloadRoundTrip(t *testing.T) {
+	data := []byte{0x00, 0xff, 0xfe, '\n', 'D', 'a', 't', 'a', 0x80}
+
+	for _, name := range CodecNames() {
+		content, err := EncodeChunkPayload(PayloadFormatText, &ChunkPayload{File: "3", Name: "codec_0002", Data: data, Next: []int{3}, Codec: name})
+		if err != nil {
+			t.Fatalf("EncodeChunkPayload(%s) failed: %v", name, err)
+		}
+
+		if hasLine := bytes.Contains(content, []byte(textPayloadCodec)); hasLine != (name != CodecBase64) {
+			t.Errorf("Payload of codec %s has a codec line: %v", name, hasLine)
+		}
+
+		payload, err := DecodePayload(content)
+		if err != nil {
+			t.Fatalf("DecodePayload(%s) failed: %v", name, err)
+		}
+
+		if payload.Codec != textCodecName(name) || payload.File != "3" || payload.Name != "codec_0002" ||
+			len(payload.Next) != 1 || !bytes.Equal(payload.Data, data) {
+			t.Errorf("DecodePayload(%s) got %+v", name, payload)
+		}
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	qrft := NewQRFileTransfer()
+
+	if err := qrft.SetCodec("hex"); !errors.Is(err, ErrUnknownCodec) {
+		t.Fatalf("SetCodec() of an unregistered codec error = %v, want %v", err, ErrUnknownCodec)
+	}
+
+	if err := qrft.RegisterCodec(hexCodec{}); err != nil {
+		t.Fatalf("RegisterCodec failed: %v", err)
+	}
+
+	for _, c := range []ChunkCodec{hexCodec{}, base45Codec{}} {
+		if err := qrft.RegisterCodec(c); err == nil {
+			t.Errorf("RegisterCodec(%s) registered a taken name", c.Name())
+		}
+	}
+
+	if err := qrft.SetCodec("hex"); err != nil {
+		t.Fatalf("SetCodec failed: %v", err)
+	}
+
+	content, err := qrft.encodePayload(&ChunkPayload{Name: "custom_0000", Data: []byte("framed")})
+	if err != nil {
+		t.Fatalf("encodePayload failed: %v", err)
+	}
+
+	if !strings.HasSuffix(string(content), textPayloadCodec+"hex"+textPayloadSeparator+hex.EncodeToString([]byte("framed"))) {
+		t.Errorf("encodePayload() = %q, want hex encoded data", content)
+	}
+
+	if payload, err := qrft.DecodePayload(content); err != nil || string(payload.Data) != "framed" || payload.Codec != "hex" {
+		t.Errorf("DecodePayload() = %+v, %v, want the hex encoded data", payload, err)
+	}
+
+	// Only the encoder it is registered on knows the codec
+	if _, err := DecodePayload(content); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("DecodePayload() error = %v, want %v", err, ErrUnknownCodec)
+	}
+
+	if err := qrft.SetCodec(CodecRaw); err != nil || qrft.payloadFormat != PayloadFormatBinary {
+		t.Errorf("SetCodec(raw) = %v with payload format %s, want binary", err, qrft.payloadFormat)
+	}
+}
+
+func TestFileToQRCodesCodec(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	content := make([]byte, 6000)
+	rand.New(rand.NewSource(3)).Read(content)
+
+	if err := afero.WriteFile(fs, "/in/codec.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base64Limit := NewQRFileTransfer()
+	base64Limit.SetPayloadFormat(PayloadFormatText)
+
+	for _, name := range []string{CodecBase45, "hex"} {
+		qrft := NewQRFileTransfer()
+		qrft.SetFs(fs)
+
+		if err := qrft.RegisterCodec(hexCodec{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := qrft.SetCodec(name); err != nil {
+			t.Fatal(err)
+		}
+
+		// Base45 is stored in alphanumeric mode, denser than base64 in byte mode
+		if limit := qrft.chunkSizeLimit("codec.bin", 4); name == CodecBase45 && limit <= base64Limit.chunkSizeLimit("codec.bin", 4) {
+			t.Errorf("Chunk size limit of base45 is %d, want more than base64", limit)
+		}
+
+		outDir := "/" + name
+		if err := qrft.FileToQRCodes("/in/codec.bin", outDir); err != nil {
+			t.Fatalf("FileToQRCodes(%s) failed: %v", name, err)
+		}
+
+		session, err := loadSession(fs, outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if session.Settings.Codec != name || len(session.ChunkSizeReductions) != 0 {
+			t.Errorf("Session of codec %s has codec %q and %d chunk size reductions", name, session.Settings.Codec, len(session.ChunkSizeReductions))
+		}
+
+		if manifest, err := loadManifest(fs, outDir); err != nil || manifest.Codec != name {
+			t.Errorf("Manifest of codec %s = %+v, %v", name, manifest, err)
+		}
+
+		if err := qrft.QRCodesToFile(outDir, outDir+".bin"); err != nil {
+			t.Fatalf("QRCodesToFile(%s) failed: %v", name, err)
+		}
+
+		if got, _ := afero.ReadFile(fs, outDir+".bin"); !bytes.Equal(got, content) {
+			t.Errorf("Reconstructed file of codec %s differs from the original", name)
+		}
+	}
+}
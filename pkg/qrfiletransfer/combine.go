@@ -0,0 +1,231 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// QuarantineDirName is the directory of a combined capture holding the chunks that
+// were rejected, kept for analysis
+const QuarantineDirName = "quarantine"
+
+// CombineResult describes the union of chunks built by CombineChunks
+type CombineResult struct {
+	// Report describes the chunks available in the output directory
+	Report *ChunkReport
+	// Added counts the chunks contributed by each input directory, in order
+	Added []int
+	// Rejected lists the indices of chunks that did not match the manifest
+	Rejected []int
+	// Conflicts lists the indices of chunks whose data differs between inputs
+	// while no manifest tells which one is right. The first input wins.
+	Conflicts []int
+}
+
+// CombineChunks unions the chunks captured independently into several directories,
+// e.g. by two receivers filming the same transfer, into the data directory of
+// outDir. Data files already in outDir are kept, so combining can be repeated as
+// more captures come in.
+//
+// Chunks are validated against manifest if it is not nil, otherwise against the
+// manifest of outDir or of the first input carrying one. The manifest is copied
+// to outDir so that the reconstruction validates the chunks as well. Rejected
+// chunks are set aside in the QuarantineDirName directory of outDir.
+func (q *QRFileTransfer) CombineChunks(outDir string, manifest *Manifest, inDirs ...string) (*CombineResult, error) {
+	if len(inDirs) == 0 {
+		return nil, errors.New("no input directories to combine")
+	}
+
+	var err error
+	if manifest == nil {
+		if manifest, err = findManifest(q.fs, append([]string{outDir}, inDirs...)); err != nil {
+			return nil, err
+		}
+	}
+
+	dataDir := filepath.Join(outDir, defaultSessionLayout().Data)
+	if err := q.fs.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	result := &CombineResult{Added: make([]int, len(inDirs))}
+	rejected := make(map[int]bool)
+	conflicts := make(map[int]bool)
+
+	for i, inDir := range inDirs {
+		files, err := captureDataFiles(q.fs, inDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range files {
+			index, ok := chunkIndex(path)
+			if !ok {
+				continue
+			}
+
+			data, err := afero.ReadFile(q.fs, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read chunk %s: %w", path, err)
+			}
+
+			base := filepath.Base(path)
+			name := strings.TrimSuffix(base, filepath.Ext(base))
+
+			if manifest != nil && manifest.verifyChunk(name, data) != nil {
+				rejected[index] = true
+
+				if err := quarantineChunk(q.fs, outDir, name, data); err != nil {
+					return nil, err
+				}
+
+				continue
+			}
+
+			dataFilePath := filepath.Join(dataDir, name+".dat")
+
+			existing, err := afero.ReadFile(q.fs, dataFilePath)
+			if err == nil {
+				if !bytes.Equal(existing, data) {
+					conflicts[index] = true
+				}
+
+				continue
+			}
+
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read chunk %s: %w", dataFilePath, err)
+			}
+
+			if err := afero.WriteFile(q.fs, dataFilePath, data, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+			}
+
+			result.Added[i]++
+		}
+
+		// Keep the parity chunks, so missing chunks can still be restored
+		parityFiles, err := captureParityFiles(q.fs, inDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for name, path := range parityFiles {
+			dataFilePath := filepath.Join(dataDir, name+".dat")
+			if fileExists(q.fs, dataFilePath) {
+				continue
+			}
+
+			data, err := afero.ReadFile(q.fs, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read parity chunk %s: %w", path, err)
+			}
+
+			if err := afero.WriteFile(q.fs, dataFilePath, data, 0644); err != nil {
+				return nil, fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+			}
+		}
+	}
+
+	if manifest != nil {
+		if err := manifest.save(q.fs, outDir); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.Report, err = q.VerifyChunks(outDir); err != nil {
+		return nil, err
+	}
+
+	// A chunk rejected from one input may have been accepted from another
+	for index := range rejected {
+		if !containsIndex(result.Report.Present, index) {
+			result.Rejected = append(result.Rejected, index)
+		}
+	}
+
+	for index := range conflicts {
+		result.Conflicts = append(result.Conflicts, index)
+	}
+
+	sort.Ints(result.Rejected)
+	sort.Ints(result.Conflicts)
+
+	return result, nil
+}
+
+// quarantineChunk keeps a rejected copy of a chunk, named after its hash so that
+// identical copies are kept once
+func quarantineChunk(fsys afero.Fs, outDir, name string, data []byte) error {
+	dir := filepath.Join(outDir, QuarantineDirName)
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	path := filepath.Join(dir, name+"-"+hashBytes(data)[:8]+".dat")
+	if err := afero.WriteFile(fsys, path, data, 0644); err != nil {
+		return fmt.Errorf("failed to quarantine chunk %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// captureDataFiles returns the data files of a capture directory. A capture whose
+// first chunk is corrupted cannot be opened as a session, its data directory is
+// listed instead so the other chunks still count.
+func captureDataFiles(fsys afero.Fs, dir string) ([]string, error) {
+	session, err := openSession(fsys, dir)
+	if err == nil {
+		return session.chunkDataFiles(fsys), nil
+	}
+
+	fallback := &Session{Layout: defaultSessionLayout(), dir: dir}
+
+	files := fallback.chunkDataFiles(fsys)
+	if len(files) == 0 {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// findManifest returns the manifest of the first directory that has one, or nil
+func findManifest(fsys afero.Fs, dirs []string) (*Manifest, error) {
+	for _, dir := range dirs {
+		manifest, err := loadManifest(fsys, dir)
+		if err == nil {
+			return manifest, nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// containsIndex reports whether sorted indices contain index
+func containsIndex(indices []int, index int) bool {
+	i := sort.SearchInts(indices, index)
+
+	return i < len(indices) && indices[i] == index
+}
+
+// captureParityFiles returns the parity chunk files of a capture directory by name,
+// like captureDataFiles
+func captureParityFiles(fsys afero.Fs, dir string) (map[string]string, error) {
+	session, err := openSession(fsys, dir)
+	if err != nil {
+		session = &Session{Layout: defaultSessionLayout(), dir: dir}
+	}
+
+	return session.parityFiles(fsys)
+}
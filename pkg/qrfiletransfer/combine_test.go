@@ -0,0 +1,158 @@
+package qrfiletransfer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCombineChunks(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "combine.txt")
+	testContent := strings.Repeat("Two receivers see more than one. ", 150)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	sessionDir := filepath.Join(testDir, "session")
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(1000)
+
+	if err := qrft.FileToQRCodes(testFilePath, sessionDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(sessionDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	total := len(session.Chunks)
+	if total < 4 {
+		t.Fatalf("Expected at least 4 chunks, got %d", total)
+	}
+
+	// Receiver A read the even chunks, receiver B the odd ones except the last,
+	// and a corrupted copy of chunk 0
+	capture := func(dir string, keep func(int) bool) {
+		dataDir := filepath.Join(dir, "data")
+		if err := os.MkdirAll(dataDir, 0750); err != nil {
+			t.Fatalf("Failed to create capture directory: %v", err)
+		}
+
+		for i, chunk := range session.Chunks {
+			if !keep(i) {
+				continue
+			}
+
+			data, err := os.ReadFile(session.DataFile(chunk.Name))
+			if err != nil {
+				t.Fatalf("Failed to read data file: %v", err)
+			}
+
+			if err := os.WriteFile(filepath.Join(dataDir, chunk.Name+".dat"), data, 0600); err != nil {
+				t.Fatalf("Failed to write data file: %v", err)
+			}
+		}
+	}
+
+	capA := filepath.Join(testDir, "capA")
+	capB := filepath.Join(testDir, "capB")
+	last := total - 1
+
+	capture(capA, func(i int) bool { return i%2 == 0 && i != last })
+	capture(capB, func(i int) bool { return i%2 == 1 && i != last })
+
+	corrupted := []byte("not the first chunk")
+	if err := os.WriteFile(filepath.Join(capB, "data", session.Chunks[0].Name+".dat"), corrupted, 0600); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	manifest, err := LoadManifest(sessionDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "merged")
+
+	result, err := qrft.CombineChunks(outDir, manifest, capA, capB)
+	if err != nil {
+		t.Fatalf("CombineChunks failed: %v", err)
+	}
+
+	if result.Report.Complete() || !reflect.DeepEqual(result.Report.Missing, []int{last}) {
+		t.Fatalf("Unexpected report: %s", result.Report)
+	}
+
+	// The corrupted copy is ignored since chunk 0 came from receiver A
+	if len(result.Rejected) != 0 || len(result.Conflicts) != 0 {
+		t.Errorf("Expected no rejected or conflicting chunks, got %v and %v", result.Rejected, result.Conflicts)
+	}
+
+	// The rejected copy is kept in quarantine for analysis
+	quarantined, err := filepath.Glob(filepath.Join(outDir, QuarantineDirName, session.Chunks[0].Name+"-*.dat"))
+	if err != nil || len(quarantined) != 1 {
+		t.Errorf("Expected the corrupted copy in quarantine, got %v (%v)", quarantined, err)
+	}
+
+	// A third capture with the last chunk completes the union, with the manifest
+	// now taken from the output directory
+	capC := filepath.Join(testDir, "capC")
+	capture(capC, func(i int) bool { return i == last })
+
+	result, err = qrft.CombineChunks(outDir, nil, capC)
+	if err != nil {
+		t.Fatalf("CombineChunks failed: %v", err)
+	}
+
+	if !result.Report.Complete() || !reflect.DeepEqual(result.Added, []int{1}) {
+		t.Fatalf("Unexpected result: %s, added %v", result.Report, result.Added)
+	}
+
+	outputFile := filepath.Join(testDir, "combine_out.txt")
+	if err := qrft.QRCodesToFile(outDir, outputFile); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructed, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if string(reconstructed) != testContent {
+		t.Error("Reconstructed file differs from the original")
+	}
+}
+
+func TestCombineChunksConflicts(t *testing.T) {
+	testDir := t.TempDir()
+
+	for _, dir := range []string{"capA", "capB"} {
+		if err := os.MkdirAll(filepath.Join(testDir, dir, "data"), 0750); err != nil {
+			t.Fatalf("Failed to create capture directory: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(testDir, dir, "data", "file_0001.dat"), []byte("from "+dir), 0600); err != nil {
+			t.Fatalf("Failed to write data file: %v", err)
+		}
+	}
+
+	result, err := NewQRFileTransfer().CombineChunks(filepath.Join(testDir, "merged"), nil,
+		filepath.Join(testDir, "capA"), filepath.Join(testDir, "capB"))
+	if err != nil {
+		t.Fatalf("CombineChunks failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Conflicts, []int{1}) || !reflect.DeepEqual(result.Added, []int{1, 0}) {
+		t.Errorf("Expected a conflict on chunk 1 won by the first input, got %v, added %v", result.Conflicts, result.Added)
+	}
+
+	data, err := os.ReadFile(filepath.Join(testDir, "merged", "data", "file_0001.dat"))
+	if err != nil || string(data) != "from capA" {
+		t.Errorf("Expected the chunk of the first input, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,210 @@
+package qrfiletransfer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// chunkIndexPattern extracts the chunk index from a chunk name such as "file_0003",
+// padded to 4 digits or more, see split.ChunkIndexWidth
+var chunkIndexPattern = regexp.MustCompile(`_(\d{4,})$`)
+
+// OpenSession returns the session describing dir.
+// Directories written by FileToQRCodes are described by their session file.
+// Archives created before session files existed are recognized as well and
+// converted on the fly into a session (with Legacy set):
+//
+//   - the qrcodes/ and data/ layout, where data/ holds one .dat file per chunk
+//   - a directory of raw chunks, where the first chunk may still carry the old
+//     .tmp extension instead of .part
+//
+// The file described by a legacy session is taken from the metadata of its
+// first chunk.
+func OpenSession(dir string) (*Session, error) {
+	return openSession(osFs, dir)
+}
+
+// openSession is OpenSession on the file system fsys
+func openSession(fsys afero.Fs, dir string) (*Session, error) {
+	session, err := loadSession(fsys, dir)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return session, err
+	}
+
+	session, legacyErr := loadLegacySession(fsys, dir)
+	if legacyErr != nil {
+		return nil, fmt.Errorf("%s is not a session directory: %w", dir, legacyErr)
+	}
+
+	return session, nil
+}
+
+// DataFile returns the path of the data file of the named chunk, or "" if the
+// session has no data directory.
+func (s *Session) DataFile(name string) string {
+	if path, ok := s.dataFiles[name]; ok {
+		return path
+	}
+
+	if s.Layout.Data == "" {
+		return ""
+	}
+
+	c := s.artifactChunk(name)
+	_, dataName := s.artifactNames(c)
+
+	return filepath.Join(s.subdir(s.volumeDir(c, s.Layout.Data)), dataName+".dat")
+}
+
+// loadLegacySession builds a session for a directory without a session file
+func loadLegacySession(fsys afero.Fs, dir string) (*Session, error) {
+	layout := defaultSessionLayout()
+
+	// The qrcodes/ + data/ layout written before session files existed
+	dataFiles, err := afero.Glob(fsys, filepath.Join(dir, layout.Data, "*.dat"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list data files: %w", err)
+	}
+
+	if len(dataFiles) > 0 {
+		if _, err := fsys.Stat(filepath.Join(dir, layout.QRCodes)); err != nil {
+			layout.QRCodes = ""
+		}
+
+		return newLegacySession(fsys, dir, layout, dataFiles)
+	}
+
+	// A directory of raw chunks, the first one possibly named .tmp
+	chunkFiles, err := afero.Glob(fsys, filepath.Join(dir, "*.part"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk files: %w", err)
+	}
+
+	tmpFiles, err := afero.Glob(fsys, filepath.Join(dir, "*.tmp"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunk files: %w", err)
+	}
+
+	if len(chunkFiles)+len(tmpFiles) > 0 {
+		return newLegacySession(fsys, dir, SessionLayout{Data: "."}, append(tmpFiles, chunkFiles...))
+	}
+
+	return nil, errors.New("no session file, data files, or chunk files found")
+}
+
+// newLegacySession builds a session from the chunk files of a legacy archive
+func newLegacySession(fsys afero.Fs, dir string, layout SessionLayout, files []string) (*Session, error) {
+	type legacyChunk struct {
+		index int
+		name  string
+		path  string
+	}
+
+	chunks := make([]legacyChunk, 0, len(files))
+	seen := make(map[string]bool)
+
+	for _, path := range files {
+		base := filepath.Base(path)
+		name := strings.TrimSuffix(base, filepath.Ext(base))
+
+		m := chunkIndexPattern.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(m[1])
+		if err != nil || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		chunks = append(chunks, legacyChunk{index: index, name: name, path: path})
+	}
+
+	if len(chunks) == 0 {
+		return nil, errors.New("no chunk files found")
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].index < chunks[j].index
+	})
+
+	session := &Session{
+		Version:   SessionVersion,
+		Layout:    layout,
+		Legacy:    true,
+		dir:       dir,
+		dataFiles: make(map[string]string, len(chunks)),
+	}
+
+	for _, c := range chunks {
+		data, err := afero.ReadFile(fsys, c.path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s: %w", c.path, err)
+		}
+
+		session.Chunks = append(session.Chunks, SessionChunk{Name: c.name, Hash: hashBytes(data)})
+		session.dataFiles[c.name] = c.path
+	}
+
+	// Frames of other protocols name no file, see SetProtocol
+	if f := detectDataFileFramer(fsys, chunks[0].path); f != nil {
+		session.Settings.Protocol = f.Name()
+
+		return session, nil
+	}
+
+	// The first chunk carries the metadata describing the original file
+	if chunks[0].index != 0 {
+		return session, nil
+	}
+
+	splitter := split.NewSplit()
+	splitter.SetFs(fsys)
+
+	info, err := splitter.ReadFileInfo(chunks[0].path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of first chunk %s: %w", chunks[0].path, err)
+	}
+
+	session.File = SessionFile{
+		Name: info.Name,
+		Size: info.Size,
+		Hash: hex.EncodeToString(info.Hash[:]),
+	}
+	session.Settings.NumChunks = info.Total
+	session.Settings.Dedup = len(info.Refs) > 0
+
+	for index, ref := range info.Refs {
+		session.Refs = append(session.Refs, ChunkRef{Index: index, Ref: ref})
+	}
+
+	slices.SortFunc(session.Refs, func(a, b ChunkRef) int { return a.Index - b.Index })
+
+	// Every chunk is present, but the duplicated ones
+	present := make(map[int]bool, len(chunks))
+	for _, c := range chunks {
+		present[c.index] = true
+	}
+
+	session.Complete = chunks[len(chunks)-1].index < info.Total
+
+	for index := range info.Total {
+		if _, ok := info.Refs[index]; !ok && !present[index] {
+			session.Complete = false
+		}
+	}
+
+	return session, nil
+}
@@ -0,0 +1,112 @@
+package qrfiletransfer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+)
+
+func TestOpenSessionLegacyLayout(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "legacy.txt")
+	testContent := strings.Repeat("Archives without a session file must stay restorable. ", 40)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Produce the qrcodes/ + data/ layout of earlier versions by dropping the session file
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(outDir, SessionFileName)); err != nil {
+		t.Fatalf("Failed to remove session file: %v", err)
+	}
+
+	session, err := OpenSession(outDir)
+	if err != nil {
+		t.Fatalf("OpenSession failed: %v", err)
+	}
+
+	if !session.Legacy || !session.Complete || session.File.Name != "legacy.txt" {
+		t.Fatalf("Unexpected legacy session: %+v", session)
+	}
+
+	reconstructedFilePath := filepath.Join(testDir, "reconstructed.txt")
+	if err := qrft.QRCodesToFile(outDir, reconstructedFilePath); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructedContent, err := os.ReadFile(reconstructedFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if string(reconstructedContent) != testContent {
+		t.Fatal("Reconstructed content does not match original content")
+	}
+}
+
+func TestOpenSessionLegacyTmpChunk(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "chunks.txt")
+	testContent := strings.Repeat("The first chunk used to be named .tmp. ", 20)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	file, err := os.Open(testFilePath)
+	if err != nil {
+		t.Fatalf("Failed to open test file: %v", err)
+	}
+
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Errorf("Failed to close file: %v", err)
+		}
+	}()
+
+	// Split into raw chunks and give the first one the old .tmp extension
+	chunkDir := filepath.Join(testDir, "chunks")
+	if err := split.NewSplit().SplitFile(file, chunkDir, 3); err != nil {
+		t.Fatalf("SplitFile failed: %v", err)
+	}
+
+	first := filepath.Join(chunkDir, "chunks_0000.part")
+	if err := os.Rename(first, strings.TrimSuffix(first, ".part")+".tmp"); err != nil {
+		t.Fatalf("Failed to rename first chunk: %v", err)
+	}
+
+	session, err := OpenSession(chunkDir)
+	if err != nil {
+		t.Fatalf("OpenSession failed: %v", err)
+	}
+
+	if !session.Complete || len(session.Chunks) != 3 {
+		t.Fatalf("Unexpected legacy session: %+v", session)
+	}
+
+	reconstructedFilePath := filepath.Join(testDir, "reconstructed.txt")
+	if err := NewQRFileTransfer().QRCodesToFile(chunkDir, reconstructedFilePath); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructedContent, err := os.ReadFile(reconstructedFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if string(reconstructedContent) != testContent {
+		t.Fatal("Reconstructed content does not match original content")
+	}
+}
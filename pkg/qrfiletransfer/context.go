@@ -0,0 +1,76 @@
+package qrfiletransfer
+
+import (
+	"context"
+)
+
+// The Ctx variants of the operations stop with the error of ctx once ctx is done,
+// e.g. canceled by the user or past its deadline. They check ctx between two
+// chunks, including while the file is split and the chunks are merged, so an
+// operation stops within the time a chunk takes. An encoding stopped this way
+// leaves the chunks done so far in the staging directory of outDir, and the next
+// run into outDir resumes from them. A decoding stopped this way leaves the output
+// untouched, files are only written once complete.
+
+// FileToQRCodesCtx converts a file to QR codes like FileToQRCodes, until ctx is done
+func (q *QRFileTransfer) FileToQRCodesCtx(ctx context.Context, filePath string, outDir string) error {
+	return q.withContext(ctx).FileToQRCodes(filePath, outDir)
+}
+
+// DirToQRCodesCtx converts a directory to QR codes like DirToQRCodes, until ctx is
+// done
+func (q *QRFileTransfer) DirToQRCodesCtx(ctx context.Context, dirPath string, outDir string) error {
+	return q.withContext(ctx).DirToQRCodes(dirPath, outDir)
+}
+
+// FilesToQRCodesCtx converts a batch of files to QR codes like FilesToQRCodes,
+// until ctx is done
+func (q *QRFileTransfer) FilesToQRCodesCtx(ctx context.Context, filePaths []string, outDir string) (*Batch, error) {
+	return q.withContext(ctx).FilesToQRCodes(filePaths, outDir)
+}
+
+// BytesToQRCodesCtx converts data to QR codes like BytesToQRCodes, until ctx is done
+func (q *QRFileTransfer) BytesToQRCodesCtx(ctx context.Context, data []byte, name string, outDir string) error {
+	return q.withContext(ctx).BytesToQRCodes(data, name, outDir)
+}
+
+// QRCodesToFileCtx reconstructs a file like QRCodesToFile, until ctx is done
+func (q *QRFileTransfer) QRCodesToFileCtx(ctx context.Context, inDir string, outFilePath string) error {
+	return q.withContext(ctx).QRCodesToFile(inDir, outFilePath)
+}
+
+// QRCodesToDirCtx reconstructs a directory tree like QRCodesToDir, until ctx is done
+func (q *QRFileTransfer) QRCodesToDirCtx(ctx context.Context, inDir string, outDir string) error {
+	return q.withContext(ctx).QRCodesToDir(inDir, outDir)
+}
+
+// QRCodesToBytesCtx reconstructs a file in memory like QRCodesToBytes, until ctx is
+// done
+func (q *QRFileTransfer) QRCodesToBytesCtx(ctx context.Context, inDir string) ([]byte, string, error) {
+	return q.withContext(ctx).QRCodesToBytes(inDir)
+}
+
+// BatchToFilesCtx reconstructs the files of a batch like BatchToFiles, until ctx is
+// done. The files not started when ctx is done are not written.
+func (q *QRFileTransfer) BatchToFilesCtx(ctx context.Context, inDir string, outDir string) (*BatchResult, error) {
+	return q.withContext(ctx).BatchToFiles(inDir, outDir)
+}
+
+// withContext returns a copy of q with the same settings, running its operations
+// until ctx is done
+func (q *QRFileTransfer) withContext(ctx context.Context) *QRFileTransfer {
+	c := *q
+	c.ctx = ctx
+
+	return &c
+}
+
+// context returns the context of the running operation, one that is never done
+// outside of the Ctx variants
+func (q *QRFileTransfer) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+
+	return q.ctx
+}
@@ -0,0 +1,103 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestFileToQRCodesCtxCanceled(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.bin")
+	outDir := filepath.Join(dir, "session")
+	content := bytes.Repeat([]byte("canceled between chunks "), 40)
+
+	if err := os.WriteFile(inPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel once the first chunk is encoded
+	var encoded atomic.Int32
+
+	// Without timestamps, the first chunk is the same when the runs straddle a
+	// second
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(200)
+	qrft.SetConcurrency(1)
+	qrft.SetDeterministic(true)
+	qrft.SetQRCodeObserver(func(string, *qrcode.QRCode) {
+		encoded.Add(1)
+		cancel()
+	})
+
+	if err := qrft.FileToQRCodesCtx(ctx, inPath, outDir); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FileToQRCodesCtx() error = %v, want context.Canceled", err)
+	}
+
+	if encoded.Load() != 1 {
+		t.Errorf("Encoded %d chunks after being canceled, want 1", encoded.Load())
+	}
+
+	if _, err := os.Stat(outDir); !os.IsNotExist(err) {
+		t.Errorf("A canceled run published its session: %v", err)
+	}
+
+	// The next run resumes from the chunk already encoded
+	encoded.Store(0)
+	qrft.SetQRCodeObserver(func(string, *qrcode.QRCode) { encoded.Add(1) })
+
+	if err := qrft.FileToQRCodes(inPath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes() failed to resume: %v", err)
+	}
+
+	session, err := OpenSession(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := len(session.Chunks) - 1; int(encoded.Load()) != want {
+		t.Errorf("Resumed run encoded %d chunks, want %d", encoded.Load(), want)
+	}
+
+	// A run over the published session that fails leaves it as it was
+	if err := qrft.FileToQRCodesCtx(ctx, inPath, outDir); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FileToQRCodesCtx() error = %v, want context.Canceled", err)
+	}
+
+	if republished, err := OpenSession(outDir); err != nil || !republished.Complete || len(republished.Chunks) != len(session.Chunks) {
+		t.Fatalf("A failed run changed the published session: %v", err)
+	}
+
+	for _, path := range session.QRCodeFiles() {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("A failed run removed a QR code of the published session: %v", err)
+		}
+	}
+
+	outPath := filepath.Join(dir, "output.bin")
+
+	if err := qrft.QRCodesToFileCtx(ctx, outDir, outPath); !errors.Is(err, context.Canceled) {
+		t.Fatalf("QRCodesToFileCtx() error = %v, want context.Canceled", err)
+	}
+
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Errorf("A canceled reconstruction wrote its output: %v", err)
+	}
+
+	if err := qrft.QRCodesToFileCtx(context.Background(), outDir, outPath); err != nil {
+		t.Fatalf("QRCodesToFileCtx() error = %v", err)
+	}
+
+	if got, err := os.ReadFile(outPath); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed %d bytes (%v), want %d", len(got), err, len(content))
+	}
+}
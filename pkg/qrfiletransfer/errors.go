@@ -0,0 +1,30 @@
+package qrfiletransfer
+
+import (
+	"errors"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+)
+
+// Errors reported when reading sessions, manifests, payloads, and chunks, so that
+// callers can branch on the failure with errors.Is and errors.As. They are the
+// errors of the split package, which reports them when merging chunks.
+var (
+	// ErrHashMismatch is returned when data does not match its recorded hash
+	ErrHashMismatch = split.ErrHashMismatch
+
+	// ErrBadMetadata is returned when the first chunk does not carry valid metadata
+	ErrBadMetadata = split.ErrBadMetadata
+
+	// ErrUnsupportedVersion is returned for a session, manifest, payload, or chunk
+	// written by a newer version
+	ErrUnsupportedVersion = split.ErrUnsupportedVersion
+)
+
+// ErrNoRawData is returned when reading the data files of a session split without
+// them, see QRFileTransfer.SetEmitRawData. Its file is recovered by decoding its QR
+// code images instead.
+var ErrNoRawData = errors.New("session has no data files")
+
+// ErrMissingChunk is returned when a chunk needed to reconstruct a file is not available
+type ErrMissingChunk = split.ErrMissingChunk
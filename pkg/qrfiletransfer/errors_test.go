@@ -0,0 +1,79 @@
+package qrfiletransfer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQRCodesToFileErrors(t *testing.T) {
+	newSession := func(t *testing.T) (string, *Session) {
+		testDir := t.TempDir()
+
+		testFilePath := filepath.Join(testDir, "errors.txt")
+		if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Fail loudly. ", 200)), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		outDir := filepath.Join(testDir, "output")
+		if err := NewQRFileTransfer().FileToQRCodes(testFilePath, outDir); err != nil {
+			t.Fatalf("FileToQRCodes failed: %v", err)
+		}
+
+		session, err := LoadSession(outDir)
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		return outDir, session
+	}
+
+	t.Run("missing chunk", func(t *testing.T) {
+		outDir, session := newSession(t)
+		if err := os.Remove(session.DataFile(session.Chunks[1].Name)); err != nil {
+			t.Fatal(err)
+		}
+
+		err := NewQRFileTransfer().QRCodesToFile(outDir, filepath.Join(t.TempDir(), "out.txt"))
+
+		var missing *ErrMissingChunk
+		if !errors.As(err, &missing) || missing.Index != 1 {
+			t.Errorf("QRCodesToFile() error = %v, want missing chunk 1", err)
+		}
+	})
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		outDir, session := newSession(t)
+		if err := os.WriteFile(session.DataFile(session.Chunks[1].Name), []byte("corrupted"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		err := NewQRFileTransfer().QRCodesToFile(outDir, filepath.Join(t.TempDir(), "out.txt"))
+		if !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("QRCodesToFile() error = %v, want ErrHashMismatch", err)
+		}
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		outDir, session := newSession(t)
+
+		session.Version = SessionVersion + 1
+
+		data, err := json.Marshal(session)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(outDir, SessionFileName), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		err = NewQRFileTransfer().QRCodesToFile(outDir, filepath.Join(t.TempDir(), "out.txt"))
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("QRCodesToFile() error = %v, want ErrUnsupportedVersion", err)
+		}
+	})
+}
@@ -0,0 +1,260 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+// Estimate describes the QR codes FileToQRCodes would generate for a file with the
+// current settings, see EstimateFile
+type Estimate struct {
+	// Name is the base name of the file
+	Name string
+	// Size is the size of the file in bytes
+	Size int64
+	// Codes lists the QR code of every chunk in order, followed by those of the
+	// parity chunks
+	Codes []EstimatedCode
+	// ChunkSizeReductions records every reduction of the chunk size FileToQRCodes
+	// would make because a chunk does not fit in a QR code
+	ChunkSizeReductions []ChunkSizeReduction
+}
+
+// EstimatedCode describes the QR code of a single chunk
+type EstimatedCode struct {
+	// ChunkSize is the size of the chunk data in bytes, including the metadata of
+	// the first chunk and the checksum
+	ChunkSize int
+	// Version is the QR code version
+	Version int
+	// RecoveryLevel is the recovery level of the QR code
+	RecoveryLevel qrcode.RecoveryLevel
+	// Pixels is the width and height of the QR code image in pixels
+	Pixels int
+	// Parity is set for the QR code of a parity chunk, see QRFileTransfer.SetParity
+	Parity bool
+}
+
+// PixelCount returns the number of pixels of all QR code images
+func (e *Estimate) PixelCount() int64 {
+	var n int64
+
+	for _, c := range e.Codes {
+		n += int64(c.Pixels) * int64(c.Pixels)
+	}
+
+	return n
+}
+
+// ParityCodes returns the number of QR codes of parity chunks
+func (e *Estimate) ParityCodes() int {
+	n := 0
+
+	for _, c := range e.Codes {
+		if c.Parity {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Versions returns the number of QR codes of every QR code version used
+func (e *Estimate) Versions() map[int]int {
+	versions := make(map[int]int)
+
+	for _, c := range e.Codes {
+		versions[c.Version]++
+	}
+
+	return versions
+}
+
+// Duration returns the time a video showing every QR code once takes at fps frames
+// per second
+func (e *Estimate) Duration(fps float64) time.Duration {
+	if fps <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(len(e.Codes)) / fps * float64(time.Second))
+}
+
+// String returns a one-line summary of the estimate
+func (e *Estimate) String() string {
+	versions := e.Versions()
+
+	keys := make([]int, 0, len(versions))
+	for v := range versions {
+		keys = append(keys, v)
+	}
+
+	sort.Ints(keys)
+
+	summary := fmt.Sprintf("%d QR codes", len(e.Codes))
+	if len(keys) > 0 {
+		summary += fmt.Sprintf(", versions %d-%d", keys[0], keys[len(keys)-1])
+	}
+
+	return summary + fmt.Sprintf(", %.1f megapixels", float64(e.PixelCount())/1e6)
+}
+
+// EstimateFile reports the QR codes FileToQRCodes would generate for the file at
+// filePath with the current settings, including the number of chunks, the QR code
+// version and image size of every chunk, and any reduction of the chunk size,
+// without writing anything. The file is split in memory and no image is drawn, so
+// settings can be tuned quickly before encoding.
+func (q *QRFileTransfer) EstimateFile(filePath string) (*Estimate, error) {
+	file, err := q.fs.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+
+	if stat.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+
+	// Chunks are written to memory, reads go to the file system of q
+	mem := q.withFs(afero.NewCopyOnWriteFs(afero.NewReadOnlyFs(q.fs), afero.NewMemMapFs()))
+
+	estimate := &Estimate{Name: filepath.Base(file.Name()), Size: stat.Size()}
+	numChunks := q.chunkCount(estimate.Name, stat.Size())
+
+	// Reduce the chunk size like FileToQRCodes does
+	for {
+		codes, err := mem.estimateChunks(file, numChunks)
+		if err == nil {
+			estimate.Codes = codes
+
+			return estimate, nil
+		}
+
+		if !errors.Is(err, qrcode.ErrContentTooLong) || len(estimate.ChunkSizeReductions) >= maxChunkSizeReductions {
+			return nil, err
+		}
+
+		reduction := reduceChunkSize(stat.Size(), numChunks)
+		estimate.ChunkSizeReductions = append(estimate.ChunkSizeReductions, reduction)
+		numChunks = reduction.ToChunks
+	}
+}
+
+// estimateChunks splits file into numChunks chunks and returns the QR code of
+// every chunk, or an error wrapping qrcode.ErrContentTooLong if a chunk does not
+// fit in a QR code
+func (q *QRFileTransfer) estimateChunks(file afero.File, numChunks int) ([]EstimatedCode, error) {
+	tempDir, releaseTemp, err := q.makeTempDir("qrcode_estimate_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		_ = releaseTemp()
+	}()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file stats: %w", err)
+	}
+
+	splitResult, err := q.splitter.SplitReader(file, stat.Size(), file.Name(), tempDir, q.splitOptions(file.Name(), stat.Size(), numChunks))
+	if err != nil {
+		return nil, fmt.Errorf("failed to split file: %w", err)
+	}
+
+	chunkFiles := splitResult.Chunks
+	indices := make([]int, 0, len(chunkFiles))
+
+	for index := range splitResult.File.Total {
+		if _, ok := splitResult.File.Refs[index]; !ok {
+			indices = append(indices, index)
+		}
+	}
+
+	codes := make([]EstimatedCode, 0, len(chunkFiles))
+	chunks := make([][]byte, len(chunkFiles))
+
+	for i, chunkPath := range chunkFiles {
+		chunkData, err := afero.ReadFile(q.fs, chunkPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s: %w", chunkPath, err)
+		}
+
+		baseName := filepath.Base(chunkPath)
+		name := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+		code, err := q.estimateCode(&ChunkPayload{File: q.fileID, Name: name, Data: chunkData, Next: nextHints(indices, i, q.nextHints)})
+		if err != nil {
+			return nil, err
+		}
+
+		chunks[i] = chunkData
+		codes = append(codes, code)
+	}
+
+	if q.parity == 0 || len(chunkFiles) == 0 {
+		return codes, nil
+	}
+
+	// The parity chunks are computed like FileToQRCodes does
+	parity, err := makeParityChunks(chunks, q.parity)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := filepath.Base(chunkFiles[0])
+	base := chunkIndexPattern.ReplaceAllString(strings.TrimSuffix(baseName, filepath.Ext(baseName)), "")
+
+	for i, data := range parity {
+		code, err := q.estimateCode(&ChunkPayload{File: q.fileID, Name: parityChunkName(base, i, len(parity)), Data: data})
+		if err != nil {
+			return nil, err
+		}
+
+		code.Parity = true
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// estimateCode returns the QR code of the chunk payload
+func (q *QRFileTransfer) estimateCode(payload *ChunkPayload) (EstimatedCode, error) {
+	qrContent, err := q.encodePayload(payload)
+	if err != nil {
+		return EstimatedCode{}, fmt.Errorf("failed to encode payload for chunk %s: %w", payload.Name, err)
+	}
+
+	qrCode, err := q.newChunkQRCode(qrContent)
+	if err != nil {
+		return EstimatedCode{}, fmt.Errorf("failed to create QR code for chunk %s: %w", payload.Name, err)
+	}
+
+	pixels := q.qrSize
+	if q.autoAdjustQRSize {
+		pixels = q.calculateOptimalQRSize(qrCode.VersionNumber)
+	}
+
+	return EstimatedCode{ChunkSize: len(payload.Data), Version: qrCode.VersionNumber, RecoveryLevel: qrCode.Level, Pixels: pixels}, nil
+}
@@ -0,0 +1,96 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+func TestEstimateFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/estimate.txt", bytes.Repeat([]byte("how many codes "), 700), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetRecoveryLevel(qrcode.High)
+
+	estimate, err := qrft.EstimateFile("/in/estimate.txt")
+	if err != nil {
+		t.Fatalf("EstimateFile failed: %v", err)
+	}
+
+	// Nothing is written
+	if entries, _ := afero.ReadDir(fs, "/"); len(entries) != 1 {
+		t.Errorf("EstimateFile wrote %v", entries)
+	}
+
+	// The estimate matches the QR codes that are generated
+	if err := qrft.FileToQRCodes("/in/estimate.txt", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := loadSession(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(estimate.Codes) != len(session.Chunks) {
+		t.Fatalf("EstimateFile() = %d QR codes, FileToQRCodes generated %d", len(estimate.Codes), len(session.Chunks))
+	}
+
+	for i, c := range estimate.Codes {
+		if c.Version != session.Chunks[i].QRVersion || int64(c.ChunkSize) != session.Chunks[i].Size {
+			t.Errorf("Chunk %d estimated as version %d of %d bytes, generated version %d of %d bytes",
+				i, c.Version, c.ChunkSize, session.Chunks[i].QRVersion, session.Chunks[i].Size)
+		}
+	}
+
+	if got, want := estimate.Duration(2), time.Duration(len(estimate.Codes))*time.Second/2; got != want {
+		t.Errorf("Duration(2) = %v, want %v", got, want)
+	}
+
+	if estimate.PixelCount() < int64(len(estimate.Codes))*800*800 {
+		t.Errorf("PixelCount() = %d, want at least %d QR codes of 800x800 pixels", estimate.PixelCount(), len(estimate.Codes))
+	}
+}
+
+func TestEstimateFileTargetQRVersion(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/large.bin", bytes.Repeat([]byte{0xa5}, 4500), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetTargetQRVersion(15)
+
+	estimate, err := qrft.EstimateFile("/in/large.bin")
+	if err != nil {
+		t.Fatalf("EstimateFile failed: %v", err)
+	}
+
+	for v := range estimate.Versions() {
+		if v > 15 {
+			t.Errorf("EstimateFile() = %s, want versions up to 15", estimate)
+		}
+	}
+
+	// The metadata of the first chunk does not fit in a version 2 QR code
+	if err := afero.WriteFile(fs, "/in/small.bin", []byte("small"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft.SetTargetQRVersion(2)
+
+	if _, err := qrft.EstimateFile("/in/small.bin"); !errors.Is(err, qrcode.ErrContentTooLong) {
+		t.Errorf("EstimateFile() error = %v, want %v", err, qrcode.ErrContentTooLong)
+	}
+}
@@ -0,0 +1,175 @@
+package qrfiletransfer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// maxExportEntrySize bounds the size of a single file read from an export archive
+const maxExportEntrySize = 64 << 20
+
+// ExportState packs the reception state of dir into a gzip compressed tar archive
+// written to w, so that a partially decoded transfer can be completed on another
+// machine with ImportState. The archive holds the received chunks under data/,
+// the session file and manifest if present, and the quarantined chunks, see
+// CombineChunks. QR code images are not included.
+func ExportState(dir string, w io.Writer) error {
+	return exportState(osFs, dir, w)
+}
+
+// exportState is ExportState on the file system fsys
+func exportState(fsys afero.Fs, dir string, w io.Writer) error {
+	session, err := openSession(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	// Archive entry names mapped to the files they are read from
+	entries := make(map[string]string)
+
+	for _, name := range []string{SessionFileName, ManifestFileName} {
+		if p := filepath.Join(dir, name); fileExists(fsys, p) {
+			entries[name] = p
+		}
+	}
+
+	// Chunks are stored as data files whatever the layout they were received in
+	dataDir := defaultSessionLayout().Data
+	for _, p := range session.chunkDataFiles(fsys) {
+		base := filepath.Base(p)
+		entries[path.Join(dataDir, strings.TrimSuffix(base, filepath.Ext(base))+".dat")] = p
+	}
+
+	quarantined, err := afero.Glob(fsys, filepath.Join(dir, QuarantineDirName, "*.dat"))
+	if err != nil {
+		return fmt.Errorf("failed to list quarantined chunks: %w", err)
+	}
+
+	for _, p := range quarantined {
+		entries[path.Join(QuarantineDirName, filepath.Base(p))] = p
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range names {
+		data, err := afero.ReadFile(fsys, entries[name])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entries[name], err)
+		}
+
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+			Format:  tar.FormatPAX,
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write archive entry %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	return nil
+}
+
+// ImportState unpacks an archive written by ExportState into dir, which must not
+// exist or be empty. Entries other than those ExportState writes are rejected.
+func ImportState(r io.Reader, dir string) error {
+	return importState(osFs, r, dir)
+}
+
+// importState is ImportState on the file system fsys
+func importState(fsys afero.Fs, r io.Reader, dir string) error {
+	if entries, err := afero.ReadDir(fsys, dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", dir)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if !isStateEntry(header) {
+			return fmt.Errorf("unexpected archive entry %q", header.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err := fsys.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, header.Size))
+		if err != nil {
+			return fmt.Errorf("failed to read archive entry %s: %w", header.Name, err)
+		}
+
+		if err := afero.WriteFile(fsys, target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+	}
+
+	return gz.Close()
+}
+
+// isStateEntry reports whether an archive entry is one ExportState writes: the
+// session file, the manifest, or a file directly inside data/ or the quarantine
+func isStateEntry(header *tar.Header) bool {
+	if header.Typeflag != tar.TypeReg || header.Size < 0 || header.Size > maxExportEntrySize {
+		return false
+	}
+
+	switch dir, file := path.Split(header.Name); dir {
+	case "":
+		return file == SessionFileName || file == ManifestFileName
+	case defaultSessionLayout().Data + "/", QuarantineDirName + "/":
+		return file != "" && file != "." && file != ".." && !strings.Contains(file, `\`)
+	}
+
+	return false
+}
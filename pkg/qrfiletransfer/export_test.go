@@ -0,0 +1,169 @@
+package qrfiletransfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestExportImportState(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "handoff.txt")
+	testContent := strings.Repeat("Finish what I started. ", 150)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	sessionDir := filepath.Join(testDir, "session")
+	qrft := NewQRFileTransfer()
+
+	if err := qrft.FileToQRCodes(testFilePath, sessionDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(sessionDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	// A partial reception missing the last chunk, with a quarantined chunk
+	stateDir := filepath.Join(testDir, "state")
+	if err := os.MkdirAll(filepath.Join(stateDir, "data"), 0750); err != nil {
+		t.Fatalf("Failed to create state directory: %v", err)
+	}
+
+	for _, chunk := range session.Chunks[:len(session.Chunks)-1] {
+		data, err := os.ReadFile(session.DataFile(chunk.Name))
+		if err != nil {
+			t.Fatalf("Failed to read data file: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(stateDir, "data", chunk.Name+".dat"), data, 0600); err != nil {
+			t.Fatalf("Failed to write data file: %v", err)
+		}
+	}
+
+	manifest, err := LoadManifest(sessionDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if err := manifest.save(osFs, stateDir); err != nil {
+		t.Fatalf("Failed to save manifest: %v", err)
+	}
+
+	if err := quarantineChunk(osFs, stateDir, session.Chunks[0].Name, []byte("misread")); err != nil {
+		t.Fatalf("Failed to quarantine chunk: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := ExportState(stateDir, &archive); err != nil {
+		t.Fatalf("ExportState failed: %v", err)
+	}
+
+	importDir := filepath.Join(testDir, "imported")
+	if err := ImportState(bytes.NewReader(archive.Bytes()), importDir); err != nil {
+		t.Fatalf("ImportState failed: %v", err)
+	}
+
+	want, err := qrft.VerifyChunks(stateDir)
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	got, err := qrft.VerifyChunks(importDir)
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Imported state %s, want %s", got, want)
+	}
+
+	for _, name := range []string{ManifestFileName, QuarantineDirName} {
+		if _, err := os.Stat(filepath.Join(importDir, name)); err != nil {
+			t.Errorf("Imported state lacks %s: %v", name, err)
+		}
+	}
+
+	// Importing again into the now populated directory is refused
+	if err := ImportState(bytes.NewReader(archive.Bytes()), importDir); err == nil {
+		t.Error("ImportState into a non-empty directory succeeded")
+	}
+}
+
+func TestExportImportStateInMemory(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/handoff.txt", []byte(strings.Repeat("Kept in memory. ", 150)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+
+	if err := qrft.FileToQRCodes("/in/handoff.txt", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := exportState(fs, "/session", &archive); err != nil {
+		t.Fatalf("exportState failed: %v", err)
+	}
+
+	if err := importState(fs, bytes.NewReader(archive.Bytes()), "/imported"); err != nil {
+		t.Fatalf("importState failed: %v", err)
+	}
+
+	want, err := qrft.VerifyChunks("/session")
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	got, err := qrft.VerifyChunks("/imported")
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Imported state %s, want %s", got, want)
+	}
+}
+
+func TestImportStateRejectsUnexpectedEntries(t *testing.T) {
+	for _, name := range []string{"../escape.dat", "data/../../escape.dat", "qrcodes/file_0000.png", "data/nested/file_0000.dat"} {
+		var archive bytes.Buffer
+
+		gz := gzip.NewWriter(&archive)
+		tw := tar.NewWriter(gz)
+
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ImportState(&archive, filepath.Join(t.TempDir(), "imported")); err == nil {
+			t.Errorf("ImportState accepted entry %q", name)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package qrfiletransfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"path/filepath"
+	"strconv"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+// FrameOrderFileName is the name of the file that lists the frames of a batch in
+// playback order
+const FrameOrderFileName = "frames.json"
+
+// FrameOrderVersion is the version of the frame order file written by this package
+const FrameOrderVersion = 1
+
+// minFrameNameDigits is the minimum number of digits of a global frame name
+const minFrameNameDigits = 4
+
+// FrameOrder lists every QR code of a batch in the order it is played back.
+// Frames are ordered by file ID and then by chunk, and named by their zero-padded
+// global position, so that generate and serve play the frames of a batch in the
+// same order across regenerations and machines. Paths are relative to the batch
+// directory and use forward slashes.
+type FrameOrder struct {
+	// Version is the frame order file version
+	Version int `json:"version"`
+	// Frames lists every frame in playback order
+	Frames []OrderedFrame `json:"frames"`
+}
+
+// OrderedFrame describes a single frame of a frame order
+type OrderedFrame struct {
+	// Index is the global position of the frame, starting at 0
+	Index int `json:"index"`
+	// Name is the zero-padded global frame number, starting at 1
+	Name string `json:"name"`
+	// File is the ID of the file the frame belongs to
+	File string `json:"file"`
+	// Chunk is the name of the chunk the frame shows
+	Chunk string `json:"chunk"`
+	// Path is the path of the QR code image relative to the batch directory
+	Path string `json:"path"`
+}
+
+// BuildFrameOrder lists the QR codes of the files of the batch in dir in playback
+// order. Every file must be a complete session with QR codes.
+func BuildFrameOrder(dir string) (*FrameOrder, error) {
+	return buildFrameOrder(osFs, dir)
+}
+
+// buildFrameOrder is BuildFrameOrder on the file system fsys
+func buildFrameOrder(fsys afero.Fs, dir string) (*FrameOrder, error) {
+	ids, err := batchFileIDs(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%s is not a batch directory", dir)
+	}
+
+	order := &FrameOrder{Version: FrameOrderVersion}
+
+	for _, id := range ids {
+		session, err := loadSession(fsys, filepath.Join(dir, id))
+		if err != nil {
+			return nil, err
+		}
+
+		if !session.Complete || session.QRCodesDir() == "" {
+			return nil, fmt.Errorf("session of file %s has no complete set of QR codes", id)
+		}
+
+		// Parity chunks are shown after the data chunks of their file
+		for _, chunk := range append(session.Chunks, session.Parity...) {
+			rel, err := filepath.Rel(dir, session.QRCodeFile(chunk.Name))
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate QR code of chunk %s: %w", chunk.Name, err)
+			}
+
+			order.Frames = append(order.Frames, OrderedFrame{
+				Index: len(order.Frames),
+				File:  id,
+				Chunk: chunk.Name,
+				Path:  filepath.ToSlash(rel),
+			})
+		}
+	}
+
+	for i := range order.Frames {
+		order.Frames[i].Name = frameName(i, len(order.Frames))
+	}
+
+	return order, nil
+}
+
+// save writes the frame order into a batch directory
+func (o *FrameOrder) save(fsys afero.Fs, dir string) error {
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode frame order: %w", err)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, FrameOrderFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write frame order: %w", err)
+	}
+
+	return nil
+}
+
+// LoadFrameOrder reads the frame order file from a batch directory.
+// It returns an error wrapping os.ErrNotExist if the directory has no frame order.
+func LoadFrameOrder(dir string) (*FrameOrder, error) {
+	return loadFrameOrder(osFs, dir)
+}
+
+// loadFrameOrder is LoadFrameOrder on the file system fsys
+func loadFrameOrder(fsys afero.Fs, dir string) (*FrameOrder, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(dir, FrameOrderFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame order: %w", err)
+	}
+
+	var o FrameOrder
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("failed to decode frame order: %w", err)
+	}
+
+	if o.Version > FrameOrderVersion {
+		return nil, fmt.Errorf("%w: frame order version %d (newest supported is %d)", ErrUnsupportedVersion, o.Version, FrameOrderVersion)
+	}
+
+	for i, f := range o.Frames {
+		if f.Index != i {
+			return nil, fmt.Errorf("frame %s is out of order in frame order", f.Name)
+		}
+
+		if _, ok := archiveEntryPath(f.Path); !ok {
+			return nil, fmt.Errorf("invalid frame path %q in frame order", f.Path)
+		}
+	}
+
+	return &o, nil
+}
+
+// FramePaths returns the paths of the QR code images of a frame order loaded from
+// dir, in playback order
+func (o *FrameOrder) FramePaths(dir string) []string {
+	paths := make([]string, 0, len(o.Frames))
+	for _, f := range o.Frames {
+		paths = append(paths, filepath.Join(dir, filepath.FromSlash(f.Path)))
+	}
+
+	return paths
+}
+
+// WriteFrameSequence copies the images in frames into outDir as a flat sequence
+// named by their zero-padded position starting at 1, e.g. 0001.png, for tools that
+// play back a directory of images in lexical order. For the frames of a batch in
+// playback order, the names are those of its FrameOrder.
+func WriteFrameSequence(frames []string, outDir string) error {
+	return writeFrameSequence(osFs, frames, outDir)
+}
+
+// writeFrameSequence is WriteFrameSequence on the file system fsys
+func writeFrameSequence(fsys afero.Fs, frames []string, outDir string) error {
+	if err := fsys.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create frame directory: %w", err)
+	}
+
+	for i, path := range frames {
+		data, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("failed to read frame %s: %w", path, err)
+		}
+
+		target := filepath.Join(outDir, frameName(i, len(frames))+filepath.Ext(path))
+		if err := WriteFileAtomic(fsys, target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write frame %s: %w", target, err)
+		}
+	}
+
+	return nil
+}
+
+// frameName returns the global name of frame i of total frames
+func frameName(i, total int) string {
+	return fmt.Sprintf("%0*d", max(minFrameNameDigits, len(strconv.Itoa(total))), i+1)
+}
+
+// TileFrames lays out the QR code images in frames, in playback order, into frames
+// of columns by rows codes written into outDir as PNG images named like those of
+// WriteFrameSequence, and returns their paths. Every tiled frame multiplies the
+// chunks a video carries per frame, read decodes all the codes of a frame. The last
+// frame leaves the cells without a code blank.
+func TileFrames(frames []string, outDir string, columns, rows int) ([]string, error) {
+	return tileFrames(osFs, frames, outDir, columns, rows)
+}
+
+// tileFrames is TileFrames on the file system fsys
+func tileFrames(fsys afero.Fs, frames []string, outDir string, columns, rows int) ([]string, error) {
+	if columns < 1 || rows < 1 {
+		return nil, fmt.Errorf("invalid tile grid %dx%d", columns, rows)
+	}
+
+	if err := fsys.MkdirAll(outDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create frame directory: %w", err)
+	}
+
+	perFrame := columns * rows
+	total := (len(frames) + perFrame - 1) / perFrame
+	tiled := make([]string, 0, total)
+
+	for i := range total {
+		var images []image.Image
+
+		for _, path := range frames[i*perFrame : min(len(frames), (i+1)*perFrame)] {
+			img, err := readPNG(fsys, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read frame %s: %w", path, err)
+			}
+
+			images = append(images, img)
+		}
+
+		data, err := encodePNG(imaging.Tile(images, columns, rows), qrcode.DefaultWriteFileOptions())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode tiled frame: %w", err)
+		}
+
+		target := filepath.Join(outDir, frameName(i, total)+".png")
+		if err := WriteFileAtomic(fsys, target, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write frame %s: %w", target, err)
+		}
+
+		tiled = append(tiled, target)
+	}
+
+	return tiled, nil
+}
+
+// readPNG decodes the PNG image at path on the file system fsys
+func readPNG(fsys afero.Fs, path string) (image.Image, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return png.Decode(f)
+}
@@ -0,0 +1,165 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestFrameOrder(t *testing.T) {
+	testDir := t.TempDir()
+
+	var paths []string
+
+	for i, content := range []string{strings.Repeat("first ", 400), strings.Repeat("second ", 200)} {
+		path := filepath.Join(testDir, "input", string(rune('a'+i))+".txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	qrft := NewQRFileTransfer()
+	batchDir := filepath.Join(testDir, "batch")
+
+	batch, err := qrft.FilesToQRCodes(paths, batchDir)
+	if err != nil {
+		t.Fatalf("FilesToQRCodes failed: %v", err)
+	}
+
+	order, err := LoadFrameOrder(batchDir)
+	if err != nil {
+		t.Fatalf("LoadFrameOrder failed: %v", err)
+	}
+
+	// Every chunk of the first file, then every chunk of the second
+	var want []string
+
+	for _, f := range batch.Files {
+		session, err := LoadSession(filepath.Join(batchDir, f.ID))
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		for _, chunk := range session.Chunks {
+			want = append(want, f.ID+"/"+chunk.Name)
+		}
+	}
+
+	if len(order.Frames) != len(want) || order.Frames[0].Name != "0001" {
+		t.Fatalf("Unexpected frame order: %+v", order.Frames)
+	}
+
+	for i, f := range order.Frames {
+		if f.File+"/"+f.Chunk != want[i] {
+			t.Errorf("Frame %d is %s/%s, want %s", i, f.File, f.Chunk, want[i])
+		}
+	}
+
+	for _, path := range order.FramePaths(batchDir) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Frame %s is missing: %v", path, err)
+		}
+	}
+
+	// Regenerating the batch elsewhere yields the same order
+	otherDir := filepath.Join(testDir, "other")
+	if _, err := qrft.FilesToQRCodes(paths, otherDir); err != nil {
+		t.Fatalf("FilesToQRCodes failed: %v", err)
+	}
+
+	first, _ := os.ReadFile(filepath.Join(batchDir, FrameOrderFileName))
+	second, _ := os.ReadFile(filepath.Join(otherDir, FrameOrderFileName))
+
+	if !bytes.Equal(first, second) {
+		t.Error("Frame order differs between regenerations")
+	}
+
+	// The flat sequence sorts in playback order
+	seqDir := filepath.Join(testDir, "sequence")
+	if err := WriteFrameSequence(order.FramePaths(batchDir), seqDir); err != nil {
+		t.Fatalf("WriteFrameSequence failed: %v", err)
+	}
+
+	images, err := filepath.Glob(filepath.Join(seqDir, "*.png"))
+	if err != nil || len(images) != len(want) || !sort.StringsAreSorted(images) {
+		t.Fatalf("Unexpected frame sequence: %v (%v)", images, err)
+	}
+
+	if filepath.Base(images[0]) != order.Frames[0].Name+".png" {
+		t.Errorf("First image of the sequence is %s", images[0])
+	}
+
+	last, _ := os.ReadFile(images[len(images)-1])
+	lastFrame, _ := os.ReadFile(order.FramePaths(batchDir)[len(want)-1])
+
+	if !bytes.Equal(last, lastFrame) {
+		t.Error("Last image of the sequence is not the last frame")
+	}
+}
+
+func TestLoadFrameOrderRejectsEscapingPaths(t *testing.T) {
+	dir := t.TempDir()
+	order := &FrameOrder{Version: FrameOrderVersion, Frames: []OrderedFrame{{Name: "0001", File: "1", Chunk: "a_0000", Path: "../a_0000.png"}}}
+
+	if err := order.save(osFs, dir); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	if _, err := LoadFrameOrder(dir); err == nil {
+		t.Error("LoadFrameOrder accepted a path outside the batch directory")
+	}
+}
+
+func TestTileFrames(t *testing.T) {
+	testDir := t.TempDir()
+
+	var frames []string
+
+	for i := range 5 {
+		q, err := qrcode.New(fmt.Sprintf("chunk %d", i), qrcode.Medium)
+		if err != nil {
+			t.Fatalf("Failed to create QR code: %v", err)
+		}
+
+		path := filepath.Join(testDir, fmt.Sprintf("chunk_%d.png", i))
+		if err := q.WriteFile(100, path); err != nil {
+			t.Fatalf("Failed to write QR code: %v", err)
+		}
+
+		frames = append(frames, path)
+	}
+
+	tiled, err := TileFrames(frames, filepath.Join(testDir, "tiled"), 2, 2)
+	if err != nil {
+		t.Fatalf("TileFrames failed: %v", err)
+	}
+
+	if len(tiled) != 2 || filepath.Base(tiled[0]) != "0001.png" || filepath.Base(tiled[1]) != "0002.png" {
+		t.Fatalf("Unexpected tiled frames: %v", tiled)
+	}
+
+	img, err := readPNG(osFs, tiled[1])
+	if err != nil {
+		t.Fatalf("Failed to read tiled frame: %v", err)
+	}
+
+	if got := img.Bounds(); got.Dx() != 200 || got.Dy() != 200 {
+		t.Errorf("Tiled frame is %v, want 200x200", got)
+	}
+
+	if _, err := TileFrames(frames, filepath.Join(testDir, "invalid"), 0, 2); err == nil {
+		t.Error("TileFrames accepted an empty grid")
+	}
+}
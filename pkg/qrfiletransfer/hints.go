@@ -0,0 +1,99 @@
+package qrfiletransfer
+
+import "sort"
+
+// SkipDetector notices skipped chunks from the next-up hints of decoded payloads.
+// Without hints a missing chunk only shows up once the whole pass has been read;
+// with them a receiver knows which chunks should have come between two decoded
+// ones and can flag the gap immediately.
+type SkipDetector struct {
+	have    map[int]bool
+	skipped map[int]bool
+
+	// last is the index of the previously decoded chunk and expected its hints
+	last     int
+	expected []int
+}
+
+// NewSkipDetector creates a detector. Chunks received before, e.g. by an earlier
+// run, are passed as known and never reported as skipped.
+func NewSkipDetector(known ...int) *SkipDetector {
+	d := &SkipDetector{
+		have:    make(map[int]bool, len(known)),
+		skipped: make(map[int]bool),
+		last:    -1,
+	}
+
+	for _, index := range known {
+		d.have[index] = true
+	}
+
+	return d
+}
+
+// Observe records a decoded chunk, in the order chunks were decoded, and returns
+// the chunks newly found to have been skipped before it
+func (d *SkipDetector) Observe(index int, next []int) []int {
+	d.have[index] = true
+	delete(d.skipped, index)
+
+	// The same code seen in consecutive frames
+	if index == d.last {
+		if len(next) > 0 {
+			d.expected = next
+		}
+
+		return nil
+	}
+
+	// Every hinted chunk before this one was skipped; if this chunk was not hinted
+	// at all, the receiver jumped past every hinted chunk
+	gap := d.expected
+	for i, hint := range d.expected {
+		if hint == index {
+			gap = d.expected[:i]
+
+			break
+		}
+	}
+
+	var newlySkipped []int
+
+	for _, hint := range gap {
+		if !d.have[hint] && !d.skipped[hint] {
+			d.skipped[hint] = true
+			newlySkipped = append(newlySkipped, hint)
+		}
+	}
+
+	d.last = index
+	d.expected = next
+
+	return newlySkipped
+}
+
+// Skipped returns the chunks found to have been skipped that have not been decoded
+// since, in ascending order
+func (d *SkipDetector) Skipped() []int {
+	indices := make([]int, 0, len(d.skipped))
+	for index := range d.skipped {
+		indices = append(indices, index)
+	}
+
+	sort.Ints(indices)
+
+	return indices
+}
+
+// nextHints returns the next-up hints of the chunk at position i of the chunks at
+// indices, see Session.chunkIndices: the indices of the following count chunks, in
+// order
+func nextHints(indices []int, i, count int) []int {
+	var hints []int
+
+	for _, index := range indices[i+1 : min(len(indices), i+1+count)] {
+		hints = append(hints, index)
+	}
+
+	return hints
+}
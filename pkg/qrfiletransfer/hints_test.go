@@ -0,0 +1,118 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPayloadNextHintsRoundTrip(t *testing.T) {
+	data := []byte{0x00, 0xff, 'Q', 'F', 'T', 0x0a}
+	next := []int{4, 5, 300}
+
+	for _, format := range []PayloadFormat{PayloadFormatText, PayloadFormatBinary} {
+		content, err := EncodeChunkPayload(format, &ChunkPayload{Name: "test_0003", Data: data, Next: next})
+		if err != nil {
+			t.Fatalf("EncodeChunkPayload(%s) failed: %v", format, err)
+		}
+
+		payload, err := DecodePayload(content)
+		if err != nil {
+			t.Fatalf("DecodePayload(%s) failed: %v", format, err)
+		}
+
+		if payload.Name != "test_0003" || !bytes.Equal(payload.Data, data) || !reflect.DeepEqual(payload.Next, next) {
+			t.Fatalf("DecodePayload(%s) got %+v", format, payload)
+		}
+
+		if index, ok := payload.Index(); !ok || index != 3 {
+			t.Errorf("Index(%s) = %d, %v", format, index, ok)
+		}
+	}
+}
+
+func TestSkipDetector(t *testing.T) {
+	d := NewSkipDetector()
+
+	// Chunk 0 announces 1 and 2; decoding 2 right after 0 means 1 was skipped
+	if skipped := d.Observe(0, []int{1, 2}); len(skipped) != 0 {
+		t.Fatalf("Unexpected skipped chunks %v", skipped)
+	}
+
+	if skipped := d.Observe(0, []int{1, 2}); len(skipped) != 0 {
+		t.Fatalf("A repeated frame must not report skips, got %v", skipped)
+	}
+
+	if skipped := d.Observe(2, []int{3, 4}); !reflect.DeepEqual(skipped, []int{1}) {
+		t.Fatalf("Expected chunk 1 to be skipped, got %v", skipped)
+	}
+
+	// Jumping past every hinted chunk skips all of them
+	if skipped := d.Observe(7, nil); !reflect.DeepEqual(skipped, []int{3, 4}) {
+		t.Fatalf("Expected chunks 3 and 4 to be skipped, got %v", skipped)
+	}
+
+	// A skipped chunk decoded later is no longer reported
+	d.Observe(1, []int{2, 3})
+
+	if skipped := d.Skipped(); !reflect.DeepEqual(skipped, []int{3, 4}) {
+		t.Fatalf("Expected chunks 3 and 4 to remain skipped, got %v", skipped)
+	}
+
+	// Known chunks are never reported
+	d = NewSkipDetector(1)
+	d.Observe(0, []int{1, 2})
+
+	if skipped := d.Observe(2, nil); len(skipped) != 0 {
+		t.Fatalf("A known chunk was reported as skipped: %v", skipped)
+	}
+}
+
+func TestFileToQRCodesNextHints(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "hints.txt")
+	testContent := strings.Repeat("Receivers learn what comes next. ", 150)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+	qrft.SetNextHints(2)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if manifest.NextHints != 2 || manifest.PayloadFormatVersion != int(binaryPayloadVersionHints) {
+		t.Errorf("Unexpected manifest hints %d and payload version %d", manifest.NextHints, manifest.PayloadFormatVersion)
+	}
+
+	reconstructedFilePath := filepath.Join(testDir, "reconstructed.txt")
+	if err := qrft.QRCodesToFile(outDir, reconstructedFilePath); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructedContent, err := os.ReadFile(reconstructedFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if string(reconstructedContent) != testContent {
+		t.Fatal("Reconstructed content does not match original content")
+	}
+
+	if got := nextHints([]int{0, 1, 2, 3, 4}, 3, 2); !reflect.DeepEqual(got, []int{4}) {
+		t.Errorf("Expected the hints of the second to last chunk to stop at the last chunk, got %v", got)
+	}
+}
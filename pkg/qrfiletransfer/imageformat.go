@@ -0,0 +1,123 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/jpeg"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// ImageFormat identifies the file format QR code images are written in
+type ImageFormat int
+
+const (
+	// ImageFormatPNG writes QR codes as PNG images, which every command can read back
+	ImageFormatPNG ImageFormat = iota
+
+	// ImageFormatSVG writes QR codes as SVG vector images that stay crisp at any
+	// print size. The QR codes cannot be decoded from SVG images, so generate,
+	// transcode and the read commands need raster images.
+	ImageFormatSVG
+
+	// ImageFormatJPEG writes QR codes as JPEG images, for displays reading no other
+	// format. The compression blurs the edges of the modules, so QR codes should be
+	// drawn at several pixels per module.
+	ImageFormatJPEG
+
+	// ImageFormatBMP writes QR codes as uncompressed BMP images, the format of many
+	// e-ink and embedded displays
+	ImageFormatBMP
+)
+
+// jpegQuality is the quality of JPEG images, high enough to keep the modules sharp
+const jpegQuality = 90
+
+// String returns the name of the image format
+func (f ImageFormat) String() string {
+	switch f {
+	case ImageFormatPNG:
+		return "png"
+	case ImageFormatSVG:
+		return "svg"
+	case ImageFormatJPEG:
+		return "jpeg"
+	case ImageFormatBMP:
+		return "bmp"
+	}
+
+	return fmt.Sprintf("ImageFormat(%d)", int(f))
+}
+
+// Ext returns the file name extension of QR code images in the format
+func (f ImageFormat) Ext() string {
+	switch f {
+	case ImageFormatSVG:
+		return ".svg"
+	case ImageFormatJPEG:
+		return ".jpg"
+	case ImageFormatBMP:
+		return ".bmp"
+	}
+
+	return ".png"
+}
+
+// Decodable reports whether QR codes can be decoded from images in the format,
+// which are all raster formats
+func (f ImageFormat) Decodable() bool {
+	return f != ImageFormatSVG
+}
+
+// encodeImage returns img encoded in the raster format f, PNG images with the
+// options, whose resolution BMP images also record
+func encodeImage(img image.Image, f ImageFormat, options qrcode.WriteFileOptions) ([]byte, error) {
+	var b bytes.Buffer
+
+	var err error
+
+	switch f {
+	case ImageFormatJPEG:
+		err = jpeg.Encode(&b, img, &jpeg.Options{Quality: jpegQuality})
+	case ImageFormatBMP:
+		err = qrcode.EncodeBMP(&b, img, options.DPI)
+	default:
+		return encodePNG(img, options)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// svgCaptionRatio is the height of the caption strip of an SVG image relative to
+// its width
+const svgCaptionRatio = 0.08
+
+// captionSVG returns the SVG image of a QR code of size pixels with a white strip
+// added below it showing caption, like imaging.Caption does for PNG images
+func captionSVG(svg []byte, size int, caption string) []byte {
+	// Drop the XML declaration so the image can be nested
+	if i := bytes.Index(svg, []byte("?>")); i >= 0 {
+		svg = bytes.TrimSpace(svg[i+2:])
+	}
+
+	strip := max(1, int(float64(size)*svgCaptionRatio))
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		size, size+strip, size, size+strip)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", size, size+strip)
+	b.Write(svg)
+	fmt.Fprintf(&b, "\n"+`<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle" dominant-baseline="middle">%s</text>`+"\n",
+		size/2, size+strip/2, strip*3/5, html.EscapeString(caption))
+	b.WriteString("</svg>\n")
+
+	return b.Bytes()
+}
@@ -0,0 +1,232 @@
+package qrfiletransfer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// IntegrityReport describes the result of VerifyIntegrity
+type IntegrityReport struct {
+	// File describes the file as recorded in the split metadata, nil if the first
+	// chunk is missing or holds no valid metadata
+	File *FileInfo
+	// Chunks holds the result of every chunk of the file in index order
+	Chunks []ChunkIntegrity
+	// Err is the reason the file as a whole failed: missing or invalid metadata, or
+	// a SHA-256 that does not match. While chunks fail, the SHA-256 is not checked
+	// and Err is nil.
+	Err error
+}
+
+// ChunkIntegrity is the result of the checks of a single chunk
+type ChunkIntegrity struct {
+	// Index is the index of the chunk
+	Index int
+	// Path is the data file of the chunk, empty if it is missing
+	Path string
+	// Err is the reason the chunk failed, nil for an intact chunk
+	Err error
+}
+
+// OK reports whether every chunk is intact and the file matches its SHA-256
+func (r *IntegrityReport) OK() bool {
+	return r.Err == nil && r.File != nil && len(r.Failed()) == 0
+}
+
+// Failed returns the results of the chunks that failed
+func (r *IntegrityReport) Failed() []ChunkIntegrity {
+	var failed []ChunkIntegrity
+
+	for _, c := range r.Chunks {
+		if c.Err != nil {
+			failed = append(failed, c)
+		}
+	}
+
+	return failed
+}
+
+// String returns a one-line summary of the report
+func (r *IntegrityReport) String() string {
+	failed := r.Failed()
+	summary := fmt.Sprintf("%d of %d chunks intact", len(r.Chunks)-len(failed), len(r.Chunks))
+
+	if len(failed) > 0 {
+		indices := make([]int, len(failed))
+		for i, c := range failed {
+			indices[i] = c.Index
+		}
+
+		summary += ", failed " + FormatIndexRanges(indices)
+	}
+
+	switch {
+	case r.Err != nil:
+		summary += fmt.Sprintf(", %v", r.Err)
+	case len(failed) > 0:
+		summary += ", SHA-256 not checked"
+	default:
+		summary += ", SHA-256 matches"
+	}
+
+	return summary
+}
+
+// VerifyIntegrity checks the chunks of the session in inDir like QRCodesToFile does,
+// without writing the file: every data file is checked against manifest, its
+// CRC-32, and the SHA-256 of the file against the metadata, the session, and the
+// manifest. Unlike QRCodesToFile, it does not stop at the first damaged chunk.
+// inDir may be a session directory or a directory written by read or scan. A nil
+// manifest uses the manifest of inDir, if it has one; the manifest of the sending
+// session checks a directory that QR codes were decoded into.
+//
+// The returned error is only set if inDir holds no session.
+func (q *QRFileTransfer) VerifyIntegrity(inDir string, manifest *Manifest) (*IntegrityReport, error) {
+	session, err := openSession(q.fs, inDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest == nil {
+		manifest, err = loadManifest(q.fs, inDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	if f, err := q.sessionFramer(session); err != nil {
+		return nil, err
+	} else if f != nil {
+		return q.verifyFramesIntegrity(f, session, manifest)
+	}
+
+	// Locate the data file of every chunk, the first one found is checked
+	paths := integrityPaths(q.fs, session, manifest)
+
+	report := &IntegrityReport{}
+
+	info, chunkErrs, err := q.splitter.VerifyFile(paths)
+
+	// The chunks duplicating another are read from its data file
+	refs := session.refIndices()
+	if info != nil {
+		refs = info.Refs
+	}
+
+	if err != nil && info == nil {
+		// Without metadata the number of chunks is only known from the session
+		report.Err = err
+		chunkErrs = make([]error, len(paths))
+
+		for i, path := range paths {
+			if _, ok := refs[i]; !ok && path == "" {
+				chunkErrs[i] = &ErrMissingChunk{Index: i}
+			}
+		}
+	}
+
+	report.File = info
+
+	for i, chunkErr := range chunkErrs {
+		c := ChunkIntegrity{Index: i, Err: chunkErr}
+
+		// A chunk duplicating another is checked as that chunk
+		index := i
+		if ref, ok := refs[i]; ok {
+			index = ref
+		}
+
+		if index < len(paths) {
+			c.Path = paths[index]
+		}
+
+		if c.Err == nil && c.Path != "" && manifest != nil {
+			c.Err = verifyManifestChunk(q.fs, manifest, index, c.Path)
+		}
+
+		report.Chunks = append(report.Chunks, c)
+	}
+
+	if report.Err != nil || info == nil || len(report.Failed()) > 0 {
+		return report, nil
+	}
+
+	if err != nil {
+		report.Err = err
+
+		return report, nil
+	}
+
+	// The metadata must describe the file the session and the manifest describe,
+	// which record its SHA-256
+	if info.HashAlgorithm != split.HashSHA256 {
+		return report, nil
+	}
+
+	hash := hex.EncodeToString(info.Sum())
+	if session.File.Hash != "" && session.File.Hash != hash {
+		report.Err = fmt.Errorf("%w: file does not match the SHA-256 of the session", ErrHashMismatch)
+	} else if manifest != nil && manifest.File.SHA256 != hash {
+		report.Err = fmt.Errorf("%w: file does not match the SHA-256 of the manifest", ErrHashMismatch)
+	}
+
+	return report, nil
+}
+
+// integrityPaths returns the data file of every chunk of session by index, empty
+// for a missing chunk. The number of chunks is taken from manifest, or the
+// session, or the highest index found.
+func integrityPaths(fsys afero.Fs, session *Session, manifest *Manifest) []string {
+	total := session.Settings.NumChunks
+	if manifest != nil {
+		total = manifest.ChunkCount
+	}
+
+	var paths []string
+
+	for _, path := range session.chunkDataFiles(fsys) {
+		for _, index := range session.dataFileIndices(path) {
+			if total > 0 && index >= total {
+				continue
+			}
+
+			for len(paths) <= index {
+				paths = append(paths, "")
+			}
+
+			if paths[index] == "" {
+				paths[index] = path
+			}
+		}
+	}
+
+	for len(paths) < total {
+		paths = append(paths, "")
+	}
+
+	return paths
+}
+
+// verifyManifestChunk checks the data file at path of the chunk at index against
+// its manifest entry
+func verifyManifestChunk(fsys afero.Fs, manifest *Manifest, index int, path string) error {
+	for _, c := range manifest.Chunks {
+		if c.Index != index {
+			continue
+		}
+
+		data, err := afero.ReadFile(fsys, path)
+		if err != nil {
+			return fmt.Errorf("failed to read data file %s: %w", path, err)
+		}
+
+		return manifest.verifyChunk(c.Name, data)
+	}
+
+	return fmt.Errorf("chunk %d is not listed in the manifest", index)
+}
@@ -0,0 +1,139 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+func TestVerifyIntegrity(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/verify.txt", bytes.Repeat([]byte("check before deleting "), 150), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+
+	if err := qrft.FileToQRCodes("/in/verify.txt", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	report, err := qrft.VerifyIntegrity("/session", nil)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+
+	if !report.OK() || len(report.Chunks) < 4 || report.File.Name != "verify.txt" {
+		t.Fatalf("VerifyIntegrity() = %s, want every chunk intact", report)
+	}
+
+	session, err := loadSession(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt a chunk and remove another, both are reported in one pass
+	corrupted := session.DataFile(session.Chunks[1].Name)
+
+	data, err := afero.ReadFile(fs, corrupted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[0] ^= 0xff
+	if err := afero.WriteFile(fs, corrupted, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Remove(session.DataFile(session.Chunks[3].Name)); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = qrft.VerifyIntegrity("/session", nil)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+
+	failed := report.Failed()
+	if report.OK() || len(failed) != 2 || failed[0].Index != 1 || failed[1].Index != 3 {
+		t.Fatalf("VerifyIntegrity() = %s, want chunks 1 and 3 failed", report)
+	}
+
+	var checksumErr *split.ChunkChecksumError
+	if !errors.As(failed[0].Err, &checksumErr) {
+		t.Errorf("Corrupted chunk error = %v, want ChunkChecksumError", failed[0].Err)
+	}
+
+	var missingErr *ErrMissingChunk
+	if !errors.As(failed[1].Err, &missingErr) || missingErr.Index != 3 {
+		t.Errorf("Missing chunk error = %v, want ErrMissingChunk", failed[1].Err)
+	}
+
+	// Nothing is reconstructed
+	if entries, _ := afero.ReadDir(fs, "/session"); len(entries) != 4 {
+		t.Errorf("VerifyIntegrity wrote into the session: %v", entries)
+	}
+}
+
+func TestVerifyIntegrityManifest(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/verify.txt", bytes.Repeat([]byte("decoded elsewhere "), 150), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+
+	if err := qrft.FileToQRCodes("/in/verify.txt", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	manifest, err := loadManifest(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Decoded chunks are checked against the manifest of the sending session
+	files, err := afero.Glob(fs, "/session/data/*.dat")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Found data files %v (%v)", files, err)
+	}
+
+	for _, path := range files {
+		data, err := afero.ReadFile(fs, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := afero.WriteFile(fs, filepath.Join("/decoded/data", filepath.Base(path)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := qrft.VerifyIntegrity("/decoded", manifest)
+	if err != nil || !report.OK() {
+		t.Fatalf("VerifyIntegrity() = %v, %v, want every chunk intact", report, err)
+	}
+
+	// A chunk that does not match the manifest fails
+	manifest.Chunks[0].SHA256 = hashBytes([]byte("other"))
+	manifest.File.SHA256 = hashBytes([]byte("other"))
+
+	report, err = qrft.VerifyIntegrity("/decoded", manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if failed := report.Failed(); report.OK() || len(failed) != 1 || !errors.Is(failed[0].Err, ErrHashMismatch) {
+		t.Errorf("VerifyIntegrity() = %s, want chunk 0 to mismatch the manifest", report)
+	}
+}
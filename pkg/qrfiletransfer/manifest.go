@@ -0,0 +1,323 @@
+package qrfiletransfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+// ManifestFileName is the name of the manifest written into a session directory
+const ManifestFileName = "manifest.json"
+
+// ManifestVersion is the version of the manifest format written by this package
+const ManifestVersion = 1
+
+// Manifest is a machine-readable description of a completed archive, written next
+// to the session file once every artifact exists. Unlike the session file, which
+// holds the state needed to resume FileToQRCodes, the manifest is a stable format
+// meant for the join command and third-party tools.
+type Manifest struct {
+	// Version is the manifest format version
+	Version int `json:"manifest_version"`
+	// File describes the archived file
+	File ManifestFile `json:"file"`
+	// ChunkCount is the number of chunks of the file, including the chunks listed
+	// in Dedup
+	ChunkCount int `json:"chunk_count"`
+	// RecoveryLevel is the QR code error correction level: low, medium, high, or highest
+	RecoveryLevel string `json:"recovery_level"`
+	// PayloadFormat names the format chunks are stored in inside the QR codes
+	PayloadFormat string `json:"payload_format"`
+	// PayloadFormatVersion is the version of the payload format, as stored in
+	// binary payload headers
+	PayloadFormatVersion int `json:"payload_format_version"`
+	// Codec names the ChunkCodec of text payloads when they are not base64 encoded
+	Codec string `json:"codec,omitempty"`
+	// Protocol names the protocol whose frames the QR codes hold instead of chunk
+	// payloads, see SetProtocol, and is empty for ProtocolNative
+	Protocol string `json:"protocol,omitempty"`
+	// NextHints is the number of next-up hints embedded in each payload
+	NextHints int `json:"next_hints,omitempty"`
+	// FileID is the ID of the file in a batch embedded in each payload
+	FileID string `json:"file_id,omitempty"`
+	// ContentAddressed is set when the QR codes and data files of the chunks are
+	// named after the hash of their content rather than the chunk name, see
+	// QRFileTransfer.SetContentAddressed
+	ContentAddressed bool `json:"content_addressed,omitempty"`
+	// VolumeCount is the number of volume directories the QR codes are split into,
+	// see QRFileTransfer.SetVolumeSize
+	VolumeCount int `json:"volume_count,omitempty"`
+	// Volume is the number of the volume a partial manifest describes, written
+	// into its volume directory, and 0 for the manifest of the whole archive
+	Volume int `json:"volume,omitempty"`
+	// Chunks lists every chunk in order, but for the chunks listed in Dedup, or
+	// those of the volume of a partial manifest
+	Chunks []ManifestChunk `json:"chunks"`
+	// Dedup lists the chunks duplicating an earlier chunk, which are read from its
+	// QR code, see QRFileTransfer.SetDedup
+	Dedup []ChunkRef `json:"dedup,omitempty"`
+	// Parity lists the Reed-Solomon parity chunks in order, their data is stored in
+	// the parity directory
+	Parity []ManifestChunk `json:"parity,omitempty"`
+	// ChunkSizeReductions lists the reductions of the chunk size made while the
+	// archive was created, in order
+	ChunkSizeReductions []ChunkSizeReduction `json:"chunk_size_reductions,omitempty"`
+}
+
+// ChunkSizeReduction records that a file was split again into more, smaller chunks
+// because a chunk did not fit in a QR code at the chosen settings
+type ChunkSizeReduction struct {
+	// FromChunks is the number of chunks before the reduction
+	FromChunks int `json:"from_chunks"`
+	// ToChunks is the number of chunks after the reduction
+	ToChunks int `json:"to_chunks"`
+	// FromChunkSize is the size in bytes of the largest chunk before the reduction
+	FromChunkSize int64 `json:"from_chunk_size"`
+	// ToChunkSize is the size in bytes of the largest chunk after the reduction
+	ToChunkSize int64 `json:"to_chunk_size"`
+	// Reason describes why the chunk size was reduced
+	Reason string `json:"reason"`
+}
+
+// ManifestFile describes the file of an archive
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// Dir is set when the file is a gzip compressed tar archive of a directory
+	Dir bool `json:"dir,omitempty"`
+}
+
+// ManifestChunk describes a single chunk of an archive
+type ManifestChunk struct {
+	// Index is the position of the chunk in the file
+	Index int `json:"index"`
+	// Name is the chunk name, shared by its QR code and data file unless the
+	// archive is content-addressed
+	Name string `json:"name"`
+	// Size is the size of the chunk data in bytes
+	Size int64 `json:"size"`
+	// SHA256 is the hex encoded SHA-256 of the chunk data
+	SHA256 string `json:"sha256"`
+	// QRVersion is the version (1-40) of the QR code holding the chunk
+	QRVersion int `json:"qr_version"`
+	// RecoveryLevel is the recovery level of the QR code if chunks have their own,
+	// otherwise the recovery level of the manifest applies
+	RecoveryLevel string `json:"recovery_level,omitempty"`
+	// QRCode is the path of the QR code image, relative to the archive directory
+	QRCode string `json:"qr_code"`
+	// Data is the path of the data file, relative to the archive directory
+	Data string `json:"data,omitempty"`
+	// Volume is the number of the volume directory holding the QR code and data
+	// file, 0 if the archive is not split into volumes. The paths of a partial
+	// manifest are relative to the volume directory.
+	Volume int `json:"volume,omitempty"`
+}
+
+// recoveryLevelNames maps recovery levels to their manifest names
+var recoveryLevelNames = map[qrcode.RecoveryLevel]string{
+	qrcode.Low:     "low",
+	qrcode.Medium:  "medium",
+	qrcode.High:    "high",
+	qrcode.Highest: "highest",
+}
+
+// newManifest describes a completed session
+func newManifest(s *Session) *Manifest {
+	format := PayloadFormat(s.Settings.PayloadFormat)
+
+	m := &Manifest{
+		Version: ManifestVersion,
+		File: ManifestFile{
+			Name:   s.File.Name,
+			Size:   s.File.Size,
+			SHA256: s.File.Hash,
+			Dir:    s.File.Dir,
+		},
+		ChunkCount:           len(s.Chunks) + len(s.Refs),
+		RecoveryLevel:        recoveryLevelNames[qrcode.RecoveryLevel(s.Settings.RecoveryLevel)],
+		PayloadFormat:        format.String(),
+		PayloadFormatVersion: payloadFormatVersion(format, s.Settings.NextHints, s.Settings.FileID),
+		Codec:                s.Settings.Codec,
+		Protocol:             s.Settings.Protocol,
+		NextHints:            s.Settings.NextHints,
+		FileID:               s.Settings.FileID,
+		ContentAddressed:     s.Settings.ContentAddressed,
+		VolumeCount:          s.VolumeCount(),
+		Chunks:               make([]ManifestChunk, 0, len(s.Chunks)),
+		Dedup:                s.Refs,
+		ChunkSizeReductions:  s.ChunkSizeReductions,
+	}
+
+	indices := s.chunkIndices()
+
+	for i, c := range s.Chunks {
+		qrName, dataName := s.artifactNames(&c)
+		chunk := ManifestChunk{
+			Index:         indices[i],
+			Name:          c.Name,
+			Size:          c.Size,
+			SHA256:        c.Hash,
+			QRVersion:     c.QRVersion,
+			RecoveryLevel: c.RecoveryLevel,
+			QRCode:        filepath.ToSlash(filepath.Join(s.volumeDir(&c, s.Layout.QRCodes), qrName+ImageFormat(s.Settings.ImageFormat).Ext())),
+			Volume:        c.Volume,
+		}
+
+		if s.Layout.Data != "" {
+			chunk.Data = filepath.ToSlash(filepath.Join(s.volumeDir(&c, s.Layout.Data), dataName+".dat"))
+		}
+
+		m.Chunks = append(m.Chunks, chunk)
+	}
+
+	for i, c := range s.Parity {
+		qrName, dataName := s.artifactNames(&c)
+		m.Parity = append(m.Parity, ManifestChunk{
+			Index:         i,
+			Name:          c.Name,
+			Size:          c.Size,
+			SHA256:        c.Hash,
+			QRVersion:     c.QRVersion,
+			RecoveryLevel: c.RecoveryLevel,
+			QRCode:        filepath.ToSlash(filepath.Join(s.volumeDir(&c, s.Layout.QRCodes), qrName+ImageFormat(s.Settings.ImageFormat).Ext())),
+			Data:          filepath.ToSlash(filepath.Join(s.volumeDir(&c, s.Layout.Parity), dataName+".dat")),
+			Volume:        c.Volume,
+		})
+	}
+
+	return m
+}
+
+// payloadFormatVersion returns the payload layout version written for a format
+func payloadFormatVersion(format PayloadFormat, nextHints int, fileID string) int {
+	if format == PayloadFormatBinary && fileID != "" {
+		return int(binaryPayloadVersionBatch)
+	}
+
+	if format == PayloadFormatBinary && nextHints > 0 {
+		return int(binaryPayloadVersionHints)
+	}
+
+	return int(format)
+}
+
+// volume returns the partial manifest of volume: the chunks and parity chunks it
+// holds, with paths relative to its directory
+func (m *Manifest) volume(volume int) *Manifest {
+	partial := *m
+	partial.Volume = volume
+	partial.Chunks = volumeChunks(m.Chunks, volume)
+	partial.Parity = volumeChunks(m.Parity, volume)
+
+	return &partial
+}
+
+// volumeChunks returns the chunks of volume, with paths relative to its directory
+func volumeChunks(chunks []ManifestChunk, volume int) []ManifestChunk {
+	prefix := volumeDirName(volume) + "/"
+
+	var selected []ManifestChunk
+
+	for _, c := range chunks {
+		if c.Volume != volume {
+			continue
+		}
+
+		c.QRCode = strings.TrimPrefix(c.QRCode, prefix)
+		c.Data = strings.TrimPrefix(c.Data, prefix)
+
+		selected = append(selected, c)
+	}
+
+	return selected
+}
+
+// saveManifests writes the manifest of the completed session s into dir, and the
+// partial manifest of every volume into its volume directory
+func saveManifests(fsys afero.Fs, s *Session, dir string) error {
+	m := newManifest(s)
+	if err := m.save(fsys, dir); err != nil {
+		return err
+	}
+
+	for volume := 1; volume <= m.VolumeCount; volume++ {
+		if err := m.volume(volume).save(fsys, filepath.Join(dir, volumeDirName(volume))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// save writes the manifest into a session directory
+func (m *Manifest) save(fsys afero.Fs, dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, ManifestFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// LoadManifest reads the manifest of an archive directory.
+// It returns an error wrapping os.ErrNotExist if the directory has no manifest.
+func LoadManifest(dir string) (*Manifest, error) {
+	return loadManifest(osFs, dir)
+}
+
+// loadManifest is LoadManifest on the file system fsys
+func loadManifest(fsys afero.Fs, dir string) (*Manifest, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("%w: manifest version %d (newest supported is %d)", ErrUnsupportedVersion, m.Version, ManifestVersion)
+	}
+
+	return &m, nil
+}
+
+// chunk returns the manifest entry of the named chunk, or nil if there is none
+func (m *Manifest) chunk(name string) *ManifestChunk {
+	for i := range m.Chunks {
+		if m.Chunks[i].Name == name {
+			return &m.Chunks[i]
+		}
+	}
+
+	return nil
+}
+
+// verifyChunk checks chunk data against its manifest entry
+func (m *Manifest) verifyChunk(name string, data []byte) error {
+	c := m.chunk(name)
+	if c == nil {
+		return fmt.Errorf("chunk %s is not listed in the manifest", name)
+	}
+
+	if int64(len(data)) != c.Size {
+		return fmt.Errorf("%w: chunk %s has %d bytes, the manifest lists %d", ErrHashMismatch, name, len(data), c.Size)
+	}
+
+	if hashBytes(data) != c.SHA256 {
+		return fmt.Errorf("%w: chunk %s does not match the SHA-256 listed in the manifest", ErrHashMismatch, name)
+	}
+
+	return nil
+}
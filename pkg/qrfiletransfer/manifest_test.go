@@ -0,0 +1,134 @@
+package qrfiletransfer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestFileToQRCodesManifest(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "manifest.txt")
+	testContent := strings.Repeat("Third-party tools read the manifest. ", 100)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if manifest.Version != ManifestVersion || manifest.File.Name != "manifest.txt" ||
+		manifest.File.Size != int64(len(testContent)) || manifest.File.SHA256 != hashBytes([]byte(testContent)) {
+		t.Fatalf("Unexpected manifest file: %+v", manifest.File)
+	}
+
+	if manifest.RecoveryLevel != "medium" || manifest.PayloadFormat != "binary" || manifest.PayloadFormatVersion != 1 {
+		t.Errorf("Unexpected manifest settings: %s %s %d", manifest.RecoveryLevel, manifest.PayloadFormat, manifest.PayloadFormatVersion)
+	}
+
+	if manifest.ChunkCount != len(manifest.Chunks) || manifest.ChunkCount < 2 {
+		t.Fatalf("Unexpected chunk count %d for %d chunks", manifest.ChunkCount, len(manifest.Chunks))
+	}
+
+	for i, c := range manifest.Chunks {
+		data, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(c.Data)))
+		if err != nil {
+			t.Fatalf("Failed to read data file of chunk %d: %v", i, err)
+		}
+
+		if c.Index != i || c.Size != int64(len(data)) || c.SHA256 != hashBytes(data) {
+			t.Errorf("Chunk %d does not match its data file: %+v", i, c)
+		}
+
+		if c.QRVersion < 1 || c.QRVersion > 40 {
+			t.Errorf("Chunk %d has invalid QR version %d", i, c.QRVersion)
+		}
+
+		if _, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(c.QRCode))); err != nil {
+			t.Errorf("QR code of chunk %d not found: %v", i, err)
+		}
+	}
+
+	// A data file that no longer matches the manifest is rejected by join
+	corrupted := filepath.Join(outDir, filepath.FromSlash(manifest.Chunks[1].Data))
+	if err := os.WriteFile(corrupted, []byte("corrupted"), 0600); err != nil {
+		t.Fatalf("Failed to corrupt data file: %v", err)
+	}
+
+	err = qrft.QRCodesToFile(outDir, filepath.Join(testDir, "reconstructed.txt"))
+	if err == nil || !strings.Contains(err.Error(), manifest.Chunks[1].Name) {
+		t.Fatalf("Expected QRCodesToFile to reject the corrupted chunk, got %v", err)
+	}
+}
+
+func TestFileToQRCodesPacksChunks(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Chunks are packed to the capacity of QR codes of the largest version, even
+	// base64 encoded at the highest recovery level
+	testFilePath := filepath.Join(testDir, "dense.txt")
+	testContent := strings.Repeat("Chunks must fit in a QR code. ", 170)[:4999]
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+	qrft.SetPayloadFormat(PayloadFormatText)
+	qrft.SetRecoveryLevel(qrcode.Highest)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if len(manifest.ChunkSizeReductions) != 0 {
+		t.Errorf("Unexpected chunk size reductions: %+v", manifest.ChunkSizeReductions)
+	}
+
+	// One chunk less would not fit
+	limit := qrft.chunkSizeLimit("dense.txt", 4)
+	if want := (len(testContent) + limit - 1) / limit; manifest.ChunkCount != want {
+		t.Errorf("File was split into %d chunks, want %d", manifest.ChunkCount, want)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	for _, c := range session.Chunks {
+		if c.QRVersion < 39 {
+			t.Errorf("Chunk %s has QR code version %d, want it packed close to version 40", c.Name, c.QRVersion)
+		}
+	}
+
+	outputFile := filepath.Join(testDir, "dense_out.txt")
+	if err := qrft.QRCodesToFile(outDir, outputFile); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructed, err := os.ReadFile(outputFile)
+	if err != nil || string(reconstructed) != testContent {
+		t.Errorf("Reconstructed file differs from the original (%v)", err)
+	}
+}
@@ -0,0 +1,98 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+)
+
+// ErrOutputPath is returned for an output path the running OS cannot create
+var ErrOutputPath = errors.New("invalid output path")
+
+// CheckOutputPath returns an error wrapping ErrOutputPath if the running OS cannot
+// create path: an element is rejected by split.CheckFileName, or the absolute path
+// is longer than the OS allows, 260 characters on Windows where most programs still
+// stop at MAX_PATH. Checking the output before decoding avoids failing once every
+// chunk has been read.
+func CheckOutputPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputPath, err)
+	}
+
+	if limit := maxPathLength(runtime.GOOS); len(abs) > limit {
+		return fmt.Errorf("%w: %s is %d bytes long, at most %d", ErrOutputPath, abs, len(abs), limit)
+	}
+
+	for _, element := range strings.Split(strings.TrimPrefix(abs, filepath.VolumeName(abs)), string(filepath.Separator)) {
+		if element == "" {
+			continue
+		}
+
+		if err := split.CheckFileName(element); err != nil {
+			return fmt.Errorf("%w: %w", ErrOutputPath, err)
+		}
+	}
+
+	return nil
+}
+
+// SafeOutputPath returns path, or the path renamed so that the running OS can create
+// it if the file name is rejected by CheckOutputPath: the name is made safe with
+// split.SafeFileName and shortened to fit the longest path. A rename is reported as
+// a diagnostic. It returns an error wrapping ErrOutputPath if the directory of path
+// cannot be created, as directories are not renamed.
+func (q *QRFileTransfer) SafeOutputPath(path string) (string, error) {
+	err := CheckOutputPath(path)
+	if err == nil {
+		return path, nil
+	}
+
+	dir, name := filepath.Split(path)
+	if dir != "" {
+		if dirErr := CheckOutputPath(dir); dirErr != nil {
+			return "", dirErr
+		}
+	}
+
+	safe := split.SafeFileName(name)
+
+	// Shorten the name to fit the longest path
+	if abs, absErr := filepath.Abs(filepath.Join(dir, safe)); absErr == nil {
+		if excess := len(abs) - maxPathLength(runtime.GOOS); excess > 0 && excess < len(safe) {
+			safe = split.ShortenFileName(safe, len(safe)-excess)
+		}
+	}
+
+	renamed := filepath.Join(dir, safe)
+	if err := CheckOutputPath(renamed); err != nil {
+		return "", err
+	}
+
+	// Report why the name was rejected, or else the length of the path
+	reason := split.CheckFileName(name)
+	if reason == nil {
+		reason = err
+	}
+
+	q.diagnostics.Warnf(diagnostics.CodeRenamedOutput, path, "%v, written as %s", reason, renamed)
+
+	return renamed, nil
+}
+
+// maxPathLength returns the length in bytes of the longest absolute path of goos
+func maxPathLength(goos string) int {
+	switch goos {
+	case "windows":
+		return 260
+	case "linux":
+		return 4096
+	default:
+		return 1024
+	}
+}
@@ -0,0 +1,48 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+)
+
+func TestSafeOutputPath(t *testing.T) {
+	dir := t.TempDir()
+
+	qrft := NewQRFileTransfer()
+	collector := diagnostics.NewCollector()
+	qrft.SetDiagnostics(collector)
+
+	valid := filepath.Join(dir, "report.txt")
+	if got, err := qrft.SafeOutputPath(valid); err != nil || got != valid {
+		t.Errorf("SafeOutputPath(%s) = %s, %v", valid, got, err)
+	}
+
+	if len(collector.Diagnostics()) != 0 {
+		t.Errorf("Diagnostics() = %+v, want none", collector.Diagnostics())
+	}
+
+	// A name longer than any file system accepts is shortened
+	long := filepath.Join(dir, strings.Repeat("x", 300)+".txt")
+	if err := CheckOutputPath(long); !errors.Is(err, ErrOutputPath) {
+		t.Fatalf("CheckOutputPath() = %v, want ErrOutputPath", err)
+	}
+
+	got, err := qrft.SafeOutputPath(long)
+	if err != nil || filepath.Dir(got) != dir || len(filepath.Base(got)) > split.MaxNameBytes || filepath.Ext(got) != ".txt" {
+		t.Fatalf("SafeOutputPath() = %s, %v", got, err)
+	}
+
+	if d := collector.Diagnostics(); len(d) != 1 || d[0].Code != diagnostics.CodeRenamedOutput {
+		t.Errorf("Diagnostics() = %+v, want the renamed output", d)
+	}
+
+	// Directories are not renamed
+	if _, err := qrft.SafeOutputPath(filepath.Join(dir, strings.Repeat("d", 300), "report.txt")); !errors.Is(err, ErrOutputPath) {
+		t.Errorf("SafeOutputPath() = %v, want ErrOutputPath", err)
+	}
+}
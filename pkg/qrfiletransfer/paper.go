@@ -0,0 +1,271 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/pdf"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+// PaperBackupFileName is the name of the PDF paper backup written next to the QR
+// codes by split --format pdf
+const PaperBackupFileName = "backup.pdf"
+
+const (
+	// paperHeaderSize is the font size of the page header in points
+	paperHeaderSize = 10
+	// paperCaptionSize is the font size of the caption below each QR code in points
+	paperCaptionSize = 8
+	// paperCellPadding is the space around each QR code of a page in points
+	paperCellPadding = 6
+)
+
+// PaperLayout describes the pages of a paper backup
+type PaperLayout struct {
+	// PageWidth and PageHeight are the size of a page in points
+	PageWidth  float64
+	PageHeight float64
+	// Margin is the blank border around every page in points
+	Margin float64
+	// CodesPerPage is the number of QR codes laid out in a grid on every page
+	CodesPerPage int
+}
+
+// DefaultPaperLayout returns the default paper backup layout: six QR codes per A4
+// page with a half inch margin
+func DefaultPaperLayout() PaperLayout {
+	return PaperLayout{
+		PageWidth:    pdf.A4Width,
+		PageHeight:   pdf.A4Height,
+		Margin:       36,
+		CodesPerPage: 6,
+	}
+}
+
+// paperCode is a QR code placed on a paper backup page
+type paperCode struct {
+	bitmap  [][]bool
+	caption string
+	header  string
+	// file describes the file the chunk belongs to
+	file SessionFile
+	// index and total are the position of the chunk in its file, starting at 0
+	index int
+	total int
+	// hash is the hex encoded SHA-256 of the chunk data
+	hash string
+}
+
+// WritePaperBackup writes a multi-page PDF of the QR codes of the complete session,
+// or of every file of the batch, in dir to w, for archival on paper. The QR codes
+// are drawn as vector shapes in chunk order, CodesPerPage to a page, each with a
+// caption showing its chunk index, e.g. "Chunk 3 of 12". The QR codes are encoded
+// again from the data files with the settings of the session, so they hold the
+// same payloads as its QR code images, whatever their image format.
+func WritePaperBackup(w io.Writer, dir string, layout PaperLayout) error {
+	if layout.CodesPerPage < 1 {
+		return fmt.Errorf("invalid number of QR codes per page %d", layout.CodesPerPage)
+	}
+
+	codes, err := collectPaperCodes(osFs, dir)
+	if err != nil {
+		return err
+	}
+
+	doc := pdf.New(layout.PageWidth, layout.PageHeight)
+	pages := (len(codes) + layout.CodesPerPage - 1) / layout.CodesPerPage
+
+	for p := range pages {
+		end := min(len(codes), (p+1)*layout.CodesPerPage)
+		layout.drawPage(doc.AddPage(), codes[p*layout.CodesPerPage:end], p+1, pages)
+	}
+
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write paper backup: %w", err)
+	}
+
+	return nil
+}
+
+// collectPaperCodes encodes the QR codes of the session, or of every file of the
+// batch, in dir in playback order
+func collectPaperCodes(fsys afero.Fs, dir string) ([]paperCode, error) {
+	dirs := []string{dir}
+	if isBatch(fsys, dir) {
+		ids, err := batchFileIDs(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		dirs = dirs[:0]
+		for _, id := range ids {
+			dirs = append(dirs, filepath.Join(dir, id))
+		}
+	}
+
+	var codes []paperCode
+
+	for _, d := range dirs {
+		sessionCodes, err := paperCodes(fsys, d)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, sessionCodes...)
+	}
+
+	return codes, nil
+}
+
+// paperCodes encodes the QR codes of the chunks of the session in dir
+func paperCodes(fsys afero.Fs, dir string) ([]paperCode, error) {
+	session, err := loadSession(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.Complete {
+		return nil, fmt.Errorf("session in %s is incomplete, re-run split to finish it", dir)
+	}
+
+	if session.DataDir() == "" {
+		return nil, fmt.Errorf("%w: session in %s has no data directory to print", ErrNoRawData, dir)
+	}
+
+	settings := session.Settings
+	format := PayloadFormat(settings.PayloadFormat)
+	level := qrcode.RecoveryLevel(settings.RecoveryLevel)
+	total := len(session.Chunks)
+
+	header := session.File.Name
+	if settings.FileID != "" {
+		header = fmt.Sprintf("File %s: %s", settings.FileID, session.File.Name)
+	}
+
+	codes := make([]paperCode, 0, total)
+	indices := session.chunkIndices()
+
+	for i, chunk := range session.Chunks {
+		data, err := afero.ReadFile(fsys, session.DataFile(chunk.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data of chunk %s: %w", chunk.Name, err)
+		}
+
+		if hashBytes(data) != chunk.Hash {
+			return nil, fmt.Errorf("%w: data of chunk %s does not match its hash", ErrHashMismatch, chunk.Name)
+		}
+
+		content, err := EncodeChunkPayload(format, &ChunkPayload{
+			File:  settings.FileID,
+			Name:  chunk.Name,
+			Data:  data,
+			Next:  nextHints(indices, i, settings.NextHints),
+			Codec: settings.Codec,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode payload for chunk %s: %w", chunk.Name, err)
+		}
+
+		chunkLevel := level
+		if chunk.RecoveryLevel != "" {
+			if chunkLevel, err = parseRecoveryLevel(chunk.RecoveryLevel); err != nil {
+				return nil, fmt.Errorf("invalid recovery level of chunk %s: %w", chunk.Name, err)
+			}
+		}
+
+		qrCode, err := newQRCode(format, content, chunkLevel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create QR code for chunk %s: %w", chunk.Name, err)
+		}
+
+		if chunk.QRVersion != 0 && qrCode.VersionNumber != chunk.QRVersion {
+			return nil, fmt.Errorf("QR code of chunk %s has version %d instead of %d", chunk.Name, qrCode.VersionNumber, chunk.QRVersion)
+		}
+
+		caption := fmt.Sprintf("Chunk %d of %d", i+1, total)
+		if settings.FileID != "" {
+			caption = fmt.Sprintf("File %s, chunk %d of %d", settings.FileID, i+1, total)
+		}
+
+		codes = append(codes, paperCode{
+			bitmap:  qrCode.Bitmap(),
+			caption: caption,
+			header:  header,
+			file:    session.File,
+			index:   i,
+			total:   total,
+			hash:    chunk.Hash,
+		})
+	}
+
+	return codes, nil
+}
+
+// grid returns the number of columns and rows of the QR codes of a page, with at
+// least as many rows as columns for portrait pages
+func (l PaperLayout) grid() (int, int) {
+	cols := max(1, int(math.Sqrt(float64(l.CodesPerPage))))
+	if l.PageWidth > l.PageHeight {
+		cols = (l.CodesPerPage + cols - 1) / cols
+	}
+
+	return cols, (l.CodesPerPage + cols - 1) / cols
+}
+
+// drawPage draws the QR codes of page number of pages
+func (l PaperLayout) drawPage(page *pdf.Page, codes []paperCode, number, pages int) {
+	top := l.PageHeight - l.Margin
+
+	page.Text(l.Margin, top-paperHeaderSize, paperHeaderSize, codes[0].header)
+
+	pageLabel := fmt.Sprintf("Page %d of %d", number, pages)
+	page.Text(l.PageWidth-l.Margin-pdf.TextWidth(pageLabel, paperHeaderSize), top-paperHeaderSize, paperHeaderSize, pageLabel)
+
+	cols, rows := l.grid()
+	gridTop := top - 2*paperHeaderSize
+	cellWidth := (l.PageWidth - 2*l.Margin) / float64(cols)
+	cellHeight := (gridTop - l.Margin) / float64(rows)
+	side := min(cellWidth, cellHeight-2*paperCaptionSize) - 2*paperCellPadding
+
+	for i, code := range codes {
+		cellX := l.Margin + float64(i%cols)*cellWidth
+		cellY := gridTop - float64(i/cols+1)*cellHeight
+
+		// Center the QR code in its cell above the caption
+		x := cellX + (cellWidth-side)/2
+		y := cellY + 2*paperCaptionSize + (cellHeight-2*paperCaptionSize-side)/2
+
+		drawBitmap(page, code.bitmap, x, y, side)
+		page.Text(cellX+(cellWidth-pdf.TextWidth(code.caption, paperCaptionSize))/2, y-paperCaptionSize-2, paperCaptionSize, code.caption)
+	}
+}
+
+// drawBitmap draws a QR code bitmap as a square of side points with its bottom left
+// corner at (x, y), one rectangle per horizontal run of set modules
+func drawBitmap(page *pdf.Page, bitmap [][]bool, x, y, side float64) {
+	var rects [][4]int
+
+	for row, bits := range bitmap {
+		// Rows of the bitmap run top to bottom, rows of the page bottom to top
+		gridRow := len(bitmap) - 1 - row
+
+		for col := 0; col < len(bits); col++ {
+			if !bits[col] {
+				continue
+			}
+
+			start := col
+			for col < len(bits) && bits[col] {
+				col++
+			}
+
+			rects = append(rects, [4]int{start, gridRow, col - start, 1})
+		}
+	}
+
+	page.FillGrid(x, y, side/float64(len(bitmap)), rects)
+}
@@ -0,0 +1,212 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func TestWritePaperBackup(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "paper.txt")
+	if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Archived on paper. ", 400)), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// The paper backup does not depend on the image format of the session
+	qrft := NewQRFileTransfer()
+	qrft.SetImageFormat(ImageFormatSVG)
+	qrft.SetNextHints(1)
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	layout := DefaultPaperLayout()
+	layout.CodesPerPage = 2
+
+	var b bytes.Buffer
+	if err := WritePaperBackup(&b, outDir, layout); err != nil {
+		t.Fatalf("WritePaperBackup failed: %v", err)
+	}
+
+	pages := paperPages(t, b.String())
+	if want := (len(session.Chunks) + 1) / 2; len(pages) != want {
+		t.Fatalf("Expected %d pages, got %d", want, len(pages))
+	}
+
+	// Every code of the backup decodes to its chunk, in chunk order
+	var index int
+
+	for p, page := range pages {
+		if want := fmt.Sprintf("(Page %d of %d)", p+1, len(pages)); !strings.Contains(page, want) {
+			t.Errorf("Page %d has no page number %s", p+1, want)
+		}
+
+		for _, img := range paperCodeImages(page) {
+			chunk := session.Chunks[index]
+
+			if want := fmt.Sprintf("(Chunk %d of %d)", index+1, len(session.Chunks)); !strings.Contains(page, want) {
+				t.Errorf("Page %d has no caption %s", p+1, want)
+			}
+
+			payload := decodePaperCode(t, img)
+
+			data, err := os.ReadFile(session.DataFile(chunk.Name))
+			if err != nil {
+				t.Fatalf("Failed to read data file: %v", err)
+			}
+
+			if payload.Name != chunk.Name || !bytes.Equal(payload.Data, data) {
+				t.Errorf("Code %d does not hold chunk %s", index, chunk.Name)
+			}
+
+			if want := nextHints(session.chunkIndices(), index, 1); fmt.Sprint(payload.Next) != fmt.Sprint(want) {
+				t.Errorf("Code %d has next-up hints %v, want %v", index, payload.Next, want)
+			}
+
+			index++
+		}
+	}
+
+	if index != len(session.Chunks) {
+		t.Errorf("Paper backup holds %d codes, session has %d chunks", index, len(session.Chunks))
+	}
+}
+
+func TestWritePaperBackupCorruptData(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "paper.txt")
+	if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Archived on paper. ", 200)), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "session")
+	if err := NewQRFileTransfer().FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if err := os.WriteFile(session.DataFile(session.Chunks[1].Name), []byte("corrupt"), 0600); err != nil {
+		t.Fatalf("Failed to corrupt data file: %v", err)
+	}
+
+	if err := WritePaperBackup(io.Discard, outDir, DefaultPaperLayout()); err == nil {
+		t.Fatal("Expected an error for a corrupt data file")
+	}
+}
+
+// paperPages returns the decompressed content streams of a PDF document
+func paperPages(t *testing.T, doc string) []string {
+	t.Helper()
+
+	var pages []string
+
+	for _, stream := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllStringSubmatch(doc, -1) {
+		r, err := zlib.NewReader(strings.NewReader(stream[1]))
+		if err != nil {
+			t.Fatalf("Failed to open content stream: %v", err)
+		}
+
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("Failed to decompress content stream: %v", err)
+		}
+
+		pages = append(pages, string(content))
+	}
+
+	return pages
+}
+
+// paperCodeImages rasterizes the QR codes drawn on a page, four pixels per module
+func paperCodeImages(page string) []image.Image {
+	const (
+		scale = 4
+		quiet = 4
+	)
+
+	var images []image.Image
+
+	blocks := regexp.MustCompile(`(?s)q \S+ 0 0 \S+ \S+ \S+ cm\n(.*?)f Q`).FindAllStringSubmatch(page, -1)
+	for _, block := range blocks {
+		var rects [][4]int
+
+		// The drawn bitmap includes the quiet zone around the symbol
+		size := 0
+
+		for _, line := range strings.Split(strings.TrimSpace(block[1]), "\n") {
+			var r [4]int
+			if _, err := fmt.Sscanf(line, "%d %d %d %d re", &r[0], &r[1], &r[2], &r[3]); err == nil {
+				rects = append(rects, r)
+				size = max(size, r[0]+r[2]+quiet, r[1]+r[3]+quiet)
+			}
+		}
+
+		img := image.NewGray(image.Rect(0, 0, size*scale, size*scale))
+		draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+		for _, r := range rects {
+			// Grid rows run bottom to top
+			top := size - r[1] - r[3]
+			rect := image.Rect(r[0]*scale, top*scale, (r[0]+r[2])*scale, (top+r[3])*scale)
+			draw.Draw(img, rect, &image.Uniform{C: color.Black}, image.Point{}, draw.Src)
+		}
+
+		images = append(images, img)
+	}
+
+	return images
+}
+
+// decodePaperCode decodes the chunk payload of a rasterized QR code
+func decodePaperCode(t *testing.T, img image.Image) *ChunkPayload {
+	t.Helper()
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		t.Fatalf("Failed to create binary bitmap: %v", err)
+	}
+
+	// The rasterized codes are pure barcodes, which spares the unreliable search for
+	// finder patterns
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_PURE_BARCODE: true}
+
+	result, err := zxingqrcode.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		t.Fatalf("Failed to decode QR code: %v", err)
+	}
+
+	segments, _ := result.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte)
+
+	payload, err := DecodePayload(bytes.Join(segments, nil))
+	if err != nil {
+		t.Fatalf("DecodePayload failed: %v", err)
+	}
+
+	return payload
+}
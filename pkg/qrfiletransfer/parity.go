@@ -0,0 +1,556 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/erasure"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// Parity chunks let a file be reconstructed when some of its QR codes are lost, see
+// SetParity. The data chunks are dealt round-robin into groups of at most
+// erasure.MaxShards data and parity chunks, so a run of consecutive lost frames is
+// spread over the groups, and every group gets its own Reed-Solomon parity chunks:
+// any of its data chunks lost, up to the number of its parity chunks, can be
+// restored from the chunks that remain.
+//
+// A parity chunk is named like a data chunk with a "p" before its index, e.g.
+// "file_p0002", and holds:
+//
+//	"QPAR" | version | uvarint data chunks | uvarint groups | uvarint group |
+//	uvarint parity chunks of the group | uvarint index in the group | shard | CRC-32
+//
+// The shards are the data chunks of the group, padded to a common size with a 0x80
+// byte followed by zeros, so a restored chunk is cut back to its size.
+
+// parityChunkMagic starts the data of every parity chunk
+const parityChunkMagic = "QPAR"
+
+// parityChunkVersion is the version of the parity chunk layout
+const parityChunkVersion = 1
+
+// parityChunkOverhead is the number of bytes a parity chunk needs beyond the
+// largest data chunk of its group: its header, the padding, the checksum, and the
+// longer chunk name
+const parityChunkOverhead = 32
+
+// parityNamePattern extracts the name of the file and the index of a parity chunk
+// from its name, e.g. "file_p0002"
+var parityNamePattern = regexp.MustCompile(`^(.*)_p(\d{4,})$`)
+
+// parityChunkName returns the name of the parity chunk at index of the total parity
+// chunks of the data chunks named after base
+func parityChunkName(base string, index, total int) string {
+	return fmt.Sprintf("%s_p%0*d", base, split.ChunkIndexWidth(total), index)
+}
+
+// parityChunkCount returns the number of parity chunks for count data chunks at
+// percent parity, rounded up
+func parityChunkCount(count, percent int) int {
+	return (count*percent + 99) / 100
+}
+
+// parityGroups returns the number of parity chunks of every group the data chunks
+// are dealt into at percent parity
+func parityGroups(dataChunks, percent int) []int {
+	groups := (dataChunks + parityChunkCount(dataChunks, percent) + erasure.MaxShards - 1) / erasure.MaxShards
+
+	for ; ; groups++ {
+		plan := make([]int, groups)
+		fits := true
+
+		for g := range plan {
+			size := groupSize(dataChunks, groups, g)
+			plan[g] = max(parityChunkCount(size, percent), 1)
+			fits = fits && size+plan[g] <= erasure.MaxShards
+		}
+
+		if fits {
+			return plan
+		}
+	}
+}
+
+// groupSize returns the number of data chunks dealt into group of groups
+func groupSize(dataChunks, groups, group int) int {
+	return (dataChunks - group + groups - 1) / groups
+}
+
+// parityChunk is a decoded parity chunk
+type parityChunk struct {
+	// dataChunks is the number of data chunks of the file
+	dataChunks int
+	// groups is the number of groups the data chunks are dealt into
+	groups int
+	// group is the group of the parity chunk, it holds the data chunks group,
+	// group+groups, group+2*groups, and so on
+	group int
+	// parityChunks is the number of parity chunks of the group
+	parityChunks int
+	// index is the position of the parity chunk among those of the group
+	index int
+	// shard is the parity shard
+	shard []byte
+}
+
+// marshal encodes the parity chunk
+func (p *parityChunk) marshal() []byte {
+	buf := make([]byte, 0, len(parityChunkMagic)+1+5*binary.MaxVarintLen32+len(p.shard)+crc32.Size)
+	buf = append(buf, parityChunkMagic...)
+	buf = append(buf, parityChunkVersion)
+
+	for _, v := range []int{p.dataChunks, p.groups, p.group, p.parityChunks, p.index} {
+		buf = binary.AppendUvarint(buf, uint64(v))
+	}
+
+	buf = append(buf, p.shard...)
+
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+}
+
+// parseParityChunk decodes the data of a parity chunk
+func parseParityChunk(data []byte) (*parityChunk, error) {
+	if len(data) < len(parityChunkMagic)+1+crc32.Size || !bytes.HasPrefix(data, []byte(parityChunkMagic)) {
+		return nil, errors.New("not a parity chunk")
+	}
+
+	body, sum := data[:len(data)-crc32.Size], data[len(data)-crc32.Size:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, errors.New("parity chunk does not match its checksum")
+	}
+
+	if version := body[len(parityChunkMagic)]; version > parityChunkVersion {
+		return nil, fmt.Errorf("%w: parity chunk version %d (newest supported is %d)", ErrUnsupportedVersion, version, parityChunkVersion)
+	}
+
+	rest := body[len(parityChunkMagic)+1:]
+
+	var fields [5]int
+	for i := range fields {
+		v, n := binary.Uvarint(rest)
+		if n <= 0 || v > erasure.MaxShards*maxParityGroups {
+			return nil, errors.New("truncated parity chunk header")
+		}
+
+		fields[i] = int(v)
+		rest = rest[n:]
+	}
+
+	p := &parityChunk{dataChunks: fields[0], groups: fields[1], group: fields[2], parityChunks: fields[3], index: fields[4], shard: rest}
+
+	if p.groups < 1 || p.group >= p.groups || p.groups > p.dataChunks || p.index >= p.parityChunks ||
+		groupSize(p.dataChunks, p.groups, p.group)+p.parityChunks > erasure.MaxShards {
+		return nil, errors.New("invalid parity chunk header")
+	}
+
+	return p, nil
+}
+
+// maxParityGroups bounds the number of groups read from a parity chunk header
+const maxParityGroups = 1 << 16
+
+// padShard returns data padded to size bytes with 0x80 followed by zeros
+func padShard(data []byte, size int) []byte {
+	shard := make([]byte, size)
+	copy(shard, data)
+	shard[len(data)] = 0x80
+
+	return shard
+}
+
+// unpadShard strips the padding added by padShard
+func unpadShard(shard []byte) ([]byte, error) {
+	end := bytes.LastIndexByte(shard, 0x80)
+	if end < 0 || bytes.IndexFunc(shard[end+1:], func(r rune) bool { return r != 0 }) >= 0 {
+		return nil, errors.New("invalid padding")
+	}
+
+	return shard[:end], nil
+}
+
+// makeParityChunks returns the data of the parity chunks of the data chunks at
+// percent parity, in order
+func makeParityChunks(chunks [][]byte, percent int) ([][]byte, error) {
+	plan := parityGroups(len(chunks), percent)
+
+	var parity [][]byte
+
+	for g, parityChunks := range plan {
+		var shards [][]byte
+
+		size := 0
+		for i := g; i < len(chunks); i += len(plan) {
+			shards = append(shards, chunks[i])
+			size = max(size, len(chunks[i])+1)
+		}
+
+		enc, err := erasure.New(len(shards), parityChunks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parity encoder: %w", err)
+		}
+
+		for i := range shards {
+			shards[i] = padShard(shards[i], size)
+		}
+
+		shards = append(shards, make([][]byte, parityChunks)...)
+		if err := enc.Encode(shards); err != nil {
+			return nil, fmt.Errorf("failed to compute parity: %w", err)
+		}
+
+		for j, shard := range shards[len(shards)-parityChunks:] {
+			p := &parityChunk{dataChunks: len(chunks), groups: len(plan), group: g, parityChunks: parityChunks, index: j, shard: shard}
+			parity = append(parity, p.marshal())
+		}
+	}
+
+	return parity, nil
+}
+
+// writeParityChunks computes the parity chunks of the chunk files at percent parity
+// and writes them to dir, named after the chunks of session. It returns the paths of
+// the parity chunk files in order.
+func (q *QRFileTransfer) writeParityChunks(session *Session, chunkFiles []string, dir string, percent int) ([]string, error) {
+	chunks := make([][]byte, len(chunkFiles))
+
+	for i, chunkPath := range chunkFiles {
+		data, err := afero.ReadFile(q.fs, chunkPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %s: %w", chunkPath, err)
+		}
+
+		chunks[i] = data
+	}
+
+	parity, err := makeParityChunks(chunks, percent)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := q.fs.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create parity directory: %w", err)
+	}
+
+	base := chunkIndexPattern.ReplaceAllString(session.Chunks[0].Name, "")
+	paths := make([]string, len(parity))
+
+	session.Parity = nil
+
+	for i, data := range parity {
+		name := parityChunkName(base, i, len(parity))
+		paths[i] = filepath.Join(dir, name+".part")
+
+		if err := afero.WriteFile(q.fs, paths[i], data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write parity chunk %s: %w", paths[i], err)
+		}
+
+		session.Parity = append(session.Parity, SessionChunk{Name: name, Hash: hashBytes(data), Size: int64(len(data))})
+	}
+
+	return paths, nil
+}
+
+// parityCaption returns the caption of parity chunk index with the hex encoded
+// SHA-256 hash, like chunkCaption with a "P" before the index
+func (q *QRFileTransfer) parityCaption(index int, hash string) string {
+	if q.fileID != "" {
+		return fmt.Sprintf("#%s/P%d %s", q.fileID, index, hash[:checksumCaptionLength])
+	}
+
+	return fmt.Sprintf("#P%d %s", index, hash[:checksumCaptionLength])
+}
+
+// parityFiles returns the data files of the parity chunks of the session by name:
+// those in its parity directory, and those a directory written by read or scan
+// keeps next to the data chunks
+func (s *Session) parityFiles(fsys afero.Fs) (map[string]string, error) {
+	files := make(map[string]string)
+
+	for _, dir := range []string{s.DataDir(), s.ParityDir()} {
+		if dir == "" {
+			continue
+		}
+
+		matches, err := afero.Glob(fsys, filepath.Join(dir, "*_p[0-9][0-9][0-9][0-9]*.dat"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parity chunks: %w", err)
+		}
+
+		for _, path := range matches {
+			if name := strings.TrimSuffix(filepath.Base(path), ".dat"); parityNamePattern.MatchString(name) {
+				files[name] = path
+			}
+		}
+	}
+
+	// Those of a content-addressed session are named after their hash, and those
+	// of a session split into volumes kept in the volume directories
+	if s.Settings.ContentAddressed || s.Settings.VolumeSize > 0 {
+		for _, c := range s.Parity {
+			if path := s.ParityFile(c.Name); path != "" && fileExists(fsys, path) {
+				files[c.Name] = path
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// paritySet holds the parity chunks found for the data chunks of a session
+type paritySet struct {
+	// base is the name the data chunks are named after
+	base string
+	// dataChunks is the number of data chunks of the file
+	dataChunks int
+	// groups is the number of groups the data chunks are dealt into
+	groups int
+	// chunks holds the parity chunks of every group by their index
+	chunks map[int]map[int]*parityChunk
+	// count is the number of parity chunks
+	count int
+}
+
+// loadParity decodes the parity chunks of session, all describing the same data
+// chunks. Damaged parity chunks are skipped. It returns nil if there are none.
+func (q *QRFileTransfer) loadParity(session *Session) (*paritySet, error) {
+	files, err := session.parityFiles(q.fs)
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	var set *paritySet
+
+	for name, path := range files {
+		base := parityNamePattern.FindStringSubmatch(name)[1]
+
+		data, err := afero.ReadFile(q.fs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read parity chunk %s: %w", path, err)
+		}
+
+		p, err := parseParityChunk(data)
+		if err != nil {
+			q.log().Warn("skipping parity chunk", "chunk", name, "error", err)
+
+			continue
+		}
+
+		if set == nil {
+			set = &paritySet{base: base, dataChunks: p.dataChunks, groups: p.groups, chunks: make(map[int]map[int]*parityChunk)}
+		} else if base != set.base || p.dataChunks != set.dataChunks || p.groups != set.groups {
+			return nil, fmt.Errorf("parity chunk %s does not belong to the chunks of %s", name, set.base)
+		}
+
+		if set.chunks[p.group] == nil {
+			set.chunks[p.group] = make(map[int]*parityChunk)
+		}
+
+		set.chunks[p.group][p.index] = p
+		set.count++
+	}
+
+	return set, nil
+}
+
+// chunkName returns the name of data chunk index
+func (p *paritySet) chunkName(index int) string {
+	return fmt.Sprintf("%s_%0*d", p.base, split.ChunkIndexWidth(p.dataChunks), index)
+}
+
+// shards returns the parity shards of group by index, nil for those not found,
+// and the number found. The parity chunks that disagree with the first one of the
+// group on the shard size or count are ignored.
+func (p *paritySet) shards(group int) ([][]byte, int) {
+	var first *parityChunk
+	for _, c := range p.chunks[group] {
+		if first == nil || c.index < first.index {
+			first = c
+		}
+	}
+
+	if first == nil {
+		return nil, 0
+	}
+
+	shards := make([][]byte, first.parityChunks)
+	found := 0
+
+	for _, c := range p.chunks[group] {
+		if c.parityChunks == first.parityChunks && len(c.shard) == len(first.shard) {
+			shards[c.index] = c.shard
+			found++
+		}
+	}
+
+	return shards, found
+}
+
+// restorable returns the chunks of missing, sorted chunk indices, that can be
+// restored: those of the groups that lost no more chunks than parity chunks found
+func (p *paritySet) restorable(missing []int) []int {
+	lost := make(map[int]int)
+	for _, index := range missing {
+		lost[index%p.groups]++
+	}
+
+	var restorable []int
+
+	for _, index := range missing {
+		if _, found := p.shards(index % p.groups); index < p.dataChunks && lost[index%p.groups] <= found {
+			restorable = append(restorable, index)
+		}
+	}
+
+	return restorable
+}
+
+// repairChunks restores the data chunks of session that are missing or do not match
+// the session or manifest from its parity chunks. It returns the data of the
+// restored chunks by name, none if the session has no parity chunks or needs no
+// repair. Restored chunks missing from a legacy session are added to it.
+// An ErrMissingChunk is returned if a group lost more chunks than it has parity.
+func (q *QRFileTransfer) repairChunks(session *Session, manifest *Manifest) (map[string][]byte, error) {
+	parity, err := q.loadParity(session)
+	if err != nil || parity == nil {
+		return nil, err
+	}
+
+	// Collect the data chunks that are intact
+	chunks := make([][]byte, parity.dataChunks)
+	for i := range chunks {
+		chunks[i] = q.intactChunk(session, manifest, parity.chunkName(i))
+	}
+
+	repaired := make(map[string][]byte)
+
+	for g := range parity.groups {
+		var (
+			shards [][]byte
+			lost   []int
+		)
+
+		for i := g; i < len(chunks); i += parity.groups {
+			shards = append(shards, chunks[i])
+
+			if chunks[i] == nil {
+				lost = append(lost, i)
+			}
+		}
+
+		if len(lost) == 0 {
+			continue
+		}
+
+		parityShards, found := parity.shards(g)
+		if found < len(lost) {
+			return nil, fmt.Errorf("%d chunks lost with %d parity chunks to restore them: %w",
+				len(lost), found, &ErrMissingChunk{Index: lost[0]})
+		}
+
+		size := 0
+		for _, shard := range parityShards {
+			size = max(size, len(shard))
+		}
+
+		for i, shard := range shards {
+			if shard == nil {
+				continue
+			}
+
+			if len(shard) >= size {
+				return nil, fmt.Errorf("chunk %s is larger than its parity chunks", parity.chunkName(g+i*parity.groups))
+			}
+
+			shards[i] = padShard(shard, size)
+		}
+
+		enc, err := erasure.New(len(shards), len(parityShards))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create parity decoder: %w", err)
+		}
+
+		shards = append(shards, parityShards...)
+		if err := enc.Reconstruct(shards); err != nil {
+			return nil, err
+		}
+
+		for _, index := range lost {
+			data, err := unpadShard(shards[(index-g)/parity.groups])
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore chunk %s: %w", parity.chunkName(index), err)
+			}
+
+			repaired[parity.chunkName(index)] = data
+		}
+	}
+
+	if len(repaired) == 0 {
+		return nil, nil
+	}
+
+	q.log().Info("restored chunks from parity", "chunks", len(repaired), "parity", parity.count)
+
+	// A legacy session only lists the chunks that were found
+	if len(session.Chunks) != parity.dataChunks {
+		chunkList := make([]SessionChunk, parity.dataChunks)
+
+		for i := range chunkList {
+			name := parity.chunkName(i)
+			if c := session.chunk(name); c != nil {
+				chunkList[i] = *c
+			} else {
+				chunkList[i] = SessionChunk{Name: name, Hash: hashBytes(repaired[name])}
+			}
+		}
+
+		session.Chunks = chunkList
+		session.Settings.NumChunks = parity.dataChunks
+		session.Complete = true
+	}
+
+	return repaired, nil
+}
+
+// intactChunk returns the data of the named chunk of session, or nil if it is
+// missing or does not match the session or manifest
+func (q *QRFileTransfer) intactChunk(session *Session, manifest *Manifest, name string) []byte {
+	path := session.DataFile(name)
+	if path == "" {
+		return nil
+	}
+
+	data, err := afero.ReadFile(q.fs, path)
+	if err != nil {
+		return nil
+	}
+
+	if c := session.chunk(name); c != nil && !session.Legacy && c.Hash != hashBytes(data) {
+		return nil
+	}
+
+	if manifest != nil && manifest.verifyChunk(name, data) != nil {
+		return nil
+	}
+
+	return data
+}
+
+// ParseParity parses a parity setting in percent of the data chunks, such as "10%"
+// or "10", see QRFileTransfer.SetParity
+func ParseParity(s string) (int, error) {
+	percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid parity %q, expected a percentage from 0%% to 100%%", s)
+	}
+
+	return percent, nil
+}
@@ -0,0 +1,203 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/erasure"
+	"github.com/spf13/afero"
+)
+
+func TestParityGroups(t *testing.T) {
+	tests := []struct {
+		dataChunks, percent int
+		want                []int
+	}{
+		{1, 10, []int{1}},
+		{8, 25, []int{2}},
+		{10, 10, []int{1}},
+		{11, 10, []int{2}},
+		{250, 10, []int{13, 13}},
+	}
+
+	for _, tt := range tests {
+		got := parityGroups(tt.dataChunks, tt.percent)
+		if len(got) != len(tt.want) {
+			t.Fatalf("parityGroups(%d, %d) = %v, want %v", tt.dataChunks, tt.percent, got, tt.want)
+		}
+
+		for g := range got {
+			if got[g] != tt.want[g] {
+				t.Errorf("parityGroups(%d, %d) = %v, want %v", tt.dataChunks, tt.percent, got, tt.want)
+			}
+		}
+	}
+
+	// Every group fits in a Reed-Solomon code
+	for _, percent := range []int{1, 10, 50, 100} {
+		plan := parityGroups(3000, percent)
+
+		for g, parity := range plan {
+			if n := groupSize(3000, len(plan), g) + parity; n > erasure.MaxShards {
+				t.Errorf("parityGroups(3000, %d) group %d has %d chunks", percent, g, n)
+			}
+		}
+	}
+}
+
+func TestParityChunkRoundTrip(t *testing.T) {
+	p := &parityChunk{dataChunks: 300, groups: 2, group: 1, parityChunks: 15, index: 3, shard: []byte("parity shard")}
+
+	got, err := parseParityChunk(p.marshal())
+	if err != nil {
+		t.Fatalf("parseParityChunk failed: %v", err)
+	}
+
+	if got.dataChunks != p.dataChunks || got.groups != p.groups || got.group != p.group ||
+		got.parityChunks != p.parityChunks || got.index != p.index || !bytes.Equal(got.shard, p.shard) {
+		t.Errorf("parseParityChunk() = %+v, want %+v", got, p)
+	}
+
+	data := p.marshal()
+	data[len(parityChunkMagic)+3] ^= 0xff
+
+	if _, err := parseParityChunk(data); err == nil {
+		t.Error("parseParityChunk succeeded on a damaged parity chunk")
+	}
+}
+
+// newParitySession splits a test file into a session with parity in fs and
+// returns the file content and the session
+func newParitySession(t *testing.T, fs afero.Fs, percent int) ([]byte, *Session) {
+	t.Helper()
+
+	content := make([]byte, 4000)
+	rand.New(rand.NewSource(7)).Read(content)
+
+	if err := afero.WriteFile(fs, "/in/parity.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetMaxChunkSize(500)
+	qrft.SetParity(percent)
+
+	if err := qrft.FileToQRCodes("/in/parity.bin", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := loadSession(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content, session
+}
+
+func TestFileToQRCodesParity(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content, session := newParitySession(t, fs, 25)
+
+	if len(session.Chunks) != 8 || len(session.Parity) != 2 || session.Settings.Parity != 25 {
+		t.Fatalf("Session has %d chunks and %d parity chunks at %d%%, want 8 and 2 at 25%%",
+			len(session.Chunks), len(session.Parity), session.Settings.Parity)
+	}
+
+	for _, c := range session.Parity {
+		if !fileExists(fs, session.QRCodeFile(c.Name)) || !fileExists(fs, session.ParityFile(c.Name)) || c.QRVersion == 0 {
+			t.Errorf("Parity chunk %s has no QR code or data file", c.Name)
+		}
+	}
+
+	if manifest, err := loadManifest(fs, "/session"); err != nil || len(manifest.Parity) != 2 {
+		t.Fatalf("Manifest lists parity %v (%v), want 2 parity chunks", manifest, err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+
+	// Lose a chunk and damage another, the parity restores both
+	if err := fs.Remove(session.DataFile(session.Chunks[0].Name)); err != nil {
+		t.Fatal(err)
+	}
+
+	damaged := session.DataFile(session.Chunks[5].Name)
+	if err := afero.WriteFile(fs, damaged, []byte("damaged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := qrft.QRCodesToFile("/session", "/out/parity.bin"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, err := afero.ReadFile(fs, "/out/parity.bin"); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Reconstructed file differs from the original (%v)", err)
+	}
+
+	// A third chunk lost is one more than the parity restores
+	if err := fs.Remove(session.DataFile(session.Chunks[7].Name)); err != nil {
+		t.Fatal(err)
+	}
+
+	var missingErr *ErrMissingChunk
+	if err := qrft.QRCodesToFile("/session", "/out/lost.bin"); !errors.As(err, &missingErr) {
+		t.Errorf("QRCodesToFile() error = %v, want ErrMissingChunk", err)
+	}
+}
+
+func TestQRCodesToFileParityReceived(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content, session := newParitySession(t, fs, 25)
+
+	// A directory written by read keeps every decoded chunk in data/, here without
+	// the first chunk and another one
+	copyChunk := func(src, name string) {
+		data, err := afero.ReadFile(fs, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := afero.WriteFile(fs, filepath.Join("/received/data", name+".dat"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, c := range session.Chunks {
+		if i != 0 && i != 3 {
+			copyChunk(session.DataFile(c.Name), c.Name)
+		}
+	}
+
+	for _, c := range session.Parity {
+		copyChunk(session.ParityFile(c.Name), c.Name)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+
+	report, err := qrft.VerifyChunks("/received")
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	if !report.Complete() || report.Total != 8 || len(report.Restorable) != 2 {
+		t.Errorf("VerifyChunks() = %s, want chunks 0 and 3 of 8 restorable", report)
+	}
+
+	// The metadata of the first chunk is restored too
+	if info, err := qrft.ReadFileInfo("/received"); err != nil || info.Name != "parity.bin" {
+		t.Errorf("ReadFileInfo() = %v, %v, want parity.bin", info, err)
+	}
+
+	if err := qrft.QRCodesToFile("/received", "/out/parity.bin"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, err := afero.ReadFile(fs, "/out/parity.bin"); err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Reconstructed file differs from the original (%v)", err)
+	}
+}
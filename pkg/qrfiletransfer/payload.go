@@ -0,0 +1,331 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PayloadFormat identifies how a chunk is stored in the content of a QR code
+type PayloadFormat int
+
+const (
+	// PayloadFormatText stores the chunk as "Chunk: <name>\nData: <base64 data>".
+	// It is the format of archives created before binary payloads existed. A
+	// "\nFile: <id>" line after the name carries the file ID of a chunk in a batch,
+	// and a "\nCodec: <name>" line before the data names the ChunkCodec of data
+	// that is not base64 encoded.
+	PayloadFormatText PayloadFormat = iota
+
+	// PayloadFormatBinary stores the chunk bytes directly in a byte mode QR code,
+	// after a binary header carrying the payload format version and chunk name.
+	PayloadFormatBinary
+)
+
+const (
+	// textPayloadPrefix starts every text payload
+	textPayloadPrefix = "Chunk: "
+
+	// textPayloadFile introduces the file ID of a text payload in a batch
+	textPayloadFile = "\nFile: "
+
+	// textPayloadHints introduces the next-up hints of a text payload
+	textPayloadHints = "\nNext: "
+
+	// textPayloadCodec introduces the name of the chunk codec of a text payload
+	// not base64 encoded
+	textPayloadCodec = "\nCodec: "
+
+	// textPayloadSeparator separates the chunk name from the data in a text payload
+	textPayloadSeparator = "\nData: "
+)
+
+// binaryPayloadMagic starts every binary payload.
+// A binary payload is laid out as:
+//
+//	magic "QFT" | format version (1 byte) | name length (uvarint) | name | chunk data
+//
+// Version 2 adds next-up hints after the name:
+//
+//	... | name | hint count (uvarint) | hinted chunk indices (uvarint each) | chunk data
+//
+// Version 3, written for the files of a batch, adds the file ID before the name and
+// always carries a hint count, which may be 0:
+//
+//	magic "QFT" | 3 | file ID length (uvarint) | file ID | name length (uvarint) | name | hints... | chunk data
+var binaryPayloadMagic = []byte("QFT")
+
+const (
+	// binaryPayloadVersion is the binary payload layout without hints
+	binaryPayloadVersion = byte(PayloadFormatBinary)
+
+	// binaryPayloadVersionHints is the binary payload layout with next-up hints
+	binaryPayloadVersionHints = binaryPayloadVersion + 1
+
+	// binaryPayloadVersionBatch is the binary payload layout with a file ID
+	binaryPayloadVersionBatch = binaryPayloadVersion + 2
+)
+
+// ChunkPayload is the decoded content of a single QR code
+type ChunkPayload struct {
+	// Format is the payload format the chunk was stored in
+	Format PayloadFormat
+	// File is the ID of the file the chunk belongs to when several files are sent
+	// in one batch, see FilesToQRCodes. It is empty for a single file.
+	File string
+	// Name is the chunk name (without extension)
+	Name string
+	// Data is the raw chunk data
+	Data []byte
+	// Next lists the indices of the chunks the sender shows after this one, so a
+	// receiver can notice skipped chunks immediately. It is empty if the sender
+	// did not embed next-up hints.
+	Next []int
+	// Codec is the name of the ChunkCodec the data of a text payload is encoded
+	// with, empty for base64
+	Codec string
+	// Protocol names the protocol of a QR code holding the frame of another
+	// protocol than the native one, whose Data is the frame and Name derived from
+	// the protocol and frame index, see SetProtocol
+	Protocol string
+}
+
+// String returns the name of the payload format
+func (f PayloadFormat) String() string {
+	switch f {
+	case PayloadFormatText:
+		return "text"
+	case PayloadFormatBinary:
+		return "binary"
+	}
+
+	return fmt.Sprintf("PayloadFormat(%d)", int(f))
+}
+
+// EncodePayload returns the QR code content for a chunk in the given format
+func EncodePayload(format PayloadFormat, name string, data []byte) ([]byte, error) {
+	return EncodeChunkPayload(format, &ChunkPayload{Name: name, Data: data})
+}
+
+// EncodeChunkPayload returns the QR code content for a chunk payload in the given
+// format, including its next-up hints if it has any. The data of a text payload
+// is encoded with the built-in codec named by its Codec field.
+func EncodeChunkPayload(format PayloadFormat, p *ChunkPayload) ([]byte, error) {
+	return encodeChunkPayload(format, p, nil)
+}
+
+// encodeChunkPayload returns the QR code content for a chunk payload in the
+// given format, looking its codec up among the built-in codecs and registered
+func encodeChunkPayload(format PayloadFormat, p *ChunkPayload, registered map[string]ChunkCodec) ([]byte, error) {
+	switch format {
+	case PayloadFormatText:
+		content := textPayloadPrefix + p.Name
+
+		if p.File != "" {
+			content += textPayloadFile + p.File
+		}
+
+		if len(p.Next) > 0 {
+			hints := make([]string, len(p.Next))
+			for i, index := range p.Next {
+				hints[i] = strconv.Itoa(index)
+			}
+
+			content += textPayloadHints + strings.Join(hints, ",")
+		}
+
+		codec, err := lookupCodec(registered, p.Codec)
+		if err != nil {
+			return nil, err
+		}
+
+		if name := textCodecName(codec.Name()); name != "" {
+			content += textPayloadCodec + name
+		}
+
+		return []byte(content + textPayloadSeparator + codec.Encode(p.Data)), nil
+	case PayloadFormatBinary:
+		version := binaryPayloadVersion
+		if p.File != "" {
+			version = binaryPayloadVersionBatch
+		} else if len(p.Next) > 0 {
+			version = binaryPayloadVersionHints
+		}
+
+		buf := make([]byte, 0, len(binaryPayloadMagic)+1+binary.MaxVarintLen64*(3+len(p.Next))+len(p.File)+len(p.Name)+len(p.Data))
+		buf = append(buf, binaryPayloadMagic...)
+		buf = append(buf, version)
+
+		if version == binaryPayloadVersionBatch {
+			buf = binary.AppendUvarint(buf, uint64(len(p.File)))
+			buf = append(buf, p.File...)
+		}
+
+		buf = binary.AppendUvarint(buf, uint64(len(p.Name)))
+		buf = append(buf, p.Name...)
+
+		if version != binaryPayloadVersion {
+			buf = binary.AppendUvarint(buf, uint64(len(p.Next)))
+			for _, index := range p.Next {
+				if index < 0 {
+					return nil, fmt.Errorf("invalid next-up hint %d", index)
+				}
+
+				buf = binary.AppendUvarint(buf, uint64(index))
+			}
+		}
+
+		buf = append(buf, p.Data...)
+
+		return buf, nil
+	}
+
+	return nil, fmt.Errorf("unsupported payload format %d", int(format))
+}
+
+// Index returns the chunk index encoded in the payload name
+func (p *ChunkPayload) Index() (int, bool) {
+	m := chunkIndexPattern.FindStringSubmatch(p.Name)
+	if m == nil {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(m[1])
+
+	return index, err == nil
+}
+
+// IsBinaryPayload reports whether content starts like a binary payload
+func IsBinaryPayload(content []byte) bool {
+	return bytes.HasPrefix(content, binaryPayloadMagic)
+}
+
+// DecodePayload parses the content of a QR code produced by EncodePayload.
+// Both payload formats are recognized, so archives created before binary
+// payloads existed remain decodable. Text payloads of the built-in codecs are
+// decoded, see QRFileTransfer.DecodePayload for registered ones, and so are the
+// frames of the built-in protocols, see SetProtocol.
+func DecodePayload(content []byte) (*ChunkPayload, error) {
+	return decodePayload(content, nil, nil)
+}
+
+// decodePayload parses the content of a QR code, decoding text payloads with the
+// built-in codecs and registered, and frames with the built-in framers and registered
+func decodePayload(content []byte, registered map[string]ChunkCodec, framers map[string]Framer) (*ChunkPayload, error) {
+	if IsBinaryPayload(content) {
+		return decodeBinaryPayload(content[len(binaryPayloadMagic):])
+	}
+
+	if bytes.HasPrefix(content, []byte(textPayloadPrefix)) {
+		return decodeTextPayload(string(content[len(textPayloadPrefix):]), registered)
+	}
+
+	if f := detectFramer(content, framers); f != nil {
+		return decodeFramedPayload(f, content)
+	}
+
+	return nil, errors.New("unrecognized chunk payload")
+}
+
+// decodeBinaryPayload parses a binary payload following its magic bytes
+func decodeBinaryPayload(content []byte) (*ChunkPayload, error) {
+	if len(content) == 0 {
+		return nil, errors.New("truncated binary payload")
+	}
+
+	version := content[0]
+	if version < binaryPayloadVersion || version > binaryPayloadVersionBatch {
+		return nil, fmt.Errorf("%w: binary payload version %d", ErrUnsupportedVersion, int(version))
+	}
+
+	rest := content[1:]
+	payload := &ChunkPayload{Format: PayloadFormatBinary}
+
+	if version == binaryPayloadVersionBatch {
+		fileLen, n := binary.Uvarint(rest)
+		if n <= 0 || fileLen > uint64(len(rest)-n) {
+			return nil, errors.New("invalid file ID length in binary payload")
+		}
+
+		payload.File = string(rest[n : n+int(fileLen)])
+		rest = rest[n+int(fileLen):]
+	}
+
+	nameLen, n := binary.Uvarint(rest)
+	if n <= 0 || nameLen > uint64(len(rest)-n) {
+		return nil, errors.New("invalid chunk name length in binary payload")
+	}
+
+	payload.Name = string(rest[n : n+int(nameLen)])
+	rest = rest[n+int(nameLen):]
+
+	if version != binaryPayloadVersion {
+		count, n := binary.Uvarint(rest)
+		if n <= 0 || count > uint64(len(rest)-n) {
+			return nil, errors.New("invalid hint count in binary payload")
+		}
+
+		rest = rest[n:]
+		payload.Next = make([]int, 0, count)
+
+		for range count {
+			index, n := binary.Uvarint(rest)
+			if n <= 0 || index > math.MaxInt32 {
+				return nil, errors.New("invalid next-up hint in binary payload")
+			}
+
+			payload.Next = append(payload.Next, int(index))
+			rest = rest[n:]
+		}
+	}
+
+	payload.Data = rest
+
+	return payload, nil
+}
+
+// decodeTextPayload parses a text payload following its "Chunk: " prefix
+func decodeTextPayload(content string, registered map[string]ChunkCodec) (*ChunkPayload, error) {
+	header, encodedData, found := strings.Cut(content, textPayloadSeparator)
+	if !found {
+		return nil, errors.New("missing data in text payload")
+	}
+
+	header, codecName, _ := strings.Cut(header, textPayloadCodec)
+
+	codec, err := lookupCodec(registered, codecName)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := codec.Decode(encodedData)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &ChunkPayload{
+		Format: PayloadFormatText,
+		Data:   data,
+		Codec:  textCodecName(codec.Name()),
+	}
+
+	header, hints, hasHints := strings.Cut(header, textPayloadHints)
+	payload.Name, payload.File, _ = strings.Cut(header, textPayloadFile)
+
+	if hasHints && hints != "" {
+		for _, hint := range strings.Split(hints, ",") {
+			index, err := strconv.Atoi(hint)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid next-up hint %q in text payload", hint)
+			}
+
+			payload.Next = append(payload.Next, index)
+		}
+	}
+
+	return payload, nil
+}
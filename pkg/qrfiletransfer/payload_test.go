@@ -0,0 +1,156 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	// Binary data including bytes that are not valid UTF-8
+	data := []byte{0x00, 0xff, 0xfe, '0', '1', '2', 0x80, 0x0a}
+
+	for _, format := range []PayloadFormat{PayloadFormatText, PayloadFormatBinary} {
+		content, err := EncodePayload(format, "test_0001", data)
+		if err != nil {
+			t.Fatalf("EncodePayload(%s) failed: %v", format, err)
+		}
+
+		payload, err := DecodePayload(content)
+		if err != nil {
+			t.Fatalf("DecodePayload(%s) failed: %v", format, err)
+		}
+
+		if payload.Format != format || payload.Name != "test_0001" || !bytes.Equal(payload.Data, data) {
+			t.Fatalf("DecodePayload(%s) got %+v", format, payload)
+		}
+	}
+
+	if _, err := DecodePayload([]byte("not a chunk")); err == nil {
+		t.Fatal("DecodePayload accepted an unrecognized payload")
+	}
+}
+
+func TestPayloadIndexWidth(t *testing.T) {
+	tests := map[string]int{"file_0003": 3, "file_12345": 12345, "file_p0002": -1, "file_123": -1}
+
+	for name, want := range tests {
+		index, ok := (&ChunkPayload{Name: name}).Index()
+		if want < 0 && ok {
+			t.Errorf("Index(%s) = %d, want no index", name, index)
+		} else if want >= 0 && (!ok || index != want) {
+			t.Errorf("Index(%s) = %d, %v, want %d", name, index, ok, want)
+		}
+	}
+
+	if name := parityChunkName("file", 3, 10001); name != "file_p00003" {
+		t.Errorf("parityChunkName() = %s, want file_p00003", name)
+	}
+
+	if name := (&paritySet{base: "file", dataChunks: 10001}).chunkName(10000); name != "file_10000" {
+		t.Errorf("chunkName() = %s, want file_10000", name)
+	}
+}
+
+func TestPayloadFileID(t *testing.T) {
+	for _, format := range []PayloadFormat{PayloadFormatText, PayloadFormatBinary} {
+		for _, next := range [][]int{nil, {4, 5}} {
+			content, err := EncodeChunkPayload(format, &ChunkPayload{File: "12", Name: "notes_0003", Data: []byte("batch"), Next: next})
+			if err != nil {
+				t.Fatalf("EncodeChunkPayload(%s) failed: %v", format, err)
+			}
+
+			payload, err := DecodePayload(content)
+			if err != nil {
+				t.Fatalf("DecodePayload(%s) failed: %v", format, err)
+			}
+
+			if payload.File != "12" || payload.Name != "notes_0003" || string(payload.Data) != "batch" || len(payload.Next) != len(next) {
+				t.Fatalf("DecodePayload(%s) got %+v", format, payload)
+			}
+		}
+	}
+}
+
+func TestBinaryPayloadQRCode(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	content, err := EncodePayload(PayloadFormatBinary, "binary_0000", data)
+	if err != nil {
+		t.Fatalf("EncodePayload failed: %v", err)
+	}
+
+	q, err := qrcode.NewBytes(content, qrcode.Medium)
+	if err != nil {
+		t.Fatalf("NewBytes failed: %v", err)
+	}
+
+	// Decode the rendered symbol and take the raw byte segments
+	bmp, err := gozxing.NewBinaryBitmapFromImage(q.Image(-4))
+	if err != nil {
+		t.Fatalf("Failed to create binary bitmap: %v", err)
+	}
+
+	result, err := zxingqrcode.NewQRCodeReader().Decode(bmp, nil)
+	if err != nil {
+		t.Fatalf("Failed to decode QR code: %v", err)
+	}
+
+	segments, ok := result.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte)
+	if !ok || len(segments) != 1 {
+		t.Fatalf("Expected a single byte segment, got %v", segments)
+	}
+
+	payload, err := DecodePayload(segments[0])
+	if err != nil {
+		t.Fatalf("DecodePayload failed: %v", err)
+	}
+
+	if payload.Name != "binary_0000" || !bytes.Equal(payload.Data, data) {
+		t.Fatal("Decoded payload does not match the encoded chunk")
+	}
+}
+
+func TestTextPayloadQRCodeInternationalName(t *testing.T) {
+	for _, name := range []string{"ファイル_0000", "café_0001", "résumé 文件_0002"} {
+		content, err := EncodePayload(PayloadFormatText, name, []byte("data"))
+		if err != nil {
+			t.Fatalf("EncodePayload failed: %v", err)
+		}
+
+		q, err := newQRCode(PayloadFormatText, content, qrcode.Medium)
+		if err != nil {
+			t.Fatalf("newQRCode failed: %v", err)
+		}
+
+		if plain, _ := qrcode.New(string(content), qrcode.Medium); q.VersionNumber > plain.VersionNumber {
+			t.Errorf("QR code of %q has version %d, above %d in byte mode", name, q.VersionNumber, plain.VersionNumber)
+		}
+
+		// A reader without hints decodes the text in the character set of the QR code
+		bmp, err := gozxing.NewBinaryBitmapFromImage(q.Image(-4))
+		if err != nil {
+			t.Fatalf("Failed to create binary bitmap: %v", err)
+		}
+
+		result, err := zxingqrcode.NewQRCodeReader().Decode(bmp, nil)
+		if err != nil {
+			t.Fatalf("Failed to decode QR code: %v", err)
+		}
+
+		payload, err := DecodePayload([]byte(result.GetText()))
+		if err != nil {
+			t.Fatalf("DecodePayload failed: %v", err)
+		}
+
+		if payload.Name != name || string(payload.Data) != "data" {
+			t.Errorf("Decoded payload %q, %q, expected %q", payload.Name, payload.Data, name)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// profilePrefix introduces the name of a built-in profile in a profile spec
+const profilePrefix = "profile:"
+
+// Profile is a set of encoder settings that can be applied to a QRFileTransfer
+type Profile struct {
+	// QRSize is the QR code size in pixels
+	QRSize int
+	// MinQRSize and MaxQRSize bound the size chosen by automatic adjustment
+	MinQRSize int
+	MaxQRSize int
+	// AutoAdjustQRSize enables automatic QR size adjustment based on content
+	AutoAdjustQRSize bool
+	// RecoveryLevel is the QR code error correction level
+	RecoveryLevel qrcode.RecoveryLevel
+	// AutoRecoveryLevel gives every QR code the highest recovery level that fits,
+	// at least RecoveryLevel
+	AutoRecoveryLevel bool
+	// PayloadFormat is the format chunks are stored in
+	PayloadFormat PayloadFormat
+	// Codec is the name of the ChunkCodec of text payloads, empty for base64
+	Codec string
+	// NextHints is the number of next-up hints embedded in each payload
+	NextHints int
+	// ChecksumCaption prints the chunk index and checksum below each QR code
+	ChecksumCaption bool
+	// TextFallback also writes every chunk as a Base45 text file
+	TextFallback bool
+	// Parity is the number of parity chunks in percent of the data chunks
+	Parity int
+}
+
+// profiles lists the built-in profiles by name
+var profiles = map[string]Profile{
+	// default matches the settings of NewQRFileTransfer
+	"default": {
+		QRSize:           800,
+		MinQRSize:        800,
+		MaxQRSize:        1600,
+		AutoAdjustQRSize: true,
+		RecoveryLevel:    qrcode.Medium,
+		PayloadFormat:    PayloadFormatBinary,
+	},
+	// screen is meant for playback on a screen filmed by a camera, where codes
+	// scroll by and skipped ones must be noticed
+	"screen": {
+		QRSize:           800,
+		MinQRSize:        800,
+		MaxQRSize:        1600,
+		AutoAdjustQRSize: true,
+		RecoveryLevel:    qrcode.Medium,
+		PayloadFormat:    PayloadFormatBinary,
+		NextHints:        2,
+	},
+	// print-archive produces large codes with the highest error correction that
+	// survive printing, scanning, and aging paper
+	"print-archive": {
+		QRSize:           1600,
+		MinQRSize:        1600,
+		MaxQRSize:        1600,
+		AutoAdjustQRSize: false,
+		RecoveryLevel:    qrcode.Highest,
+		PayloadFormat:    PayloadFormatBinary,
+		ChecksumCaption:  true,
+	},
+	// compact favors fewer, denser codes for clean digital transfers
+	"compact": {
+		QRSize:           400,
+		MinQRSize:        400,
+		MaxQRSize:        1200,
+		AutoAdjustQRSize: true,
+		RecoveryLevel:    qrcode.Low,
+		PayloadFormat:    PayloadFormatBinary,
+	},
+}
+
+// LookupProfile returns the built-in profile with the given name
+func LookupProfile(name string) (Profile, bool) {
+	p, ok := profiles[name]
+
+	return p, ok
+}
+
+// ProfileNames returns the names of the built-in profiles in alphabetical order
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ProfileFromSettings returns the profile of the encoder settings of a session
+func ProfileFromSettings(s SessionSettings) Profile {
+	return Profile{
+		QRSize:            s.QRSize,
+		MinQRSize:         s.MinQRSize,
+		MaxQRSize:         s.MaxQRSize,
+		AutoAdjustQRSize:  s.AutoAdjustQRSize,
+		RecoveryLevel:     qrcode.RecoveryLevel(s.RecoveryLevel),
+		AutoRecoveryLevel: s.AutoRecoveryLevel,
+		PayloadFormat:     PayloadFormat(s.PayloadFormat),
+		Codec:             s.Codec,
+		NextHints:         s.NextHints,
+		ChecksumCaption:   s.ChecksumCaption,
+		TextFallback:      s.TextFallback,
+		Parity:            s.Parity,
+	}
+}
+
+// ParseProfile applies a profile spec to base and returns the result.
+// A spec is a comma separated list of items, each either "profile:<name>", which
+// replaces every setting with those of a built-in profile, or "<key>=<value>",
+// which overrides one setting. Items are applied in order, e.g.
+// "profile:print-archive,payload=text". The keys are size, min-size, max-size,
+// auto-adjust, recovery (low, medium, high, highest), auto-recovery, payload
+// (binary, text), codec (base64, base45, raw), next-hints, caption, text, and
+// parity (a percentage).
+func ParseProfile(spec string, base Profile) (Profile, error) {
+	p := base
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(item, profilePrefix); ok {
+			named, found := LookupProfile(name)
+			if !found {
+				return Profile{}, fmt.Errorf("unknown profile %q (expected one of %s)", name, strings.Join(ProfileNames(), ", "))
+			}
+
+			p = named
+
+			continue
+		}
+
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return Profile{}, fmt.Errorf("invalid profile setting %q (expected profile:<name> or <key>=<value>)", item)
+		}
+
+		if err := p.set(strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+			return Profile{}, err
+		}
+	}
+
+	return p, nil
+}
+
+// set overrides the setting named key
+func (p *Profile) set(key, value string) error {
+	var err error
+
+	switch key {
+	case "size":
+		p.QRSize, err = parsePositive(key, value)
+	case "min-size":
+		p.MinQRSize, err = parsePositive(key, value)
+	case "max-size":
+		p.MaxQRSize, err = parsePositive(key, value)
+	case "auto-adjust":
+		p.AutoAdjustQRSize, err = strconv.ParseBool(value)
+		if err != nil {
+			err = fmt.Errorf("invalid auto-adjust value %q: %w", value, err)
+		}
+	case "recovery":
+		p.RecoveryLevel, err = parseRecoveryLevel(value)
+	case "auto-recovery":
+		p.AutoRecoveryLevel, err = strconv.ParseBool(value)
+		if err != nil {
+			err = fmt.Errorf("invalid auto-recovery value %q: %w", value, err)
+		}
+	case "payload":
+		p.PayloadFormat, err = parsePayloadFormat(value)
+		p.Codec = ""
+	case "codec":
+		err = p.setCodec(value)
+	case "caption":
+		p.ChecksumCaption, err = strconv.ParseBool(value)
+		if err != nil {
+			err = fmt.Errorf("invalid caption value %q: %w", value, err)
+		}
+	case "text":
+		p.TextFallback, err = strconv.ParseBool(value)
+		if err != nil {
+			err = fmt.Errorf("invalid text value %q: %w", value, err)
+		}
+	case "parity":
+		p.Parity, err = ParseParity(value)
+	case "next-hints":
+		p.NextHints, err = strconv.Atoi(value)
+		if err != nil || p.NextHints < 0 {
+			err = fmt.Errorf("invalid next-hints value %q", value)
+		}
+	default:
+		err = fmt.Errorf("unknown profile setting %q", key)
+	}
+
+	return err
+}
+
+// ApplyProfile sets the encoder settings of a profile
+func (q *QRFileTransfer) ApplyProfile(p Profile) {
+	q.SetQRSize(p.QRSize)
+	q.SetMinQRSize(p.MinQRSize)
+	q.SetMaxQRSize(p.MaxQRSize)
+	q.SetAutoAdjustQRSize(p.AutoAdjustQRSize)
+	q.SetRecoveryLevel(p.RecoveryLevel)
+	q.SetAutoRecoveryLevel(p.AutoRecoveryLevel)
+	q.SetPayloadFormat(p.PayloadFormat)
+	q.codec = p.Codec
+	q.SetNextHints(p.NextHints)
+	q.SetChecksumCaption(p.ChecksumCaption)
+	q.SetTextFallback(p.TextFallback)
+	q.SetParity(p.Parity)
+}
+
+// setCodec selects the built-in chunk codec name and its payload format
+func (p *Profile) setCodec(name string) error {
+	if _, err := lookupCodec(nil, name); err != nil {
+		return err
+	}
+
+	p.PayloadFormat, p.Codec = PayloadFormatText, textCodecName(name)
+	if name == CodecRaw {
+		p.PayloadFormat, p.Codec = PayloadFormatBinary, ""
+	}
+
+	return nil
+}
+
+// parsePositive parses the positive integer value of a setting
+func parsePositive(key, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q (expected a positive integer)", key, value)
+	}
+
+	return n, nil
+}
+
+// parseRecoveryLevel parses the name of a recovery level, see recoveryLevelNames
+func parseRecoveryLevel(name string) (qrcode.RecoveryLevel, error) {
+	for level, levelName := range recoveryLevelNames {
+		if levelName == name {
+			return level, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown recovery level %q (expected low, medium, high, or highest)", name)
+}
+
+// parsePayloadFormat parses the name of a payload format
+func parsePayloadFormat(name string) (PayloadFormat, error) {
+	for _, format := range []PayloadFormat{PayloadFormatBinary, PayloadFormatText} {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown payload format %q (expected binary or text)", name)
+}
@@ -0,0 +1,69 @@
+package qrfiletransfer
+
+import (
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+func TestParseProfile(t *testing.T) {
+	base, _ := LookupProfile("default")
+
+	tests := []struct {
+		name string
+		spec string
+		want Profile
+	}{
+		{"empty keeps base", "", base},
+		{"named profile", "profile:print-archive", profiles["print-archive"]},
+		{"override base", "recovery=high, next-hints=3", func() Profile {
+			p := base
+			p.RecoveryLevel = qrcode.High
+			p.NextHints = 3
+
+			return p
+		}()},
+		{"override named profile", "profile:print-archive,payload=text,auto-adjust=true", func() Profile {
+			p := profiles["print-archive"]
+			p.PayloadFormat = PayloadFormatText
+			p.AutoAdjustQRSize = true
+
+			return p
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProfile(tt.spec, base)
+			if err != nil {
+				t.Fatalf("ParseProfile(%q) failed: %v", tt.spec, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseProfile(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+
+	for _, spec := range []string{"profile:unknown", "size", "size=-1", "recovery=max", "payload=json", "color=red"} {
+		if _, err := ParseProfile(spec, base); err == nil {
+			t.Errorf("ParseProfile(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestProfileFromSettings(t *testing.T) {
+	q := NewQRFileTransfer()
+
+	p := profiles["print-archive"]
+	q.ApplyProfile(p)
+
+	if got := ProfileFromSettings(q.sessionSettings(2)); got != p {
+		t.Errorf("ProfileFromSettings() = %+v, want %+v", got, p)
+	}
+
+	// The default profile matches a new instance
+	if got := ProfileFromSettings(NewQRFileTransfer().sessionSettings(2)); got != profiles["default"] {
+		t.Errorf("default profile %+v does not match NewQRFileTransfer %+v", profiles["default"], got)
+	}
+}
@@ -0,0 +1,647 @@
+package qrfiletransfer
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/dyammarcano/qrfiletransfer/pkg/ur"
+	"github.com/spf13/afero"
+)
+
+// Framer frames a whole file into the QR codes of an air-gap transfer protocol of
+// other tools, instead of the chunk payloads of this package, so their senders
+// and receivers interoperate. Downstream projects register their own framers
+// with QRFileTransfer.RegisterFramer.
+type Framer interface {
+	// Name identifies the protocol, it must not be empty nor contain white space
+	Name() string
+	// Format is the payload format the frames are held in: PayloadFormatText for
+	// frames of upper case letters and digits held in alphanumeric mode,
+	// PayloadFormatBinary for any other frames, held in byte mode
+	Format() PayloadFormat
+	// NewFrameEncoder returns an encoder cutting data into frames holding at most
+	// fragmentLen bytes of it each
+	NewFrameEncoder(data []byte, fragmentLen int) (FrameEncoder, error)
+	// Index returns the index of the frame, unique among the frames of the same
+	// data, or an error if content is not a frame of the protocol
+	Index(content []byte) (int, error)
+	// NewDeframer returns a deframer reassembling data from its frames
+	NewDeframer() Deframer
+}
+
+// FrameEncoder writes data as the frames of a protocol
+type FrameEncoder interface {
+	// Fragments returns the number of frames that hold the data once
+	Fragments() int
+	// Frame returns the frame with sequence number seqNum, from 1. The frames after
+	// Fragments are redundant: fountain coded frames of protocols with a rateless
+	// code, repetitions of the first frames of others.
+	Frame(seqNum int) []byte
+}
+
+// Deframer reassembles data from the frames of a protocol, received in any order
+type Deframer interface {
+	// Receive adds a frame, frames already received are ignored
+	Receive(content []byte) error
+	// Fragments returns the number of fragments of the data and the indices of
+	// those received or solved so far, the total is 0 until it is known
+	Fragments() (int, []int)
+	// Result returns the reassembled data, or false while it is incomplete
+	Result() ([]byte, bool, error)
+}
+
+// Names of the built-in protocols
+const (
+	// ProtocolNative stores the chunks in the payloads of this package, see
+	// PayloadFormat, the default
+	ProtocolNative = "native"
+	// ProtocolUR frames the file as a BC-UR of type bytes (BCR-2020-005), the
+	// animated QR code format of wallet and scanner apps: the QR codes hold the
+	// fountain coded parts of the file, "UR:BYTES/<seq>-<n>/<bytewords>", and
+	// redundant frames mix several fragments
+	ProtocolUR = "ur"
+	// ProtocolTXQR frames the file like txqr and the air-gap tools built on it:
+	// every QR code holds "<offset>/<total>|" followed by the bytes of the file
+	// from offset, total being its size
+	ProtocolTXQR = "txqr"
+	// ProtocolStructuredAppend links up to 16 QR codes holding the bytes of the
+	// file by the structured append of the QR code standard, which scanner apps
+	// supporting it join into the file: every QR code holds its index, the number
+	// of QR codes, and the parity of the file in its structured append header,
+	// followed by its part of the file in byte mode
+	ProtocolStructuredAppend = "structured-append"
+)
+
+// ErrUnknownProtocol is returned for a protocol that is neither built in nor registered
+var ErrUnknownProtocol = errors.New("unknown protocol")
+
+// builtinFramers lists the framers of the built-in protocols by name
+var builtinFramers = map[string]Framer{
+	ProtocolUR:               urFramer{},
+	ProtocolTXQR:             txqrFramer{},
+	ProtocolStructuredAppend: structuredAppendFramer{},
+}
+
+// ProtocolNames returns the names of the built-in protocols in alphabetical order
+func ProtocolNames() []string {
+	names := []string{ProtocolNative}
+	for name := range builtinFramers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupFramer returns the framer of the protocol named name among the built-in
+// framers and registered, nil for the native protocol
+func lookupFramer(registered map[string]Framer, name string) (Framer, error) {
+	if name == "" || name == ProtocolNative {
+		return nil, nil
+	}
+
+	if f, ok := builtinFramers[name]; ok {
+		return f, nil
+	}
+
+	if f, ok := registered[name]; ok {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("%w %q (expected one of %s, or a registered protocol)", ErrUnknownProtocol, name, strings.Join(ProtocolNames(), ", "))
+}
+
+// detectFramer returns the framer of the protocol content is a frame of, among
+// the built-in framers and registered in name order, or nil
+func detectFramer(content []byte, registered map[string]Framer) Framer {
+	for _, framers := range []map[string]Framer{builtinFramers, registered} {
+		names := make([]string, 0, len(framers))
+		for name := range framers {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		for _, name := range names {
+			if _, err := framers[name].Index(content); err == nil {
+				return framers[name]
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisterFramer makes the framer of a protocol available to SetProtocol and
+// DecodePayload. Its name must not be empty, contain white space, or be taken by
+// a built-in or previously registered protocol.
+func (q *QRFileTransfer) RegisterFramer(f Framer) error {
+	name := f.Name()
+	if name == "" || strings.ContainsFunc(name, unicode.IsSpace) {
+		return fmt.Errorf("invalid protocol name %q", name)
+	}
+
+	if _, err := lookupFramer(q.framers, name); err == nil || name == ProtocolNative {
+		return fmt.Errorf("protocol %q is already registered", name)
+	}
+
+	if q.framers == nil {
+		q.framers = make(map[string]Framer)
+	}
+
+	q.framers[name] = f
+
+	return nil
+}
+
+// SetProtocol sets the protocol the QR codes written by FileToQRCodes follow by
+// name, see ProtocolNames and RegisterFramer. ProtocolNative is the default. The
+// other protocols frame the whole file, so only carry single files, not
+// directories or batches, nor its name, and they ignore the payload format,
+// next-up hints, and text fallback; SetParity adds redundant frames instead of
+// parity chunks. QRCodesToFile, VerifyChunks, and DecodePayload recognize the
+// frames of the built-in protocols whichever protocol is set. An error wrapping
+// ErrUnknownProtocol is returned for an unknown name.
+func (q *QRFileTransfer) SetProtocol(name string) error {
+	f, err := lookupFramer(q.framers, name)
+	if err != nil {
+		return err
+	}
+
+	q.framer = f
+
+	return nil
+}
+
+// protocolName returns the name of the protocol recorded in the session, empty
+// for the native protocol
+func (q *QRFileTransfer) protocolName() string {
+	if q.framer == nil {
+		return ""
+	}
+
+	return q.framer.Name()
+}
+
+// isFramed reports whether the session holds the frames of a protocol other
+// than the native one
+func (s *Session) isFramed() bool {
+	return s.Settings.Protocol != ""
+}
+
+// contentFormat returns the payload format the content of a QR code is encoded in
+func (q *QRFileTransfer) contentFormat() PayloadFormat {
+	if q.framer != nil {
+		return q.framer.Format()
+	}
+
+	return q.payloadFormat
+}
+
+// frameChunkName returns the name of the chunk holding the frame with sequence number
+// seqNum of frames frames
+func frameChunkName(base string, seqNum, frames int) string {
+	return fmt.Sprintf("%s_%0*d", base, split.ChunkIndexWidth(frames), seqNum-1)
+}
+
+// encodeFrames writes the file as the frames of the protocol of q into tempDir,
+// plans them in session, and generates their QR codes and data files in workDir
+func (q *QRFileTransfer) encodeFrames(file afero.File, workDir, tempDir string, session *Session) error {
+	if q.fileID != "" {
+		return fmt.Errorf("the %s protocol carries a single file, not a batch", q.framer.Name())
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	encoder, err := q.framer.NewFrameEncoder(data, q.fragmentLen(data))
+	if err != nil {
+		return fmt.Errorf("failed to frame file: %w", err)
+	}
+
+	if err := q.fs.RemoveAll(tempDir); err != nil {
+		return fmt.Errorf("failed to clean up temporary directory: %w", err)
+	}
+
+	if err := q.fs.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	// Plan the session: every frame with the hash of its content
+	base := strings.TrimSuffix(session.File.Name, filepath.Ext(session.File.Name))
+	frames := q.frameCount(encoder.Fragments())
+	framePaths := make([]string, 0, frames)
+
+	session.Settings = q.sessionSettings(encoder.Fragments())
+	session.Chunks = nil
+	session.Parity = nil
+
+	for seqNum := 1; seqNum <= frames; seqNum++ {
+		name := frameChunkName(base, seqNum, frames)
+		content := encoder.Frame(seqNum)
+
+		framePath := filepath.Join(tempDir, name+".part")
+		if err := afero.WriteFile(q.fs, framePath, content, 0600); err != nil {
+			return fmt.Errorf("failed to write frame %s: %w", framePath, err)
+		}
+
+		framePaths = append(framePaths, framePath)
+		chunk := SessionChunk{Name: name, Hash: hashBytes(content), Size: int64(len(content))}
+
+		// The QR code of a frame holds the frame itself
+		if session.Settings.ContentAddressed {
+			chunk.QRHash = chunk.Hash
+		}
+
+		session.Chunks = append(session.Chunks, chunk)
+	}
+
+	session.assignVolumes()
+
+	// Resume a previous run for the same input and settings, otherwise start over
+	previous := loadPreviousSession(q.fs, workDir)
+	resume := previous != nil && previous.matches(session.File.Hash, session.Settings)
+
+	if previous != nil && !resume {
+		if err := removeStaleArtifacts(q.fs, previous, session, workDir); err != nil {
+			return err
+		}
+	}
+
+	if err := session.makeVolumeDirs(q.fs, workDir); err != nil {
+		return err
+	}
+
+	if err := session.save(q.fs, workDir); err != nil {
+		return err
+	}
+
+	var jobs []chunkJob
+
+	for i, framePath := range framePaths {
+		chunk := &session.Chunks[i]
+		qrName, dataName := session.artifactNames(chunk)
+		job := chunkJob{
+			chunkPath:     framePath,
+			name:          chunk.Name,
+			qrFilePath:    filepath.Join(workDir, session.volumeDir(chunk, session.Layout.QRCodes), qrName+q.imageFormat.Ext()),
+			dataFilePath:  filepath.Join(workDir, session.volumeDir(chunk, session.Layout.Data), dataName+".dat"),
+			qrVersion:     &chunk.QRVersion,
+			recoveryLevel: &chunk.RecoveryLevel,
+			verbatim:      true,
+		}
+
+		if q.checksumCaption {
+			job.caption = q.chunkCaption(i, chunk.Hash)
+		}
+
+		if resume && q.reuseChunk(job, chunk, previous.chunk(job.name)) {
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if err := q.encodeChunks(jobs); err != nil {
+		// Record the QR codes of the chunks encoded before the operation was
+		// canceled, so that the next run resumes from them
+		if q.context().Err() != nil {
+			_ = session.save(q.fs, workDir)
+		}
+
+		return err
+	}
+
+	q.log().Info("split file into frames", "file", session.File.Name, "protocol", q.framer.Name(), "fragments", encoder.Fragments(), "frames", frames, "dir", workDir)
+
+	return nil
+}
+
+// frameCount returns the number of frames written of a file of fragments: one
+// frame per fragment and, with SetParity, the percentage of redundant frames
+func (q *QRFileTransfer) frameCount(fragments int) int {
+	if q.parity == 0 {
+		return fragments
+	}
+
+	return fragments + parityChunkCount(fragments, q.parity)
+}
+
+// fragmentLen returns the length of the fragments of data whose frames fit in a
+// QR code of the version of SetTargetQRVersion, or of the largest version, capped
+// by SetMaxChunkSize
+func (q *QRFileTransfer) fragmentLen(data []byte) int {
+	version := q.targetQRVersion
+	if version == 0 {
+		version = maxQRVersion
+	}
+
+	fits := func(fragmentLen int) bool {
+		// Frames that cannot be encoded at all, such as too many structured append
+		// frames, fail in encodeFrames with the longest fragments instead
+		encoder, err := q.framer.NewFrameEncoder(data, fragmentLen)
+		if err != nil {
+			return true
+		}
+
+		// The frames of the last fragments hold the largest offsets or sequence
+		// numbers, and the last frame the largest sequence number
+		n := encoder.Fragments()
+		for _, seqNum := range []int{1, n - 1, n, q.frameCount(n)} {
+			if seqNum < 1 {
+				continue
+			}
+
+			code, err := newQRCode(q.framer.Format(), encoder.Frame(seqNum), q.recoveryLevel)
+			if err != nil || code.VersionNumber > version {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	limit := sort.Search(qrcode.MaxBytes(version, q.recoveryLevel)+1, func(n int) bool { return n > 0 && !fits(n) }) - 1
+	if q.maxChunkSize > 0 && q.maxChunkSize < limit {
+		return q.maxChunkSize
+	}
+
+	return max(limit, 1)
+}
+
+// sessionFramer returns the framer of the protocol of the session, nil for the
+// native protocol. The data files of a directory written by read or scan are
+// checked for the frames of a registered protocol.
+func (q *QRFileTransfer) sessionFramer(session *Session) (Framer, error) {
+	if session.isFramed() {
+		return lookupFramer(q.framers, session.Settings.Protocol)
+	}
+
+	if !session.Legacy || len(q.framers) == 0 || len(session.Chunks) == 0 {
+		return nil, nil
+	}
+
+	data, err := afero.ReadFile(q.fs, session.DataFile(session.Chunks[0].Name))
+	if err != nil {
+		return nil, nil
+	}
+
+	if f := detectFramer(data, q.framers); f != nil {
+		session.Settings.Protocol = f.Name()
+
+		return f, nil
+	}
+
+	return nil, nil
+}
+
+// deframe feeds the data files of the session, the frames of the protocol of f,
+// into a deframer. Files that hold no valid frame are reported and skipped.
+func (q *QRFileTransfer) deframe(f Framer, session *Session) (Deframer, error) {
+	deframer := f.NewDeframer()
+
+	for _, path := range session.chunkDataFiles(q.fs) {
+		data, err := afero.ReadFile(q.fs, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data file %s: %w", path, err)
+		}
+
+		if err := deframer.Receive(data); err != nil {
+			q.diagnostics.Warnf(diagnostics.CodeInvalidChunk, path, "invalid %s frame: %v", f.Name(), err)
+		}
+	}
+
+	return deframer, nil
+}
+
+// verifyFrames reports the fragments of the file of the session that are
+// received or solved from the frames present
+func (q *QRFileTransfer) verifyFrames(f Framer, session *Session) (*ChunkReport, error) {
+	deframer, err := q.deframe(f, session)
+	if err != nil {
+		return nil, err
+	}
+
+	total, present := receivedFragments(deframer, session)
+	report := &ChunkReport{Total: total}
+
+	for i := range total {
+		if present[i] {
+			report.Present = append(report.Present, i)
+		} else {
+			report.Missing = append(report.Missing, i)
+		}
+	}
+
+	return report, nil
+}
+
+// receivedFragments returns the number of fragments of the deframer, or of the
+// session while it is unknown, and the fragments received or solved
+func receivedFragments(deframer Deframer, session *Session) (int, map[int]bool) {
+	total, solved := deframer.Fragments()
+	if total == 0 {
+		total = session.Settings.NumChunks
+	}
+
+	present := make(map[int]bool, len(solved))
+	for _, i := range solved {
+		present[i] = true
+	}
+
+	return total, present
+}
+
+// verifyFramesIntegrity checks the frames of the session like VerifyIntegrity: a
+// fragment neither received nor solved fails as missing, and the reassembled file
+// is checked against the SHA-256 of the session and manifest
+func (q *QRFileTransfer) verifyFramesIntegrity(f Framer, session *Session, manifest *Manifest) (*IntegrityReport, error) {
+	deframer, err := q.deframe(f, session)
+	if err != nil {
+		return nil, err
+	}
+
+	total, present := receivedFragments(deframer, session)
+	report := &IntegrityReport{}
+
+	for i := range total {
+		c := ChunkIntegrity{Index: i}
+		if !present[i] {
+			c.Err = &ErrMissingChunk{Index: i}
+		}
+
+		report.Chunks = append(report.Chunks, c)
+	}
+
+	data, complete, err := deframer.Result()
+
+	switch {
+	case err != nil:
+		report.Err = fmt.Errorf("failed to reassemble %s frames: %w", f.Name(), err)
+
+		return report, nil
+	case !complete:
+		if len(present) == 0 {
+			report.Err = fmt.Errorf("no %s frame found", f.Name())
+		}
+
+		return report, nil
+	}
+
+	// The frames carry no file name, nor does a directory they were decoded into
+	name := session.File.Name
+	if name == "" && manifest != nil {
+		name = manifest.File.Name
+	}
+
+	report.File = &FileInfo{Name: name, Size: int64(len(data)), Total: total, Hash: sha256.Sum256(data)}
+
+	hash := hashBytes(data)
+	if session.File.Hash != "" && session.File.Hash != hash {
+		report.Err = fmt.Errorf("%w: file does not match the SHA-256 of the session", ErrHashMismatch)
+	} else if manifest != nil && manifest.File.SHA256 != hash {
+		report.Err = fmt.Errorf("%w: file does not match the SHA-256 of the manifest", ErrHashMismatch)
+	}
+
+	return report, nil
+}
+
+// framesToFile reassembles the file carried by the frames of the session into outFilePath
+func (q *QRFileTransfer) framesToFile(f Framer, session *Session, outFilePath string) error {
+	deframer, err := q.deframe(f, session)
+	if err != nil {
+		return err
+	}
+
+	data, complete, err := deframer.Result()
+	if err != nil {
+		return fmt.Errorf("failed to reassemble %s frames: %w", f.Name(), err)
+	}
+
+	if !complete {
+		total, present := receivedFragments(deframer, session)
+		if len(present) == 0 {
+			return fmt.Errorf("no %s frame found", f.Name())
+		}
+
+		missing := 0
+		for present[missing] {
+			missing++
+		}
+
+		return fmt.Errorf("%s frames are incomplete, %d of %d fragments: %w", f.Name(), len(present), total, &ErrMissingChunk{Index: missing})
+	}
+
+	if !session.Legacy && hashBytes(data) != session.File.Hash {
+		return fmt.Errorf("%w: file reassembled from the %s frames", ErrHashMismatch, f.Name())
+	}
+
+	writeData := func(w io.Writer) error {
+		_, err := w.Write(data)
+
+		return err
+	}
+
+	if err := split.PublishFile(q.fs, outFilePath, 0644, writeData, nil); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+
+	return nil
+}
+
+// decodeFramedPayload returns the payload of a QR code holding a frame of the
+// protocol of f: the frame as Data, named after the protocol and frame index
+func decodeFramedPayload(f Framer, content []byte) (*ChunkPayload, error) {
+	index, err := f.Index(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChunkPayload{Format: f.Format(), Protocol: f.Name(), Name: fmt.Sprintf("%s_%04d", f.Name(), index), Data: content}, nil
+}
+
+// detectDataFileFramer returns the built-in framer of the protocol the data file
+// at path holds a frame of, or nil
+func detectDataFileFramer(fsys afero.Fs, path string) Framer {
+	data, err := afero.ReadFile(fsys, path)
+	if err != nil {
+		return nil
+	}
+
+	return detectFramer(data, nil)
+}
+
+// urFramer is the framer of ProtocolUR
+type urFramer struct{}
+
+func (urFramer) Name() string { return ProtocolUR }
+
+func (urFramer) Format() PayloadFormat { return PayloadFormatText }
+
+func (urFramer) NewFrameEncoder(data []byte, fragmentLen int) (FrameEncoder, error) {
+	encoder, err := ur.NewEncoder(ur.NewBytes(data), fragmentLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return urFrameEncoder{encoder}, nil
+}
+
+func (urFramer) Index(content []byte) (int, error) {
+	seqNum, err := ur.SeqNum(string(content))
+	if err != nil {
+		return 0, err
+	}
+
+	return seqNum - 1, nil
+}
+
+func (urFramer) NewDeframer() Deframer { return &urDeframer{} }
+
+// urFrameEncoder writes the upper case parts of a UR, which QR codes hold in
+// alphanumeric mode
+type urFrameEncoder struct {
+	encoder *ur.Encoder
+}
+
+func (e urFrameEncoder) Fragments() int { return e.encoder.SeqLen() }
+
+func (e urFrameEncoder) Frame(seqNum int) []byte {
+	return []byte(strings.ToUpper(e.encoder.Part(seqNum)))
+}
+
+// urDeframer reassembles the data of a UR of type bytes
+type urDeframer struct {
+	decoder ur.Decoder
+}
+
+func (d *urDeframer) Receive(content []byte) error { return d.decoder.Receive(string(content)) }
+
+func (d *urDeframer) Fragments() (int, []int) { return d.decoder.Fragments() }
+
+func (d *urDeframer) Result() ([]byte, bool, error) {
+	if !d.decoder.Complete() {
+		return nil, false, nil
+	}
+
+	data, err := d.decoder.Result().Bytes()
+
+	return data, err == nil, err
+}
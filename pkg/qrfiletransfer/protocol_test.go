@@ -0,0 +1,306 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// newFramedSession splits a random file with the protocol and the parity into
+// /<protocol> and returns its content and session
+func newFramedSession(t *testing.T, fs afero.Fs, protocol string, percent int) ([]byte, *Session) {
+	t.Helper()
+
+	content := make([]byte, 6000)
+	rand.New(rand.NewSource(5)).Read(content)
+
+	if err := afero.WriteFile(fs, "/in/framed.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetTargetQRVersion(20)
+	qrft.SetParity(percent)
+
+	if err := qrft.SetProtocol(protocol); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := qrft.FileToQRCodes("/in/framed.bin", "/"+protocol); err != nil {
+		t.Fatalf("FileToQRCodes(%s) failed: %v", protocol, err)
+	}
+
+	session, err := loadSession(fs, "/"+protocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content, session
+}
+
+func TestFileToQRCodesProtocol(t *testing.T) {
+	for _, protocol := range []string{ProtocolUR, ProtocolTXQR, ProtocolStructuredAppend} {
+		fs := afero.NewMemMapFs()
+		content, session := newFramedSession(t, fs, protocol, 100)
+
+		fragments := session.Settings.NumChunks
+		if session.Settings.Protocol != protocol || fragments < 2 || len(session.Chunks) != 2*fragments {
+			t.Fatalf("Session has protocol %q, %d fragments and %d frames, want %s and twice as many frames", session.Settings.Protocol, fragments, len(session.Chunks), protocol)
+		}
+
+		if manifest, err := loadManifest(fs, "/"+protocol); err != nil || manifest.Protocol != protocol {
+			t.Errorf("Manifest = %+v, %v, want protocol %s", manifest, err, protocol)
+		}
+
+		data, err := afero.ReadFile(fs, session.DataFile(session.Chunks[1].Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		payload, err := DecodePayload(data)
+		if err != nil || payload.Protocol != protocol || !bytes.Equal(payload.Data, data) {
+			t.Fatalf("DecodePayload(%.20q) = %+v, %v, want a %s frame", data, payload, err, protocol)
+		}
+
+		qrft := NewQRFileTransfer()
+		qrft.SetFs(fs)
+
+		// The redundant frames make up for a lost frame
+		if err := fs.Remove(session.DataFile(session.Chunks[1].Name)); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := qrft.VerifyChunks("/" + protocol)
+		if err != nil || !report.Complete() || report.Total != fragments {
+			t.Fatalf("VerifyChunks(%s) = %+v, %v, want %d fragments", protocol, report, err, fragments)
+		}
+
+		if err := qrft.QRCodesToFile("/"+protocol, "/out.bin"); err != nil {
+			t.Fatalf("QRCodesToFile(%s) failed: %v", protocol, err)
+		}
+
+		if got, _ := afero.ReadFile(fs, "/out.bin"); !bytes.Equal(got, content) {
+			t.Errorf("Reconstructed file of %s differs from the original", protocol)
+		}
+	}
+}
+
+func TestQRCodesToFileProtocolReceived(t *testing.T) {
+	for _, protocol := range []string{ProtocolUR, ProtocolTXQR, ProtocolStructuredAppend} {
+		fs := afero.NewMemMapFs()
+		content, session := newFramedSession(t, fs, protocol, 0)
+
+		// Save the frames like read does, named after the protocol and frame index
+		var first string
+
+		for i, chunk := range session.Chunks {
+			data, err := afero.ReadFile(fs, session.DataFile(chunk.Name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload, err := DecodePayload(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := ChunkDataPath("/received", payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if i == 0 {
+				first = path
+			}
+
+			if err := afero.WriteFile(fs, path, payload.Data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		qrft := NewQRFileTransfer()
+		qrft.SetFs(fs)
+
+		manifest, err := loadManifest(fs, "/"+protocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		integrity, err := qrft.VerifyIntegrity("/received", manifest)
+		if err != nil || !integrity.OK() || integrity.File.Name != "framed.bin" {
+			t.Fatalf("VerifyIntegrity(%s) = %v, %v, want intact framed.bin", protocol, integrity, err)
+		}
+
+		if err := qrft.QRCodesToFile("/received", "/out.bin"); err != nil {
+			t.Fatalf("QRCodesToFile(%s) failed: %v", protocol, err)
+		}
+
+		if got, _ := afero.ReadFile(fs, "/out.bin"); !bytes.Equal(got, content) {
+			t.Errorf("Reconstructed file of %s differs from the original", protocol)
+		}
+
+		// Without the first frame the file is incomplete
+		if err := fs.Remove(first); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := qrft.VerifyChunks("/received")
+		if err != nil || report.Complete() || len(report.Missing) != 1 || report.Missing[0] != 0 {
+			t.Errorf("VerifyChunks(%s) = %+v, %v, want fragment 0 missing", protocol, report, err)
+		}
+
+		if err := qrft.QRCodesToFile("/received", "/out.bin"); err == nil {
+			t.Errorf("QRCodesToFile(%s) succeeded without fragment 0", protocol)
+		}
+	}
+}
+
+func TestTXQRFrames(t *testing.T) {
+	encoder, err := txqrFramer{}.NewFrameEncoder([]byte("hello, world"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var frames []string
+	for seqNum := 1; seqNum <= encoder.Fragments()+1; seqNum++ {
+		frames = append(frames, string(encoder.Frame(seqNum)))
+	}
+
+	want := []string{"0/12|hello", "5/12|, wor", "10/12|ld", "0/12|hello"}
+	if strings.Join(frames, " ") != strings.Join(want, " ") {
+		t.Fatalf("Frames = %q, want %q", frames, want)
+	}
+
+	deframer := txqrFramer{}.NewDeframer()
+	for _, frame := range []string{"10/12|ld", "0/12|hello", "5/12|, wor"} {
+		if err := deframer.Receive([]byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if data, ok, err := deframer.Result(); !ok || err != nil || string(data) != "hello, world" {
+		t.Errorf("Result() = %q, %v, %v", data, ok, err)
+	}
+
+	if total, received := deframer.Fragments(); total != 3 || len(received) != 3 {
+		t.Errorf("Fragments() = %d, %v, want 3 fragments", total, received)
+	}
+
+	for _, invalid := range []string{"hello", "0/12hello", "01/12|hello", "10/12|hello", "-1/12|hello", "0/12|"} {
+		if _, err := (txqrFramer{}).Index([]byte(invalid)); err == nil {
+			t.Errorf("Index(%q) succeeded", invalid)
+		}
+	}
+}
+
+// prefixFramer is a protocol of txqr frames prefixed with "PFX"
+type prefixFramer struct{}
+
+func (prefixFramer) Name() string { return "pfx" }
+
+func (prefixFramer) Format() PayloadFormat { return PayloadFormatBinary }
+
+func (prefixFramer) NewFrameEncoder(data []byte, fragmentLen int) (FrameEncoder, error) {
+	return prefixEncoder{txqrFrameEncoder{data: data, fragmentLen: fragmentLen}}, nil
+}
+
+func (prefixFramer) Index(content []byte) (int, error) {
+	if !bytes.HasPrefix(content, []byte("PFX")) {
+		return 0, errors.New("not a pfx frame")
+	}
+
+	return txqrFramer{}.Index(content[3:])
+}
+
+func (prefixFramer) NewDeframer() Deframer { return prefixDeframer{txqrFramer{}.NewDeframer()} }
+
+type prefixEncoder struct{ txqrFrameEncoder }
+
+func (e prefixEncoder) Frame(seqNum int) []byte {
+	return append([]byte("PFX"), e.txqrFrameEncoder.Frame(seqNum)...)
+}
+
+type prefixDeframer struct{ Deframer }
+
+func (d prefixDeframer) Receive(content []byte) error {
+	return d.Deframer.Receive(bytes.TrimPrefix(content, []byte("PFX")))
+}
+
+func TestRegisterFramer(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	content := []byte(strings.Repeat("registered protocol ", 100))
+	if err := afero.WriteFile(fs, "/in/pfx.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+
+	if err := qrft.SetProtocol("pfx"); !errors.Is(err, ErrUnknownProtocol) {
+		t.Fatalf("SetProtocol(pfx) error = %v, want %v", err, ErrUnknownProtocol)
+	}
+
+	for _, f := range []Framer{prefixFramer{}, txqrFramer{}} {
+		if err := qrft.RegisterFramer(f); (err == nil) != (f.Name() == "pfx") {
+			t.Errorf("RegisterFramer(%s) error = %v", f.Name(), err)
+		}
+	}
+
+	if err := qrft.SetProtocol("pfx"); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft.SetMaxChunkSize(500)
+
+	if err := qrft.FileToQRCodes("/in/pfx.txt", "/pfx"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	// A reader without the framer cannot decode the frames
+	if _, err := DecodePayload([]byte("PFX0/5|hello")); err == nil {
+		t.Error("DecodePayload of a pfx frame succeeded without its framer")
+	}
+
+	if payload, err := qrft.DecodePayload([]byte("PFX0/5|hello")); err != nil || payload.Protocol != "pfx" {
+		t.Errorf("DecodePayload() = %+v, %v, want a pfx frame", payload, err)
+	}
+
+	if err := qrft.QRCodesToFile("/pfx", "/out.txt"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, _ := afero.ReadFile(fs, "/out.txt"); !bytes.Equal(got, content) {
+		t.Error("Reconstructed file differs from the original")
+	}
+}
+
+func TestProtocolSingleFileOnly(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	for _, name := range []string{"/in/dir/a.txt", "/in/b.txt"} {
+		if err := afero.WriteFile(fs, name, []byte("data of "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+
+	if err := qrft.SetProtocol(ProtocolUR); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := qrft.DirToQRCodes("/in/dir", "/dir"); err == nil {
+		t.Error("DirToQRCodes succeeded with the UR protocol")
+	}
+
+	if _, err := qrft.FilesToQRCodes([]string{"/in/dir/a.txt", "/in/b.txt"}, "/batch"); err == nil {
+		t.Error("FilesToQRCodes succeeded with the UR protocol")
+	}
+}
@@ -0,0 +1,41 @@
+package qrfiletransfer
+
+import "github.com/dyammarcano/qrfiletransfer/pkg/split"
+
+// Protocol lists the versions of a file or payload format this package reads
+type Protocol struct {
+	// Name identifies the format
+	Name string `json:"name"`
+	// Versions lists every version read in ascending order, the newest is written
+	Versions []int `json:"versions"`
+}
+
+// Protocols returns the formats this package reads and writes with their versions.
+// The payload versions are those recorded as payload_format_version in manifest.json:
+// binary payloads are written in version 1, or 2 with next-up hints and 3 for the
+// files of a batch.
+func Protocols() []Protocol {
+	return []Protocol{
+		{Name: "session", Versions: versionsUpTo(1, SessionVersion)},
+		{Name: "manifest", Versions: versionsUpTo(1, ManifestVersion)},
+		{Name: "batch", Versions: versionsUpTo(1, BatchVersion)},
+		{Name: "frame-order", Versions: versionsUpTo(1, FrameOrderVersion)},
+		{Name: "split-metadata", Versions: versionsUpTo(1, split.MetadataVersion)},
+		{Name: "payload-text", Versions: []int{int(PayloadFormatText)}},
+		{Name: "payload-binary", Versions: versionsUpTo(int(binaryPayloadVersion), int(binaryPayloadVersionBatch))},
+		{Name: "text-chunk", Versions: versionsUpTo(1, textChunkVersion)},
+		{Name: "parity-chunk", Versions: versionsUpTo(1, parityChunkVersion)},
+		{Name: "ack", Versions: versionsUpTo(1, ackVersion)},
+		{Name: "stream-info", Versions: versionsUpTo(1, streamInfoVersion)},
+	}
+}
+
+// versionsUpTo returns the versions from first to newest
+func versionsUpTo(first, newest int) []int {
+	versions := make([]int, 0, newest-first+1)
+	for v := first; v <= newest; v++ {
+		versions = append(versions, v)
+	}
+
+	return versions
+}
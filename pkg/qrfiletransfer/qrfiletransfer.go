@@ -1,26 +1,50 @@
 package qrfiletransfer
 
 import (
-	"encoding/base64"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
 	"io"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
+	"github.com/dyammarcano/qrfiletransfer/pkg/cleanup"
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
 	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
 	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/dyammarcano/qrfiletransfer/pkg/workspace"
+	"github.com/spf13/afero"
 )
 
 // QRFileTransfer handles the conversion of files to QR codes and back
 type QRFileTransfer struct {
 	splitter *split.Split
-	// Maximum chunk size in bytes (considering QR code capacity)
-	// Version 40 with Low recovery level can encode up to 2953 bytes
-	// Using a slightly smaller value to be safe
+	// File system the files, chunks and QR codes are read from and written to
+	fs afero.Fs
+	// Maximum size in bytes of the file data of a chunk, 0 to fill the QR codes
 	maxChunkSize int
+	// Highest QR code version generated, 0 for no limit
+	targetQRVersion int
+	// Draw every QR code at the target version, padding the smaller ones
+	uniformQRVersion bool
 	// QR code recovery level
 	recoveryLevel qrcode.RecoveryLevel
+	// Give every QR code the highest recovery level fitting in its version
+	autoRecoveryLevel bool
 	// QR code size in pixels
 	qrSize int
 	// Minimum QR code size in pixels
@@ -29,24 +53,188 @@ type QRFileTransfer struct {
 	maxQRSize int
 	// Enable automatic QR size adjustment based on content
 	autoAdjustQRSize bool
+	// Colors, quiet zone, and logo of the QR code images
+	qrOptions qrcode.Options
+	// Fingerprint of qrOptions recorded in the session, empty for the defaults
+	qrOptionsID string
+	// Compression and resolution of the PNG images
+	pngOptions qrcode.WriteFileOptions
+	// Number of chunks encoded in parallel
+	concurrency int
+	// Format used to store chunks in QR codes
+	payloadFormat PayloadFormat
+	// Name of the codec of text payloads, empty for base64
+	codec string
+	// Chunk codecs registered in addition to the built-in ones
+	codecs map[string]ChunkCodec
+	// Framer of the protocol the QR codes follow, nil for the native protocol
+	framer Framer
+	// Framers registered with RegisterFramer, by name
+	framers map[string]Framer
+	// Number of following chunk indices embedded as next-up hints in each payload
+	nextHints int
+	// ID of the file in a batch written into each payload, empty for a single file
+	fileID string
+	// Print the chunk index and checksum in a caption strip below each QR code
+	checksumCaption bool
+	// File format of the QR code images
+	imageFormat ImageFormat
+	// Also write every chunk as a Base45 text file
+	textFallback bool
+	// Do not write the raw data of every chunk next to its QR code
+	noRawData bool
+	// Number of parity chunks in percent of the data chunks, 0 for none
+	parity int
+	// Encode identical chunks once, cutting them at content-defined boundaries
+	dedup bool
+	// Name the data files and QR code images after the hash of their content
+	contentAddressed bool
+	// Most QR codes of a volume directory, 0 for a single QR codes directory
+	volumeSize int
+	// Omit timestamps so the same input always yields the same output
+	deterministic bool
+	// Collects the non-fatal issues found, nil to discard them
+	diagnostics *diagnostics.Collector
+	// Receives the progress of the files of a batch reconstructed by BatchToFiles
+	batchProgress func(BatchProgress)
+	// Receives the QR code of every chunk encoded, nil to discard them
+	qrCodeObserver func(name string, code *qrcode.QRCode)
+	// Receives the progress and warnings of the operations, nil to discard them
+	logger *slog.Logger
+	// Tracks the temporary directories until they are removed, nil to remove them
+	// only when the operation returns
+	cleanup *cleanup.Manager
+	// Decides which intermediate directories are kept, nil to keep none
+	work *workspace.Workspace
+	// Context of the running operation, set on a copy by the Ctx variants of the
+	// operations, nil for one that cannot be canceled
+	ctx context.Context
 }
 
 // NewQRFileTransfer creates a new QRFileTransfer instance
 func NewQRFileTransfer() *QRFileTransfer {
 	return &QRFileTransfer{
 		splitter:         split.NewSplit(),
-		maxChunkSize:     2000, // Using a conservative value to ensure QR codes can be generated
+		fs:               osFs,
 		recoveryLevel:    qrcode.Medium,
 		qrSize:           800,  // Default QR code size in pixels
 		minQRSize:        800,  // Minimum QR code size in pixels
 		maxQRSize:        1600, // Maximum QR code size in pixels
 		autoAdjustQRSize: true, // Enable automatic QR size adjustment by default
+		concurrency:      runtime.NumCPU(),
+		payloadFormat:    PayloadFormatBinary,
+		pngOptions:       qrcode.DefaultWriteFileOptions(),
 	}
 }
 
-// SetRecoveryLevel sets the QR code recovery level
+// SetRecoveryLevel sets the QR code recovery level, raised to the level required
+// by the logo of SetQROptions
 func (q *QRFileTransfer) SetRecoveryLevel(level qrcode.RecoveryLevel) {
-	q.recoveryLevel = level
+	q.recoveryLevel = q.qrOptions.RecoveryLevel(level)
+}
+
+// SetQROptions sets the colors, quiet zone width, logo, and data mask the QR code
+// images are drawn with. A logo raises the recovery level to one recovering the modules it
+// hides, see qrcode.Options.RecoveryLevel, taking more QR codes for the file.
+// Inverted colors, light modules on a dark background, suit dark screens but are
+// not read by every scanner. An error is returned for invalid options.
+func (q *QRFileTransfer) SetQROptions(options qrcode.Options) error {
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("failed to set QR code options: %w", err)
+	}
+
+	q.qrOptions = options
+	q.qrOptionsID = qrOptionsID(options)
+	q.recoveryLevel = options.RecoveryLevel(q.recoveryLevel)
+
+	return nil
+}
+
+// SetPNGOptions sets the compression and resolution of the PNG images of the QR
+// codes, by default the best compression and no resolution. With a resolution in
+// DPI, a QR code of --size pixels prints size/DPI inches wide from programs
+// honoring it, and BMP images record it too. An error is returned for invalid
+// options.
+func (q *QRFileTransfer) SetPNGOptions(options qrcode.WriteFileOptions) error {
+	if err := options.Validate(); err != nil {
+		return fmt.Errorf("failed to set PNG options: %w", err)
+	}
+
+	q.pngOptions = options
+
+	return nil
+}
+
+// pngOptionsID returns a fingerprint of the PNG options, so QR code images
+// written with other options are not resumed, or an empty string for the
+// defaults
+func pngOptionsID(options qrcode.WriteFileOptions) string {
+	var parts []string
+
+	if options.CompressionLevel != qrcode.DefaultWriteFileOptions().CompressionLevel {
+		parts = append(parts, fmt.Sprintf("compression=%d", options.CompressionLevel))
+	}
+
+	if options.DPI > 0 {
+		parts = append(parts, fmt.Sprintf("dpi=%d", options.DPI))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// qrOptionsID returns a fingerprint of the drawing options, so QR code images
+// drawn with other options are not resumed, or an empty string for the default
+// black on white QR codes with the standard quiet zone
+func qrOptionsID(options qrcode.Options) string {
+	var parts []string
+
+	for _, c := range []struct {
+		name  string
+		color color.Color
+		def   color.Color
+	}{{"fg", options.ForegroundColor, color.Black}, {"bg", options.BackgroundColor, color.White}} {
+		if c.color != nil && color.RGBAModel.Convert(c.color) != color.RGBAModel.Convert(c.def) {
+			r, g, b, a := c.color.RGBA()
+			parts = append(parts, fmt.Sprintf("%s=%02x%02x%02x%02x", c.name, r>>8, g>>8, b>>8, a>>8))
+		}
+	}
+
+	if options.QuietZone > 0 {
+		parts = append(parts, fmt.Sprintf("quiet-zone=%d", options.QuietZone))
+	}
+
+	if options.FastMask {
+		parts = append(parts, "fast-mask")
+	}
+
+	if options.Logo != nil {
+		h := sha256.New()
+
+		bounds := options.Logo.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBAModel.Convert(options.Logo.At(x, y)).(color.NRGBA)
+				h.Write([]byte{c.R, c.G, c.B, c.A})
+			}
+		}
+
+		parts = append(parts, fmt.Sprintf("logo=%dx%d:%s", bounds.Dx(), bounds.Dy(), hex.EncodeToString(h.Sum(nil))[:16]),
+			fmt.Sprintf("logo-size=%g", options.LogoSize))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// SetAutoRecoveryLevel enables picking the recovery level of every QR code
+// separately: each chunk gets the highest recovery level that still fits its payload
+// in a QR code of the version set by SetTargetQRVersion, 40 by default. The level
+// of SetRecoveryLevel is the lowest used and the one chunks are packed for, so
+// dense chunks keep it while sparse ones, such as those of a file a little larger
+// than a multiple of the capacity, get more error correction without more QR
+// codes. The level of every chunk is carried by the format information of its QR
+// code, so readers need no change, and recorded in the session.
+func (q *QRFileTransfer) SetAutoRecoveryLevel(enable bool) {
+	q.autoRecoveryLevel = enable
 }
 
 // SetQRSize sets the QR code size in pixels
@@ -64,57 +252,261 @@ func (q *QRFileTransfer) SetMaxQRSize(size int) {
 	q.maxQRSize = size
 }
 
+// SetMaxChunkSize sets the maximum size in bytes of the file data of a chunk.
+// Chunks are otherwise as large as the QR codes can hold at the recovery level and
+// payload format, see SetTargetQRVersion. 0, the default, sets no other limit.
+func (q *QRFileTransfer) SetMaxChunkSize(bytes int) {
+	q.maxChunkSize = max(bytes, 0)
+}
+
+// SetTargetQRVersion sets the highest QR code version generated, 1 to 40, e.g. 20
+// for scanners that struggle with dense version 40 symbols. Chunks are packed to
+// the capacity of a QR code of that version at the recovery level and payload
+// format, and a chunk that still needs a higher version is handled like a chunk too
+// long for any QR code: the file is split again into smaller chunks. 0, the
+// default, packs chunks into QR codes of the largest version, 40.
+func (q *QRFileTransfer) SetTargetQRVersion(version int) {
+	q.targetQRVersion = max(version, 0)
+}
+
+// SetUniformQRVersion draws every QR code at the version of SetTargetQRVersion,
+// padding those of the last chunk and other short payloads, so that all QR codes
+// of the file have the same number of modules and are drawn at the same scale,
+// which helps decoders locking onto the frames of a video. It has no effect
+// without a target version.
+func (q *QRFileTransfer) SetUniformQRVersion(enable bool) {
+	q.uniformQRVersion = enable
+}
+
+// SetParity adds Reed-Solomon parity chunks to the data chunks of a file, percent
+// of their number rounded up, from 0 (the default, no parity) to 100. The file can
+// be reconstructed as long as no more QR codes are lost than there are parity
+// chunks: QRCodesToFile restores missing or damaged chunks from the parity chunks.
+// Files of more than 256 chunks are protected in groups of interleaved chunks, each
+// with its own parity chunks.
+func (q *QRFileTransfer) SetParity(percent int) {
+	q.parity = min(max(percent, 0), 100)
+}
+
+// SetDedup cuts files into chunks at boundaries depending on their content, so
+// that repeated data, such as the zeros of a sparse file or the blocks of a disk
+// image repeated across it, yields identical chunks that are encoded as a single QR
+// code. The metadata of the first chunk, which then holds no file data, and the
+// manifest list the chunks duplicating another, and decoding reads them from the
+// QR code of that chunk. Chunks average 3/4 of the capacity of the QR codes, so a
+// file without repeated data takes more QR codes than without it. It cannot be
+// combined with SetParity and is ignored by protocols other than ProtocolNative.
+func (q *QRFileTransfer) SetDedup(enable bool) {
+	q.dedup = enable
+}
+
+// SetContentAddressed names the data file of every chunk after the first 16 hex
+// digits of the SHA-256 of its data, and its QR code image and text file after
+// those of the SHA-256 of the QR code content, instead of after the chunk name.
+// Identical data is then stored in a single data file, and the directories of
+// several archives can be merged or synced, e.g. with rsync, without files of
+// different content colliding. The session and the manifest map the chunks to
+// their files.
+func (q *QRFileTransfer) SetContentAddressed(enable bool) {
+	q.contentAddressed = enable
+}
+
+// SetVolumeSize splits the QR codes of a file into numbered volume directories,
+// vol001, vol002, and so on, of at most count QR codes each, with the data, text,
+// and parity files of their chunks and a manifest of their own, so that a large
+// set is printed or displayed one volume at a time. The parity chunks start a
+// volume of their own, and QRCodesToFile restores the chunks of missing volumes
+// from them, see SetParity. A count of 0 (the default) keeps all QR codes in a
+// single directory.
+func (q *QRFileTransfer) SetVolumeSize(count int) {
+	q.volumeSize = max(count, 0)
+}
+
+// SetFs sets the file system the files, chunks and QR codes are read from and
+// written to, including the chunks of split.Split, e.g. afero.NewMemMapFs() to
+// encode and decode in memory. The operating system's file system is the default.
+func (q *QRFileTransfer) SetFs(fs afero.Fs) {
+	q.fs = fs
+	q.splitter.SetFs(fs)
+}
+
+// osFs is the operating system's file system
+var osFs = afero.NewOsFs()
+
+// SetLogger sets the logger receiving the progress and warnings of the operations,
+// including the merges of split.Split. Nothing is logged without one.
+func (q *QRFileTransfer) SetLogger(logger *slog.Logger) {
+	q.logger = logger
+	q.splitter.SetLogger(logger)
+}
+
+// log returns the logger of q, discarding the records without one
+func (q *QRFileTransfer) log() *slog.Logger {
+	if q.logger == nil {
+		return discardLogger
+	}
+
+	return q.logger
+}
+
+// discardLogger drops every record
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
+
+// SetBatchProgress sets the function BatchToFiles reports the progress of every
+// file to, as it starts and once it is done. It is called by one worker at a time.
+func (q *QRFileTransfer) SetBatchProgress(fn func(BatchProgress)) {
+	q.batchProgress = fn
+}
+
+// SetQRCodeObserver sets the function the QR code of every chunk, parity chunk,
+// or frame is passed to with the chunk name as it is encoded, e.g. to display
+// the QR codes without reading back their images. It is called concurrently by
+// the encoding workers, and not for chunks reused from a previous run.
+func (q *QRFileTransfer) SetQRCodeObserver(fn func(name string, code *qrcode.QRCode)) {
+	q.qrCodeObserver = fn
+}
+
 // SetAutoAdjustQRSize enables or disables automatic QR size adjustment
 func (q *QRFileTransfer) SetAutoAdjustQRSize(enable bool) {
 	q.autoAdjustQRSize = enable
 }
 
-// calculateOptimalQRSize calculates the optimal QR code size in pixels based on the chunk size
-// It estimates the QR code version based on the chunk size and then calculates an appropriate pixel size
-func (q *QRFileTransfer) calculateOptimalQRSize(chunkSize int) int {
-	// Base64 encoding increases the size by approximately 4/3
-	encodedSize := int(float64(chunkSize) * 1.34)
+// SetConcurrency sets the number of chunks encoded into QR codes in parallel, and
+// of files of a batch reconstructed in parallel by BatchToFiles. Values below 1
+// process them one at a time.
+func (q *QRFileTransfer) SetConcurrency(n int) {
+	q.concurrency = n
+}
 
-	// Add some overhead for the chunk identifier and format
-	// "Chunk: name\nData: base64data"
-	encodedSize += 20
+// SetPayloadFormat sets the format used to store chunks in QR codes.
+// PayloadFormatBinary stores chunk bytes directly and is the default;
+// PayloadFormatText stores them base64 encoded, which costs about a third of
+// the QR code capacity but yields codes that generic scanner apps display as text.
+// Text payloads are base64 encoded until SetCodec selects another codec.
+func (q *QRFileTransfer) SetPayloadFormat(format PayloadFormat) {
+	q.payloadFormat = format
+	q.codec = ""
+}
 
-	// Estimate QR code version based on data size and recovery level
-	// These are rough estimates based on QR code capacity
-	var estimatedVersion int
+// SetNextHints sets the number of following chunk indices embedded in each payload.
+// Receivers use these next-up hints to flag skipped chunks as soon as they happen.
+// Payloads with hints cannot be read by versions that predate them, so 0, the
+// default, disables hints.
+func (q *QRFileTransfer) SetNextHints(count int) {
+	if count < 0 {
+		count = 0
+	}
 
-	// Capacity in bytes for different versions with Medium recovery level
-	// Version 1: ~16 bytes, Version 10: ~271 bytes, Version 20: ~858 bytes,
-	// Version 30: ~1732 bytes, Version 40: ~2953 bytes
-	switch {
-	case encodedSize < 16:
-		estimatedVersion = 1
-	case encodedSize < 100:
-		estimatedVersion = 5
-	case encodedSize < 271:
-		estimatedVersion = 10
-	case encodedSize < 500:
-		estimatedVersion = 15
-	case encodedSize < 858:
-		estimatedVersion = 20
-	case encodedSize < 1300:
-		estimatedVersion = 25
-	case encodedSize < 1732:
-		estimatedVersion = 30
-	case encodedSize < 2300:
-		estimatedVersion = 35
-	default:
-		estimatedVersion = 40
+	q.nextHints = count
+}
+
+// SetChecksumCaption enables a caption strip below each QR code image showing the
+// chunk index and the first digits of the SHA-256 of the chunk, e.g. "#3 9f86d0",
+// or "#2/3 9f86d0" for chunk 3 of file 2 in a batch. An operator comparing the
+// captions of printed pages against the manifest can tell which page is damaged
+// without any software. The caption is not part of the QR code.
+func (q *QRFileTransfer) SetChecksumCaption(enable bool) {
+	q.checksumCaption = enable
+}
+
+// SetImageFormat sets the file format QR code images are written in.
+// ImageFormatPNG is the default. join decodes the QR codes of a session without
+// data files from the images of every raster format, see ImageFormat.Decodable.
+func (q *QRFileTransfer) SetImageFormat(format ImageFormat) {
+	q.imageFormat = format
+}
+
+// SetTextFallback enables writing every chunk as a Base45 text file next to its QR
+// code, see EncodeTextChunk. If the QR code images are damaged, a file can still be
+// recovered from the text files with TextToFile after OCR or manual typing.
+func (q *QRFileTransfer) SetTextFallback(enable bool) {
+	q.textFallback = enable
+}
+
+// SetEmitRawData sets whether the raw data of every chunk is written into the data
+// directory next to its QR code, as by default. Without it a session takes half the
+// disk space, but its file can only be reconstructed by decoding the QR code images,
+// QRCodesToFile returns ErrNoRawData. The QR codes of other protocols always keep
+// their data files.
+func (q *QRFileTransfer) SetEmitRawData(enable bool) {
+	q.noRawData = !enable
+}
+
+// SetDeterministic omits the time of the split and the modification times of the
+// file, or of the entries of a directory archive, from the metadata, so that
+// encoding the same input twice writes byte-identical QR codes, manifests and
+// sessions. Outputs can then be compared or signed, e.g. to verify an archive or
+// diff the outputs of a CI pipeline. Reconstructed files keep the time they are
+// written at.
+func (q *QRFileTransfer) SetDeterministic(enable bool) {
+	q.deterministic = enable
+	q.splitter.SetDeterministic(enable)
+}
+
+// checksumCaptionLength is the number of hex digits of the chunk hash in a caption
+const checksumCaptionLength = 6
+
+// SetDiagnostics sets the collector of the non-fatal issues found while encoding
+// and reconstructing files, such as low-density QR codes or renamed outputs
+func (q *QRFileTransfer) SetDiagnostics(c *diagnostics.Collector) {
+	q.diagnostics = c
+}
+
+// SetCleanup sets the manager tracking the temporary directories of the operations,
+// so that a program interrupted while one runs can remove them with m.Cleanup()
+func (q *QRFileTransfer) SetCleanup(m *cleanup.Manager) {
+	q.cleanup = m
+}
+
+// SetWorkspace sets the workspace creating the intermediate directories of the
+// operations, such as split chunks, which keeps them in place if its retention says
+// so. It replaces the cleanup manager set by SetCleanup.
+func (q *QRFileTransfer) SetWorkspace(w *workspace.Workspace) {
+	q.work = w
+}
+
+// workspace returns the workspace of the intermediate directories, one keeping
+// none of them and tracking them in the cleanup manager if none is set
+func (q *QRFileTransfer) workspace() *workspace.Workspace {
+	if q.work == nil {
+		return workspace.New(q.cleanup, workspace.KeepNone)
 	}
 
-	// Calculate module size (number of modules in the QR code)
-	// Formula: 21 + (version-1)*4
-	moduleSize := 21 + (estimatedVersion-1)*4
+	return q.work
+}
 
-	// Calculate pixel size based on module size
-	// We want each module to be at least 2 pixels for readability
-	// But we also want to keep the QR code size reasonable
-	pixelSize := moduleSize * 4
+// makeTempDir creates a temporary directory in the workspace, and returns it with
+// the function removing it
+func (q *QRFileTransfer) makeTempDir(pattern string) (string, func() error, error) {
+	return q.workspace().Create(q.fs, workspace.Scratch, pattern)
+}
+
+// optimalPixelsPerModule is the number of pixels per module automatically sized QR
+// codes are drawn with: QR codes of the highest versions decode reliably from six
+const optimalPixelsPerModule = 6
+
+// minPixelsPerModule is the number of pixels per module, quiet zone included, below
+// which a QR code image is reported as low-density: modules this small blur when
+// the code is shown on a screen and filmed
+const minPixelsPerModule = 3
+
+// chunkCaption returns the caption of chunk index with the hex encoded SHA-256
+// hash, see SetChecksumCaption
+func (q *QRFileTransfer) chunkCaption(index int, hash string) string {
+	if q.fileID != "" {
+		return fmt.Sprintf("#%s/%d %s", q.fileID, index, hash[:checksumCaptionLength])
+	}
+
+	return fmt.Sprintf("#%d %s", index, hash[:checksumCaptionLength])
+}
+
+// calculateOptimalQRSize calculates the optimal QR code size in pixels of a QR code
+// of version, so that every module, quiet zone included, is drawn with
+// optimalPixelsPerModule pixels
+func (q *QRFileTransfer) calculateOptimalQRSize(version int) int {
+	// A QR code of version has 21 + (version-1)*4 modules and a quiet zone of 4
+	// modules on each side
+	pixelSize := (21 + (version-1)*4 + 8) * optimalPixelsPerModule
 
 	// Ensure the size is within the min and max bounds
 	if pixelSize < q.minQRSize {
@@ -127,14 +519,26 @@ func (q *QRFileTransfer) calculateOptimalQRSize(chunkSize int) int {
 }
 
 // FileToQRCodes converts a file to a series of QR codes
+// The output directory is laid out as described by Session and only appears once
+// the session is complete. Re-running it into an output directory that already
+// holds a session for the same input and settings only regenerates the QR codes
+// and data files that are missing. If a chunk does not fit in a QR code at the
+// chosen recovery level and payload format, the file is split again into smaller
+// chunks instead of failing, and the reduction is recorded in the manifest.
 // Parameters:
 //   - filePath: Path to the file to convert
 //   - outDir: Directory to store the QR codes
 //
 // Returns an error if any part of the process fails.
 func (q *QRFileTransfer) FileToQRCodes(filePath string, outDir string) error {
+	return q.fileToQRCodes(filePath, outDir, nil)
+}
+
+// fileToQRCodes implements FileToQRCodes. If dir is not nil, the file is an archive
+// of the directory dir describes, see DirToQRCodes.
+func (q *QRFileTransfer) fileToQRCodes(filePath string, outDir string, dir os.FileInfo) (err error) {
 	// Open the file
-	file, err := os.Open(filePath)
+	file, err := q.fs.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
@@ -146,13 +550,34 @@ func (q *QRFileTransfer) FileToQRCodes(filePath string, outDir string) error {
 		}
 	}()
 
-	// Create a temporary directory for chunks
-	tempDir := filepath.Join(outDir, "temp")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
+	return q.encodeFile(file, outDir, dir)
+}
+
+// encodeFile converts the open file to QR codes in outDir, see fileToQRCodes. The
+// file is named after the base name of file.Name().
+func (q *QRFileTransfer) encodeFile(file afero.File, outDir string, dir os.FileInfo) (err error) {
+	// Hash the input so that a previous run for the same file can be detected
+	inputHash, err := hashReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
 	}
 
-	// Calculate the number of chunks based on file size and max chunk size
+	// Build the session in a staging directory that is published once complete
+	workDir, err := prepareSessionDir(q.fs, outDir)
+	if err != nil {
+		return err
+	}
+
+	// The chunks are split into a temporary directory
+	tempDir := filepath.Join(workDir, "temp")
+	releaseTemp := q.workspace().Track(q.fs, workspace.Scratch, tempDir)
+
+	defer func() {
+		if err != nil {
+			_ = releaseTemp()
+		}
+	}()
+
 	fileInfo, err := file.Stat()
 	if err != nil {
 		return fmt.Errorf("failed to get file info: %w", err)
@@ -160,227 +585,903 @@ func (q *QRFileTransfer) FileToQRCodes(filePath string, outDir string) error {
 
 	fileSize := fileInfo.Size()
 
-	// Calculate the number of chunks based on file size
-	// For larger files; we need more chunks to ensure each chunk is small enough for QR encoding
-	var numChunks int
+	if dir != nil && q.framer != nil {
+		return fmt.Errorf("the %s protocol carries a single file, not a directory", q.framer.Name())
+	}
 
-	switch {
-	case fileSize <= 1000:
-		// For small files, use at least 2 chunks
-		numChunks = 2
-	case fileSize <= 5000:
-		// For medium files, ensure chunks are around 1000 bytes or less
-		numChunks = int(fileSize/1000) + 1
-	case fileSize <= 20000:
-		// For larger files, ensure chunks are around 800 bytes or less
-		numChunks = int(fileSize/800) + 1
-	default:
-		// For very large files, ensure chunks are around 500 bytes or less
-		numChunks = int(fileSize/500) + 1
+	if q.dedup && q.parity > 0 && q.framer == nil {
+		return errors.New("deduplicated chunks cannot be protected by parity chunks")
 	}
 
-	// Update maxChunkSize based on the calculated number of chunks
-	// This ensures that the chunks are properly sized for QR encoding
-	q.maxChunkSize = int(fileSize/int64(numChunks)) + 1
+	// Calculate the number of chunks based on file size
+	numChunks := q.chunkCount(filepath.Base(file.Name()), fileSize)
+
+	// Volumes hold the layout directories themselves, see Session.makeVolumeDirs
+	mkdir := func(dir string) error {
+		if q.volumeSize > 0 {
+			return nil
+		}
 
-	// Split the file into chunks
-	if err := q.splitter.SplitFile(file, tempDir, numChunks); err != nil {
-		return fmt.Errorf("failed to split file: %w", err)
+		return q.fs.MkdirAll(filepath.Join(workDir, dir), 0750)
 	}
 
 	// Create an output directory for QR codes
-	qrDir := filepath.Join(outDir, "qrcodes")
-	if err := os.MkdirAll(qrDir, 0750); err != nil {
+	layout := defaultSessionLayout()
+	if err := mkdir(layout.QRCodes); err != nil {
 		return fmt.Errorf("failed to create QR codes directory: %w", err)
 	}
 
 	// Create an output directory for raw data
-	dataDir := filepath.Join(outDir, "data")
-	if err := os.MkdirAll(dataDir, 0750); err != nil {
+	if q.noRawData && q.framer == nil {
+		layout.Data = ""
+	} else if err := mkdir(layout.Data); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Get all chunk files
-	chunkFiles, err := filepath.Glob(filepath.Join(tempDir, "*.part"))
+	// Create an output directory for the text fallback
+	if q.textFallback && q.framer == nil {
+		layout.Text = "text"
+		if err := mkdir(layout.Text); err != nil {
+			return fmt.Errorf("failed to create text directory: %w", err)
+		}
+	}
+
+	// Create an output directory for the parity chunks
+	if q.parity > 0 && q.framer == nil {
+		layout.Parity = "parity"
+		if err := mkdir(layout.Parity); err != nil {
+			return fmt.Errorf("failed to create parity directory: %w", err)
+		}
+	}
+
+	session := &Session{
+		Version: SessionVersion,
+		File: SessionFile{
+			Name: filepath.Base(file.Name()),
+			Size: fileSize,
+			Hash: inputHash,
+			Dir:  dir != nil,
+		},
+		Layout: layout,
+	}
+
+	// A previous run that had to reduce the chunk size resumes with the reduced size
+	if previous := loadPreviousSession(q.fs, workDir); previous != nil && len(previous.ChunkSizeReductions) > 0 &&
+		previous.matches(inputHash, q.sessionSettings(previous.Settings.NumChunks)) {
+		numChunks = previous.ChunkSizeReductions[len(previous.ChunkSizeReductions)-1].ToChunks
+		session.ChunkSizeReductions = previous.ChunkSizeReductions
+	}
+
+	// A chunk too long for a QR code at the current settings does not abort the run:
+	// the file is split again into smaller chunks and the change recorded. The chunk
+	// count is part of the metadata of the first chunk, so the whole file is split
+	// again rather than only the chunks that were not encoded yet.
+	for q.framer == nil {
+		err := q.encodeFileChunks(file, dir, workDir, tempDir, session, numChunks)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, qrcode.ErrContentTooLong) || len(session.ChunkSizeReductions) >= maxChunkSizeReductions {
+			return err
+		}
+
+		reduction := reduceChunkSize(fileSize, numChunks)
+		session.ChunkSizeReductions = append(session.ChunkSizeReductions, reduction)
+		numChunks = reduction.ToChunks
+	}
+
+	// The frames of other protocols are sized to the QR codes up front
+	if q.framer != nil {
+		if err := q.encodeFrames(file, workDir, tempDir, session); err != nil {
+			return err
+		}
+	}
+
+	// Clean up temporary directory
+	if err := releaseTemp(); err != nil {
+		return fmt.Errorf("failed to clean up temporary directory: %w", err)
+	}
+
+	// Mark the session as complete, describe it in the manifest, and publish it
+	session.Complete = true
+	if err := session.save(q.fs, workDir); err != nil {
+		return err
+	}
+
+	if err := saveManifests(q.fs, session, workDir); err != nil {
+		return err
+	}
+
+	return publishSessionDir(q.fs, workDir, outDir)
+}
+
+// chunkCount returns the number of chunks the file name of fileSize bytes is split
+// into: as few as the QR codes can hold, see chunkSizeLimit. The metadata takes the
+// place of data in the first chunk, unless chunks of balanced sizes leave it room
+// for the metadata, see splitOptions.
+func (q *QRFileTransfer) chunkCount(name string, fileSize int64) int {
+	if count := q.chunksFor(name, fileSize); q.balancedChunksFit(name, fileSize, count) {
+		return count
+	}
+
+	return q.chunksFor(name, fileSize+int64(split.MetadataSize(name)))
+}
+
+// chunksFor returns the number of chunks of the file name that size bytes fill
+func (q *QRFileTransfer) chunksFor(name string, size int64) int {
+	// Past 10000 chunks the longer chunk names leave less room for the data
+	count, width := 1, 0
+
+	for width != split.ChunkIndexWidth(count) {
+		width = split.ChunkIndexWidth(count)
+		limit := int64(q.chunkSizeLimit(name, width))
+		count = max(1, int((size+limit-1)/limit))
+	}
+
+	return count
+}
+
+// chunkSizeLimit returns the maximum size in bytes of the file data of a chunk of
+// the file name, its index padded to width digits: its chunkCapacity, capped by
+// SetMaxChunkSize
+func (q *QRFileTransfer) chunkSizeLimit(name string, width int) int {
+	limit := q.chunkCapacity(name, width)
+	if q.maxChunkSize > 0 && q.maxChunkSize < limit {
+		return q.maxChunkSize
+	}
+
+	return limit
+}
+
+// balancedChunksFit reports whether the file name of fileSize bytes split into
+// numChunks chunks of balanced sizes fits in the QR codes, the first chunk holding
+// the metadata on top of its data
+func (q *QRFileTransfer) balancedChunksFit(name string, fileSize int64, numChunks int) bool {
+	capacity := int64(q.chunkCapacity(name, split.ChunkIndexWidth(numChunks)))
+
+	return chunkSizeFor(fileSize, numChunks)+int64(split.MetadataSize(name)) <= capacity
+}
+
+// chunkCapacity returns the most bytes of file data and metadata a QR code holds
+// in a chunk of the file name, its index padded to width digits: the capacity of a
+// QR code of the version of SetTargetQRVersion, or of the largest version, at the
+// recovery level in the encoding mode of the payload format. The capacity is what
+// is left of the QR code once the payload header and the checksum are stored, with
+// room for the header of the parity chunks with SetParity.
+func (q *QRFileTransfer) chunkCapacity(name string, width int) int {
+	version := q.targetQRVersion
+	if version == 0 {
+		version = maxQRVersion
+	}
+
+	chunkName := strings.TrimSuffix(name, filepath.Ext(name)) + "_" + strings.Repeat("0", width)
+	lastIndex := int(math.Pow10(width)) - 1
+
+	var capacity int
+	if q.payloadFormat == PayloadFormatText && q.codec != "" {
+		// Other codecs are measured, as QR codes may hold their text in denser modes
+		capacity = q.codecCapacity(&ChunkPayload{File: q.fileID, Name: chunkName, Next: slices.Repeat([]int{lastIndex}, q.nextHints)}, version)
+	} else {
+		// Up to 4 bytes a next-up hint
+		header, _ := EncodeChunkPayload(q.payloadFormat, &ChunkPayload{File: q.fileID, Name: chunkName})
+		capacity = qrcode.MaxCapacity(version, q.recoveryLevel, qrcode.ModeByte) - len(header) - 4*q.nextHints
+
+		// Base64 stores 3 bytes in 4 characters
+		if q.payloadFormat == PayloadFormatText {
+			capacity = capacity / 4 * 3
+		}
+	}
+
+	// A parity chunk holds the largest chunk of its group and a header
+	if q.parity > 0 {
+		capacity -= parityChunkOverhead
+	}
+
+	return max(capacity-split.ChecksumSize, 1)
+}
+
+// codecCapacity returns the most bytes of chunk data the payload p holds in a QR
+// code of version when encoded with the codec of q
+func (q *QRFileTransfer) codecCapacity(p *ChunkPayload, version int) int {
+	fits := func(n int) bool {
+		p.Data = bytes.Repeat([]byte{0xff}, n)
+
+		content, err := q.encodePayload(p)
+		if err != nil {
+			return false
+		}
+
+		code, err := newQRCode(PayloadFormatText, content, q.recoveryLevel)
+
+		return err == nil && code.VersionNumber <= version
+	}
+
+	return sort.Search(qrcode.MaxBytes(version, q.recoveryLevel)+1, func(n int) bool { return !fits(n) }) - 1
+}
+
+// maxQRVersion is the largest QR code version
+const maxQRVersion = 40
+
+// reduceChunkSize returns the reduction of the chunk size of a file of fileSize
+// bytes split into numChunks chunks made when a chunk does not fit in a QR code
+func reduceChunkSize(fileSize int64, numChunks int) ChunkSizeReduction {
+	reduced := numChunks + max(1, numChunks/3)
+
+	return ChunkSizeReduction{
+		FromChunks:    numChunks,
+		ToChunks:      reduced,
+		FromChunkSize: chunkSizeFor(fileSize, numChunks),
+		ToChunkSize:   chunkSizeFor(fileSize, reduced),
+		Reason:        qrcode.ErrContentTooLong.Error(),
+	}
+}
+
+// maxChunkSizeReductions bounds how often FileToQRCodes reduces the chunk size of a run
+const maxChunkSizeReductions = 10
+
+// encodeFileChunks splits file, an archive of dir if dir is not nil, into numChunks
+// chunks in tempDir, plans them in session, and generates their QR codes and data
+// files in workDir. Artifacts of a previous run for the same input and settings are
+// reused.
+func (q *QRFileTransfer) encodeFileChunks(file afero.File, dir os.FileInfo, workDir, tempDir string, session *Session, numChunks int) error {
+	// Start from an empty temporary directory, a previous attempt may have left chunks
+	if err := q.fs.RemoveAll(tempDir); err != nil {
+		return fmt.Errorf("failed to clean up temporary directory: %w", err)
+	}
+
+	if err := q.fs.MkdirAll(tempDir, 0755); err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	stat, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to list chunk files: %w", err)
+		return fmt.Errorf("failed to get file stats: %w", err)
+	}
+
+	// Split the file into chunks, recording the attributes of the directory of an archive
+	opts := q.splitOptions(file.Name(), stat.Size(), numChunks)
+	opts.Mode, opts.ModTime = stat.Mode(), stat.ModTime()
+
+	if dir != nil {
+		opts.Mode, opts.ModTime = dir.Mode(), dir.ModTime()
 	}
 
-	// Also include the first chunk which has a .tmp extension
-	firstChunk, err := filepath.Glob(filepath.Join(tempDir, "*.tmp"))
+	splitResult, err := q.splitter.SplitReaderCtx(q.context(), file, stat.Size(), file.Name(), tempDir, opts)
 	if err != nil {
-		return fmt.Errorf("failed to find first chunk: %w", err)
+		return fmt.Errorf("failed to split file: %w", err)
 	}
 
-	if len(firstChunk) > 0 {
-		chunkFiles = append(firstChunk, chunkFiles...)
+	chunkFiles := splitResult.Chunks
+
+	// Plan the session: every chunk with the hash of its data
+	session.Settings = q.sessionSettings(splitResult.File.Total)
+	session.Chunks = nil
+	session.Parity = nil
+	session.Refs = nil
+
+	for index, ref := range splitResult.File.Refs {
+		session.Refs = append(session.Refs, ChunkRef{Index: index, Ref: ref})
 	}
 
-	// Convert each chunk to a QR code and store raw data
+	slices.SortFunc(session.Refs, func(a, b ChunkRef) int { return a.Index - b.Index })
+
 	for _, chunkPath := range chunkFiles {
-		// Read the chunk
-		chunkData, err := os.ReadFile(chunkPath)
+		chunkData, err := afero.ReadFile(q.fs, chunkPath)
 		if err != nil {
 			return fmt.Errorf("failed to read chunk %s: %w", chunkPath, err)
 		}
 
-		// Get the base name of the chunk file
 		baseName := filepath.Base(chunkPath)
-		baseNameWithoutExt := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+		session.Chunks = append(session.Chunks, SessionChunk{
+			Name: strings.TrimSuffix(baseName, filepath.Ext(baseName)),
+			Hash: hashBytes(chunkData),
+			Size: int64(len(chunkData)),
+		})
+	}
+
+	// Compute the parity chunks from the data chunks
+	var parityFiles []string
+	if q.parity > 0 {
+		parityFiles, err = q.writeParityChunks(session, chunkFiles, filepath.Join(tempDir, session.Layout.Parity), q.parity)
+		if err != nil {
+			return err
+		}
+	}
+
+	indices := session.chunkIndices()
+	session.assignVolumes()
 
-		// Create a QR code file name with the same naming convention
-		qrFileName := baseNameWithoutExt + ".png"
-		qrFilePath := filepath.Join(qrDir, qrFileName)
+	if q.contentAddressed {
+		if err := q.addressChunks(session, indices, chunkFiles, parityFiles); err != nil {
+			return err
+		}
+	}
 
-		// Create a data file name with the same naming convention
-		dataFileName := baseNameWithoutExt + ".dat"
-		dataFilePath := filepath.Join(dataDir, dataFileName)
+	// Resume a previous run for the same input and settings, otherwise start over
+	previous := loadPreviousSession(q.fs, workDir)
+	resume := previous != nil && previous.matches(session.File.Hash, session.Settings)
+
+	if previous != nil && !resume {
+		if err := removeStaleArtifacts(q.fs, previous, session, workDir); err != nil {
+			return err
+		}
+	}
+
+	if err := session.makeVolumeDirs(q.fs, workDir); err != nil {
+		return err
+	}
 
-		// Create a QR code from the chunk data
-		// For binary data, we need to use a string representation
-		// This is a limitation of the QR code package
-		// Encode the binary data as base64 string
-		encodedData := base64.StdEncoding.EncodeToString(chunkData)
-		qrContent := fmt.Sprintf("Chunk: %s\nData: %s", baseNameWithoutExt, encodedData)
+	// Record the plan before generating anything so an interrupted run can be resumed
+	if err := session.save(q.fs, workDir); err != nil {
+		return err
+	}
+
+	// Collect the chunks that still need a QR code and data file
+	var jobs []chunkJob
+
+	for i, chunkPath := range chunkFiles {
+		chunk := &session.Chunks[i]
+		qrName, dataName := session.artifactNames(chunk)
+		job := chunkJob{
+			chunkPath:     chunkPath,
+			name:          chunk.Name,
+			qrFilePath:    filepath.Join(workDir, session.volumeDir(chunk, session.Layout.QRCodes), qrName+q.imageFormat.Ext()),
+			qrVersion:     &session.Chunks[i].QRVersion,
+			recoveryLevel: &session.Chunks[i].RecoveryLevel,
+			next:          nextHints(indices, i, q.nextHints),
+		}
+
+		if session.Layout.Data != "" {
+			job.dataFilePath = filepath.Join(workDir, session.volumeDir(chunk, session.Layout.Data), dataName+".dat")
+		}
 
-		qrCode, err := qrcode.New(qrContent, q.recoveryLevel)
+		if q.checksumCaption {
+			job.caption = q.chunkCaption(indices[i], session.Chunks[i].Hash)
+		}
+
+		if session.Layout.Text != "" {
+			job.textFilePath = filepath.Join(workDir, session.volumeDir(chunk, session.Layout.Text), qrName+TextChunkExt)
+			job.text = &TextChunk{File: session.File.Name, Name: job.name, Index: indices[i], Total: session.Settings.NumChunks}
+		}
+
+		// Skip chunks whose artifacts were already produced by a previous run
+		if resume && q.reuseChunk(job, &session.Chunks[i], previous.chunk(job.name)) {
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	for i, parityPath := range parityFiles {
+		chunk := &session.Parity[i]
+		qrName, dataName := session.artifactNames(chunk)
+		job := chunkJob{
+			chunkPath:     parityPath,
+			name:          chunk.Name,
+			qrFilePath:    filepath.Join(workDir, session.volumeDir(chunk, session.Layout.QRCodes), qrName+q.imageFormat.Ext()),
+			dataFilePath:  filepath.Join(workDir, session.volumeDir(chunk, session.Layout.Parity), dataName+".dat"),
+			qrVersion:     &chunk.QRVersion,
+			recoveryLevel: &chunk.RecoveryLevel,
+		}
+
+		if q.checksumCaption {
+			job.caption = q.parityCaption(i, chunk.Hash)
+		}
+
+		if resume && q.reuseChunk(job, chunk, previous.parityChunk(job.name)) {
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	if resume {
+		total := len(chunkFiles) + len(parityFiles)
+		q.log().Info("resuming previous run", "dir", workDir, "done", total-len(jobs), "chunks", total)
+	}
+
+	// Convert each chunk to a QR code and store raw data
+	if err := q.encodeChunks(jobs); err != nil {
+		// Record the QR codes of the chunks encoded before the operation was
+		// canceled, so that the next run resumes from them
+		if q.context().Err() != nil {
+			_ = session.save(q.fs, workDir)
+		}
+
+		return err
+	}
+
+	q.log().Info("split file into QR codes", "file", session.File.Name, "chunks", len(chunkFiles), "parity", len(parityFiles), "dir", workDir)
+
+	return nil
+}
+
+// addressChunks records the SHA-256 of the QR code content of every chunk and
+// parity chunk of session, at indices in the file, which names their QR code
+// images with SetContentAddressed
+func (q *QRFileTransfer) addressChunks(session *Session, indices []int, chunkFiles, parityFiles []string) error {
+	address := func(chunk *SessionChunk, path string, next []int) error {
+		data, err := afero.ReadFile(q.fs, path)
 		if err != nil {
-			return fmt.Errorf("failed to create QR code for chunk %s: %w", chunkPath, err)
+			return fmt.Errorf("failed to read chunk %s: %w", path, err)
 		}
 
-		// Determine the QR code size to use
-		qrSize := q.qrSize
-		if q.autoAdjustQRSize {
-			// Calculate optimal QR code size based on chunk size
-			qrSize = q.calculateOptimalQRSize(len(chunkData))
+		content, err := q.encodePayload(&ChunkPayload{File: q.fileID, Name: chunk.Name, Data: data, Next: next})
+		if err != nil {
+			return fmt.Errorf("failed to encode payload for chunk %s: %w", path, err)
 		}
 
-		// Save the QR code to a file
-		if err := qrCode.WriteFile(qrSize, qrFilePath); err != nil {
-			return fmt.Errorf("failed to write QR code to file %s: %w", qrFilePath, err)
+		chunk.QRHash = hashBytes(content)
+
+		return nil
+	}
+
+	for i, path := range chunkFiles {
+		if err := address(&session.Chunks[i], path, nextHints(indices, i, q.nextHints)); err != nil {
+			return err
 		}
+	}
 
-		// Save the raw data to a file
-		if err := os.WriteFile(dataFilePath, chunkData, 0600); err != nil {
-			return fmt.Errorf("failed to write data to file %s: %w", dataFilePath, err)
+	for i, path := range parityFiles {
+		if err := address(&session.Parity[i], path, nil); err != nil {
+			return err
 		}
 	}
 
-	// Clean up temporary directory
-	if err := os.RemoveAll(tempDir); err != nil {
-		return fmt.Errorf("failed to clean up temporary directory: %w", err)
+	return nil
+}
+
+// splitOptions returns the options splitting the file name of fileSize bytes into
+// numChunks chunks of balanced sizes if the first chunk has room for the metadata
+// on top of its data, and otherwise into chunks of the same size, the first of
+// which holds the metadata in place of some data. With SetDedup the chunks are of
+// content-defined sizes up to the size of a balanced chunk, the first holding the
+// metadata alone.
+func (q *QRFileTransfer) splitOptions(name string, fileSize int64, numChunks int) split.SplitOptions {
+	name = filepath.Base(name)
+	metaSize := int64(split.MetadataSize(name))
+
+	if q.dedup {
+		return split.SplitOptions{
+			ChunkBytes: max(chunkSizeFor(fileSize, numChunks), metaSize+1) + split.ChecksumSize,
+			Dedup:      true,
+		}
+	}
+
+	// Chunks too small to hold the metadata in place of data are balanced too
+	chunkBytes := chunkSizeFor(fileSize+metaSize, numChunks) + split.ChecksumSize
+	if q.balancedChunksFit(name, fileSize, numChunks) || chunkBytes <= metaSize+split.ChecksumSize {
+		return split.SplitOptions{Chunks: numChunks}
+	}
+
+	return split.SplitOptions{ChunkBytes: chunkBytes}
+}
+
+// reuseChunk reports whether the artifacts of job, the chunk of the session, were
+// already produced by a previous run as prev, and takes over its QR code details if
+// so. Artifacts are written atomically, so their presence means they are complete.
+func (q *QRFileTransfer) reuseChunk(job chunkJob, chunk, prev *SessionChunk) bool {
+	if prev == nil || prev.Hash != chunk.Hash || prev.QRVersion == 0 || !fileExists(q.fs, job.qrFilePath) ||
+		(job.dataFilePath != "" && !fileExists(q.fs, job.dataFilePath)) || (job.text != nil && !fileExists(q.fs, job.textFilePath)) {
+		return false
+	}
+
+	chunk.QRVersion = prev.QRVersion
+	chunk.RecoveryLevel = prev.RecoveryLevel
+
+	return true
+}
+
+// chunkSizeFor returns the size of the largest chunk of a file of fileSize bytes
+// split into numChunks chunks, without metadata and checksum
+func chunkSizeFor(fileSize int64, numChunks int) int64 {
+	return (fileSize + int64(numChunks) - 1) / int64(numChunks)
+}
+
+// chunkJob describes the artifacts to generate for a single chunk
+type chunkJob struct {
+	chunkPath    string
+	name         string
+	qrFilePath   string
+	dataFilePath string
+	// qrVersion receives the version of the generated QR code
+	qrVersion *int
+	// recoveryLevel receives the name of the recovery level of the generated QR
+	// code with SetAutoRecoveryLevel
+	recoveryLevel *string
+	// next holds the next-up hints embedded in the payload
+	next []int
+	// verbatim stores the chunk in the QR code as it is instead of in a payload,
+	// as the frames of other protocols are
+	verbatim bool
+	// caption is printed below the QR code if not empty
+	caption string
+	// text describes the text fallback of the chunk written to textFilePath, nil
+	// if the text fallback is disabled
+	text         *TextChunk
+	textFilePath string
+}
+
+// encodeChunks generates the QR codes and data files of all jobs using a pool of
+// q.concurrency workers. Every job writes to its own deterministic paths, so the
+// output does not depend on the order in which workers finish.
+// The first error stops the remaining jobs from being started and is returned, as
+// does the context of the operation being done.
+func (q *QRFileTransfer) encodeChunks(jobs []chunkJob) error {
+	ctx := q.context()
+
+	workers := q.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		failed   = make(chan struct{})
+		queue    = make(chan chunkJob)
+	)
+
+	for range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range queue {
+				err := ctx.Err()
+				if err == nil {
+					err = q.encodeChunk(job)
+				}
+
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(failed)
+					})
+				}
+			}
+		}()
 	}
 
+feed:
+	for _, job := range jobs {
+		select {
+		case queue <- job:
+		case <-failed:
+			break feed
+		}
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+// encodeChunk converts a single chunk to a QR code and stores its raw data
+func (q *QRFileTransfer) encodeChunk(job chunkJob) error {
+	// Read the chunk
+	chunkData, err := afero.ReadFile(q.fs, job.chunkPath)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk %s: %w", job.chunkPath, err)
+	}
+
+	// Create a QR code from the chunk payload
+	// Binary payloads are stored verbatim in a single byte mode segment
+	qrContent := chunkData
+	if !job.verbatim {
+		qrContent, err = q.encodePayload(&ChunkPayload{File: q.fileID, Name: job.name, Data: chunkData, Next: job.next})
+		if err != nil {
+			return fmt.Errorf("failed to encode payload for chunk %s: %w", job.chunkPath, err)
+		}
+	}
+
+	qrCode, err := q.newChunkQRCode(qrContent)
+	if err != nil {
+		return fmt.Errorf("failed to create QR code for chunk %s: %w", job.chunkPath, err)
+	}
+
+	qrCode.SetOptions(q.qrOptions)
+
+	*job.qrVersion = qrCode.VersionNumber
+	if q.autoRecoveryLevel {
+		*job.recoveryLevel = recoveryLevelNames[qrCode.Level]
+	}
+
+	if q.qrCodeObserver != nil {
+		q.qrCodeObserver(job.name, qrCode)
+	}
+
+	// Determine the QR code size to use
+	qrSize := q.qrSize
+	if q.autoAdjustQRSize {
+		// Calculate optimal QR code size based on chunk size
+		qrSize = q.calculateOptimalQRSize(qrCode.VersionNumber)
+	}
+
+	if modules := len(qrCode.Bitmap()); q.imageFormat != ImageFormatSVG && qrSize < minPixelsPerModule*modules {
+		q.diagnostics.Warnf(diagnostics.CodeLowDensity, job.name,
+			"QR code version %d drawn at %d pixels has %.1f pixels per module, raise the QR code size or lower the chunk size",
+			qrCode.VersionNumber, qrSize, float64(qrSize)/float64(modules))
+	}
+
+	// Save the QR code to a file
+	var img []byte
+	switch {
+	case q.imageFormat == ImageFormatSVG && job.caption != "":
+		img = captionSVG(qrCode.SVG(qrSize), qrSize, job.caption)
+	case q.imageFormat == ImageFormatSVG:
+		img = qrCode.SVG(qrSize)
+	case job.caption != "":
+		img, err = encodeImage(imaging.Caption(qrCode.Image(qrSize), job.caption), q.imageFormat, q.pngOptions)
+	default:
+		img, err = encodeImage(qrCode.Image(qrSize), q.imageFormat, q.pngOptions)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code for chunk %s: %w", job.chunkPath, err)
+	}
+
+	if err := WriteFileAtomic(q.fs, job.qrFilePath, img, 0644); err != nil {
+		return fmt.Errorf("failed to write QR code to file %s: %w", job.qrFilePath, err)
+	}
+
+	// Save the raw data to a file
+	if job.dataFilePath != "" {
+		if err := WriteFileAtomic(q.fs, job.dataFilePath, chunkData, 0600); err != nil {
+			return fmt.Errorf("failed to write data to file %s: %w", job.dataFilePath, err)
+		}
+	}
+
+	// Save the text fallback to a file
+	if job.text != nil {
+		text := *job.text
+		text.Data = chunkData
+
+		if err := WriteFileAtomic(q.fs, job.textFilePath, EncodeTextChunk(&text), 0600); err != nil {
+			return fmt.Errorf("failed to write text to file %s: %w", job.textFilePath, err)
+		}
+	}
+
+	q.log().Debug("encoded chunk", "chunk", job.name, "version", qrCode.VersionNumber, "size", len(chunkData))
+
 	return nil
 }
 
+// newQRCode creates the QR code of a chunk payload in the given format. The frames
+// of ProtocolStructuredAppend are held in the structured append header and data,
+// and text payloads holding international text are encoded with qrcode.NewText.
+func newQRCode(format PayloadFormat, content []byte, level qrcode.RecoveryLevel) (*qrcode.QRCode, error) {
+	if format == PayloadFormatBinary {
+		if sa, data, err := parseStructuredAppendFrame(content); err == nil {
+			return qrcode.NewStructuredAppend(data, level, sa)
+		}
+
+		return qrcode.NewBytes(content, level)
+	}
+
+	text := string(content)
+
+	code, err := qrcode.New(text, level)
+	if !utf8.ValidString(text) || strings.IndexFunc(text, func(r rune) bool { return r >= utf8.RuneSelf }) < 0 {
+		return code, err
+	}
+
+	// International text states its character set, or is held in Kanji mode, where
+	// that takes no larger QR code, so chunks are packed alike
+	if textCode, textErr := qrcode.NewText(text, level); textErr == nil && (err != nil || textCode.VersionNumber <= code.VersionNumber) {
+		return textCode, nil
+	}
+
+	return code, err
+}
+
+// newChunkQRCode creates the QR code of the payload of a chunk, at the highest
+// recovery level that fits with SetAutoRecoveryLevel, and at the target version
+// with SetUniformQRVersion. An error wrapping
+// qrcode.ErrContentTooLong is returned if it needs a version above SetTargetQRVersion.
+func (q *QRFileTransfer) newChunkQRCode(content []byte) (*qrcode.QRCode, error) {
+	qrCode, err := newQRCode(q.contentFormat(), content, q.recoveryLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.targetQRVersion > 0 && qrCode.VersionNumber > q.targetQRVersion {
+		return nil, fmt.Errorf("%w: QR code version %d exceeds the target version %d",
+			qrcode.ErrContentTooLong, qrCode.VersionNumber, q.targetQRVersion)
+	}
+
+	if !q.autoRecoveryLevel {
+		return q.uniformVersion(qrCode)
+	}
+
+	// Chunks are packed to the target version, a higher level costs no extra QR code
+	maxVersion := q.targetQRVersion
+	if maxVersion == 0 {
+		maxVersion = maxQRVersion
+	}
+
+	for level := qrcode.Highest; level > q.recoveryLevel; level-- {
+		if c, err := newQRCode(q.contentFormat(), content, level); err == nil && c.VersionNumber <= maxVersion {
+			return q.uniformVersion(c)
+		}
+	}
+
+	return q.uniformVersion(qrCode)
+}
+
+// uniformVersion returns code at the target version with SetUniformQRVersion
+func (q *QRFileTransfer) uniformVersion(code *qrcode.QRCode) (*qrcode.QRCode, error) {
+	if !q.uniformQRVersion || q.targetQRVersion == 0 {
+		return code, nil
+	}
+
+	if err := code.SetVersion(q.targetQRVersion); err != nil {
+		return nil, err
+	}
+
+	return code, nil
+}
+
+// encodePNG encodes an image of QR codes, e.g. with a caption, with the PNG
+// options
+func encodePNG(img image.Image, options qrcode.WriteFileOptions) ([]byte, error) {
+	var b bytes.Buffer
+	if err := qrcode.EncodePNG(&b, img, options); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
 // QRCodesToFile reconstructs a file from a series of QR codes and their associated data files
+// The input directory is opened with OpenSession, so archives created before session
+// files existed remain restorable. The mode and modification time of the original
+// file are restored when the split metadata records them. Nothing is written to
+// inDir, the chunks are merged in a temporary directory.
 // Parameters:
 //   - inDir: Directory containing the QR codes and data files
 //   - outFilePath: Path to save the reconstructed file
 //
 // Returns an error if any part of the process fails.
 func (q *QRFileTransfer) QRCodesToFile(inDir string, outFilePath string) (err error) {
-	// Create a temporary directory for chunks
-	tempDir := filepath.Join(inDir, "temp")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
+	// Merge the chunks in a temporary directory of the workspace, as inDir may be
+	// read-only, e.g. a mounted DVD or another user's directory
+	tempDir, releaseTemp, err := q.makeTempDir("qrcode_merge_*")
+	if err != nil {
 		return fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 
 	defer func() {
-		removeErr := os.RemoveAll(tempDir)
+		removeErr := releaseTemp()
 		if removeErr != nil && err == nil {
 			err = fmt.Errorf("failed to remove temporary directory: %w", removeErr)
 		}
 	}()
 
-	// Get all data files
-	dataDir := filepath.Join(inDir, "data")
+	// Locate the data files through the session, converting legacy archives on the fly
+	session, err := openSession(q.fs, inDir)
+	if err != nil {
+		return err
+	}
+
+	// The frames of other protocols are reassembled as a whole
+	if f, err := q.sessionFramer(session); err != nil {
+		return err
+	} else if f != nil {
+		return q.framesToFile(f, session, outFilePath)
+	}
+
+	// Check the chunks against the manifest, if the archive has one
+	manifest, err := loadManifest(q.fs, inDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
 
-	dataFiles, err := filepath.Glob(filepath.Join(dataDir, "*.dat"))
+	// Restore missing or damaged chunks from the parity chunks, if the archive has any
+	repaired, err := q.repairChunks(session, manifest)
 	if err != nil {
-		return fmt.Errorf("failed to list data files: %w", err)
+		return fmt.Errorf("failed to restore chunks from parity: %w", err)
 	}
 
-	if len(dataFiles) == 0 {
-		return fmt.Errorf("no data files found in %s", dataDir)
+	if !session.Complete {
+		if report, verifyErr := q.VerifyChunks(inDir); verifyErr == nil && len(report.Missing) > 0 {
+			return fmt.Errorf("session in %s is incomplete: %w", inDir, &ErrMissingChunk{Index: report.Missing[0]})
+		}
+
+		return fmt.Errorf("session in %s is incomplete", inDir)
 	}
 
-	// Process each data file
-	for _, dataFilePath := range dataFiles {
-		// Read the data file
-		chunkData, err := os.ReadFile(dataFilePath)
+	// Process each chunk of the session
+	for _, chunk := range session.Chunks {
+		if err := q.context().Err(); err != nil {
+			return err
+		}
+
+		dataFilePath := session.DataFile(chunk.Name)
+		if dataFilePath == "" && session.Settings.NoRawData {
+			return fmt.Errorf("%w: session in %s was split without them, decode its QR codes instead", ErrNoRawData, inDir)
+		} else if dataFilePath == "" {
+			return fmt.Errorf("session in %s has no data directory", inDir)
+		}
+
+		// Read the data file, unless the chunk was restored
+		chunkData, ok := repaired[chunk.Name]
+		if !ok {
+			chunkData, err = afero.ReadFile(q.fs, dataFilePath)
+		}
+
 		if err != nil {
+			if indices := session.dataFileIndices(dataFilePath); len(indices) > 0 && errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to read data file %s: %w", dataFilePath, &ErrMissingChunk{Index: indices[0]})
+			}
+
 			return fmt.Errorf("failed to read data file %s: %w", dataFilePath, err)
 		}
 
-		// Get the base name of the data file
-		baseName := filepath.Base(dataFilePath)
-		baseNameWithoutExt := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+		if manifest != nil {
+			if err := manifest.verifyChunk(chunk.Name, chunkData); err != nil {
+				return err
+			}
+		}
 
 		// All chunks should have .part extension
 		// The first chunk is identified by its index (0), not by its extension
-		chunkFilePath := filepath.Join(tempDir, baseNameWithoutExt+".part")
+		chunkFilePath := filepath.Join(tempDir, chunk.Name+".part")
 
 		// Write the chunk data to a file
-		if err := os.WriteFile(chunkFilePath, chunkData, 0600); err != nil {
+		if err := afero.WriteFile(q.fs, chunkFilePath, chunkData, 0600); err != nil {
 			return fmt.Errorf("failed to write chunk to file %s: %w", chunkFilePath, err)
 		}
 	}
 
-	// Merge the chunks to reconstruct the original file
-	if err := q.splitter.MergeFile(tempDir); err != nil {
-		return fmt.Errorf("failed to merge chunks: %w", err)
+	if len(session.Chunks) == 0 {
+		return fmt.Errorf("session in %s has no chunks", inDir)
 	}
 
-	// Find the reconstructed file in the temp directory
-	files, err := os.ReadDir(tempDir)
-	if err != nil {
-		return fmt.Errorf("failed to read temporary directory: %w", err)
-	}
+	return q.mergeChunks(tempDir, outFilePath)
+}
 
-	var reconstructedFile string
+// mergeChunks merges the .part chunk files in tempDir into outFilePath and restores
+// the attributes of the original file recorded in the metadata of the first chunk.
+// The file is written to outFilePath completely or not at all.
+func (q *QRFileTransfer) mergeChunks(tempDir, outFilePath string) error {
+	var (
+		merged   *split.MergeResult
+		mergeErr error
+	)
 
-	for _, file := range files {
-		if !file.IsDir() && !strings.HasSuffix(file.Name(), ".part") && !strings.HasSuffix(file.Name(), ".tmp") {
-			reconstructedFile = filepath.Join(tempDir, file.Name())
+	mergeFile := func(w io.Writer) error {
+		// The chunks are removed along with tempDir
+		merged, mergeErr = q.splitter.MergeFileWith(q.context(), tempDir, split.MergeOptions{KeepChunks: true, Output: w})
 
-			break
-		}
+		return mergeErr
 	}
 
-	if reconstructedFile == "" {
-		return fmt.Errorf("reconstructed file not found")
+	restoreInfo := func(tmpPath string) error {
+		return q.splitter.RestoreFileInfo(tmpPath, merged.File)
 	}
 
-	// Copy the reconstructed file to the output path
-	srcFile, err := os.Open(reconstructedFile)
-	if err != nil {
-		return fmt.Errorf("failed to open reconstructed file: %w", err)
-	}
-
-	defer func() {
-		closeErr := srcFile.Close()
-		if closeErr != nil && err == nil {
-			err = fmt.Errorf("failed to close source file: %w", closeErr)
+	if err := split.PublishFile(q.fs, outFilePath, 0644, mergeFile, restoreInfo); err != nil {
+		if mergeErr != nil {
+			return fmt.Errorf("failed to merge chunks: %w", mergeErr)
 		}
-	}()
 
-	dstFile, err := os.Create(outFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+		return fmt.Errorf("failed to write output file: %w", err)
 	}
 
-	defer func() {
-		closeErr := dstFile.Close()
-		if closeErr != nil && err == nil {
-			err = fmt.Errorf("failed to close destination file: %w", closeErr)
-		}
-	}()
-
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return fmt.Errorf("failed to copy reconstructed file: %w", err)
-	}
+	q.log().Info("reconstructed file", "file", merged.File.Name, "path", outFilePath, "size", merged.File.Size)
 
 	return nil
 }
@@ -1,9 +1,32 @@
 package qrfiletransfer
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"log/slog"
+	"math/rand"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/diagnostics"
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/spf13/afero"
 )
 
 func TestQRFileTransfer(t *testing.T) {
@@ -106,3 +129,1513 @@ func TestQRFileTransfer(t *testing.T) {
 			testContent, string(reconstructedContent))
 	}
 }
+
+func TestFileToQRCodesResume(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Create a test file large enough to produce several chunks
+	testFilePath := filepath.Join(testDir, "resume.txt")
+	testContent := strings.Repeat("Resumable QR file transfer content. ", 100)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(1000)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if len(session.Chunks) < 3 {
+		t.Fatalf("Expected at least 3 chunks, got %d", len(session.Chunks))
+	}
+
+	// Simulate an interrupted run by removing the QR code of the last chunk
+	last := session.Chunks[len(session.Chunks)-1].Name
+	lastQR := filepath.Join(outDir, "qrcodes", last+".png")
+
+	if err := os.Remove(lastQR); err != nil {
+		t.Fatalf("Failed to remove QR code: %v", err)
+	}
+
+	// Remember the modification time of a chunk that must not be regenerated
+	kept := filepath.Join(outDir, "qrcodes", session.Chunks[1].Name+".png")
+	old := time.Now().Add(-time.Hour)
+
+	if err := os.Chtimes(kept, old, old); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes resume failed: %v", err)
+	}
+
+	if _, err := os.Stat(lastQR); err != nil {
+		t.Fatalf("Missing QR code was not regenerated: %v", err)
+	}
+
+	info, err := os.Stat(kept)
+	if err != nil {
+		t.Fatalf("Failed to stat QR code: %v", err)
+	}
+
+	if !info.ModTime().Equal(old) {
+		t.Fatal("Unchanged QR code was regenerated")
+	}
+}
+
+func TestFileToQRCodesConcurrency(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "parallel.txt")
+	testContent := strings.Repeat("Parallel QR generation must be deterministic. ", 200)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Encode the same file serially and with a worker pool
+	serialDir := filepath.Join(testDir, "serial")
+	parallelDir := filepath.Join(testDir, "parallel")
+
+	qrft := NewQRFileTransfer()
+	qrft.SetConcurrency(1)
+
+	if err := qrft.FileToQRCodes(testFilePath, serialDir); err != nil {
+		t.Fatalf("FileToQRCodes (serial) failed: %v", err)
+	}
+
+	qrft.SetConcurrency(4)
+
+	if err := qrft.FileToQRCodes(testFilePath, parallelDir); err != nil {
+		t.Fatalf("FileToQRCodes (parallel) failed: %v", err)
+	}
+
+	serial, err := LoadSession(serialDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	// The first chunk carries a timestamp, every other QR code must be identical
+	for _, chunk := range serial.Chunks[1:] {
+		want, err := os.ReadFile(filepath.Join(serialDir, "qrcodes", chunk.Name+".png"))
+		if err != nil {
+			t.Fatalf("Failed to read serial QR code: %v", err)
+		}
+
+		got, err := os.ReadFile(filepath.Join(parallelDir, "qrcodes", chunk.Name+".png"))
+		if err != nil {
+			t.Fatalf("Failed to read parallel QR code: %v", err)
+		}
+
+		if !bytes.Equal(want, got) {
+			t.Fatalf("QR code %s differs between serial and parallel runs", chunk.Name)
+		}
+	}
+}
+
+func TestFileToQRCodesLowDensity(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "dense.txt")
+	if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Dense QR codes. ", 150)), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	collector := diagnostics.NewCollector()
+
+	qrft := NewQRFileTransfer()
+	qrft.SetDiagnostics(collector)
+
+	if err := qrft.FileToQRCodes(testFilePath, filepath.Join(testDir, "default")); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	if d := collector.Diagnostics(); len(d) != 0 {
+		t.Fatalf("Default settings reported %+v", d)
+	}
+
+	// Large chunks drawn at a small fixed size leave too few pixels per module
+	qrft.SetAutoAdjustQRSize(false)
+	qrft.SetQRSize(200)
+
+	if err := qrft.FileToQRCodes(testFilePath, filepath.Join(testDir, "small")); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	d := collector.Diagnostics()
+	if len(d) == 0 || d[0].Code != diagnostics.CodeLowDensity || d[0].Severity != diagnostics.Warning {
+		t.Fatalf("Diagnostics() = %+v, want low-density warnings", d)
+	}
+}
+
+func TestFileToQRCodesTinyFiles(t *testing.T) {
+	for _, size := range []int{0, 1, 32, 500} {
+		testDir := t.TempDir()
+
+		content := bytes.Repeat([]byte{0xA5}, size)
+		testFilePath := filepath.Join(testDir, "secret.key")
+
+		if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		outDir := filepath.Join(testDir, "session")
+		qrft := NewQRFileTransfer()
+
+		if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+			t.Fatalf("FileToQRCodes of %d bytes failed: %v", size, err)
+		}
+
+		// The metadata and the whole file fit in a single QR code
+		session, err := LoadSession(outDir)
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		if len(session.Chunks) != 1 {
+			t.Fatalf("File of %d bytes was split into %d chunks, want 1", size, len(session.Chunks))
+		}
+
+		payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(session.Chunks[0].Name)))
+		if err != nil {
+			t.Fatalf("DecodePayload failed: %v", err)
+		}
+
+		data, err := os.ReadFile(session.DataFile(session.Chunks[0].Name))
+		if err != nil || !bytes.Equal(payload.Data, data) {
+			t.Fatalf("QR code of a %d byte file does not hold its data file: %v", size, err)
+		}
+
+		reconstructed := filepath.Join(testDir, "reconstructed.key")
+		if err := qrft.QRCodesToFile(outDir, reconstructed); err != nil {
+			t.Fatalf("QRCodesToFile of %d bytes failed: %v", size, err)
+		}
+
+		if got, err := os.ReadFile(reconstructed); err != nil || !bytes.Equal(got, content) {
+			t.Fatalf("Reconstructed file of %d bytes differs: %v", size, err)
+		}
+	}
+}
+
+func TestQRCodesToFileRestoresAttributes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows files have no executable bit")
+	}
+
+	testDir := t.TempDir()
+
+	// An executable script loses its meaning without the executable bit
+	testFilePath := filepath.Join(testDir, "install.sh")
+	if err := os.WriteFile(testFilePath, []byte("#!/bin/sh\necho installed\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if err := os.Chmod(testFilePath, 0755); err != nil {
+		t.Fatalf("Failed to change test file mode: %v", err)
+	}
+
+	modTime := time.Date(2023, 7, 1, 12, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(testFilePath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to change test file time: %v", err)
+	}
+
+	var logs bytes.Buffer
+
+	qrft := NewQRFileTransfer()
+	qrft.SetLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	outDir := filepath.Join(testDir, "session")
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	outputFile := filepath.Join(testDir, "received.sh")
+	if err := qrft.QRCodesToFile(outDir, outputFile); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	for _, msg := range []string{`msg="split file into QR codes" file=install.sh`, `msg="merge successful"`, `msg="reconstructed file" file=install.sh`} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("Logs lack %s: %s", msg, logs.String())
+		}
+	}
+
+	stat, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to stat reconstructed file: %v", err)
+	}
+
+	if stat.Mode().Perm() != 0755 || !stat.ModTime().Equal(modTime) {
+		t.Errorf("Reconstructed file mode %v, mtime %v, want %v, %v", stat.Mode(), stat.ModTime(), os.FileMode(0755), modTime)
+	}
+}
+
+func TestQRFileTransferInMemory(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	// A path that does not exist on disk, so any write outside fs is caught
+	root := filepath.Join(os.TempDir(), "qrfiletransfer-memfs-"+strconv.FormatInt(time.Now().UnixNano(), 36))
+	content := bytes.Repeat([]byte("in memory transfer "), 200)
+
+	if err := afero.WriteFile(fs, filepath.Join(root, "input.txt"), content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+	outDir := filepath.Join(root, "session")
+
+	if err := qrft.FileToQRCodes(filepath.Join(root, "input.txt"), outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	qrFiles, err := afero.Glob(fs, filepath.Join(outDir, "qrcodes", "*.png"))
+	if err != nil || len(qrFiles) < 2 {
+		t.Fatalf("Found QR codes %v (%v), want several", qrFiles, err)
+	}
+
+	outputFile := filepath.Join(root, "output.txt")
+	if err := qrft.QRCodesToFile(outDir, outputFile); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	got, err := afero.ReadFile(fs, outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed %d bytes, want %d", len(got), len(content))
+	}
+
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("Transfer wrote %s to disk: %v", root, err)
+	}
+}
+
+func TestFileToQRCodesWithoutRawData(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("no sidecar "), 200)
+
+	if err := afero.WriteFile(fs, "/in/input.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+
+	// A session split with its data files loses them when split again without
+	for _, emit := range []bool{true, false} {
+		qrft.SetEmitRawData(emit)
+
+		if err := qrft.FileToQRCodes("/in/input.txt", "/out"); err != nil {
+			t.Fatalf("FileToQRCodes failed: %v", err)
+		}
+	}
+
+	session, err := loadSession(fs, "/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.DataDir() != "" || !session.Settings.NoRawData {
+		t.Errorf("Session has data directory %q", session.DataDir())
+	}
+
+	if dataFiles, _ := afero.Glob(fs, "/out/data/*.dat"); len(dataFiles) != 0 {
+		t.Errorf("Data files %v were left behind", dataFiles)
+	}
+
+	if qrFiles, _ := afero.Glob(fs, "/out/qrcodes/*.png"); len(qrFiles) != len(session.Chunks) {
+		t.Errorf("Found %d QR codes, want %d", len(qrFiles), len(session.Chunks))
+	}
+
+	if err := qrft.QRCodesToFile("/out", "/received.txt"); !errors.Is(err, ErrNoRawData) {
+		t.Errorf("Expected ErrNoRawData, got %v", err)
+	}
+}
+
+func TestFileToQRCodesDedup(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	// A sparse file repeating a block between runs of zeros
+	block := make([]byte, 2000)
+	rand.New(rand.NewSource(3)).Read(block)
+
+	var content []byte
+	for range 3 {
+		content = append(content, block...)
+		content = append(content, make([]byte, 20000)...)
+	}
+
+	if err := afero.WriteFile(fs, "/in/sparse.img", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetMaxChunkSize(500)
+	qrft.SetDedup(true)
+	qrft.SetTextFallback(true)
+	qrft.SetNextHints(1)
+
+	if err := qrft.FileToQRCodes("/in/sparse.img", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := loadSession(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Far fewer QR codes than chunks of balanced sizes
+	if len(session.Refs) == 0 || len(session.Chunks) > len(content)/500/4 || len(session.Chunks)+len(session.Refs) != session.Settings.NumChunks {
+		t.Fatalf("Session has %d chunks and %d duplicated of %d", len(session.Chunks), len(session.Refs), session.Settings.NumChunks)
+	}
+
+	if qrFiles, _ := afero.Glob(fs, "/session/qrcodes/*.png"); len(qrFiles) != len(session.Chunks) {
+		t.Errorf("Found %d QR codes, want %d", len(qrFiles), len(session.Chunks))
+	}
+
+	manifest, err := loadManifest(fs, "/session")
+	if err != nil || manifest.ChunkCount != session.Settings.NumChunks || len(manifest.Dedup) != len(session.Refs) {
+		t.Fatalf("Manifest = %+v (%v), want the duplicated chunks of the session", manifest, err)
+	}
+
+	// The manifest and the hints name the chunks by their index in the file
+	indices := session.chunkIndices()
+	for i, c := range manifest.Chunks {
+		if index, _ := chunkIndex(c.Name); c.Index != index || c.Index != indices[i] {
+			t.Errorf("Manifest chunk %s has index %d", c.Name, c.Index)
+		}
+	}
+
+	// Splitting again resumes the session, the chunks cut at the same boundaries
+	if err := qrft.FileToQRCodes("/in/sparse.img", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed to resume: %v", err)
+	}
+
+	if err := qrft.QRCodesToFile("/session", "/out/session.img"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if report, err := qrft.VerifyIntegrity("/session", nil); err != nil || !report.OK() {
+		t.Errorf("VerifyIntegrity() = %v, %v", report, err)
+	}
+
+	// A directory of the data files decoded from the QR codes alone
+	for _, c := range session.Chunks {
+		data, err := afero.ReadFile(fs, session.DataFile(c.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := afero.WriteFile(fs, filepath.Join("/received/data", c.Name+".dat"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := qrft.VerifyChunks("/received")
+	if err != nil || !report.Complete() || report.Total != session.Settings.NumChunks {
+		t.Errorf("VerifyChunks() = %v, %v, want %d chunks complete", report, err, session.Settings.NumChunks)
+	}
+
+	if err := qrft.QRCodesToFile("/received", "/out/received.img"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if err := qrft.TextToFile([]string{"/session"}, "/out/text.img"); err != nil {
+		t.Fatalf("TextToFile failed: %v", err)
+	}
+
+	for _, path := range []string{"/out/session.img", "/out/received.img", "/out/text.img"} {
+		if got, err := afero.ReadFile(fs, path); err != nil || !bytes.Equal(got, content) {
+			t.Errorf("Reconstructed %s differs from the original (%v)", path, err)
+		}
+	}
+
+	qrft.SetParity(10)
+
+	if err := qrft.FileToQRCodes("/in/sparse.img", "/parity"); err == nil {
+		t.Error("FileToQRCodes() protected deduplicated chunks with parity")
+	}
+}
+
+// readOnlyDirFs is a file system on which nothing can be written under dir, like
+// a mounted DVD
+type readOnlyDirFs struct {
+	afero.Fs
+	dir string
+}
+
+func (fs readOnlyDirFs) check(name string) error {
+	if name == fs.dir || strings.HasPrefix(name, fs.dir+string(filepath.Separator)) {
+		return &os.PathError{Op: "write", Path: name, Err: os.ErrPermission}
+	}
+
+	return nil
+}
+
+func (fs readOnlyDirFs) Create(name string) (afero.File, error) {
+	if err := fs.check(name); err != nil {
+		return nil, err
+	}
+
+	return fs.Fs.Create(name)
+}
+
+func (fs readOnlyDirFs) Mkdir(name string, perm os.FileMode) error {
+	if err := fs.check(name); err != nil {
+		return err
+	}
+
+	return fs.Fs.Mkdir(name, perm)
+}
+
+func (fs readOnlyDirFs) MkdirAll(name string, perm os.FileMode) error {
+	if err := fs.check(name); err != nil {
+		return err
+	}
+
+	return fs.Fs.MkdirAll(name, perm)
+}
+
+func (fs readOnlyDirFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) != 0 {
+		if err := fs.check(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs.Fs.OpenFile(name, flag, perm)
+}
+
+func TestQRCodesToFileReadOnlyInput(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("read from a DVD "), 200)
+
+	if err := afero.WriteFile(fs, "/in/input.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+
+	if err := qrft.FileToQRCodes("/in/input.txt", "/media/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	before := readTree(t, fs, "/media")
+
+	qrft.SetFs(readOnlyDirFs{Fs: fs, dir: "/media"})
+
+	if err := qrft.QRCodesToFile("/media/session", "/out/output.txt"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	got, err := afero.ReadFile(fs, "/out/output.txt")
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed %d bytes (%v), want %d", len(got), err, len(content))
+	}
+
+	if after := readTree(t, fs, "/media"); len(after) != len(before) {
+		t.Errorf("Decoding changed the input directory from %d to %d files", len(before), len(after))
+	}
+}
+
+// failingRenameFs is a file system on which renames fail, like a run interrupted
+// before the output is complete
+type failingRenameFs struct {
+	afero.Fs
+}
+
+func (fs failingRenameFs) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+}
+
+func TestQRCodesToFileAtomicOutput(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("all or nothing "), 200)
+
+	if err := afero.WriteFile(fs, "/in/input.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.maxChunkSize = 500
+
+	if err := qrft.FileToQRCodes("/in/input.txt", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	if err := afero.WriteFile(fs, "/out/output.txt", []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output is only replaced once it is complete
+	qrft.SetFs(failingRenameFs{fs})
+
+	if err := qrft.QRCodesToFile("/session", "/out/output.txt"); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("Expected the rename of the output to fail, got %v", err)
+	}
+
+	if got, _ := afero.ReadFile(fs, "/out/output.txt"); string(got) != "previous" {
+		t.Errorf("Output was changed to %d bytes by a failed reconstruction", len(got))
+	}
+
+	if entries, _ := afero.ReadDir(fs, "/out"); len(entries) != 1 {
+		t.Errorf("Failed reconstruction left %d files in the output directory", len(entries))
+	}
+
+	qrft.SetFs(fs)
+
+	if err := qrft.QRCodesToFile("/session", "/out/output.txt"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, _ := afero.ReadFile(fs, "/out/output.txt"); !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestFileToQRCodesDeterministic(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("reproducible archive "), 200)
+
+	if err := afero.WriteFile(fs, "/in/tree/input.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetDeterministic(true)
+	qrft.maxChunkSize = 500
+
+	// Touching the input between the runs changes none of the outputs
+	for i, outDir := range []string{"/out/1", "/out/2"} {
+		modTime := time.Date(2020+i, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, path := range []string{"/in/tree/input.txt", "/in/tree"} {
+			if err := fs.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := qrft.FileToQRCodes("/in/tree/input.txt", filepath.Join(outDir, "file")); err != nil {
+			t.Fatalf("FileToQRCodes failed: %v", err)
+		}
+
+		if err := qrft.DirToQRCodes("/in/tree", filepath.Join(outDir, "dir")); err != nil {
+			t.Fatalf("DirToQRCodes failed: %v", err)
+		}
+	}
+
+	first, second := readTree(t, fs, "/out/1"), readTree(t, fs, "/out/2")
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("Runs wrote %d and %d files", len(first), len(second))
+	}
+
+	for name, data := range first {
+		if !bytes.Equal(data, second[name]) {
+			t.Errorf("%s differs between runs", name)
+		}
+	}
+
+	info, err := qrft.ReadFileInfo("/out/1/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Time.IsZero() || !info.ModTime.IsZero() {
+		t.Errorf("Metadata records split time %v and modification time %v, want none", info.Time, info.ModTime)
+	}
+
+	// Omitted times are not restored
+	if err := qrft.QRCodesToDir("/out/1/dir", "/restored"); err != nil {
+		t.Fatalf("QRCodesToDir failed: %v", err)
+	}
+
+	stat, err := fs.Stat("/restored/input.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.ModTime().Unix() == 0 {
+		t.Errorf("Restored file has modification time %v", stat.ModTime())
+	}
+}
+
+// readTree returns the content of every file below dir by path relative to dir
+func readTree(t *testing.T, fs afero.Fs, dir string) map[string][]byte {
+	t.Helper()
+
+	files := make(map[string][]byte)
+
+	err := afero.Walk(fs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		data, err := afero.ReadFile(fs, path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		files[rel] = data
+
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestFileToQRCodesChecksumCaption(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "caption.txt")
+	if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Printed on paper. ", 100)), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(1000)
+	qrft.SetChecksumCaption(true)
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if !session.Settings.ChecksumCaption {
+		t.Error("Session does not record the checksum caption")
+	}
+
+	f, err := os.Open(session.QRCodeFile(session.Chunks[1].Name))
+	if err != nil {
+		t.Fatalf("Failed to open QR code: %v", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode QR code image: %v", err)
+	}
+
+	// The caption strip makes the image taller than wide, and the code still reads
+	if bounds := img.Bounds(); bounds.Dy() <= bounds.Dx() {
+		t.Errorf("Expected a caption strip below the QR code, image is %v", bounds)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		t.Fatalf("Failed to create binary bitmap: %v", err)
+	}
+
+	if _, err := zxingqrcode.NewQRCodeReader().Decode(bmp, nil); err != nil {
+		t.Errorf("Captioned QR code does not decode: %v", err)
+	}
+
+	if got, want := qrft.chunkCaption(1, session.Chunks[1].Hash), "#1 "+session.Chunks[1].Hash[:6]; got != want {
+		t.Errorf("chunkCaption() = %q, want %q", got, want)
+	}
+}
+
+func TestFileToQRCodesSVG(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "vector.txt")
+	if err := os.WriteFile(testFilePath, []byte(strings.Repeat("Drawn as vectors. ", 200)), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "session")
+	if err := NewQRFileTransfer().FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	// Switching the image format replaces the PNG images of the previous run
+	qrft := NewQRFileTransfer()
+	qrft.SetImageFormat(ImageFormatSVG)
+	qrft.SetChecksumCaption(true)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	pngs, _ := filepath.Glob(filepath.Join(session.QRCodesDir(), "*.png"))
+	if len(pngs) != 0 {
+		t.Errorf("Expected the PNG images to be removed, found %v", pngs)
+	}
+
+	for _, chunk := range session.Chunks {
+		path := session.QRCodeFile(chunk.Name)
+		if filepath.Ext(path) != ".svg" {
+			t.Fatalf("QRCodeFile() = %s, want an SVG image", path)
+		}
+
+		svg, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read SVG image: %v", err)
+		}
+
+		if !bytes.Contains(svg, []byte("<svg")) || !bytes.Contains(svg, []byte(chunk.Hash[:6])) {
+			t.Errorf("SVG image of chunk %s has no QR code or caption", chunk.Name)
+		}
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	if got := manifest.Chunks[0].QRCode; !strings.HasSuffix(got, ".svg") {
+		t.Errorf("Manifest lists QR code %s, want an SVG image", got)
+	}
+}
+
+func TestFileToQRCodesRasterFormats(t *testing.T) {
+	testDir := t.TempDir()
+
+	content := []byte(strings.Repeat("Shown on an e-ink display. ", 40))
+
+	testFilePath := filepath.Join(testDir, "display.txt")
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		format ImageFormat
+		ext    string
+		magic  string
+	}{
+		{ImageFormatJPEG, ".jpg", "\xff\xd8\xff"},
+		{ImageFormatBMP, ".bmp", "BM"},
+	}
+
+	for _, test := range tests {
+		qrft := NewQRFileTransfer()
+		qrft.SetImageFormat(test.format)
+		qrft.SetChecksumCaption(true)
+
+		outDir := filepath.Join(testDir, test.format.String())
+		if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+			t.Fatalf("FileToQRCodes() in %s failed: %v", test.format, err)
+		}
+
+		session, err := LoadSession(outDir)
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		if ImageFormat(session.Settings.ImageFormat) != test.format {
+			t.Errorf("Session image format %d, want %s", session.Settings.ImageFormat, test.format)
+		}
+
+		for _, chunk := range session.Chunks {
+			path := session.QRCodeFile(chunk.Name)
+			if filepath.Ext(path) != test.ext {
+				t.Fatalf("QRCodeFile() = %s, want a %s image", path, test.ext)
+			}
+
+			img, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read QR code image: %v", err)
+			}
+
+			if !bytes.HasPrefix(img, []byte(test.magic)) {
+				t.Errorf("QR code image %s starts with %q, want %q", path, img[:min(len(img), 4)], test.magic)
+			}
+		}
+
+		// BMP images decode, and JPEG images are blurred but still scan
+		if payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(session.Chunks[0].Name))); err != nil || payload.Name != session.Chunks[0].Name {
+			t.Errorf("DecodePayload() of a %s image = %+v, %v", test.format, payload, err)
+		}
+
+		joined := filepath.Join(testDir, test.format.String()+".txt")
+		if err := NewQRFileTransfer().QRCodesToFile(outDir, joined); err != nil {
+			t.Fatalf("QRCodesToFile() of %s images failed: %v", test.format, err)
+		}
+
+		if got, _ := os.ReadFile(joined); !bytes.Equal(got, content) {
+			t.Errorf("Joined %s session differs from the input", test.format)
+		}
+	}
+}
+
+func TestFileToQRCodesChunkSizeLimits(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("sparse symbols scan better "), 400)
+
+	if err := afero.WriteFile(fs, "/in/input.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		maxChunkSize int
+		version      int
+	}{
+		{"max chunk size", 300, 0},
+		{"target version", 0, 15},
+		{"both", 200, 20},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			qrft := NewQRFileTransfer()
+			qrft.SetFs(fs)
+			qrft.SetMaxChunkSize(test.maxChunkSize)
+			qrft.SetTargetQRVersion(test.version)
+
+			outDir := "/" + strings.ReplaceAll(test.name, " ", "-")
+			if err := qrft.FileToQRCodes("/in/input.txt", outDir); err != nil {
+				t.Fatalf("FileToQRCodes failed: %v", err)
+			}
+
+			session, err := loadSession(fs, outDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, c := range session.Chunks {
+				// Every chunk but the first holds only file data and its checksum
+				if test.maxChunkSize > 0 && i > 0 && c.Size > int64(test.maxChunkSize+split.ChecksumSize) {
+					t.Errorf("Chunk %d holds %d bytes, want at most %d", i, c.Size, test.maxChunkSize)
+				}
+
+				if test.version > 0 && c.QRVersion > test.version {
+					t.Errorf("Chunk %d has QR code version %d, want at most %d", i, c.QRVersion, test.version)
+				}
+			}
+
+			if err := qrft.QRCodesToFile(outDir, outDir+".txt"); err != nil {
+				t.Fatalf("QRCodesToFile failed: %v", err)
+			}
+
+			if got, _ := afero.ReadFile(fs, outDir+".txt"); !bytes.Equal(got, content) {
+				t.Errorf("Reconstructed %d bytes, want %d", len(got), len(content))
+			}
+		})
+	}
+}
+
+func TestFileToQRCodesPackedChunks(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := make([]byte, 20000)
+	rand.New(rand.NewSource(3)).Read(content)
+
+	if err := afero.WriteFile(fs, "/in/packed.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetTargetQRVersion(10)
+
+	if err := qrft.FileToQRCodes("/in/packed.bin", "/session"); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := loadSession(fs, "/session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the first chunk gives room to the metadata, every chunk but the last
+	// fills a QR code of the target version
+	full := session.Chunks[1].Size
+	data := full - split.ChecksumSize
+	size := int64(len(content) + split.MetadataSize("packed.bin"))
+
+	if want := int((size + data - 1) / data); len(session.Chunks) != want {
+		t.Errorf("Session has %d chunks of %d bytes, want %d", len(session.Chunks), full, want)
+	}
+
+	for i, c := range session.Chunks[:len(session.Chunks)-1] {
+		if c.Size != full || c.QRVersion != 10 {
+			t.Errorf("Chunk %d holds %d bytes in a version %d QR code, want %d in version 10", i, c.Size, c.QRVersion, full)
+		}
+	}
+
+	if err := qrft.QRCodesToFile("/session", "/out/packed.bin"); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, _ := afero.ReadFile(fs, "/out/packed.bin"); !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed %d bytes, want %d", len(got), len(content))
+	}
+}
+
+func TestFileToQRCodesAutoRecoveryLevel(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Two chunks of about 1500 bytes fit version 40 QR codes at the high level
+	testFilePath := filepath.Join(testDir, "sparse.bin")
+	content := bytes.Repeat([]byte{0x5a, 0xc3, 0x0f}, 1000)
+
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetRecoveryLevel(qrcode.Low)
+	qrft.SetAutoRecoveryLevel(true)
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if !session.Settings.AutoRecoveryLevel || len(session.Chunks) != 2 {
+		t.Fatalf("Session settings %+v with %d chunks, want automatic recovery levels and 2 chunks", session.Settings, len(session.Chunks))
+	}
+
+	manifest, err := LoadManifest(outDir)
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+
+	for i, c := range session.Chunks {
+		if c.RecoveryLevel != "high" || manifest.Chunks[i].RecoveryLevel != c.RecoveryLevel {
+			t.Errorf("Chunk %s has recovery level %q, manifest %q, want high", c.Name, c.RecoveryLevel, manifest.Chunks[i].RecoveryLevel)
+		}
+	}
+
+	// The QR codes are read like any other, and reproduced on paper
+	payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(session.Chunks[1].Name)))
+	if err != nil || payload.Name != session.Chunks[1].Name {
+		t.Fatalf("DecodePayload() = %+v, %v", payload, err)
+	}
+
+	if err := WritePaperBackup(io.Discard, outDir, DefaultPaperLayout()); err != nil {
+		t.Errorf("WritePaperBackup failed: %v", err)
+	}
+
+	reconstructed := filepath.Join(testDir, "reconstructed.bin")
+	if err := qrft.QRCodesToFile(outDir, reconstructed); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, err := os.ReadFile(reconstructed); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed file differs: %v", err)
+	}
+}
+
+func TestFileToQRCodesUniformQRVersion(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Several chunks at version 10, the last holding fewer bytes
+	testFilePath := filepath.Join(testDir, "input.bin")
+	content := bytes.Repeat([]byte{0x5a, 0xc3, 0x0f, 0x96}, 130)
+
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetTargetQRVersion(10)
+	qrft.SetUniformQRVersion(true)
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if session.Settings.UniformQRVersion != 10 || len(session.Chunks) < 2 {
+		t.Fatalf("Session settings %+v with %d chunks, want a uniform version 10 and several chunks", session.Settings, len(session.Chunks))
+	}
+
+	for _, c := range session.Chunks {
+		if c.QRVersion != 10 {
+			t.Errorf("Chunk %s has QR code version %d, want 10", c.Name, c.QRVersion)
+		}
+	}
+
+	// The padded last QR code is read like any other
+	last := session.Chunks[len(session.Chunks)-1]
+
+	payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(last.Name)))
+	if err != nil || payload.Name != last.Name {
+		t.Fatalf("DecodePayload() = %+v, %v", payload, err)
+	}
+
+	reconstructed := filepath.Join(testDir, "reconstructed.bin")
+	if err := qrft.QRCodesToFile(outDir, reconstructed); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if got, err := os.ReadFile(reconstructed); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Reconstructed file differs: %v", err)
+	}
+
+	// Without the option the last QR code is smaller
+	plain := NewQRFileTransfer()
+	plain.SetTargetQRVersion(10)
+
+	plainDir := filepath.Join(testDir, "plain")
+	if err := plain.FileToQRCodes(testFilePath, plainDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	plainSession, err := LoadSession(plainDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if v := plainSession.Chunks[len(plainSession.Chunks)-1].QRVersion; v >= 10 {
+		t.Errorf("Last chunk without uniform version has QR code version %d, want less than 10", v)
+	}
+}
+
+func TestFileToQRCodesPNGOptions(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "input.txt")
+	if err := os.WriteFile(testFilePath, []byte("printed at 300 DPI"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+
+	if err := qrft.SetPNGOptions(qrcode.WriteFileOptions{DPI: -1}); err == nil {
+		t.Error("SetPNGOptions with a negative resolution succeeded, want error")
+	}
+
+	if err := qrft.SetPNGOptions(qrcode.WriteFileOptions{CompressionLevel: png.BestSpeed, DPI: 300}); err != nil {
+		t.Fatalf("SetPNGOptions failed: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if session.Settings.PNGOptions != "compression=-2 dpi=300" {
+		t.Errorf("Session PNG options %q, want the compression and resolution", session.Settings.PNGOptions)
+	}
+
+	img, err := os.ReadFile(session.QRCodeFile(session.Chunks[0].Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The pHYs chunk follows the IHDR chunk, at 11811 pixels per meter
+	if len(img) < 49 || string(img[37:41]) != "pHYs" || binary.BigEndian.Uint32(img[41:]) != 11811 {
+		t.Error("QR code image lacks the pHYs chunk of 300 DPI")
+	}
+
+	if payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(session.Chunks[0].Name))); err != nil || payload.Name != session.Chunks[0].Name {
+		t.Errorf("DecodePayload() = %+v, %v", payload, err)
+	}
+
+	if id := pngOptionsID(qrcode.DefaultWriteFileOptions()); id != "" {
+		t.Errorf("pngOptionsID of the defaults = %q, want empty", id)
+	}
+}
+
+func TestFileToQRCodesQROptions(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "branded.txt")
+	content := bytes.Repeat([]byte("branded QR codes "), 60)
+
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	logo := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(logo, logo.Bounds(), &image.Uniform{C: color.NRGBA{R: 0xc0, A: 0xff}}, image.Point{}, draw.Src)
+
+	bg := color.RGBA{R: 0xff, G: 0xf0, B: 0xd0, A: 0xff}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetRecoveryLevel(qrcode.Low)
+	qrft.SetMaxChunkSize(400)
+
+	if err := qrft.SetQROptions(qrcode.Options{LogoSize: qrcode.MaxLogoSize + 0.1}); err == nil {
+		t.Fatal("SetQROptions of a too large logo succeeded")
+	}
+
+	options := qrcode.Options{ForegroundColor: color.RGBA{B: 0x60, A: 0xff}, BackgroundColor: bg, QuietZone: 2, Logo: logo}
+	if err := qrft.SetQROptions(options); err != nil {
+		t.Fatalf("SetQROptions failed: %v", err)
+	}
+
+	// The logo raises the recovery level, also when set afterwards
+	qrft.SetRecoveryLevel(qrcode.Low)
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if want := options.RecoveryLevel(qrcode.Low); session.Settings.RecoveryLevel != int(want) || want == qrcode.Low {
+		t.Errorf("Session recovery level %d, want %d", session.Settings.RecoveryLevel, want)
+	}
+
+	path := session.QRCodeFile(session.Chunks[0].Name)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(f)
+	_ = f.Close()
+
+	if err != nil {
+		t.Fatalf("Failed to decode QR code image: %v", err)
+	}
+
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != bg {
+		t.Errorf("QR code background %v, want %v", got, bg)
+	}
+
+	// The QR code is read despite its logo
+	payload, err := DecodePayload(scanReferenceSample(t, path))
+	if err != nil || payload.Name != session.Chunks[0].Name {
+		t.Fatalf("DecodePayload() = %+v, %v", payload, err)
+	}
+
+	// Other options draw the QR codes again instead of resuming them
+	options.BackgroundColor = color.White
+	if err := qrft.SetQROptions(options); err != nil {
+		t.Fatalf("SetQROptions failed: %v", err)
+	}
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	if f, err = os.Open(path); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err = png.Decode(f)
+	_ = f.Close()
+
+	if err != nil || color.RGBAModel.Convert(img.At(0, 0)) != color.RGBAModel.Convert(color.White) {
+		t.Errorf("QR code drawn with the previous options was resumed: %v", err)
+	}
+
+	for _, defaults := range []qrcode.Options{{}, {ForegroundColor: color.Black, BackgroundColor: color.Gray16{Y: 0xffff}}} {
+		if id := qrOptionsID(defaults); id != "" {
+			t.Errorf("qrOptionsID(%+v) = %q, want empty for the defaults", defaults, id)
+		}
+	}
+
+	if id := qrOptionsID(qrcode.Options{FastMask: true}); id != "fast-mask" {
+		t.Errorf("qrOptionsID with a fixed mask = %q, want fast-mask", id)
+	}
+}
+
+func TestFileToQRCodesContentAddressed(t *testing.T) {
+	testDir := t.TempDir()
+
+	// Repeated blocks yield identical chunk data
+	block := strings.Repeat("content addressed ", 20)[:300]
+	inputs := map[string][]byte{
+		"repeated.txt": []byte(strings.Repeat(block, 4)),
+		"other.txt":    []byte(strings.Repeat("another file ", 70)),
+	}
+
+	merged := filepath.Join(testDir, "merged")
+
+	for name, content := range inputs {
+		testFilePath := filepath.Join(testDir, name)
+		if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+
+		qrft := NewQRFileTransfer()
+		qrft.SetMaxChunkSize(300)
+		qrft.SetParity(50)
+		qrft.SetContentAddressed(true)
+
+		outDir := filepath.Join(testDir, name+"_qrcodes")
+		if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+			t.Fatalf("FileToQRCodes() of %s failed: %v", name, err)
+		}
+
+		session, err := LoadSession(outDir)
+		if err != nil {
+			t.Fatalf("LoadSession failed: %v", err)
+		}
+
+		manifest, err := LoadManifest(outDir)
+		if err != nil {
+			t.Fatalf("LoadManifest failed: %v", err)
+		}
+
+		if !session.Settings.ContentAddressed || !manifest.ContentAddressed {
+			t.Fatal("Session and manifest are not content-addressed")
+		}
+
+		dataFiles := make(map[string]bool)
+
+		for i, chunk := range session.Chunks {
+			if want := chunk.Hash[:16] + ".dat"; filepath.Base(session.DataFile(chunk.Name)) != want || path.Base(manifest.Chunks[i].Data) != want {
+				t.Errorf("Data file of %s = %s, manifest %s, want %s", chunk.Name, session.DataFile(chunk.Name), manifest.Chunks[i].Data, want)
+			}
+
+			if want := chunk.QRHash[:16] + ".png"; filepath.Base(session.QRCodeFile(chunk.Name)) != want || path.Base(manifest.Chunks[i].QRCode) != want {
+				t.Errorf("QR code of %s = %s, manifest %s, want %s", chunk.Name, session.QRCodeFile(chunk.Name), manifest.Chunks[i].QRCode, want)
+			}
+
+			if payload, err := DecodePayload(scanReferenceSample(t, session.QRCodeFile(chunk.Name))); err != nil || payload.Name != chunk.Name {
+				t.Errorf("DecodePayload() = %+v, %v", payload, err)
+			}
+
+			dataFiles[session.DataFile(chunk.Name)] = true
+		}
+
+		// The first chunk also holds the file header, the others share a data file
+		if name == "repeated.txt" && len(dataFiles) != 2 {
+			t.Errorf("%d data files for the chunks of %s, want the repeated chunk stored once", len(dataFiles), name)
+		}
+
+		report, err := qrft.VerifyChunks(outDir)
+		if err != nil || !report.Complete() {
+			t.Fatalf("VerifyChunks() = %+v, %v, want complete", report, err)
+		}
+
+		// Merge the archives: no file of one overwrites a file of the other
+		for _, dir := range []string{session.QRCodesDir(), session.DataDir(), session.ParityDir()} {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mergedDir := filepath.Join(merged, filepath.Base(dir))
+			if err := os.MkdirAll(mergedDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, entry := range entries {
+				target := filepath.Join(mergedDir, entry.Name())
+				if _, err := os.Stat(target); err == nil {
+					t.Fatalf("%s of %s collides in the merged archive", entry.Name(), name)
+				}
+
+				data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := os.WriteFile(target, data, 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	// Every file is joined from the merged directories with its own session file
+	for name, content := range inputs {
+		sessionFile, err := os.ReadFile(filepath.Join(testDir, name+"_qrcodes", SessionFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(merged, SessionFileName), sessionFile, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		outputPath := filepath.Join(testDir, "joined_"+name)
+		if err := NewQRFileTransfer().QRCodesToFile(merged, outputPath); err != nil {
+			t.Fatalf("QRCodesToFile() of %s failed: %v", name, err)
+		}
+
+		if joined, err := os.ReadFile(outputPath); err != nil || !bytes.Equal(joined, content) {
+			t.Errorf("Joined %s differs from the original", name)
+		}
+	}
+
+	// Splitting again by chunk name removes the content-addressed files
+	outDir := filepath.Join(testDir, "other.txt_qrcodes")
+
+	previous, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(300)
+
+	if err := qrft.FileToQRCodes(filepath.Join(testDir, "other.txt"), outDir); err != nil {
+		t.Fatalf("FileToQRCodes() failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	for _, dir := range []string{session.QRCodesDir(), session.DataDir(), previous.ParityDir()} {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), "other_") {
+				t.Errorf("Stale file %s left in %s", entry.Name(), dir)
+			}
+		}
+	}
+}
+
+func TestFileToQRCodesVolumes(t *testing.T) {
+	testDir := t.TempDir()
+
+	content := make([]byte, 3000)
+	rand.New(rand.NewSource(7)).Read(content)
+
+	testFilePath := filepath.Join(testDir, "input.bin")
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(250)
+	qrft.SetParity(50)
+	qrft.SetVolumeSize(4)
+
+	outDir := filepath.Join(testDir, "volumes")
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	// 12 data chunks fill 3 volumes, and the 6 parity chunks 2 of their own
+	if len(session.Chunks) != 12 || len(session.Parity) != 6 || session.VolumeCount() != 5 {
+		t.Fatalf("Session of %d chunks and %d parity chunks in %d volumes, want 12 and 6 in 5", len(session.Chunks), len(session.Parity), session.VolumeCount())
+	}
+
+	if dirs := session.QRCodesDirs(); len(dirs) != 5 || dirs[1] != filepath.Join(outDir, "vol002", "qrcodes") {
+		t.Errorf("QRCodesDirs() = %v, want the QR codes directories of 5 volumes", dirs)
+	}
+
+	if _, err := os.Stat(session.QRCodesDir()); !os.IsNotExist(err) {
+		t.Errorf("QR codes directory %s exists next to the volumes", session.QRCodesDir())
+	}
+
+	for i, chunk := range session.Chunks {
+		if want := filepath.Join(outDir, volumeDirName(i/4+1), "qrcodes", chunk.Name+".png"); session.QRCodeFile(chunk.Name) != want {
+			t.Errorf("QRCodeFile(%s) = %s, want %s", chunk.Name, session.QRCodeFile(chunk.Name), want)
+		}
+
+		if _, err := os.Stat(session.DataFile(chunk.Name)); err != nil {
+			t.Errorf("Data file of %s: %v", chunk.Name, err)
+		}
+	}
+
+	if want := filepath.Join(outDir, "vol005", "parity", session.Parity[5].Name+".dat"); session.ParityFile(session.Parity[5].Name) != want {
+		t.Errorf("ParityFile() = %s, want %s", session.ParityFile(session.Parity[5].Name), want)
+	}
+
+	// Every volume has a manifest of its own chunks, relative to its directory
+	for volume := 1; volume <= 5; volume++ {
+		volumeDir := filepath.Join(outDir, volumeDirName(volume))
+
+		manifest, err := LoadManifest(volumeDir)
+		if err != nil {
+			t.Fatalf("LoadManifest of volume %d failed: %v", volume, err)
+		}
+
+		// The last volume holds the remaining 2 parity chunks
+		want := 4
+		if volume == 5 {
+			want = 2
+		}
+
+		if manifest.Volume != volume || manifest.VolumeCount != 5 || manifest.ChunkCount != 12 || len(manifest.Chunks)+len(manifest.Parity) != want {
+			t.Errorf("Manifest of volume %d = volume %d of %d, %d chunks and %d parity chunks of %d", volume, manifest.Volume,
+				manifest.VolumeCount, len(manifest.Chunks), len(manifest.Parity), manifest.ChunkCount)
+		}
+
+		for _, chunk := range append(manifest.Chunks, manifest.Parity...) {
+			if _, err := os.Stat(filepath.Join(volumeDir, filepath.FromSlash(chunk.QRCode))); err != nil || chunk.Volume != volume {
+				t.Errorf("Chunk %s of volume %d in volume %d: %v", chunk.Name, volume, chunk.Volume, err)
+			}
+		}
+	}
+
+	// A lost volume is restored from the parity chunks
+	if err := os.RemoveAll(filepath.Join(outDir, "vol002")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := qrft.VerifyChunks(outDir)
+	if err != nil || !slices.Equal(report.Missing, []int{4, 5, 6, 7}) {
+		t.Fatalf("VerifyChunks() = %+v, %v, want chunks 4-7 missing", report, err)
+	}
+
+	outputPath := filepath.Join(testDir, "joined.bin")
+	if err := qrft.QRCodesToFile(outDir, outputPath); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	if joined, err := os.ReadFile(outputPath); err != nil || !bytes.Equal(joined, content) {
+		t.Error("Joined file differs from the original")
+	}
+
+	// Splitting again without volumes removes them
+	qrft.SetVolumeSize(0)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	for volume := 1; volume <= 5; volume++ {
+		if _, err := os.Stat(filepath.Join(outDir, volumeDirName(volume))); !os.IsNotExist(err) {
+			t.Errorf("Volume %d left after splitting without volumes", volume)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/imaging"
+	"github.com/spf13/afero"
+)
+
+// ReferenceSamplesFileName is the name of the file describing a set of reference
+// samples, see WriteReferenceSamples
+const ReferenceSamplesFileName = "reference.json"
+
+// referenceSample is the chunk encoded into every reference sample. It is fixed,
+// so that the samples of every release hold the same chunk.
+var referenceSample = ChunkPayload{
+	Name: "reference_0001",
+	Data: []byte("QR File Transfer reference sample 1. Scan me with any QR code app.\n"),
+}
+
+// ReferenceSample describes a reference sample QR code and the content a scanner
+// must report for it
+type ReferenceSample struct {
+	// Image is the file name of the QR code image
+	Image string `json:"image"`
+	// Protocol is the payload layout: text, binary-v1, binary-v2 with next-up hints,
+	// or binary-v3 with a file ID
+	Protocol string `json:"protocol"`
+	// PayloadFormatVersion is the payload version as recorded in manifest.json
+	PayloadFormatVersion int `json:"payload_format_version"`
+	// Profile is the built-in profile the QR code is encoded with
+	Profile string `json:"profile"`
+	// RecoveryLevel is the error correction level of the QR code
+	RecoveryLevel string `json:"recovery_level"`
+	// QRVersion is the version of the QR code
+	QRVersion int `json:"qr_version"`
+	// Content is the hex encoded content of the QR code
+	Content string `json:"content"`
+	// Text is the content as a scanner app displays it, for text payloads only
+	Text string `json:"text,omitempty"`
+}
+
+// ReferenceSamples describes a set of reference samples
+type ReferenceSamples struct {
+	// Chunk and Data are the name and the hex encoded data of the chunk held by
+	// every sample
+	Chunk string `json:"chunk"`
+	Data  string `json:"data"`
+	// Samples lists every sample
+	Samples []ReferenceSample `json:"samples"`
+}
+
+// referenceProtocols lists the payload layouts of the reference samples
+var referenceProtocols = []struct {
+	name   string
+	format PayloadFormat
+	file   string
+	next   []int
+}{
+	{name: "text", format: PayloadFormatText},
+	{name: "binary-v1", format: PayloadFormatBinary},
+	{name: "binary-v2", format: PayloadFormatBinary, next: []int{2, 3}},
+	{name: "binary-v3", format: PayloadFormatBinary, file: "1", next: []int{2}},
+}
+
+// WriteReferenceSamples writes a canonical QR code for every payload layout and
+// built-in profile into dir, together with reference.json describing the content
+// of each and index.html showing them for scanning off a screen. The samples hold
+// the same fixed chunk in every release, so scanning them with common QR code apps
+// verifies that new releases stay readable in the field: an app must report the
+// Content of every sample, and display the Text of the text payloads. Samples of
+// the print-archive profile carry a checksum caption like its sessions do.
+func WriteReferenceSamples(dir string) (*ReferenceSamples, error) {
+	return writeReferenceSamples(osFs, dir)
+}
+
+// writeReferenceSamples is WriteReferenceSamples on the file system fsys
+func writeReferenceSamples(fsys afero.Fs, dir string) (*ReferenceSamples, error) {
+	if err := fsys.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create reference samples directory: %w", err)
+	}
+
+	set := &ReferenceSamples{Chunk: referenceSample.Name, Data: hex.EncodeToString(referenceSample.Data)}
+
+	for _, protocol := range referenceProtocols {
+		for _, name := range ProfileNames() {
+			profile, _ := LookupProfile(name)
+
+			sample, err := writeReferenceSample(fsys, dir, protocol.name, name, profile, protocol.format, protocol.file, protocol.next)
+			if err != nil {
+				return nil, err
+			}
+
+			set.Samples = append(set.Samples, *sample)
+		}
+	}
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference samples: %w", err)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, ReferenceSamplesFileName), data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write reference samples file: %w", err)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, "index.html"), set.html(), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write reference samples page: %w", err)
+	}
+
+	return set, nil
+}
+
+// LoadReferenceSamples reads the description of the reference samples in dir
+func LoadReferenceSamples(dir string) (*ReferenceSamples, error) {
+	return loadReferenceSamples(osFs, dir)
+}
+
+// loadReferenceSamples is LoadReferenceSamples on the file system fsys
+func loadReferenceSamples(fsys afero.Fs, dir string) (*ReferenceSamples, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(dir, ReferenceSamplesFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reference samples file: %w", err)
+	}
+
+	var set ReferenceSamples
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse reference samples file: %w", err)
+	}
+
+	return &set, nil
+}
+
+// writeReferenceSample writes the sample of a payload layout and profile into dir on
+// the file system fsys
+func writeReferenceSample(fsys afero.Fs, dir, protocol, profileName string, profile Profile, format PayloadFormat, file string, next []int) (*ReferenceSample, error) {
+	payload := referenceSample
+	payload.File = file
+	payload.Next = next
+
+	content, err := EncodeChunkPayload(format, &payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference sample %s: %w", protocol, err)
+	}
+
+	qrCode, err := newQRCode(format, content, profile.RecoveryLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reference sample %s: %w", protocol, err)
+	}
+
+	// Size the QR code like a session encoded with the profile would
+	q := NewQRFileTransfer()
+	q.ApplyProfile(profile)
+	q.SetPayloadFormat(format)
+	q.SetNextHints(len(next))
+	q.fileID = file
+
+	size := q.qrSize
+	if q.autoAdjustQRSize {
+		size = q.calculateOptimalQRSize(qrCode.VersionNumber)
+	}
+
+	var img []byte
+	if q.checksumCaption {
+		img, err = encodePNG(imaging.Caption(qrCode.Image(size), q.chunkCaption(1, hashBytes(payload.Data))), q.pngOptions)
+	} else {
+		img, err = encodePNG(qrCode.Image(size), q.pngOptions)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode reference sample %s: %w", protocol, err)
+	}
+
+	sample := &ReferenceSample{
+		Image:                fmt.Sprintf("%s_%s.png", protocol, profileName),
+		Protocol:             protocol,
+		PayloadFormatVersion: payloadFormatVersion(format, len(next), file),
+		Profile:              profileName,
+		RecoveryLevel:        recoveryLevelNames[profile.RecoveryLevel],
+		QRVersion:            qrCode.VersionNumber,
+		Content:              hex.EncodeToString(content),
+	}
+
+	if format == PayloadFormatText {
+		sample.Text = string(content)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, sample.Image), img, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write reference sample %s: %w", sample.Image, err)
+	}
+
+	return sample, nil
+}
+
+// Check verifies the content a scanner reported for a sample: the content must be
+// the sample content, and decode to the reference chunk. It returns an error
+// describing the first difference.
+func (s *ReferenceSample) Check(content []byte) error {
+	if got := hex.EncodeToString(content); got != s.Content {
+		return fmt.Errorf("%w: sample %s scanned as %s, want %s", ErrHashMismatch, s.Image, got, s.Content)
+	}
+
+	payload, err := DecodePayload(content)
+	if err != nil {
+		return fmt.Errorf("failed to decode sample %s: %w", s.Image, err)
+	}
+
+	if payload.Name != referenceSample.Name || !bytes.Equal(payload.Data, referenceSample.Data) {
+		return fmt.Errorf("%w: sample %s does not hold the reference chunk", ErrHashMismatch, s.Image)
+	}
+
+	return nil
+}
+
+// html returns a page showing every sample with the content a scanner must report
+func (set *ReferenceSamples) html() []byte {
+	var b strings.Builder
+
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>QR File Transfer reference samples</title>\n")
+	b.WriteString("<style>body{font-family:sans-serif} section{margin:2em 0} img{width:400px;image-rendering:pixelated} pre{white-space:pre-wrap;word-break:break-all}</style>\n")
+	b.WriteString("</head>\n<body>\n<h1>QR File Transfer reference samples</h1>\n")
+	b.WriteString("<p>Scan every code with the QR code app under test and compare what it reports with the expected content.</p>\n")
+
+	for _, s := range set.Samples {
+		fmt.Fprintf(&b, "<section>\n<h2>%s, profile %s</h2>\n", html.EscapeString(s.Protocol), html.EscapeString(s.Profile))
+		fmt.Fprintf(&b, "<p>Payload version %d, recovery level %s, QR version %d</p>\n", s.PayloadFormatVersion, s.RecoveryLevel, s.QRVersion)
+		fmt.Fprintf(&b, "<img src=\"%s\" alt=\"%s\">\n", html.EscapeString(s.Image), html.EscapeString(s.Image))
+
+		if s.Text != "" {
+			fmt.Fprintf(&b, "<p>Expected text:</p>\n<pre>%s</pre>\n", html.EscapeString(s.Text))
+		} else {
+			fmt.Fprintf(&b, "<p>Expected bytes (hex):</p>\n<pre>%s</pre>\n", s.Content)
+		}
+
+		b.WriteString("</section>\n")
+	}
+
+	b.WriteString("</body>\n</html>\n")
+
+	return []byte(b.String())
+}
@@ -0,0 +1,134 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/makiuchi-d/gozxing"
+	zxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// TestReferenceSamples is the interop harness of the reference samples: every sample
+// is scanned with the zxing decoder most QR code apps are built on, and must report
+// the content recorded for it.
+func TestReferenceSamples(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "samples")
+
+	set, err := WriteReferenceSamples(dir)
+	if err != nil {
+		t.Fatalf("WriteReferenceSamples failed: %v", err)
+	}
+
+	if want := len(referenceProtocols) * len(ProfileNames()); len(set.Samples) != want {
+		t.Fatalf("Expected %d samples, got %d", want, len(set.Samples))
+	}
+
+	loaded, err := LoadReferenceSamples(dir)
+	if err != nil {
+		t.Fatalf("LoadReferenceSamples failed: %v", err)
+	}
+
+	if len(loaded.Samples) != len(set.Samples) || loaded.Data != hex.EncodeToString(referenceSample.Data) {
+		t.Fatal("Loaded reference samples do not match the written ones")
+	}
+
+	for _, sample := range loaded.Samples {
+		t.Run(sample.Image, func(t *testing.T) {
+			content := scanReferenceSample(t, filepath.Join(dir, sample.Image))
+
+			if err := sample.Check(content); err != nil {
+				t.Errorf("Check failed: %v", err)
+			}
+
+			if sample.Text != "" && string(content) != sample.Text {
+				t.Errorf("Scanner reports text %q, want %q", content, sample.Text)
+			}
+		})
+	}
+
+	// A scanner that mangles the content fails the check
+	if err := loaded.Samples[0].Check([]byte("Chunk: reference_0001\nData: ")); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("Check() error = %v, want ErrHashMismatch", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read reference samples page: %v", err)
+	}
+
+	for _, sample := range loaded.Samples {
+		if !strings.Contains(string(page), `src="`+sample.Image+`"`) {
+			t.Errorf("Reference samples page does not show %s", sample.Image)
+		}
+	}
+}
+
+// TestReferenceSampleContent pins the content of the samples, which must not change
+// between releases without a new payload version
+func TestReferenceSampleContent(t *testing.T) {
+	set, err := WriteReferenceSamples(t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteReferenceSamples failed: %v", err)
+	}
+
+	data := hex.EncodeToString(referenceSample.Data)
+	want := map[string]string{
+		"text":      hex.EncodeToString([]byte("Chunk: reference_0001\nData: UVIgRmlsZSBUcmFuc2ZlciByZWZlcmVuY2Ugc2FtcGxlIDEuIFNjYW4gbWUgd2l0aCBhbnkgUVIgY29kZSBhcHAuCg==")),
+		"binary-v1": "514654010e7265666572656e63655f30303031" + data,
+		"binary-v2": "514654020e7265666572656e63655f30303031020203" + data,
+		"binary-v3": "5146540301310e7265666572656e63655f303030310102" + data,
+	}
+
+	for _, sample := range set.Samples {
+		if got := sample.Content; got != want[sample.Protocol] {
+			t.Errorf("Sample %s has content %s, want %s", sample.Image, got, want[sample.Protocol])
+		}
+	}
+}
+
+// scanReferenceSample decodes the QR code image at path and returns its content
+func scanReferenceSample(t *testing.T, path string) []byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open sample: %v", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("Failed to decode sample image: %v", err)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		t.Fatalf("Failed to create binary bitmap: %v", err)
+	}
+
+	reader := zxingqrcode.NewQRCodeReader()
+
+	result, err := reader.Decode(bmp, map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true})
+	if err != nil {
+		// The finder pattern detection of gozxing misses a few percent of the
+		// rendered codes from version 7 on, whose modules read as pure barcodes
+		result, err = reader.Decode(bmp, map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_PURE_BARCODE: true})
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to scan sample: %v", err)
+	}
+
+	if segments, ok := result.GetResultMetadata()[gozxing.ResultMetadataType_BYTE_SEGMENTS].([][]byte); ok {
+		return bytes.Join(segments, nil)
+	}
+
+	return []byte(result.GetText())
+}
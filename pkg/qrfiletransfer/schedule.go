@@ -0,0 +1,110 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Strategies of a Schedule
+const (
+	// ScheduleSequential plays every frame once in order
+	ScheduleSequential = "sequential"
+	// ScheduleRepeat plays the whole sequence N times
+	ScheduleRepeat = "repeat"
+	// ScheduleShuffle plays the frames in a shuffled order, in blocks of N frames
+	// that are each played twice, so every frame comes around again N frames later
+	ScheduleShuffle = "shuffle"
+	// ScheduleWeighted plays the sequence in N passes, each pass a shorter prefix of
+	// the frames, so the first frames, which carry the metadata, are played N times
+	// and the last ones once
+	ScheduleWeighted = "weighted"
+)
+
+// defaultScheduleN is the parameter of a strategy given without one
+var defaultScheduleN = map[string]int{
+	ScheduleSequential: 1,
+	ScheduleRepeat:     2,
+	ScheduleShuffle:    8,
+	ScheduleWeighted:   3,
+}
+
+// Schedule orders the frames of a video or animation, repeating and interleaving
+// them so that a camera dropping frames still catches every chunk
+type Schedule struct {
+	// Strategy is one of the Schedule constants
+	Strategy string
+	// N is the number of times of ScheduleRepeat and passes of ScheduleWeighted,
+	// or the period of ScheduleShuffle
+	N int
+}
+
+// ParseSchedule parses a schedule written as "<strategy>[:<n>]", e.g. "repeat:3",
+// "shuffle", or "weighted:4". A strategy without n gets its default: the whole
+// sequence twice, a shuffle period of 8 frames, and 3 weighted passes.
+func ParseSchedule(spec string) (Schedule, error) {
+	strategy, value, hasN := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+
+	n, ok := defaultScheduleN[strategy]
+	if !ok {
+		return Schedule{}, fmt.Errorf("unknown schedule %q, expected sequential, repeat, shuffle, or weighted", spec)
+	}
+
+	if hasN {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n < 1 || (strategy == ScheduleSequential && n != 1) {
+			return Schedule{}, fmt.Errorf("invalid schedule %q, expected a count of at least 1", spec)
+		}
+	}
+
+	return Schedule{Strategy: strategy, N: n}, nil
+}
+
+// String returns the schedule as ParseSchedule reads it
+func (s Schedule) String() string {
+	if s.Strategy == ScheduleSequential {
+		return s.Strategy
+	}
+
+	return fmt.Sprintf("%s:%d", s.Strategy, s.N)
+}
+
+// Order returns the indices of count frames in the order they are played. The
+// order only depends on the schedule and count, so a video generated again
+// plays the same frames.
+func (s Schedule) Order(count int) []int {
+	n := max(s.N, 1)
+
+	var order []int
+
+	switch s.Strategy {
+	case ScheduleRepeat:
+		for range n {
+			for i := range count {
+				order = append(order, i)
+			}
+		}
+	case ScheduleShuffle:
+		shuffled := rand.New(rand.NewSource(int64(count))).Perm(count)
+
+		for start := 0; start < count; start += n {
+			block := shuffled[start:min(start+n, count)]
+			order = append(order, block...)
+			order = append(order, block...)
+		}
+	case ScheduleWeighted:
+		for pass := range n {
+			// Pass 0 plays every frame, the last pass the first count/n frames
+			for i := range (count*(n-pass) + n - 1) / n {
+				order = append(order, i)
+			}
+		}
+	default:
+		for i := range count {
+			order = append(order, i)
+		}
+	}
+
+	return order
+}
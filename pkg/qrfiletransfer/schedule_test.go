@@ -0,0 +1,74 @@
+package qrfiletransfer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Schedule
+	}{
+		{"sequential", Schedule{ScheduleSequential, 1}},
+		{"repeat", Schedule{ScheduleRepeat, 2}},
+		{"Repeat:3", Schedule{ScheduleRepeat, 3}},
+		{"shuffle", Schedule{ScheduleShuffle, 8}},
+		{"weighted:4", Schedule{ScheduleWeighted, 4}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSchedule(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSchedule(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+
+		if again, err := ParseSchedule(got.String()); err != nil || again != got {
+			t.Errorf("ParseSchedule(%q) = %+v, %v, want %+v", got.String(), again, err, got)
+		}
+	}
+
+	for _, spec := range []string{"", "random", "repeat:0", "repeat:x", "sequential:2"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestScheduleOrder(t *testing.T) {
+	if got := (Schedule{ScheduleRepeat, 2}).Order(3); !reflect.DeepEqual(got, []int{0, 1, 2, 0, 1, 2}) {
+		t.Errorf("repeat:2 order = %v", got)
+	}
+
+	if got := (Schedule{ScheduleWeighted, 3}).Order(6); !reflect.DeepEqual(got, []int{0, 1, 2, 3, 4, 5, 0, 1, 2, 3, 0, 1}) {
+		t.Errorf("weighted:3 order = %v", got)
+	}
+
+	// Every frame of a shuffle is played twice, its repeat at most a period later
+	const count, period = 21, 4
+
+	order := (Schedule{ScheduleShuffle, period}).Order(count)
+	if len(order) != 2*count {
+		t.Fatalf("shuffle order has %d frames, want %d", len(order), 2*count)
+	}
+
+	seen := make(map[int][]int)
+	for position, index := range order {
+		seen[index] = append(seen[index], position)
+	}
+
+	for index := range count {
+		positions := seen[index]
+		if len(positions) != 2 || positions[1]-positions[0] > period {
+			t.Errorf("Frame %d is played at %v", index, positions)
+		}
+	}
+
+	if order[0] == 0 && order[1] == 1 && order[2] == 2 {
+		t.Errorf("shuffle order %v is not shuffled", order)
+	}
+
+	if again := (Schedule{ScheduleShuffle, period}).Order(count); !reflect.DeepEqual(again, order) {
+		t.Errorf("shuffle order changed: %v, then %v", order, again)
+	}
+}
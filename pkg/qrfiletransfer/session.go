@@ -0,0 +1,864 @@
+package qrfiletransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/split"
+	"github.com/spf13/afero"
+)
+
+// SessionFileName is the name of the file that describes a session directory
+const SessionFileName = "session.json"
+
+// SessionVersion is the version of the session layout written by this package
+const SessionVersion = 1
+
+// stagingSuffix is appended to an output directory to name the directory a session
+// is built in before it is published
+const stagingSuffix = ".partial"
+
+// previousSuffix is appended to an output directory to name the directory its
+// published session is moved to while the session replacing it is published
+const previousSuffix = ".previous"
+
+// Session describes a session directory produced by FileToQRCodes.
+// A session directory contains the session file, the manifest once the session is
+// complete, and the subdirectories named by its Layout: qrcodes/ with the QR code
+// images, the optional data/ and parity/ directories, and text/ with the text
+// fallback of the chunks if enabled. Consumers locate artifacts through the
+// session instead of guessing the structure from directory names.
+//
+// The session file is written before any QR code is generated so that an
+// interrupted run can be resumed: a later run for the same input and settings only
+// regenerates the artifacts that are missing or no longer match.
+type Session struct {
+	// Version is the session layout version
+	Version int `json:"version"`
+	// Complete is set once every artifact of the session has been written
+	Complete bool `json:"complete"`
+	// File describes the input file
+	File SessionFile `json:"file"`
+	// Layout names the subdirectories of the session directory
+	Layout SessionLayout `json:"layout"`
+	// Settings are the encoder settings the artifacts were produced with
+	Settings SessionSettings `json:"settings"`
+	// Chunks lists every chunk of the session in order, but for the chunks
+	// duplicating an earlier one, see Refs
+	Chunks []SessionChunk `json:"chunks"`
+	// Refs lists the chunks duplicating an earlier chunk, which have no QR code of
+	// their own, see QRFileTransfer.SetDedup
+	Refs []ChunkRef `json:"refs,omitempty"`
+	// Parity lists the parity chunks of the session in order, see
+	// QRFileTransfer.SetParity
+	Parity []SessionChunk `json:"parity,omitempty"`
+	// ChunkSizeReductions records every reduction of the chunk size made because a
+	// chunk did not fit in a QR code
+	ChunkSizeReductions []ChunkSizeReduction `json:"chunk_size_reductions,omitempty"`
+
+	// Legacy is set for archives without a session file, see OpenSession
+	Legacy bool `json:"-"`
+
+	// dir is the session directory the session was loaded from
+	dir string
+	// dataFiles maps chunk names to data files that do not follow the layout
+	dataFiles map[string]string
+	// chunkNames and parityNames index Chunks and Parity by name, see chunk
+	chunkNames, parityNames chunkNames
+}
+
+// chunkNames maps the names of a list of chunks to their index in the list
+type chunkNames struct {
+	// chunks is the list indexed, which is indexed again once it is replaced
+	chunks []SessionChunk
+	// index maps the name of every chunk to its first index in chunks
+	index map[string]int
+}
+
+// lookup returns the chunk of chunks with the given name, or nil if there is none.
+// chunks is indexed on the first lookup, and again once it is replaced or grows.
+func (n *chunkNames) lookup(chunks []SessionChunk, name string) *SessionChunk {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	if n.index == nil || len(n.chunks) != len(chunks) || &n.chunks[0] != &chunks[0] {
+		n.chunks = chunks
+		n.index = make(map[string]int, len(chunks))
+
+		for i := range chunks {
+			if _, ok := n.index[chunks[i].Name]; !ok {
+				n.index[chunks[i].Name] = i
+			}
+		}
+	}
+
+	if i, ok := n.index[name]; ok {
+		return &chunks[i]
+	}
+
+	return nil
+}
+
+// ChunkRef records a chunk duplicating an earlier chunk with the same data, which
+// has no QR code of its own, see QRFileTransfer.SetDedup
+type ChunkRef struct {
+	// Index is the index of the chunk in the file
+	Index int `json:"index"`
+	// Ref is the index of the chunk it duplicates
+	Ref int `json:"ref"`
+}
+
+// SessionFile describes the file encoded by a session
+type SessionFile struct {
+	// Name is the base name of the input file
+	Name string `json:"name"`
+	// Size is the size of the input file in bytes
+	Size int64 `json:"size"`
+	// Hash is the hex encoded SHA-256 of the input file
+	Hash string `json:"hash"`
+	// Dir is set when the file is an archive of a directory, see DirToQRCodes
+	Dir bool `json:"dir,omitempty"`
+}
+
+// SessionLayout names the subdirectories of a session directory, relative to it.
+// Optional directories are empty when the session does not contain them.
+type SessionLayout struct {
+	QRCodes string `json:"qrcodes"`
+	Data    string `json:"data,omitempty"`
+	Parity  string `json:"parity,omitempty"`
+	Text    string `json:"text,omitempty"`
+}
+
+// defaultSessionLayout returns the layout written by FileToQRCodes
+func defaultSessionLayout() SessionLayout {
+	return SessionLayout{
+		QRCodes: "qrcodes",
+		Data:    "data",
+	}
+}
+
+// SessionSettings holds the encoder settings that affect the generated artifacts
+type SessionSettings struct {
+	NumChunks     int `json:"num_chunks"`
+	RecoveryLevel int `json:"recovery_level"`
+	// AutoRecoveryLevel is set when every chunk has its own recovery level, at
+	// least RecoveryLevel
+	AutoRecoveryLevel bool `json:"auto_recovery_level,omitempty"`
+	QRSize            int  `json:"qr_size"`
+	MinQRSize         int  `json:"min_qr_size"`
+	MaxQRSize         int  `json:"max_qr_size"`
+	AutoAdjustQRSize  bool `json:"auto_adjust_qr_size"`
+	PayloadFormat     int  `json:"payload_format"`
+	// Codec is the name of the ChunkCodec of text payloads, empty for base64
+	Codec string `json:"codec,omitempty"`
+	// Protocol is the name of the protocol the QR codes follow, empty for
+	// ProtocolNative
+	Protocol  string `json:"protocol,omitempty"`
+	NextHints int    `json:"next_hints,omitempty"`
+	// FileID is the ID of the file in a batch, see FilesToQRCodes
+	FileID string `json:"file_id,omitempty"`
+	// ChecksumCaption is set when QR code images carry a checksum caption
+	ChecksumCaption bool `json:"checksum_caption,omitempty"`
+	// ImageFormat is the ImageFormat of the QR code images, PNG if not set
+	ImageFormat int `json:"image_format,omitempty"`
+	// TextFallback is set when every chunk is also written as a text file
+	TextFallback bool `json:"text_fallback,omitempty"`
+	// Parity is the number of parity chunks in percent of the data chunks
+	Parity int `json:"parity,omitempty"`
+	// NoRawData is set when the raw data of the chunks is not written, see
+	// QRFileTransfer.SetEmitRawData
+	NoRawData bool `json:"no_raw_data,omitempty"`
+	// Dedup is set when identical chunks are only encoded once, see
+	// QRFileTransfer.SetDedup
+	Dedup bool `json:"dedup,omitempty"`
+	// QROptions fingerprints the colors, quiet zone, and logo of the QR code
+	// images, see QRFileTransfer.SetQROptions, empty for the defaults
+	QROptions string `json:"qr_options,omitempty"`
+	// UniformQRVersion is the version of every QR code, see
+	// QRFileTransfer.SetUniformQRVersion, 0 for the smallest fitting each chunk
+	UniformQRVersion int `json:"uniform_qr_version,omitempty"`
+	// PNGOptions fingerprints the compression and resolution of the PNG images,
+	// see QRFileTransfer.SetPNGOptions, empty for the defaults
+	PNGOptions string `json:"png_options,omitempty"`
+	// ContentAddressed is set when the files of the chunks are named after the
+	// hash of their content, see QRFileTransfer.SetContentAddressed
+	ContentAddressed bool `json:"content_addressed,omitempty"`
+	// VolumeSize is the most QR codes of a volume directory, see
+	// QRFileTransfer.SetVolumeSize, 0 if the QR codes are not split into volumes
+	VolumeSize int `json:"volume_size,omitempty"`
+}
+
+// SessionChunk describes a single chunk of a session
+type SessionChunk struct {
+	// Name is the chunk name shared by its QR code and data file (without extension)
+	Name string `json:"name"`
+	// Hash is the hex encoded SHA-256 of the chunk data
+	Hash string `json:"hash"`
+	// Size is the size of the chunk data in bytes
+	Size int64 `json:"size,omitempty"`
+	// QRVersion is the version of the QR code holding the chunk, once generated
+	QRVersion int `json:"qr_version,omitempty"`
+	// RecoveryLevel is the name of the recovery level of the QR code with
+	// automatic recovery levels, see QRFileTransfer.SetAutoRecoveryLevel
+	RecoveryLevel string `json:"recovery_level,omitempty"`
+	// QRHash is the hex encoded SHA-256 of the content of the QR code, which
+	// names its image in a content-addressed session
+	QRHash string `json:"qr_hash,omitempty"`
+	// Volume is the number, from 1, of the volume directory holding the files of
+	// the chunk, 0 if the session is not split into volumes
+	Volume int `json:"volume,omitempty"`
+}
+
+// LoadSession reads the session file from an output directory.
+// It returns an error wrapping os.ErrNotExist if the directory has no session.
+func LoadSession(dir string) (*Session, error) {
+	return loadSession(osFs, dir)
+}
+
+// loadSession is LoadSession on the file system fsys
+func loadSession(fsys afero.Fs, dir string) (*Session, error) {
+	data, err := afero.ReadFile(fsys, filepath.Join(dir, SessionFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %w", err)
+	}
+
+	if s.Version > SessionVersion {
+		return nil, fmt.Errorf("%w: session version %d (newest supported is %d)", ErrUnsupportedVersion, s.Version, SessionVersion)
+	}
+
+	s.dir = dir
+
+	return &s, nil
+}
+
+// Dir returns the session directory the session was loaded from
+func (s *Session) Dir() string {
+	return s.dir
+}
+
+// QRCodesDir returns the directory holding the QR code images
+func (s *Session) QRCodesDir() string {
+	return s.subdir(s.Layout.QRCodes)
+}
+
+// DataDir returns the directory holding the raw chunk data, or "" if the session has none
+func (s *Session) DataDir() string {
+	return s.subdir(s.Layout.Data)
+}
+
+// TextDir returns the directory holding the text fallback of the chunks, or "" if
+// the session has none
+func (s *Session) TextDir() string {
+	return s.subdir(s.Layout.Text)
+}
+
+// ParityDir returns the directory holding parity chunks, or "" if the session has none
+func (s *Session) ParityDir() string {
+	return s.subdir(s.Layout.Parity)
+}
+
+// QRCodeFile returns the path of the QR code image of the named chunk, or "" if the
+// session has no QR codes directory.
+func (s *Session) QRCodeFile(name string) string {
+	if s.Layout.QRCodes == "" {
+		return ""
+	}
+
+	c := s.artifactChunk(name)
+	qrName, _ := s.artifactNames(c)
+
+	return filepath.Join(s.subdir(s.volumeDir(c, s.Layout.QRCodes)), qrName+ImageFormat(s.Settings.ImageFormat).Ext())
+}
+
+// QRCodeFiles returns the paths of the QR code images of the chunks and then the
+// parity chunks of the session in order, or nil if the session has no QR codes
+// directory.
+func (s *Session) QRCodeFiles() []string {
+	if s.QRCodesDir() == "" {
+		return nil
+	}
+
+	files := make([]string, 0, len(s.Chunks)+len(s.Parity))
+	for _, chunks := range [][]SessionChunk{s.Chunks, s.Parity} {
+		for _, c := range chunks {
+			files = append(files, s.QRCodeFile(c.Name))
+		}
+	}
+
+	return files
+}
+
+// QRCodesDirs returns the directories holding the QR code images: the QR codes
+// directory, or that of every volume of a session split into volumes, see
+// QRFileTransfer.SetVolumeSize. It returns nil if the session has no QR codes
+// directory.
+func (s *Session) QRCodesDirs() []string {
+	if s.QRCodesDir() == "" {
+		return nil
+	}
+
+	if s.Settings.VolumeSize == 0 {
+		return []string{s.QRCodesDir()}
+	}
+
+	dirs := make([]string, 0, s.VolumeCount())
+	for volume := 1; volume <= s.VolumeCount(); volume++ {
+		dirs = append(dirs, s.subdir(filepath.Join(volumeDirName(volume), s.Layout.QRCodes)))
+	}
+
+	return dirs
+}
+
+// ParityFile returns the path of the data file of the named parity chunk, or "" if
+// the session has no parity directory.
+func (s *Session) ParityFile(name string) string {
+	if s.Layout.Parity == "" {
+		return ""
+	}
+
+	c := s.artifactChunk(name)
+	_, dataName := s.artifactNames(c)
+
+	return filepath.Join(s.subdir(s.volumeDir(c, s.Layout.Parity)), dataName+".dat")
+}
+
+// contentAddressLength is the number of hex digits of the SHA-256 naming the files
+// of a content-addressed session, whose 64 bits keep collisions out of reach
+const contentAddressLength = 16
+
+// artifactNames returns the names, without extension, of the QR code image and
+// text file of chunk c, and of its data file: the chunk name, or in a
+// content-addressed session the first contentAddressLength hex digits of the
+// SHA-256 of the QR code content and of the chunk data
+func (s *Session) artifactNames(c *SessionChunk) (qrName, dataName string) {
+	qrName, dataName = c.Name, c.Name
+
+	if s.Settings.ContentAddressed && c.QRHash != "" {
+		qrName = c.QRHash[:min(len(c.QRHash), contentAddressLength)]
+	}
+
+	if s.Settings.ContentAddressed && c.Hash != "" {
+		dataName = c.Hash[:min(len(c.Hash), contentAddressLength)]
+	}
+
+	return qrName, dataName
+}
+
+// artifactChunk returns the named chunk or parity chunk of a session whose files
+// are not named after their chunk or are split into volumes, and otherwise, or if
+// the session does not list it, a chunk of that name only
+func (s *Session) artifactChunk(name string) *SessionChunk {
+	if !s.Settings.ContentAddressed && s.Settings.VolumeSize == 0 {
+		return &SessionChunk{Name: name}
+	}
+
+	if c := s.chunk(name); c != nil {
+		return c
+	}
+
+	if c := s.parityChunk(name); c != nil {
+		return c
+	}
+
+	return &SessionChunk{Name: name}
+}
+
+// volumeDirName returns the name of the directory of a volume numbered from 1,
+// e.g. "vol001"
+func volumeDirName(volume int) string {
+	return fmt.Sprintf("vol%03d", volume)
+}
+
+// volumeDir returns the layout directory dir of the volume of chunk c, relative
+// to the session directory, dir itself for a chunk outside of any volume
+func (s *Session) volumeDir(c *SessionChunk, dir string) string {
+	if c.Volume == 0 {
+		return dir
+	}
+
+	return filepath.Join(volumeDirName(c.Volume), dir)
+}
+
+// VolumeCount returns the number of volumes the QR codes of the session are split
+// into, 0 if they are not, see QRFileTransfer.SetVolumeSize
+func (s *Session) VolumeCount() int {
+	count := 0
+	for _, chunks := range [][]SessionChunk{s.Chunks, s.Parity} {
+		for _, c := range chunks {
+			count = max(count, c.Volume)
+		}
+	}
+
+	return count
+}
+
+// assignVolumes deals the chunks and then the parity chunks of the session into
+// volumes of at most Settings.VolumeSize QR codes in order, the parity chunks
+// starting a volume of their own, which a subset of the data volumes can be
+// joined with
+func (s *Session) assignVolumes() {
+	size := s.Settings.VolumeSize
+	if size == 0 {
+		return
+	}
+
+	for i := range s.Chunks {
+		s.Chunks[i].Volume = i/size + 1
+	}
+
+	first := (len(s.Chunks) + size - 1) / size
+
+	for i := range s.Parity {
+		s.Parity[i].Volume = first + i/size + 1
+	}
+}
+
+// makeVolumeDirs creates the layout directories the chunks of every volume of the
+// session are written to in workDir
+func (s *Session) makeVolumeDirs(fsys afero.Fs, workDir string) error {
+	dirs := make(map[string]bool)
+
+	for i := range s.Chunks {
+		if c := &s.Chunks[i]; c.Volume > 0 {
+			for _, path := range s.chunkArtifacts(workDir, c) {
+				dirs[filepath.Dir(path)] = true
+			}
+		}
+	}
+
+	for i := range s.Parity {
+		if c := &s.Parity[i]; c.Volume > 0 {
+			dirs[filepath.Join(workDir, s.volumeDir(c, s.Layout.QRCodes))] = true
+			dirs[filepath.Join(workDir, s.volumeDir(c, s.Layout.Parity))] = true
+		}
+	}
+
+	for dir := range dirs {
+		if err := fsys.MkdirAll(dir, 0750); err != nil {
+			return fmt.Errorf("failed to create volume directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// subdir resolves a layout entry against the session directory
+func (s *Session) subdir(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return filepath.Join(s.dir, name)
+}
+
+// save writes the session file to an output directory.
+// The file is written under a temporary name and renamed so that a crash never
+// leaves a truncated session behind.
+func (s *Session) save(fsys afero.Fs, dir string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	if err := WriteFileAtomic(fsys, filepath.Join(dir, SessionFileName), data, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+
+	return nil
+}
+
+// matches reports whether the session was produced from the same input and settings
+func (s *Session) matches(inputHash string, settings SessionSettings) bool {
+	return s.File.Hash == inputHash && s.Settings == settings
+}
+
+// chunkIndices returns the index in the file of every chunk of the session in
+// order, skipping the chunks duplicating an earlier one
+func (s *Session) chunkIndices() []int {
+	refs := s.refIndices()
+	indices := make([]int, 0, len(s.Chunks))
+
+	for index := 0; len(indices) < len(s.Chunks); index++ {
+		if _, ok := refs[index]; !ok {
+			indices = append(indices, index)
+		}
+	}
+
+	return indices
+}
+
+// refIndices maps the index of every chunk duplicating an earlier one to the index
+// of that chunk
+func (s *Session) refIndices() map[int]int {
+	refs := make(map[int]int, len(s.Refs))
+	for _, r := range s.Refs {
+		refs[r.Index] = r.Ref
+	}
+
+	return refs
+}
+
+// chunk returns the chunk with the given name, or nil if the session has none
+func (s *Session) chunk(name string) *SessionChunk {
+	return s.chunkNames.lookup(s.Chunks, name)
+}
+
+// parityChunk returns the parity chunk with the given name, or nil if the session
+// has none
+func (s *Session) parityChunk(name string) *SessionChunk {
+	return s.parityNames.lookup(s.Parity, name)
+}
+
+// uniformQRVersionNumber returns the version of every QR code with
+// SetUniformQRVersion, 0 otherwise
+func (q *QRFileTransfer) uniformQRVersionNumber() int {
+	if !q.uniformQRVersion {
+		return 0
+	}
+
+	return q.targetQRVersion
+}
+
+// sessionSettings returns the current encoder settings for a run with numChunks chunks
+func (q *QRFileTransfer) sessionSettings(numChunks int) SessionSettings {
+	return SessionSettings{
+		NumChunks:         numChunks,
+		RecoveryLevel:     int(q.recoveryLevel),
+		AutoRecoveryLevel: q.autoRecoveryLevel,
+		QRSize:            q.qrSize,
+		MinQRSize:         q.minQRSize,
+		MaxQRSize:         q.maxQRSize,
+		AutoAdjustQRSize:  q.autoAdjustQRSize,
+		PayloadFormat:     int(q.payloadFormat),
+		Codec:             q.codec,
+		Protocol:          q.protocolName(),
+		NextHints:         q.nextHints,
+		FileID:            q.fileID,
+		ChecksumCaption:   q.checksumCaption,
+		ImageFormat:       int(q.imageFormat),
+		TextFallback:      q.textFallback,
+		Parity:            q.parity,
+		NoRawData:         q.noRawData && q.framer == nil,
+		Dedup:             q.dedup && q.framer == nil,
+		QROptions:         q.qrOptionsID,
+		UniformQRVersion:  q.uniformQRVersionNumber(),
+		PNGOptions:        pngOptionsID(q.pngOptions),
+		ContentAddressed:  q.contentAddressed,
+		VolumeSize:        q.volumeSize,
+	}
+}
+
+// loadPreviousSession returns the session of a previous run in outDir, or nil if
+// there is none or it cannot be read. An unreadable session is treated as absent
+// so that the run simply regenerates everything.
+func loadPreviousSession(fsys afero.Fs, outDir string) *Session {
+	s, err := loadSession(fsys, outDir)
+	if err != nil {
+		return nil
+	}
+
+	return s
+}
+
+// prepareSessionDir returns the staging directory a session for outDir is built in.
+// An interrupted run leaves its staging directory behind and is resumed from it.
+// A published session is copied into staging while it is updated, so readers never
+// observe a session directory that is only partially rewritten, and a run that
+// fails leaves the published session as it was.
+func prepareSessionDir(fsys afero.Fs, outDir string) (string, error) {
+	staging := filepath.Clean(outDir) + stagingSuffix
+
+	if info, err := fsys.Stat(staging); err == nil && info.IsDir() {
+		return staging, nil
+	}
+
+	entries, err := afero.ReadDir(fsys, outDir)
+
+	switch {
+	case os.IsNotExist(err):
+		// Nothing has been published yet
+	case err != nil:
+		return "", fmt.Errorf("failed to read output directory: %w", err)
+	case fileExists(fsys, filepath.Join(outDir, SessionFileName)):
+		if err := copySessionDir(fsys, outDir, staging); err != nil {
+			return "", fmt.Errorf("failed to reopen session directory: %w", err)
+		}
+
+		return staging, nil
+	case len(entries) == 0:
+		if err := fsys.Remove(outDir); err != nil {
+			return "", fmt.Errorf("failed to replace empty output directory: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("output directory %s is not empty and does not contain a session", outDir)
+	}
+
+	if err := fsys.MkdirAll(staging, 0750); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	return staging, nil
+}
+
+// copySessionDir copies the published session in outDir to staging. The copy is
+// made in a temporary directory renamed to staging once complete, so an interrupted
+// copy is not resumed from as if it were the whole session.
+func copySessionDir(fsys afero.Fs, outDir, staging string) (err error) {
+	tmp, err := afero.TempDir(fsys, filepath.Dir(staging), "."+filepath.Base(staging)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = fsys.RemoveAll(tmp)
+		}
+	}()
+
+	err = afero.Walk(fsys, outDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(tmp, rel)
+
+		switch {
+		case info.IsDir():
+			if err := fsys.MkdirAll(target, 0750); err != nil {
+				return err
+			}
+
+			return fsys.Chmod(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			// The copies keep their modification time, as a rename would
+			if err := copyFile(fsys, path, target, info.Mode().Perm()); err != nil {
+				return err
+			}
+
+			return fsys.Chtimes(target, info.ModTime(), info.ModTime())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return fsys.Rename(tmp, staging)
+}
+
+// copyFile copies the regular file at src to dst with the permissions perm
+func copyFile(fsys afero.Fs, src, dst string, perm os.FileMode) (err error) {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := fsys.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+
+	return err
+}
+
+// publishSessionDir moves a completed staging directory to outDir. An empty
+// directory at outDir, e.g. one created by the caller, is replaced, and so is a
+// published session, moved aside until the new one is in place and restored if it
+// cannot be.
+func publishSessionDir(fsys afero.Fs, staging, outDir string) error {
+	previous := filepath.Clean(outDir) + previousSuffix
+
+	// A run interrupted while publishing leaves the session it replaced behind
+	if err := fsys.RemoveAll(previous); err != nil {
+		return fmt.Errorf("failed to remove previous session directory: %w", err)
+	}
+
+	replaced := false
+
+	if entries, err := afero.ReadDir(fsys, outDir); err == nil && len(entries) == 0 {
+		if err := fsys.Remove(outDir); err != nil {
+			return fmt.Errorf("failed to replace empty output directory: %w", err)
+		}
+	} else if err == nil && fileExists(fsys, filepath.Join(outDir, SessionFileName)) {
+		if err := fsys.Rename(outDir, previous); err != nil {
+			return fmt.Errorf("failed to replace session directory: %w", err)
+		}
+
+		replaced = true
+	}
+
+	if err := fsys.Rename(staging, outDir); err != nil {
+		if replaced {
+			_ = fsys.Rename(previous, outDir)
+		}
+
+		return fmt.Errorf("failed to publish session directory: %w", err)
+	}
+
+	if replaced {
+		if err := fsys.RemoveAll(previous); err != nil {
+			return fmt.Errorf("failed to remove previous session directory: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// hashReader returns the hex encoded SHA-256 of everything read from r
+func hashReader(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashBytes returns the hex encoded SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// fileExists reports whether path exists and is a regular file
+func fileExists(fsys afero.Fs, path string) bool {
+	info, err := fsys.Stat(path)
+
+	return err == nil && info.Mode().IsRegular()
+}
+
+// WriteFileAtomic writes data to path in fsys like os.WriteFile, into a temporary
+// file next to path that is flushed to disk and renamed into place, so that a crash
+// or an interrupt never leaves a truncated file at path, e.g. a chunk decoded by
+// read that a later run would take for complete
+func WriteFileAtomic(fsys afero.Fs, path string, data []byte, perm os.FileMode) error {
+	return split.PublishFile(fsys, path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+
+		return err
+	}, nil)
+}
+
+// removeStaleArtifacts deletes the QR codes, data files, and text files of a previous
+// session in workDir that are not part of the new one, so they cannot be mistaken for
+// current chunks, and all of its parity chunks. QR codes of a previous session
+// written in another image format are removed too, as are the text files of a
+// session without text fallback, the data files of a session without raw data, and
+// the files of a session named otherwise, see QRFileTransfer.SetContentAddressed.
+func removeStaleArtifacts(fsys afero.Fs, previous, current *Session, workDir string) error {
+	planned := make(map[string]bool)
+
+	for i := range current.Chunks {
+		for _, path := range current.chunkArtifacts(workDir, &current.Chunks[i]) {
+			planned[path] = true
+		}
+	}
+
+	var paths []string
+
+	for i := range previous.Chunks {
+		for _, path := range previous.chunkArtifacts(workDir, &previous.Chunks[i]) {
+			if !planned[path] {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	// Parity chunks are computed from all data chunks, none is kept
+	previousExt := ImageFormat(previous.Settings.ImageFormat).Ext()
+
+	for i := range previous.Parity {
+		c := &previous.Parity[i]
+		qrName, dataName := previous.artifactNames(c)
+		paths = append(paths, filepath.Join(workDir, previous.volumeDir(c, previous.Layout.QRCodes), qrName+previousExt),
+			filepath.Join(workDir, previous.volumeDir(c, previous.Layout.Parity), dataName+".dat"))
+	}
+
+	// Volume manifests are written again once the new session is complete
+	for volume := 1; volume <= previous.VolumeCount(); volume++ {
+		paths = append(paths, filepath.Join(workDir, volumeDirName(volume), ManifestFileName))
+	}
+
+	for _, path := range paths {
+		if err := fsys.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale artifact %s: %w", path, err)
+		}
+	}
+
+	// Remove the directories left empty by a change of volumes, those of new
+	// volumes are created again
+	var dirs []string
+
+	for volume := 1; volume <= previous.VolumeCount(); volume++ {
+		dirs = append(dirs, volumeDirName(volume))
+	}
+
+	if current.Settings.VolumeSize > 0 {
+		dirs = append(dirs, "")
+	}
+
+	for _, dir := range dirs {
+		for _, layoutDir := range []string{previous.Layout.QRCodes, previous.Layout.Data, previous.Layout.Text, previous.Layout.Parity} {
+			if layoutDir != "" {
+				removeEmptyDir(fsys, filepath.Join(workDir, dir, layoutDir))
+			}
+		}
+
+		if dir != "" {
+			removeEmptyDir(fsys, filepath.Join(workDir, dir))
+		}
+	}
+
+	return nil
+}
+
+// removeEmptyDir removes the directory at path if it is empty
+func removeEmptyDir(fsys afero.Fs, path string) {
+	if entries, err := afero.ReadDir(fsys, path); err == nil && len(entries) == 0 {
+		_ = fsys.Remove(path)
+	}
+}
+
+// chunkArtifacts returns the paths of the QR code image, data file, and text file
+// of data chunk c of the session in workDir, those of its layout
+func (s *Session) chunkArtifacts(workDir string, c *SessionChunk) []string {
+	qrName, dataName := s.artifactNames(c)
+	paths := []string{filepath.Join(workDir, s.volumeDir(c, s.Layout.QRCodes), qrName+ImageFormat(s.Settings.ImageFormat).Ext())}
+
+	if s.Layout.Data != "" {
+		paths = append(paths, filepath.Join(workDir, s.volumeDir(c, s.Layout.Data), dataName+".dat"))
+	}
+
+	if s.Layout.Text != "" {
+		paths = append(paths, filepath.Join(workDir, s.volumeDir(c, s.Layout.Text), qrName+TextChunkExt))
+	}
+
+	return paths
+}
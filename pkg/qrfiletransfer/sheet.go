@@ -0,0 +1,178 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/pdf"
+)
+
+const (
+	// sheetNameSize and sheetInfoSize are the font sizes of the two header lines
+	// of a sheet cell in points
+	sheetNameSize = 8
+	sheetInfoSize = 7
+	// sheetFooterSize is the font size of the page footer in points
+	sheetFooterSize = 7
+	// sheetCellPadding is the space between the edges of a cell and its content
+	sheetCellPadding = 10
+	// sheetCutMarkSize is the length of the arms of a cut mark in points
+	sheetCutMarkSize = 6
+	// sheetHashLength is the number of hex digits of the chunk hash in a header
+	sheetHashLength = 8
+	// sheetDateLayout is the layout of the date in cell headers
+	sheetDateLayout = "2006-01-02"
+)
+
+// SheetLayout describes the pages of a paper backup sheet
+type SheetLayout struct {
+	// PageWidth and PageHeight are the size of a page in points
+	PageWidth  float64
+	PageHeight float64
+	// Margin is the blank border around every page in points
+	Margin float64
+	// Columns and Rows set the grid of cells of a page, one QR code per cell
+	Columns int
+	Rows    int
+	// Date is printed in the header of every cell, the current date if zero
+	Date time.Time
+}
+
+// DefaultSheetLayout returns the default sheet layout: a grid of 2 by 3 cells on A4
+// pages with a half inch margin
+func DefaultSheetLayout() SheetLayout {
+	return SheetLayout{
+		PageWidth:  pdf.A4Width,
+		PageHeight: pdf.A4Height,
+		Margin:     36,
+		Columns:    2,
+		Rows:       3,
+	}
+}
+
+// FileToSheet encodes a file with the settings of q and writes the QR codes as a
+// paper backup sheet to w, see WriteSheet. The QR code images are only built in a
+// temporary directory.
+func (q *QRFileTransfer) FileToSheet(filePath string, w io.Writer, layout SheetLayout) (err error) {
+	tempDir, releaseTemp, err := q.makeTempDir("qrfiletransfer_sheet_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		if removeErr := releaseTemp(); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove temporary directory: %w", removeErr)
+		}
+	}()
+
+	// The images are not used, SVG images are the cheapest to write
+	sq := *q
+	sq.imageFormat = ImageFormatSVG
+
+	sessionDir := filepath.Join(tempDir, "session")
+	if err := sq.FileToQRCodes(filePath, sessionDir); err != nil {
+		return err
+	}
+
+	return WriteSheet(w, sessionDir, layout)
+}
+
+// WriteSheet writes the QR codes of the complete session, or of every file of the
+// batch, in dir to w as a PDF sheet in the spirit of paperkey: the codes are laid
+// out in a grid of cells separated by cut marks, and every cell is headed with the
+// file name, the chunk number as x/y, the date, and the first digits of the
+// SHA-256 of the chunk, so every cut out code stays identifiable on its own. The
+// footer of every page carries the size and full SHA-256 of the file. The QR codes
+// hold the same payloads as the QR code images of the session.
+func WriteSheet(w io.Writer, dir string, layout SheetLayout) error {
+	if layout.Columns < 1 || layout.Rows < 1 {
+		return fmt.Errorf("invalid sheet grid %dx%d", layout.Columns, layout.Rows)
+	}
+
+	codes, err := collectPaperCodes(osFs, dir)
+	if err != nil {
+		return err
+	}
+
+	date := layout.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	perPage := layout.Columns * layout.Rows
+	pages := (len(codes) + perPage - 1) / perPage
+	doc := pdf.New(layout.PageWidth, layout.PageHeight)
+
+	for p := range pages {
+		end := min(len(codes), (p+1)*perPage)
+		layout.drawPage(doc.AddPage(), codes[p*perPage:end], date.Format(sheetDateLayout), p+1, pages)
+	}
+
+	if _, err := doc.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write sheet: %w", err)
+	}
+
+	return nil
+}
+
+// drawPage draws the cells of page number of pages
+func (l SheetLayout) drawPage(page *pdf.Page, codes []paperCode, date string, number, pages int) {
+	footerHeight := 2 * sheetFooterSize
+	gridTop := l.PageHeight - l.Margin
+	gridBottom := l.Margin + float64(footerHeight)
+	cellWidth := (l.PageWidth - 2*l.Margin) / float64(l.Columns)
+	cellHeight := (gridTop - gridBottom) / float64(l.Rows)
+
+	// Cut marks at every corner of the grid cells
+	for row := 0; row <= l.Rows; row++ {
+		for col := 0; col <= l.Columns; col++ {
+			x := l.Margin + float64(col)*cellWidth
+			y := gridTop - float64(row)*cellHeight
+
+			page.Line(x-sheetCutMarkSize, y, x+sheetCutMarkSize, y, 0.25)
+			page.Line(x, y-sheetCutMarkSize, x, y+sheetCutMarkSize, 0.25)
+		}
+	}
+
+	headerHeight := float64(sheetNameSize + sheetInfoSize + 6)
+	textWidth := cellWidth - 2*sheetCellPadding
+	side := min(textWidth, cellHeight-2*sheetCellPadding-headerHeight)
+
+	for i, code := range codes {
+		left := l.Margin + float64(i%l.Columns)*cellWidth + sheetCellPadding
+		top := gridTop - float64(i/l.Columns)*cellHeight - sheetCellPadding
+
+		info := fmt.Sprintf("Chunk %d/%d   %s   SHA-256 %s", code.index+1, code.total, date, code.hash[:sheetHashLength])
+
+		page.Text(left, top-sheetNameSize, sheetNameSize, fitText(code.header, sheetNameSize, textWidth))
+		page.Text(left, top-sheetNameSize-sheetInfoSize-3, sheetInfoSize, fitText(info, sheetInfoSize, textWidth))
+
+		x := left + (textWidth-side)/2
+		y := top - headerHeight - side
+		drawBitmap(page, code.bitmap, x, y, side)
+	}
+
+	pageLabel := fmt.Sprintf("Page %d of %d", number, pages)
+	pageLabelWidth := pdf.TextWidth(pageLabel, sheetFooterSize)
+	file := codes[0].file
+	footer := fmt.Sprintf("%s, %d bytes, SHA-256 %s", file.Name, file.Size, file.Hash)
+
+	page.Text(l.Margin, l.Margin, sheetFooterSize, fitText(footer, sheetFooterSize, l.PageWidth-2*l.Margin-pageLabelWidth-sheetCellPadding))
+	page.Text(l.PageWidth-l.Margin-pageLabelWidth, l.Margin, sheetFooterSize, pageLabel)
+}
+
+// fitText shortens text with an ellipsis so that it fits in width points at size
+func fitText(text string, size, width float64) string {
+	if pdf.TextWidth(text, size) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.TextWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
@@ -0,0 +1,105 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/pdf"
+)
+
+func TestFileToSheet(t *testing.T) {
+	testDir := t.TempDir()
+
+	content := []byte(strings.Repeat("Kept in a drawer. ", 300))
+
+	testFilePath := filepath.Join(testDir, "drawer.txt")
+	if err := os.WriteFile(testFilePath, content, 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	layout := DefaultSheetLayout()
+	layout.Columns, layout.Rows = 2, 2
+	layout.Date = time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+
+	var b bytes.Buffer
+	if err := NewQRFileTransfer().FileToSheet(testFilePath, &b, layout); err != nil {
+		t.Fatalf("FileToSheet failed: %v", err)
+	}
+
+	pages := paperPages(t, b.String())
+	if len(pages) == 0 {
+		t.Fatal("Sheet has no pages")
+	}
+
+	var payloads []*ChunkPayload
+
+	for p, page := range pages {
+		if want := fmt.Sprintf("SHA-256 %s)", hashBytes(content)); !strings.Contains(page, want) {
+			t.Errorf("Footer of page %d does not carry the file hash", p+1)
+		}
+
+		// A cut mark at every corner of the 2x2 grid
+		if marks := strings.Count(page, " l S"); marks != 2*9 {
+			t.Errorf("Page %d has %d cut mark lines, want %d", p+1, marks, 2*9)
+		}
+
+		for _, img := range paperCodeImages(page) {
+			payloads = append(payloads, decodePaperCode(t, img))
+		}
+	}
+
+	if want := (len(payloads) + 3) / 4; len(pages) != want {
+		t.Errorf("Expected %d pages for %d codes, got %d", want, len(payloads), len(pages))
+	}
+
+	// The sheet holds the chunks of the file encoded with the same settings, in order,
+	// each headed with its number and hash
+	outDir := filepath.Join(testDir, "session")
+	if err := NewQRFileTransfer().FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	if len(payloads) != len(session.Chunks) {
+		t.Fatalf("Sheet holds %d codes, want %d", len(payloads), len(session.Chunks))
+	}
+
+	for i, payload := range payloads {
+		chunk := session.Chunks[i]
+		if payload.Name != chunk.Name {
+			t.Errorf("Code %d holds chunk %s, want %s", i, payload.Name, chunk.Name)
+		}
+
+		// The metadata chunk records the time its session was split, the data chunks
+		// are the same in both sessions
+		hash := hashBytes(payload.Data)
+		if i > 0 && hash != chunk.Hash {
+			t.Errorf("Code %d does not hold the data of chunk %s", i, chunk.Name)
+		}
+
+		header := fmt.Sprintf("(Chunk %d/%d   2024-05-17   SHA-256 %s)", i+1, len(payloads), hash[:sheetHashLength])
+		if !strings.Contains(pages[i/4], header) {
+			t.Errorf("Page %d has no header %s", i/4+1, header)
+		}
+	}
+}
+
+func TestFitText(t *testing.T) {
+	if got := fitText("short", 8, 100); got != "short" {
+		t.Errorf("fitText() = %q, want the text unchanged", got)
+	}
+
+	got := fitText(strings.Repeat("long name ", 10), 8, 100)
+	if !strings.HasSuffix(got, "...") || pdf.TextWidth(got, 8) > 100 {
+		t.Errorf("fitText() = %q, want a shortened text of at most 100 points", got)
+	}
+}
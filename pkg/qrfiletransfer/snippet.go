@@ -0,0 +1,272 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+const (
+	// SnippetName is the file name a snippet is recorded under, so that read and
+	// join restore a plain snippet as a text file
+	SnippetName = "snippet.txt"
+
+	// EncryptedSnippetName is the file name an encrypted snippet is recorded under
+	EncryptedSnippetName = "snippet.enc"
+
+	// MaxSnippetSize is the largest snippet, encryption included, that is encoded
+	// into a single QR code, see SnippetToQRCode
+	MaxSnippetSize = 500
+
+	// snippetMagic and snippetVersion start an encrypted snippet, laid out as:
+	//
+	//	magic "QFE" | version (1 byte) | salt (16 bytes) | nonce (12 bytes) | AES-256-GCM ciphertext
+	snippetMagic   = "QFE"
+	snippetVersion = 1
+
+	// snippetSaltSize is the size of the salt of the passphrase
+	snippetSaltSize = 16
+
+	// snippetKDFIterations is the number of PBKDF2-HMAC-SHA256 iterations deriving
+	// the key from the passphrase
+	snippetKDFIterations = 600000
+)
+
+var (
+	// ErrSnippetTooLong is returned for a snippet that does not fit in a single QR code
+	ErrSnippetTooLong = errors.New("snippet too long for a single QR code")
+
+	// ErrPassphraseRequired is returned when decoding an encrypted snippet without
+	// a passphrase
+	ErrPassphraseRequired = errors.New("snippet is encrypted, a passphrase is required")
+
+	// ErrWrongPassphrase is returned when an encrypted snippet cannot be decrypted
+	// with the passphrase, or was altered
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted snippet")
+)
+
+// encryptedSnippetOverhead is the number of bytes encryption adds to a snippet
+const encryptedSnippetOverhead = len(snippetMagic) + 1 + snippetSaltSize + 12 + 16
+
+// SnippetToQRCode encodes snippet, a short text or secret, into a single QR code
+// with the settings of q. The snippet is recorded as a file named SnippetName with
+// the same metadata and payload header as the chunks of any file, so read and join
+// restore it too. With a passphrase, the snippet is encrypted with AES-256-GCM
+// under a key derived from it and recorded as EncryptedSnippetName. It returns an
+// error wrapping ErrSnippetTooLong if the snippet takes more than MaxSnippetSize
+// bytes or does not fit in a QR code at the recovery level of q.
+func (q *QRFileTransfer) SnippetToQRCode(snippet []byte, passphrase string) (code *qrcode.QRCode, err error) {
+	name := SnippetName
+
+	if passphrase != "" {
+		if snippet, err = encryptSnippet(snippet, passphrase); err != nil {
+			return nil, err
+		}
+
+		name = EncryptedSnippetName
+	}
+
+	if len(snippet) > MaxSnippetSize {
+		return nil, fmt.Errorf("%w: %d bytes, at most %d", ErrSnippetTooLong, len(snippet), MaxSnippetSize)
+	}
+
+	tempDir, releaseTemp, err := q.makeTempDir("qrfiletransfer_snippet_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		if removeErr := releaseTemp(); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove temporary directory: %w", removeErr)
+		}
+	}()
+
+	filePath := filepath.Join(tempDir, name)
+	if err := afero.WriteFile(q.fs, filePath, snippet, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write snippet: %w", err)
+	}
+
+	// The images are not used, SVG images are the cheapest to write
+	sq := *q
+	sq.imageFormat = ImageFormatSVG
+	sq.nextHints = 0
+
+	sessionDir := filepath.Join(tempDir, "session")
+	if err := sq.FileToQRCodes(filePath, sessionDir); err != nil {
+		return nil, err
+	}
+
+	session, err := loadSession(q.fs, sessionDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(session.Chunks) != 1 {
+		return nil, fmt.Errorf("%w: split into %d chunks at the recovery level", ErrSnippetTooLong, len(session.Chunks))
+	}
+
+	chunk := session.Chunks[0]
+
+	data, err := afero.ReadFile(q.fs, session.DataFile(chunk.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snippet chunk: %w", err)
+	}
+
+	content, err := q.encodePayload(&ChunkPayload{Name: chunk.Name, Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snippet payload: %w", err)
+	}
+
+	code, err = newQRCode(q.payloadFormat, content, q.recoveryLevel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snippet QR code: %w", err)
+	}
+
+	return code, nil
+}
+
+// QRCodeToSnippet returns the snippet held by the content of a QR code written by
+// SnippetToQRCode, or by split for a file small enough for a single chunk. An
+// encrypted snippet is decrypted with passphrase, it returns ErrPassphraseRequired
+// without one and ErrWrongPassphrase if it does not match.
+func (q *QRFileTransfer) QRCodeToSnippet(content []byte, passphrase string) (_ []byte, err error) {
+	payload, err := q.DecodePayload(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode snippet payload: %w", err)
+	}
+
+	if index, ok := payload.Index(); ok && index != 0 {
+		return nil, fmt.Errorf("QR code holds chunk %d of a file, not a snippet", index)
+	}
+
+	tempDir, releaseTemp, err := q.makeTempDir("qrfiletransfer_snippet_*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		if removeErr := releaseTemp(); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove temporary directory: %w", removeErr)
+		}
+	}()
+
+	chunkPath := filepath.Join(tempDir, payload.Name+".part")
+	if err := afero.WriteFile(q.fs, chunkPath, payload.Data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write snippet chunk: %w", err)
+	}
+
+	snippet, info, err := q.splitter.ReadSingleChunk(chunkPath)
+	if err != nil {
+		return nil, fmt.Errorf("QR code does not hold a snippet: %w", err)
+	}
+
+	if info.Name != EncryptedSnippetName {
+		return snippet, nil
+	}
+
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	return decryptSnippet(snippet, passphrase)
+}
+
+// encryptSnippet encrypts snippet under a key derived from passphrase
+func encryptSnippet(snippet []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, snippetSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	aead, err := snippetCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	header := append(append([]byte(snippetMagic), snippetVersion), salt...)
+	sealed := append(bytes.Clone(header), nonce...)
+
+	// The header is authenticated along with the snippet
+	return aead.Seal(sealed, nonce, snippet, header), nil
+}
+
+// decryptSnippet decrypts a snippet encrypted by encryptSnippet
+func decryptSnippet(sealed []byte, passphrase string) ([]byte, error) {
+	if len(sealed) < encryptedSnippetOverhead || !bytes.HasPrefix(sealed, []byte(snippetMagic)) {
+		return nil, ErrWrongPassphrase
+	}
+
+	if version := sealed[len(snippetMagic)]; version != snippetVersion {
+		return nil, fmt.Errorf("%w: encrypted snippet version %d", ErrUnsupportedVersion, version)
+	}
+
+	headerSize := len(snippetMagic) + 1 + snippetSaltSize
+
+	aead, err := snippetCipher(passphrase, sealed[len(snippetMagic)+1:headerSize])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := sealed[headerSize : headerSize+aead.NonceSize()]
+
+	snippet, err := aead.Open(nil, nonce, sealed[headerSize+aead.NonceSize():], sealed[:headerSize])
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return snippet, nil
+}
+
+// snippetCipher returns the AES-256-GCM cipher keyed by passphrase and salt
+func snippetCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(passphrase), salt, snippetKDFIterations))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	return aead, nil
+}
+
+// pbkdf2SHA256 derives a 32 byte key from password and salt with PBKDF2-HMAC-SHA256
+// (RFC 8018). A single block is needed as the key is as long as the hash, and
+// crypto/pbkdf2 requires a newer Go than the module does.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+
+	u := prf.Sum(nil)
+	key := append([]byte(nil), u...)
+
+	for range iterations - 1 {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+
+		for i := range key {
+			key[i] ^= u[i]
+		}
+	}
+
+	return key
+}
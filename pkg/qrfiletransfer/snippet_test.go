@@ -0,0 +1,118 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// snippetContent renders code and scans it back like a receiver would
+func snippetContent(t *testing.T, code *qrcode.QRCode) []byte {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "snippet.png")
+	if err := code.WriteFile(-6, path); err != nil {
+		t.Fatalf("Failed to write QR code: %v", err)
+	}
+
+	return scanReferenceSample(t, path)
+}
+
+func TestSnippetRoundTrip(t *testing.T) {
+	qrft := NewQRFileTransfer()
+
+	for _, snippet := range []string{"", "correct horse battery staple", "ünïcode sécret\n"} {
+		code, err := qrft.SnippetToQRCode([]byte(snippet), "")
+		if err != nil {
+			t.Fatalf("SnippetToQRCode(%q) failed: %v", snippet, err)
+		}
+
+		got, err := qrft.QRCodeToSnippet(snippetContent(t, code), "")
+		if err != nil {
+			t.Fatalf("QRCodeToSnippet(%q) failed: %v", snippet, err)
+		}
+
+		if string(got) != snippet {
+			t.Fatalf("QRCodeToSnippet = %q, want %q", got, snippet)
+		}
+	}
+}
+
+func TestEncryptedSnippet(t *testing.T) {
+	qrft := NewQRFileTransfer()
+	secret := []byte("api-key-0123456789")
+
+	code, err := qrft.SnippetToQRCode(secret, "hunter2")
+	if err != nil {
+		t.Fatalf("SnippetToQRCode failed: %v", err)
+	}
+
+	content := snippetContent(t, code)
+	if bytes.Contains(content, secret) {
+		t.Fatal("Encrypted snippet QR code holds the secret in clear")
+	}
+
+	if _, err := qrft.QRCodeToSnippet(content, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("QRCodeToSnippet without passphrase = %v, want ErrPassphraseRequired", err)
+	}
+
+	if _, err := qrft.QRCodeToSnippet(content, "hunter3"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("QRCodeToSnippet with a wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+
+	got, err := qrft.QRCodeToSnippet(content, "hunter2")
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Fatalf("QRCodeToSnippet = %q, %v", got, err)
+	}
+}
+
+func TestSnippetTooLong(t *testing.T) {
+	qrft := NewQRFileTransfer()
+
+	if _, err := qrft.SnippetToQRCode(make([]byte, MaxSnippetSize+1), ""); !errors.Is(err, ErrSnippetTooLong) {
+		t.Fatalf("SnippetToQRCode = %v, want ErrSnippetTooLong", err)
+	}
+
+	if _, err := qrft.SnippetToQRCode(make([]byte, MaxSnippetSize-encryptedSnippetOverhead+1), "pass"); !errors.Is(err, ErrSnippetTooLong) {
+		t.Fatalf("SnippetToQRCode = %v, want ErrSnippetTooLong", err)
+	}
+}
+
+func TestSnippetFromSplit(t *testing.T) {
+	testDir := t.TempDir()
+
+	filePath := filepath.Join(testDir, "token")
+	if err := os.WriteFile(filePath, []byte("ghp_token"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	qrft := NewQRFileTransfer()
+
+	outDir := filepath.Join(testDir, "session")
+	if err := qrft.FileToQRCodes(filePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	got, err := qrft.QRCodeToSnippet(scanReferenceSample(t, session.QRCodeFile(session.Chunks[0].Name)), "")
+	if err != nil || string(got) != "ghp_token" {
+		t.Fatalf("QRCodeToSnippet = %q, %v", got, err)
+	}
+}
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11, first 32 bytes
+	got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1))
+	if want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc"; got != want {
+		t.Fatalf("pbkdf2SHA256 = %s, want %s", got, want)
+	}
+}
@@ -65,13 +65,13 @@ func TestSplitPackage(t *testing.T) {
 		t.Fatalf("Failed to list chunk files: %v", err)
 	}
 
-	firstChunk, err := filepath.Glob(filepath.Join(outDir, "*.tmp"))
-	if err != nil {
-		t.Fatalf("Failed to find first chunk: %v", err)
+	// Every chunk, the first one included, has the .part extension
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunk files, got %v", chunks)
 	}
 
-	if len(chunks) == 0 && len(firstChunk) == 0 {
-		t.Fatal("No chunk files were created")
+	if tmpFiles, _ := filepath.Glob(filepath.Join(outDir, "*.tmp")); len(tmpFiles) != 0 {
+		t.Errorf("SplitFile left %v", tmpFiles)
 	}
 
 	// Merge the chunks
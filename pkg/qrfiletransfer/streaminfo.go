@@ -0,0 +1,138 @@
+package qrfiletransfer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// streamInfoMagic and streamInfoVersion start the first line of the stream info
+const (
+	streamInfoMagic   = "QRFT-STREAM"
+	streamInfoVersion = 1
+)
+
+// ErrBadStreamInfo is returned when a side channel does not hold valid stream info
+var ErrBadStreamInfo = errors.New("bad stream info")
+
+// StreamInfo describes the file a stream of QR codes carries. A video holds it in a
+// side channel, such as a subtitle track, for a receiver to check the stream
+// before decoding every frame.
+type StreamInfo struct {
+	// Name is the name of the file
+	Name string
+	// Size is the size of the file in bytes
+	Size int64
+	// Hash is the SHA-256 of the file
+	Hash string
+	// Chunks is the number of chunks of the file
+	Chunks int
+	// Parity is the number of parity chunks
+	Parity int
+}
+
+// NewStreamInfo returns the stream info of the archive described by m
+func NewStreamInfo(m *Manifest) *StreamInfo {
+	return &StreamInfo{
+		Name:   m.File.Name,
+		Size:   m.File.Size,
+		Hash:   m.File.SHA256,
+		Chunks: m.ChunkCount,
+		Parity: len(m.Parity),
+	}
+}
+
+// String describes the stream, e.g. "notes.txt, 6000 bytes in 3 chunks"
+func (s *StreamInfo) String() string {
+	text := fmt.Sprintf("%s, %d bytes in %d chunks", s.Name, s.Size, s.Chunks)
+	if s.Parity > 0 {
+		text += fmt.Sprintf(" and %d parity chunks", s.Parity)
+	}
+
+	return text
+}
+
+// EncodeStreamInfo returns the text of the stream info:
+//
+//	QRFT-STREAM 1
+//	NAME "notes.txt"
+//	SIZE 6000
+//	SHA256 cc590ea4a65a993948ccc29bc67ec294c5bd991034ac89919a122d4c082bc998
+//	CHUNKS 3
+//	PARITY 1
+//
+// The name is quoted as a Go string so that it fits on a line, and PARITY is left
+// out without parity chunks.
+func EncodeStreamInfo(s *StreamInfo) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%s %d\n", streamInfoMagic, streamInfoVersion)
+	fmt.Fprintf(&b, "NAME %s\n", strconv.Quote(s.Name))
+	fmt.Fprintf(&b, "SIZE %d\n", s.Size)
+	fmt.Fprintf(&b, "SHA256 %s\n", s.Hash)
+	fmt.Fprintf(&b, "CHUNKS %d\n", s.Chunks)
+
+	if s.Parity > 0 {
+		fmt.Fprintf(&b, "PARITY %d\n", s.Parity)
+	}
+
+	return b.Bytes()
+}
+
+// ParseStreamInfo parses the stream info written by EncodeStreamInfo. Text before
+// its first line is skipped, as are the lines of a newer version it does not
+// know. It returns an error wrapping ErrBadStreamInfo if content holds none.
+func ParseStreamInfo(content []byte) (*StreamInfo, error) {
+	start := bytes.Index(content, []byte(streamInfoMagic+" "))
+	if start < 0 {
+		return nil, fmt.Errorf("%w: missing %s line", ErrBadStreamInfo, streamInfoMagic)
+	}
+
+	var (
+		s      StreamInfo
+		chunks bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content[start:]))
+	for scanner.Scan() {
+		keyword, value, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+
+		var err error
+
+		switch keyword {
+		case streamInfoMagic:
+			var v int
+			if v, err = strconv.Atoi(value); err == nil && v > streamInfoVersion {
+				return nil, fmt.Errorf("%w: stream info version %d (newest supported is %d)", ErrUnsupportedVersion, v, streamInfoVersion)
+			}
+		case "NAME":
+			s.Name, err = strconv.Unquote(value)
+		case "SIZE":
+			s.Size, err = strconv.ParseInt(value, 10, 64)
+		case "SHA256":
+			s.Hash = strings.ToLower(value)
+		case "CHUNKS":
+			s.Chunks, err = strconv.Atoi(value)
+			chunks = true
+		case "PARITY":
+			s.Parity, err = strconv.Atoi(value)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid %s line %q", ErrBadStreamInfo, keyword, value)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadStreamInfo, err)
+	}
+
+	if !chunks || s.Chunks < 1 || s.Size < 0 || s.Parity < 0 {
+		return nil, fmt.Errorf("%w: missing or invalid chunk count", ErrBadStreamInfo)
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,51 @@
+package qrfiletransfer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStreamInfoRoundTrip(t *testing.T) {
+	m := &Manifest{
+		File:       ManifestFile{Name: "notes\n\"v2\".txt", Size: 6000, SHA256: "cc590ea4"},
+		ChunkCount: 3,
+		Parity:     []ManifestChunk{{Name: "notes_p0000"}},
+	}
+
+	info := NewStreamInfo(m)
+
+	got, err := ParseStreamInfo(EncodeStreamInfo(info))
+	if err != nil {
+		t.Fatalf("ParseStreamInfo failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(got, info) {
+		t.Errorf("ParseStreamInfo() = %+v, want %+v", got, info)
+	}
+
+	// As extracted from a subtitle track
+	srt := append([]byte("1\n00:00:00,000 --> 00:00:01,000\n"), EncodeStreamInfo(&StreamInfo{Name: "a", Chunks: 1})...)
+	if got, err := ParseStreamInfo(srt); err != nil || got.Name != "a" || got.Chunks != 1 || got.Parity != 0 {
+		t.Errorf("ParseStreamInfo() = %+v, %v, want the stream info of the cue", got, err)
+	}
+}
+
+func TestParseStreamInfoInvalid(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"1\n00:00:00,000 --> 00:00:01,000\nhello\n",
+		"QRFT-STREAM 1\nNAME \"a\"\n",
+		"QRFT-STREAM 1\nNAME a\nCHUNKS 1\n",
+		"QRFT-STREAM 1\nCHUNKS 0\n",
+		"QRFT-STREAM 1\nSIZE x\nCHUNKS 1\n",
+	} {
+		if _, err := ParseStreamInfo([]byte(content)); !errors.Is(err, ErrBadStreamInfo) {
+			t.Errorf("ParseStreamInfo(%q) = %v, want ErrBadStreamInfo", content, err)
+		}
+	}
+
+	if _, err := ParseStreamInfo([]byte("QRFT-STREAM 2\nCHUNKS 1\n")); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("ParseStreamInfo() = %v, want ErrUnsupportedVersion", err)
+	}
+}
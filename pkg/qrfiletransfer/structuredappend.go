@@ -0,0 +1,159 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// structuredAppendMagic starts the frames of ProtocolStructuredAppend as they are
+// stored in the data files:
+//
+//	magic "QSA" | index (1 byte) | total (1 byte) | parity (1 byte) | data
+//
+// The QR codes hold the index, total, and parity in their structured append
+// header and the data alone, see StructuredAppendFrame.
+var structuredAppendMagic = []byte("QSA")
+
+// structuredAppendFrameHeaderLen is the length of the header of a frame
+const structuredAppendFrameHeaderLen = 6
+
+// StructuredAppendFrame returns the frame of ProtocolStructuredAppend of a QR code
+// holding data after the structured append header sa, to receive QR codes decoded
+// by other means than this package, see qrdecode.Symbol
+func StructuredAppendFrame(sa qrcode.StructuredAppend, data []byte) []byte {
+	frame := make([]byte, 0, structuredAppendFrameHeaderLen+len(data))
+	frame = append(frame, structuredAppendMagic...)
+	frame = append(frame, byte(sa.Index), byte(sa.Total), sa.Parity)
+
+	return append(frame, data...)
+}
+
+// parseStructuredAppendFrame splits a frame of ProtocolStructuredAppend into its
+// structured append header and data
+func parseStructuredAppendFrame(content []byte) (qrcode.StructuredAppend, []byte, error) {
+	if len(content) <= structuredAppendFrameHeaderLen || !bytes.HasPrefix(content, structuredAppendMagic) {
+		return qrcode.StructuredAppend{}, nil, errors.New("not a structured append frame")
+	}
+
+	header := content[len(structuredAppendMagic):structuredAppendFrameHeaderLen]
+	sa := qrcode.StructuredAppend{Index: int(header[0]), Total: int(header[1]), Parity: header[2]}
+
+	if sa.Total < 1 || sa.Total > qrcode.MaxStructuredAppendSymbols || sa.Index >= sa.Total {
+		return qrcode.StructuredAppend{}, nil, fmt.Errorf("invalid structured append frame %d of %d", sa.Index, sa.Total)
+	}
+
+	return sa, content[structuredAppendFrameHeaderLen:], nil
+}
+
+// structuredAppendFramer is the framer of ProtocolStructuredAppend
+type structuredAppendFramer struct{}
+
+func (structuredAppendFramer) Name() string { return ProtocolStructuredAppend }
+
+func (structuredAppendFramer) Format() PayloadFormat { return PayloadFormatBinary }
+
+func (structuredAppendFramer) NewFrameEncoder(data []byte, fragmentLen int) (FrameEncoder, error) {
+	if len(data) == 0 || fragmentLen < 1 {
+		return nil, errors.New("structured append frames an empty file or fragment")
+	}
+
+	e := structuredAppendFrameEncoder{data: data, fragmentLen: fragmentLen, parity: qrcode.StructuredAppendParity(data)}
+	if n := e.Fragments(); n > qrcode.MaxStructuredAppendSymbols {
+		return nil, fmt.Errorf("%d bytes need %d QR codes of %d bytes, structured append links at most %d",
+			len(data), n, fragmentLen, qrcode.MaxStructuredAppendSymbols)
+	}
+
+	return e, nil
+}
+
+func (structuredAppendFramer) Index(content []byte) (int, error) {
+	sa, _, err := parseStructuredAppendFrame(content)
+
+	return sa.Index, err
+}
+
+func (structuredAppendFramer) NewDeframer() Deframer { return &structuredAppendDeframer{} }
+
+// structuredAppendFrameEncoder cuts data into frames of fragmentLen bytes, the last
+// one shorter
+type structuredAppendFrameEncoder struct {
+	data        []byte
+	fragmentLen int
+	parity      byte
+}
+
+func (e structuredAppendFrameEncoder) Fragments() int {
+	return (len(e.data) + e.fragmentLen - 1) / e.fragmentLen
+}
+
+func (e structuredAppendFrameEncoder) Frame(seqNum int) []byte {
+	// Structured append has no redundant frames, the first ones are repeated
+	index := (seqNum - 1) % e.Fragments()
+	offset := index * e.fragmentLen
+	end := min(offset+e.fragmentLen, len(e.data))
+
+	sa := qrcode.StructuredAppend{Index: index, Total: e.Fragments(), Parity: e.parity}
+
+	return StructuredAppendFrame(sa, e.data[offset:end])
+}
+
+// structuredAppendDeframer reassembles data from structured append frames by their
+// indices, and checks it against their parity
+type structuredAppendDeframer struct {
+	total  int
+	parity byte
+	frames map[int][]byte
+}
+
+func (d *structuredAppendDeframer) Receive(content []byte) error {
+	sa, data, err := parseStructuredAppendFrame(content)
+	if err != nil {
+		return err
+	}
+
+	if d.frames == nil {
+		d.total = sa.Total
+		d.parity = sa.Parity
+		d.frames = make(map[int][]byte)
+	} else if sa.Total != d.total || sa.Parity != d.parity {
+		return fmt.Errorf("structured append frame of %d frames with parity %#02x, expected %d with %#02x", sa.Total, sa.Parity, d.total, d.parity)
+	}
+
+	if _, ok := d.frames[sa.Index]; !ok {
+		d.frames[sa.Index] = append([]byte(nil), data...)
+	}
+
+	return nil
+}
+
+func (d *structuredAppendDeframer) Fragments() (int, []int) {
+	indices := make([]int, 0, len(d.frames))
+	for index := range d.frames {
+		indices = append(indices, index)
+	}
+
+	sort.Ints(indices)
+
+	return d.total, indices
+}
+
+func (d *structuredAppendDeframer) Result() ([]byte, bool, error) {
+	if d.frames == nil || len(d.frames) < d.total {
+		return nil, false, nil
+	}
+
+	var data []byte
+	for index := range d.total {
+		data = append(data, d.frames[index]...)
+	}
+
+	if parity := qrcode.StructuredAppendParity(data); parity != d.parity {
+		return nil, false, fmt.Errorf("structured append parity mismatch: got %#02x, expected %#02x", parity, d.parity)
+	}
+
+	return data, true, nil
+}
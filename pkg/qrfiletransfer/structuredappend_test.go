@@ -0,0 +1,112 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+	"github.com/spf13/afero"
+)
+
+func TestStructuredAppendQRCodes(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content, session := newFramedSession(t, fs, ProtocolStructuredAppend, 0)
+
+	parity := qrcode.StructuredAppendParity(content)
+	total := len(session.Chunks)
+
+	var joined []byte
+
+	for i, chunk := range session.Chunks {
+		data, err := afero.ReadFile(fs, session.DataFile(chunk.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The QR code holds the header of the frame in its structured append header
+		code, err := newQRCode(PayloadFormatBinary, data, qrcode.Medium)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := qrcode.StructuredAppend{Index: i, Total: total, Parity: parity}
+		if code.StructuredAppend == nil || *code.StructuredAppend != want {
+			t.Fatalf("QR code %d has header %v, want %v", i, code.StructuredAppend, want)
+		}
+
+		if !bytes.Equal(StructuredAppendFrame(want, []byte(code.Content)), data) {
+			t.Fatalf("Frame of QR code %d differs from its data file", i)
+		}
+
+		joined = append(joined, code.Content...)
+	}
+
+	if !bytes.Equal(joined, content) {
+		t.Error("Joined QR codes differ from the file")
+	}
+}
+
+func TestStructuredAppendTooManyFrames(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	content := make([]byte, 20000)
+	rand.New(rand.NewSource(3)).Read(content)
+
+	if err := afero.WriteFile(fs, "/in/large.bin", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	qrft := NewQRFileTransfer()
+	qrft.SetFs(fs)
+	qrft.SetTargetQRVersion(20)
+
+	if err := qrft.SetProtocol(ProtocolStructuredAppend); err != nil {
+		t.Fatal(err)
+	}
+
+	err := qrft.FileToQRCodes("/in/large.bin", "/large")
+	if err == nil || !strings.Contains(err.Error(), "at most 16") {
+		t.Fatalf("FileToQRCodes of 20000 bytes = %v, want too many QR codes", err)
+	}
+}
+
+func TestStructuredAppendDeframer(t *testing.T) {
+	data := []byte("hello, structured append")
+	parity := qrcode.StructuredAppendParity(data)
+
+	d := structuredAppendFramer{}.NewDeframer()
+
+	if err := d.Receive(StructuredAppendFrame(qrcode.StructuredAppend{Index: 1, Total: 2, Parity: parity}, data[10:])); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := d.Result(); ok || err != nil {
+		t.Fatalf("Result of 1 of 2 frames = %v, %v, want incomplete", ok, err)
+	}
+
+	// A corrupted byte breaks the parity
+	corrupted := append([]byte("Hello"), data[5:10]...)
+	if err := d.Receive(StructuredAppendFrame(qrcode.StructuredAppend{Index: 0, Total: 2, Parity: parity}, corrupted)); err != nil {
+		t.Fatal(err)
+	}
+
+	if total, indices := d.Fragments(); total != 2 || len(indices) != 2 {
+		t.Fatalf("Fragments = %d, %v, want 2 of 2", total, indices)
+	}
+
+	if _, ok, err := d.Result(); ok || err == nil {
+		t.Errorf("Result of a corrupted frame = %v, %v, want parity mismatch", ok, err)
+	}
+
+	if err := d.Receive(StructuredAppendFrame(qrcode.StructuredAppend{Index: 0, Total: 3, Parity: parity}, data)); err == nil {
+		t.Error("Receive of a frame of another message succeeded")
+	}
+
+	for _, invalid := range [][]byte{[]byte("QSA"), []byte("QSA\x02\x02\x00x"), []byte("QFT\x00\x02\x00x")} {
+		if _, err := (structuredAppendFramer{}).Index(invalid); err == nil {
+			t.Errorf("Index(%q) succeeded, want error", invalid)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package qrfiletransfer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/base45"
+	"github.com/spf13/afero"
+)
+
+const (
+	// TextChunkExt is the file name extension of the text fallback of a chunk
+	TextChunkExt = ".txt"
+
+	// textChunkMagic and textChunkVersion start the first line of a text chunk
+	textChunkMagic   = "QRFT-TEXT"
+	textChunkVersion = 1
+
+	// textLineLength is the number of Base45 characters per data line, a multiple of
+	// 3 so that every line decodes on its own
+	textLineLength = 48
+)
+
+// ErrBadTextChunk is returned when a text file is not a valid text chunk
+var ErrBadTextChunk = errors.New("bad text chunk")
+
+// TextChunk is a chunk in the plain text form written next to its QR code when the
+// text fallback is enabled, see SetTextFallback
+type TextChunk struct {
+	// File is the name of the file the chunk belongs to
+	File string
+	// Name is the chunk name shared by its QR code and data file
+	Name string
+	// Index is the position of the chunk in the file, Total the number of chunks
+	Index int
+	Total int
+	// Data is the chunk data
+	Data []byte
+}
+
+// EncodeTextChunk returns the text form of a chunk. The data is Base45 encoded in
+// short lines enclosed in bars, which keep spaces at the ends of a line visible, and
+// followed by a checksum line with the SHA-256 of the data:
+//
+//	QRFT-TEXT 1
+//	FILE report.pdf
+//	CHUNK 2/5 report_0001
+//	|%69 VD92EX0...|
+//	SHA256 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//
+// Base45 only uses digits, upper case letters, and a few symbols, so the text can be
+// read back by OCR or typed by hand if the QR code image is damaged.
+func EncodeTextChunk(c *TextChunk) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%s %d\n", textChunkMagic, textChunkVersion)
+	fmt.Fprintf(&b, "# Recover with: qrfiletransfer recover-text -i <text files> -o <file>\n")
+	fmt.Fprintf(&b, "FILE %s\n", c.File)
+	fmt.Fprintf(&b, "CHUNK %d/%d %s\n", c.Index+1, c.Total, c.Name)
+
+	encoded := base45.Encode(c.Data)
+	for i := 0; i < len(encoded); i += textLineLength {
+		fmt.Fprintf(&b, "|%s|\n", encoded[i:min(i+textLineLength, len(encoded))])
+	}
+
+	fmt.Fprintf(&b, "SHA256 %s\n", hashBytes(c.Data))
+
+	return b.Bytes()
+}
+
+// DecodeTextChunk parses the text form of a chunk written by EncodeTextChunk.
+// Blank lines and lines starting with "#" are ignored, and letters may be typed in
+// lower case, so text restored by OCR or typed by hand is accepted. It returns an
+// error wrapping ErrBadTextChunk for malformed text and ErrHashMismatch if the data
+// does not match the checksum line.
+func DecodeTextChunk(text []byte) (*TextChunk, error) {
+	var (
+		c        TextChunk
+		encoded  strings.Builder
+		version  bool
+		checksum string
+		chunk    bool
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, value, _ := strings.Cut(line, " ")
+		value = strings.TrimSpace(value)
+
+		switch strings.ToUpper(keyword) {
+		case textChunkMagic:
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid version %q", ErrBadTextChunk, value)
+			}
+
+			if v > textChunkVersion {
+				return nil, fmt.Errorf("%w: text chunk version %d (newest supported is %d)", ErrUnsupportedVersion, v, textChunkVersion)
+			}
+
+			version = true
+		case "FILE":
+			c.File = value
+		case "CHUNK":
+			position, name, _ := strings.Cut(value, " ")
+			if _, err := fmt.Sscanf(position, "%d/%d", &c.Index, &c.Total); err != nil || c.Index < 1 || c.Index > c.Total {
+				return nil, fmt.Errorf("%w: invalid chunk position %q", ErrBadTextChunk, position)
+			}
+
+			c.Index--
+			c.Name = strings.TrimSpace(name)
+			chunk = true
+		case "SHA256":
+			checksum = strings.ToLower(value)
+		default:
+			// A data line, the bars may have been lost by OCR
+			line = strings.TrimPrefix(line, "|")
+			line = strings.TrimSuffix(line, "|")
+			encoded.WriteString(strings.ToUpper(line))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read text chunk: %w", err)
+	}
+
+	switch {
+	case !version:
+		return nil, fmt.Errorf("%w: missing %s line", ErrBadTextChunk, textChunkMagic)
+	case !chunk || c.Name == "":
+		return nil, fmt.Errorf("%w: missing CHUNK line", ErrBadTextChunk)
+	case checksum == "":
+		return nil, fmt.Errorf("%w: missing SHA256 line", ErrBadTextChunk)
+	}
+
+	data, err := base45.Decode(encoded.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %s: %w", ErrBadTextChunk, c.Name, err)
+	}
+
+	if hashBytes(data) != checksum {
+		return nil, fmt.Errorf("%w: chunk %s does not match its SHA256 line", ErrHashMismatch, c.Name)
+	}
+
+	c.Data = data
+
+	return &c, nil
+}
+
+// TextToFile reconstructs a file from the text fallback of its chunks, see
+// SetTextFallback. Every path is a text chunk file or a directory: the text
+// directory of a session, a session directory, or any directory of .txt files,
+// e.g. files restored by OCR. The chunks may be given in any order and more than
+// once. It returns an error wrapping ErrMissingChunk if a chunk is not found.
+func (q *QRFileTransfer) TextToFile(paths []string, outFilePath string) (err error) {
+	files, err := textChunkFiles(q.fs, paths)
+	if err != nil {
+		return err
+	}
+
+	var first *TextChunk
+
+	chunks := make(map[int]*TextChunk)
+
+	for _, path := range files {
+		text, err := afero.ReadFile(q.fs, path)
+		if err != nil {
+			return fmt.Errorf("failed to read text chunk: %w", err)
+		}
+
+		c, err := DecodeTextChunk(text)
+		if err != nil {
+			return fmt.Errorf("failed to decode text chunk %s: %w", path, err)
+		}
+
+		if first == nil {
+			first = c
+		}
+
+		if c.File != first.File || c.Total != first.Total {
+			return fmt.Errorf("text chunk %s belongs to %s (%d chunks), not to %s (%d chunks)",
+				path, c.File, c.Total, first.File, first.Total)
+		}
+
+		if prev := chunks[c.Index]; prev != nil && !bytes.Equal(prev.Data, c.Data) {
+			return fmt.Errorf("text chunk %s conflicts with another copy of chunk %s", path, c.Name)
+		}
+
+		chunks[c.Index] = c
+	}
+
+	if first == nil {
+		return errors.New("no text chunks found")
+	}
+
+	// Merge the chunks like QRCodesToFile does with the data files of a session
+	tempDir, releaseTemp, err := q.makeTempDir("qrfiletransfer_text_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+
+	defer func() {
+		if removeErr := releaseTemp(); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove temporary directory: %w", removeErr)
+		}
+	}()
+
+	for _, c := range chunks {
+		chunkFilePath := filepath.Join(tempDir, c.Name+".part")
+		if err := afero.WriteFile(q.fs, chunkFilePath, c.Data, 0600); err != nil {
+			return fmt.Errorf("failed to write chunk to file %s: %w", chunkFilePath, err)
+		}
+	}
+
+	// The metadata of the first chunk lists the chunks duplicating another, which
+	// have no text chunk, see SetDedup
+	var refs map[int]int
+	if c := chunks[0]; c != nil {
+		if info, err := q.splitter.ReadFileInfo(filepath.Join(tempDir, c.Name+".part")); err == nil {
+			refs = info.Refs
+		}
+	}
+
+	for i := range first.Total {
+		if _, ok := refs[i]; !ok && chunks[i] == nil {
+			return fmt.Errorf("failed to recover %s: %w", first.File, &ErrMissingChunk{Index: i})
+		}
+	}
+
+	return q.mergeChunks(tempDir, outFilePath)
+}
+
+// textChunkFiles expands the paths given to TextToFile into text chunk files
+func textChunkFiles(fsys afero.Fs, paths []string) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		info, err := fsys.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read text chunks: %w", err)
+		}
+
+		if !info.IsDir() {
+			files = append(files, path)
+
+			continue
+		}
+
+		dir := path
+		if session, err := loadSession(fsys, path); err == nil && session.TextDir() != "" {
+			dir = session.TextDir()
+		}
+
+		matches, err := afero.Glob(fsys, filepath.Join(dir, "*"+TextChunkExt))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list text chunks: %w", err)
+		}
+
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	return files, nil
+}
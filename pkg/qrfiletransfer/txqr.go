@@ -0,0 +1,161 @@
+package qrfiletransfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// txqrFramer is the framer of ProtocolTXQR
+type txqrFramer struct{}
+
+func (txqrFramer) Name() string { return ProtocolTXQR }
+
+func (txqrFramer) Format() PayloadFormat { return PayloadFormatBinary }
+
+func (txqrFramer) NewFrameEncoder(data []byte, fragmentLen int) (FrameEncoder, error) {
+	if len(data) == 0 || fragmentLen < 1 {
+		return nil, errors.New("txqr frames an empty file or fragment")
+	}
+
+	return txqrFrameEncoder{data: data, fragmentLen: fragmentLen}, nil
+}
+
+func (txqrFramer) Index(content []byte) (int, error) {
+	offset, _, _, err := parseTXQRFrame(content)
+
+	return offset, err
+}
+
+func (txqrFramer) NewDeframer() Deframer { return &txqrDeframer{} }
+
+// parseTXQRFrame splits a txqr frame into the offset and the data following it,
+// and the size of the whole data
+func parseTXQRFrame(content []byte) (offset, total int, data []byte, err error) {
+	header, data, found := bytes.Cut(content, []byte("|"))
+	if !found {
+		return 0, 0, nil, errors.New("txqr frame has no header")
+	}
+
+	o, t, found := bytes.Cut(header, []byte("/"))
+	if !found {
+		return 0, 0, nil, fmt.Errorf("invalid txqr frame header %q", header)
+	}
+
+	offset, err = parseTXQRNumber(o)
+	if err == nil {
+		total, err = parseTXQRNumber(t)
+	}
+
+	if err != nil || len(data) == 0 || offset+len(data) > total {
+		return 0, 0, nil, fmt.Errorf("invalid txqr frame header %q", header)
+	}
+
+	return offset, total, data, nil
+}
+
+// parseTXQRNumber parses a decimal number of a txqr frame header, without sign
+// or leading zeros
+func parseTXQRNumber(b []byte) (int, error) {
+	if len(b) == 0 || len(b) > 1 && b[0] == '0' || b[0] < '0' || b[0] > '9' {
+		return 0, fmt.Errorf("invalid number %q", b)
+	}
+
+	return strconv.Atoi(string(b))
+}
+
+// txqrFrameEncoder cuts data into frames of fragmentLen bytes, the last one shorter
+type txqrFrameEncoder struct {
+	data        []byte
+	fragmentLen int
+}
+
+func (e txqrFrameEncoder) Fragments() int {
+	return (len(e.data) + e.fragmentLen - 1) / e.fragmentLen
+}
+
+func (e txqrFrameEncoder) Frame(seqNum int) []byte {
+	// txqr has no redundant frames, the first ones are repeated
+	offset := (seqNum - 1) % e.Fragments() * e.fragmentLen
+	end := min(offset+e.fragmentLen, len(e.data))
+
+	frame := fmt.Appendf(nil, "%d/%d|", offset, len(e.data))
+
+	return append(frame, e.data[offset:end]...)
+}
+
+// txqrDeframer reassembles data from txqr frames by their offsets
+type txqrDeframer struct {
+	total int
+	// fragmentLen is the length of the frames before the last one, 0 until one is received
+	fragmentLen int
+	frames      map[int][]byte
+}
+
+func (d *txqrDeframer) Receive(content []byte) error {
+	offset, total, data, err := parseTXQRFrame(content)
+	if err != nil {
+		return err
+	}
+
+	if d.frames == nil {
+		d.total = total
+		d.frames = make(map[int][]byte)
+	} else if total != d.total {
+		return fmt.Errorf("txqr frame of %d bytes of data, expected %d", total, d.total)
+	}
+
+	if _, ok := d.frames[offset]; ok {
+		return nil
+	}
+
+	if offset+len(data) < total {
+		d.fragmentLen = max(d.fragmentLen, len(data))
+	}
+
+	d.frames[offset] = append([]byte(nil), data...)
+
+	return nil
+}
+
+// Fragments counts the frames of the length of the first ones, which is known
+// once one of them is received or the data is complete
+func (d *txqrDeframer) Fragments() (int, []int) {
+	fragmentLen := d.fragmentLen
+	if fragmentLen == 0 {
+		if data, ok, _ := d.Result(); !ok || len(data) == 0 {
+			return 0, nil
+		}
+
+		fragmentLen = d.total
+	}
+
+	indices := make([]int, 0, len(d.frames))
+	for offset := range d.frames {
+		indices = append(indices, offset/fragmentLen)
+	}
+
+	sort.Ints(indices)
+
+	return (d.total + fragmentLen - 1) / fragmentLen, indices
+}
+
+func (d *txqrDeframer) Result() ([]byte, bool, error) {
+	if d.frames == nil {
+		return nil, false, nil
+	}
+
+	data := make([]byte, 0, d.total)
+	for len(data) < d.total {
+		frame, ok := d.frames[len(data)]
+		if !ok {
+			return nil, false, nil
+		}
+
+		data = append(data, frame...)
+	}
+
+	return data, true, nil
+}
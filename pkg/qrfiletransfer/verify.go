@@ -0,0 +1,228 @@
+package qrfiletransfer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ChunkReport describes which chunks of a file are available in a directory
+type ChunkReport struct {
+	// Total is the number of chunks of the file, or 0 if it is unknown because
+	// the first chunk, which records it, has not been received yet
+	Total int
+	// Present lists the indices of the chunks that are available
+	Present []int
+	// Duplicated lists the indices available from more than one data file
+	Duplicated []int
+	// Missing lists the indices of the chunks that are not available.
+	// While Total is unknown it only covers the gaps below the highest present index.
+	Missing []int
+	// Restorable lists the missing chunks that can be restored from the parity
+	// chunks present, see QRFileTransfer.SetParity
+	Restorable []int
+}
+
+// Complete reports whether every chunk of the file is available or can be
+// restored from the parity chunks
+func (r *ChunkReport) Complete() bool {
+	return r.Total > 0 && len(r.Missing) == len(r.Restorable)
+}
+
+// String returns a one-line summary of the report
+func (r *ChunkReport) String() string {
+	total := "unknown"
+	if r.Total > 0 {
+		total = strconv.Itoa(r.Total)
+	}
+
+	summary := fmt.Sprintf("%d of %s chunks present", len(r.Present), total)
+
+	if len(r.Missing) > 0 {
+		summary += ", missing " + FormatIndexRanges(r.Missing)
+	}
+
+	if len(r.Restorable) > 0 {
+		summary += ", restorable from parity " + FormatIndexRanges(r.Restorable)
+	}
+
+	if len(r.Duplicated) > 0 {
+		summary += ", duplicated " + FormatIndexRanges(r.Duplicated)
+	}
+
+	return summary
+}
+
+// VerifyChunks reports which chunk indices are present, duplicated, or missing in
+// the data files of inDir, and which missing ones the parity chunks restore. The
+// directory may be a session directory, a legacy archive, or a partially decoded
+// directory, see OpenSession.
+func (q *QRFileTransfer) VerifyChunks(inDir string) (*ChunkReport, error) {
+	session, err := openSession(q.fs, inDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The chunks of other protocols are the fragments solved from their frames
+	if f, err := q.sessionFramer(session); err != nil {
+		return nil, err
+	} else if f != nil {
+		return q.verifyFrames(f, session)
+	}
+
+	report := &ChunkReport{Total: session.Settings.NumChunks}
+
+	// The parity chunks record the number of chunks too
+	parity, err := q.loadParity(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Total == 0 && parity != nil {
+		report.Total = parity.dataChunks
+	}
+
+	// Count the data files available for every chunk index
+	counts := make(map[int]int)
+	maxIndex := -1
+
+	for _, path := range session.chunkDataFiles(q.fs) {
+		for _, index := range session.dataFileIndices(path) {
+			counts[index]++
+
+			if index > maxIndex {
+				maxIndex = index
+			}
+		}
+	}
+
+	upper := report.Total
+	if upper == 0 {
+		upper = maxIndex + 1
+	}
+
+	// The chunks duplicating another are read from its data file
+	refs := session.refIndices()
+
+	for index := range upper {
+		if _, ok := refs[index]; !ok && counts[index] == 0 {
+			report.Missing = append(report.Missing, index)
+		}
+	}
+
+	for index, count := range counts {
+		report.Present = append(report.Present, index)
+
+		if count > 1 {
+			report.Duplicated = append(report.Duplicated, index)
+		}
+	}
+
+	sort.Ints(report.Present)
+	sort.Ints(report.Duplicated)
+
+	if parity != nil {
+		report.Restorable = parity.restorable(report.Missing)
+	}
+
+	return report, nil
+}
+
+// chunkDataFiles returns every existing data file of the session.
+// Besides the files listed by the session, files in the data directory whose name
+// carries a chunk index are included, so duplicates can be detected.
+func (s *Session) chunkDataFiles(fsys afero.Fs) []string {
+	seen := make(map[string]bool)
+
+	var files []string
+
+	add := func(path string) {
+		if !seen[path] && fileExists(fsys, path) {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, chunk := range s.Chunks {
+		if path := s.DataFile(chunk.Name); path != "" {
+			add(path)
+		}
+	}
+
+	if dataDir := s.DataDir(); dataDir != "" {
+		for _, pattern := range []string{"*.dat", "*.part"} {
+			matches, _ := afero.Glob(fsys, filepath.Join(dataDir, pattern))
+			for _, path := range matches {
+				add(path)
+			}
+		}
+	}
+
+	return files
+}
+
+// dataFileIndices returns the indices of the chunks whose data the file at path
+// holds: the index in its name, or in a content-addressed session those of every
+// chunk whose data it is
+func (s *Session) dataFileIndices(path string) []int {
+	if !s.Settings.ContentAddressed {
+		if index, ok := chunkIndex(path); ok {
+			return []int{index}
+		}
+
+		return nil
+	}
+
+	var indices []int
+
+	base := filepath.Base(path)
+	chunkIndices := s.chunkIndices()
+
+	for i := range s.Chunks {
+		if _, dataName := s.artifactNames(&s.Chunks[i]); dataName+".dat" == base {
+			indices = append(indices, chunkIndices[i])
+		}
+	}
+
+	return indices
+}
+
+// chunkIndex extracts the chunk index from the name of a chunk file
+func chunkIndex(path string) (int, bool) {
+	base := filepath.Base(path)
+
+	m := chunkIndexPattern.FindStringSubmatch(strings.TrimSuffix(base, filepath.Ext(base)))
+	if m == nil {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(m[1])
+
+	return index, err == nil
+}
+
+// FormatIndexRanges formats sorted chunk indices as ranges, e.g. "0-3, 7, 9-10"
+func FormatIndexRanges(indices []int) string {
+	var parts []string
+
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && indices[j+1] == indices[j]+1 {
+			j++
+		}
+
+		if i == j {
+			parts = append(parts, strconv.Itoa(indices[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", indices[i], indices[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,126 @@
+package qrfiletransfer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyChunks(t *testing.T) {
+	testDir := t.TempDir()
+
+	testFilePath := filepath.Join(testDir, "verify.txt")
+	testContent := strings.Repeat("Every chunk must be accounted for. ", 120)
+
+	if err := os.WriteFile(testFilePath, []byte(testContent), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outDir := filepath.Join(testDir, "output")
+	qrft := NewQRFileTransfer()
+	qrft.SetMaxChunkSize(1000)
+
+	if err := qrft.FileToQRCodes(testFilePath, outDir); err != nil {
+		t.Fatalf("FileToQRCodes failed: %v", err)
+	}
+
+	session, err := LoadSession(outDir)
+	if err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	total := len(session.Chunks)
+	if total < 4 {
+		t.Fatalf("Expected at least 4 chunks, got %d", total)
+	}
+
+	// Simulate a partial decode that read every chunk except the second and the last
+	stateDir := filepath.Join(testDir, "state")
+	stateDataDir := filepath.Join(stateDir, "data")
+
+	if err := os.MkdirAll(stateDataDir, 0750); err != nil {
+		t.Fatalf("Failed to create state directory: %v", err)
+	}
+
+	copyChunk := func(index int, ext string) {
+		name := session.Chunks[index].Name
+
+		data, err := os.ReadFile(session.DataFile(name))
+		if err != nil {
+			t.Fatalf("Failed to read data file: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(stateDataDir, name+ext), data, 0600); err != nil {
+			t.Fatalf("Failed to write data file: %v", err)
+		}
+	}
+
+	for i := range total {
+		if i != 1 && i != total-1 {
+			copyChunk(i, ".dat")
+		}
+	}
+
+	// The same chunk decoded into two data files
+	copyChunk(2, ".part")
+
+	report, err := qrft.VerifyChunks(stateDir)
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	if report.Total != total || report.Complete() {
+		t.Fatalf("Unexpected report: %s", report)
+	}
+
+	if !reflect.DeepEqual(report.Missing, []int{1, total - 1}) {
+		t.Errorf("Expected missing chunks [1 %d], got %v", total-1, report.Missing)
+	}
+
+	if !reflect.DeepEqual(report.Duplicated, []int{2}) {
+		t.Errorf("Expected duplicated chunks [2], got %v", report.Duplicated)
+	}
+
+	if len(report.Present) != total-2 {
+		t.Errorf("Expected %d present chunks, got %v", total-2, report.Present)
+	}
+
+	// Scanning the missing chunks completes the state directory
+	copyChunk(1, ".dat")
+	copyChunk(total-1, ".dat")
+
+	if err := os.Remove(filepath.Join(stateDataDir, session.Chunks[2].Name+".part")); err != nil {
+		t.Fatalf("Failed to remove duplicate data file: %v", err)
+	}
+
+	report, err = qrft.VerifyChunks(stateDir)
+	if err != nil {
+		t.Fatalf("VerifyChunks failed: %v", err)
+	}
+
+	if !report.Complete() || len(report.Duplicated) != 0 {
+		t.Fatalf("Expected a complete report, got: %s", report)
+	}
+
+	reconstructedFilePath := filepath.Join(testDir, "reconstructed.txt")
+	if err := qrft.QRCodesToFile(stateDir, reconstructedFilePath); err != nil {
+		t.Fatalf("QRCodesToFile failed: %v", err)
+	}
+
+	reconstructedContent, err := os.ReadFile(reconstructedFilePath)
+	if err != nil {
+		t.Fatalf("Failed to read reconstructed file: %v", err)
+	}
+
+	if string(reconstructedContent) != testContent {
+		t.Fatal("Reconstructed content does not match original content")
+	}
+}
+
+func TestFormatIndexRanges(t *testing.T) {
+	if got := FormatIndexRanges([]int{0, 1, 2, 3, 7, 9, 10}); got != "0-3, 7, 9-10" {
+		t.Errorf("Unexpected ranges: %q", got)
+	}
+}
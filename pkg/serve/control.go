@@ -0,0 +1,253 @@
+package serve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// Playback is the position of the slideshow as directed through the API. The page
+// follows it whenever Seq changes, and otherwise cycles through the frames from
+// Start to End on its own, or through Frames if they are set.
+type Playback struct {
+	// Seq is incremented by every jump, replay, or status request
+	Seq uint64 `json:"seq"`
+	// Index is the frame the page jumps to
+	Index int `json:"index"`
+	// Start is the first frame of the range being shown
+	Start int `json:"start"`
+	// End is the last frame of the range being shown, inclusive
+	End int `json:"end"`
+	// Frames lists the frames being shown in order instead of the range, if set
+	Frames []int `json:"frames,omitempty"`
+	// Status shows the status QR code instead of the frames
+	Status bool `json:"status"`
+	// Shown is the frame last reported as shown by the page, -1 if none
+	Shown int `json:"shown"`
+}
+
+// Playback returns the current playback position
+func (s *Server) Playback() Playback {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.playback
+}
+
+// Jump directs the slideshow to a frame. A frame outside the range or the frames
+// being replayed resets the range to all frames.
+func (s *Server) Jump(index int) error {
+	if err := s.checkIndex(index); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < s.playback.Start || index > s.playback.End || (s.playback.Frames != nil && !slices.Contains(s.playback.Frames, index)) {
+		s.playback.Start, s.playback.End, s.playback.Frames = 0, len(s.frames)-1, nil
+	}
+
+	s.playback.Index = index
+	s.playback.Status = false
+	s.playback.Seq++
+
+	return nil
+}
+
+// Replay directs the slideshow to cycle through the frames from start to end,
+// inclusive, starting at start
+func (s *Server) Replay(start, end int) error {
+	if err := s.checkIndex(start); err != nil {
+		return err
+	}
+
+	if err := s.checkIndex(end); err != nil {
+		return err
+	}
+
+	if start > end {
+		return fmt.Errorf("start %d is after end %d", start, end)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.playback.Index, s.playback.Start, s.playback.End, s.playback.Frames = start, start, end, nil
+	s.playback.Status = false
+	s.playback.Seq++
+
+	return nil
+}
+
+// Queue directs the slideshow to cycle through the given frames in order, e.g.
+// the chunks a receiver reported missing, starting at the first one
+func (s *Server) Queue(frames []int) error {
+	if len(frames) == 0 {
+		return errors.New("no frames to queue")
+	}
+
+	for _, index := range frames {
+		if err := s.checkIndex(index); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.playback.Index, s.playback.Start, s.playback.End = frames[0], 0, len(s.frames)-1
+	s.playback.Frames = slices.Clone(frames)
+	s.playback.Status = false
+	s.playback.Seq++
+
+	return nil
+}
+
+// ShowStatus shows or hides the status QR code set with SetStatusImage
+func (s *Server) ShowStatus(show bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if show && s.status == nil {
+		return errors.New("no status QR code available")
+	}
+
+	s.playback.Status = show
+	s.playback.Seq++
+
+	return nil
+}
+
+// SetStatusImage sets the PNG image of the status QR code describing the transfer
+func (s *Server) SetStatusImage(png []byte) {
+	s.mu.Lock()
+	s.status = png
+	s.mu.Unlock()
+}
+
+// checkIndex checks that index is the index of a frame
+func (s *Server) checkIndex(index int) error {
+	if index < 0 || index >= len(s.frames) {
+		return fmt.Errorf("frame index %d out of range, there are %d frames", index, len(s.frames))
+	}
+
+	return nil
+}
+
+// handleStatus serves the image of the status QR code
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	status := s.status
+	s.mu.RUnlock()
+
+	if status == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(status)
+}
+
+// handleGetPlayback serves the playback position. The page reports the frame it
+// shows with the shown query parameter.
+func (s *Server) handleGetPlayback(w http.ResponseWriter, r *http.Request) {
+	if shown, err := strconv.Atoi(r.URL.Query().Get("shown")); err == nil && s.checkIndex(shown) == nil {
+		s.mu.Lock()
+		s.playback.Shown = shown
+		s.mu.Unlock()
+	}
+
+	writeJSON(w, http.StatusOK, s.Playback())
+}
+
+// handleJump directs the slideshow to the frame of a JSON object holding index
+func (s *Server) handleJump(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Index *int `json:"index"`
+	}
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	if request.Index == nil {
+		writeJSON(w, http.StatusBadRequest, apiError{"index is required"})
+
+		return
+	}
+
+	s.writePlayback(w, s.Jump(*request.Index))
+}
+
+// handleReplay directs the slideshow to the range of a JSON object holding start
+// and end, which default to the first and last frames
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Start int `json:"start"`
+		End   int `json:"end"`
+	}{0, len(s.frames) - 1}
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	s.writePlayback(w, s.Replay(request.Start, request.End))
+}
+
+// handleQueue directs the slideshow to the frames of a JSON object holding frames
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Frames []int `json:"frames"`
+	}
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	s.writePlayback(w, s.Queue(request.Frames))
+}
+
+// handlePostStatus shows or hides the status QR code according to a JSON object
+// holding show, which defaults to true
+func (s *Server) handlePostStatus(w http.ResponseWriter, r *http.Request) {
+	request := struct {
+		Show bool `json:"show"`
+	}{true}
+
+	if !decodeRequest(w, r, &request) {
+		return
+	}
+
+	s.writePlayback(w, s.ShowStatus(request.Show))
+}
+
+// writePlayback responds with the playback position, or with err if the request failed
+func (s *Server) writePlayback(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.Playback())
+}
+
+// decodeRequest decodes the JSON body of a request into v. An empty body leaves v
+// unchanged. A failure is reported to the client and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, apiError{fmt.Sprintf("invalid request: %v", err)})
+
+		return false
+	}
+
+	return true
+}
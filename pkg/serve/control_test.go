@@ -0,0 +1,141 @@
+package serve
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// postPlayback posts body to an API path and decodes the playback position it responds with
+func postPlayback(t *testing.T, url, body string) (int, Playback) {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST %s failed: %v", url, err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var playback Playback
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&playback); err != nil {
+			t.Fatalf("Failed to decode playback: %v", err)
+		}
+	}
+
+	return resp.StatusCode, playback
+}
+
+func TestServerPlayback(t *testing.T) {
+	server, ts := newTestServer(t)
+
+	if got := server.Playback(); !reflect.DeepEqual(got, Playback{End: 1, Shown: -1}) {
+		t.Fatalf("Unexpected initial playback: %+v", got)
+	}
+
+	status, playback := postPlayback(t, ts.URL+"/api/jump", `{"index": 1}`)
+	if status != http.StatusOK || playback.Index != 1 || playback.Seq != 1 {
+		t.Errorf("Unexpected jump response %d: %+v", status, playback)
+	}
+
+	status, playback = postPlayback(t, ts.URL+"/api/replay", `{"start": 1, "end": 1}`)
+	if status != http.StatusOK || !reflect.DeepEqual(playback, Playback{Seq: 2, Index: 1, Start: 1, End: 1, Shown: -1}) {
+		t.Errorf("Unexpected replay response %d: %+v", status, playback)
+	}
+
+	// Jumping out of the range being replayed shows all frames again
+	if err := server.Jump(0); err != nil {
+		t.Fatalf("Jump failed: %v", err)
+	}
+
+	if got := server.Playback(); got.Start != 0 || got.End != 1 || got.Index != 0 {
+		t.Errorf("Unexpected playback after jump: %+v", got)
+	}
+
+	// An empty replay request replays all frames
+	if status, playback = postPlayback(t, ts.URL+"/api/replay", ``); status != http.StatusOK || playback.Start != 0 || playback.End != 1 {
+		t.Errorf("Unexpected replay response %d: %+v", status, playback)
+	}
+
+	// Queued frames are shown instead of the range, until a jump leaves them
+	status, playback = postPlayback(t, ts.URL+"/api/queue", `{"frames": [1]}`)
+	if status != http.StatusOK || !reflect.DeepEqual(playback, Playback{Seq: 5, Index: 1, End: 1, Frames: []int{1}, Shown: -1}) {
+		t.Errorf("Unexpected queue response %d: %+v", status, playback)
+	}
+
+	if err := server.Jump(0); err != nil {
+		t.Fatalf("Jump failed: %v", err)
+	}
+
+	if got := server.Playback(); got.Frames != nil || got.Index != 0 {
+		t.Errorf("Unexpected playback after jump: %+v", got)
+	}
+
+	for path, body := range map[string]string{
+		"/api/jump":   `{"index": 2}`,
+		"/api/replay": `{"start": 1, "end": 0}`,
+		"/api/queue":  `{"frames": [0, 2]}`,
+		"/api/status": `{}`,
+	} {
+		if status, _ := postPlayback(t, ts.URL+path, body); status != http.StatusBadRequest {
+			t.Errorf("POST %s %s: expected 400, got %d", path, body, status)
+		}
+	}
+
+	// The page reports the frame it shows
+	resp, err := http.Get(ts.URL + "/api/playback?shown=1")
+	if err != nil {
+		t.Fatalf("GET playback failed: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if got := server.Playback().Shown; got != 1 {
+		t.Errorf("Expected shown frame 1, got %d", got)
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	server, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatalf("GET status failed: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 without a status image, got %d", resp.StatusCode)
+	}
+
+	server.SetStatusImage([]byte("png status"))
+
+	status, playback := postPlayback(t, ts.URL+"/api/status", `{}`)
+	if status != http.StatusOK || !playback.Status {
+		t.Errorf("Unexpected status response %d: %+v", status, playback)
+	}
+
+	resp, err = http.Get(ts.URL + "/status")
+	if err != nil {
+		t.Fatalf("GET status failed: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if string(body) != "png status" {
+		t.Errorf("Unexpected status image %q", body)
+	}
+
+	// Jumping to a frame hides the status QR code
+	if _, playback = postPlayback(t, ts.URL+"/api/jump", `{"index": 0}`); playback.Status {
+		t.Errorf("Expected the status QR code to be hidden: %+v", playback)
+	}
+}
@@ -0,0 +1,228 @@
+// Package serve provides an HTTP server presenting QR code frames as an
+// auto-cycling slideshow, so a receiving device only has to point its camera at
+// the screen. The playback settings and position can be changed from the page
+// or remotely through a small JSON API, so the receiving operator can direct the
+// sender, e.g. to replay the chunks that were missed.
+package serve
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	// DefaultFPS is the default number of frames shown per second
+	DefaultFPS = 2.0
+
+	// MaxFPS is the highest frame rate accepted through the API
+	MaxFPS = 60.0
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Settings control the playback of the slideshow
+type Settings struct {
+	// FPS is the number of frames shown per second
+	FPS float64 `json:"fps"`
+	// Loop restarts the slideshow after the last frame
+	Loop bool `json:"loop"`
+	// Paused stops the slideshow on the current frame
+	Paused bool `json:"paused"`
+}
+
+// settingsUpdate is a partial update of the settings, fields left out are unchanged
+type settingsUpdate struct {
+	FPS    *float64 `json:"fps"`
+	Loop   *bool    `json:"loop"`
+	Paused *bool    `json:"paused"`
+}
+
+// Server serves a slideshow of QR code images.
+//
+// Endpoints:
+//
+//	GET  /               the slideshow page
+//	GET  /frames/{index} the QR code image of a frame, starting at 0
+//	GET  /api/frames     the number of frames and their names
+//	GET  /api/settings   the playback settings
+//	POST /api/settings   update the playback settings with a JSON object holding any of fps, loop, and paused
+//	GET  /api/playback   the playback position
+//	POST /api/jump       jump to the frame of a JSON object holding index
+//	POST /api/replay     cycle through the frames of a JSON object holding start and end
+//	POST /api/queue      cycle through the frames listed by a JSON object holding frames
+//	GET  /status         the status QR code image, see SetStatusImage
+//	POST /api/status     show or hide the status QR code with a JSON object holding show
+type Server struct {
+	frames []Frame
+	mux    *http.ServeMux
+
+	mu       sync.RWMutex
+	settings Settings
+	playback Playback
+	status   []byte
+}
+
+// Frame is a single image of the slideshow
+type Frame struct {
+	// Name identifies the frame, e.g. the chunk name
+	Name string `json:"name"`
+	// Path is the path of the PNG image
+	Path string `json:"-"`
+}
+
+// NewServer creates a server presenting frames in order
+func NewServer(frames []Frame) *Server {
+	s := &Server{
+		frames:   frames,
+		mux:      http.NewServeMux(),
+		settings: Settings{FPS: DefaultFPS, Loop: true},
+		playback: Playback{End: len(frames) - 1, Shown: -1},
+	}
+
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /frames/{index}", s.handleFrame)
+	s.mux.HandleFunc("GET /api/frames", s.handleFrames)
+	s.mux.HandleFunc("GET /api/settings", s.handleGetSettings)
+	s.mux.HandleFunc("POST /api/settings", s.handlePostSettings)
+	s.mux.HandleFunc("GET /api/playback", s.handleGetPlayback)
+	s.mux.HandleFunc("POST /api/jump", s.handleJump)
+	s.mux.HandleFunc("POST /api/replay", s.handleReplay)
+	s.mux.HandleFunc("POST /api/queue", s.handleQueue)
+	s.mux.HandleFunc("GET /status", s.handleStatus)
+	s.mux.HandleFunc("POST /api/status", s.handlePostStatus)
+
+	return s
+}
+
+// Settings returns the current playback settings
+func (s *Server) Settings() Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.settings
+}
+
+// SetSettings replaces the playback settings
+func (s *Server) SetSettings(settings Settings) error {
+	if err := validateSettings(settings); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.settings = settings
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleIndex serves the slideshow page
+func (s *Server) handleIndex(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexHTML)
+}
+
+// handleFrame serves the image of a single frame
+func (s *Server) handleFrame(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 || index >= len(s.frames) {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(s.frames[index].Path))
+	if contentType == "" {
+		contentType = "image/png"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "max-age=3600")
+	http.ServeFile(w, r, s.frames[index].Path)
+}
+
+// handleFrames serves the list of frames
+func (s *Server) handleFrames(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, struct {
+		Count  int     `json:"count"`
+		Frames []Frame `json:"frames"`
+	}{len(s.frames), s.frames})
+}
+
+// handleGetSettings serves the playback settings
+func (s *Server) handleGetSettings(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.Settings())
+}
+
+// handlePostSettings applies a partial update of the playback settings
+func (s *Server) handlePostSettings(w http.ResponseWriter, r *http.Request) {
+	var update settingsUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&update); err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{fmt.Sprintf("invalid settings: %v", err)})
+
+		return
+	}
+
+	s.mu.Lock()
+
+	settings := s.settings
+	if update.FPS != nil {
+		settings.FPS = *update.FPS
+	}
+
+	if update.Loop != nil {
+		settings.Loop = *update.Loop
+	}
+
+	if update.Paused != nil {
+		settings.Paused = *update.Paused
+	}
+
+	err := validateSettings(settings)
+	if err == nil {
+		s.settings = settings
+	}
+
+	s.mu.Unlock()
+
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{err.Error()})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// apiError is the body of a failed API request
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// validateSettings checks that settings can be played back
+func validateSettings(settings Settings) error {
+	if settings.FPS <= 0 || settings.FPS > MaxFPS {
+		return errors.New("fps must be greater than 0 and at most " + strconv.FormatFloat(MaxFPS, 'f', -1, 64))
+	}
+
+	return nil
+}
+
+// writeJSON writes v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
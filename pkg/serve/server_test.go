@@ -0,0 +1,145 @@
+package serve
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	var frames []Frame
+
+	for _, name := range []string{"file_0000", "file_0001"} {
+		path := filepath.Join(dir, name+".png")
+		if err := os.WriteFile(path, []byte("png "+name), 0600); err != nil {
+			t.Fatalf("Failed to create frame: %v", err)
+		}
+
+		frames = append(frames, Frame{Name: name, Path: path})
+	}
+
+	server := NewServer(frames)
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	return server, ts
+}
+
+func TestServerFrames(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/frames/1")
+	if err != nil {
+		t.Fatalf("GET frame failed: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "png file_0001" {
+		t.Fatalf("Unexpected frame response %d: %q", resp.StatusCode, body)
+	}
+
+	for _, path := range []string{"/frames/2", "/frames/-1", "/frames/x"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/api/frames")
+	if err != nil {
+		t.Fatalf("GET frames failed: %v", err)
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var list struct {
+		Count  int     `json:"count"`
+		Frames []Frame `json:"frames"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode frames: %v", err)
+	}
+
+	if list.Count != 2 || list.Frames[0].Name != "file_0000" {
+		t.Errorf("Unexpected frame list: %+v", list)
+	}
+}
+
+func TestServerSettings(t *testing.T) {
+	server, ts := newTestServer(t)
+
+	resp, err := http.Post(ts.URL+"/api/settings", "application/json", strings.NewReader(`{"fps": 5, "loop": false}`))
+	if err != nil {
+		t.Fatalf("POST settings failed: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	if got := server.Settings(); got != (Settings{FPS: 5, Loop: false, Paused: false}) {
+		t.Errorf("Unexpected settings: %+v", got)
+	}
+
+	// A partial update keeps the other settings
+	resp, err = http.Post(ts.URL+"/api/settings", "application/json", strings.NewReader(`{"paused": true}`))
+	if err != nil {
+		t.Fatalf("POST settings failed: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if got := server.Settings(); got != (Settings{FPS: 5, Loop: false, Paused: true}) {
+		t.Errorf("Unexpected settings: %+v", got)
+	}
+
+	// Invalid frame rates are rejected
+	resp, err = http.Post(ts.URL+"/api/settings", "application/json", strings.NewReader(`{"fps": 0}`))
+	if err != nil {
+		t.Fatalf("POST settings failed: %v", err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || server.Settings().FPS != 5 {
+		t.Errorf("Expected invalid fps to be rejected, got %d and %+v", resp.StatusCode, server.Settings())
+	}
+}
+
+func TestServerIndex(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("GET index failed: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "/api/settings") {
+		t.Errorf("Unexpected index response %d", resp.StatusCode)
+	}
+}
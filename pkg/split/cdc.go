@@ -0,0 +1,102 @@
+package split
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// chunker cuts the data read from a reader into chunks at content-defined
+// boundaries, with a gear rolling hash, so that the same data cuts into the same
+// chunks wherever it is in the file. Repeated data then yields identical chunks,
+// see SplitOptions.Dedup.
+type chunker struct {
+	r *bufio.Reader
+	// min and max bound the size of the chunks, only the last one can be shorter
+	// than min
+	min, max int
+	// mask selects the high bits of the hash that are zero at a boundary
+	mask uint64
+	// buf holds the last chunk returned by next
+	buf []byte
+}
+
+// newChunker returns a chunker cutting r into chunks of at most maxSize bytes, of
+// 3/4 maxSize bytes on average
+func newChunker(r io.Reader, maxSize int) *chunker {
+	// Past the minimum of maxSize/2, a boundary is found within maxSize/4 bytes on
+	// average
+	maskBits := max(1, bits.Len(uint(maxSize/4))-1)
+
+	return &chunker{
+		r:    bufio.NewReaderSize(r, maxSize),
+		min:  maxSize / 2,
+		max:  maxSize,
+		mask: ^uint64(0) << (64 - maskBits),
+		buf:  make([]byte, 0, maxSize),
+	}
+}
+
+// next returns the next chunk, valid until the next call, or io.EOF after the last
+// chunk
+func (c *chunker) next() ([]byte, error) {
+	data, err := c.r.Peek(c.max)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, io.EOF
+	}
+
+	c.buf = append(c.buf[:0], data[:c.cut(data)]...)
+
+	if _, err := c.r.Discard(len(c.buf)); err != nil {
+		return nil, err
+	}
+
+	return c.buf, nil
+}
+
+// cut returns the length of the chunk starting data, the first boundary past the
+// minimum size
+func (c *chunker) cut(data []byte) int {
+	if len(data) <= c.min {
+		return len(data)
+	}
+
+	var h uint64
+
+	// Every byte shifts out of the hash after 64 more, so the window is 64 bytes
+	for i := max(0, c.min-64); i < len(data); i++ {
+		h = h<<1 + gear[data[i]]
+
+		if i >= c.min && h&c.mask == 0 {
+			return i + 1
+		}
+	}
+
+	return len(data)
+}
+
+// gear maps the bytes to the random values of the rolling hash, fixed so that the
+// boundaries of the same data never change between versions
+var gear = gearTable()
+
+// gearTable returns the values of gear, generated with splitmix64
+func gearTable() [256]uint64 {
+	var table [256]uint64
+
+	state := uint64(0x5146544d)
+
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		table[i] = z ^ z>>31
+	}
+
+	return table
+}
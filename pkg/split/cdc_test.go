@@ -0,0 +1,69 @@
+package split
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// chunkHashes returns the hashes of the chunks data is cut into
+func chunkHashes(t *testing.T, data []byte, maxSize int) map[[sha256.Size]byte]bool {
+	t.Helper()
+
+	hashes := make(map[[sha256.Size]byte]bool)
+	c := newChunker(bytes.NewReader(data), maxSize)
+	total := 0
+
+	for {
+		chunk, err := c.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(chunk) > maxSize || (len(chunk) < maxSize/2 && total+len(chunk) != len(data)) {
+			t.Fatalf("chunk of %d bytes at %d, want %d to %d", len(chunk), total, maxSize/2, maxSize)
+		}
+
+		total += len(chunk)
+		hashes[sha256.Sum256(chunk)] = true
+	}
+
+	if total != len(data) {
+		t.Fatalf("chunks hold %d bytes, want %d", total, len(data))
+	}
+
+	return hashes
+}
+
+func TestChunkerBoundaries(t *testing.T) {
+	data := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	original := chunkHashes(t, data, 1024)
+
+	// Inserting bytes only changes the chunks around them
+	shifted := chunkHashes(t, append([]byte("inserted"), data...), 1024)
+
+	same := 0
+	for h := range shifted {
+		if original[h] {
+			same++
+		}
+	}
+
+	if same < len(original)-2 {
+		t.Errorf("%d of %d chunks unchanged by an insertion", same, len(original))
+	}
+
+	// Repeated data yields identical chunks
+	if n := len(chunkHashes(t, make([]byte, 64<<10), 1024)); n > 2 {
+		t.Errorf("zeros cut into %d distinct chunks", n)
+	}
+}
@@ -0,0 +1,48 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+var (
+	// ErrHashMismatch is returned when merged data does not match the hash it was
+	// split with. Checksum mismatches of single chunks match it as well.
+	ErrHashMismatch = errors.New("hash mismatch")
+
+	// ErrBadMetadata is returned when the first chunk does not carry valid metadata
+	ErrBadMetadata = errors.New("bad metadata")
+
+	// ErrUnsupportedVersion is returned for data written by a newer version of the format
+	ErrUnsupportedVersion = errors.New("unsupported version")
+)
+
+// ErrMissingChunk is returned when a chunk needed to merge a file is not available
+type ErrMissingChunk struct {
+	// Index is the index of the missing chunk
+	Index int
+}
+
+// Error implements the error interface
+func (e *ErrMissingChunk) Error() string {
+	return fmt.Sprintf("missing chunk %d", e.Index)
+}
+
+// ChunkChecksumError reports a chunk whose data does not match its checksum
+type ChunkChecksumError struct {
+	// Index is the index of the corrupted chunk
+	Index int
+	// Path is the path of the chunk file
+	Path string
+}
+
+// Error implements the error interface
+func (e *ChunkChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch: chunk %d (%s) is corrupted", e.Index, filepath.Base(e.Path))
+}
+
+// Unwrap lets errors.Is match a checksum mismatch as ErrHashMismatch
+func (e *ChunkChecksumError) Unwrap() error {
+	return ErrHashMismatch
+}
@@ -0,0 +1,151 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxNameBytes is the length in bytes of the longest file name accepted by the file
+// systems of every supported OS
+const MaxNameBytes = 255
+
+// ErrInvalidFileName is returned for a file name the running OS cannot create
+var ErrInvalidFileName = errors.New("invalid file name")
+
+// windowsReserved are the device names Windows reserves, with or without extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// CheckFileName returns an error wrapping ErrInvalidFileName if name, the name
+// recorded by the sender, cannot be used as a file name on the running OS: it is
+// longer than MaxNameBytes or holds characters or a device name the OS rejects.
+func CheckFileName(name string) error {
+	return checkFileName(name, runtime.GOOS)
+}
+
+// SafeFileName returns name if CheckFileName accepts it, or else a name the running
+// OS accepts: rejected characters are replaced by '_', a device name is prefixed
+// with '_' and a long name is shortened, keeping its extension.
+func SafeFileName(name string) string {
+	return safeFileName(name, runtime.GOOS)
+}
+
+func checkFileName(name, goos string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("%w: %q", ErrInvalidFileName, name)
+	case len(name) > MaxNameBytes:
+		return fmt.Errorf("%w: %q is %d bytes long, at most %d", ErrInvalidFileName, name, len(name), MaxNameBytes)
+	case goos != "linux" && !utf8.ValidString(name):
+		// Windows names are UTF-16 and APFS names are UTF-8
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidFileName, name)
+	}
+
+	for _, r := range name {
+		if invalidNameRune(r, goos) {
+			return fmt.Errorf("%w: %q holds the character %q", ErrInvalidFileName, name, r)
+		}
+	}
+
+	if goos == "windows" {
+		if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+			return fmt.Errorf("%w: %q ends with a dot or a space", ErrInvalidFileName, name)
+		}
+
+		if windowsReserved[windowsDeviceName(name)] {
+			return fmt.Errorf("%w: %q is a reserved device name", ErrInvalidFileName, name)
+		}
+	}
+
+	return nil
+}
+
+func safeFileName(name, goos string) string {
+	if checkFileName(name, goos) == nil {
+		return name
+	}
+
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// Linux takes any byte, the other systems need valid UTF-8
+			if goos == "linux" {
+				b.WriteByte(name[i])
+			} else {
+				b.WriteByte('_')
+			}
+		case invalidNameRune(r, goos):
+			b.WriteByte('_')
+		default:
+			b.WriteString(name[i : i+size])
+		}
+
+		i += size
+	}
+
+	safe := b.String()
+
+	if goos == "windows" {
+		safe = strings.TrimRight(safe, ". ")
+		if safe == "" || windowsReserved[windowsDeviceName(safe)] {
+			safe = "_" + safe
+		}
+	}
+
+	return ShortenFileName(safe, MaxNameBytes)
+}
+
+// ShortenFileName cuts name to at most n bytes on a character boundary, keeping an
+// extension of up to 16 bytes
+func ShortenFileName(name string, n int) string {
+	if len(name) <= n {
+		return name
+	}
+
+	ext := ""
+	if i := strings.LastIndexByte(name, '.'); i > 0 && len(name)-i <= 16 {
+		name, ext = name[:i], name[i:]
+	}
+
+	cut := n - len(ext)
+	for cut > 0 && !utf8.RuneStart(name[cut]) {
+		cut--
+	}
+
+	return name[:cut] + ext
+}
+
+// invalidNameRune reports whether goos rejects r in a file name
+func invalidNameRune(r rune, goos string) bool {
+	if r == 0 || r == '/' {
+		return true
+	}
+
+	if goos == "windows" {
+		return r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r)
+	}
+
+	return false
+}
+
+// windowsDeviceName returns the upper case part of name before its first dot,
+// which Windows matches against its device names
+func windowsDeviceName(name string) string {
+	base, _, _ := strings.Cut(name, ".")
+
+	return strings.ToUpper(strings.TrimRight(base, " "))
+}
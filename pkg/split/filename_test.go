@@ -0,0 +1,79 @@
+package split
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCheckFileName(t *testing.T) {
+	tests := []struct {
+		name  string
+		goos  string
+		valid bool
+	}{
+		{"report.txt", "linux", true},
+		{"report.txt", "windows", true},
+		{"a:b.txt", "linux", true},
+		{"a:b.txt", "windows", false},
+		{`what?.txt`, "windows", false},
+		{"CON", "windows", false},
+		{"con.txt", "windows", false},
+		{"console.txt", "windows", true},
+		{"CON", "linux", true},
+		{"trailing.", "windows", false},
+		{"trailing ", "windows", false},
+		{"\xff.bin", "linux", true},
+		{"\xff.bin", "darwin", false},
+		{strings.Repeat("a", MaxNameBytes), "linux", true},
+		{strings.Repeat("a", MaxNameBytes+1), "linux", false},
+		{"..", "linux", false},
+		{"", "windows", false},
+	}
+
+	for _, tt := range tests {
+		err := checkFileName(tt.name, tt.goos)
+		if (err == nil) != tt.valid {
+			t.Errorf("checkFileName(%q, %s) = %v, want valid %v", tt.name, tt.goos, err, tt.valid)
+		}
+
+		if err != nil && !errors.Is(err, ErrInvalidFileName) {
+			t.Errorf("checkFileName(%q, %s) = %v, want ErrInvalidFileName", tt.name, tt.goos, err)
+		}
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		goos string
+		want string
+	}{
+		{"report.txt", "windows", "report.txt"},
+		{"a:b.txt", "linux", "a:b.txt"},
+		{`a:b|c?.txt`, "windows", "a_b_c_.txt"},
+		{"nul.txt", "windows", "_nul.txt"},
+		{"notes. ", "windows", "notes"},
+		{"\xffdata.bin", "darwin", "_data.bin"},
+		{"..", "linux", "file"},
+	}
+
+	for _, tt := range tests {
+		if got := safeFileName(tt.name, tt.goos); got != tt.want {
+			t.Errorf("safeFileName(%q, %s) = %q, want %q", tt.name, tt.goos, got, tt.want)
+		}
+	}
+
+	// A long name keeps its extension and whole characters
+	long := strings.Repeat("ç", 200) + ".tar.gz"
+
+	got := safeFileName(long, "linux")
+	if len(got) > MaxNameBytes || !strings.HasSuffix(got, ".gz") || !utf8.ValidString(got) {
+		t.Errorf("safeFileName() = %q, want a valid name of at most %d bytes", got, MaxNameBytes)
+	}
+
+	if err := checkFileName(got, "linux"); err != nil {
+		t.Errorf("Safe name rejected: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package split
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/blake3"
+	"github.com/dyammarcano/qrfiletransfer/pkg/xxhash"
+)
+
+// HashAlgorithm identifies the hash verifying a merged file, recorded in the
+// metadata since version 3
+type HashAlgorithm uint8
+
+const (
+	// HashSHA256 is SHA-256, the default and the hash of files split with metadata
+	// before version 3
+	HashSHA256 HashAlgorithm = iota
+	// HashBLAKE3 is BLAKE3, a cryptographic hash whose chunks are hashed in
+	// parallel
+	HashBLAKE3
+	// HashXXH64 is the 64-bit xxHash, the fastest, which detects accidental
+	// corruption but not tampering
+	HashXXH64
+)
+
+// hashNames are the names of the hash algorithms by ID
+var hashNames = []string{
+	HashSHA256: "sha256",
+	HashBLAKE3: "blake3",
+	HashXXH64:  "xxh64",
+}
+
+// String returns the name of the algorithm, such as "sha256"
+func (a HashAlgorithm) String() string {
+	if int(a) < len(hashNames) {
+		return hashNames[a]
+	}
+
+	return fmt.Sprintf("hash(%d)", uint8(a))
+}
+
+// ParseHashAlgorithm returns the algorithm named name, case-insensitively, such as
+// "sha256", "blake3", or "xxh64"
+func ParseHashAlgorithm(name string) (HashAlgorithm, error) {
+	for i, n := range hashNames {
+		if strings.EqualFold(name, n) {
+			return HashAlgorithm(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown hash algorithm %q (want %s)", name, strings.Join(hashNames, ", "))
+}
+
+// Size returns the size of the digests of the algorithm in bytes, 0 for an
+// unknown algorithm
+func (a HashAlgorithm) Size() int {
+	switch a {
+	case HashSHA256:
+		return sha256.Size
+	case HashBLAKE3:
+		return blake3.Size
+	case HashXXH64:
+		return xxhash.Size
+	}
+
+	return 0
+}
+
+// valid reports whether the algorithm is known
+func (a HashAlgorithm) valid() bool {
+	return int(a) < len(hashNames)
+}
+
+// new returns a new hash computing the digests of the algorithm
+func (a HashAlgorithm) new() hash.Hash {
+	switch a {
+	case HashBLAKE3:
+		return blake3.New()
+	case HashXXH64:
+		return xxhash.New()
+	}
+
+	return sha256.New()
+}
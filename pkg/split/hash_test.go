@@ -0,0 +1,40 @@
+package split
+
+import "testing"
+
+func TestParseHashAlgorithm(t *testing.T) {
+	for _, want := range []HashAlgorithm{HashSHA256, HashBLAKE3, HashXXH64} {
+		got, err := ParseHashAlgorithm(want.String())
+		if err != nil || got != want {
+			t.Errorf("ParseHashAlgorithm(%q) = %v, %v, want %v", want.String(), got, err, want)
+		}
+
+		if got := want.new().Size(); got != want.Size() {
+			t.Errorf("%v digests are %d bytes, Size() = %d", want, got, want.Size())
+		}
+	}
+
+	if got, err := ParseHashAlgorithm("BLAKE3"); err != nil || got != HashBLAKE3 {
+		t.Errorf("ParseHashAlgorithm(BLAKE3) = %v, %v", got, err)
+	}
+
+	if _, err := ParseHashAlgorithm("md5"); err == nil {
+		t.Error("ParseHashAlgorithm(md5) accepted an unknown algorithm")
+	}
+}
+
+func BenchmarkHashAlgorithm(b *testing.B) {
+	data := make([]byte, copyBufferSize)
+
+	for _, algorithm := range []HashAlgorithm{HashSHA256, HashBLAKE3, HashXXH64} {
+		b.Run(algorithm.String(), func(b *testing.B) {
+			h := algorithm.new()
+
+			b.SetBytes(int64(len(data)))
+
+			for range b.N {
+				_, _ = h.Write(data)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+package split
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// MetadataVersion is the newest version of the metadata written by SplitFile,
+	// used for files with duplicated chunks. Files without are written as version 3,
+	// which readers of version 3 still merge.
+	MetadataVersion = 4
+
+	// metadataVersionNoRefs is the version of the metadata of files without
+	// duplicated chunks
+	metadataVersionNoRefs = 3
+
+	// metadataMagic starts versioned metadata. Metadata written before versions
+	// existed (version 1) starts directly with the file hash.
+	metadataMagic = "QFTM"
+
+	// maxNameLength bounds the length of a file name read from metadata
+	maxNameLength = 4096
+)
+
+// metadata describes a split file as recorded in its first chunk
+type metadata struct {
+	// Version is the metadata format version
+	Version int
+	// Hash is the digest of the file with HashAlgorithm, in its first
+	// HashAlgorithm.Size() bytes
+	Hash [32]byte
+	// HashAlgorithm is the hash of the file, SHA-256 before version 3
+	HashAlgorithm HashAlgorithm
+	// Total is the number of chunks
+	Total uint32
+	// Size is the size of the file in bytes
+	Size int64
+	// Time is the time the file was split, in Unix seconds, 0 if omitted
+	Time int64
+	// ModTime is the modification time of the file in Unix nanoseconds, 0 if unknown
+	ModTime int64
+	// Mode holds the os.FileMode bits of the file, 0 if unknown
+	Mode uint32
+	// Name is the base name of the file
+	Name string
+	// Checksums is set when every chunk ends with a CRC-32 of its data
+	Checksums bool
+	// Refs maps the index of every chunk that duplicates an earlier chunk, and has
+	// no chunk file, to the index of that chunk, since version 4
+	Refs map[int]int
+
+	// length is the number of bytes the metadata occupies in the first chunk
+	length int64
+}
+
+// metadataV1 is the fixed-size metadata of version 1, which truncates file names
+// to MaxFilenameLength bytes and records neither mode nor modification time
+type metadataV1 struct {
+	Hash  [32]byte                // 32 bytes SHA-256
+	Total uint32                  // 4 bytes
+	Size  int64                   // 8 bytes
+	Time  int64                   // 8 bytes
+	Name  [MaxFilenameLength]byte // truncated or padded filename
+}
+
+// metadataV2Header is the fixed-size part of version 2 metadata, which is followed
+// by the uvarint length of the file name and the name itself. Version 3 adds the
+// HashAlgorithm byte between the header and the name length, and version 4 the
+// table of duplicated chunks after the name, see marshalRefs.
+type metadataV2Header struct {
+	Magic   [4]byte
+	Version uint8
+	Hash    [32]byte
+	Total   uint32
+	Size    int64
+	Time    int64
+	ModTime int64
+	Mode    uint32
+}
+
+// MetadataSize returns the number of bytes the metadata of a file named name adds
+// to the first chunk, without duplicated chunks
+func MetadataSize(name string) int {
+	return binary.Size(metadataV2Header{}) + 1 + uvarintSize(uint64(len(name))) + len(name)
+}
+
+// marshal encodes the metadata in the current format, as version 4 with the table
+// of duplicated chunks if there are any and as version 3 otherwise, recording the
+// version written in m.Version
+func (m *metadata) marshal() ([]byte, error) {
+	m.Version = metadataVersionNoRefs
+	if len(m.Refs) > 0 {
+		m.Version = MetadataVersion
+	}
+
+	header := metadataV2Header{
+		Version: uint8(m.Version),
+		Hash:    m.Hash,
+		Total:   m.Total,
+		Size:    m.Size,
+		Time:    m.Time,
+		ModTime: m.ModTime,
+		Mode:    m.Mode,
+	}
+
+	copy(header.Magic[:], metadataMagic)
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to write metadata to buffer: %w", err)
+	}
+
+	buf.WriteByte(byte(m.HashAlgorithm))
+	buf.Write(binary.AppendUvarint(nil, uint64(len(m.Name))))
+	buf.WriteString(m.Name)
+
+	if len(m.Refs) > 0 {
+		buf.Write(marshalRefs(m.Refs))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalRefs encodes the table of duplicated chunks as the uvarint number of
+// chunks followed by a pair of uvarints for every chunk in index order: the number
+// of chunks since the previous one in the table, minus one, and the index of the
+// chunk it duplicates
+func marshalRefs(refs map[int]int) []byte {
+	indices := make([]int, 0, len(refs))
+	for i := range refs {
+		indices = append(indices, i)
+	}
+
+	sort.Ints(indices)
+
+	buf := binary.AppendUvarint(nil, uint64(len(indices)))
+	previous := -1
+
+	for _, i := range indices {
+		buf = binary.AppendUvarint(buf, uint64(i-previous-1))
+		buf = binary.AppendUvarint(buf, uint64(refs[i]))
+		previous = i
+	}
+
+	return buf
+}
+
+// readRefs reads the table of duplicated chunks of a file of total chunks, see
+// marshalRefs, returning the number of bytes read
+func readRefs(r *bufio.Reader, total uint32) (map[int]int, int, error) {
+	length := 0
+
+	readUvarint := func() (uint64, error) {
+		v, err := binary.ReadUvarint(r)
+		length += uvarintSize(v)
+
+		return v, err
+	}
+
+	count, err := readUvarint()
+	if err != nil || count > uint64(total) {
+		return nil, 0, fmt.Errorf("%w: invalid duplicated chunk count", ErrBadMetadata)
+	}
+
+	if count == 0 {
+		return nil, length, nil
+	}
+
+	refs := make(map[int]int, count)
+	previous := uint64(0)
+
+	for n := range count {
+		gap, err := readUvarint()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: failed to read duplicated chunks: %w", ErrBadMetadata, err)
+		}
+
+		ref, err := readUvarint()
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: failed to read duplicated chunks: %w", ErrBadMetadata, err)
+		}
+
+		index := gap
+		if n > 0 {
+			index += previous + 1
+		}
+
+		// A chunk duplicates an earlier chunk with a chunk file of its own
+		if index < gap || index >= uint64(total) || ref >= index {
+			return nil, 0, fmt.Errorf("%w: invalid duplicated chunk %d", ErrBadMetadata, index)
+		}
+
+		if _, ok := refs[int(ref)]; ok {
+			return nil, 0, fmt.Errorf("%w: chunk %d duplicates the duplicated chunk %d", ErrBadMetadata, index, ref)
+		}
+
+		refs[int(index)] = int(ref)
+		previous = index
+	}
+
+	return refs, length, nil
+}
+
+// readMetadata reads the metadata at the start of the first chunk of a file, in
+// any supported version
+func readMetadata(fs afero.Fs, path string) (*metadata, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file for metadata extraction: %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := bufio.NewReader(f)
+
+	if magic, _ := r.Peek(len(metadataMagic)); string(magic) == metadataMagic {
+		return readMetadataV2(r)
+	}
+
+	return readMetadataV1(r)
+}
+
+// readMetadataV2 reads versioned metadata, of version 2 or later
+func readMetadataV2(r *bufio.Reader) (*metadata, error) {
+	var header metadataV2Header
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: failed to read metadata: %w", ErrBadMetadata, err)
+	}
+
+	if header.Version > MetadataVersion {
+		return nil, fmt.Errorf("%w: metadata version %d (newest supported is %d)", ErrUnsupportedVersion, header.Version, MetadataVersion)
+	}
+
+	if header.Version < 2 {
+		return nil, fmt.Errorf("%w: invalid metadata version %d", ErrBadMetadata, header.Version)
+	}
+
+	length := binary.Size(header)
+
+	algorithm := HashSHA256
+	if header.Version >= 3 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read hash algorithm: %w", ErrBadMetadata, err)
+		}
+
+		if algorithm = HashAlgorithm(b); !algorithm.valid() {
+			return nil, fmt.Errorf("%w: unknown hash algorithm %d", ErrUnsupportedVersion, b)
+		}
+
+		length++
+	}
+
+	nameLength, err := binary.ReadUvarint(r)
+	if err != nil || nameLength > maxNameLength {
+		return nil, fmt.Errorf("%w: invalid file name length", ErrBadMetadata)
+	}
+
+	name := make([]byte, nameLength)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, fmt.Errorf("%w: failed to read file name: %w", ErrBadMetadata, err)
+	}
+
+	length += uvarintSize(nameLength) + len(name)
+
+	var refs map[int]int
+	if header.Version >= 4 {
+		var n int
+
+		if refs, n, err = readRefs(r, header.Total); err != nil {
+			return nil, err
+		}
+
+		length += n
+	}
+
+	return &metadata{
+		Version:       int(header.Version),
+		Hash:          header.Hash,
+		HashAlgorithm: algorithm,
+		Total:         header.Total,
+		Size:          header.Size,
+		Time:          header.Time,
+		ModTime:       header.ModTime,
+		Mode:          header.Mode,
+		Name:          string(name),
+		Checksums:     true,
+		Refs:          refs,
+		length:        int64(length),
+	}, nil
+}
+
+// readMetadataV1 reads the fixed-size metadata of version 1. Files split with
+// per-chunk checksums before the metadata was versioned mark them after it.
+func readMetadataV1(r *bufio.Reader) (*metadata, error) {
+	var v1 metadataV1
+	if err := binary.Read(r, binary.BigEndian, &v1); err != nil {
+		return nil, fmt.Errorf("%w: failed to read metadata: %w", ErrBadMetadata, err)
+	}
+
+	m := &metadata{
+		Version: 1,
+		Hash:    v1.Hash,
+		Total:   v1.Total,
+		Size:    v1.Size,
+		Time:    v1.Time,
+		Name:    string(bytes.Trim(v1.Name[:], "\x00")),
+		length:  int64(binary.Size(v1)),
+	}
+
+	if marker, _ := r.Peek(len(checksumMagic)); string(marker) == checksumMagic {
+		m.Checksums = true
+		m.length += int64(len(checksumMagic))
+	}
+
+	return m, nil
+}
+
+// validate checks that the metadata describes a split file
+func (m *metadata) validate() error {
+	if m.Total < MinChunks || m.Size < 0 {
+		return fmt.Errorf("%w: chunk does not contain a valid chunk count and size", ErrBadMetadata)
+	}
+
+	// The name becomes the name of the merged file and must not escape its directory
+	if m.Name == "" || m.Name == "." || m.Name == ".." || strings.ContainsAny(m.Name, `/\`) {
+		return fmt.Errorf("%w: invalid file name %q", ErrBadMetadata, m.Name)
+	}
+
+	return nil
+}
+
+// fileInfo returns the description of the file recorded in the metadata
+func (m *metadata) fileInfo() *FileInfo {
+	info := &FileInfo{
+		Name:          m.Name,
+		Size:          m.Size,
+		Total:         int(m.Total),
+		Hash:          m.Hash,
+		HashAlgorithm: m.HashAlgorithm,
+		Mode:          os.FileMode(m.Mode),
+		Version:       m.Version,
+		Refs:          m.Refs,
+	}
+
+	if m.Time != 0 {
+		info.Time = time.Unix(m.Time, 0)
+	}
+
+	if m.ModTime != 0 {
+		info.ModTime = time.Unix(0, m.ModTime)
+	}
+
+	return info
+}
+
+// newHash returns a new hash of the algorithm the file was split with
+func (m *metadata) newHash() hash.Hash {
+	return m.HashAlgorithm.new()
+}
+
+// matches reports whether sum is the digest of the file recorded in the metadata
+func (m *metadata) matches(sum []byte) bool {
+	return bytes.Equal(sum, m.Hash[:m.HashAlgorithm.Size()])
+}
+
+// uvarintSize returns the number of bytes of the uvarint encoding of v
+func uvarintSize(v uint64) int {
+	return len(binary.AppendUvarint(nil, v))
+}
@@ -0,0 +1,262 @@
+package split
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestMetadataLongFileName(t *testing.T) {
+	dir := t.TempDir()
+
+	// Longer than the 46 bytes of version 1 metadata, with multi-byte characters
+	name := "relatório_trimestral_de_manutenção_preventiva_número_七.tar.gz"
+	inPath := filepath.Join(dir, name)
+	data := bytes.Repeat([]byte("metadata "), 50)
+
+	if err := os.WriteFile(inPath, data, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2024, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	if err := os.Chtimes(inPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(inPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	outDir := filepath.Join(dir, "chunks")
+	if err := NewSplit().SplitFile(file, outDir, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := filepath.Glob(filepath.Join(outDir, "*_0000.part"))
+	if err != nil || len(first) != 1 {
+		t.Fatalf("first chunk not found: %v", err)
+	}
+
+	info, err := NewSplit().ReadFileInfo(first[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != metadataVersionNoRefs || info.Name != name || info.Total != 3 {
+		t.Errorf("ReadFileInfo() = %+v", info)
+	}
+
+	if info.Mode.Perm() != 0750 || !info.ModTime.Equal(modTime) {
+		t.Errorf("ReadFileInfo() mode %v, mtime %v, want %v, %v", info.Mode, info.ModTime, os.FileMode(0750), modTime)
+	}
+
+	if err := NewSplit().MergeFile(outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := os.ReadFile(filepath.Join(outDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(merged, data) {
+		t.Error("merged file differs from the input")
+	}
+
+	// The mode and modification time of the input are restored
+	stat, err := os.Stat(filepath.Join(outDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.Mode().Perm() != 0750 || !stat.ModTime().Equal(modTime) {
+		t.Errorf("merged file mode %v, mtime %v, want %v, %v", stat.Mode(), stat.ModTime(), os.FileMode(0750), modTime)
+	}
+}
+
+func TestMetadataVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old_0000.part")
+
+	meta := metadataV1{Total: 3, Size: 10, Time: 1700000000}
+	copy(meta.Name[:], "old.txt")
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := NewSplit().ReadFileInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Version != 1 || info.Name != "old.txt" || info.Total != 3 || info.Mode != 0 || !info.ModTime.IsZero() {
+		t.Errorf("ReadFileInfo() = %+v", info)
+	}
+}
+
+func TestMetadataVersion2(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old_0000.part")
+	data := []byte("version 2")
+
+	// Version 2 has no hash algorithm between the header and the name
+	header := metadataV2Header{Version: 2, Hash: sha256.Sum256(data), Total: 1, Size: int64(len(data))}
+	copy(header.Magic[:], metadataMagic)
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, binary.BigEndian, &header); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Write(binary.AppendUvarint(nil, uint64(len("old.txt"))))
+	buf.WriteString("old.txt")
+	buf.Write(data)
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(data)))
+
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, info, err := NewSplit().ReadSingleChunk(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, data) || info.Version != 2 || info.HashAlgorithm != HashSHA256 || info.Name != "old.txt" {
+		t.Errorf("ReadSingleChunk() = %q, %+v", got, info)
+	}
+}
+
+func TestMetadataRefs(t *testing.T) {
+	refs := map[int]int{2: 1, 3: 1, 4: 1, 9: 5, 300: 7}
+	meta := &metadata{Total: 400, Name: "refs.bin", Refs: refs}
+
+	content, err := meta.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := MetadataSize("refs.bin") + len(marshalRefs(refs)); len(content) != want {
+		t.Errorf("marshal() wrote %d bytes, want %d", len(content), want)
+	}
+
+	path := filepath.Join(t.TempDir(), "refs_0000.part")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readMetadata(afero.NewOsFs(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !maps.Equal(got.Refs, refs) || got.length != int64(len(content)) || got.Version != MetadataVersion {
+		t.Errorf("readMetadata() = %v of %d bytes, version %d, want %v of %d, version %d", got.Refs, got.length, got.Version, refs, len(content), MetadataVersion)
+	}
+}
+
+func TestMetadataVersion3(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	data := bytes.Repeat([]byte("no duplicates "), 200)
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	result, err := s.SplitReader(bytes.NewReader(data), int64(len(data)), "plain.txt", "/chunks", SplitOptions{ChunkBytes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.File.Version != metadataVersionNoRefs {
+		t.Errorf("SplitReader() version = %d, want %d", result.File.Version, metadataVersionNoRefs)
+	}
+
+	got, err := readMetadata(fs, result.Chunks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != metadataVersionNoRefs || got.Refs != nil || got.length != int64(MetadataSize("plain.txt")) {
+		t.Errorf("readMetadata() = version %d, refs %v, %d bytes, want version %d of %d bytes", got.Version, got.Refs, got.length, metadataVersionNoRefs, MetadataSize("plain.txt"))
+	}
+
+	merged := new(bytes.Buffer)
+	if err := s.MergeTo("/chunks", merged); err != nil || !bytes.Equal(merged.Bytes(), data) {
+		t.Errorf("MergeTo() = %d bytes (%v), want %d", merged.Len(), err, len(data))
+	}
+}
+
+func TestMetadataErrors(t *testing.T) {
+	write := func(t *testing.T, meta *metadata) string {
+		content, err := meta.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "file_0000.part")
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		path := write(t, &metadata{Total: 2, Name: "file.txt"})
+
+		// Bump the version byte that follows the magic
+		content, _ := os.ReadFile(path)
+		content[len(metadataMagic)] = MetadataVersion + 1
+
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewSplit().ReadFileInfo(path); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("ReadFileInfo() error = %v, want ErrUnsupportedVersion", err)
+		}
+	})
+
+	t.Run("unknown hash algorithm", func(t *testing.T) {
+		if _, err := NewSplit().ReadFileInfo(write(t, &metadata{Total: 2, Name: "file.txt", HashAlgorithm: 200})); !errors.Is(err, ErrUnsupportedVersion) {
+			t.Errorf("ReadFileInfo() error = %v, want ErrUnsupportedVersion", err)
+		}
+	})
+
+	for name, refs := range map[string]map[int]int{
+		"later chunk":      {2: 3},
+		"duplicated chunk": {2: 1, 3: 2},
+		"beyond the last":  {5: 1},
+	} {
+		t.Run("duplicated chunks "+name, func(t *testing.T) {
+			if _, err := NewSplit().ReadFileInfo(write(t, &metadata{Total: 4, Name: "file.txt", Refs: refs})); !errors.Is(err, ErrBadMetadata) {
+				t.Errorf("ReadFileInfo() error = %v, want ErrBadMetadata", err)
+			}
+		})
+	}
+
+	for _, name := range []string{"", "..", "../escape.txt", `dir\file.txt`} {
+		t.Run("file name "+name, func(t *testing.T) {
+			path := write(t, &metadata{Total: 2, Name: name})
+
+			if _, err := NewSplit().ReadFileInfo(path); !errors.Is(err, ErrBadMetadata) {
+				t.Errorf("ReadFileInfo() error = %v, want ErrBadMetadata", err)
+			}
+		})
+	}
+}
@@ -5,18 +5,25 @@ package split
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/spf13/afero"
 )
 
 // Constants for file operations
@@ -27,42 +34,129 @@ const (
 	// DefaultDirPermissions is the default permission for created directories
 	DefaultDirPermissions = 0755
 
-	// MinChunks is the minimum number of chunks required for splitting
-	MinChunks = 2
+	// MinChunks is the minimum number of chunks required for splitting. A single
+	// chunk holds the metadata and the whole file; versions that predate single
+	// chunk files required 2 chunks and reject them.
+	MinChunks = 1
 
-	// MaxFilenameLength is the maximum length of a filename in the metadata
+	// MaxFilenameLength is the maximum length of a filename in version 1 metadata
 	MaxFilenameLength = 46
-)
 
-// metadata stores essential information about the split file
-type metadata struct {
-	Hash  [32]byte                // 32 bytes SHA-256
-	Total uint32                  // 4 bytes
-	Size  int64                   // 8 bytes
-	Time  int64                   // 8 bytes
-	Name  [MaxFilenameLength]byte // truncated or padded filename
-}
+	// ChecksumSize is the number of bytes the checksum adds to every chunk
+	ChecksumSize = crc32.Size
+
+	// checksumMagic follows the version 1 metadata of files split with per-chunk
+	// checksums, which version 2 metadata always implies
+	checksumMagic = "QCRC"
+)
 
 // Split is a utility struct for splitting and merging files and data
-type Split struct{}
+type Split struct {
+	// fs holds the files split and merged, nil for the operating system's
+	fs afero.Fs
+	// logger receives the progress and warnings of merges, nil to discard them
+	logger *slog.Logger
+	// deterministic omits the split and modification times from the metadata
+	deterministic bool
+}
 
 // NewSplit creates a new instance of the Split utility
 func NewSplit() *Split {
 	return &Split{}
 }
 
+// SetFs sets the file system the chunks and merged files are read from and written
+// to, e.g. afero.NewMemMapFs() to split and merge in memory. The operating system's
+// file system is used without one.
+func (s *Split) SetFs(fs afero.Fs) {
+	s.fs = fs
+}
+
+// filesystem returns the file system of s
+func (s *Split) filesystem() afero.Fs {
+	if s.fs == nil {
+		return osFs
+	}
+
+	return s.fs
+}
+
+// osFs is the operating system's file system
+var osFs = afero.NewOsFs()
+
+// SetLogger sets the logger receiving the progress and warnings of merges, which
+// are discarded without one
+func (s *Split) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// SetDeterministic omits the time of the split and the modification time of the
+// file from the metadata, so that splitting the same content twice writes the same
+// chunks. Merged files then keep the time they are written at.
+func (s *Split) SetDeterministic(enable bool) {
+	s.deterministic = enable
+}
+
+// log returns the logger of s, discarding the records without one
+func (s *Split) log() *slog.Logger {
+	if s.logger == nil {
+		return discardLogger
+	}
+
+	return s.logger
+}
+
+// discardLogger drops every record
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
+
 // SplitFile splits a file into multiple chunks of roughly equal size.
-// It creates chunks in the specified output directory and adds metadata to the first chunk.
+// Exactly chunks chunks are written, the metadata records their number, and files
+// smaller than chunks bytes leave the last chunks empty. It creates chunks in the
+// specified output directory and adds metadata to the first chunk.
 // The metadata includes an SHA-256 hash of the original file, which is used to verify
-// data integrity during merging.
+// data integrity during merging. Every chunk also ends with a CRC-32 of its data, so
+// a corrupted chunk can be identified, see ChunkChecksumError. The checksums are
+// kept with their chunks rather than in the metadata, whose size would otherwise
+// grow with the number of chunks.
 //
 // Parameters:
 //   - file: Pointer to the file to split
 //   - outDir: Directory to store the chunks
-//   - chunks: Number of chunks to create (minimum 2)
+//   - chunks: Number of chunks to create (at least MinChunks)
 //
 // Returns an error if any part of the process fails.
-func (s *Split) SplitFile(file *os.File, outDir string, chunks int) error {
+func (s *Split) SplitFile(file afero.File, outDir string, chunks int) error {
+	return s.SplitFileCtx(context.Background(), file, outDir, chunks)
+}
+
+// SplitFileCtx splits file like SplitFile, and stops with the error of ctx once ctx
+// is done, before writing the next chunk. The chunks written so far are left in
+// outDir.
+func (s *Split) SplitFileCtx(ctx context.Context, file afero.File, outDir string, chunks int) error {
+	return s.splitFile(ctx, file, outDir, chunks, nil)
+}
+
+// SplitArchive splits file like SplitFile, for a file holding an archive of the
+// directory described by dir. The metadata records the mode of the directory,
+// including os.ModeDir, and its modification time instead of those of file, so
+// that the receiver knows to unpack the merged file, see FileInfo.
+func (s *Split) SplitArchive(file afero.File, outDir string, chunks int, dir os.FileInfo) error {
+	return s.SplitArchiveCtx(context.Background(), file, outDir, chunks, dir)
+}
+
+// SplitArchiveCtx splits an archive like SplitArchive, stopping once ctx is done
+// like SplitFileCtx
+func (s *Split) SplitArchiveCtx(ctx context.Context, file afero.File, outDir string, chunks int, dir os.FileInfo) error {
+	if !dir.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir.Name())
+	}
+
+	return s.splitFile(ctx, file, outDir, chunks, dir)
+}
+
+// splitFile splits file into chunks of balanced sizes, recording the mode and
+// modification time of attrs, or of file if attrs is nil
+func (s *Split) splitFile(ctx context.Context, file afero.File, outDir string, chunks int, attrs os.FileInfo) error {
 	if chunks < MinChunks {
 		return fmt.Errorf("chunks must be at least %d", MinChunks)
 	}
@@ -72,158 +166,795 @@ func (s *Split) SplitFile(file *os.File, outDir string, chunks int) error {
 		return fmt.Errorf("failed to get file stats: %w", err)
 	}
 
-	fileSize := stat.Size()
-	chunkSize := fileSize/int64(chunks) + 1
-	buf := make([]byte, chunkSize)
+	if attrs == nil {
+		attrs = stat
+	}
+
+	_, err = s.writeChunks(ctx, file, filepath.Base(file.Name()), outDir, stat.Size(), attrs.Mode(), attrs.ModTime(), HashSHA256, balancedSizes(stat.Size(), chunks))
+
+	return err
+}
+
+// balancedSizes returns the number of file bytes stored in each chunk when splitting
+// fileSize bytes into chunks chunks, spreading the remainder over the first chunks
+// so exactly chunks chunks are written
+func balancedSizes(fileSize int64, chunks int) []int64 {
+	sizes := make([]int64, chunks)
+
+	for i := range sizes {
+		sizes[i] = fileSize / int64(chunks)
+		if int64(i) < fileSize%int64(chunks) {
+			sizes[i]++
+		}
+	}
+
+	return sizes
+}
+
+// SplitFileBySize splits a file into chunks of at most chunkBytes bytes each.
+// Unlike SplitFile, the size of the chunk files is fixed, which lets callers
+// match them to a known capacity, e.g. the payload of a QR code version. The
+// metadata added to the first chunk and the checksum added to every chunk count
+// towards their size, so every chunk file, including the first, is at most
+// chunkBytes long. A file fitting in the first chunk, an empty one for instance, is
+// written as a single chunk.
+//
+// Parameters:
+//   - file: Pointer to the file to split
+//   - outDir: Directory to store the chunks
+//   - chunkBytes: Maximum size of a chunk file (larger than MetadataSize + ChecksumSize)
+//
+// Returns an error if any part of the process fails.
+func (s *Split) SplitFileBySize(file afero.File, outDir string, chunkBytes int64) error {
+	return s.SplitFileBySizeCtx(context.Background(), file, outDir, chunkBytes)
+}
+
+// SplitFileBySizeCtx splits file like SplitFileBySize, stopping once ctx is done
+// like SplitFileCtx
+func (s *Split) SplitFileBySizeCtx(ctx context.Context, file afero.File, outDir string, chunkBytes int64) error {
+	metaSize := MetadataSize(filepath.Base(file.Name()))
+	if chunkBytes <= int64(metaSize+ChecksumSize) {
+		return fmt.Errorf("chunk size must be larger than %d bytes", metaSize+ChecksumSize)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get file stats: %w", err)
+	}
+
+	_, err = s.writeChunks(ctx, file, filepath.Base(file.Name()), outDir, stat.Size(), stat.Mode(), stat.ModTime(), HashSHA256, chunkSizes(stat.Size(), chunkBytes, int64(metaSize)))
+
+	return err
+}
+
+// SplitOptions are the options of SplitReader
+type SplitOptions struct {
+	// Chunks is the number of chunks of balanced sizes to write, like SplitFile
+	Chunks int
+	// ChunkBytes is the maximum size of a chunk file if Chunks is 0, like
+	// SplitFileBySize
+	ChunkBytes int64
+	// Mode is the mode of the file recorded in the metadata, 0 if unknown, with
+	// os.ModeDir set for an archive of a directory like SplitArchive
+	Mode os.FileMode
+	// ModTime is the modification time of the file recorded in the metadata, if not
+	// zero
+	ModTime time.Time
+	// Hash is the hash verifying the merged file, SHA-256 by default. xxHash64 is
+	// several times faster on large files, and BLAKE3 on several cores.
+	Hash HashAlgorithm
+	// Dedup cuts the file into chunks of at most ChunkBytes bytes at boundaries
+	// depending on their content instead of at fixed offsets, so that repeated data,
+	// such as the zeros of a sparse file, yields identical chunks. Only the first of
+	// identical chunks gets a chunk file, and the table in the metadata maps the
+	// others to it, see FileInfo.Refs. The first chunk then holds the metadata alone
+	// and is written last. It requires ChunkBytes, and files fitting in the first
+	// chunk are written as a single chunk as without it.
+	Dedup bool
+}
+
+// SplitResult describes the chunks written by SplitReader
+type SplitResult struct {
+	// Chunks lists the paths of the chunk files by index, starting with the first
+	// chunk, which holds the metadata. With SplitOptions.Dedup, the chunks
+	// duplicating an earlier chunk have no chunk file and are left out.
+	Chunks []string
+	// File describes the file as recorded in the metadata
+	File *FileInfo
+}
+
+// SplitReader splits the size bytes read from r, the content of a file named name,
+// into chunks in outDir like SplitFile or SplitFileBySize, depending on opts. It
+// returns the paths of the chunk files, so callers do not have to list outDir.
+func (s *Split) SplitReader(r io.Reader, size int64, name, outDir string, opts SplitOptions) (*SplitResult, error) {
+	return s.SplitReaderCtx(context.Background(), r, size, name, outDir, opts)
+}
+
+// SplitReaderCtx splits r like SplitReader, stopping once ctx is done like
+// SplitFileCtx
+func (s *Split) SplitReaderCtx(ctx context.Context, r io.Reader, size int64, name, outDir string, opts SplitOptions) (*SplitResult, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid file size %d", size)
+	}
+
+	name = filepath.Base(name)
 
-	if err := os.MkdirAll(outDir, DefaultDirPermissions); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	var sizes []int64
+
+	switch {
+	case opts.Chunks != 0:
+		if opts.Chunks < MinChunks {
+			return nil, fmt.Errorf("chunks must be at least %d", MinChunks)
+		}
+
+		sizes = balancedSizes(size, opts.Chunks)
+	case opts.ChunkBytes != 0:
+		metaSize := MetadataSize(name)
+		if opts.ChunkBytes <= int64(metaSize+ChecksumSize) {
+			return nil, fmt.Errorf("chunk size must be larger than %d bytes", metaSize+ChecksumSize)
+		}
+
+		sizes = chunkSizes(size, opts.ChunkBytes, int64(metaSize))
+	default:
+		return nil, errors.New("either the number of chunks or the chunk size is required")
+	}
+
+	if !opts.Hash.valid() {
+		return nil, fmt.Errorf("unknown hash algorithm %d", opts.Hash)
 	}
 
-	hash := sha256.New()
-	nameBase := filepath.Base(file.Name())
-	meta := metadata{
-		Total: uint32(chunks),
-		Time:  time.Now().Unix(),
-		Size:  fileSize,
-		Name:  [MaxFilenameLength]byte{},
-		Hash:  [32]byte{},
+	if opts.Dedup && opts.Chunks != 0 {
+		return nil, errors.New("deduplication requires the chunk size instead of the number of chunks")
 	}
 
-	copy(meta.Name[:], nameBase)
+	if opts.Dedup && len(sizes) > 1 {
+		return s.writeDedupChunks(ctx, r, name, outDir, size, opts.Mode, opts.ModTime, opts.Hash, opts.ChunkBytes)
+	}
 
-	var firstChunk string
+	return s.writeChunks(ctx, r, name, outDir, size, opts.Mode, opts.ModTime, opts.Hash, sizes)
+}
 
-	for i := 0; ; i++ {
-		n, err := file.Read(buf)
-		if n > 0 {
-			chunkName := fmt.Sprintf("%s_%04d.part", strings.TrimSuffix(nameBase, filepath.Ext(nameBase)), i)
-			fullPath := filepath.Join(outDir, chunkName)
+// writeChunks writes the chunks of a file named nameBase of size bytes read from r,
+// sizes listing the number of file bytes stored in each chunk, and adds the
+// metadata to the first chunk. The metadata records mode and modTime, the digest of
+// the file with algorithm, and the time of the split unless s is deterministic. It
+// stops with the error of ctx once ctx is done.
+func (s *Split) writeChunks(ctx context.Context, r io.Reader, nameBase, outDir string, size int64, mode os.FileMode, modTime time.Time, algorithm HashAlgorithm, sizes []int64) (*SplitResult, error) {
+	if err := s.filesystem().MkdirAll(outDir, DefaultDirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
 
-			if i == 0 {
-				fullPath = strings.Replace(fullPath, "part", "tmp", 1)
-				firstChunk = fullPath
-			}
+	meta := s.newMetadata(nameBase, size, mode, modTime, algorithm)
+	meta.Total = uint32(len(sizes))
+	hash := meta.newHash()
 
-			if writeErr := os.WriteFile(fullPath, buf[:n], DefaultFilePermissions); writeErr != nil {
-				return fmt.Errorf("failed to write chunk file: %w", writeErr)
-			}
+	result := &SplitResult{Chunks: make([]string, len(sizes))}
 
-			hash.Write(buf[:n])
+	// The first chunk starts with space for the metadata, written over it once the
+	// hash of the file is known
+	reserved := make([]byte, MetadataSize(nameBase))
+
+	// The file is copied through buf, so memory use does not grow with the chunks
+	buf := make([]byte, copyBufferSize)
+
+	for i, size := range sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		var prefix []byte
+		if i == 0 {
+			prefix = reserved
+		}
+
+		result.Chunks[i] = filepath.Join(outDir, chunkFileName(nameBase, i, len(sizes)))
+
+		if err := s.writeChunk(result.Chunks[i], prefix, io.LimitReader(r, size), size, hash, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	copy(meta.Hash[:], hash.Sum(nil))
+
+	if err := s.writeMetadata(result.Chunks[0], meta); err != nil {
+		return nil, err
+	}
+
+	result.File = meta.fileInfo()
+
+	return result, nil
+}
+
+// newMetadata returns the metadata of a file named nameBase of size bytes, without
+// its hash and number of chunks, recording mode and modTime and the time of the
+// split unless s is deterministic
+func (s *Split) newMetadata(nameBase string, size int64, mode os.FileMode, modTime time.Time, algorithm HashAlgorithm) *metadata {
+	meta := &metadata{
+		HashAlgorithm: algorithm,
+		Time:          time.Now().Unix(),
+		Mode:          uint32(mode),
+		Size:          size,
+		Name:          nameBase,
+	}
+
+	if !modTime.IsZero() {
+		meta.ModTime = modTime.UnixNano()
+	}
+
+	if s.deterministic {
+		meta.Time, meta.ModTime = 0, 0
+	}
+
+	return meta
+}
+
+// writeDedupChunks writes the chunks of a file like writeChunks, cut at content-defined
+// boundaries into chunk files of at most chunkBytes bytes, see SplitOptions.Dedup.
+// Identical chunks are written once and recorded in the table of the metadata,
+// which the first chunk holds alone. Should the table not fit in it, the last
+// duplicated chunks are written to chunk files of their own instead.
+func (s *Split) writeDedupChunks(ctx context.Context, r io.Reader, nameBase, outDir string, size int64, mode os.FileMode, modTime time.Time, algorithm HashAlgorithm, chunkBytes int64) (*SplitResult, error) {
+	if err := s.filesystem().MkdirAll(outDir, DefaultDirPermissions); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	meta := s.newMetadata(nameBase, size, mode, modTime, algorithm)
+	meta.Refs = make(map[int]int)
+	hash := meta.newHash()
+	chunker := newChunker(io.LimitReader(r, size), int(chunkBytes-ChecksumSize))
+
+	// paths lists the chunk files by index, empty for duplicated chunks, named with
+	// 4 digits until the number of chunks is known
+	paths := []string{""}
+	written := make(map[[sha256.Size]byte]int)
+	buf := make([]byte, copyBufferSize)
+	read := int64(0)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, err := chunker.next()
+		if errors.Is(err, io.EOF) {
+			break
 		}
 
 		if err != nil {
-			if err == io.EOF {
-				copy(meta.Hash[:], hash.Sum(nil))
+			return nil, fmt.Errorf("error reading file: %w", err)
+		}
+
+		index := len(paths)
+		read += int64(len(data))
+		_, _ = hash.Write(data)
+
+		key := sha256.Sum256(data)
+		if ref, ok := written[key]; ok {
+			meta.Refs[index] = ref
+			paths = append(paths, "")
+
+			continue
+		}
+
+		written[key] = index
+		paths = append(paths, filepath.Join(outDir, chunkFileName(nameBase, index, 0)))
+
+		if err := s.writeChunk(paths[index], nil, bytes.NewReader(data), int64(len(data)), io.Discard, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	if read < size {
+		return nil, fmt.Errorf("error reading file: %w", io.ErrUnexpectedEOF)
+	}
+
+	meta.Total = uint32(len(paths))
+	copy(meta.Hash[:], hash.Sum(nil))
+
+	if err := s.fitRefs(meta, paths, chunkBytes-ChecksumSize, buf); err != nil {
+		return nil, err
+	}
 
-				return s.injectMetadata(firstChunk, &meta)
+	// Rename the chunk files if the number of chunks needs more digits
+	if ChunkIndexWidth(len(paths)) != ChunkIndexWidth(0) {
+		for i, path := range paths[1:] {
+			if path == "" {
+				continue
 			}
 
-			return fmt.Errorf("error reading file: %w", err)
+			paths[i+1] = filepath.Join(outDir, chunkFileName(nameBase, i+1, len(paths)))
+
+			if err := s.filesystem().Rename(path, paths[i+1]); err != nil {
+				return nil, fmt.Errorf("failed to rename chunk file: %w", err)
+			}
+		}
+	}
+
+	header, err := meta.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	paths[0] = filepath.Join(outDir, chunkFileName(nameBase, 0, len(paths)))
+
+	if err := s.writeChunk(paths[0], header, bytes.NewReader(nil), 0, io.Discard, buf); err != nil {
+		return nil, err
+	}
+
+	result := &SplitResult{File: meta.fileInfo()}
+
+	for _, path := range paths {
+		if path != "" {
+			result.Chunks = append(result.Chunks, path)
+		}
+	}
+
+	return result, nil
+}
+
+// fitRefs drops the last duplicated chunks from the table of meta until its
+// metadata fits in limit bytes, copying the chunk files they duplicate to chunk
+// files of their own, listed in paths
+func (s *Split) fitRefs(meta *metadata, paths []string, limit int64, buf []byte) error {
+	indices := make([]int, 0, len(meta.Refs))
+	for i := range meta.Refs {
+		indices = append(indices, i)
+	}
+
+	sort.Ints(indices)
+
+	// Keep the longest prefix of the table that fits, allowing for the count
+	length := int64(MetadataSize(meta.Name)) + int64(uvarintSize(uint64(len(indices))))
+	keep, previous := 0, -1
+
+	for _, i := range indices {
+		length += int64(uvarintSize(uint64(i-previous-1)) + uvarintSize(uint64(meta.Refs[i])))
+		if length > limit {
+			break
+		}
+
+		keep, previous = keep+1, i
+	}
+
+	for _, i := range indices[keep:] {
+		ref := meta.Refs[i]
+		delete(meta.Refs, i)
+
+		paths[i] = filepath.Join(filepath.Dir(paths[ref]), chunkFileName(meta.Name, i, 0))
+
+		if err := s.copyChunk(paths[ref], paths[i], buf); err != nil {
+			return err
+		}
+	}
+
+	if len(meta.Refs) == 0 {
+		meta.Refs = nil
+	}
+
+	return nil
+}
+
+// copyChunk copies the chunk file at src to dst through buf
+func (s *Split) copyChunk(src, dst string, buf []byte) (err error) {
+	in, err := s.filesystem().Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk file %s: %w", src, err)
+	}
+
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := s.filesystem().OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write chunk file: %w", err)
+	}
+
+	defer func() {
+		if closeErr := out.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write chunk file: %w", closeErr)
+		}
+	}()
+
+	if _, err := io.CopyBuffer(out, in, buf); err != nil {
+		return fmt.Errorf("failed to copy chunk file: %w", err)
+	}
+
+	return nil
+}
+
+// chunkSizes returns the number of file bytes stored in each chunk when splitting
+// fileSize bytes into chunk files of at most chunkBytes bytes, the first one also
+// holding metaSize bytes of metadata
+func chunkSizes(fileSize, chunkBytes, metaSize int64) []int64 {
+	chunkBytes -= ChecksumSize
+	first := chunkBytes - metaSize
+
+	// Small enough to fit in the first chunk along with the metadata
+	if fileSize <= first {
+		return []int64{fileSize}
+	}
+
+	sizes := []int64{first}
+
+	for remaining := fileSize - first; remaining > 0; remaining -= chunkBytes {
+		sizes = append(sizes, min(remaining, chunkBytes))
+	}
+
+	return sizes
+}
+
+// copyBufferSize is the size of the buffer chunks are written through
+const copyBufferSize = 64 << 10
+
+// writeChunk writes the size bytes of data of a chunk read from r after prefix,
+// followed by the checksum of the data, copying them through buf. The data is
+// written to hash as well.
+func (s *Split) writeChunk(path string, prefix []byte, r io.Reader, size int64, hash io.Writer, buf []byte) (err error) {
+	f, err := s.filesystem().OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, DefaultFilePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write chunk file: %w", err)
+	}
+
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write chunk file: %w", closeErr)
 		}
+	}()
+
+	if _, err := f.Write(prefix); err != nil {
+		return fmt.Errorf("failed to write chunk file: %w", err)
 	}
+
+	checksum := crc32.NewIEEE()
+
+	n, err := io.CopyBuffer(io.MultiWriter(f, hash, checksum), r, buf)
+	if err != nil {
+		return fmt.Errorf("failed to copy chunk data: %w", err)
+	}
+
+	if n < size {
+		return fmt.Errorf("error reading file: %w", io.ErrUnexpectedEOF)
+	}
+
+	if err := binary.Write(f, binary.BigEndian, checksum.Sum32()); err != nil {
+		return fmt.Errorf("failed to write chunk checksum: %w", err)
+	}
+
+	return nil
+}
+
+// chunkFileName returns the name of the chunk file at index of the file nameBase
+// split into total chunks
+func chunkFileName(nameBase string, index, total int) string {
+	return fmt.Sprintf("%s_%0*d.part", strings.TrimSuffix(nameBase, filepath.Ext(nameBase)), ChunkIndexWidth(total), index)
+}
+
+// ChunkIndexWidth returns the number of digits the indices of total chunks are
+// zero-padded to in their names: 4, or as many as the last index needs beyond
+// 10000 chunks, so that the names of the chunks of a file sort in index order
+func ChunkIndexWidth(total int) int {
+	return max(4, len(strconv.Itoa(total-1)))
+}
+
+// MergeOptions are the options of MergeFileWith
+type MergeOptions struct {
+	// KeepChunks leaves the chunk files in place after a successful merge, which
+	// otherwise removes them
+	KeepChunks bool
+	// Path is the path the merged file is written to if set, instead of a file
+	// named after the metadata in the directory of the chunks
+	Path string
+	// Output receives the merged file if set, instead of a file. The data is
+	// written as the chunks are merged, before the hash of the file is verified.
+	Output io.Writer
+}
+
+// MergeResult describes a merged file
+type MergeResult struct {
+	// Path is the path of the merged file, empty if it was written to
+	// MergeOptions.Output
+	Path string
+	// File describes the file as recorded in the metadata
+	File *FileInfo
+	// Chunks is the number of chunks merged
+	Chunks int
+	// Removed lists the chunk files removed after the merge
+	Removed []string
 }
 
 // MergeFile reconstructs a file from its chunks in the specified directory.
 // It extracts metadata from the first chunk, combines all chunks into a single file,
-// and verifies the SHA-256 hash to ensure data integrity.
-// After successful merging, it removes the chunk files.
+// and verifies the hash recorded in the metadata to ensure data integrity. The
+// checksum of every chunk is verified as well, and a corrupted chunk reported as a
+// ChunkChecksumError.
+// The file mode and modification time recorded in the metadata are restored, see
+// RestoreFileInfo. After successful merging, it removes the chunk files.
 //
 // Parameters:
 //   - inDir: Directory containing the chunks
 //
 // Returns an error if any part of the process fails.
 func (s *Split) MergeFile(inDir string) error {
+	return s.MergeFileCtx(context.Background(), inDir)
+}
+
+// MergeFileCtx merges the chunks in inDir like MergeFile, and stops with the error
+// of ctx once ctx is done, before merging the next chunk. The chunks are then left
+// in inDir, and no file is written.
+func (s *Split) MergeFileCtx(ctx context.Context, inDir string) error {
+	_, err := s.MergeFileWith(ctx, inDir, MergeOptions{})
+
+	return err
+}
+
+// MergeFileTo merges the chunks in inDir like MergeFile, into the file at outPath
+// instead of the directory of the chunks. outPath is only written once the hash of
+// the merged file matches.
+func (s *Split) MergeFileTo(inDir, outPath string) error {
+	_, err := s.MergeFileWith(context.Background(), inDir, MergeOptions{Path: outPath})
+
+	return err
+}
+
+// MergeTo merges the chunks in inDir like MergeFile, writing the file to w instead
+// of a file. w receives the data before the hash of the file is verified, so a
+// returned ErrHashMismatch means the data written is corrupted.
+func (s *Split) MergeTo(inDir string, w io.Writer) error {
+	_, err := s.MergeFileWith(context.Background(), inDir, MergeOptions{Output: w})
+
+	return err
+}
+
+// MergeFileWith merges the chunks in inDir like MergeFileCtx with opts, and returns
+// where the file was written and the chunk files removed. A chunk file that cannot
+// be removed is logged and left in place.
+func (s *Split) MergeFileWith(ctx context.Context, inDir string, opts MergeOptions) (*MergeResult, error) {
 	chunks, err := s.checkFiles(inDir)
 	if err != nil {
-		return fmt.Errorf("failed to check chunk files: %w", err)
+		return nil, fmt.Errorf("failed to check chunk files: %w", err)
 	}
 
 	if len(chunks) == 0 {
-		return errors.New("no chunk files found in the specified directory")
+		return nil, errors.New("no chunk files found in the specified directory")
 	}
 
 	// Extract metadata from the first chunk
-	var (
-		meta            metadata
-		foundFirstChunk bool
-	)
-
-	for _, c := range chunks {
-		if c.first {
-			if err := s.extractMetadata(c.name, &meta); err != nil {
-				return fmt.Errorf("failed to extract metadata: %w", err)
-			}
+	if !chunks[0].first {
+		return nil, &ErrMissingChunk{Index: 0}
+	}
 
-			foundFirstChunk = true
+	meta, err := readMetadata(s.filesystem(), chunks[0].name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
 
-			break
+	if err := meta.validate(); err != nil {
+		return nil, err
+	}
+
+	files := make([]parsedChunk, meta.Total)
+	for _, chunk := range chunks {
+		if chunk.index < len(files) {
+			files[chunk.index] = chunk
 		}
 	}
 
-	if !foundFirstChunk {
-		return errors.New("first chunk (index 0) not found")
+	// Every chunk recorded by the metadata must be present, the duplicated ones as
+	// the chunk they duplicate
+	for i := range files {
+		if ref, ok := meta.Refs[i]; ok {
+			files[i] = files[ref]
+		}
+
+		if files[i].name == "" {
+			return nil, &ErrMissingChunk{Index: i}
+		}
 	}
 
-	// Create an output file
-	outputFileName := string(bytes.Trim(meta.Name[:], "\x00"))
+	result := &MergeResult{File: meta.fileInfo(), Chunks: len(files)}
+	hash := meta.newHash()
 
-	outFile, err := os.Create(filepath.Join(inDir, outputFileName))
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
+	// Process each chunk and verify data integrity
+	merge := func(out io.Writer) error {
+		for _, chunk := range files {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if err := s.mergeChunk(out, hash, chunk, meta); err != nil {
+				return err
+			}
+		}
+
+		if !meta.matches(hash.Sum(nil)) {
+			return fmt.Errorf("%w: file not reconstructed properly", ErrHashMismatch)
+		}
+
+		return nil
 	}
 
-	defer func() {
-		if closeErr := outFile.Close(); closeErr != nil {
-			// We can only log the error since we're in a deferred
-			fmt.Printf("Error closing output file: %v\n", closeErr)
+	if opts.Output != nil {
+		if err := merge(opts.Output); err != nil {
+			return nil, err
+		}
+	} else {
+		// Write the output file under a name the running OS accepts, published only
+		// once its hash matches so that a failed merge leaves no partial file behind
+		result.Path = opts.Path
+		if result.Path == "" {
+			result.Path = filepath.Join(inDir, SafeFileName(meta.Name))
 		}
-	}()
 
-	hash := sha256.New()
+		restoreInfo := func(tmpPath string) error {
+			return s.RestoreFileInfo(tmpPath, result.File)
+		}
 
-	// Process each chunk
-	for _, chunk := range chunks {
-		f, err := os.Open(chunk.name)
-		if err != nil {
-			return fmt.Errorf("failed to open chunk file %s: %w", chunk.name, err)
+		if err := PublishFile(s.filesystem(), result.Path, 0644, merge, restoreInfo); err != nil {
+			return nil, err
 		}
+	}
+
+	// Remove chunk files after a successful merge
+	if !opts.KeepChunks {
+		for _, c := range chunks {
+			if err := s.filesystem().Remove(c.name); err != nil {
+				s.log().Warn("failed to remove chunk file", "chunk", c.name, "error", err)
 
-		// Skip metadata in the first chunk
-		if chunk.first {
-			if _, err := f.Seek(int64(binary.Size(meta)), io.SeekStart); err != nil {
-				return fmt.Errorf("failed to seek past metadata: %w", err)
+				continue
 			}
+
+			result.Removed = append(result.Removed, c.name)
 		}
+	}
 
-		// Copy chunk data to an output file and calculate hash
-		if _, err := io.Copy(outFile, io.TeeReader(f, hash)); err != nil {
-			// Close the file before returning the error
-			_ = f.Close() // Ignore the close error since we're already handling another error
+	s.log().Info("merge successful", "file", meta.Name, "chunks", len(files))
 
-			return fmt.Errorf("failed to copy chunk data: %w", err)
+	return result, nil
+}
+
+// ReadSingleChunk returns the file held by a single chunk, as written for files small
+// enough to fit in the first chunk along with the metadata, see MinChunks. The
+// checksum of the chunk and the hash of the file are verified, and nothing is
+// written to disk.
+//
+// Parameters:
+//   - chunkPath: Path to the only chunk of the file
+//
+// Returns an error if the chunk holds no valid metadata or its file has more chunks.
+func (s *Split) ReadSingleChunk(chunkPath string) ([]byte, *FileInfo, error) {
+	meta, err := readMetadata(s.filesystem(), chunkPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	if err := meta.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if meta.Total != 1 {
+		return nil, nil, &ErrMissingChunk{Index: 1}
+	}
+
+	var data bytes.Buffer
+
+	hash := meta.newHash()
+	if err := s.mergeChunk(&data, hash, parsedChunk{first: true, name: chunkPath}, meta); err != nil {
+		return nil, nil, err
+	}
+
+	if !meta.matches(hash.Sum(nil)) {
+		return nil, nil, fmt.Errorf("%w: file not reconstructed properly", ErrHashMismatch)
+	}
+
+	return data.Bytes(), meta.fileInfo(), nil
+}
+
+// VerifyFile checks the chunk files of a file like MergeFile merges them, without
+// writing the file. paths lists the chunk files by index, starting with the first
+// chunk, which holds the metadata, and an empty path for a chunk that is not
+// available. Every chunk is checked, so all damaged chunks are found in one pass.
+// A chunk duplicating another, see FileInfo.Refs, is checked as that chunk.
+//
+// It returns the description of the file recorded in the metadata and the result
+// of every chunk it records, nil for an intact chunk, an ErrMissingChunk, or a
+// ChunkChecksumError. The hash of the file is only verified when every chunk is
+// intact; a mismatch is returned as ErrHashMismatch, as is unreadable metadata.
+func (s *Split) VerifyFile(paths []string) (*FileInfo, []error, error) {
+	if len(paths) == 0 || paths[0] == "" {
+		return nil, nil, &ErrMissingChunk{Index: 0}
+	}
+
+	meta, err := readMetadata(s.filesystem(), paths[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to extract metadata: %w", err)
+	}
+
+	if err := meta.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	hash := meta.newHash()
+	errs := make([]error, meta.Total)
+	intact := true
+
+	for i := range errs {
+		index := i
+		if ref, ok := meta.Refs[i]; ok {
+			index = ref
 		}
 
-		// Close the file explicitly after processing to release resources immediately
-		// This is better than using defer inside a loop which would accumulate open files
-		if err := f.Close(); err != nil {
-			return fmt.Errorf("failed to close chunk file: %w", err)
+		if index >= len(paths) || paths[index] == "" {
+			errs[i] = &ErrMissingChunk{Index: index}
+		} else {
+			errs[i] = s.mergeChunk(io.Discard, hash, parsedChunk{first: index == 0, name: paths[index], index: index}, meta)
 		}
+
+		intact = intact && errs[i] == nil
 	}
 
-	// Verify data integrity
-	if !bytes.Equal(hash.Sum(nil), meta.Hash[:]) {
-		return errors.New("hash mismatch: file not reconstructed properly")
+	if intact && !meta.matches(hash.Sum(nil)) {
+		return meta.fileInfo(), errs, fmt.Errorf("%w: file does not match its %s hash", ErrHashMismatch, meta.HashAlgorithm)
 	}
 
-	// Remove chunk files after a successful merge
-	for _, c := range chunks {
-		if err := os.Remove(c.name); err != nil {
-			fmt.Printf("Warning: failed to remove chunk file %s: %v\n", c.name, err)
+	return meta.fileInfo(), errs, nil
+}
+
+// mergeChunk appends the data of a chunk to out and hash. If the chunks of the file
+// carry checksums, the checksum at the end of the chunk is verified and a
+// ChunkChecksumError returned if it does not match.
+func (s *Split) mergeChunk(out io.Writer, hash io.Writer, chunk parsedChunk, meta *metadata) error {
+	f, err := s.filesystem().Open(chunk.name)
+	if err != nil {
+		return fmt.Errorf("failed to open chunk file %s: %w", chunk.name, err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	// Skip metadata in the first chunk
+	var offset int64
+	if chunk.first {
+		offset = meta.length
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek past metadata: %w", err)
+		}
+	}
+
+	if !meta.Checksums {
+		if _, err := io.Copy(out, io.TeeReader(f, hash)); err != nil {
+			return fmt.Errorf("failed to copy chunk data: %w", err)
 		}
+
+		return nil
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to get chunk file stats: %w", err)
+	}
+
+	dataSize := stat.Size() - offset - ChecksumSize
+	if dataSize < 0 {
+		return &ChunkChecksumError{Index: chunk.index, Path: chunk.name}
+	}
+
+	// Copy chunk data to an output file and calculate the hash and checksum
+	checksum := crc32.NewIEEE()
+	if _, err := io.CopyN(out, io.TeeReader(f, io.MultiWriter(hash, checksum)), dataSize); err != nil {
+		return fmt.Errorf("failed to copy chunk data: %w", err)
 	}
 
-	fmt.Printf("Merge successful. File saved as: %s\n", outputFileName)
+	var want uint32
+	if err := binary.Read(f, binary.BigEndian, &want); err != nil {
+		return fmt.Errorf("failed to read chunk checksum: %w", err)
+	}
+
+	if checksum.Sum32() != want {
+		return &ChunkChecksumError{Index: chunk.index, Path: chunk.name}
+	}
 
 	return nil
 }
@@ -234,7 +965,7 @@ func (s *Split) MergeFile(inDir string) error {
 // Parameters:
 //   - v: Data to split (any type)
 //   - a: Slice to store the chunks (must be pre-allocated with length equal to chunks)
-//   - chunks: Number of chunks to create (minimum 2)
+//   - chunks: Number of chunks to create (at least MinChunks)
 //
 // Returns an error if any part of the process fails.
 func (s *Split) SplitData(v any, a []any, chunks int) error {
@@ -328,99 +1059,175 @@ func (s *Split) MergeData(a []any, v any) error {
 	return nil
 }
 
-// parsedChunk represents a chunk file with its metadata
-type parsedChunk struct {
-	first bool   // indicates if this is the first chunk (contains metadata)
-	name  string // full path to the chunk file
-	index int    // numerical index of the chunk
+// FileInfo describes the original file as recorded in the metadata of the first chunk
+type FileInfo struct {
+	Name          string        // name of the original file
+	Size          int64         // size of the original file in bytes
+	Total         int           // number of chunks the file was split into
+	Hash          [32]byte      // digest of the original file with HashAlgorithm, in its first HashAlgorithm.Size() bytes
+	Time          time.Time     // time the file was split, zero if not recorded
+	Mode          os.FileMode   // mode of the original file, 0 if not recorded, with os.ModeDir for a directory archive
+	ModTime       time.Time     // modification time of the original file, zero if not recorded
+	Version       int           // version of the metadata
+	HashAlgorithm HashAlgorithm // hash of the original file, SHA-256 before metadata version 3
+	Refs          map[int]int   // index of the chunk every chunk without a chunk file duplicates, see SplitOptions.Dedup
 }
 
-// injectMetadata adds metadata to the first chunk.
-// It creates a new file with metadata at the beginning, followed by the chunk data.
-// The original temporary file is removed after a successful operation.
-func (s *Split) injectMetadata(chunkPath string, meta *metadata) error {
-	src, err := os.Open(chunkPath)
+// Sum returns the digest of the original file, HashAlgorithm.Size() bytes long
+func (i *FileInfo) Sum() []byte {
+	return i.Hash[:i.HashAlgorithm.Size()]
+}
+
+// ReadFileInfo reads the metadata of the first chunk of a split file.
+// It allows callers to learn about the original file without merging the chunks.
+//
+// Parameters:
+//   - chunkPath: Path to the first chunk (index 0)
+//
+// Returns an error if the chunk cannot be read or holds no valid metadata.
+func (s *Split) ReadFileInfo(chunkPath string) (*FileInfo, error) {
+	meta, err := readMetadata(s.filesystem(), chunkPath)
 	if err != nil {
-		return fmt.Errorf("failed to open source chunk file: %w", err)
+		return nil, err
 	}
-	defer func(src *os.File) {
-		if err := src.Close(); err != nil {
-			fmt.Printf("Error closing source file: %v\n", err)
+
+	if err := meta.validate(); err != nil {
+		return nil, err
+	}
+
+	return meta.fileInfo(), nil
+}
+
+// RestoreFileInfo applies the permission bits, including the executable bits, and
+// the modification time recorded in info to the file at path. Attributes that were
+// not recorded are left unchanged, and setuid, setgid, and sticky bits are never
+// restored. The mode of a directory is not applied to the archive holding it, see
+// SplitArchive.
+func (s *Split) RestoreFileInfo(path string, info *FileInfo) error {
+	if info.Mode != 0 && !info.Mode.IsDir() {
+		if err := s.filesystem().Chmod(path, info.Mode.Perm()); err != nil {
+			return fmt.Errorf("failed to restore file mode: %w", err)
+		}
+	}
+
+	if !info.ModTime.IsZero() {
+		if err := s.filesystem().Chtimes(path, time.Time{}, info.ModTime); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
 		}
-	}(src)
+	}
 
-	// Create a destination file with .part extension
-	// Use filepath functions to ensure only the extension is replaced
-	dir := filepath.Dir(chunkPath)
-	base := filepath.Base(chunkPath)
-	baseWithoutExt := strings.TrimSuffix(base, filepath.Ext(base))
-	dstName := filepath.Join(dir, baseWithoutExt+".part")
+	return nil
+}
 
-	dst, err := os.Create(dstName)
+// PublishFile writes the content written by write into a temporary file next to
+// path, flushes it to disk, calls finish with the temporary path if it is not nil,
+// e.g. to restore the attributes of the file, and renames it into place. The
+// directory is flushed too, so that the rename survives a crash. path keeps its
+// previous content, if any, until the new one is complete, and the temporary file
+// is removed if write, finish, or any step fails.
+func PublishFile(fsys afero.Fs, path string, perm os.FileMode, write func(w io.Writer) error, finish func(tmpPath string) error) (err error) {
+	tmp, err := afero.TempFile(fsys, filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return err
 	}
-	defer func(dst *os.File) {
-		if err := dst.Close(); err != nil {
-			fmt.Printf("Error closing destination file: %v\n", err)
+
+	defer func() {
+		if err != nil {
+			_ = fsys.Remove(tmp.Name())
 		}
-	}(dst)
+	}()
 
-	// Write metadata to a buffer
-	buf := new(bytes.Buffer)
-	if err := binary.Write(buf, binary.BigEndian, meta); err != nil {
-		return fmt.Errorf("failed to write metadata to buffer: %w", err)
+	if err := write(tmp); err != nil {
+		_ = tmp.Close()
+
+		return err
 	}
 
-	// Write metadata to a destination file
-	if _, err := dst.Write(buf.Bytes()); err != nil {
-		return fmt.Errorf("failed to write metadata to file: %w", err)
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return err
 	}
 
-	// Copy chunk data to a destination file
-	if _, err := io.Copy(dst, src); err != nil {
-		return fmt.Errorf("failed to copy chunk data: %w", err)
+	if err := tmp.Close(); err != nil {
+		return err
 	}
 
-	// Remove a temporary file
-	if err := os.Remove(chunkPath); err != nil {
-		return fmt.Errorf("failed to remove temporary file: %w", err)
+	if err := fsys.Chmod(tmp.Name(), perm); err != nil {
+		return err
 	}
 
+	if finish != nil {
+		if err := finish(tmp.Name()); err != nil {
+			return err
+		}
+	}
+
+	if err := fsys.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	syncDir(fsys, filepath.Dir(path))
+
 	return nil
 }
 
-// extractMetadata retrieves metadata from the first chunk.
-// It reads the binary metadata structure from the beginning of the file.
-func (s *Split) extractMetadata(filePath string, meta *metadata) error {
-	f, err := os.Open(filePath)
+// syncDir flushes the entries of dir to disk, where the file system supports it.
+// Windows cannot sync a directory, its renames are durable without.
+func syncDir(fsys afero.Fs, dir string) {
+	d, err := fsys.Open(dir)
 	if err != nil {
-		return fmt.Errorf("failed to open file for metadata extraction: %w", err)
+		return
+	}
+
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// parsedChunk represents a chunk file with its metadata
+type parsedChunk struct {
+	first bool   // indicates if this is the first chunk (contains metadata)
+	name  string // full path to the chunk file
+	index int    // numerical index of the chunk
+}
+
+// writeMetadata writes meta over the space reserved for it at the start of the
+// first chunk
+func (s *Split) writeMetadata(chunkPath string, meta *metadata) error {
+	buf, err := meta.marshal()
+	if err != nil {
+		return err
+	}
+
+	f, err := s.filesystem().OpenFile(chunkPath, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open first chunk: %w", err)
+	}
+
+	if _, err := f.WriteAt(buf, 0); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("failed to write metadata to file: %w", err)
 	}
-	defer func(f *os.File) {
-		if err := f.Close(); err != nil {
-			fmt.Printf("Error closing file: %v\n", err)
-		}
-	}(f)
 
-	if err := binary.Read(f, binary.BigEndian, meta); err != nil {
-		return fmt.Errorf("failed to read metadata: %w", err)
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close first chunk: %w", err)
 	}
 
 	return nil
 }
 
 // checkFiles identifies and sorts chunk files in a directory.
-// It uses regex to find files with the pattern `_NNNN.part`.
+// It uses regex to find files with the pattern `_NNNN.part`, of 4 digits or more.
 func (s *Split) checkFiles(dir string) ([]parsedChunk, error) {
-	entries, err := os.ReadDir(dir)
+	entries, err := afero.ReadDir(s.filesystem(), dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read directory: %w", err)
 	}
 
 	chunks := make([]parsedChunk, 0)
 
-	re := regexp.MustCompile(`_(\d{4})\.part$`)
+	re := regexp.MustCompile(`_(\d{4,})\.part$`)
 
 	for _, e := range entries {
 		if e.IsDir() {
@@ -436,7 +1243,7 @@ func (s *Split) checkFiles(dir string) ([]parsedChunk, error) {
 
 		var idx int
 		if _, scanErr := fmt.Sscanf(m[1], "%d", &idx); scanErr != nil {
-			fmt.Printf("Warning: failed to parse chunk index from %s: %v\n", e.Name(), scanErr)
+			s.log().Warn("failed to parse chunk index", "chunk", e.Name(), "error", scanErr)
 
 			continue
 		}
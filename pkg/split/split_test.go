@@ -1,11 +1,26 @@
 package split
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/blake3"
+	"github.com/dyammarcano/qrfiletransfer/pkg/xxhash"
+	"github.com/spf13/afero"
 )
 
 const testDataDir = "../../testdata"
@@ -52,3 +67,931 @@ func TestNewSplitData(t *testing.T) {
 
 	fmt.Printf("Restored: %+v\n", output)
 }
+
+func TestSplitFileBySize(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		chunkBytes int64
+		wantChunks int
+	}{
+		{"exact multiple", 1000 - MetadataSize("input.bin") + 3*(1000-ChecksumSize) - ChecksumSize, 1000, 4},
+		{"remainder", 5000, 1000, 6},
+		{"smaller than one chunk", 10, 1000, 1},
+		{"empty", 0, 1000, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			inPath := filepath.Join(dir, "input.bin")
+
+			data := make([]byte, tt.size)
+			for i := range data {
+				data[i] = byte(i * 7)
+			}
+
+			if err := os.WriteFile(inPath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := os.Open(inPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			outDir := filepath.Join(dir, "chunks")
+			if err := NewSplit().SplitFileBySize(file, outDir, tt.chunkBytes); err != nil {
+				t.Fatal(err)
+			}
+
+			chunks, err := filepath.Glob(filepath.Join(outDir, "*.part"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(chunks) != tt.wantChunks {
+				t.Fatalf("got %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+
+			for _, chunk := range chunks {
+				info, err := os.Stat(chunk)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if info.Size() > tt.chunkBytes {
+					t.Errorf("chunk %s has %d bytes, want at most %d", filepath.Base(chunk), info.Size(), tt.chunkBytes)
+				}
+			}
+
+			fileInfo, err := NewSplit().ReadFileInfo(chunks[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fileInfo.Total != tt.wantChunks {
+				t.Errorf("metadata records %d chunks, want %d", fileInfo.Total, tt.wantChunks)
+			}
+
+			if err := NewSplit().MergeFile(outDir); err != nil {
+				t.Fatal(err)
+			}
+
+			merged, err := os.ReadFile(filepath.Join(outDir, "input.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(merged) != string(data) {
+				t.Error("merged file differs from the input")
+			}
+		})
+	}
+}
+
+func TestSplitFileBySizeTooSmall(t *testing.T) {
+	file, err := os.Open(filepath.Join(testDataDir, "night.city_cars.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := NewSplit().SplitFileBySize(file, t.TempDir(), int64(MetadataSize("night.city_cars.jpg"))); err == nil {
+		t.Error("expected an error for a chunk size not larger than the metadata")
+	}
+}
+
+func TestSplitInMemory(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("chunked in memory "), 100)
+
+	if err := afero.WriteFile(fs, "/in/data.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/in/data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	if err := s.SplitFile(file, "/chunks", 4); err != nil {
+		t.Fatalf("SplitFile() error = %v", err)
+	}
+
+	if err := s.MergeFile("/chunks"); err != nil {
+		t.Fatalf("MergeFile() error = %v", err)
+	}
+
+	merged, err := afero.ReadFile(fs, "/chunks/data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(merged, content) {
+		t.Fatalf("MergeFile() wrote %d bytes, want %d", len(merged), len(content))
+	}
+
+	if _, err := os.Stat("/chunks"); !os.IsNotExist(err) {
+		t.Fatalf("SplitFile() wrote to disk: %v", err)
+	}
+}
+
+func TestMergeFileWith(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("merged with options "), 100)
+
+	if err := afero.WriteFile(fs, "/in/data.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/in/data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	if err := s.SplitFile(file, "/chunks", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Merge to a writer, keeping the chunks
+	var out bytes.Buffer
+
+	result, err := s.MergeFileWith(context.Background(), "/chunks", MergeOptions{KeepChunks: true, Output: &out})
+	if err != nil {
+		t.Fatalf("MergeFileWith() error = %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("MergeFileWith() wrote %d bytes, want %d", out.Len(), len(content))
+	}
+
+	if result.Path != "" || result.Chunks != 3 || len(result.Removed) != 0 || result.File.Name != "data.txt" {
+		t.Errorf("MergeFileWith() = %+v, want 3 chunks of data.txt written to the writer", result)
+	}
+
+	if chunks, _ := afero.Glob(fs, "/chunks/*"); len(chunks) != 3 {
+		t.Errorf("Expected only the 3 chunks to be left, got %v", chunks)
+	}
+
+	// Merge to the directory of the chunks, removing them
+	result, err = s.MergeFileWith(context.Background(), "/chunks", MergeOptions{})
+	if err != nil {
+		t.Fatalf("MergeFileWith() error = %v", err)
+	}
+
+	if result.Path != filepath.Join("/chunks", "data.txt") || len(result.Removed) != 3 {
+		t.Errorf("MergeFileWith() = %+v, want data.txt written and 3 chunks removed", result)
+	}
+
+	if chunks, _ := afero.Glob(fs, "/chunks/*.part"); len(chunks) != 0 {
+		t.Errorf("Expected the chunks to be removed, got %v", chunks)
+	}
+}
+
+func TestMergeFileTo(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("merged elsewhere "), 100)
+
+	if err := afero.WriteFile(fs, "/in/data.txt", content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/in/data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	if err := s.SplitFile(file, "/chunks", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SplitFile(file, "/chunks2", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MergeFileTo("/chunks", "/out/restored.txt"); err != nil {
+		t.Fatalf("MergeFileTo() error = %v", err)
+	}
+
+	if merged, err := afero.ReadFile(fs, "/out/restored.txt"); err != nil || !bytes.Equal(merged, content) {
+		t.Fatalf("MergeFileTo() wrote %d bytes (%v), want %d", len(merged), err, len(content))
+	}
+
+	if exists, _ := afero.Exists(fs, "/chunks/data.txt"); exists {
+		t.Error("MergeFileTo() wrote the file to the directory of the chunks")
+	}
+
+	var out bytes.Buffer
+
+	if err := s.MergeTo("/chunks2", &out); err != nil {
+		t.Fatalf("MergeTo() error = %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("MergeTo() wrote %d bytes, want %d", out.Len(), len(content))
+	}
+
+	if files, _ := afero.Glob(fs, "/chunks2/*"); len(files) != 0 {
+		t.Errorf("MergeTo() left %v", files)
+	}
+}
+
+func TestSplitReader(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("split from a reader "), 100)
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	opts := SplitOptions{ChunkBytes: 512, Mode: 0600, ModTime: modTime}
+
+	result, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "dir/stream.txt", "/chunks", opts)
+	if err != nil {
+		t.Fatalf("SplitReader() error = %v", err)
+	}
+
+	files, _ := afero.Glob(fs, "/chunks/*")
+	if len(result.Chunks) != len(files) || len(files) < 2 {
+		t.Fatalf("SplitReader() returned chunks %v, wrote %v", result.Chunks, files)
+	}
+
+	for i, path := range result.Chunks {
+		if want := filepath.Join("/chunks", chunkFileName("stream.txt", i, len(result.Chunks))); path != want {
+			t.Errorf("Chunks[%d] = %s, want %s", i, path, want)
+		}
+
+		if info, err := fs.Stat(path); err != nil || info.Size() > opts.ChunkBytes {
+			t.Errorf("Chunk %s: %v, want at most %d bytes", path, err, opts.ChunkBytes)
+		}
+	}
+
+	if result.File.Name != "stream.txt" || result.File.Total != len(result.Chunks) || result.File.Mode != 0600 || !result.File.ModTime.Equal(modTime) {
+		t.Errorf("SplitReader() file = %+v", result.File)
+	}
+
+	var out bytes.Buffer
+
+	if err := s.MergeTo("/chunks", &out); err != nil || !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("MergeTo() wrote %d bytes (%v), want %d", out.Len(), err, len(content))
+	}
+
+	// A reader shorter than its size
+	if _, err := s.SplitReader(bytes.NewReader(content[:10]), int64(len(content)), "short.txt", "/short", SplitOptions{Chunks: 2}); err == nil {
+		t.Error("SplitReader() accepted a reader shorter than its size")
+	}
+
+	if _, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "none.txt", "/none", SplitOptions{}); err == nil {
+		t.Error("SplitReader() accepted neither chunks nor a chunk size")
+	}
+}
+
+func TestSplitReaderHash(t *testing.T) {
+	content := bytes.Repeat([]byte("hashed with another algorithm "), 200)
+
+	sha := sha256.Sum256(content)
+	b3 := blake3.Sum256(content)
+
+	for _, tt := range []struct {
+		hash HashAlgorithm
+		want []byte
+	}{
+		{HashSHA256, sha[:]},
+		{HashBLAKE3, b3[:]},
+		{HashXXH64, binary.BigEndian.AppendUint64(nil, xxhash.Sum64(content))},
+	} {
+		t.Run(tt.hash.String(), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			s := NewSplit()
+			s.SetFs(fs)
+
+			result, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "hashed.txt", "/chunks", SplitOptions{Chunks: 3, Hash: tt.hash})
+			if err != nil {
+				t.Fatalf("SplitReader() error = %v", err)
+			}
+
+			info, err := s.ReadFileInfo(result.Chunks[0])
+			if err != nil {
+				t.Fatalf("ReadFileInfo() error = %v", err)
+			}
+
+			if info.HashAlgorithm != tt.hash || !bytes.Equal(info.Sum(), tt.want) || !bytes.Equal(result.File.Sum(), tt.want) {
+				t.Errorf("ReadFileInfo() hash %v %x, want %v %x", info.HashAlgorithm, info.Sum(), tt.hash, tt.want)
+			}
+
+			if _, errs, err := s.VerifyFile(result.Chunks); err != nil || errs[0] != nil {
+				t.Errorf("VerifyFile() = %v, %v", errs, err)
+			}
+
+			var out bytes.Buffer
+
+			opts := MergeOptions{KeepChunks: true, Output: &out}
+			if _, err := s.MergeFileWith(context.Background(), "/chunks", opts); err != nil || !bytes.Equal(out.Bytes(), content) {
+				t.Fatalf("MergeFileWith() wrote %d bytes (%v), want %d", out.Len(), err, len(content))
+			}
+
+			// Alter the recorded hash, which follows the magic and the version
+			first, _ := afero.ReadFile(fs, result.Chunks[0])
+			first[len(metadataMagic)+1] ^= 0xff
+
+			if err := afero.WriteFile(fs, result.Chunks[0], first, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.MergeFileWith(context.Background(), "/chunks", opts); !errors.Is(err, ErrHashMismatch) {
+				t.Errorf("MergeFileWith() error = %v, want ErrHashMismatch", err)
+			}
+		})
+	}
+
+	if _, err := NewSplit().SplitReader(bytes.NewReader(content), int64(len(content)), "x.txt", t.TempDir(), SplitOptions{Chunks: 2, Hash: 200}); err == nil {
+		t.Error("SplitReader() accepted an unknown hash algorithm")
+	}
+}
+
+// repetitiveContent returns data repeating a random block between runs of zeros
+func repetitiveContent() []byte {
+	block := make([]byte, 3000)
+	rand.New(rand.NewSource(1)).Read(block)
+
+	var content []byte
+	for range 4 {
+		content = append(content, block...)
+		content = append(content, make([]byte, 10000)...)
+	}
+
+	return content
+}
+
+func TestSplitReaderDedup(t *testing.T) {
+	content := repetitiveContent()
+
+	for _, chunkBytes := range []int64{512, 96} {
+		t.Run(fmt.Sprintf("%d bytes", chunkBytes), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+
+			s := NewSplit()
+			s.SetFs(fs)
+
+			opts := SplitOptions{ChunkBytes: chunkBytes, Dedup: true}
+
+			result, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "repeated.bin", "/chunks", opts)
+			if err != nil {
+				t.Fatalf("SplitReader() error = %v", err)
+			}
+
+			if len(result.File.Refs) == 0 || len(result.Chunks)+len(result.File.Refs) != result.File.Total {
+				t.Fatalf("SplitReader() wrote %d of %d chunks with %d duplicated", len(result.Chunks), result.File.Total, len(result.File.Refs))
+			}
+
+			// Every chunk file, including the first holding the table, fits in
+			// chunkBytes
+			paths := make([]string, result.File.Total)
+			next := 0
+
+			for i := range paths {
+				if _, ok := result.File.Refs[i]; ok {
+					continue
+				}
+
+				paths[i] = result.Chunks[next]
+				next++
+
+				if stat, err := fs.Stat(paths[i]); err != nil || stat.Size() > chunkBytes {
+					t.Errorf("chunk %d: %v, %v", i, stat.Size(), err)
+				}
+			}
+
+			info, err := s.ReadFileInfo(result.Chunks[0])
+			if err != nil || len(info.Refs) != len(result.File.Refs) {
+				t.Fatalf("ReadFileInfo() = %+v, %v", info, err)
+			}
+
+			if _, errs, err := s.VerifyFile(paths); err != nil || errors.Join(errs...) != nil {
+				t.Errorf("VerifyFile() = %v, %v", errs, err)
+			}
+
+			var out bytes.Buffer
+
+			merged, err := s.MergeFileWith(context.Background(), "/chunks", MergeOptions{Output: &out})
+			if err != nil || !bytes.Equal(out.Bytes(), content) {
+				t.Fatalf("MergeFileWith() wrote %d bytes (%v), want %d", out.Len(), err, len(content))
+			}
+
+			if merged.Chunks != result.File.Total || len(merged.Removed) != len(result.Chunks) {
+				t.Errorf("MergeFileWith() merged %d chunks and removed %d files", merged.Chunks, len(merged.Removed))
+			}
+		})
+	}
+
+	t.Run("missing duplicated chunk", func(t *testing.T) {
+		fs := afero.NewMemMapFs()
+
+		s := NewSplit()
+		s.SetFs(fs)
+
+		result, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "repeated.bin", "/chunks", SplitOptions{ChunkBytes: 512, Dedup: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The chunk of zeros the others duplicate is missing for all of them
+		var ref int
+		for _, ref = range result.File.Refs {
+			break
+		}
+
+		for _, path := range result.Chunks {
+			if strings.HasSuffix(path, fmt.Sprintf("_%04d.part", ref)) {
+				_ = fs.Remove(path)
+			}
+		}
+
+		var missing *ErrMissingChunk
+		if _, err := s.MergeFileWith(context.Background(), "/chunks", MergeOptions{Output: io.Discard}); !errors.As(err, &missing) || missing.Index != ref {
+			t.Errorf("MergeFileWith() error = %v, want chunk %d missing", err, ref)
+		}
+	})
+
+	t.Run("small file", func(t *testing.T) {
+		result, err := NewSplit().SplitReader(bytes.NewReader([]byte("small")), 5, "small.txt", t.TempDir(), SplitOptions{ChunkBytes: 512, Dedup: true})
+		if err != nil || len(result.Chunks) != 1 || result.File.Refs != nil {
+			t.Errorf("SplitReader() = %v, %v, want a single chunk", result, err)
+		}
+	})
+
+	if _, err := NewSplit().SplitReader(bytes.NewReader(content), int64(len(content)), "x.bin", t.TempDir(), SplitOptions{Chunks: 4, Dedup: true}); err == nil {
+		t.Error("SplitReader() deduplicated a fixed number of chunks")
+	}
+}
+
+func TestChunkIndexWidth(t *testing.T) {
+	for total, want := range map[int]int{1: 4, 10000: 4, 10001: 5, 100000: 5, 100001: 6} {
+		if got := ChunkIndexWidth(total); got != want {
+			t.Errorf("ChunkIndexWidth(%d) = %d, want %d", total, got, want)
+		}
+	}
+}
+
+func TestSplitManyChunks(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	content := bytes.Repeat([]byte("0123456789"), 1001)
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	// One byte a chunk, past the 10000 chunks of 4 digit indices
+	result, err := s.SplitReader(bytes.NewReader(content), int64(len(content)), "many.bin", "/chunks", SplitOptions{Chunks: len(content)})
+	if err != nil {
+		t.Fatalf("SplitReader() error = %v", err)
+	}
+
+	if first, last := filepath.Base(result.Chunks[0]), filepath.Base(result.Chunks[len(content)-1]); first != "many_00000.part" || last != "many_10009.part" {
+		t.Errorf("SplitReader() named the chunks %s to %s, want many_00000.part to many_10009.part", first, last)
+	}
+
+	var out bytes.Buffer
+
+	if err := s.MergeTo("/chunks", &out); err != nil {
+		t.Fatalf("MergeTo() error = %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), content) {
+		t.Fatalf("MergeTo() wrote %d bytes, not the %d bytes split", out.Len(), len(content))
+	}
+}
+
+func TestSplitCanceled(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if err := afero.WriteFile(fs, "/in/data.txt", bytes.Repeat([]byte("canceled "), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/in/data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.SplitFileCtx(ctx, file, "/chunks", 4); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SplitFileCtx() error = %v, want context.Canceled", err)
+	}
+
+	if chunks, _ := afero.Glob(fs, "/chunks/*"); len(chunks) != 0 {
+		t.Errorf("SplitFileCtx() wrote %v after being canceled", chunks)
+	}
+
+	if err := s.SplitFile(file, "/chunks", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MergeFileCtx(ctx, "/chunks"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("MergeFileCtx() error = %v, want context.Canceled", err)
+	}
+
+	// The chunks are left for another attempt
+	if chunks, _ := afero.Glob(fs, "/chunks/*.part"); len(chunks) != 4 {
+		t.Errorf("Expected the chunks to be kept, got %v", chunks)
+	}
+}
+
+func TestSplitFileDeterministic(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	s := NewSplit()
+	s.SetFs(fs)
+	s.SetDeterministic(true)
+
+	for i, outDir := range []string{"/chunks1", "/chunks2"} {
+		if err := afero.WriteFile(fs, "/in/data.txt", bytes.Repeat([]byte("same content "), 50), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := time.Date(2020+i, 1, 1, 0, 0, 0, 0, time.UTC)
+		if err := fs.Chtimes("/in/data.txt", modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := fs.Open("/in/data.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.SplitFile(file, outDir, 3)
+		_ = file.Close()
+		if err != nil {
+			t.Fatalf("SplitFile() error = %v", err)
+		}
+	}
+
+	for _, name := range []string{"data_0000.part", "data_0001.part", "data_0002.part"} {
+		first, err := afero.ReadFile(fs, filepath.Join("/chunks1", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := afero.ReadFile(fs, filepath.Join("/chunks2", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(first, second) {
+			t.Errorf("%s differs between runs", name)
+		}
+	}
+
+	info, err := s.ReadFileInfo("/chunks1/data_0000.part")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Time.IsZero() || !info.ModTime.IsZero() {
+		t.Errorf("ReadFileInfo() = time %v, modification time %v, want zero", info.Time, info.ModTime)
+	}
+}
+
+func TestReadSingleChunk(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "secret.txt")
+
+	if err := os.WriteFile(inPath, []byte("s3cr3t"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(inPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	outDir := filepath.Join(dir, "chunks")
+	if err := NewSplit().SplitFileBySize(file, outDir, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	chunkPath := filepath.Join(outDir, "secret_0000.part")
+
+	data, info, err := NewSplit().ReadSingleChunk(chunkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "s3cr3t" || info.Name != "secret.txt" {
+		t.Fatalf("ReadSingleChunk() = %q, %+v", data, info)
+	}
+
+	chunk, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk[len(chunk)-ChecksumSize-1] ^= 0xff
+
+	if err := os.WriteFile(chunkPath, chunk, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var checksumErr *ChunkChecksumError
+	if _, _, err := NewSplit().ReadSingleChunk(chunkPath); !errors.As(err, &checksumErr) {
+		t.Fatalf("ReadSingleChunk() error = %v, want a ChunkChecksumError", err)
+	}
+}
+
+func TestMergeFileCorruptedChunk(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "input.bin")
+
+	if err := os.WriteFile(inPath, bytes.Repeat([]byte("0123456789"), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(inPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	outDir := filepath.Join(dir, "chunks")
+	if err := NewSplit().SplitFile(file, outDir, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte in the middle of chunk 2
+	chunkPath := filepath.Join(outDir, "input_0002.part")
+
+	chunk, err := os.ReadFile(chunkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk[len(chunk)/2] ^= 0xff
+
+	if err := os.WriteFile(chunkPath, chunk, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = NewSplit().MergeFile(outDir)
+
+	var checksumErr *ChunkChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("MergeFile() error = %v, want a ChunkChecksumError", err)
+	}
+
+	if checksumErr.Index != 2 {
+		t.Errorf("corrupted chunk index = %d, want 2", checksumErr.Index)
+	}
+
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("MergeFile() error = %v, want it to match ErrHashMismatch", err)
+	}
+}
+
+func TestMergeFileToCorruptedChunk(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	if err := afero.WriteFile(fs, "/in/data.bin", bytes.Repeat([]byte("0123456789"), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := fs.Open("/in/data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	s := NewSplit()
+	s.SetFs(fs)
+
+	if err := s.SplitFile(file, "/chunks", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk, err := afero.ReadFile(fs, "/chunks/data_0003.part")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunk[len(chunk)/2] ^= 0xff
+
+	if err := afero.WriteFile(fs, "/chunks/data_0003.part", chunk, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.MergeFileTo("/chunks", "/out/restored.bin"); !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("MergeFileTo() error = %v, want ErrHashMismatch", err)
+	}
+
+	if exists, _ := afero.Exists(fs, "/out/restored.bin"); exists {
+		t.Error("MergeFileTo() left a corrupted file at outPath")
+	}
+
+	if leftover, _ := afero.Glob(fs, "/out/.restored.bin.*"); len(leftover) != 0 {
+		t.Errorf("MergeFileTo() left temporary files %v", leftover)
+	}
+}
+
+func TestMergeFileErrors(t *testing.T) {
+	newChunks := func(t *testing.T) string {
+		dir := t.TempDir()
+		inPath := filepath.Join(dir, "input.bin")
+
+		if err := os.WriteFile(inPath, bytes.Repeat([]byte("abc"), 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.Open(inPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		outDir := filepath.Join(dir, "chunks")
+		if err := NewSplit().SplitFile(file, outDir, 4); err != nil {
+			t.Fatal(err)
+		}
+
+		return outDir
+	}
+
+	t.Run("missing chunk", func(t *testing.T) {
+		dir := newChunks(t)
+		if err := os.Remove(filepath.Join(dir, "input_0002.part")); err != nil {
+			t.Fatal(err)
+		}
+
+		var missing *ErrMissingChunk
+		if err := NewSplit().MergeFile(dir); !errors.As(err, &missing) || missing.Index != 2 {
+			t.Errorf("MergeFile() error = %v, want missing chunk 2", err)
+		}
+	})
+
+	t.Run("missing last chunk", func(t *testing.T) {
+		dir := newChunks(t)
+		if err := os.Remove(filepath.Join(dir, "input_0003.part")); err != nil {
+			t.Fatal(err)
+		}
+
+		var missing *ErrMissingChunk
+		if err := NewSplit().MergeFile(dir); !errors.As(err, &missing) || missing.Index != 3 {
+			t.Errorf("MergeFile() error = %v, want missing chunk 3", err)
+		}
+	})
+
+	t.Run("bad metadata", func(t *testing.T) {
+		dir := newChunks(t)
+		if err := os.WriteFile(filepath.Join(dir, "input_0000.part"), []byte("short"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := NewSplit().MergeFile(dir); !errors.Is(err, ErrBadMetadata) {
+			t.Errorf("MergeFile() error = %v, want ErrBadMetadata", err)
+		}
+	})
+}
+
+func TestMergeFileWithoutChecksums(t *testing.T) {
+	// Chunks written before per-chunk checksums existed: metadata directly
+	// followed by the data in the first chunk, and no checksums
+	dir := t.TempDir()
+	data := []byte("legacy chunks without checksums")
+
+	meta := metadataV1{Total: 2, Size: int64(len(data)), Hash: sha256.Sum256(data)}
+	copy(meta.Name[:], "legacy.txt")
+
+	first := new(bytes.Buffer)
+	if err := binary.Write(first, binary.BigEndian, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	first.Write(data[:10])
+
+	if err := os.WriteFile(filepath.Join(dir, "legacy_0000.part"), first.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "legacy_0001.part"), data[10:], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The merge is logged to the logger only
+	var logs bytes.Buffer
+
+	s := NewSplit()
+	s.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	if err := s.MergeFile(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(logs.Bytes(), []byte(`"msg":"merge successful","file":"legacy.txt"`)) {
+		t.Errorf("Unexpected logs: %s", logs.String())
+	}
+
+	merged, err := os.ReadFile(filepath.Join(dir, "legacy.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(merged, data) {
+		t.Errorf("merged %q, want %q", merged, data)
+	}
+}
+
+// sparseFile creates a file of size zero bytes in dir without writing them
+func sparseFile(tb testing.TB, dir string, size int64) string {
+	tb.Helper()
+
+	path := filepath.Join(dir, "large.bin")
+
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := f.Truncate(size); err != nil {
+		tb.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSplitFileConstantMemory(t *testing.T) {
+	dir := t.TempDir()
+	path := sparseFile(t, dir, 64<<20)
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	// Two chunks of 32 MiB, copied through a fixed buffer
+	if err := NewSplit().SplitFile(file, filepath.Join(dir, "chunks"), 2); err != nil {
+		t.Fatalf("SplitFile() error = %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+		t.Errorf("SplitFile() allocated %d bytes for a 64 MiB file", allocated)
+	}
+}
+
+func BenchmarkSplitFile(b *testing.B) {
+	for _, size := range []int64{1 << 20, 16 << 20, 64 << 20} {
+		b.Run(fmt.Sprintf("%dMiB", size>>20), func(b *testing.B) {
+			dir := b.TempDir()
+			path := sparseFile(b, dir, size)
+
+			file, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer file.Close()
+
+			s := NewSplit()
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+
+			for n := 0; n < b.N; n++ {
+				if _, err := file.Seek(0, io.SeekStart); err != nil {
+					b.Fatal(err)
+				}
+
+				if err := s.SplitFile(file, filepath.Join(dir, "chunks"), 2); err != nil {
+					b.Fatalf("SplitFile() error = %v", err)
+				}
+			}
+		})
+	}
+}
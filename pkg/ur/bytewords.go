@@ -0,0 +1,92 @@
+package ur
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// words is the Bytewords list of BCR-2020-012, the word at index i encodes the byte i
+const words = "ableacidalsoapexaquaarchatomauntawayaxis" +
+	"backbaldbarnbeltbetabiasbluebodybragbrewbulbbuzz" +
+	"calmcashcatschefcityclawcodecolacookcostcruxcurlcuspcyan" +
+	"darkdatadaysdelidicedietdoordowndrawdropdrumdullduty" +
+	"eacheasyechoedgeepicevenexamexiteyes" +
+	"factfairfernfigsfilmfishfizzflapflewfluxfoxyfreefrogfuelfund" +
+	"galagamegeargemsgiftgirlglowgoodgraygrimgurugushgyro" +
+	"halfhanghardhawkheathelphighhillholyhopehornhuts" +
+	"icedideaidleinchinkyintoirisironitem" +
+	"jadejazzjoinjoltjowljudojugsjumpjunkjury" +
+	"keepkenokeptkeyskickkilnkingkitekiwiknob" +
+	"lamblavalazyleaflegsliarlimplionlistlogoloudloveluaulucklung" +
+	"mainmanymathmazememomenumeowmildmintmissmonk" +
+	"nailnavyneednewsnextnoonnotenumb" +
+	"obeyoboeomitonyxopenovalowls" +
+	"paidpartpeckplaypluspoempoolposepuffpumapurr" +
+	"quadquiz" +
+	"raceramprealredorichroadrockroofrubyruinrunsrust" +
+	"safesagascarsetssilkskewslotsoapsolosongstubsurfswan" +
+	"tacotasktaxitenttiedtimetinytoiltombtoystriptunatwin" +
+	"uglyundouniturgeuser" +
+	"vastveryvetovialvibeviewvisavoidvows" +
+	"wallwandwarmwaspwavewaxywebswhatwhenwhizwolfwork" +
+	"yankyawnyellyogayurt" +
+	"zapszerozestzinczonezoom"
+
+// ErrInvalidBytewords is returned when decoding text that is not valid minimal Bytewords
+var ErrInvalidBytewords = errors.New("invalid bytewords")
+
+// minimalIndex maps the first and last letter of every word to its byte
+var minimalIndex = func() map[[2]byte]byte {
+	index := make(map[[2]byte]byte, 256)
+	for i := range 256 {
+		w := words[4*i : 4*i+4]
+		index[[2]byte{w[0], w[3]}] = byte(i)
+	}
+
+	return index
+}()
+
+// EncodeMinimal returns the minimal Bytewords encoding of data followed by its
+// CRC-32: the first and last letter of the word of every byte
+func EncodeMinimal(data []byte) string {
+	data = binary.BigEndian.AppendUint32(append([]byte(nil), data...), crc32.ChecksumIEEE(data))
+
+	var b strings.Builder
+	b.Grow(2 * len(data))
+
+	for _, c := range data {
+		b.WriteByte(words[4*int(c)])
+		b.WriteByte(words[4*int(c)+3])
+	}
+
+	return b.String()
+}
+
+// DecodeMinimal returns the data of minimal Bytewords text produced by
+// EncodeMinimal, in either case, after checking its CRC-32
+func DecodeMinimal(text string) ([]byte, error) {
+	text = strings.ToLower(text)
+	if len(text)%2 != 0 || len(text) < 8 {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidBytewords, len(text))
+	}
+
+	data := make([]byte, len(text)/2)
+	for i := range data {
+		c, ok := minimalIndex[[2]byte{text[2*i], text[2*i+1]}]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown word %q", ErrInvalidBytewords, text[2*i:2*i+2])
+		}
+
+		data[i] = c
+	}
+
+	body, checksum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(checksum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidBytewords)
+	}
+
+	return body, nil
+}
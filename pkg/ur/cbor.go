@@ -0,0 +1,86 @@
+package ur
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The CBOR major types used by UR messages and fountain parts
+const (
+	cborUnsigned = 0 << 5
+	cborBytes    = 2 << 5
+	cborArray    = 4 << 5
+)
+
+// ErrInvalidCBOR is returned for a message or part that is not the expected CBOR
+var ErrInvalidCBOR = errors.New("invalid CBOR")
+
+// appendCBORHead appends the head of a CBOR item of the major type with the
+// argument n in its shortest form
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= 0xff:
+		return append(buf, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
+
+// readCBORHead reads the head of a CBOR item of the major type from data and
+// returns its argument and the rest of data
+func readCBORHead(data []byte, major byte) (uint64, []byte, error) {
+	if len(data) == 0 || data[0]&0xe0 != major {
+		return 0, nil, fmt.Errorf("%w: expected major type %d", ErrInvalidCBOR, major>>5)
+	}
+
+	info, data := data[0]&0x1f, data[1:]
+	if info < 24 {
+		return uint64(info), data, nil
+	}
+
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("%w: unsupported additional information %d", ErrInvalidCBOR, info)
+	}
+
+	if len(data) < size {
+		return 0, nil, fmt.Errorf("%w: truncated item", ErrInvalidCBOR)
+	}
+
+	var n uint64
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+
+	return n, data[size:], nil
+}
+
+// readCBORBytes reads a CBOR byte string from data and returns it and the rest of data
+func readCBORBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := readCBORHead(data, cborBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if n > uint64(len(data)) {
+		return nil, nil, fmt.Errorf("%w: truncated byte string", ErrInvalidCBOR)
+	}
+
+	return data[:n], data[n:], nil
+}
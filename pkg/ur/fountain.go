@@ -0,0 +1,263 @@
+package ur
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// minFragmentLen is the smallest fragment a message is cut into
+const minFragmentLen = 10
+
+var (
+	// ErrInconsistentPart is returned for a part that belongs to another message
+	// than the parts received before
+	ErrInconsistentPart = errors.New("part of another message")
+	// ErrChecksumMismatch is returned when the reassembled message does not match
+	// the checksum of its parts
+	ErrChecksumMismatch = errors.New("message checksum mismatch")
+)
+
+// part is a fountain part of BCR-2020-006, the XOR of the message fragments
+// chooseFragments picks for its sequence number
+type part struct {
+	seqNum     uint32
+	seqLen     int
+	messageLen int
+	checksum   uint32
+	data       []byte
+}
+
+// marshal returns the CBOR of the part: [seqNum, seqLen, messageLen, checksum, data]
+func (p *part) marshal() []byte {
+	buf := appendCBORHead(nil, cborArray, 5)
+	buf = appendCBORHead(buf, cborUnsigned, uint64(p.seqNum))
+	buf = appendCBORHead(buf, cborUnsigned, uint64(p.seqLen))
+	buf = appendCBORHead(buf, cborUnsigned, uint64(p.messageLen))
+	buf = appendCBORHead(buf, cborUnsigned, uint64(p.checksum))
+	buf = appendCBORHead(buf, cborBytes, uint64(len(p.data)))
+
+	return append(buf, p.data...)
+}
+
+// parsePart parses the CBOR of a part
+func parsePart(data []byte) (*part, error) {
+	n, data, err := readCBORHead(data, cborArray)
+	if err != nil {
+		return nil, err
+	}
+
+	if n != 5 {
+		return nil, fmt.Errorf("%w: part is an array of %d items", ErrInvalidCBOR, n)
+	}
+
+	var fields [4]uint64
+	for i := range fields {
+		if fields[i], data, err = readCBORHead(data, cborUnsigned); err != nil {
+			return nil, err
+		}
+	}
+
+	fragment, rest, err := readCBORBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidCBOR, len(rest))
+	}
+
+	if fields[0] == 0 || fields[0] > 0xffffffff || fields[1] == 0 || fields[1] > 0xffff ||
+		fields[2] == 0 || fields[2] > fields[1]*uint64(len(fragment)) || fields[3] > 0xffffffff {
+		return nil, fmt.Errorf("%w: invalid part header", ErrInvalidCBOR)
+	}
+
+	return &part{
+		seqNum:     uint32(fields[0]),
+		seqLen:     int(fields[1]),
+		messageLen: int(fields[2]),
+		checksum:   uint32(fields[3]),
+		data:       fragment,
+	}, nil
+}
+
+// fragmentLength returns the length of the fragments a message of messageLen
+// bytes is cut into: the fewest fragments of at most maxFragmentLen bytes, and of
+// at least minFragmentLen bytes
+func fragmentLength(messageLen, maxFragmentLen int) int {
+	maxFragments := max(1, messageLen/minFragmentLen)
+
+	length := messageLen
+	for count := 1; count <= maxFragments; count++ {
+		length = (messageLen + count - 1) / count
+		if length <= maxFragmentLen {
+			break
+		}
+	}
+
+	return length
+}
+
+// fountainEncoder cuts a message into fragments and mixes them into parts
+type fountainEncoder struct {
+	messageLen int
+	checksum   uint32
+	fragments  [][]byte
+}
+
+// newFountainEncoder cuts message into fragments of at most maxFragmentLen bytes,
+// the last one padded with zeros
+func newFountainEncoder(message []byte, maxFragmentLen int) *fountainEncoder {
+	length := fragmentLength(len(message), maxFragmentLen)
+	count := (len(message) + length - 1) / length
+
+	padded := make([]byte, count*length)
+	copy(padded, message)
+
+	e := &fountainEncoder{messageLen: len(message), checksum: crc32.ChecksumIEEE(message)}
+	for i := range count {
+		e.fragments = append(e.fragments, padded[i*length:(i+1)*length])
+	}
+
+	return e
+}
+
+// part returns the part with sequence number seqNum, from 1
+func (e *fountainEncoder) part(seqNum uint32) *part {
+	data := make([]byte, len(e.fragments[0]))
+	for _, i := range chooseFragments(seqNum, len(e.fragments), e.checksum) {
+		xorInto(data, e.fragments[i])
+	}
+
+	return &part{seqNum: seqNum, seqLen: len(e.fragments), messageLen: e.messageLen, checksum: e.checksum, data: data}
+}
+
+// xorInto sets dst to dst XOR src
+func xorInto(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// mixedPart is a received part not reduced to a single fragment yet
+type mixedPart struct {
+	indexes map[int]bool
+	data    []byte
+}
+
+// fountainDecoder reassembles a message from its parts in any order
+type fountainDecoder struct {
+	// first is the first part received, which the others must match
+	first    *part
+	received map[uint32]bool
+	solved   map[int][]byte
+	mixed    []*mixedPart
+	message  []byte
+	err      error
+}
+
+// receive adds a part and reports whether it was new
+func (d *fountainDecoder) receive(p *part) (bool, error) {
+	if d.first == nil {
+		d.first = p
+		d.received = make(map[uint32]bool)
+		d.solved = make(map[int][]byte)
+	} else if p.seqLen != d.first.seqLen || p.messageLen != d.first.messageLen ||
+		p.checksum != d.first.checksum || len(p.data) != len(d.first.data) {
+		return false, ErrInconsistentPart
+	}
+
+	if d.received[p.seqNum] || d.complete() {
+		return false, nil
+	}
+
+	d.received[p.seqNum] = true
+
+	indexes := make(map[int]bool)
+	for _, i := range chooseFragments(p.seqNum, p.seqLen, p.checksum) {
+		indexes[i] = true
+	}
+
+	d.reduce(&mixedPart{indexes: indexes, data: append([]byte(nil), p.data...)})
+
+	if len(d.solved) == p.seqLen {
+		d.join()
+	}
+
+	return true, d.err
+}
+
+// reduce removes the solved fragments from p and from the mixed parts a fragment
+// solved by p is mixed into, until no part reduces further
+func (d *fountainDecoder) reduce(p *mixedPart) {
+	queue := []*mixedPart{p}
+
+	for len(queue) > 0 {
+		p, queue = queue[0], queue[1:]
+
+		for i := range p.indexes {
+			if fragment, ok := d.solved[i]; ok {
+				xorInto(p.data, fragment)
+				delete(p.indexes, i)
+			}
+		}
+
+		switch len(p.indexes) {
+		case 0:
+			continue
+		case 1:
+			for i := range p.indexes {
+				d.solved[i] = p.data
+
+				// The mixed parts holding the fragment are reduced again
+				kept := d.mixed[:0]
+				for _, m := range d.mixed {
+					if m.indexes[i] {
+						queue = append(queue, m)
+					} else {
+						kept = append(kept, m)
+					}
+				}
+
+				d.mixed = kept
+			}
+		default:
+			d.mixed = append(d.mixed, p)
+		}
+	}
+}
+
+// join reassembles the message from the solved fragments
+func (d *fountainDecoder) join() {
+	message := make([]byte, 0, d.first.seqLen*len(d.first.data))
+	for i := range d.first.seqLen {
+		message = append(message, d.solved[i]...)
+	}
+
+	message = message[:d.first.messageLen]
+	if crc32.ChecksumIEEE(message) != d.first.checksum {
+		d.err = ErrChecksumMismatch
+
+		return
+	}
+
+	d.message = message
+}
+
+// complete reports whether the message was reassembled
+func (d *fountainDecoder) complete() bool {
+	return d.message != nil
+}
+
+// solvedFragments returns the indices of the fragments known, in order
+func (d *fountainDecoder) solvedFragments() []int {
+	indexes := make([]int, 0, len(d.solved))
+	for i := range d.solved {
+		indexes = append(indexes, i)
+	}
+
+	sort.Ints(indexes)
+
+	return indexes
+}
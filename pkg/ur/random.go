@@ -0,0 +1,159 @@
+package ur
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// xoshiro256 is the xoshiro256** generator the fountain code of BCR-2020-006
+// chooses the fragments of a part with, so every encoder and decoder agrees
+type xoshiro256 struct {
+	s [4]uint64
+}
+
+// newXoshiro256 returns the generator seeded with the SHA-256 digest of seed
+func newXoshiro256(seed []byte) *xoshiro256 {
+	digest := sha256.Sum256(seed)
+
+	r := &xoshiro256{}
+	for i := range r.s {
+		r.s[i] = binary.BigEndian.Uint64(digest[8*i:])
+	}
+
+	return r
+}
+
+// next returns the next 64 random bits
+func (r *xoshiro256) next() uint64 {
+	result := bits.RotateLeft64(r.s[1]*5, 7) * 9
+	t := r.s[1] << 17
+
+	r.s[2] ^= r.s[0]
+	r.s[3] ^= r.s[1]
+	r.s[1] ^= r.s[2]
+	r.s[0] ^= r.s[3]
+	r.s[2] ^= t
+	r.s[3] = bits.RotateLeft64(r.s[3], 45)
+
+	return result
+}
+
+// nextDouble returns a random number in [0, 1)
+func (r *xoshiro256) nextDouble() float64 {
+	return float64(r.next()) / (float64(math.MaxUint64) + 1)
+}
+
+// nextInt returns a random integer in [low, high]
+func (r *xoshiro256) nextInt(low, high int) int {
+	return int(r.nextDouble()*float64(high-low+1)) + low
+}
+
+// sampler draws indices with given probabilities by Walker's alias method
+type sampler struct {
+	probs   []float64
+	aliases []int
+}
+
+// newSampler returns a sampler of the indices of weights, in proportion to them
+func newSampler(weights []float64) *sampler {
+	n := len(weights)
+
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+
+	p := make([]float64, n)
+	for i, w := range weights {
+		p[i] = w * float64(n) / sum
+	}
+
+	var small, large []int
+	for i := n - 1; i >= 0; i-- {
+		if p[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	s := &sampler{probs: make([]float64, n), aliases: make([]int, n)}
+
+	for len(small) > 0 && len(large) > 0 {
+		a := small[len(small)-1]
+		small = small[:len(small)-1]
+		g := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		s.probs[a] = p[a]
+		s.aliases[a] = g
+		p[g] += p[a] - 1
+
+		if p[g] < 1 {
+			small = append(small, g)
+		} else {
+			large = append(large, g)
+		}
+	}
+
+	// What is left has a probability of 1, up to rounding errors
+	for _, i := range large {
+		s.probs[i] = 1
+	}
+
+	for _, i := range small {
+		s.probs[i] = 1
+	}
+
+	return s
+}
+
+// next draws an index
+func (s *sampler) next(r *xoshiro256) int {
+	r1 := r.nextDouble()
+	r2 := r.nextDouble()
+
+	i := int(float64(len(s.probs)) * r1)
+	if r2 < s.probs[i] {
+		return i
+	}
+
+	return s.aliases[i]
+}
+
+// chooseFragments returns the indices of the fragments mixed into the part with
+// sequence number seqNum of a message of seqLen fragments with the checksum. The
+// first seqLen parts hold one fragment each, the following ones a random mix.
+func chooseFragments(seqNum uint32, seqLen int, checksum uint32) []int {
+	if int(seqNum) <= seqLen {
+		return []int{int(seqNum) - 1}
+	}
+
+	seed := binary.BigEndian.AppendUint32(nil, seqNum)
+	seed = binary.BigEndian.AppendUint32(seed, checksum)
+	r := newXoshiro256(seed)
+
+	// The degree follows the ideal soliton distribution
+	weights := make([]float64, seqLen)
+	for i := range weights {
+		weights[i] = 1 / float64(i+1)
+	}
+
+	degree := newSampler(weights).next(r) + 1
+
+	remaining := make([]int, seqLen)
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	shuffled := make([]int, 0, seqLen)
+	for len(remaining) > 0 {
+		i := r.nextInt(0, len(remaining)-1)
+		shuffled = append(shuffled, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+
+	return shuffled[:degree]
+}
@@ -0,0 +1,248 @@
+// Package ur implements Uniform Resources, the animated QR code format of the
+// Blockchain Commons (BCR-2020-005), so files can be exchanged with UR-capable
+// wallets and scanner apps.
+//
+// A UR is a typed CBOR message written as "ur:<type>/<bytewords>", where the body is
+// the message in minimal Bytewords (BCR-2020-012). A message too long for one QR
+// code is cut into fragments and sent as a sequence of parts, "ur:<type>/<seq>-<n>/
+// <bytewords>", with the fountain code of BCR-2020-006: the first n parts hold one
+// fragment each, and every part after them a pseudo-random mix of fragments, so a
+// receiver reassembles the message from enough parts in any order, whichever
+// ones it misses.
+package ur
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TypeBytes is the UR type of a message that is a CBOR byte string
+const TypeBytes = "bytes"
+
+// scheme starts every UR
+const scheme = "ur:"
+
+// ErrInvalidUR is returned for text that is not a UR
+var ErrInvalidUR = errors.New("invalid UR")
+
+// UR is a typed CBOR message
+type UR struct {
+	// Type is the type of the message, e.g. TypeBytes
+	Type string
+	// CBOR is the message
+	CBOR []byte
+}
+
+// NewBytes returns the UR of type TypeBytes holding data
+func NewBytes(data []byte) UR {
+	return UR{Type: TypeBytes, CBOR: append(appendCBORHead(nil, cborBytes, uint64(len(data))), data...)}
+}
+
+// Bytes returns the data of a UR of type TypeBytes
+func (u UR) Bytes() ([]byte, error) {
+	if u.Type != TypeBytes {
+		return nil, fmt.Errorf("%w: type %q is not %q", ErrInvalidUR, u.Type, TypeBytes)
+	}
+
+	data, rest, err := readCBORBytes(u.CBOR)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrInvalidCBOR, len(rest))
+	}
+
+	return data, nil
+}
+
+// IsUR reports whether text starts like a UR, in either case
+func IsUR(text string) bool {
+	return len(text) >= len(scheme) && strings.EqualFold(text[:len(scheme)], scheme)
+}
+
+// Encoder writes a UR as a sequence of parts
+type Encoder struct {
+	typ      string
+	message  []byte
+	fountain *fountainEncoder
+}
+
+// NewEncoder returns an Encoder cutting u into fragments of at most maxFragmentLen bytes
+func NewEncoder(u UR, maxFragmentLen int) (*Encoder, error) {
+	if !validType(u.Type) {
+		return nil, fmt.Errorf("%w: type %q", ErrInvalidUR, u.Type)
+	}
+
+	if len(u.CBOR) == 0 || maxFragmentLen < 1 {
+		return nil, fmt.Errorf("%w: empty message or fragment", ErrInvalidUR)
+	}
+
+	return &Encoder{typ: u.Type, message: u.CBOR, fountain: newFountainEncoder(u.CBOR, maxFragmentLen)}, nil
+}
+
+// SeqLen returns the number of fragments, which is also the number of parts that
+// hold the message without any mixing
+func (e *Encoder) SeqLen() int {
+	return len(e.fountain.fragments)
+}
+
+// Part returns the part with sequence number seqNum, from 1, in lower case. A
+// message of a single fragment is written as a single part UR, whatever seqNum.
+func (e *Encoder) Part(seqNum int) string {
+	if e.SeqLen() == 1 {
+		return scheme + e.typ + "/" + EncodeMinimal(e.message)
+	}
+
+	p := e.fountain.part(uint32(seqNum))
+
+	return fmt.Sprintf("%s%s/%d-%d/%s", scheme, e.typ, seqNum, p.seqLen, EncodeMinimal(p.marshal()))
+}
+
+// Decoder reassembles a UR from its parts, received in any order
+type Decoder struct {
+	typ      string
+	result   *UR
+	fountain fountainDecoder
+}
+
+// Receive adds a part, or a single part UR, in either case. Parts already received
+// are ignored. An error is returned for text that is not a UR or a part of another
+// UR than the parts received before.
+func (d *Decoder) Receive(text string) error {
+	typ, seq, body, err := parse(text)
+	if err != nil {
+		return err
+	}
+
+	if d.typ != "" && typ != d.typ {
+		return fmt.Errorf("%w: type %q, expected %q", ErrInconsistentPart, typ, d.typ)
+	}
+
+	data, err := DecodeMinimal(body)
+	if err != nil {
+		return err
+	}
+
+	d.typ = typ
+
+	// A single part UR holds the whole message
+	if seq == "" {
+		if d.result == nil {
+			d.result = &UR{Type: typ, CBOR: data}
+		}
+
+		return nil
+	}
+
+	p, err := parsePart(data)
+	if err != nil {
+		return err
+	}
+
+	if want := fmt.Sprintf("%d-%d", p.seqNum, p.seqLen); seq != want {
+		return fmt.Errorf("%w: sequence %s does not match part %s", ErrInvalidUR, seq, want)
+	}
+
+	if _, err := d.fountain.receive(p); err != nil {
+		return err
+	}
+
+	if d.result == nil && d.fountain.complete() {
+		d.result = &UR{Type: typ, CBOR: d.fountain.message}
+	}
+
+	return nil
+}
+
+// Complete reports whether the UR was reassembled
+func (d *Decoder) Complete() bool {
+	return d.result != nil
+}
+
+// Result returns the reassembled UR, or nil if it is not complete
+func (d *Decoder) Result() *UR {
+	return d.result
+}
+
+// Fragments returns the number of fragments of the UR and the indices of those
+// received or solved so far. A single part UR has one fragment. The total is 0
+// until a part has been received.
+func (d *Decoder) Fragments() (int, []int) {
+	if d.fountain.first == nil {
+		if d.result != nil {
+			return 1, []int{0}
+		}
+
+		return 0, nil
+	}
+
+	return d.fountain.first.seqLen, d.fountain.solvedFragments()
+}
+
+// SeqNum returns the sequence number of a part, 1 for a single part UR
+func SeqNum(text string) (int, error) {
+	_, seq, _, err := parse(text)
+	if err != nil {
+		return 0, err
+	}
+
+	if seq == "" {
+		return 1, nil
+	}
+
+	n, _, _ := strings.Cut(seq, "-")
+
+	return strconv.Atoi(n)
+}
+
+// parse splits a UR into its type, sequence ("" for a single part UR), and body
+func parse(text string) (typ, seq, body string, err error) {
+	if !IsUR(text) {
+		return "", "", "", fmt.Errorf("%w: missing %q scheme", ErrInvalidUR, scheme)
+	}
+
+	components := strings.Split(strings.ToLower(text[len(scheme):]), "/")
+
+	switch len(components) {
+	case 2:
+		typ, body = components[0], components[1]
+	case 3:
+		typ, seq, body = components[0], components[1], components[2]
+
+		n, total, found := strings.Cut(seq, "-")
+		if _, err := strconv.ParseUint(n, 10, 32); !found || err != nil {
+			return "", "", "", fmt.Errorf("%w: sequence %q", ErrInvalidUR, seq)
+		}
+
+		if _, err := strconv.ParseUint(total, 10, 16); err != nil {
+			return "", "", "", fmt.Errorf("%w: sequence %q", ErrInvalidUR, seq)
+		}
+	default:
+		return "", "", "", fmt.Errorf("%w: %d path components", ErrInvalidUR, len(components))
+	}
+
+	if !validType(typ) {
+		return "", "", "", fmt.Errorf("%w: type %q", ErrInvalidUR, typ)
+	}
+
+	return typ, seq, body, nil
+}
+
+// validType reports whether typ is a valid UR type: lower case letters, digits,
+// and hyphens
+func validType(typ string) bool {
+	if typ == "" {
+		return false
+	}
+
+	for _, c := range typ {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,157 @@
+package ur
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestBytewordsMinimal(t *testing.T) {
+	// The test vector of BCR-2020-012
+	data := []byte{0x00, 0x01, 0x02, 0x80, 0xff}
+
+	encoded := EncodeMinimal(data)
+	if encoded != "aeadaolazmjendeoti" {
+		t.Fatalf("EncodeMinimal() = %q, want %q", encoded, "aeadaolazmjendeoti")
+	}
+
+	decoded, err := DecodeMinimal(strings.ToUpper(encoded))
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("DecodeMinimal() = %x, %v, want %x", decoded, err, data)
+	}
+
+	for _, invalid := range []string{"aeadaolazmjendeot", "aeadaolazmjendeota", "aeadaolazmjendeotx", "aeadaolazmjendeoto"} {
+		if _, err := DecodeMinimal(invalid); !errors.Is(err, ErrInvalidBytewords) {
+			t.Errorf("DecodeMinimal(%q) error = %v, want %v", invalid, err, ErrInvalidBytewords)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	// The test vectors of BCR-2020-006
+	r := newXoshiro256([]byte("Wolf"))
+	want := []uint64{42, 81, 85, 8, 82, 84, 76, 73, 70, 88, 2, 74}
+
+	for i, w := range want {
+		if got := r.next() % 100; got != w {
+			t.Fatalf("Value %d is %d, want %d", i, got, w)
+		}
+	}
+
+	r = newXoshiro256([]byte("Wolf"))
+	s := newSampler([]float64{1, 2, 4, 8})
+
+	for i, w := range []int{3, 3, 3, 3, 3, 3, 3, 0, 2, 3, 3, 3, 3, 1, 2, 2, 1, 3, 3, 2} {
+		if got := s.next(r); got != w {
+			t.Fatalf("Sample %d is %d, want %d", i, got, w)
+		}
+	}
+}
+
+func TestFragmentLength(t *testing.T) {
+	tests := []struct{ messageLen, maxFragmentLen, want int }{
+		{12345, 1955, 1764},
+		{12345, 30000, 12345},
+		{5, 100, 5},
+		{100, 10, 10},
+	}
+
+	for _, tt := range tests {
+		if got := fragmentLength(tt.messageLen, tt.maxFragmentLen); got != tt.want {
+			t.Errorf("fragmentLength(%d, %d) = %d, want %d", tt.messageLen, tt.maxFragmentLen, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	data := make([]byte, 4096)
+	rng.Read(data)
+
+	enc, err := NewEncoder(NewBytes(data), 200)
+	if err != nil {
+		t.Fatalf("NewEncoder failed: %v", err)
+	}
+
+	seqLen := enc.SeqLen()
+	if seqLen != 21 {
+		t.Fatalf("SeqLen() = %d, want 21", seqLen)
+	}
+
+	// Lose a third of the fragment parts and make up for them with mixed parts,
+	// received in random order and in upper case as QR codes hold them
+	var parts []string
+	for seq := 1; seq <= 3*seqLen; seq++ {
+		if seq <= seqLen && seq%3 == 0 {
+			continue
+		}
+
+		parts = append(parts, enc.Part(seq))
+	}
+
+	rng.Shuffle(len(parts), func(i, j int) { parts[i], parts[j] = parts[j], parts[i] })
+
+	var dec Decoder
+	for _, p := range parts {
+		if err := dec.Receive(strings.ToUpper(p)); err != nil {
+			t.Fatalf("Receive(%q) failed: %v", p, err)
+		}
+	}
+
+	if !dec.Complete() {
+		total, solved := dec.Fragments()
+		t.Fatalf("Decoder solved %d of %d fragments", len(solved), total)
+	}
+
+	got, err := dec.Result().Bytes()
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Result() = %d bytes, %v, want the message", len(got), err)
+	}
+
+	// A part of another message is rejected
+	other, err := NewEncoder(NewBytes(data[1:]), 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dec.Receive(other.Part(1)); !errors.Is(err, ErrInconsistentPart) {
+		t.Errorf("Receive() of another message error = %v, want %v", err, ErrInconsistentPart)
+	}
+}
+
+func TestSinglePart(t *testing.T) {
+	enc, err := NewEncoder(NewBytes([]byte("tiny")), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	part := enc.Part(1)
+	if enc.SeqLen() != 1 || strings.Count(part, "/") != 1 {
+		t.Fatalf("Part(1) = %q, want a single part UR", part)
+	}
+
+	if seq, err := SeqNum(part); err != nil || seq != 1 {
+		t.Errorf("SeqNum(%q) = %d, %v, want 1", part, seq, err)
+	}
+
+	var dec Decoder
+	if err := dec.Receive(part); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := dec.Result().Bytes(); err != nil || string(got) != "tiny" {
+		t.Errorf("Result() = %q, %v, want %q", got, err, "tiny")
+	}
+}
+
+func TestReceiveInvalid(t *testing.T) {
+	for _, text := range []string{"", "bytes/aeadaolazmjendeoti", "ur:Bytes!/aeadaolazmjendeoti", "ur:bytes/x-2/aeadaolazmjendeoti", "ur:bytes/aeadaolazmjendeota"} {
+		var dec Decoder
+		if err := dec.Receive(text); err == nil {
+			t.Errorf("Receive(%q) succeeded", text)
+		}
+	}
+}
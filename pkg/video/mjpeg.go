@@ -0,0 +1,310 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"os"
+)
+
+const (
+	// mjpegProbeSize is the size of the start of a file searched for a JPEG image
+	// to detect Motion JPEG
+	mjpegProbeSize = 4 << 20
+
+	// maxJPEGSize is the size above which a JPEG image is taken for garbage
+	maxJPEGSize = 64 << 20
+)
+
+// errInvalidJPEG is returned by jpegScanner.parse for bytes that only look like the
+// start of a JPEG image
+var errInvalidJPEG = errors.New("invalid JPEG image")
+
+// extractMJPEG writes the JPEG images found in the file at path. The images are
+// found by their markers, so that a raw stream and the common containers such as
+// AVI and MOV, which store every frame as a complete JPEG image, are read alike.
+func extractMJPEG(path string, w *frameWriter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	w.info.FrameRate = aviFrameRate(r)
+
+	s := &jpegScanner{r: r}
+
+	for {
+		data, err := s.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read input: %w", err)
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			w.info.Skipped++
+
+			continue
+		}
+
+		if err := w.write(img); err != nil {
+			return err
+		}
+	}
+}
+
+// findJPEG reports whether r holds a complete JPEG image
+func findJPEG(r io.Reader) (bool, error) {
+	s := &jpegScanner{r: bufio.NewReader(r)}
+
+	_, err := s.next()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// aviFrameRate returns the frame rate recorded in the main header of an AVI file read
+// by r, or 0 for other files. Nothing is consumed from r.
+func aviFrameRate(r *bufio.Reader) float64 {
+	head, _ := r.Peek(64 << 10)
+	if len(head) < 12 || string(head[:4]) != "RIFF" || string(head[8:12]) != "AVI " {
+		return 0
+	}
+
+	// The main header starts with the duration of a frame in microseconds
+	i := bytes.Index(head, []byte("avih"))
+	if i < 0 || len(head) < i+12 {
+		return 0
+	}
+
+	if usPerFrame := binary.LittleEndian.Uint32(head[i+8:]); usPerFrame > 0 {
+		return 1e6 / float64(usPerFrame)
+	}
+
+	return 0
+}
+
+// jpegScanner finds the JPEG images in a stream of bytes
+type jpegScanner struct {
+	r *bufio.Reader
+	// pending holds bytes read from r that are read again before r
+	pending []byte
+	// buf holds the bytes of the image being parsed
+	buf []byte
+}
+
+// readByte returns the next byte of the stream
+func (s *jpegScanner) readByte() (byte, error) {
+	if len(s.pending) > 0 {
+		c := s.pending[0]
+		s.pending = s.pending[1:]
+
+		return c, nil
+	}
+
+	return s.r.ReadByte()
+}
+
+// next returns the next complete JPEG image of the stream, or io.EOF at its end.
+// Bytes that only look like the start of an image, as they may in the other data of
+// a container, are searched again for a real one, and so is an image cut short at
+// the end of the stream.
+func (s *jpegScanner) next() ([]byte, error) {
+	for {
+		if err := s.findStart(); err != nil {
+			return nil, err
+		}
+
+		err := s.parse()
+		if err == nil {
+			return s.buf, nil
+		}
+
+		if !errors.Is(err, errInvalidJPEG) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+
+		// Search again from the byte after the false start of image marker
+		s.pending = append(append([]byte(nil), s.buf[1:]...), s.pending...)
+	}
+}
+
+// findStart consumes the stream up to and including the next start of image marker
+func (s *jpegScanner) findStart() error {
+	var prev byte
+
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return err
+		}
+
+		if prev == 0xFF && c == 0xD8 {
+			s.buf = append(s.buf[:0], 0xFF, 0xD8)
+
+			return nil
+		}
+
+		prev = c
+	}
+}
+
+// parse reads the segments of the image started in buf up to its end of image
+// marker. It fails with errInvalidJPEG if the bytes do not form an image with a
+// frame header and scan data, and with io.ErrUnexpectedEOF if the stream ends first.
+func (s *jpegScanner) parse() error {
+	var sawFrame, sawScan bool
+
+	marker, err := s.readMarker()
+
+	for {
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		if len(s.buf) > maxJPEGSize {
+			return errInvalidJPEG
+		}
+
+		switch {
+		case marker == 0xD9:
+			// End of image
+			if !sawFrame || !sawScan {
+				return errInvalidJPEG
+			}
+
+			return nil
+		case marker == 0x00 || marker == 0xD8:
+			return errInvalidJPEG
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			// Markers without a segment
+			marker, err = s.readMarker()
+
+			continue
+		}
+
+		if err := s.readSegment(); err != nil {
+			return err
+		}
+
+		// SOF0 to SOF15, except DHT, JPG, and DAC
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			sawFrame = true
+		}
+
+		if marker != 0xDA {
+			marker, err = s.readMarker()
+
+			continue
+		}
+
+		sawScan = true
+		marker, err = s.readScan()
+	}
+}
+
+// readMarker reads a marker, skipping fill bytes, and appends it to buf
+func (s *jpegScanner) readMarker() (byte, error) {
+	c, err := s.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	if c != 0xFF {
+		return 0, errInvalidJPEG
+	}
+
+	for c == 0xFF {
+		if c, err = s.readByte(); err != nil {
+			return 0, err
+		}
+	}
+
+	s.buf = append(s.buf, 0xFF, c)
+
+	return c, nil
+}
+
+// readSegment reads the length and data of a marker segment into buf
+func (s *jpegScanner) readSegment() error {
+	var length [2]byte
+
+	for i := range length {
+		c, err := s.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		length[i] = c
+	}
+
+	n := int(binary.BigEndian.Uint16(length[:]))
+	if n < 2 {
+		return errInvalidJPEG
+	}
+
+	s.buf = append(s.buf, length[0], length[1])
+
+	for range n - 2 {
+		c, err := s.readByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+
+		s.buf = append(s.buf, c)
+	}
+
+	return nil
+}
+
+// readScan reads entropy coded scan data into buf up to the next marker, which is
+// appended and returned. Stuffed zero bytes and restart markers belong to the data.
+func (s *jpegScanner) readScan() (byte, error) {
+	for {
+		c, err := s.readByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if c != 0xFF {
+			s.buf = append(s.buf, c)
+
+			if len(s.buf) > maxJPEGSize {
+				return 0, errInvalidJPEG
+			}
+
+			continue
+		}
+
+		for c == 0xFF {
+			if c, err = s.readByte(); err != nil {
+				return 0, err
+			}
+		}
+
+		s.buf = append(s.buf, 0xFF, c)
+
+		if c != 0x00 && (c < 0xD0 || c > 0xD7) {
+			return c, nil
+		}
+	}
+}
+
+// unexpectedEOF turns the end of the stream within an image into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
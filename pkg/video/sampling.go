@@ -0,0 +1,155 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoFrameRate is returned when frames are sampled by time from an input that
+// does not record its frame rate, such as a directory of images
+var ErrNoFrameRate = errors.New("input has no frame rate")
+
+// errEndReached stops the extraction at the end of the sampled range
+var errEndReached = errors.New("end of sampled range reached")
+
+// Sampling selects the frames extracted from a recording. The zero value extracts
+// every frame. The range is applied first, then EveryNth, then FPS.
+type Sampling struct {
+	// Start skips the frames before this time
+	Start time.Duration
+	// End stops before the frame at this time, 0 for the end of the recording
+	End time.Duration
+	// EveryNth keeps one frame out of every EveryNth in the range, 0 or 1 for all
+	EveryNth int
+	// FPS keeps at most this many frames per second, 0 for all
+	FPS float64
+}
+
+// Validate reports an invalid sampling
+func (s Sampling) Validate() error {
+	switch {
+	case s.Start < 0 || s.End < 0:
+		return fmt.Errorf("invalid range %s-%s, times must not be negative", s.Start, s.End)
+	case s.End > 0 && s.End <= s.Start:
+		return fmt.Errorf("invalid range %s-%s, the end must be after the start", s.Start, s.End)
+	case s.EveryNth < 0:
+		return fmt.Errorf("invalid frame step %d", s.EveryNth)
+	case s.FPS < 0:
+		return fmt.Errorf("invalid sampling rate %v", s.FPS)
+	}
+
+	return nil
+}
+
+// IsZero reports whether every frame is extracted
+func (s Sampling) IsZero() bool {
+	return s.Start == 0 && s.End == 0 && s.EveryNth <= 1 && s.FPS == 0
+}
+
+// byTime reports whether frames are selected by their time
+func (s Sampling) byTime() bool {
+	return s.Start > 0 || s.End > 0 || s.FPS > 0
+}
+
+// FrameRate returns the rate of the frames extracted from a recording of the given
+// frame rate, 0 if it is unknown
+func (s Sampling) FrameRate(rate float64) float64 {
+	if s.EveryNth > 1 {
+		rate /= float64(s.EveryNth)
+	}
+
+	if s.FPS > 0 && (rate == 0 || s.FPS < rate) {
+		rate = s.FPS
+	}
+
+	return rate
+}
+
+// sampler decides which frames of a recording a Sampling keeps
+type sampler struct {
+	Sampling
+	// inRange counts the frames seen within the range
+	inRange int
+	// next is the time in seconds of the next frame kept by FPS
+	next    float64
+	started bool
+}
+
+// keep reports whether the frame with index n of a recording at rate frames per
+// second is extracted. It returns errEndReached past the end of the range.
+func (s *sampler) keep(n int, rate float64) (bool, error) {
+	if !s.byTime() {
+		s.inRange++
+
+		return s.EveryNth <= 1 || (s.inRange-1)%s.EveryNth == 0, nil
+	}
+
+	if rate <= 0 {
+		return false, fmt.Errorf("%w, sample it by frames with every nth instead of by time", ErrNoFrameRate)
+	}
+
+	t := float64(n) / rate
+	if t < s.Start.Seconds() {
+		return false, nil
+	}
+
+	if s.End > 0 && t >= s.End.Seconds() {
+		return false, errEndReached
+	}
+
+	s.inRange++
+	if s.EveryNth > 1 && (s.inRange-1)%s.EveryNth != 0 {
+		return false, nil
+	}
+
+	if s.FPS <= 0 {
+		return true, nil
+	}
+
+	if !s.started {
+		s.next, s.started = t, true
+	}
+
+	// Allow for the rounding of frame times
+	if t < s.next-0.5/rate {
+		return false, nil
+	}
+
+	for s.next <= t+0.5/rate {
+		s.next += 1 / s.FPS
+	}
+
+	return true, nil
+}
+
+// ParseTimestamp parses a time in a recording given as seconds, e.g. "90" or
+// "12.5", as [hh:]mm:ss[.frac], e.g. "1:30" or "01:02:03.5", or as a Go duration,
+// e.g. "1m30s"
+func ParseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+
+	var seconds float64
+
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 || (i > 0 && value >= 60) || (i < len(parts)-1 && strings.Contains(part, ".")) {
+			return 0, fmt.Errorf("invalid time %q, expected seconds, [hh:]mm:ss, or a duration such as 1m30s", s)
+		}
+
+		seconds = seconds*60 + value
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
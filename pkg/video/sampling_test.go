@@ -0,0 +1,125 @@
+package video
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeY4M writes a y4m stream of n frames at 10fps, the grey level of frame i is i
+func writeY4M(t *testing.T, n int) string {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	b.WriteString("YUV4MPEG2 W32 H16 F10:1 Ip A1:1 Cmono\n")
+
+	for i := range n {
+		b.WriteString("FRAME\n")
+		b.Write(testFrame(uint8(i)).Pix)
+	}
+
+	path := filepath.Join(t.TempDir(), "capture.y4m")
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+
+	return path
+}
+
+func TestExtractSampledFrames(t *testing.T) {
+	path := writeY4M(t, 40)
+
+	for _, tc := range []struct {
+		name     string
+		sampling Sampling
+		want     []uint8
+	}{
+		{"range", Sampling{Start: time.Second, End: 1500 * time.Millisecond}, []uint8{10, 11, 12, 13, 14}},
+		{"every nth", Sampling{EveryNth: 8}, []uint8{0, 8, 16, 24, 32}},
+		{"fps", Sampling{FPS: 2.5, Start: 2 * time.Second}, []uint8{20, 24, 28, 32, 36}},
+		{"every nth in range", Sampling{Start: 3 * time.Second, EveryNth: 3}, []uint8{30, 33, 36, 39}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			info, err := ExtractSampledFrames(path, dir, tc.sampling)
+			if err != nil {
+				t.Fatalf("ExtractSampledFrames failed: %v", err)
+			}
+
+			if info.Frames != len(tc.want) {
+				t.Fatalf("Got %+v, want %d frames", info, len(tc.want))
+			}
+
+			for i, frame := range readFrames(t, dir, len(tc.want)) {
+				if got := grey(frame, 0, 0); got != tc.want[i] {
+					t.Errorf("Frame %d is frame %d of the stream, want %d", i+1, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtractSampledFramesInvalid(t *testing.T) {
+	if _, err := ExtractSampledFrames(writeY4M(t, 2), t.TempDir(), Sampling{Start: time.Second, End: time.Second}); err == nil {
+		t.Error("ExtractSampledFrames accepted an empty range")
+	}
+
+	// A directory of images has no frame rate to sample by time
+	var b bytes.Buffer
+	if err := png.Encode(&b, testFrame(0)); err != nil {
+		t.Fatalf("Failed to encode frame: %v", err)
+	}
+
+	input := t.TempDir()
+	if err := os.WriteFile(filepath.Join(input, "0.png"), b.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+
+	if _, err := ExtractSampledFrames(input, t.TempDir(), Sampling{FPS: 1}); !errors.Is(err, ErrNoFrameRate) {
+		t.Errorf("ExtractSampledFrames = %v, want ErrNoFrameRate", err)
+	}
+}
+
+func TestSamplingFrameRate(t *testing.T) {
+	if got := (Sampling{EveryNth: 2}).FrameRate(60); got != 30 {
+		t.Errorf("FrameRate() = %v, want 30", got)
+	}
+
+	if got := (Sampling{FPS: 10}).FrameRate(60); got != 10 {
+		t.Errorf("FrameRate() = %v, want 10", got)
+	}
+
+	if got := (Sampling{FPS: 10}).FrameRate(5); got != 5 {
+		t.Errorf("FrameRate() = %v, want 5", got)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"90":         90 * time.Second,
+		"12.5":       12500 * time.Millisecond,
+		"1:30":       90 * time.Second,
+		"01:02:03.5": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+		"1m30s":      90 * time.Second,
+	} {
+		if got, err := ParseTimestamp(s); err != nil || got != want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+
+	for _, s := range []string{"", "1:60", "1.5:30", "1:2:3:4", "-5", "abc"} {
+		if _, err := ParseTimestamp(s); err == nil {
+			t.Errorf("ParseTimestamp(%q) succeeded", s)
+		}
+	}
+
+	if _, err := ExtractSampledFrames("", "", Sampling{EveryNth: -1}); err == nil || errors.Is(err, ErrNoFrameRate) {
+		t.Errorf("ExtractSampledFrames = %v, want an invalid sampling", err)
+	}
+}
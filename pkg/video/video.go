@@ -0,0 +1,306 @@
+// Package video extracts the frames of a recording with the standard library only,
+// so that QR codes can be read from it without ffmpeg. It reads a directory of
+// images, a raw YUV4MPEG2 (.y4m) stream, and Motion JPEG, either as a raw stream of
+// JPEG images or inside a container such as AVI or MOV. Codecs like H.264 need
+// ffmpeg.
+package video
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Decoders of the images of a directory, the qrcode package registering BMP
+	_ "image/gif"
+	_ "image/jpeg"
+
+	_ "github.com/dyammarcano/qrfiletransfer/pkg/qrcode"
+)
+
+// Format is a kind of input the frames are extracted from
+type Format string
+
+const (
+	// FormatImages is a directory of images, played in name order
+	FormatImages Format = "images"
+	// FormatY4M is a raw YUV4MPEG2 stream
+	FormatY4M Format = "y4m"
+	// FormatMJPEG is a stream of JPEG images, raw or in a container
+	FormatMJPEG Format = "mjpeg"
+)
+
+// ErrUnsupported is returned for an input that is not a directory of images, a
+// YUV4MPEG2 stream, or Motion JPEG
+var ErrUnsupported = errors.New("unsupported video format")
+
+// Info describes the frames extracted from an input
+type Info struct {
+	// Format is the kind of input
+	Format Format
+	// Frames is the number of frames written
+	Frames int
+	// Skipped is the number of frames that could not be decoded
+	Skipped int
+	// Dropped is the number of frames left out by the Sampling
+	Dropped int
+	// FrameRate is the frame rate recorded in the input, 0 if it is unknown
+	FrameRate float64
+}
+
+// FramePattern is the name of the extracted frame files, numbered from 1 like the
+// frames extracted by ffmpeg
+const FramePattern = "frame_%04d.png"
+
+// Detect returns the format of the input at path
+func Detect(path string) (Format, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open input: %w", err)
+	}
+
+	if info.IsDir() {
+		return FormatImages, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open input: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+
+	if magic, _ := r.Peek(len(y4mMagic)); string(magic) == y4mMagic {
+		return FormatY4M, nil
+	}
+
+	if found, err := findJPEG(io.LimitReader(r, mjpegProbeSize)); err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	} else if found {
+		return FormatMJPEG, nil
+	}
+
+	return "", fmt.Errorf("%w: %s is not a directory of images, a y4m stream, or Motion JPEG", ErrUnsupported, path)
+}
+
+// ExtractFrames writes the frames of the input at path into outputDir as PNG files
+// named after FramePattern. Frames of a stream that cannot be decoded are skipped
+// and counted, the extraction fails only if no frame is left.
+func ExtractFrames(path, outputDir string) (*Info, error) {
+	return ExtractSampledFrames(path, outputDir, Sampling{})
+}
+
+// ExtractSampledFrames is ExtractFrames writing only the frames selected by
+// sampling. Sampling by time needs the frame rate of the input, it returns an
+// error wrapping ErrNoFrameRate for an input that does not record it.
+func ExtractSampledFrames(path, outputDir string, sampling Sampling) (*Info, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create frames directory: %w", err)
+	}
+
+	var count int
+
+	return StreamFrames(path, sampling, func(img image.Image) error {
+		count++
+
+		return writePNG(filepath.Join(outputDir, fmt.Sprintf(FramePattern, count)), img)
+	})
+}
+
+// StreamFrames passes the frames of the input at path selected by sampling to fn
+// in order, decoded in memory rather than written to files. An error returned by
+// fn stops the extraction and is returned. Frames that cannot be decoded are
+// skipped as by ExtractFrames.
+func StreamFrames(path string, sampling Sampling, fn func(img image.Image) error) (*Info, error) {
+	if err := sampling.Validate(); err != nil {
+		return nil, err
+	}
+
+	format, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &frameWriter{sink: fn, info: &Info{Format: format}, sampler: &sampler{Sampling: sampling}}
+
+	switch format {
+	case FormatImages:
+		err = extractImages(path, w)
+	case FormatY4M:
+		err = extractY4M(path, w)
+	default:
+		err = extractMJPEG(path, w)
+	}
+
+	if err != nil && !errors.Is(err, errEndReached) {
+		return nil, err
+	}
+
+	if w.info.Frames == 0 {
+		return nil, fmt.Errorf("no frames could be decoded from %s", path)
+	}
+
+	return w.info, nil
+}
+
+// StreamY4M is StreamFrames for a YUV4MPEG2 stream read from r, such as the output
+// of ffmpeg -f yuv4mpegpipe
+func StreamY4M(r io.Reader, sampling Sampling, fn func(img image.Image) error) (*Info, error) {
+	if err := sampling.Validate(); err != nil {
+		return nil, err
+	}
+
+	w := &frameWriter{sink: fn, info: &Info{Format: FormatY4M}, sampler: &sampler{Sampling: sampling}}
+
+	if err := readY4M(bufio.NewReaderSize(r, 1<<20), w); err != nil && !errors.Is(err, errEndReached) {
+		return nil, err
+	}
+
+	if w.info.Frames == 0 {
+		return nil, errors.New("no frames could be decoded from the stream")
+	}
+
+	return w.info, nil
+}
+
+// Probe returns the format and frame rate of the input at path without decoding
+// its frames, the frame counts of the returned Info are 0
+func Probe(path string) (*Info, error) {
+	format, err := Detect(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{Format: format}
+	if format == FormatImages {
+		return info, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	r := bufio.NewReader(f)
+
+	if format == FormatMJPEG {
+		info.FrameRate = aviFrameRate(r)
+
+		return info, nil
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read y4m header: %w", err)
+	}
+
+	header, err := parseY4MHeader(strings.TrimSuffix(line, "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	info.FrameRate = header.frameRate
+
+	return info, nil
+}
+
+// frameWriter counts the extracted frames and passes them to its sink
+type frameWriter struct {
+	sink    func(img image.Image) error
+	info    *Info
+	sampler *sampler
+}
+
+// write passes img to the sink as the next frame, unless the sampling leaves it out
+func (w *frameWriter) write(img image.Image) error {
+	n := w.info.Frames + w.info.Skipped + w.info.Dropped
+	if keep, err := w.sampler.keep(n, w.info.FrameRate); err != nil {
+		return err
+	} else if !keep {
+		w.info.Dropped++
+
+		return nil
+	}
+
+	if err := w.sink(img); err != nil {
+		return err
+	}
+
+	w.info.Frames++
+
+	return nil
+}
+
+// writePNG writes img as a PNG file at path
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create frame: %w", err)
+	}
+
+	encoder := png.Encoder{CompressionLevel: png.BestSpeed}
+
+	err = encoder.Encode(f, img)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write frame %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// imageExts are the extensions of the image files read from a directory
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true}
+
+// extractImages writes the images of dir in name order, other files are ignored
+func extractImages(dir string, w *frameWriter) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !imageExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+
+		img, err := decodeImageFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			w.info.Skipped++
+
+			continue
+		}
+
+		if err := w.write(img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeImageFile decodes the image file at path
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+
+	return img, err
+}
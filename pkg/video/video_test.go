@@ -0,0 +1,268 @@
+package video
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrame returns a grey frame whose left half has the grey level y
+func testFrame(y uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 32, 16))
+	for x := range 16 {
+		for row := range 16 {
+			img.SetGray(x, row, color.Gray{Y: y})
+		}
+	}
+
+	return img
+}
+
+// readFrames decodes the frames written into dir
+func readFrames(t *testing.T, dir string, n int) []image.Image {
+	t.Helper()
+
+	var frames []image.Image
+
+	for i := range n {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf(FramePattern, i+1)))
+		if err != nil {
+			t.Fatalf("Failed to open frame %d: %v", i+1, err)
+		}
+
+		img, err := png.Decode(f)
+		_ = f.Close()
+
+		if err != nil {
+			t.Fatalf("Failed to decode frame %d: %v", i+1, err)
+		}
+
+		frames = append(frames, img)
+	}
+
+	return frames
+}
+
+// grey returns the grey level of img at x, y
+func grey(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestExtractFramesY4M(t *testing.T) {
+	var b bytes.Buffer
+
+	b.WriteString("YUV4MPEG2 W32 H16 F240:1 Ip A1:1 C420jpeg\n")
+
+	for _, y := range []uint8{10, 200} {
+		b.WriteString("FRAME\n")
+		b.Write(testFrame(y).Pix)
+		b.Write(bytes.Repeat([]byte{128}, 2*16*8))
+	}
+
+	// A frame cut short is dropped
+	b.WriteString("FRAME\n")
+	b.Write(make([]byte, 100))
+
+	path := filepath.Join(t.TempDir(), "capture.y4m")
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+
+	dir := t.TempDir()
+
+	info, err := ExtractFrames(path, dir)
+	if err != nil {
+		t.Fatalf("ExtractFrames failed: %v", err)
+	}
+
+	if info.Format != FormatY4M || info.Frames != 2 || info.Skipped != 1 || info.FrameRate != 240 {
+		t.Fatalf("Got %+v, want 2 y4m frames, 1 skipped, at 240fps", info)
+	}
+
+	frames := readFrames(t, dir, 2)
+	if grey(frames[0], 0, 0) != 10 || grey(frames[1], 0, 0) != 200 || grey(frames[1], 31, 15) != 0 {
+		t.Fatal("Frames do not hold the luma planes of the stream")
+	}
+}
+
+func TestParseY4MHeader(t *testing.T) {
+	tests := []struct {
+		header         string
+		chroma, depth  int
+		bytesPerSample int
+	}{
+		{"YUV4MPEG2 W5 H3", 2 * 3 * 2, 8, 1},
+		{"YUV4MPEG2 W5 H3 C420mpeg2", 2 * 3 * 2, 8, 1},
+		{"YUV4MPEG2 W5 H3 C422", 2 * 3 * 3, 8, 1},
+		{"YUV4MPEG2 W5 H3 C444p10", 2 * 5 * 3, 10, 2},
+		{"YUV4MPEG2 W5 H3 C444alpha", 3 * 5 * 3, 8, 1},
+		{"YUV4MPEG2 W5 H3 Cmono16", 0, 16, 2},
+	}
+
+	for _, tt := range tests {
+		h, err := parseY4MHeader(tt.header)
+		if err != nil {
+			t.Fatalf("parseY4MHeader(%q) failed: %v", tt.header, err)
+		}
+
+		if h.chroma != tt.chroma || h.depth != tt.depth || h.bytesPerSample != tt.bytesPerSample {
+			t.Errorf("parseY4MHeader(%q) = %+v", tt.header, h)
+		}
+	}
+
+	for _, header := range []string{"YUV4MPEG2 H3", "YUV4MPEG2 W5 H3 C420p4", "YUV4MPEG2 W5 H3 Cxyz"} {
+		if _, err := parseY4MHeader(header); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("parseY4MHeader(%q) = %v, want ErrUnsupported", header, err)
+		}
+	}
+}
+
+func TestExtractFramesMJPEG(t *testing.T) {
+	var b bytes.Buffer
+
+	// Container data around the frames, with bytes that look like the start of an
+	// image
+	b.WriteString("RIFF\x00\x00\x00\x00AVI LIST\xff\xd8\xff\xe0 junk")
+
+	for _, y := range []uint8{0, 255} {
+		if err := jpeg.Encode(&b, testFrame(y), &jpeg.Options{Quality: 95}); err != nil {
+			t.Fatalf("Failed to encode frame: %v", err)
+		}
+
+		b.WriteString("00dc\xff\x00")
+	}
+
+	// A frame cut short is dropped
+	var last bytes.Buffer
+	if err := jpeg.Encode(&last, testFrame(128), nil); err != nil {
+		t.Fatalf("Failed to encode frame: %v", err)
+	}
+
+	b.Write(last.Bytes()[:last.Len()/2])
+
+	path := filepath.Join(t.TempDir(), "capture.avi")
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatalf("Failed to write stream: %v", err)
+	}
+
+	dir := t.TempDir()
+
+	info, err := ExtractFrames(path, dir)
+	if err != nil {
+		t.Fatalf("ExtractFrames failed: %v", err)
+	}
+
+	if info.Format != FormatMJPEG || info.Frames != 2 || info.Skipped != 0 {
+		t.Fatalf("Got %+v, want 2 mjpeg frames", info)
+	}
+
+	frames := readFrames(t, dir, 2)
+	if grey(frames[0], 4, 4) > 20 || grey(frames[1], 4, 4) < 235 {
+		t.Fatal("Frames do not hold the images of the stream")
+	}
+}
+
+func TestExtractFramesImages(t *testing.T) {
+	input := t.TempDir()
+
+	for i, y := range []uint8{50, 150} {
+		var b bytes.Buffer
+		if err := png.Encode(&b, testFrame(y)); err != nil {
+			t.Fatalf("Failed to encode frame: %v", err)
+		}
+
+		if err := os.WriteFile(filepath.Join(input, fmt.Sprintf("%d.png", i)), b.Bytes(), 0600); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(input, "notes.txt"), []byte("not a frame"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dir := t.TempDir()
+
+	info, err := ExtractFrames(input, dir)
+	if err != nil {
+		t.Fatalf("ExtractFrames failed: %v", err)
+	}
+
+	if info.Format != FormatImages || info.Frames != 2 || info.Skipped != 0 {
+		t.Fatalf("Got %+v, want 2 images", info)
+	}
+
+	frames := readFrames(t, dir, 2)
+	if grey(frames[0], 0, 0) != 50 || grey(frames[1], 0, 0) != 150 {
+		t.Fatal("Frames are not the images in name order")
+	}
+}
+
+func TestDetectUnsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.mp4")
+	if err := os.WriteFile(path, []byte("\x00\x00\x00\x18ftypmp42 h264 data\xff\xd8"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if _, err := ExtractFrames(path, t.TempDir()); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("ExtractFrames = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestStreamY4M(t *testing.T) {
+	var b bytes.Buffer
+
+	b.WriteString("YUV4MPEG2 W32 H16 F30:1 Ip A1:1 Cmono\n")
+
+	for _, y := range []uint8{10, 200, 90} {
+		b.WriteString("FRAME\n")
+		b.Write(testFrame(y).Pix)
+	}
+
+	var levels []uint8
+
+	info, err := StreamY4M(&b, Sampling{EveryNth: 2}, func(img image.Image) error {
+		levels = append(levels, grey(img, 0, 0))
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamY4M failed: %v", err)
+	}
+
+	if info.Frames != 2 || info.Dropped != 1 || info.FrameRate != 30 || len(levels) != 2 || levels[0] != 10 || levels[1] != 90 {
+		t.Fatalf("Got %+v with grey levels %v, want frames 1 and 3 at 30fps", info, levels)
+	}
+
+	// An error of the sink stops the stream
+	stop := errors.New("stop")
+	if _, err := StreamY4M(bytes.NewReader(nil), Sampling{}, nil); err == nil {
+		t.Error("StreamY4M read frames from an empty stream")
+	}
+
+	path := writeY4M(t, 5)
+	if _, err := StreamFrames(path, Sampling{}, func(image.Image) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("StreamFrames = %v, want the error of the sink", err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	info, err := Probe(writeY4M(t, 3))
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+
+	if info.Format != FormatY4M || info.FrameRate != 10 || info.Frames != 0 {
+		t.Errorf("Probe() = %+v, want a 10fps y4m stream", info)
+	}
+
+	if info, err := Probe(t.TempDir()); err != nil || info.Format != FormatImages || info.FrameRate != 0 {
+		t.Errorf("Probe() = %+v, %v, want a directory of images", info, err)
+	}
+}
@@ -0,0 +1,192 @@
+package video
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// y4mMagic starts the header of a YUV4MPEG2 stream
+const y4mMagic = "YUV4MPEG2 "
+
+// y4mHeader holds the parameters of a YUV4MPEG2 stream needed to read its frames
+type y4mHeader struct {
+	width, height int
+	// chroma is the number of chroma (and alpha) samples per frame
+	chroma int
+	// bytesPerSample is 2 for streams of more than 8 bits per sample
+	bytesPerSample int
+	// depth is the number of bits per sample
+	depth     int
+	frameRate float64
+}
+
+// extractY4M writes the frames of the YUV4MPEG2 stream at path. Only the luma plane
+// is kept, as a grey image, which is all a QR code decoder looks at.
+func extractY4M(path string, w *frameWriter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return readY4M(bufio.NewReaderSize(f, 1<<20), w)
+}
+
+// readY4M writes the frames of the YUV4MPEG2 stream read from r
+func readY4M(r *bufio.Reader, w *frameWriter) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read y4m header: %w", err)
+	}
+
+	header, err := parseY4MHeader(strings.TrimSuffix(line, "\n"))
+	if err != nil {
+		return err
+	}
+
+	w.info.FrameRate = header.frameRate
+
+	luma := make([]byte, header.width*header.height*header.bytesPerSample)
+
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read y4m frame header: %w", err)
+		}
+
+		if !strings.HasPrefix(line, "FRAME") {
+			return fmt.Errorf("%w: invalid y4m frame header %q", ErrUnsupported, strings.TrimSpace(line))
+		}
+
+		// A frame cut short at the end of a recording is dropped
+		if _, err := io.ReadFull(r, luma); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				w.info.Skipped++
+
+				return nil
+			}
+
+			return fmt.Errorf("failed to read y4m frame: %w", err)
+		}
+
+		if _, err := r.Discard(header.chroma * header.bytesPerSample); err != nil {
+			w.info.Skipped++
+
+			return nil
+		}
+
+		if err := w.write(header.lumaImage(luma)); err != nil {
+			return err
+		}
+	}
+}
+
+// lumaImage returns the luma plane of a frame as a grey image
+func (h *y4mHeader) lumaImage(luma []byte) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, h.width, h.height))
+
+	if h.bytesPerSample == 1 {
+		copy(img.Pix, luma)
+
+		return img
+	}
+
+	// Samples of more than 8 bits are little endian
+	shift := h.depth - 8
+	for i := range img.Pix {
+		img.Pix[i] = uint8((int(luma[2*i]) | int(luma[2*i+1])<<8) >> shift)
+	}
+
+	return img
+}
+
+// parseY4MHeader parses the header line of a YUV4MPEG2 stream, e.g.
+// "YUV4MPEG2 W640 H480 F30:1 Ip A1:1 C420jpeg"
+func parseY4MHeader(line string) (*y4mHeader, error) {
+	h := &y4mHeader{bytesPerSample: 1, depth: 8}
+	colorSpace := "420"
+
+	for _, field := range strings.Fields(strings.TrimPrefix(line, y4mMagic)) {
+		value := field[1:]
+
+		switch field[0] {
+		case 'W':
+			h.width, _ = strconv.Atoi(value)
+		case 'H':
+			h.height, _ = strconv.Atoi(value)
+		case 'F':
+			num, den, _ := strings.Cut(value, ":")
+			n, _ := strconv.ParseFloat(num, 64)
+			if d, _ := strconv.ParseFloat(den, 64); d > 0 {
+				h.frameRate = n / d
+			}
+		case 'C':
+			colorSpace = value
+		}
+	}
+
+	if h.width <= 0 || h.height <= 0 {
+		return nil, fmt.Errorf("%w: y4m header without frame size", ErrUnsupported)
+	}
+
+	if err := h.setColorSpace(colorSpace); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// y4mChromaPlanes maps the y4m color spaces to the number of chroma (and alpha)
+// samples per frame of a width by height frame, longest names first
+var y4mChromaPlanes = []struct {
+	name    string
+	samples func(width, height int) int
+}{
+	{"444alpha", func(w, h int) int { return 3 * w * h }},
+	{"420jpeg", func(w, h int) int { return 2 * ((w + 1) / 2) * ((h + 1) / 2) }},
+	{"420paldv", func(w, h int) int { return 2 * ((w + 1) / 2) * ((h + 1) / 2) }},
+	{"420mpeg2", func(w, h int) int { return 2 * ((w + 1) / 2) * ((h + 1) / 2) }},
+	{"420", func(w, h int) int { return 2 * ((w + 1) / 2) * ((h + 1) / 2) }},
+	{"422", func(w, h int) int { return 2 * ((w + 1) / 2) * h }},
+	{"411", func(w, h int) int { return 2 * ((w + 3) / 4) * h }},
+	{"444", func(w, h int) int { return 2 * w * h }},
+	{"mono", func(int, int) int { return 0 }},
+}
+
+// setColorSpace sets the chroma plane size and sample depth of a y4m color space,
+// e.g. "420jpeg", "444p10", "mono" or "mono16"
+func (h *y4mHeader) setColorSpace(colorSpace string) error {
+	for _, planes := range y4mChromaPlanes {
+		depth, ok := strings.CutPrefix(colorSpace, planes.name)
+		if !ok {
+			continue
+		}
+
+		if depth = strings.TrimPrefix(depth, "p"); depth != "" {
+			d, err := strconv.Atoi(depth)
+			if err != nil || d < 8 || d > 16 {
+				break
+			}
+
+			h.depth = d
+			if d > 8 {
+				h.bytesPerSample = 2
+			}
+		}
+
+		h.chroma = planes.samples(h.width, h.height)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: y4m color space %s", ErrUnsupported, colorSpace)
+}
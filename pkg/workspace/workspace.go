@@ -0,0 +1,234 @@
+// Package workspace manages the intermediate files and directories of a run, such
+// as split chunks, extracted frames, and decoded chunks, under one retention
+// policy: none of them are kept when the run ends, only the decoded data, or all of
+// them. Paths that are not kept are tracked by a cleanup.Manager, so that they are
+// removed even when the run fails or is interrupted.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/cleanup"
+	"github.com/spf13/afero"
+)
+
+// ErrInvalidRetention is returned by ParseRetention for an unknown retention
+var ErrInvalidRetention = errors.New("invalid retention")
+
+// Retention tells which intermediate paths are kept when they are released
+type Retention int
+
+const (
+	// KeepNone removes every intermediate path
+	KeepNone Retention = iota
+	// KeepData keeps the decoded chunks, e.g. to resume a transfer with --state
+	KeepData
+	// KeepAll keeps every intermediate path, e.g. to inspect the frames
+	KeepAll
+)
+
+// ParseRetention parses a retention named none, data, or all
+func ParseRetention(s string) (Retention, error) {
+	switch s {
+	case "none", "":
+		return KeepNone, nil
+	case "data":
+		return KeepData, nil
+	case "all":
+		return KeepAll, nil
+	}
+
+	return KeepNone, fmt.Errorf("%w '%s' (expected none, data, or all)", ErrInvalidRetention, s)
+}
+
+// String returns the name of the retention, as parsed by ParseRetention
+func (r Retention) String() string {
+	switch r {
+	case KeepData:
+		return "data"
+	case KeepAll:
+		return "all"
+	}
+
+	return "none"
+}
+
+// Kind is the kind of an intermediate path, which decides whether it is kept
+type Kind int
+
+const (
+	// Scratch is a path only needed while an operation runs, such as split chunks
+	Scratch Kind = iota
+	// Frames is a directory of frames extracted from, or rendered for, a video
+	Frames
+	// Data is a directory of decoded chunks, which a later run can resume from
+	Data
+)
+
+// String returns the name of the kind
+func (k Kind) String() string {
+	switch k {
+	case Frames:
+		return "frames"
+	case Data:
+		return "data"
+	}
+
+	return "scratch"
+}
+
+// keptPath is a path kept in place and the file system it is on
+type keptPath struct {
+	fs   afero.Fs
+	path string
+}
+
+// Workspace creates and tracks the intermediate paths of a run, safely for
+// concurrent use. A nil Workspace keeps nothing and tracks nothing, releasing a
+// path removes it right away.
+type Workspace struct {
+	mu        sync.Mutex
+	cleanup   *cleanup.Manager
+	retention Retention
+	base      string
+	kept      []keptPath
+}
+
+// New returns a Workspace tracking the paths it does not keep in m
+func New(m *cleanup.Manager, retention Retention) *Workspace {
+	return &Workspace{cleanup: m, retention: retention}
+}
+
+// SetRetention sets which paths are kept, for the paths tracked from now on
+func (w *Workspace) SetRetention(retention Retention) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.retention = retention
+}
+
+// Retention returns which paths are kept
+func (w *Workspace) Retention() Retention {
+	if w == nil {
+		return KeepNone
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.retention
+}
+
+// SetBase sets the directory Create creates the directories in, the default
+// directory for temporary files if empty
+func (w *Workspace) SetBase(dir string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.base = dir
+}
+
+// Keeps reports whether paths of kind are kept
+func (w *Workspace) Keeps(kind Kind) bool {
+	switch w.Retention() {
+	case KeepAll:
+		return true
+	case KeepData:
+		return kind == Data
+	}
+
+	return false
+}
+
+// Create creates a directory of kind on fs, named after pattern followed by a
+// random string in place of a trailing *, and tracks it, see Track. The base
+// directory is created first if it does not exist.
+func (w *Workspace) Create(fs afero.Fs, kind Kind, pattern string) (dir string, release func() error, err error) {
+	var base string
+	if w != nil {
+		w.mu.Lock()
+		base = w.base
+		w.mu.Unlock()
+	}
+
+	if base != "" {
+		if err := fs.MkdirAll(base, 0750); err != nil {
+			return "", nil, err
+		}
+	}
+
+	dir, err = afero.TempDir(fs, base, strings.TrimSuffix(pattern, "*"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return dir, w.Track(fs, kind, dir), nil
+}
+
+// Track registers path, an intermediate file or directory of kind on fs. A path
+// that is kept is left in place and listed by Kept, its release function does
+// nothing. Otherwise it is removed by its release function, or by the cleanup of
+// the run if it is never released.
+func (w *Workspace) Track(fs afero.Fs, kind Kind, path string) (release func() error) {
+	if w == nil {
+		return func() error {
+			return fs.RemoveAll(path)
+		}
+	}
+
+	if !w.Keeps(kind) {
+		return w.cleanup.Track(fs, path)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.kept = append(w.kept, keptPath{fs: fs, path: path})
+
+	return func() error {
+		return nil
+	}
+}
+
+// Keep stops tracking path without removing it, for an intermediate path that
+// turned out to be worth keeping whatever the retention, e.g. the decoded chunks
+// of an interrupted transfer
+func (w *Workspace) Keep(path string) {
+	if w == nil {
+		return
+	}
+
+	w.cleanup.Keep(path)
+}
+
+// Kept returns the paths kept by the retention that exist, in the order they were
+// tracked, e.g. to list them when the run ends
+func (w *Workspace) Kept() []string {
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var paths []string
+
+	for _, k := range w.kept {
+		if ok, err := afero.Exists(k.fs, k.path); err == nil && ok {
+			paths = append(paths, k.path)
+		}
+	}
+
+	return paths
+}
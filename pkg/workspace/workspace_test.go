@@ -0,0 +1,118 @@
+package workspace
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dyammarcano/qrfiletransfer/pkg/cleanup"
+	"github.com/spf13/afero"
+)
+
+func TestParseRetention(t *testing.T) {
+	for _, r := range []Retention{KeepNone, KeepData, KeepAll} {
+		if got, err := ParseRetention(r.String()); err != nil || got != r {
+			t.Errorf("ParseRetention(%q) = %v, %v", r, got, err)
+		}
+	}
+
+	if _, err := ParseRetention("frames"); !errors.Is(err, ErrInvalidRetention) {
+		t.Errorf("Expected ErrInvalidRetention, got %v", err)
+	}
+}
+
+func TestWorkspaceRetention(t *testing.T) {
+	tests := []struct {
+		retention Retention
+		kept      []Kind
+	}{
+		{KeepNone, nil},
+		{KeepData, []Kind{Data}},
+		{KeepAll, []Kind{Scratch, Frames, Data}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.retention.String(), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			m := cleanup.NewManager()
+			w := New(m, tt.retention)
+			w.SetBase("/work")
+
+			var want []string
+
+			for _, kind := range []Kind{Scratch, Frames, Data} {
+				dir, release, err := w.Create(fs, kind, kind.String()+"_*")
+				if err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+
+				if filepath.Dir(dir) != "/work" {
+					t.Errorf("Created %s outside of the base directory", dir)
+				}
+
+				if err := release(); err != nil {
+					t.Fatalf("release failed: %v", err)
+				}
+
+				exists, _ := afero.DirExists(fs, dir)
+				if kept := w.Keeps(kind); exists != kept {
+					t.Errorf("%s directory exists: %v, want %v", kind, exists, kept)
+				}
+
+				if exists {
+					want = append(want, dir)
+				}
+			}
+
+			if got := w.Kept(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Kept() = %v, want %v", got, want)
+			}
+
+			if len(m.Tracked()) != 0 {
+				t.Errorf("Released paths are still tracked: %v", m.Tracked())
+			}
+		})
+	}
+}
+
+func TestWorkspaceCleanupAndKeep(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	m := cleanup.NewManager()
+	w := New(m, KeepNone)
+
+	scratch, _, err := w.Create(fs, Scratch, "scratch_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _, err := w.Create(fs, Data, "data_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chunks of a failed transfer are worth keeping
+	w.Keep(data)
+
+	if err := m.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+
+	if ok, _ := afero.DirExists(fs, scratch); ok {
+		t.Error("Cleanup left the scratch directory")
+	}
+
+	if ok, _ := afero.DirExists(fs, data); !ok {
+		t.Error("Cleanup removed the directory to keep")
+	}
+
+	// A nil workspace removes a path as it is released
+	var nilWorkspace *Workspace
+	if err := nilWorkspace.Track(fs, Data, data)(); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+
+	if ok, _ := afero.DirExists(fs, data); ok || nilWorkspace.Keeps(Data) {
+		t.Error("Expected a nil workspace to keep nothing")
+	}
+}
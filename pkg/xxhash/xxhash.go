@@ -0,0 +1,175 @@
+// Package xxhash implements the 64-bit xxHash, XXH64, a non-cryptographic hash
+// that is several times faster than SHA-256 on large inputs. It detects accidental
+// corruption, not tampering.
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// Size is the size of an XXH64 checksum in bytes
+const Size = 8
+
+// BlockSize is the size of the stripes XXH64 processes in bytes
+const BlockSize = 32
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// digest is the state of an XXH64 computation
+type digest struct {
+	seed uint64
+	// v holds the accumulators of the stripes
+	v [4]uint64
+	// total is the number of bytes written
+	total uint64
+	// mem buffers the bytes of an incomplete stripe
+	mem [BlockSize]byte
+	n   int
+}
+
+// New returns a hash.Hash64 computing the XXH64 checksum with seed 0. Its Sum
+// appends the checksum in big endian, the canonical representation of XXH64.
+func New() hash.Hash64 {
+	return NewWithSeed(0)
+}
+
+// NewWithSeed returns a hash.Hash64 computing the XXH64 checksum with seed
+func NewWithSeed(seed uint64) hash.Hash64 {
+	d := &digest{seed: seed}
+	d.Reset()
+
+	return d
+}
+
+// Sum64 returns the XXH64 checksum of data with seed 0
+func Sum64(data []byte) uint64 {
+	d := New()
+	_, _ = d.Write(data)
+
+	return d.Sum64()
+}
+
+// Reset implements hash.Hash
+func (d *digest) Reset() {
+	d.v = [4]uint64{d.seed + prime1 + prime2, d.seed + prime2, d.seed, d.seed - prime1}
+	d.total = 0
+	d.n = 0
+}
+
+// Size implements hash.Hash
+func (d *digest) Size() int {
+	return Size
+}
+
+// BlockSize implements hash.Hash
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
+
+// Write implements io.Writer, it never fails
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+	d.total += uint64(written)
+
+	// Complete the buffered stripe first
+	if d.n > 0 {
+		n := copy(d.mem[d.n:], p)
+		d.n += n
+		p = p[n:]
+
+		if d.n < BlockSize {
+			return written, nil
+		}
+
+		d.stripe(d.mem[:])
+		d.n = 0
+	}
+
+	for ; len(p) >= BlockSize; p = p[BlockSize:] {
+		d.stripe(p)
+	}
+
+	d.n = copy(d.mem[:], p)
+
+	return written, nil
+}
+
+// stripe processes the first BlockSize bytes of p
+func (d *digest) stripe(p []byte) {
+	for i := range d.v {
+		d.v[i] = round(d.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+// Sum implements hash.Hash
+func (d *digest) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, d.Sum64())
+}
+
+// Sum64 implements hash.Hash64
+func (d *digest) Sum64() uint64 {
+	var h uint64
+
+	if d.total >= BlockSize {
+		v := d.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) + bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+
+		for _, acc := range v {
+			h = mergeRound(h, acc)
+		}
+	} else {
+		h = d.seed + prime5
+	}
+
+	h += d.total
+
+	p := d.mem[:d.n]
+
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		p = p[4:]
+	}
+
+	for _, b := range p {
+		h ^= uint64(b) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	// Avalanche
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+
+	return h
+}
+
+// round mixes 8 bytes of input into an accumulator
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+
+	return acc * prime1
+}
+
+// mergeRound merges an accumulator into the checksum
+func mergeRound(h, acc uint64) uint64 {
+	h ^= round(0, acc)
+
+	return h*prime1 + prime4
+}
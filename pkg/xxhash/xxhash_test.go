@@ -0,0 +1,67 @@
+package xxhash
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSum64(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+	}
+
+	for _, tt := range tests {
+		if got := Sum64([]byte(tt.input)); got != tt.want {
+			t.Errorf("Sum64(%q) = %#x, want %#x", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// Lengths around the 32 byte stripes and the 8 and 4 byte tails
+	tests := []struct {
+		n    int
+		want uint64
+	}{
+		{0, 0xef46db3751d8e999},
+		{1, 0xe934a84adb052768},
+		{3, 0xe5c7bb4533bc65dd},
+		{4, 0xffced8604453cc1e},
+		{7, 0x14cc643f630c72d2},
+		{8, 0x884a173614b81b8d},
+		{15, 0xa948f5f0f6abac2d},
+		{31, 0xc346d2b59b4d8ee1},
+		{32, 0xcbf59c5116ff32b4},
+		{33, 0x0c535d1acafb8ead},
+		{100, 0x6ac1e58032166597},
+		{1000, 0xf306f04aa88b54d3},
+	}
+
+	for _, tt := range tests {
+		data := make([]byte, tt.n)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+
+		if got := Sum64(data); got != tt.want {
+			t.Errorf("Sum64(%d bytes) = %#x, want %#x", tt.n, got, tt.want)
+		}
+
+		// Written a few bytes at a time
+		h := New()
+		for p := data; len(p) > 0; {
+			n := min(len(p), 5)
+			_, _ = h.Write(p[:n])
+			p = p[n:]
+		}
+
+		if got := binary.BigEndian.Uint64(h.Sum(nil)); got != tt.want {
+			t.Errorf("Write(%d bytes) in pieces = %#x, want %#x", tt.n, got, tt.want)
+		}
+	}
+}